@@ -0,0 +1,168 @@
+// Package app runs the HTTP server and the background workers as one unit. Run starts the
+// workers and the server, serves until its context is cancelled, e.g. on SIGTERM, and then
+// shuts down in order: the server stops accepting connections and lets the requests in
+// flight finish, the workers are told to stop, and the resources registered with OnClose,
+// such as the database pool, are released. All of it has to fit in the shutdown timeout.
+package app
+
+import (
+	"adong-be/logger"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Worker is a background task running for the life of the application
+type Worker struct {
+	Name string
+	// Start prepares the worker before the server takes requests and fails when the worker
+	// cannot run. Optional.
+	Start func(ctx context.Context) error
+	// Run works until ctx is cancelled, then returns
+	Run func(ctx context.Context)
+}
+
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// App is the server with its workers and the resources to release on shutdown
+type App struct {
+	Server          *http.Server
+	ShutdownTimeout time.Duration
+
+	workers []Worker
+	closers []closer
+
+	listener      net.Listener
+	serveErr      chan error
+	cancelWorkers context.CancelFunc
+	running       map[string]chan struct{}
+}
+
+// New returns an application serving with server
+func New(server *http.Server, shutdownTimeout time.Duration) *App {
+	return &App{Server: server, ShutdownTimeout: shutdownTimeout}
+}
+
+// Add registers a worker, started by Start
+func (a *App) Add(w Worker) {
+	a.workers = append(a.workers, w)
+}
+
+// OnClose registers a resource released on shutdown. Resources are released in the reverse
+// order of registration, after the server and the workers have stopped.
+func (a *App) OnClose(name string, close func(ctx context.Context) error) {
+	a.closers = append(a.closers, closer{name: name, close: close})
+}
+
+// Run starts the application and shuts it down once ctx is done or the server fails
+func (a *App) Run(ctx context.Context) error {
+	if err := a.Start(ctx); err != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout)
+		defer cancel()
+		return errors.Join(err, a.close(shutdownCtx))
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logger.Log.Info("shutting down", "timeout", a.ShutdownTimeout.String())
+	case err := <-a.serveErr:
+		runErr = fmt.Errorf("serve: %w", err)
+	}
+	return errors.Join(runErr, a.Shutdown())
+}
+
+// Start starts every worker, then listens on the server address. It fails, naming each of
+// them, when workers cannot start, and then starts none.
+func (a *App) Start(ctx context.Context) error {
+	var errs []error
+	for _, w := range a.workers {
+		if w.Start == nil {
+			continue
+		}
+		if err := w.Start(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("worker %s failed to start: %w", w.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	ln, err := net.Listen("tcp", a.Server.Addr)
+	if err != nil {
+		return err
+	}
+	a.listener = ln
+
+	workerCtx, cancel := context.WithCancel(context.Background())
+	a.cancelWorkers = cancel
+	a.running = make(map[string]chan struct{}, len(a.workers))
+	for _, w := range a.workers {
+		done := make(chan struct{})
+		a.running[w.Name] = done
+		go func() {
+			defer close(done)
+			w.Run(workerCtx)
+		}()
+		logger.Log.Info("worker started", "worker", w.Name)
+	}
+
+	a.serveErr = make(chan error, 1)
+	go func() {
+		if err := a.Server.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			a.serveErr <- err
+		}
+	}()
+	logger.Log.Info("server listening", "addr", ln.Addr().String())
+	return nil
+}
+
+// Addr is the address the server listens on, once started
+func (a *App) Addr() net.Addr {
+	return a.listener.Addr()
+}
+
+// Shutdown stops the server, letting the requests in flight finish, then the workers, then
+// releases the resources. Requests and workers still running when the shutdown timeout
+// expires are abandoned and reported in the error.
+func (a *App) Shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := a.Server.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("requests still in flight: %w", err))
+		_ = a.Server.Close()
+	}
+
+	a.cancelWorkers()
+	for name, done := range a.running {
+		select {
+		case <-done:
+			logger.Log.Info("worker stopped", "worker", name)
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("worker %s did not stop in time", name))
+		}
+	}
+
+	errs = append(errs, a.close(ctx))
+	return errors.Join(errs...)
+}
+
+// close releases the resources, the last registered first
+func (a *App) close(ctx context.Context) error {
+	var errs []error
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		c := a.closers[i]
+		if err := c.close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
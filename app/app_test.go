@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newApp(handler http.Handler, timeout time.Duration) (*App, *[]string) {
+	var closed []string
+	a := New(&http.Server{Addr: "127.0.0.1:0", Handler: handler}, timeout)
+	for _, name := range []string{"tracing", "database"} {
+		a.OnClose(name, func(context.Context) error {
+			closed = append(closed, name)
+			return nil
+		})
+	}
+	return a, &closed
+}
+
+// Requests in flight finish before the workers stop and the resources are released
+func TestShutdownDrainsRequests(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	a, closed := newApp(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}), 5*time.Second)
+
+	workerStopped := make(chan struct{})
+	a.Add(Worker{Name: "cleanup", Run: func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	}})
+	require.NoError(t, a.Start(context.Background()))
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + a.Addr().String())
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-entered
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- a.Shutdown() }()
+	select {
+	case <-workerStopped:
+		t.Fatal("worker stopped while a request was in flight")
+	case <-shutdown:
+		t.Fatal("shutdown returned while a request was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusNoContent, <-status)
+	require.NoError(t, <-shutdown)
+	<-workerStopped
+	assert.Equal(t, []string{"database", "tracing"}, *closed, "released in reverse order")
+}
+
+// Every worker that cannot start is reported and the server does not start
+func TestStartReportsWorkers(t *testing.T) {
+	a, closed := newApp(http.NotFoundHandler(), time.Second)
+	ran := false
+	a.Add(Worker{Name: "ok", Start: func(context.Context) error { return nil }, Run: func(context.Context) { ran = true }})
+	a.Add(Worker{Name: "tokens", Start: func(context.Context) error { return errors.New("table missing") }, Run: func(context.Context) {}})
+	a.Add(Worker{Name: "scheduler", Start: func(context.Context) error { return errors.New("no lock") }, Run: func(context.Context) {}})
+
+	err := a.Run(context.Background())
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "worker tokens failed to start: table missing")
+	assert.ErrorContains(t, err, "worker scheduler failed to start: no lock")
+	assert.Nil(t, a.listener)
+	assert.False(t, ran)
+	assert.Equal(t, []string{"database", "tracing"}, *closed)
+}
+
+// A worker ignoring the stop is abandoned at the deadline
+func TestShutdownTimeout(t *testing.T) {
+	a, closed := newApp(http.NotFoundHandler(), 50*time.Millisecond)
+	stuck := make(chan struct{})
+	defer close(stuck)
+	a.Add(Worker{Name: "stuck", Run: func(context.Context) { <-stuck }})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := a.Run(ctx)
+	assert.ErrorContains(t, err, "worker stuck did not stop in time")
+	assert.Equal(t, []string{"database", "tracing"}, *closed)
+}
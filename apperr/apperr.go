@@ -0,0 +1,153 @@
+// Package apperr is the error model of the API. Every error response carries a stable code
+// the frontend switches on, the HTTP status belonging to it and a message in the language
+// asked for by Accept-Language (Vietnamese or English, Vietnamese by default):
+//
+//	{"code": "STOCK_INSUFFICIENT", "error": "Số lượng tồn kho không đủ", "details": {"ingredient_id": "NL001", ...}}
+//
+// Invalid requests list the offending fields:
+//
+//	{"code": "VALIDATION_FAILED", "error": "...", "fields": [{"field": "kitchenId", "rule": "required", "message": "..."}]}
+//
+// The cause of an error is logged, never sent, so database errors do not leak to clients.
+package apperr
+
+import (
+	"adong-be/logger"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Error is an API error
+type Error struct {
+	Code Code
+	// Details carries data about the failure, e.g. the ingredient and quantities of
+	// STOCK_INSUFFICIENT
+	Details map[string]any
+	// Fields lists the invalid request fields of VALIDATION_FAILED
+	Fields []FieldError
+	// Err is the cause, logged but never sent
+	Err error
+}
+
+// FieldError is one invalid request field. Rule names the failed check, e.g. required, min
+// or oneof, with its parameter in Param.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// New returns an error with code
+func New(code Code) *Error {
+	return &Error{Code: code}
+}
+
+// Wrap returns an error with code caused by err
+func Wrap(code Code, err error) *Error {
+	return &Error{Code: code, Err: err}
+}
+
+// Internal returns the error of an unexpected failure caused by err
+func Internal(err error) *Error {
+	return Wrap(CodeInternal, err)
+}
+
+// Invalid returns a VALIDATION_FAILED error for one field. param is the parameter of rule,
+// e.g. the allowed values of oneof.
+func Invalid(field, rule string, param ...string) *Error {
+	return New(CodeValidation).Field(field, rule, param...)
+}
+
+// Field returns a copy of e listing one more invalid field
+func (e *Error) Field(field, rule string, param ...string) *Error {
+	c := e.clone()
+	f := FieldError{Field: field, Rule: rule}
+	if len(param) > 0 {
+		f.Param = param[0]
+	}
+	c.Fields = append(c.Fields, f)
+	return c
+}
+
+// With returns a copy of e with a detail added
+func (e *Error) With(key string, value any) *Error {
+	c := e.clone()
+	if c.Details == nil {
+		c.Details = make(map[string]any)
+	}
+	c.Details[key] = value
+	return c
+}
+
+func (e *Error) clone() *Error {
+	c := *e
+	c.Details = maps.Clone(e.Details)
+	c.Fields = append([]FieldError(nil), e.Fields...)
+	return &c
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Code, e.Err)
+	}
+	return string(e.Code)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is matches errors with the same code, so errors.Is(err, apperr.New(code)) tests the code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Status is the HTTP status of the error
+func (e *Error) Status() int {
+	return e.Code.Status()
+}
+
+// From returns err as an *Error; errors that are not API errors become INTERNAL_ERROR
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Internal(err)
+}
+
+// Response is the body of an error response
+type Response struct {
+	Code    Code           `json:"code"`
+	Message string         `json:"error"`
+	Details map[string]any `json:"details,omitempty"`
+	Fields  []FieldError   `json:"fields,omitempty"`
+}
+
+// Localize returns the response body of e in lang
+func (e *Error) Localize(lang Lang) Response {
+	r := Response{Code: e.Code, Message: e.Code.Message(lang), Details: e.Details}
+	for _, f := range e.Fields {
+		f.Message = fieldMessage(lang, f)
+		r.Fields = append(r.Fields, f)
+	}
+	return r
+}
+
+// Respond writes err as the response to c in the caller's language and aborts the handler
+// chain. Server errors are logged with their cause.
+func Respond(c *gin.Context, err error) {
+	e := From(err)
+	status := e.Status()
+	if status >= http.StatusInternalServerError {
+		uid, _ := c.Get("identity")
+		logger.From(c).Error("request failed", "code", e.Code, "method", c.Request.Method, "path", c.FullPath(), "user_id", uid, "error", e.Err)
+	}
+	c.AbortWithStatusJSON(status, e.Localize(Language(c)))
+}
@@ -0,0 +1,127 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Lang
+	}{
+		{"", Vietnamese},
+		{"vi-VN,vi;q=0.9", Vietnamese},
+		{"en-US,en;q=0.9", English},
+		{"fr-FR, en;q=0.5", English},
+		{"en;q=0.5, vi", Vietnamese},
+		{"de-DE", Vietnamese},
+		{"not a header;;", Vietnamese},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ParseLanguage(tt.header), tt.header)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	for code := range Codes() {
+		assert.NotZero(t, code.Status(), code)
+		assert.NotEmpty(t, code.Message(Vietnamese), code)
+		assert.NotEmpty(t, code.Message(English), code)
+	}
+	assert.Equal(t, http.StatusInternalServerError, Code("UNKNOWN").Status())
+}
+
+// respond serves err to a request sending acceptLanguage and decodes the response
+func respond(t *testing.T, err error, acceptLanguage string) (int, Response) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) { Respond(c, err) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Language", acceptLanguage)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return w.Code, body
+}
+
+func TestRespond(t *testing.T) {
+	stock := New(CodeStockInsufficient).With("ingredient_id", "NL001")
+
+	status, body := respond(t, stock, "en")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, CodeStockInsufficient, body.Code)
+	assert.Equal(t, CodeStockInsufficient.Message(English), body.Message)
+	assert.Equal(t, "NL001", body.Details["ingredient_id"])
+
+	_, body = respond(t, fmt.Errorf("approve export: %w", stock), "vi")
+	assert.Equal(t, CodeStockInsufficient, body.Code, "wrapped errors keep their code")
+	assert.Equal(t, CodeStockInsufficient.Message(Vietnamese), body.Message)
+
+	status, body = respond(t, errors.New(`pq: relation "orders" does not exist`), "en")
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, CodeInternal, body.Code)
+	assert.NotContains(t, body.Message, "orders", "causes are not sent")
+}
+
+func TestErrorCopies(t *testing.T) {
+	base := New(CodeValidation)
+	withField := base.Field("kitchenId", "required")
+	assert.Empty(t, base.Fields)
+	assert.Len(t, withField.Fields, 1)
+	assert.True(t, errors.Is(fmt.Errorf("x: %w", withField), New(CodeValidation)))
+	assert.False(t, errors.Is(withField, New(CodeInternal)))
+}
+
+func TestBind(t *testing.T) {
+	type detail struct {
+		Quantity float64 `json:"quantity" binding:"gt=0"`
+	}
+	type request struct {
+		KitchenID string   `json:"kitchenId" binding:"required"`
+		Type      string   `json:"type" binding:"oneof=in out"`
+		Details   []detail `json:"details" binding:"dive"`
+	}
+	gin.SetMode(gin.TestMode)
+
+	bind := func(body string) *Error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		var req request
+		err := c.ShouldBindJSON(&req)
+		require.Error(t, err)
+		return Bind(err)
+	}
+
+	e := bind(`{"type": "sideways", "details": [{"quantity": 1}, {"quantity": 0}]}`)
+	assert.Equal(t, CodeValidation, e.Code)
+	assert.Equal(t, []FieldError{
+		{Field: "kitchenId", Rule: "required"},
+		{Field: "type", Rule: "oneof", Param: "in out"},
+		{Field: "details[1].quantity", Rule: "gt", Param: "0"},
+	}, e.Fields)
+
+	body := e.Localize(English)
+	assert.Equal(t, "kitchenId is required", body.Fields[0].Message)
+	assert.Equal(t, "details[1].quantity must be greater than 0", body.Fields[2].Message)
+
+	e = bind(`{"kitchenId": 1}`)
+	assert.Equal(t, CodeValidation, e.Code)
+	assert.Equal(t, "kitchenId", e.Fields[0].Field)
+	assert.Equal(t, "type", e.Fields[0].Rule)
+
+	assert.Equal(t, CodeInvalidRequest, bind(`{`).Code)
+}
@@ -0,0 +1,102 @@
+package apperr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Field errors name the request fields as the client sends them: by their json tag, or form
+// tag for query parameters. The validator caches field names per type when it first sees
+// it, so this has to run before any request is bound.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return f.Name
+	})
+}
+
+// Bind returns the error of a request that could not be bound (the error of gin's
+// ShouldBind methods): VALIDATION_FAILED listing the fields failing their binding rules, or
+// INVALID_REQUEST for a body or query that cannot be parsed.
+func Bind(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		e := Wrap(CodeValidation, err)
+		for _, fe := range validationErrs {
+			e = e.Field(fieldPath(fe), fe.Tag(), fe.Param())
+		}
+		return e
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		e := Wrap(CodeValidation, err)
+		return e.Field(typeErr.Field, "type", typeErr.Type.String())
+	}
+	return Wrap(CodeInvalidRequest, err)
+}
+
+// fieldPath is the path of the field in the request, without the name of the bound struct,
+// e.g. importDetails[0].quantity
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+// fieldMessage is the message of an invalid field in lang
+func fieldMessage(lang Lang, f FieldError) string {
+	templates, ok := ruleMessages[f.Rule]
+	if !ok {
+		templates = ruleMessages[""]
+	}
+	if strings.Count(templates[lang], "%s") == 2 {
+		return fmt.Sprintf(templates[lang], f.Field, f.Param)
+	}
+	return fmt.Sprintf(templates[lang], f.Field)
+}
+
+// ruleMessages are the field messages per validation rule, in Lang order. The empty rule is
+// the fallback for rules without a message of their own.
+var ruleMessages = map[string][2]string{
+	"":         {"%s không hợp lệ", "%s is invalid"},
+	"required": {"%s là bắt buộc", "%s is required"},
+	"min":      {"%s phải tối thiểu %s", "%s must be at least %s"},
+	"max":      {"%s không được vượt quá %s", "%s must be at most %s"},
+	"gt":       {"%s phải lớn hơn %s", "%s must be greater than %s"},
+	"gte":      {"%s phải lớn hơn hoặc bằng %s", "%s must be at least %s"},
+	"lt":       {"%s phải nhỏ hơn %s", "%s must be less than %s"},
+	"lte":      {"%s phải nhỏ hơn hoặc bằng %s", "%s must be at most %s"},
+	"oneof":    {"%s phải là một trong: %s", "%s must be one of: %s"},
+	"email":    {"%s không phải là email hợp lệ", "%s must be a valid email address"},
+	"date":     {"%s phải có định dạng %s", "%s must have the format %s"},
+	"duration": {"%s phải là khoảng thời gian từ 0 đến %s", "%s must be a duration between 0 and %s"},
+	"type":     {"%s phải có kiểu %s", "%s must be of type %s"},
+	"unique":   {"%s bị trùng lặp", "%s must not repeat"},
+	"exists":   {"%s không tồn tại", "%s does not exist"},
+	"after":    {"%s phải sau %s", "%s must be after %s"},
+	"policy":   {"%s không đáp ứng chính sách mật khẩu", "%s does not meet the password policy"},
+	"differ":   {"%s phải khác %s", "%s must differ from %s"},
+	"same":     {"%s phải giống nhau", "%s must be the same for every item"},
+	"one":      {"%s cần có một trong: %s", "%s needs one of: %s"},
+}
@@ -0,0 +1,203 @@
+package apperr
+
+import "net/http"
+
+// Code identifies an error for clients. Codes never change once published.
+type Code string
+
+// General errors
+const (
+	CodeInvalidRequest   Code = "INVALID_REQUEST"
+	CodeValidation       Code = "VALIDATION_FAILED"
+	CodeUnauthorized     Code = "UNAUTHORIZED"
+	CodeForbidden        Code = "FORBIDDEN"
+	CodeKitchenForbidden Code = "KITCHEN_FORBIDDEN"
+	CodeNotFound         Code = "NOT_FOUND"
+	CodeInternal         Code = "INTERNAL_ERROR"
+)
+
+// Concurrent changes: a change sends the ETag of the record it read in If-Match, see package
+// etag
+const (
+	CodePreconditionRequired Code = "PRECONDITION_REQUIRED"
+	CodeVersionMismatch      Code = "VERSION_MISMATCH"
+)
+
+// Authentication, accounts and sessions
+const (
+	CodeInvalidCredentials          Code = "INVALID_CREDENTIALS"
+	CodeTokenInvalid                Code = "TOKEN_INVALID"
+	CodeTokenExpired                Code = "TOKEN_EXPIRED"
+	CodeUserInactive                Code = "USER_INACTIVE"
+	CodeLoginThrottled              Code = "LOGIN_THROTTLED"
+	CodeTwoFactorCodeRequired       Code = "TWO_FACTOR_CODE_REQUIRED"
+	CodeTwoFactorCodeInvalid        Code = "TWO_FACTOR_CODE_INVALID"
+	CodeTwoFactorEnrollmentRequired Code = "TWO_FACTOR_ENROLLMENT_REQUIRED"
+	CodeTwoFactorNotEnrolling       Code = "TWO_FACTOR_NOT_ENROLLING"
+	CodeTwoFactorNotEnabled         Code = "TWO_FACTOR_NOT_ENABLED"
+	CodeTwoFactorAlreadyEnabled     Code = "TWO_FACTOR_ALREADY_ENABLED"
+	CodePasswordPolicy              Code = "PASSWORD_POLICY"
+	CodePasswordIncorrect           Code = "PASSWORD_INCORRECT"
+	CodeResetTokenInvalid           Code = "RESET_TOKEN_INVALID"
+	CodeInvalidAPIKey               Code = "API_KEY_INVALID"
+	CodeAPIKeyNotScoped             Code = "API_KEY_NOT_SCOPED"
+	CodeAPIKeyNotFound              Code = "API_KEY_NOT_FOUND"
+	CodeAPIKeyRevoked               Code = "API_KEY_REVOKED"
+	CodeServiceAccountNotFound      Code = "SERVICE_ACCOUNT_NOT_FOUND"
+	CodeServiceAccountInactive      Code = "SERVICE_ACCOUNT_INACTIVE"
+	CodeSessionNotFound             Code = "SESSION_NOT_FOUND"
+	CodeUserNotFound                Code = "USER_NOT_FOUND"
+	CodeUsernameTaken               Code = "USERNAME_TAKEN"
+	CodeEmailTaken                  Code = "EMAIL_TAKEN"
+	CodeRoleNotFound                Code = "ROLE_NOT_FOUND"
+	CodeRoleExists                  Code = "ROLE_EXISTS"
+	CodeRoleInUse                   Code = "ROLE_IN_USE"
+	CodeLastSuperuser               Code = "LAST_SUPERUSER"
+)
+
+// Master data: kitchens, dishes, ingredients and suppliers
+const (
+	CodeKitchenNotFound          Code = "KITCHEN_NOT_FOUND"
+	CodeDishNotFound             Code = "DISH_NOT_FOUND"
+	CodeIngredientNotFound       Code = "INGREDIENT_NOT_FOUND"
+	CodeRecipeStandardNotFound   Code = "RECIPE_STANDARD_NOT_FOUND"
+	CodeSupplierNotFound         Code = "SUPPLIER_NOT_FOUND"
+	CodeSupplierPriceNotFound    Code = "SUPPLIER_PRICE_NOT_FOUND"
+	CodeProductNotFound          Code = "PRODUCT_NOT_FOUND"
+	CodeSelectionRuleNotFound    Code = "SELECTION_RULE_NOT_FOUND"
+	CodeFavoriteSupplierNotFound Code = "FAVORITE_SUPPLIER_NOT_FOUND"
+	CodeFavoriteSupplierExists   Code = "FAVORITE_SUPPLIER_EXISTS"
+)
+
+// Orders
+const (
+	CodeOrderNotFound         Code = "ORDER_NOT_FOUND"
+	CodeOrderHasNoIngredients Code = "ORDER_HAS_NO_INGREDIENTS"
+	CodeIngredientNotInOrder  Code = "INGREDIENT_NOT_IN_ORDER"
+)
+
+// Inventory
+const (
+	CodeImportNotFound            Code = "IMPORT_NOT_FOUND"
+	CodeExportNotFound            Code = "EXPORT_NOT_FOUND"
+	CodeAdjustmentNotFound        Code = "ADJUSTMENT_NOT_FOUND"
+	CodeIngredientRequestNotFound Code = "INGREDIENT_REQUEST_NOT_FOUND"
+	CodeStockNotFound             Code = "STOCK_NOT_FOUND"
+	CodeDocumentAlreadyApproved   Code = "DOCUMENT_ALREADY_APPROVED"
+	CodeDocumentNotEditable       Code = "DOCUMENT_NOT_EDITABLE"
+	CodeRequestNotApproved        Code = "REQUEST_NOT_APPROVED"
+	CodeStockMissing              Code = "STOCK_MISSING"
+	CodeStockInsufficient         Code = "STOCK_INSUFFICIENT"
+	CodeStockCountNegative        Code = "STOCK_COUNT_NEGATIVE"
+)
+
+// Idempotent requests, see the Idempotency-Key header
+const (
+	CodeIdempotencyKeyInvalid Code = "IDEMPOTENCY_KEY_INVALID"
+	CodeIdempotencyKeyReused  Code = "IDEMPOTENCY_KEY_REUSED"
+	CodeIdempotencyInProgress Code = "IDEMPOTENCY_IN_PROGRESS"
+)
+
+type entry struct {
+	status int
+	// messages in Lang order
+	messages [2]string
+}
+
+var catalog = map[Code]entry{
+	CodeInvalidRequest:   {http.StatusBadRequest, [2]string{"Yêu cầu không hợp lệ", "The request could not be read"}},
+	CodeValidation:       {http.StatusBadRequest, [2]string{"Dữ liệu không hợp lệ", "Some fields are invalid"}},
+	CodeUnauthorized:     {http.StatusUnauthorized, [2]string{"Vui lòng đăng nhập", "Authentication is required"}},
+	CodeForbidden:        {http.StatusForbidden, [2]string{"Bạn không có quyền thực hiện thao tác này", "You are not allowed to do this"}},
+	CodeKitchenForbidden: {http.StatusForbidden, [2]string{"Bạn không có quyền truy cập bếp này", "Access to this kitchen is not allowed"}},
+	CodeNotFound:         {http.StatusNotFound, [2]string{"Không tìm thấy dữ liệu", "Not found"}},
+	CodeInternal:         {http.StatusInternalServerError, [2]string{"Đã xảy ra lỗi, vui lòng thử lại sau", "Something went wrong, please try again later"}},
+
+	CodePreconditionRequired: {http.StatusPreconditionRequired, [2]string{"Thiếu phiên bản dữ liệu, vui lòng tải lại trước khi sửa", "The If-Match header with the record's ETag is required"}},
+	CodeVersionMismatch:      {http.StatusPreconditionFailed, [2]string{"Dữ liệu đã được người khác thay đổi, vui lòng tải lại", "The record was changed by someone else, reload it and try again"}},
+
+	CodeInvalidCredentials:          {http.StatusUnauthorized, [2]string{"Sai tên đăng nhập hoặc mật khẩu", "Invalid username or password"}},
+	CodeTokenInvalid:                {http.StatusUnauthorized, [2]string{"Phiên đăng nhập không hợp lệ", "The token is missing or invalid"}},
+	CodeTokenExpired:                {http.StatusUnauthorized, [2]string{"Phiên đăng nhập đã hết hạn", "The token is expired"}},
+	CodeUserInactive:                {http.StatusUnauthorized, [2]string{"Tài khoản đã bị vô hiệu hóa", "The user account is inactive"}},
+	CodeLoginThrottled:              {http.StatusTooManyRequests, [2]string{"Đăng nhập sai quá nhiều lần, vui lòng thử lại sau", "Too many failed attempts, try again later"}},
+	CodeTwoFactorCodeRequired:       {http.StatusUnauthorized, [2]string{"Cần nhập mã xác thực hai lớp", "A two-factor code is required"}},
+	CodeTwoFactorCodeInvalid:        {http.StatusUnauthorized, [2]string{"Mã xác thực hai lớp không đúng", "Invalid two-factor code"}},
+	CodeTwoFactorEnrollmentRequired: {http.StatusForbidden, [2]string{"Vai trò của bạn bắt buộc xác thực hai lớp", "Two-factor authentication is required for your role"}},
+	CodeTwoFactorNotEnrolling:       {http.StatusConflict, [2]string{"Chưa bắt đầu đăng ký xác thực hai lớp", "No two-factor enrollment in progress"}},
+	CodeTwoFactorNotEnabled:         {http.StatusConflict, [2]string{"Xác thực hai lớp chưa được bật", "Two-factor authentication is not enabled"}},
+	CodeTwoFactorAlreadyEnabled:     {http.StatusConflict, [2]string{"Xác thực hai lớp đã được bật", "Two-factor authentication is already enabled"}},
+	CodePasswordPolicy:              {http.StatusBadRequest, [2]string{"Mật khẩu không đáp ứng chính sách mật khẩu", "The password does not meet the password policy"}},
+	CodePasswordIncorrect:           {http.StatusBadRequest, [2]string{"Mật khẩu hiện tại không đúng", "The current password is incorrect"}},
+	CodeResetTokenInvalid:           {http.StatusBadRequest, [2]string{"Liên kết đặt lại mật khẩu không hợp lệ hoặc đã hết hạn", "The reset token is invalid or expired"}},
+	CodeInvalidAPIKey:               {http.StatusUnauthorized, [2]string{"API key không hợp lệ", "Invalid API key"}},
+	CodeAPIKeyNotScoped:             {http.StatusForbidden, [2]string{"API key không được cấp quyền này", "The API key is not scoped to this permission"}},
+	CodeAPIKeyNotFound:              {http.StatusNotFound, [2]string{"Không tìm thấy API key", "API key not found"}},
+	CodeAPIKeyRevoked:               {http.StatusConflict, [2]string{"API key đã bị thu hồi hoặc hết hạn", "The API key is revoked or expired"}},
+	CodeServiceAccountNotFound:      {http.StatusNotFound, [2]string{"Không tìm thấy tài khoản dịch vụ", "Service account not found"}},
+	CodeServiceAccountInactive:      {http.StatusConflict, [2]string{"Tài khoản dịch vụ đã bị vô hiệu hóa", "The service account is inactive"}},
+	CodeSessionNotFound:             {http.StatusNotFound, [2]string{"Không tìm thấy phiên đăng nhập", "Session not found"}},
+	CodeUserNotFound:                {http.StatusNotFound, [2]string{"Không tìm thấy người dùng", "User not found"}},
+	CodeUsernameTaken:               {http.StatusConflict, [2]string{"Tên đăng nhập đã tồn tại", "The username is already taken"}},
+	CodeEmailTaken:                  {http.StatusConflict, [2]string{"Email đã được đăng ký", "The email is already registered"}},
+	CodeRoleNotFound:                {http.StatusNotFound, [2]string{"Không tìm thấy vai trò", "Role not found"}},
+	CodeRoleExists:                  {http.StatusConflict, [2]string{"Vai trò đã tồn tại", "The role already exists"}},
+	CodeRoleInUse:                   {http.StatusConflict, [2]string{"Vai trò đang được gán cho người dùng", "The role is assigned to users"}},
+	CodeLastSuperuser:               {http.StatusConflict, [2]string{"Phải còn ít nhất một vai trò quản trị cao nhất", "At least one superuser role must remain"}},
+
+	CodeKitchenNotFound:          {http.StatusNotFound, [2]string{"Không tìm thấy bếp", "Kitchen not found"}},
+	CodeDishNotFound:             {http.StatusNotFound, [2]string{"Không tìm thấy món ăn", "Dish not found"}},
+	CodeIngredientNotFound:       {http.StatusNotFound, [2]string{"Không tìm thấy nguyên liệu", "Ingredient not found"}},
+	CodeRecipeStandardNotFound:   {http.StatusNotFound, [2]string{"Không tìm thấy định mức", "Recipe standard not found"}},
+	CodeSupplierNotFound:         {http.StatusNotFound, [2]string{"Không tìm thấy nhà cung cấp", "Supplier not found"}},
+	CodeSupplierPriceNotFound:    {http.StatusNotFound, [2]string{"Không tìm thấy giá của nhà cung cấp", "Supplier price not found"}},
+	CodeProductNotFound:          {http.StatusNotFound, [2]string{"Không tìm thấy sản phẩm của nhà cung cấp cho nguyên liệu này", "Product not found or does not match the supplier and ingredient"}},
+	CodeSelectionRuleNotFound:    {http.StatusNotFound, [2]string{"Không tìm thấy quy tắc chọn nhà cung cấp", "Selection rule not found"}},
+	CodeFavoriteSupplierNotFound: {http.StatusNotFound, [2]string{"Không tìm thấy nhà cung cấp yêu thích", "Favorite supplier not found"}},
+	CodeFavoriteSupplierExists:   {http.StatusConflict, [2]string{"Nhà cung cấp đã có trong danh sách yêu thích của bếp", "This supplier is already in the kitchen's favorites"}},
+
+	CodeOrderNotFound:         {http.StatusNotFound, [2]string{"Không tìm thấy đơn hàng", "Order not found"}},
+	CodeOrderHasNoIngredients: {http.StatusBadRequest, [2]string{"Đơn hàng không có nguyên liệu", "The order has no ingredients"}},
+	CodeIngredientNotInOrder:  {http.StatusBadRequest, [2]string{"Nguyên liệu không thuộc đơn hàng", "The ingredient does not belong to the order"}},
+
+	CodeImportNotFound:            {http.StatusNotFound, [2]string{"Không tìm thấy phiếu nhập", "Import not found"}},
+	CodeExportNotFound:            {http.StatusNotFound, [2]string{"Không tìm thấy phiếu xuất", "Export not found"}},
+	CodeAdjustmentNotFound:        {http.StatusNotFound, [2]string{"Không tìm thấy phiếu kiểm kê", "Adjustment not found"}},
+	CodeIngredientRequestNotFound: {http.StatusNotFound, [2]string{"Không tìm thấy phiếu yêu cầu", "Ingredient request not found"}},
+	CodeStockNotFound:             {http.StatusNotFound, [2]string{"Không tìm thấy tồn kho", "Stock not found"}},
+	CodeDocumentAlreadyApproved:   {http.StatusBadRequest, [2]string{"Phiếu đã được duyệt", "The document is already approved"}},
+	CodeDocumentNotEditable:       {http.StatusBadRequest, [2]string{"Không thể sửa hoặc xóa phiếu đã duyệt", "An approved document cannot be changed or deleted"}},
+	CodeRequestNotApproved:        {http.StatusBadRequest, [2]string{"Phiếu yêu cầu chưa được duyệt", "The ingredient request is not approved"}},
+	CodeStockMissing:              {http.StatusBadRequest, [2]string{"Nguyên liệu không tồn tại trong kho", "The ingredient is not in stock"}},
+	CodeStockInsufficient:         {http.StatusBadRequest, [2]string{"Số lượng tồn kho không đủ", "Insufficient stock"}},
+	CodeStockCountNegative:        {http.StatusBadRequest, [2]string{"Số lượng kiểm kê không được âm", "A counted quantity cannot be negative"}},
+
+	CodeIdempotencyKeyInvalid: {http.StatusBadRequest, [2]string{"Idempotency-Key không hợp lệ", "The Idempotency-Key header is invalid"}},
+	CodeIdempotencyKeyReused:  {http.StatusUnprocessableEntity, [2]string{"Idempotency-Key đã được dùng cho một yêu cầu khác", "The Idempotency-Key was already used for a different request"}},
+	CodeIdempotencyInProgress: {http.StatusConflict, [2]string{"Yêu cầu với Idempotency-Key này đang được xử lý", "A request with this Idempotency-Key is still being processed"}},
+}
+
+// Status is the HTTP status of errors with the code, 500 for unknown codes
+func (c Code) Status() int {
+	if e, ok := catalog[c]; ok {
+		return e.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Message is the message of the code in lang
+func (c Code) Message(lang Lang) string {
+	if e, ok := catalog[c]; ok {
+		return e.messages[lang]
+	}
+	return catalog[CodeInternal].messages[lang]
+}
+
+// Codes lists every code with its status, for documentation
+func Codes() map[Code]int {
+	codes := make(map[Code]int, len(catalog))
+	for c, e := range catalog {
+		codes[c] = e.status
+	}
+	return codes
+}
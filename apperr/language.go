@@ -0,0 +1,40 @@
+package apperr
+
+import (
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+// Lang is a language messages are available in
+type Lang int
+
+const (
+	Vietnamese Lang = iota
+	English
+)
+
+// supported lists the languages in Lang order, the first being the default
+var supported = language.NewMatcher([]language.Tag{language.Vietnamese, language.English})
+
+// ParseLanguage returns the best language for an Accept-Language header, Vietnamese when
+// nothing in it is supported
+func ParseLanguage(acceptLanguage string) Lang {
+	_, index, confidence := supported.Match(parseTags(acceptLanguage)...)
+	if confidence == language.No {
+		return Vietnamese
+	}
+	return Lang(index)
+}
+
+func parseTags(acceptLanguage string) []language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return nil
+	}
+	return tags
+}
+
+// Language returns the language c asked for with Accept-Language
+func Language(c *gin.Context) Lang {
+	return ParseLanguage(c.GetHeader("Accept-Language"))
+}
@@ -0,0 +1,364 @@
+// Package audit records every create, update, delete and approval made through GORM in the
+// audit_logs table. Callbacks registered by Register capture the row before and after the
+// change; the actor, request ID, IP and user agent come from the statement context, so
+// handlers only need to run their queries with db.WithContext(c).
+package audit
+
+import (
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/requestid"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// MetaKey is the gin context key holding the request Meta
+const MetaKey = "audit_meta"
+
+// RequestIDHeader carries the request ID, see requestid
+const RequestIDHeader = requestid.Header
+
+// identityKey is the gin context key under which the auth middleware stores the user ID
+const identityKey = "identity"
+
+// Meta describes the request a change was made in
+type Meta struct {
+	RequestID string
+	IP        string
+	UserAgent string
+}
+
+// Middleware stores the request Meta for the audit callbacks, assigning the request ID
+// unless requestid.Middleware ran before
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(MetaKey, Meta{RequestID: requestid.Ensure(c), IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
+		c.Next()
+	}
+}
+
+// Tables that are not audited: the logs themselves, the auth token storage, the login
+// failure counters and the stored responses of idempotent requests
+var skipTables = map[string]bool{
+	"audit_logs":         true,
+	"failed_logins":      true,
+	"login_throttles":    true,
+	"auth_token_pairs":   true,
+	"auth_user_sessions": true,
+	"idempotency_keys":   true,
+}
+
+// Columns whose values never reach the log
+var redactedColumns = map[string]bool{
+	"password":       true,
+	"plain_password": true,
+	"totp_secret":    true,
+	"secret_hash":    true,
+}
+
+const redacted = "[REDACTED]"
+
+// maxRows bounds the rows captured for one bulk update or delete
+const maxRows = 1000
+
+const beforeKey = "audit:before"
+
+// Register installs the audit callbacks on db
+func Register(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("audit:after_create", afterCreate); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("audit:before_update", loadBefore); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("audit:after_update", afterUpdate); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("audit:before_delete", loadBefore); err != nil {
+		return err
+	}
+	return cb.Delete().After("gorm:delete").Register("audit:after_delete", afterDelete)
+}
+
+func audited(db *gorm.DB) bool {
+	stmt := db.Statement
+	return db.Error == nil && stmt.Schema != nil &&
+		len(stmt.Schema.PrimaryFields) > 0 && !skipTables[stmt.Table]
+}
+
+// loadBefore captures the rows an update or delete is about to change
+func loadBefore(db *gorm.DB) {
+	if !audited(db) {
+		return
+	}
+	stmt := db.Statement
+	var exprs []clause.Expression
+	if c, ok := stmt.Clauses["WHERE"]; ok {
+		if where, ok := c.Expression.(clause.Where); ok {
+			exprs = append(exprs, where.Exprs...)
+		}
+	}
+	// gorm adds the model's primary key to the WHERE clause later in the chain
+	if stmt.Model != nil {
+		model := reflect.Indirect(reflect.ValueOf(stmt.Model))
+		if model.Kind() == reflect.Struct || model.Kind() == reflect.Slice {
+			_, values := schema.GetIdentityFieldValuesMap(stmt.Context, model, stmt.Schema.PrimaryFields)
+			if column, in := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, values); len(in) > 0 {
+				exprs = append(exprs, clause.IN{Column: column, Values: in})
+			}
+		}
+	}
+	if len(exprs) == 0 {
+		return
+	}
+
+	rows, err := loadRows(db, exprs)
+	if err != nil {
+		logger.From(stmt.Context).Error("audit load rows error", "table", stmt.Table, "error", err)
+		return
+	}
+	db.InstanceSet(beforeKey, rows)
+}
+
+func loadRows(db *gorm.DB, exprs []clause.Expression) ([]map[string]interface{}, error) {
+	stmt := db.Statement
+	var rows []map[string]interface{}
+	err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).
+		Model(reflect.New(stmt.Schema.ModelType).Interface()).
+		Clauses(clause.Where{Exprs: exprs}).
+		Limit(maxRows).
+		Find(&rows).Error
+	return rows, err
+}
+
+func beforeRows(db *gorm.DB) []map[string]interface{} {
+	v, ok := db.InstanceGet(beforeKey)
+	if !ok {
+		return nil
+	}
+	rows, _ := v.([]map[string]interface{})
+	return rows
+}
+
+func afterCreate(db *gorm.DB) {
+	if !audited(db) || db.RowsAffected == 0 {
+		return
+	}
+	stmt := db.Statement
+	var rows []map[string]interface{}
+	switch rv := stmt.ReflectValue; rv.Kind() {
+	case reflect.Struct:
+		rows = append(rows, structRow(stmt, rv))
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if elem := reflect.Indirect(rv.Index(i)); elem.Kind() == reflect.Struct {
+				rows = append(rows, structRow(stmt, elem))
+			}
+		}
+	case reflect.Map:
+		if m, ok := stmt.Dest.(map[string]interface{}); ok {
+			rows = append(rows, m)
+		}
+	}
+
+	entries := make([]models.AuditLog, 0, len(rows))
+	for _, row := range rows {
+		if entry, ok := newEntry(stmt.Context, stmt.Table, stmt.Schema.PrimaryFieldDBNames, nil, row); ok {
+			entries = append(entries, entry)
+		}
+	}
+	write(db, entries)
+}
+
+func afterUpdate(db *gorm.DB) {
+	if !audited(db) || db.RowsAffected == 0 {
+		return
+	}
+	stmt := db.Statement
+	before := beforeRows(db)
+	if len(before) == 0 {
+		return
+	}
+
+	pks := make([][]interface{}, 0, len(before))
+	for _, row := range before {
+		pk := make([]interface{}, len(stmt.Schema.PrimaryFieldDBNames))
+		for i, name := range stmt.Schema.PrimaryFieldDBNames {
+			pk[i] = row[name]
+		}
+		pks = append(pks, pk)
+	}
+	column, in := schema.ToQueryValues(stmt.Table, stmt.Schema.PrimaryFieldDBNames, pks)
+	after, err := loadRows(db, []clause.Expression{clause.IN{Column: column, Values: in}})
+	if err != nil {
+		logger.From(stmt.Context).Error("audit reload rows error", "table", stmt.Table, "error", err)
+		return
+	}
+	afterByID := make(map[string]map[string]interface{}, len(after))
+	for _, row := range after {
+		afterByID[entityID(stmt.Schema.PrimaryFieldDBNames, row)] = row
+	}
+
+	entries := make([]models.AuditLog, 0, len(before))
+	for _, row := range before {
+		newRow, ok := afterByID[entityID(stmt.Schema.PrimaryFieldDBNames, row)]
+		if !ok {
+			continue
+		}
+		if entry, ok := newEntry(stmt.Context, stmt.Table, stmt.Schema.PrimaryFieldDBNames, row, newRow); ok {
+			entries = append(entries, entry)
+		}
+	}
+	write(db, entries)
+}
+
+func afterDelete(db *gorm.DB) {
+	if !audited(db) || db.RowsAffected == 0 {
+		return
+	}
+	stmt := db.Statement
+	before := beforeRows(db)
+	entries := make([]models.AuditLog, 0, len(before))
+	for _, row := range before {
+		if entry, ok := newEntry(stmt.Context, stmt.Table, stmt.Schema.PrimaryFieldDBNames, row, nil); ok {
+			entries = append(entries, entry)
+		}
+	}
+	write(db, entries)
+}
+
+// write inserts the entries on the statement's connection, so they commit or roll back
+// together with the change. A failure is logged and does not fail the change itself.
+func write(db *gorm.DB, entries []models.AuditLog) {
+	if len(entries) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Create(&entries).Error; err != nil {
+		logger.From(db.Statement.Context).Error("audit write error", "table", db.Statement.Table, "error", err)
+	}
+}
+
+func structRow(stmt *gorm.Statement, rv reflect.Value) map[string]interface{} {
+	row := make(map[string]interface{}, len(stmt.Schema.DBNames))
+	for _, name := range stmt.Schema.DBNames {
+		if field := stmt.Schema.LookUpField(name); field != nil {
+			value, _ := field.ValueOf(stmt.Context, rv)
+			row[name] = value
+		}
+	}
+	return row
+}
+
+// newEntry builds the log entry for one row. before is nil for a create and after is nil
+// for a delete; an update that changed nothing is not logged.
+func newEntry(ctx context.Context, table string, primaryKeys []string, before, after map[string]interface{}) (models.AuditLog, bool) {
+	entry := models.AuditLog{EntityType: table}
+
+	row := after
+	switch {
+	case before == nil:
+		entry.Action = models.AuditActionCreate
+	case after == nil:
+		entry.Action = models.AuditActionDelete
+		row = before
+	default:
+		entry.Action = models.AuditActionUpdate
+		changes := diff(before, after)
+		if len(changes) == 0 {
+			return entry, false
+		}
+		if status, ok := changes["status"]; ok && fmt.Sprint(status["to"]) == "approved" {
+			entry.Action = models.AuditActionApprove
+		}
+		entry.Changes = marshal(changes)
+	}
+	entry.EntityID = entityID(primaryKeys, row)
+	if kitchenID, ok := stringValue(row["kitchen_id"]); ok {
+		entry.KitchenID = &kitchenID
+	}
+	if before != nil {
+		entry.Before = marshal(redact(before))
+	}
+	if after != nil {
+		entry.After = marshal(redact(after))
+	}
+
+	if ctx != nil {
+		if actor, ok := ctx.Value(identityKey).(string); ok && actor != "" {
+			entry.ActorUserID = &actor
+		}
+		if meta, ok := ctx.Value(MetaKey).(Meta); ok {
+			entry.RequestID, entry.IPAddress, entry.UserAgent = meta.RequestID, meta.IP, meta.UserAgent
+		}
+	}
+	return entry, true
+}
+
+// diff returns {"column": {"from": old, "to": new}} for every column whose value changed
+func diff(before, after map[string]interface{}) map[string]map[string]interface{} {
+	changes := make(map[string]map[string]interface{})
+	for column, newValue := range after {
+		oldValue := before[column]
+		if string(marshal(oldValue)) == string(marshal(newValue)) {
+			continue
+		}
+		if redactedColumns[column] {
+			oldValue, newValue = redacted, redacted
+		}
+		changes[column] = map[string]interface{}{"from": oldValue, "to": newValue}
+	}
+	return changes
+}
+
+func redact(row map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		if redactedColumns[column] && value != nil {
+			value = redacted
+		}
+		out[column] = value
+	}
+	return out
+}
+
+func entityID(primaryKeys []string, row map[string]interface{}) string {
+	parts := make([]string, len(primaryKeys))
+	for i, name := range primaryKeys {
+		if s, ok := stringValue(row[name]); ok {
+			parts[i] = s
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// stringValue formats v, dereferencing pointers; ok is false for nil
+func stringValue(v interface{}) (string, bool) {
+	rv := reflect.ValueOf(v)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return "", false
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return "", false
+	}
+	return fmt.Sprint(rv.Interface()), true
+}
+
+func marshal(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage(fmt.Sprintf("%q", fmt.Sprint(v)))
+	}
+	return data
+}
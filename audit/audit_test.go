@@ -0,0 +1,124 @@
+package audit
+
+import (
+	"adong-be/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func auditContext(t *testing.T) *gin.Context {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("identity", "u1")
+	c.Set(MetaKey, Meta{RequestID: "req-1", IP: "10.0.0.1", UserAgent: "test"})
+	return c
+}
+
+func TestNewEntryUpdate(t *testing.T) {
+	before := map[string]interface{}{"product_id": 7, "ingredient_id": "NL001", "kitchen_id": "K001", "unit_price": 12000.0}
+	after := map[string]interface{}{"product_id": 7, "ingredient_id": "NL001", "kitchen_id": "K001", "unit_price": 13500.0}
+
+	entry, ok := newEntry(auditContext(t), "supplier_price_list", []string{"product_id"}, before, after)
+	require.True(t, ok)
+	assert.Equal(t, models.AuditActionUpdate, entry.Action)
+	assert.Equal(t, "supplier_price_list", entry.EntityType)
+	assert.Equal(t, "7", entry.EntityID)
+	require.NotNil(t, entry.KitchenID)
+	assert.Equal(t, "K001", *entry.KitchenID)
+	require.NotNil(t, entry.ActorUserID)
+	assert.Equal(t, "u1", *entry.ActorUserID)
+	assert.Equal(t, "req-1", entry.RequestID)
+	assert.Equal(t, "10.0.0.1", entry.IPAddress)
+
+	var changes map[string]map[string]interface{}
+	require.NoError(t, json.Unmarshal(entry.Changes, &changes))
+	assert.Equal(t, map[string]map[string]interface{}{
+		"unit_price": {"from": 12000.0, "to": 13500.0},
+	}, changes)
+}
+
+func TestNewEntryUnchangedUpdateIsSkipped(t *testing.T) {
+	row := map[string]interface{}{"kitchen_id": "K001", "kitchen_name": "Bếp 1"}
+	_, ok := newEntry(auditContext(t), "master_kitchens", []string{"kitchen_id"}, row, row)
+	assert.False(t, ok)
+}
+
+func TestNewEntryApprove(t *testing.T) {
+	before := map[string]interface{}{"import_id": "IM1", "status": "draft"}
+	after := map[string]interface{}{"import_id": "IM1", "status": "approved"}
+	entry, ok := newEntry(auditContext(t), "inventory_imports", []string{"import_id"}, before, after)
+	require.True(t, ok)
+	assert.Equal(t, models.AuditActionApprove, entry.Action)
+}
+
+func TestNewEntryCreateAndDelete(t *testing.T) {
+	kitchen := "K002"
+	row := map[string]interface{}{"user_id": "u2", "kitchen_id": &kitchen, "password": "$2a$10$hash"}
+
+	created, ok := newEntry(auditContext(t), "master_users", []string{"user_id"}, nil, row)
+	require.True(t, ok)
+	assert.Equal(t, models.AuditActionCreate, created.Action)
+	assert.Equal(t, "u2", created.EntityID)
+	assert.Equal(t, "K002", *created.KitchenID)
+	assert.Nil(t, created.Before)
+	assert.NotContains(t, string(created.After), "$2a$10$hash")
+	assert.Contains(t, string(created.After), redacted)
+
+	deleted, ok := newEntry(nil, "master_users", []string{"user_id"}, row, nil)
+	require.True(t, ok)
+	assert.Equal(t, models.AuditActionDelete, deleted.Action)
+	assert.Nil(t, deleted.After)
+	assert.Nil(t, deleted.ActorUserID)
+}
+
+func TestEntityIDCompositeKey(t *testing.T) {
+	row := map[string]interface{}{"user_id": "u1", "kitchen_id": "K001"}
+	assert.Equal(t, "u1:K001", entityID([]string{"user_id", "kitchen_id"}, row))
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	var meta Meta
+	r.GET("/", func(c *gin.Context) {
+		v, _ := c.Get(MetaKey)
+		meta = v.(Meta)
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	req.Header.Set("User-Agent", "agent")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "abc", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "abc", meta.RequestID)
+	assert.Equal(t, "agent", meta.UserAgent)
+
+	req, _ = http.NewRequest("GET", "/", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+}
+
+func TestRegisterOnDryRun(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN: "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable",
+	}), &gorm.Config{DisableAutomaticPing: true, DryRun: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+	require.NoError(t, Register(db))
+
+	c := auditContext(t)
+	assert.NoError(t, db.WithContext(c).Create(&models.Kitchen{KitchenID: "K001"}).Error)
+	assert.NoError(t, db.WithContext(c).Model(&models.Kitchen{KitchenID: "K001"}).Update("kitchen_name", "Bếp 1").Error)
+	assert.NoError(t, db.WithContext(c).Delete(&models.Kitchen{}, "kitchen_id = ?", "K001").Error)
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"adong-be/apperr"
+	"adong-be/auth/apikey"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/rbac"
+	"adong-be/store"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyTouchInterval limits how often the last-used time of a key is written
+const apiKeyTouchInterval = time.Minute
+
+// APIKeyOrToken authenticates requests sending an X-API-Key header as the key's service
+// account and hands every other request to tokenAuth, the JWT middleware. A key request gets
+// the same "identity" and "user_role" as a token would, plus the key's permissions as
+// rbac.PermissionLimitKey, so route permissions and kitchen scoping apply unchanged.
+func APIKeyOrToken(db *store.Store, tokenAuth gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		raw := c.GetHeader(apikey.Header)
+		if raw == "" {
+			tokenAuth(c)
+			return
+		}
+
+		key, user, ok := authenticateAPIKey(c, db, raw)
+		if !ok {
+			apperr.Respond(c, apperr.New(apperr.CodeInvalidAPIKey))
+			return
+		}
+
+		now := time.Now()
+		if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval || key.LastUsedIP != c.ClientIP() {
+			if err := db.TouchAPIKey(c, key.KeyID, c.ClientIP(), now); err != nil {
+				logger.From(c).Error("touch API key error", "key_id", key.KeyID, "error", err)
+			}
+		}
+
+		c.Set("identity", user.UserID)
+		c.Set("user_role", user.Role)
+		c.Set(rbac.PermissionLimitKey, key.PermissionKeys())
+		c.Next()
+	}
+}
+
+// authenticateAPIKey returns the key and its service account when raw is a valid, usable key
+// of an active service account
+func authenticateAPIKey(c *gin.Context, db *store.Store, raw string) (*models.APIKey, *models.User, bool) {
+	prefix, secret, ok := apikey.Parse(raw)
+	if !ok {
+		logger.From(c).Warn("malformed API key", "ip", c.ClientIP())
+		return nil, nil, false
+	}
+	key, err := db.FindAPIKeyByPrefix(c, prefix)
+	if err != nil || !apikey.Verify(key.SecretHash, secret) {
+		logger.From(c).Warn("unknown API key", "prefix", prefix, "ip", c.ClientIP())
+		return nil, nil, false
+	}
+	if !key.Usable(time.Now()) {
+		logger.From(c).Warn("revoked or expired API key", "key_id", key.KeyID, "ip", c.ClientIP())
+		return nil, nil, false
+	}
+
+	var user models.User
+	if err := db.GormClient.WithContext(c).First(&user, "user_id = ?", key.UserID).Error; err != nil {
+		logger.From(c).Error("API key account lookup error", "key_id", key.KeyID, "error", err)
+		return nil, nil, false
+	}
+	if user.AccountType != models.AccountTypeService || user.Active == nil || !*user.Active {
+		logger.From(c).Warn("API key of inactive or non-service account", "key_id", key.KeyID, "user_id", user.UserID)
+		return nil, nil, false
+	}
+	return key, &user, true
+}
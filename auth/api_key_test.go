@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"adong-be/auth/apikey"
+	"adong-be/store"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeyOrToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tokenCalls := 0
+	tokenAuth := func(c *gin.Context) {
+		tokenCalls++
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+	r := gin.New()
+	r.GET("/api/x", APIKeyOrToken(&store.Store{}, tokenAuth), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/x", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 1, tokenCalls, "requests without a key use the token middleware")
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/x", nil)
+	req.Header.Set(apikey.Header, "not-a-key")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"API_KEY_INVALID"`)
+	assert.Equal(t, 1, tokenCalls, "a key request never falls back to the token")
+}
@@ -0,0 +1,55 @@
+// Package apikey creates and parses API keys of service accounts. A key reads
+// adk_<prefix>_<secret>: the prefix is stored in clear to find the key and to show it in
+// listings, only the SHA-256 hash of the secret is stored.
+package apikey
+
+import (
+	"adong-be/auth/password"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"strings"
+)
+
+const (
+	// Header carries the key on API requests
+	Header = "X-API-Key"
+
+	scheme     = "adk"
+	prefixSize = 5 // bytes, 8 characters once encoded
+)
+
+var prefixEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Generate returns a new key, its prefix and the hash of its secret
+func Generate() (key, prefix, hash string, err error) {
+	buf := make([]byte, prefixSize)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	prefix = prefixEncoding.EncodeToString(buf)
+	secret, hash, err := password.NewToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	return scheme + "_" + prefix + "_" + secret, prefix, hash, nil
+}
+
+// Parse splits key into its prefix and secret
+func Parse(key string) (prefix, secret string, ok bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(key), scheme+"_")
+	if !found {
+		return "", "", false
+	}
+	// The secret is base64url and may itself contain '_', the prefix never does
+	prefix, secret, found = strings.Cut(rest, "_")
+	if !found || len(prefix) != prefixEncoding.EncodedLen(prefixSize) || secret == "" {
+		return "", "", false
+	}
+	return prefix, secret, true
+}
+
+// Verify reports whether secret matches the stored hash
+func Verify(hash, secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(hash), []byte(password.HashToken(secret))) == 1
+}
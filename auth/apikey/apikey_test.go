@@ -0,0 +1,36 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateAndParse(t *testing.T) {
+	key, prefix, hash, err := Generate()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, "adk_"+prefix+"_"))
+	assert.Len(t, prefix, 8)
+
+	gotPrefix, secret, ok := Parse(key)
+	require.True(t, ok)
+	assert.Equal(t, prefix, gotPrefix)
+	assert.True(t, Verify(hash, secret))
+	assert.False(t, Verify(hash, secret+"x"))
+
+	other, _, _, _ := Generate()
+	assert.NotEqual(t, key, other)
+}
+
+func TestParseRejectsMalformedKeys(t *testing.T) {
+	for _, key := range []string{"", "adk_", "adk_abcdefgh", "adk_abcdefgh_", "xyz_abcdefgh_secret", "adk_abc_secret", "Bearer abc"} {
+		_, _, ok := Parse(key)
+		assert.False(t, ok, key)
+	}
+	prefix, secret, ok := Parse("adk_abcdefgh_se_cr-et")
+	assert.True(t, ok)
+	assert.Equal(t, "abcdefgh", prefix)
+	assert.Equal(t, "se_cr-et", secret)
+}
@@ -1,68 +1,148 @@
 package auth
 
 import (
+	"adong-be/auth/password"
+	"adong-be/logger"
+	"adong-be/models"
 	"adong-be/store"
+	"context"
+	"crypto/subtle"
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/hsdfat/go-auth-middleware/core"
-	"golang.org/x/crypto/bcrypt"
 )
 
-// LoginRequest represents the login credentials
+// LoginRequest represents the login credentials. Users with two-factor authentication
+// also send either a TOTP code or one of their recovery codes.
 type LoginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username     string `json:"username" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	TOTPCode     string `json:"totpCode"`
+	RecoveryCode string `json:"recoveryCode"`
 }
 
-// CreateDualPasswordAuthenticator creates an authenticator that supports both hashed and plain text passwords
-// It first tries bcrypt verification, then falls back to plain text comparison
-func CreateDualPasswordAuthenticator(db *store.Store) func(c *gin.Context) (*core.User, error) {
+// PlaintextFallbackEnabled reports whether legacy plain text passwords are still accepted at
+// now. until is the last day of the migration window, inclusive; the zero time keeps it open.
+func PlaintextFallbackEnabled(until, now time.Time) bool {
+	return until.IsZero() || now.Before(until.AddDate(0, 0, 1))
+}
+
+// EnforcePlaintextWindow runs at startup: while the migration window is open it reports how many
+// accounts still rely on a plain text password, once it has closed it wipes them all
+func EnforcePlaintextWindow(ctx context.Context, db *store.Store, until time.Time) error {
+	if PlaintextFallbackEnabled(until, time.Now()) {
+		count, err := db.CountPlainPasswords()
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			windowUntil := "open"
+			if !until.IsZero() {
+				windowUntil = until.Format(time.DateOnly)
+			}
+			logger.From(ctx).Warn("users still have plain text passwords, they are rehashed on next login",
+				"count", count, "window_until", windowUntil)
+		}
+		return nil
+	}
+
+	wiped, err := db.WipePlainPasswords()
+	if err != nil {
+		return err
+	}
+	if wiped > 0 {
+		logger.From(ctx).Info("plain text password window closed, remaining plain text passwords wiped", "count", wiped)
+	}
+	return nil
+}
+
+// ErrInvalidCredentials is returned for an unknown user, an inactive user and a wrong
+// password alike, so the login response does not reveal which accounts exist
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// dummyHash is verified against when the user does not exist or is inactive, so those
+// attempts take as long as a wrong password
+var dummyHash = sync.OnceValues(func() (string, error) {
+	return password.Hash("not-a-real-password")
+})
+
+// verifyDummy spends the time of a password check on a login that is refused anyway
+func verifyDummy(c *gin.Context, pw string) {
+	hash, err := dummyHash()
+	if err != nil {
+		logger.From(c).Error("failed to create dummy password hash", "error", err)
+		return
+	}
+	password.Verify(hash, pw)
+}
+
+// CreatePasswordAuthenticator creates an authenticator that verifies bcrypt hashes.
+// Until the last day of the migration window, plaintextUntil, a legacy plain text password
+// is still accepted once: on success it is rehashed and the plain text copy is wiped.
+func CreatePasswordAuthenticator(db *store.Store, plaintextUntil time.Time) func(c *gin.Context) (*core.User, error) {
 	return func(c *gin.Context) (*core.User, error) {
 		var loginReq LoginRequest
-		if err := c.ShouldBindJSON(&loginReq); err != nil {
+		// The body is cached so CreateTwoFactorAuthenticator can read the codes afterwards
+		if err := c.ShouldBindBodyWith(&loginReq, binding.JSON); err != nil {
 			return nil, errors.New("invalid request format")
 		}
 
-		// Get user with both passwords
-		dbUser, err := db.GetUserWithPlainPassword(loginReq.Username)
+		dbUser, err := db.GetUserForLogin(loginReq.Username)
 		if err != nil {
-			return nil, errors.New("invalid username or password")
+			verifyDummy(c, loginReq.Password)
+			return nil, ErrInvalidCredentials
 		}
+		c.Set(loginUserIDKey, dbUser.UserID)
 
-		// Check if user is active
-		if dbUser.Active == nil || !*dbUser.Active {
-			return nil, errors.New("user account is inactive")
+		// Inactive accounts and service accounts, which only use API keys, get the same
+		// answer as a wrong password
+		if dbUser.Active == nil || !*dbUser.Active || dbUser.AccountType == models.AccountTypeService {
+			verifyDummy(c, loginReq.Password)
+			logger.From(c).Warn("login to inactive or service account", "user_id", dbUser.UserID)
+			return nil, ErrInvalidCredentials
 		}
 
-		// Try bcrypt verification first
-		err = bcrypt.CompareHashAndPassword([]byte(dbUser.Password), []byte(loginReq.Password))
-		if err == nil {
-			// Bcrypt verification successful
-			return &core.User{
-				ID:       dbUser.UserID,
-				Username: dbUser.UserName,
-				Email:    dbUser.Email,
-				Password: dbUser.Password,
-				Role:     dbUser.Role,
-				IsActive: *dbUser.Active,
-			}, nil
+		if password.Verify(dbUser.Password, loginReq.Password) {
+			// The hash works, a leftover plain text copy is no longer needed
+			if dbUser.PlainPassword != "" {
+				if err := db.ClearPlainPassword(dbUser.UserID); err != nil {
+					logger.From(c).Error("failed to clear plain text password", "user_id", dbUser.UserID, "error", err)
+				}
+			}
+			return toCoreUser(dbUser), nil
 		}
 
-		// Fallback to plain text comparison if bcrypt fails
-		if dbUser.PlainPassword != "" && dbUser.PlainPassword == loginReq.Password {
-			// Plain text verification successful
-			return &core.User{
-				ID:       dbUser.UserID,
-				Username: dbUser.UserName,
-				Email:    dbUser.Email,
-				Password: dbUser.Password,
-				Role:     dbUser.Role,
-				IsActive: *dbUser.Active,
-			}, nil
+		// Compared in constant time like the bcrypt check, so timing doesn't leak the password
+		if dbUser.PlainPassword != "" && subtle.ConstantTimeCompare([]byte(dbUser.PlainPassword), []byte(loginReq.Password)) == 1 &&
+			PlaintextFallbackEnabled(plaintextUntil, time.Now()) {
+			hash, err := password.Hash(loginReq.Password)
+			if err != nil {
+				return nil, err
+			}
+			if err := db.UpgradePasswordHash(dbUser.UserID, hash); err != nil {
+				logger.From(c).Error("failed to rehash plain text password", "user_id", dbUser.UserID, "error", err)
+				return nil, ErrInvalidCredentials
+			}
+			logger.From(c).Info("plain text password rehashed", "user_id", dbUser.UserID)
+			dbUser.Password = hash
+			return toCoreUser(dbUser), nil
 		}
 
-		// Both verifications failed
-		return nil, errors.New("invalid username or password")
+		return nil, ErrInvalidCredentials
+	}
+}
+
+func toCoreUser(dbUser *models.User) *core.User {
+	return &core.User{
+		ID:       dbUser.UserID,
+		Username: dbUser.UserName,
+		Email:    dbUser.Email,
+		Password: dbUser.Password,
+		Role:     dbUser.Role,
+		IsActive: *dbUser.Active,
 	}
 }
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlaintextFallbackEnabled(t *testing.T) {
+	until := time.Date(2026, 3, 31, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		name  string
+		until time.Time
+		now   time.Time
+		want  bool
+	}{
+		{"no deadline", time.Time{}, time.Date(2030, 1, 1, 0, 0, 0, 0, time.Local), true},
+		{"before the last day", until, until.AddDate(0, 0, -1), true},
+		{"during the last day", until, until.Add(23 * time.Hour), true},
+		{"after the last day", until, until.AddDate(0, 0, 1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PlaintextFallbackEnabled(tt.until, tt.now))
+		})
+	}
+}
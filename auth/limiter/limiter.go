@@ -0,0 +1,78 @@
+// Package limiter counts failed login attempts per key (a user name or an IP address) and
+// turns them into a waiting time: exponential backoff after a few free attempts, then a
+// temporary lockout. Memory keeps the counters in process; Postgres shares them between
+// replicas through the login_throttles table.
+package limiter
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Entry is the failure counter of one key
+type Entry struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Limiter stores failure counters. Fail increments the counter atomically, starting over
+// when the last failure is older than window.
+type Limiter interface {
+	Get(ctx context.Context, key string) (Entry, error)
+	Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// UserKey is the key counting failures for a user name
+func UserKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+// IPKey is the key counting failures from a client address
+func IPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Policy turns a failure count into a waiting time
+type Policy struct {
+	// FreeAttempts failures are allowed before backoff starts
+	FreeAttempts int
+	// BaseDelay is the wait after the first failure beyond FreeAttempts, doubled for each
+	// further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures lock the key for LockoutDuration; 0 disables the lockout
+	LockoutAfter    int
+	LockoutDuration time.Duration
+	// Window is how long failures are remembered after the last one
+	Window time.Duration
+}
+
+// Wait returns how long the key of e must wait at now before its next attempt
+func (p Policy) Wait(e Entry, now time.Time) time.Duration {
+	if e.Failures == 0 || now.Sub(e.LastFailure) >= p.Window {
+		return 0
+	}
+	var delay time.Duration
+	switch {
+	case p.LockoutAfter > 0 && e.Failures >= p.LockoutAfter:
+		delay = p.LockoutDuration
+	case e.Failures > p.FreeAttempts:
+		delay = p.MaxDelay
+		if shift := e.Failures - p.FreeAttempts - 1; shift < 30 && p.BaseDelay<<shift < p.MaxDelay {
+			delay = p.BaseDelay << shift
+		}
+	default:
+		return 0
+	}
+	if wait := e.LastFailure.Add(delay).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Locked reports whether e has reached the lockout threshold and is still within it
+func (p Policy) Locked(e Entry, now time.Time) bool {
+	return p.LockoutAfter > 0 && e.Failures >= p.LockoutAfter && now.Before(e.LastFailure.Add(p.LockoutDuration))
+}
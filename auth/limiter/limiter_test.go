@@ -0,0 +1,71 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+func TestPolicy_Wait(t *testing.T) {
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		failures int
+		ago      time.Duration
+		want     time.Duration
+	}{
+		{0, 0, 0},
+		{3, 0, 0},
+		{4, 0, time.Second},
+		{5, 0, 2 * time.Second},
+		{6, time.Second, 3 * time.Second},
+		{9, 0, 32 * time.Second},
+		{9, time.Minute, 0},
+		{10, 0, 15 * time.Minute},
+		{10, 5 * time.Minute, 10 * time.Minute},
+		{40, 0, 15 * time.Minute},
+		{10, 2 * time.Hour, 0},
+	}
+	for _, tt := range tests {
+		e := Entry{Failures: tt.failures, LastFailure: now.Add(-tt.ago)}
+		assert.Equal(t, tt.want, testPolicy.Wait(e, now), "failures=%d ago=%s", tt.failures, tt.ago)
+	}
+
+	noLockout := testPolicy
+	noLockout.LockoutAfter = 0
+	assert.Equal(t, time.Minute, noLockout.Wait(Entry{Failures: 50, LastFailure: now}, now), "backoff is capped")
+	assert.True(t, testPolicy.Locked(Entry{Failures: 10, LastFailure: now}, now))
+	assert.False(t, noLockout.Locked(Entry{Failures: 10, LastFailure: now}, now))
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	for i := 1; i <= 3; i++ {
+		e, err := m.Fail(ctx, UserKey("Bep01"), now, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, i, e.Failures)
+	}
+	e, _ := m.Get(ctx, UserKey(" bep01"))
+	assert.Equal(t, 3, e.Failures, "user keys ignore case and spaces")
+
+	e, _ = m.Fail(ctx, UserKey("bep01"), now.Add(2*time.Hour), time.Hour)
+	assert.Equal(t, 1, e.Failures, "counting starts over after the window")
+
+	require.NoError(t, m.Reset(ctx, UserKey("bep01")))
+	e, _ = m.Get(ctx, UserKey("bep01"))
+	assert.Zero(t, e.Failures)
+}
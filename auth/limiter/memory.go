@@ -0,0 +1,61 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps the counters of a single process. Counters are lost on restart and are not
+// shared between replicas; use Postgres when running more than one instance.
+type Memory struct {
+	mu        sync.Mutex
+	entries   map[string]Entry
+	lastPrune time.Time
+}
+
+// NewMemory returns an empty in-memory limiter
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]Entry)}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.entries[key], nil
+}
+
+func (m *Memory) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now, window)
+
+	e := m.entries[key]
+	if now.Sub(e.LastFailure) >= window {
+		e.Failures = 0
+	}
+	e.Failures++
+	e.LastFailure = now
+	m.entries[key] = e
+	return e, nil
+}
+
+func (m *Memory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+// prune drops counters older than window, at most once per window
+func (m *Memory) prune(now time.Time, window time.Duration) {
+	if now.Sub(m.lastPrune) < window {
+		return
+	}
+	for key, e := range m.entries {
+		if now.Sub(e.LastFailure) >= window {
+			delete(m.entries, key)
+		}
+	}
+	m.lastPrune = now
+}
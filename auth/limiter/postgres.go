@@ -0,0 +1,76 @@
+package limiter
+
+import (
+	"adong-be/logger"
+	"adong-be/models"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Postgres keeps the counters in the login_throttles table so every replica sees the same
+// failures. Fail is a single upsert, concurrent failures are all counted.
+type Postgres struct {
+	DB *gorm.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewPostgres returns a limiter storing its counters through db
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{DB: db}
+}
+
+func (p *Postgres) Get(ctx context.Context, key string) (Entry, error) {
+	var row models.LoginThrottle
+	err := p.DB.WithContext(ctx).First(&row, "throttle_key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Entry{}, nil
+	}
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Failures: row.Failures, LastFailure: row.LastFailure}, nil
+}
+
+func (p *Postgres) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	p.prune(ctx, now, window)
+
+	var row models.LoginThrottle
+	err := p.DB.WithContext(ctx).Raw(`
+		INSERT INTO login_throttles (throttle_key, failures, last_failure)
+		VALUES (?, 1, ?)
+		ON CONFLICT (throttle_key) DO UPDATE SET
+			failures = CASE WHEN login_throttles.last_failure <= ? THEN 1 ELSE login_throttles.failures + 1 END,
+			last_failure = EXCLUDED.last_failure
+		RETURNING throttle_key, failures, last_failure`,
+		key, now, now.Add(-window)).Scan(&row).Error
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Failures: row.Failures, LastFailure: row.LastFailure}, nil
+}
+
+func (p *Postgres) Reset(ctx context.Context, key string) error {
+	return p.DB.WithContext(ctx).Where("throttle_key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+// prune deletes counters older than window, at most once per window and replica
+func (p *Postgres) prune(ctx context.Context, now time.Time, window time.Duration) {
+	p.mu.Lock()
+	due := now.Sub(p.lastPrune) >= window
+	if due {
+		p.lastPrune = now
+	}
+	p.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := p.DB.WithContext(ctx).Where("last_failure <= ?", now.Add(-window)).Delete(&models.LoginThrottle{}).Error; err != nil {
+		logger.Log.Error("prune login throttles error", "error", err)
+	}
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"adong-be/apperr"
+	"adong-be/auth/limiter"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/store"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// Context keys the authenticators use to tell LoginGuard how an attempt failed
+const (
+	loginFailureKey = "login_failure"
+	loginUserIDKey  = "login_user_id"
+)
+
+// loginPending marks an attempt with a correct password that still needs its second factor;
+// it is neither a failure nor a success
+const loginPending = "pending"
+
+// LoginGuard throttles password logins per user name and per client address. It runs in
+// front of the login handler: attempts are refused with 429 while a key has to wait, and the
+// outcome of every attempt updates the counters and the failed login log.
+type LoginGuard struct {
+	Limiter limiter.Limiter
+	Store   *store.Store
+	User    limiter.Policy
+	IP      limiter.Policy
+	now     func() time.Time
+}
+
+// NewLoginGuard creates a guard counting failures in l and logging them to st
+func NewLoginGuard(l limiter.Limiter, st *store.Store, user, ip limiter.Policy) *LoginGuard {
+	return &LoginGuard{Limiter: l, Store: st, User: user, IP: ip, now: time.Now}
+}
+
+// setLoginFailure records why the current attempt failed, for LoginGuard
+func setLoginFailure(c *gin.Context, reason string) {
+	c.Set(loginFailureKey, reason)
+}
+
+// wait returns how long key has to wait under p. A broken limiter lets the attempt through
+// rather than locking everybody out.
+func (g *LoginGuard) wait(ctx context.Context, key string, p limiter.Policy, now time.Time) (time.Duration, bool) {
+	e, err := g.Limiter.Get(ctx, key)
+	if err != nil {
+		logger.From(ctx).Error("login limiter get error", "key", key, "error", err)
+		return 0, false
+	}
+	return p.Wait(e, now), p.Locked(e, now)
+}
+
+// Middleware guards a handler taking {"username": ...} in its JSON body
+func (g *LoginGuard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Username string `json:"username"`
+		}
+		// The body is cached, the handler binds it again
+		_ = c.ShouldBindBodyWith(&req, binding.JSON)
+		userKey, ipKey := limiter.UserKey(req.Username), limiter.IPKey(c.ClientIP())
+		now := g.now()
+
+		userWait, userLocked := g.wait(c, userKey, g.User, now)
+		ipWait, ipLocked := g.wait(c, ipKey, g.IP, now)
+		if wait := max(userWait, ipWait); wait > 0 {
+			reason := models.LoginFailureThrottled
+			if userLocked || ipLocked {
+				reason = models.LoginFailureLocked
+			}
+			g.record(c, req.Username, reason)
+			seconds := int(math.Ceil(wait.Seconds()))
+			c.Header("Retry-After", fmt.Sprint(seconds))
+			apperr.Respond(c, apperr.New(apperr.CodeLoginThrottled).With("retryAfter", seconds))
+			return
+		}
+
+		c.Next()
+
+		reason := c.GetString(loginFailureKey)
+		switch status := c.Writer.Status(); {
+		case status == http.StatusOK && reason != loginPending:
+			// Only the user counter is cleared: signing in to one's own account must not
+			// reset the failures an address collected against other accounts
+			if err := g.Limiter.Reset(c, userKey); err != nil {
+				logger.From(c).Error("login limiter reset error", "key", userKey, "error", err)
+			}
+		case status == http.StatusUnauthorized && reason != loginPending:
+			if reason == "" {
+				reason = models.LoginFailureInvalidCredentials
+			}
+			g.fail(c, userKey, g.User, now)
+			g.fail(c, ipKey, g.IP, now)
+			g.record(c, req.Username, reason)
+		}
+	}
+}
+
+func (g *LoginGuard) fail(ctx context.Context, key string, p limiter.Policy, now time.Time) {
+	e, err := g.Limiter.Fail(ctx, key, now, p.Window)
+	if err != nil {
+		logger.From(ctx).Error("login limiter fail error", "key", key, "error", err)
+		return
+	}
+	if p.LockoutAfter > 0 && e.Failures == p.LockoutAfter {
+		logger.From(ctx).Warn("login locked after repeated failures", "key", key, "failures", e.Failures, "duration", p.LockoutDuration.String())
+	}
+}
+
+// record writes the attempt to the failed login log
+func (g *LoginGuard) record(c *gin.Context, username, reason string) {
+	entry := models.FailedLogin{
+		UserName:  username,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Reason:    reason,
+	}
+	if id := c.GetString(loginUserIDKey); id != "" {
+		entry.UserID = &id
+	}
+	logger.From(c).Warn("login failed", "username", username, "ip", entry.IPAddress, "reason", reason)
+	if err := g.Store.RecordFailedLogin(c, &entry); err != nil {
+		logger.From(c).Error("record failed login error", "error", err)
+	}
+}
+
+// Unlock clears the failure counter of a user name, ending a lockout
+func (g *LoginGuard) Unlock(ctx context.Context, username string) error {
+	return g.Limiter.Reset(ctx, limiter.UserKey(username))
+}
+
+// Status returns the failure counter of a user name and whether it is locked out
+func (g *LoginGuard) Status(ctx context.Context, username string) (limiter.Entry, bool, error) {
+	e, err := g.Limiter.Get(ctx, limiter.UserKey(username))
+	if err != nil {
+		return limiter.Entry{}, false, err
+	}
+	return e, g.User.Locked(e, g.now()), nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"adong-be/auth/limiter"
+	"adong-be/store"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// guardRouter serves a login endpoint answering with status, behind a guard writing its
+// failed login log to a dry run database
+func guardRouter(t *testing.T, l limiter.Limiter, now *time.Time, status func(c *gin.Context) int) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable"}),
+		&gorm.Config{DisableAutomaticPing: true, DryRun: true, SkipDefaultTransaction: true})
+	require.NoError(t, err)
+
+	policy := limiter.Policy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAfter: 3, LockoutDuration: 15 * time.Minute, Window: time.Hour}
+	guard := NewLoginGuard(l, &store.Store{GormClient: db}, policy, policy)
+	guard.now = func() time.Time { return *now }
+
+	r := gin.New()
+	r.POST("/login", guard.Middleware(), func(c *gin.Context) {
+		c.Status(status(c))
+	})
+	return r
+}
+
+func login(r *gin.Engine, username string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"username":"`+username+`","password":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginGuard_BackoffAndLockout(t *testing.T) {
+	l := limiter.NewMemory()
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	r := guardRouter(t, l, &now, func(c *gin.Context) int { return http.StatusUnauthorized })
+
+	assert.Equal(t, http.StatusUnauthorized, login(r, "bep01").Code)
+	assert.Equal(t, http.StatusUnauthorized, login(r, "bep01").Code, "first failure is free")
+
+	w := login(r, "bep01")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "second failure costs a second")
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusUnauthorized, login(r, "bep01").Code)
+	w = login(r, "bep01")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, "third failure locks the user")
+	assert.Equal(t, "900", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"LOGIN_THROTTLED"`)
+
+	e, _ := l.Get(context.Background(), limiter.UserKey("bep01"))
+	assert.Equal(t, 3, e.Failures, "refused attempts are not counted")
+}
+
+func TestLoginGuard_SuccessClearsUserOnly(t *testing.T) {
+	ctx := context.Background()
+	l := limiter.NewMemory()
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	r := guardRouter(t, l, &now, func(c *gin.Context) int {
+		if c.Query("ok") != "" {
+			return http.StatusOK
+		}
+		return http.StatusUnauthorized
+	})
+
+	login(r, "bep01")
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/login?ok=1", strings.NewReader(`{"username":"bep01"}`))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	user, _ := l.Get(ctx, limiter.UserKey("bep01"))
+	ip, _ := l.Get(ctx, limiter.IPKey("192.0.2.1"))
+	assert.Zero(t, user.Failures)
+	assert.Equal(t, 1, ip.Failures)
+}
+
+func TestLoginGuard_PendingSecondFactorIsNotAFailure(t *testing.T) {
+	l := limiter.NewMemory()
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	r := guardRouter(t, l, &now, func(c *gin.Context) int {
+		setLoginFailure(c, loginPending)
+		return http.StatusUnauthorized
+	})
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login(r, "bep01").Code)
+	}
+	e, _ := l.Get(context.Background(), limiter.UserKey("bep01"))
+	assert.Zero(t, e.Failures)
+}
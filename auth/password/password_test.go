@@ -0,0 +1,62 @@
+package password
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := DefaultPolicy()
+	tests := []struct {
+		name       string
+		password   string
+		username   string
+		violations int
+	}{
+		{"valid", "Adong2024", "bep01", 0},
+		{"too short", "Ab1", "", 1},
+		{"no digit no upper", "adongfood", "", 2},
+		{"contains username", "Bep01secret", "bep01", 1},
+		{"vietnamese letters count", "Bếpăn2024", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.username)
+			if tt.violations == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var perr *PolicyError
+			if assert.True(t, errors.As(err, &perr)) {
+				assert.Len(t, perr.Violations, tt.violations)
+			}
+		})
+	}
+
+	symbols := Policy{MinLength: 4, RequireSymbol: true}
+	assert.Error(t, symbols.Validate("abcd", ""))
+	assert.NoError(t, symbols.Validate("ab-cd", ""))
+}
+
+func TestHashVerify(t *testing.T) {
+	hash, err := Hash("Adong2024")
+	assert.NoError(t, err)
+	assert.True(t, IsHash(hash))
+	assert.False(t, IsHash("Adong2024"))
+	assert.True(t, Verify(hash, "Adong2024"))
+	assert.False(t, Verify(hash, "adong2024"))
+	assert.False(t, Verify("Adong2024", "Adong2024"))
+}
+
+func TestNewToken(t *testing.T) {
+	token, hash, err := NewToken()
+	assert.NoError(t, err)
+	assert.Len(t, token, 43)
+	assert.Equal(t, HashToken(token), hash)
+	assert.NotEqual(t, token, hash)
+
+	other, _, _ := NewToken()
+	assert.NotEqual(t, token, other)
+}
@@ -0,0 +1,35 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewToken returns a random URL-safe token, e.g. for a password reset link, and the hash
+// to store in its place. Only the hash is kept; the token itself is shown to the user once.
+func NewToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the SHA-256 hex digest under which a token is stored
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Unusable returns a bcrypt hash that no password matches, used to lock an account
+// until its owner sets a new password through a reset link
+func Unusable() (string, error) {
+	token, _, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	return Hash(token)
+}
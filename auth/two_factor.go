@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"adong-be/auth/password"
+	"adong-be/auth/totp"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/store"
+	"errors"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/hsdfat/go-auth-middleware/core"
+)
+
+// Login errors of the second step. Their messages reach the client unchanged, which tells
+// the login form whether to ask for a code or send the user to enrollment.
+var (
+	ErrTwoFactorRequired           = errors.New("two-factor code required")
+	ErrTwoFactorInvalid            = errors.New("invalid two-factor code")
+	ErrTwoFactorEnrollmentRequired = errors.New("two-factor enrollment required")
+)
+
+// CreateTwoFactorAuthenticator wraps next, which checks the password, with the TOTP step.
+// A user who enabled two-factor authentication, or whose role requires it, only gets a
+// user back, and therefore a token pair, once a valid TOTP or recovery code is sent along
+// with the password. Each code is accepted once.
+func CreateTwoFactorAuthenticator(db *store.Store, next func(c *gin.Context) (*core.User, error)) func(c *gin.Context) (*core.User, error) {
+	return func(c *gin.Context) (*core.User, error) {
+		user, err := next(c)
+		if err != nil {
+			return nil, err
+		}
+
+		tf, err := db.GetTwoFactor(c, user.ID)
+		if err != nil {
+			logger.From(c).Error("load two-factor error", "user_id", user.ID, "error", err)
+			return nil, errors.New("login failed")
+		}
+		if !tf.Enabled() {
+			required, err := db.RequiresTwoFactor(c, user.ID, user.Role)
+			if err != nil {
+				logger.From(c).Error("two-factor requirement check error", "user_id", user.ID, "error", err)
+				return nil, errors.New("login failed")
+			}
+			if required {
+				logger.From(c).Warn("login refused, role requires two-factor", "user_id", user.ID, "role", user.Role)
+				setLoginFailure(c, loginPending)
+				return nil, ErrTwoFactorEnrollmentRequired
+			}
+			return user, nil
+		}
+
+		var loginReq LoginRequest
+		if err := c.ShouldBindBodyWith(&loginReq, binding.JSON); err != nil {
+			return nil, errors.New("invalid request format")
+		}
+
+		var ok bool
+		switch {
+		case loginReq.TOTPCode != "":
+			if step, valid := totp.Validate(tf.TOTPSecret, loginReq.TOTPCode, time.Now()); valid {
+				ok, err = db.UseTOTPStep(c, user.ID, step)
+			}
+		case loginReq.RecoveryCode != "":
+			ok, err = db.UseRecoveryCode(c, user.ID, password.HashToken(totp.NormalizeRecoveryCode(loginReq.RecoveryCode)))
+			if ok {
+				logger.From(c).Warn("login with recovery code", "user_id", user.ID)
+			}
+		default:
+			setLoginFailure(c, loginPending)
+			return nil, ErrTwoFactorRequired
+		}
+		if err != nil {
+			logger.From(c).Error("two-factor check error", "user_id", user.ID, "error", err)
+			return nil, errors.New("login failed")
+		}
+		if !ok {
+			logger.From(c).Warn("login with invalid two-factor code", "user_id", user.ID)
+			setLoginFailure(c, models.LoginFailureTwoFactorInvalid)
+			return nil, ErrTwoFactorInvalid
+		}
+		return user, nil
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"adong-be/apperr"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// middlewareCodes maps the messages of the auth middleware's error responses, and of the
+// authenticator errors it passes through, to error codes
+var middlewareCodes = map[string]apperr.Code{
+	ErrInvalidCredentials.Error():          apperr.CodeInvalidCredentials,
+	ErrTwoFactorRequired.Error():           apperr.CodeTwoFactorCodeRequired,
+	ErrTwoFactorInvalid.Error():            apperr.CodeTwoFactorCodeInvalid,
+	ErrTwoFactorEnrollmentRequired.Error(): apperr.CodeTwoFactorEnrollmentRequired,
+	"Token is expired":                     apperr.CodeTokenExpired,
+	"Refresh token is expired":             apperr.CodeTokenExpired,
+	"User account is inactive":             apperr.CodeUserInactive,
+	"Username already exists":              apperr.CodeUsernameTaken,
+	"Email already registered":             apperr.CodeEmailTaken,
+	"Session not found":                    apperr.CodeSessionNotFound,
+}
+
+// Unauthorized writes the error responses of the auth middleware in the API's error model.
+// Messages it does not know fall back to a code for their status.
+func Unauthorized(c *gin.Context, status int, message string) {
+	if code, ok := middlewareCodes[message]; ok {
+		apperr.Respond(c, apperr.New(code))
+		return
+	}
+	switch {
+	case status >= http.StatusInternalServerError:
+		apperr.Respond(c, apperr.Internal(errors.New(message)))
+	case status == http.StatusForbidden:
+		apperr.Respond(c, apperr.New(apperr.CodeForbidden))
+	case status == http.StatusUnauthorized:
+		apperr.Respond(c, apperr.New(apperr.CodeTokenInvalid))
+	default:
+		apperr.Respond(c, apperr.New(apperr.CodeInvalidRequest))
+	}
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"adong-be/apperr"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnauthorized(t *testing.T) {
+	tests := []struct {
+		status     int
+		message    string
+		wantStatus int
+		wantCode   apperr.Code
+	}{
+		{http.StatusUnauthorized, ErrInvalidCredentials.Error(), http.StatusUnauthorized, apperr.CodeInvalidCredentials},
+		{http.StatusUnauthorized, ErrTwoFactorRequired.Error(), http.StatusUnauthorized, apperr.CodeTwoFactorCodeRequired},
+		{http.StatusUnauthorized, ErrTwoFactorEnrollmentRequired.Error(), http.StatusForbidden, apperr.CodeTwoFactorEnrollmentRequired},
+		{http.StatusUnauthorized, "Token is expired", http.StatusUnauthorized, apperr.CodeTokenExpired},
+		{http.StatusUnauthorized, "Token not found or invalid", http.StatusUnauthorized, apperr.CodeTokenInvalid},
+		{http.StatusForbidden, "You don't have permission to access this resource", http.StatusForbidden, apperr.CodeForbidden},
+		{http.StatusConflict, "Username already exists", http.StatusConflict, apperr.CodeUsernameTaken},
+		{http.StatusInternalServerError, "Failed to store tokens", http.StatusInternalServerError, apperr.CodeInternal},
+	}
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/auth/login", nil)
+		Unauthorized(c, tt.status, tt.message)
+
+		assert.Equal(t, tt.wantStatus, w.Code, tt.message)
+		var body apperr.Response
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, tt.wantCode, body.Code, tt.message)
+	}
+}
@@ -1,50 +1,115 @@
 package main
 
 import (
+	"adong-be/app"
+	"adong-be/audit"
+	"adong-be/auth"
+	"adong-be/config"
+	"adong-be/metrics"
 	"adong-be/migrate"
 	"adong-be/server"
 	"adong-be/store"
+	"adong-be/telemetry"
+	"context"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
 )
 
+// tokenCleanupInterval is how often expired tokens and sessions are deleted
+const tokenCleanupInterval = time.Hour
+
 func main() {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found")
 	}
 
-	// Initialize database
-	var err error
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		dsn = "host=localhost user=adong password=adong123 dbname=adongfood port=5432 sslmode=disable"
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
 	}
+	log.Printf("Configuration loaded (env=%s)", cfg.Env)
 
-	store.DB.GormClient, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	// Trace requests and queries, see telemetry
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatal("Failed to set up tracing: ", err)
+	}
+
+	// Initialize database
+	db, err := store.Open(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
+	db.SessionIdleTimeout = cfg.Auth.SessionIdleTimeout
+	db.PasswordPolicy = cfg.Auth.PasswordPolicy
+	store.DB = db
+
+	// A span for every query run with a request context
+	if err := telemetry.InstrumentDB(db.GormClient); err != nil {
+		log.Fatal("Failed to register tracing callbacks:", err)
+	}
+
+	// Record every data change in the audit log
+	if err := audit.Register(db.GormClient); err != nil {
+		log.Fatal("Failed to register audit callbacks:", err)
+	}
 
 	log.Println("Database connected successfully")
 
+	// Export the connection pool statistics on /metrics
+	sqlDB, err := db.GormClient.DB()
+	if err != nil {
+		log.Fatal("Failed to get database connection:", err)
+	}
+	if err := metrics.RegisterDB(sqlDB); err != nil {
+		log.Fatal("Failed to register database metrics:", err)
+	}
+
 	// Run auto-migration to initialize schema if needed
-	if err := migrate.AutoMigrate(store.DB.GormClient); err != nil {
-		log.Fatal("Failed to auto-migrate database:", err)
+	if cfg.Migrate.Auto {
+		if err := migrate.AutoMigrate(db.GormClient); err != nil {
+			log.Fatal("Failed to auto-migrate database:", err)
+		}
+	}
+
+	// Wipe legacy plain text passwords once their migration window has closed
+	if err := auth.EnforcePlaintextWindow(context.Background(), db, cfg.Auth.PlaintextFallbackUntil); err != nil {
+		log.Fatal("Failed to enforce plain text password window:", err)
 	}
-	s := server.SetupRouter() 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "18080"
+	// The server and the background workers stop together on SIGTERM or Ctrl+C, letting
+	// requests in flight, e.g. approvals, commit before the database pool closes
+	httpServer := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           server.SetupRouter(cfg, db),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
 	}
+	application := app.New(httpServer, cfg.Server.ShutdownTimeout)
+	application.OnClose("tracing", shutdownTracing)
+	application.OnClose("database", func(context.Context) error { return sqlDB.Close() })
+
+	// Purge expired and idle sessions in the background, once at startup to check the
+	// token tables are usable
+	application.Add(app.Worker{
+		Name:  "token_cleanup",
+		Start: func(context.Context) error { return db.CleanupExpiredTokens() },
+		Run:   func(ctx context.Context) { db.CleanupTokensEvery(ctx, tokenCleanupInterval) },
+	})
 
-	log.Printf("Server starting on port %s", port)
-	if err := s.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	log.Printf("Server starting on port %s", cfg.Server.Port)
+	if err := application.Run(ctx); err != nil {
+		log.Fatal("Server stopped with errors: ", err)
 	}
+	log.Println("Server stopped")
 }
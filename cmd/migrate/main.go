@@ -0,0 +1,131 @@
+// Command migrate applies, reverts and creates schema migrations.
+//
+//	migrate [config flags] up             apply every pending migration
+//	migrate [config flags] down [n]       revert the n most recent migrations (default 1)
+//	migrate [config flags] to <version>   migrate up or down to version, 0 reverts everything
+//	migrate [config flags] status         list migrations and when they were applied
+//	migrate create <name> [dir]           add empty up and down files (dir defaults to migrate/migrations)
+//
+// The database is configured like the server, see the config package.
+package main
+
+import (
+	"adong-be/config"
+	"adong-be/migrate"
+	"adong-be/store"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/joho/godotenv"
+)
+
+// migrationsDir is where create writes new migrations, relative to the repository root
+const migrationsDir = "migrate/migrations"
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "create" {
+		create(os.Args[2:])
+		return
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found")
+	}
+	cfg, args, err := config.LoadCommand(os.Args[1:])
+	if err != nil {
+		log.Fatal("Invalid configuration: ", err)
+	}
+	if len(args) == 0 {
+		usage()
+	}
+
+	db, err := store.Open(cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	m, err := migrate.New(db.GormClient)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps = parseInt(args[1])
+		}
+		err = m.Down(ctx, steps)
+	case "to":
+		if len(args) < 2 {
+			usage()
+		}
+		err = m.To(ctx, parseInt(args[1]))
+	case "status":
+		err = status(ctx, m)
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func create(args []string) {
+	if len(args) == 0 {
+		usage()
+	}
+	dir := migrationsDir
+	if len(args) > 1 {
+		dir = args[1]
+	}
+	up, down, err := migrate.Create(dir, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Created", up)
+	fmt.Println("Created", down)
+}
+
+func status(ctx context.Context, m *migrate.Migrator) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED\tNOTE")
+	for _, s := range statuses {
+		applied, note := "pending", ""
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		switch {
+		case s.Unknown:
+			note = "unknown to this build"
+		case s.Modified:
+			note = "modified after it was applied"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, applied, note)
+	}
+	return w.Flush()
+}
+
+func parseInt(v string) int {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("%q is not a number", v)
+	}
+	return n
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate [config flags] up | down [n] | to <version> | status")
+	fmt.Fprintln(os.Stderr, "       migrate create <name> [dir]")
+	os.Exit(2)
+}
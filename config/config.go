@@ -0,0 +1,672 @@
+// Package config loads the application settings from, in increasing priority, built-in
+// defaults, a YAML or TOML file, environment variables and command line flags.
+//
+// Secrets (the database URL and the JWT keys) are never accepted as flags. Besides their
+// environment variable they can be read from a file named by <VAR>_FILE or by <key>_file in
+// the config file, which is how Docker and Kubernetes secrets are mounted.
+package config
+
+import (
+	"adong-be/auth/password"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
+
+// Development defaults for secrets. Production refuses to start while any of them is in use.
+const (
+	devDatabaseURL   = "host=localhost user=adong password=adong123 dbname=adongfood port=5432 sslmode=disable"
+	devAccessSecret  = "your-access-token-secret-key"
+	devRefreshSecret = "your-refresh-token-secret-key"
+)
+
+// minSecretLength is the shortest JWT key accepted in production
+const minSecretLength = 32
+
+// Config - Application settings
+type Config struct {
+	Env       string
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	Mail      MailConfig
+	Migrate   MigrateConfig
+	Inventory InventoryConfig
+	Tracing   TracingConfig
+}
+
+// ServerConfig - HTTP listener and CORS
+type ServerConfig struct {
+	Port string
+	// CORSAllowedOrigins lists the origins allowed to call the API; "*" allows any origin
+	CORSAllowedOrigins []string
+	// ValidateRequests rejects requests that do not match the OpenAPI document before they
+	// reach the handlers
+	ValidateRequests bool
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout bound the phases of a
+	// connection, see http.Server
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is how long requests in flight and background workers get to finish
+	// once the server is told to stop
+	ShutdownTimeout time.Duration
+}
+
+// DatabaseConfig - PostgreSQL connection and pool
+type DatabaseConfig struct {
+	URL             string
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// AuthConfig - JWT keys, token lifetimes, cookies and session limits
+type AuthConfig struct {
+	AccessSecret          string
+	RefreshSecret         string
+	AccessTokenTTL        time.Duration
+	RefreshTokenTTL       time.Duration
+	CookieSecure          bool
+	CookieDomain          string
+	MaxConcurrentSessions int
+	SingleSessionMode     bool
+	EnableRegistration    bool
+	// SessionIdleTimeout signs out sessions without a request for that long; 0 disables it
+	SessionIdleTimeout time.Duration
+	// PasswordResetTTL is how long a password reset link stays valid
+	PasswordResetTTL time.Duration
+	// PasswordResetURL is the frontend page receiving the reset token as ?token=
+	PasswordResetURL string
+	// TOTPIssuer names the account in authenticator apps
+	TOTPIssuer string
+	// LoginLimiter stores the failed login counters: memory for one instance, postgres to
+	// share them between replicas
+	LoginLimiter string
+	// LoginLockoutAfter failures lock a user name for LoginLockoutDuration; a client address
+	// is locked after LoginIPLockoutAfter failures
+	LoginLockoutAfter    int
+	LoginLockoutDuration time.Duration
+	LoginIPLockoutAfter  int
+	// PasswordPolicy is checked by every password a user sets
+	PasswordPolicy password.Policy
+	// PlaintextFallbackUntil is the last day on which legacy plain text passwords are still
+	// accepted; the zero time keeps the window open
+	PlaintextFallbackUntil time.Time
+}
+
+// Login limiters
+const (
+	LoginLimiterMemory   = "memory"
+	LoginLimiterPostgres = "postgres"
+)
+
+// Mail drivers
+const (
+	MailDriverLog  = "log"
+	MailDriverFile = "file"
+	MailDriverSMTP = "smtp"
+)
+
+// MailConfig - Outgoing email. The log and file drivers are for local testing.
+type MailConfig struct {
+	Driver       string
+	From         string
+	FileDir      string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// MigrateConfig - Schema migration at startup
+type MigrateConfig struct {
+	// Auto applies the embedded schema and updates when the server starts
+	Auto bool
+}
+
+// InventoryConfig - Stock posting rules
+type InventoryConfig struct {
+	// AllowNegativeStock lets approved exports take more than a kitchen holds
+	AllowNegativeStock bool
+}
+
+// Trace exporters
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+// TracingConfig - OpenTelemetry spans of requests and queries
+type TracingConfig struct {
+	// Exporter is none, otlp to send spans to a collector, or stdout to print them
+	Exporter string
+	// OTLPEndpoint is the collector URL, e.g. http://localhost:4318; empty uses the
+	// OTEL_EXPORTER_OTLP_* environment variables
+	OTLPEndpoint string
+	// SampleRatio is the share of new traces recorded, between 0 and 1
+	SampleRatio float64
+}
+
+// IsProduction reports whether the production safety checks apply
+func (c *Config) IsProduction() bool {
+	return c.Env == EnvProduction
+}
+
+// AllowsAnyOrigin reports whether CORS accepts every origin
+func (s ServerConfig) AllowsAnyOrigin() bool {
+	for _, o := range s.CORSAllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin reports whether CORS accepts origin
+func (s ServerConfig) AllowsOrigin(origin string) bool {
+	for _, o := range s.CORSAllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// field binds one setting to its config file key, environment variable and flag
+type field struct {
+	key    string // dotted key in the config file, e.g. auth.access_token_ttl
+	env    string
+	def    string
+	secret bool // never a flag, may be read from a file
+	set    func(c *Config, v string) error
+	usage  string
+}
+
+// flagName is the command line name of a field, e.g. -auth-access-token-ttl
+func (f field) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.key)
+}
+
+var fields = []field{
+	{key: "env", env: "APP_ENV", def: EnvDevelopment, usage: "development or production",
+		set: func(c *Config, v string) error { c.Env = strings.ToLower(v); return nil }},
+
+	{key: "server.port", env: "PORT", def: "18080", usage: "HTTP port",
+		set: func(c *Config, v string) error { c.Server.Port = v; return nil }},
+	{key: "server.cors_allowed_origins", env: "CORS_ALLOWED_ORIGINS", def: "*", usage: "comma separated allowed origins, * for any",
+		set: func(c *Config, v string) error { c.Server.CORSAllowedOrigins = splitList(v); return nil }},
+	{key: "server.validate_requests", env: "VALIDATE_REQUESTS", def: "false", usage: "validate requests against the OpenAPI document",
+		set: func(c *Config, v string) error { return setBool(&c.Server.ValidateRequests, v) }},
+	{key: "server.read_header_timeout", env: "READ_HEADER_TIMEOUT", def: "10s", usage: "time to read request headers",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.ReadHeaderTimeout, v) }},
+	{key: "server.read_timeout", env: "READ_TIMEOUT", def: "30s", usage: "time to read a whole request",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.ReadTimeout, v) }},
+	{key: "server.write_timeout", env: "WRITE_TIMEOUT", def: "60s", usage: "time to answer a request",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.WriteTimeout, v) }},
+	{key: "server.idle_timeout", env: "IDLE_TIMEOUT", def: "120s", usage: "time a keep-alive connection may stay idle",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.IdleTimeout, v) }},
+	{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", def: "25s", usage: "time requests and workers get to finish on shutdown",
+		set: func(c *Config, v string) error { return setDuration(&c.Server.ShutdownTimeout, v) }},
+
+	{key: "database.url", env: "DATABASE_URL", def: devDatabaseURL, secret: true,
+		set: func(c *Config, v string) error { c.Database.URL = v; return nil }},
+	{key: "database.max_open_conns", env: "DB_MAX_OPEN_CONNS", def: "25", usage: "maximum open connections",
+		set: func(c *Config, v string) error { return setInt(&c.Database.MaxOpenConns, v) }},
+	{key: "database.max_idle_conns", env: "DB_MAX_IDLE_CONNS", def: "5", usage: "maximum idle connections",
+		set: func(c *Config, v string) error { return setInt(&c.Database.MaxIdleConns, v) }},
+	{key: "database.conn_max_lifetime", env: "DB_CONN_MAX_LIFETIME", def: "30m", usage: "maximum connection lifetime",
+		set: func(c *Config, v string) error { return setDuration(&c.Database.ConnMaxLifetime, v) }},
+
+	{key: "auth.access_secret", env: "JWT_ACCESS_SECRET", def: devAccessSecret, secret: true,
+		set: func(c *Config, v string) error { c.Auth.AccessSecret = v; return nil }},
+	{key: "auth.refresh_secret", env: "JWT_REFRESH_SECRET", def: devRefreshSecret, secret: true,
+		set: func(c *Config, v string) error { c.Auth.RefreshSecret = v; return nil }},
+	{key: "auth.access_token_ttl", env: "ACCESS_TOKEN_TTL", def: "24h", usage: "access token lifetime",
+		set: func(c *Config, v string) error { return setDuration(&c.Auth.AccessTokenTTL, v) }},
+	{key: "auth.refresh_token_ttl", env: "REFRESH_TOKEN_TTL", def: "168h", usage: "refresh token lifetime",
+		set: func(c *Config, v string) error { return setDuration(&c.Auth.RefreshTokenTTL, v) }},
+	{key: "auth.cookie_secure", env: "COOKIE_SECURE", def: "false", usage: "send auth cookies over HTTPS only",
+		set: func(c *Config, v string) error { return setBool(&c.Auth.CookieSecure, v) }},
+	{key: "auth.cookie_domain", env: "COOKIE_DOMAIN", def: "", usage: "domain of the auth cookies",
+		set: func(c *Config, v string) error { c.Auth.CookieDomain = v; return nil }},
+	{key: "auth.max_concurrent_sessions", env: "MAX_CONCURRENT_SESSIONS", def: "5", usage: "sessions allowed per user",
+		set: func(c *Config, v string) error { return setInt(&c.Auth.MaxConcurrentSessions, v) }},
+	{key: "auth.single_session_mode", env: "SINGLE_SESSION_MODE", def: "false", usage: "end other sessions on login",
+		set: func(c *Config, v string) error { return setBool(&c.Auth.SingleSessionMode, v) }},
+	{key: "auth.enable_registration", env: "ENABLE_REGISTRATION", def: "true", usage: "allow self registration",
+		set: func(c *Config, v string) error { return setBool(&c.Auth.EnableRegistration, v) }},
+	{key: "auth.session_idle_timeout", env: "SESSION_IDLE_TIMEOUT", def: "30m", usage: "sign out sessions idle for this long, 0 to disable",
+		set: func(c *Config, v string) error { return setDuration(&c.Auth.SessionIdleTimeout, v) }},
+
+	{key: "auth.password_reset_ttl", env: "PASSWORD_RESET_TTL", def: "1h", usage: "password reset link lifetime",
+		set: func(c *Config, v string) error { return setDuration(&c.Auth.PasswordResetTTL, v) }},
+	{key: "auth.password_reset_url", env: "PASSWORD_RESET_URL", def: "http://localhost:3000/reset-password", usage: "frontend password reset page",
+		set: func(c *Config, v string) error { c.Auth.PasswordResetURL = v; return nil }},
+	{key: "auth.totp_issuer", env: "TOTP_ISSUER", def: "Adong", usage: "issuer shown in authenticator apps",
+		set: func(c *Config, v string) error { c.Auth.TOTPIssuer = v; return nil }},
+	{key: "auth.login_limiter", env: "LOGIN_LIMITER", def: LoginLimiterMemory, usage: "memory or postgres (shared between replicas)",
+		set: func(c *Config, v string) error { c.Auth.LoginLimiter = v; return nil }},
+	{key: "auth.login_lockout_after", env: "LOGIN_LOCKOUT_AFTER", def: "10", usage: "failed logins that lock a user name",
+		set: func(c *Config, v string) error { return setInt(&c.Auth.LoginLockoutAfter, v) }},
+	{key: "auth.login_lockout_duration", env: "LOGIN_LOCKOUT_DURATION", def: "15m", usage: "how long a lockout lasts",
+		set: func(c *Config, v string) error { return setDuration(&c.Auth.LoginLockoutDuration, v) }},
+	{key: "auth.login_ip_lockout_after", env: "LOGIN_IP_LOCKOUT_AFTER", def: "100", usage: "failed logins that lock a client address",
+		set: func(c *Config, v string) error { return setInt(&c.Auth.LoginIPLockoutAfter, v) }},
+	{key: "auth.password_min_length", env: "PASSWORD_MIN_LENGTH", def: "8", usage: "minimum password length",
+		set: func(c *Config, v string) error { return setInt(&c.Auth.PasswordPolicy.MinLength, v) }},
+	{key: "auth.password_require_upper", env: "PASSWORD_REQUIRE_UPPER", def: "true", usage: "passwords need an uppercase letter",
+		set: func(c *Config, v string) error { return setBool(&c.Auth.PasswordPolicy.RequireUpper, v) }},
+	{key: "auth.password_require_lower", env: "PASSWORD_REQUIRE_LOWER", def: "true", usage: "passwords need a lowercase letter",
+		set: func(c *Config, v string) error { return setBool(&c.Auth.PasswordPolicy.RequireLower, v) }},
+	{key: "auth.password_require_digit", env: "PASSWORD_REQUIRE_DIGIT", def: "true", usage: "passwords need a digit",
+		set: func(c *Config, v string) error { return setBool(&c.Auth.PasswordPolicy.RequireDigit, v) }},
+	{key: "auth.password_require_symbol", env: "PASSWORD_REQUIRE_SYMBOL", def: "false", usage: "passwords need a symbol",
+		set: func(c *Config, v string) error { return setBool(&c.Auth.PasswordPolicy.RequireSymbol, v) }},
+	{key: "auth.plaintext_password_fallback_until", env: "PLAINTEXT_PASSWORD_FALLBACK_UNTIL", def: "", usage: "last day (YYYY-MM-DD) legacy plain text passwords are accepted, empty for no end",
+		set: func(c *Config, v string) error { return setDate(&c.Auth.PlaintextFallbackUntil, v) }},
+
+	{key: "mail.driver", env: "MAIL_DRIVER", def: MailDriverLog, usage: "log, file or smtp",
+		set: func(c *Config, v string) error { c.Mail.Driver = strings.ToLower(v); return nil }},
+	{key: "mail.from", env: "MAIL_FROM", def: "no-reply@adongfood.local", usage: "sender address",
+		set: func(c *Config, v string) error { c.Mail.From = v; return nil }},
+	{key: "mail.file_dir", env: "MAIL_FILE_DIR", def: "mail", usage: "directory of the file mail driver",
+		set: func(c *Config, v string) error { c.Mail.FileDir = v; return nil }},
+	{key: "mail.smtp_host", env: "SMTP_HOST", def: "", usage: "SMTP server host",
+		set: func(c *Config, v string) error { c.Mail.SMTPHost = v; return nil }},
+	{key: "mail.smtp_port", env: "SMTP_PORT", def: "587", usage: "SMTP server port",
+		set: func(c *Config, v string) error { return setInt(&c.Mail.SMTPPort, v) }},
+	{key: "mail.smtp_username", env: "SMTP_USERNAME", def: "", usage: "SMTP user",
+		set: func(c *Config, v string) error { c.Mail.SMTPUsername = v; return nil }},
+	{key: "mail.smtp_password", env: "SMTP_PASSWORD", def: "", secret: true,
+		set: func(c *Config, v string) error { c.Mail.SMTPPassword = v; return nil }},
+
+	{key: "migrate.auto", env: "AUTO_MIGRATE", def: "true", usage: "apply schema migrations at startup",
+		set: func(c *Config, v string) error { return setBool(&c.Migrate.Auto, v) }},
+
+	{key: "inventory.allow_negative_stock", env: "ALLOW_NEGATIVE_STOCK", def: "false", usage: "let exports drive stock below zero",
+		set: func(c *Config, v string) error { return setBool(&c.Inventory.AllowNegativeStock, v) }},
+
+	{key: "tracing.exporter", env: "TRACING_EXPORTER", def: TracingExporterNone, usage: "none, otlp or stdout",
+		set: func(c *Config, v string) error { c.Tracing.Exporter = strings.ToLower(v); return nil }},
+	{key: "tracing.otlp_endpoint", env: "TRACING_OTLP_ENDPOINT", def: "", usage: "OTLP/HTTP collector URL",
+		set: func(c *Config, v string) error { c.Tracing.OTLPEndpoint = v; return nil }},
+	{key: "tracing.sample_ratio", env: "TRACING_SAMPLE_RATIO", def: "1", usage: "share of traces recorded, 0 to 1",
+		set: func(c *Config, v string) error { return setFloat(&c.Tracing.SampleRatio, v) }},
+}
+
+// Default returns the development defaults
+func Default() *Config {
+	c := &Config{}
+	for _, f := range fields {
+		if err := f.set(c, f.def); err != nil {
+			panic(fmt.Sprintf("config: invalid default for %s: %v", f.key, err))
+		}
+	}
+	return c
+}
+
+// Load reads the configuration for the process with command line args (without the program
+// name). The config file is named by -config or CONFIG_FILE. The result is validated.
+func Load(args []string) (*Config, error) {
+	c, _, err := LoadCommand(args)
+	return c, err
+}
+
+// LoadCommand is Load for command line tools taking positional arguments after the flags;
+// it also returns those arguments
+func LoadCommand(args []string) (*Config, []string, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet("adong-be", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	flagValues := make(map[string]*string)
+	for _, f := range fields {
+		if !f.secret {
+			flagValues[f.key] = fs.String(f.flagName(), "", f.usage+" ("+f.env+")")
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configFile != "" {
+		values, err := readFile(*configFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := c.apply(values, "config file"); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	envValues := make(map[string]string)
+	for _, f := range fields {
+		if v, ok := os.LookupEnv(f.env); ok {
+			envValues[f.key] = v
+		}
+		if f.secret {
+			if path := os.Getenv(f.env + "_FILE"); path != "" {
+				v, err := readSecret(path)
+				if err != nil {
+					return nil, nil, fmt.Errorf("config: %s_FILE: %w", f.env, err)
+				}
+				envValues[f.key] = v
+			}
+		}
+	}
+	if err := c.apply(envValues, "environment"); err != nil {
+		return nil, nil, err
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+	flagSet := make(map[string]string)
+	for _, f := range fields {
+		if p, ok := flagValues[f.key]; ok && set[f.flagName()] {
+			flagSet[f.key] = *p
+		}
+	}
+	if err := c.apply(flagSet, "flags"); err != nil {
+		return nil, nil, err
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+func (c *Config) apply(values map[string]string, source string) error {
+	for _, f := range fields {
+		v, ok := values[f.key]
+		if !ok {
+			continue
+		}
+		if err := f.set(c, v); err != nil {
+			return fmt.Errorf("config: %s from %s: %w", f.key, source, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks every setting and, in production, refuses the development secrets
+func (c *Config) Validate() error {
+	var errs []error
+	if c.Env != EnvDevelopment && c.Env != EnvProduction {
+		errs = append(errs, fmt.Errorf("env must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Env))
+	}
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port %q is not a valid port", c.Server.Port))
+	}
+	if c.Server.ReadHeaderTimeout <= 0 || c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		errs = append(errs, errors.New("server read, write and idle timeouts must be positive"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be positive"))
+	}
+	if len(c.Server.CORSAllowedOrigins) == 0 {
+		errs = append(errs, errors.New("server.cors_allowed_origins must not be empty"))
+	}
+	for _, o := range c.Server.CORSAllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("server.cors_allowed_origins: %q is not an origin", o))
+		}
+	}
+	if c.Database.URL == "" {
+		errs = append(errs, errors.New("database.url is required"))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database connection limits must not be negative"))
+	}
+	if c.Auth.AccessSecret == "" || c.Auth.RefreshSecret == "" {
+		errs = append(errs, errors.New("auth.access_secret and auth.refresh_secret are required"))
+	} else if c.Auth.AccessSecret == c.Auth.RefreshSecret {
+		errs = append(errs, errors.New("auth.access_secret and auth.refresh_secret must differ"))
+	}
+	if c.Auth.AccessTokenTTL <= 0 {
+		errs = append(errs, errors.New("auth.access_token_ttl must be positive"))
+	}
+	if c.Auth.RefreshTokenTTL < c.Auth.AccessTokenTTL {
+		errs = append(errs, errors.New("auth.refresh_token_ttl must not be shorter than auth.access_token_ttl"))
+	}
+	if c.Auth.MaxConcurrentSessions < 0 {
+		errs = append(errs, errors.New("auth.max_concurrent_sessions must not be negative"))
+	}
+	if c.Auth.SessionIdleTimeout != 0 && c.Auth.SessionIdleTimeout < time.Minute {
+		errs = append(errs, errors.New("auth.session_idle_timeout must be 0 or at least 1m"))
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("auth.password_reset_ttl must be positive"))
+	}
+	if u, err := url.Parse(c.Auth.PasswordResetURL); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("auth.password_reset_url %q is not an absolute URL", c.Auth.PasswordResetURL))
+	}
+	if c.Auth.TOTPIssuer == "" || strings.Contains(c.Auth.TOTPIssuer, ":") {
+		errs = append(errs, errors.New("auth.totp_issuer must be set and must not contain ':'"))
+	}
+	if c.Auth.LoginLimiter != LoginLimiterMemory && c.Auth.LoginLimiter != LoginLimiterPostgres {
+		errs = append(errs, fmt.Errorf("auth.login_limiter must be %s or %s, got %q", LoginLimiterMemory, LoginLimiterPostgres, c.Auth.LoginLimiter))
+	}
+	if c.Auth.LoginLockoutAfter < 1 || c.Auth.LoginIPLockoutAfter < 1 {
+		errs = append(errs, errors.New("auth.login_lockout_after and auth.login_ip_lockout_after must be positive"))
+	}
+	if c.Auth.LoginLockoutDuration <= 0 {
+		errs = append(errs, errors.New("auth.login_lockout_duration must be positive"))
+	}
+	// bcrypt ignores everything after 72 bytes, the policy refuses longer passwords
+	if c.Auth.PasswordPolicy.MinLength < 1 || c.Auth.PasswordPolicy.MinLength > 72 {
+		errs = append(errs, errors.New("auth.password_min_length must be between 1 and 72"))
+	}
+	switch c.Mail.Driver {
+	case MailDriverLog:
+	case MailDriverFile:
+		if c.Mail.FileDir == "" {
+			errs = append(errs, errors.New("mail.file_dir is required for the file driver"))
+		}
+	case MailDriverSMTP:
+		if c.Mail.SMTPHost == "" || c.Mail.SMTPPort < 1 || c.Mail.SMTPPort > 65535 {
+			errs = append(errs, errors.New("mail.smtp_host and a valid mail.smtp_port are required for the smtp driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.driver must be %q, %q or %q, got %q", MailDriverLog, MailDriverFile, MailDriverSMTP, c.Mail.Driver))
+	}
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		if c.Tracing.OTLPEndpoint != "" {
+			if u, err := url.Parse(c.Tracing.OTLPEndpoint); err != nil || u.Scheme == "" || u.Host == "" {
+				errs = append(errs, fmt.Errorf("tracing.otlp_endpoint %q is not an absolute URL", c.Tracing.OTLPEndpoint))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter must be %q, %q or %q, got %q", TracingExporterNone, TracingExporterOTLP, TracingExporterStdout, c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio must be between 0 and 1"))
+	}
+	if c.Mail.From == "" {
+		errs = append(errs, errors.New("mail.from is required"))
+	}
+
+	if c.IsProduction() {
+		if c.Database.URL == devDatabaseURL {
+			errs = append(errs, errors.New("production: database.url is the development default"))
+		}
+		if c.Auth.AccessSecret == devAccessSecret || c.Auth.RefreshSecret == devRefreshSecret {
+			errs = append(errs, errors.New("production: JWT secrets are the development defaults"))
+		}
+		if len(c.Auth.AccessSecret) < minSecretLength || len(c.Auth.RefreshSecret) < minSecretLength {
+			errs = append(errs, fmt.Errorf("production: JWT secrets must be at least %d characters", minSecretLength))
+		}
+		if !c.Auth.CookieSecure {
+			errs = append(errs, errors.New("production: auth.cookie_secure must be enabled"))
+		}
+		// The other drivers write password reset links where operators can read them
+		if c.Mail.Driver != MailDriverSMTP {
+			errs = append(errs, errors.New("production: mail.driver must be smtp"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// readFile parses a YAML (.yaml, .yml) or TOML (.toml) file into dotted keys.
+// A <key>_file entry for a secret is replaced by the content of the named file.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+
+	tree := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config: unsupported config file type %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config: parse %s: %w", path, err)
+	}
+
+	flat := make(map[string]string)
+	flatten("", tree, flat)
+
+	known := make(map[string]field, len(fields))
+	for _, f := range fields {
+		known[f.key] = f
+	}
+	values := make(map[string]string, len(flat))
+	for key, v := range flat {
+		if _, ok := known[key]; ok {
+			values[key] = v
+			continue
+		}
+		if f, ok := known[strings.TrimSuffix(key, "_file")]; ok && f.secret && strings.HasSuffix(key, "_file") {
+			secret, err := readSecret(v)
+			if err != nil {
+				return nil, fmt.Errorf("config: %s: %w", key, err)
+			}
+			values[f.key] = secret
+			continue
+		}
+		return nil, fmt.Errorf("config: unknown key %q in %s", key, path)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, out map[string]string) {
+	for k, v := range tree {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case map[string]any:
+			flatten(key, val, out)
+		case []any:
+			items := make([]string, len(val))
+			for i, item := range val {
+				items[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(items, ",")
+		default:
+			out[key] = fmt.Sprint(val)
+		}
+	}
+}
+
+// readSecret returns the content of a mounted secret file without the trailing newline
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%q is not an integer", v)
+	}
+	*dst = n
+	return nil
+}
+
+func setBool(dst *bool, v string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", v)
+	}
+	*dst = b
+	return nil
+}
+
+func setFloat(dst *float64, v string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", v)
+	}
+	*dst = f
+	return nil
+}
+
+// setDate parses a YYYY-MM-DD day in the local time zone, empty is the zero time
+func setDate(dst *time.Time, v string) error {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		*dst = time.Time{}
+		return nil
+	}
+	d, err := time.ParseInLocation(time.DateOnly, v, time.Local)
+	if err != nil {
+		return fmt.Errorf("%q is not a date (YYYY-MM-DD)", v)
+	}
+	*dst = d
+	return nil
+}
+
+func setDuration(dst *time.Duration, v string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return fmt.Errorf("%q is not a duration", v)
+	}
+	*dst = d
+	return nil
+}
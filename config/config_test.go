@@ -0,0 +1,159 @@
+package config
+
+import (
+	"adong-be/auth/password"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestDefaultIsValidForDevelopment(t *testing.T) {
+	c := Default()
+	assert.NoError(t, c.Validate())
+	assert.Equal(t, EnvDevelopment, c.Env)
+	assert.Equal(t, "18080", c.Server.Port)
+	assert.Equal(t, 24*time.Hour, c.Auth.AccessTokenTTL)
+	assert.True(t, c.Server.AllowsAnyOrigin())
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "app.yaml", `
+server:
+  port: 9000
+  cors_allowed_origins: [https://a.example.com, https://b.example.com]
+auth:
+  access_token_ttl: 1h
+  max_concurrent_sessions: 2
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("ACCESS_TOKEN_TTL", "2h")
+	t.Setenv("MAX_CONCURRENT_SESSIONS", "3")
+
+	c, err := Load([]string{"-auth-max-concurrent-sessions=4"})
+	require.NoError(t, err)
+	assert.Equal(t, "9000", c.Server.Port, "file overrides default")
+	assert.Equal(t, []string{"https://a.example.com", "https://b.example.com"}, c.Server.CORSAllowedOrigins)
+	assert.Equal(t, 2*time.Hour, c.Auth.AccessTokenTTL, "env overrides file")
+	assert.Equal(t, 4, c.Auth.MaxConcurrentSessions, "flag overrides env")
+}
+
+func TestLoadTOMLAndSecretFiles(t *testing.T) {
+	access := writeFile(t, "access", strings.Repeat("a", 40)+"\n")
+	refresh := writeFile(t, "refresh", strings.Repeat("r", 40)+"\n")
+	dsn := writeFile(t, "dsn", "host=db user=app password=s3cret dbname=app\n")
+	path := writeFile(t, "app.toml", `
+env = "production"
+
+[server]
+cors_allowed_origins = ["https://app.example.com"]
+
+[auth]
+access_secret_file = "`+access+`"
+cookie_secure = true
+
+[mail]
+driver = "smtp"
+smtp_host = "smtp.example.com"
+`)
+	t.Setenv("JWT_REFRESH_SECRET_FILE", refresh)
+	t.Setenv("DATABASE_URL_FILE", dsn)
+
+	c, err := Load([]string{"-config", path})
+	require.NoError(t, err)
+	assert.True(t, c.IsProduction())
+	assert.Equal(t, strings.Repeat("a", 40), c.Auth.AccessSecret)
+	assert.Equal(t, strings.Repeat("r", 40), c.Auth.RefreshSecret)
+	assert.Equal(t, "host=db user=app password=s3cret dbname=app", c.Database.URL)
+}
+
+func TestProductionRefusesDefaultSecrets(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("COOKIE_SECURE", "true")
+
+	_, err := Load(nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT secrets are the development defaults")
+	assert.Contains(t, err.Error(), "database.url is the development default")
+}
+
+func TestSecretsAreNotFlags(t *testing.T) {
+	_, err := Load([]string{"-auth-access-secret=x"})
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		want   string
+	}{
+		{"bad port", func(c *Config) { c.Server.Port = "http" }, "server.port"},
+		{"no shutdown timeout", func(c *Config) { c.Server.ShutdownTimeout = 0 }, "server.shutdown_timeout"},
+		{"same secrets", func(c *Config) { c.Auth.RefreshSecret = c.Auth.AccessSecret }, "must differ"},
+		{"refresh shorter than access", func(c *Config) { c.Auth.RefreshTokenTTL = time.Minute }, "refresh_token_ttl"},
+		{"bad origin", func(c *Config) { c.Server.CORSAllowedOrigins = []string{"example.com"} }, "is not an origin"},
+		{"unknown env", func(c *Config) { c.Env = "staging" }, "env must be"},
+		{"unknown mail driver", func(c *Config) { c.Mail.Driver = "sendmail" }, "mail.driver"},
+		{"totp issuer with colon", func(c *Config) { c.Auth.TOTPIssuer = "Adong:Food" }, "auth.totp_issuer"},
+		{"unknown login limiter", func(c *Config) { c.Auth.LoginLimiter = "redis" }, "auth.login_limiter"},
+		{"session idle timeout too short", func(c *Config) { c.Auth.SessionIdleTimeout = time.Second }, "auth.session_idle_timeout"},
+		{"smtp without host", func(c *Config) { c.Mail.Driver = MailDriverSMTP }, "mail.smtp_host"},
+		{"unknown trace exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, "tracing.exporter"},
+		{"relative otlp endpoint", func(c *Config) {
+			c.Tracing.Exporter, c.Tracing.OTLPEndpoint = TracingExporterOTLP, "localhost:4318"
+		}, "tracing.otlp_endpoint"},
+		{"sample ratio above one", func(c *Config) { c.Tracing.SampleRatio = 2 }, "tracing.sample_ratio"},
+		{"password min length zero", func(c *Config) { c.Auth.PasswordPolicy.MinLength = 0 }, "auth.password_min_length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := Default()
+			tt.modify(c)
+			err := c.Validate()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordSettings(t *testing.T) {
+	c := Default()
+	assert.Equal(t, password.DefaultPolicy(), c.Auth.PasswordPolicy)
+	assert.True(t, c.Auth.PlaintextFallbackUntil.IsZero(), "the plain text window stays open by default")
+
+	t.Setenv("PASSWORD_MIN_LENGTH", "12")
+	t.Setenv("PASSWORD_REQUIRE_UPPER", "false")
+	t.Setenv("PASSWORD_REQUIRE_SYMBOL", "true")
+	t.Setenv("PLAINTEXT_PASSWORD_FALLBACK_UNTIL", "2026-03-31")
+	c, err := Load(nil)
+	require.NoError(t, err)
+	assert.Equal(t, password.Policy{MinLength: 12, RequireLower: true, RequireDigit: true, RequireSymbol: true}, c.Auth.PasswordPolicy)
+	assert.Equal(t, time.Date(2026, 3, 31, 0, 0, 0, 0, time.Local), c.Auth.PlaintextFallbackUntil)
+
+	t.Setenv("PLAINTEXT_PASSWORD_FALLBACK_UNTIL", "31/03/2026")
+	_, err = Load(nil)
+	if assert.Error(t, err, "a bad deadline stops the startup") {
+		assert.Contains(t, err.Error(), "auth.plaintext_password_fallback_until")
+	}
+}
+
+func TestUnknownFileKey(t *testing.T) {
+	path := writeFile(t, "app.yaml", "server:\n  prot: 9000\n")
+	_, err := Load([]string{"-config", path})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "server.prot")
+	}
+}
@@ -0,0 +1,105 @@
+// Package delivery evaluates supplier delivery calendars: whether a supplier can
+// deliver on a date and the latest time the order must be placed.
+package delivery
+
+import (
+	"adong-be/models"
+	"fmt"
+	"time"
+)
+
+// Status is the delivery answer for one supplier and delivery date
+type Status struct {
+	CanDeliver bool       `json:"canDeliver"`
+	OrderBy    *time.Time `json:"orderBy,omitempty"` // nil when the supplier has no schedule
+	ScheduleID int        `json:"scheduleId,omitempty"`
+	Reason     string     `json:"reason"`
+}
+
+// ParseCutoff parses an HH:MM cut-off time
+func ParseCutoff(s string) (hour, minute int, err error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid cut-off time %q, expected HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// Applicable returns the schedules that apply to kitchenID: the kitchen's own rows if
+// the supplier has any for it, otherwise the supplier's general rows. Inactive rows are skipped.
+func Applicable(schedules []models.SupplierDeliverySchedule, kitchenID string) []models.SupplierDeliverySchedule {
+	var own, general []models.SupplierDeliverySchedule
+	for _, s := range schedules {
+		if s.Active != nil && !*s.Active {
+			continue
+		}
+		switch {
+		case s.KitchenID == nil:
+			general = append(general, s)
+		case *s.KitchenID == kitchenID:
+			own = append(own, s)
+		}
+	}
+	if len(own) > 0 {
+		return own
+	}
+	return general
+}
+
+// OrderDeadline is the latest time to order for delivery on deliveryDate under schedule s.
+// The cut-off is interpreted in deliveryDate's location.
+func OrderDeadline(s models.SupplierDeliverySchedule, deliveryDate time.Time) (time.Time, error) {
+	h, m, err := ParseCutoff(s.CutoffTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	y, mo, d := deliveryDate.Date()
+	return time.Date(y, mo, d-s.CutoffDaysBefore, h, m, 0, 0, deliveryDate.Location()), nil
+}
+
+// Check reports whether a supplier with the given schedules can deliver to kitchenID
+// on deliveryDate when ordering at now. A supplier without any applicable schedule
+// is assumed to deliver every day.
+func Check(schedules []models.SupplierDeliverySchedule, kitchenID string, deliveryDate, now time.Time) Status {
+	applicable := Applicable(schedules, kitchenID)
+	if len(applicable) == 0 {
+		return Status{CanDeliver: true, Reason: "No delivery schedule, assumed to deliver every day"}
+	}
+
+	var best *time.Time
+	bestID := 0
+	for _, s := range applicable {
+		if time.Weekday(s.Weekday) != deliveryDate.Weekday() {
+			continue
+		}
+		deadline, err := OrderDeadline(s, deliveryDate)
+		if err != nil {
+			continue
+		}
+		if best == nil || deadline.After(*best) {
+			best = &deadline
+			bestID = s.ScheduleID
+		}
+	}
+
+	if best == nil {
+		return Status{
+			CanDeliver: false,
+			Reason:     fmt.Sprintf("Does not deliver on %s", deliveryDate.Weekday()),
+		}
+	}
+	if now.After(*best) {
+		return Status{
+			CanDeliver: false,
+			OrderBy:    best,
+			ScheduleID: bestID,
+			Reason:     fmt.Sprintf("Order cut-off passed at %s", best.Format("2006-01-02 15:04")),
+		}
+	}
+	return Status{
+		CanDeliver: true,
+		OrderBy:    best,
+		ScheduleID: bestID,
+		Reason:     fmt.Sprintf("Order by %s", best.Format("2006-01-02 15:04")),
+	}
+}
@@ -0,0 +1,58 @@
+package delivery
+
+import (
+	"adong-be/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func strp(s string) *string { return &s }
+
+func TestCheck(t *testing.T) {
+	// 2025-06-16 is a Monday
+	monday := time.Date(2025, 6, 16, 0, 0, 0, 0, time.UTC)
+	schedules := []models.SupplierDeliverySchedule{
+		{ScheduleID: 1, Weekday: int(time.Monday), CutoffDaysBefore: 1, CutoffTime: "15:00"},
+		{ScheduleID: 2, Weekday: int(time.Wednesday), CutoffDaysBefore: 1, CutoffTime: "15:00"},
+		{ScheduleID: 3, KitchenID: strp("K002"), Weekday: int(time.Tuesday), CutoffDaysBefore: 2, CutoffTime: "10:00"},
+	}
+
+	tests := []struct {
+		name        string
+		schedules   []models.SupplierDeliverySchedule
+		kitchen     string
+		date        time.Time
+		now         time.Time
+		wantDeliver bool
+		wantOrderBy string
+	}{
+		{"no schedule delivers every day", nil, "K001", monday, monday, true, ""},
+		{"before cut-off", schedules, "K001", monday, monday.Add(-10 * time.Hour), true, "2025-06-15 15:00"},
+		{"after cut-off", schedules, "K001", monday, monday.Add(-8 * time.Hour), false, "2025-06-15 15:00"},
+		{"weekday not served", schedules, "K001", monday.AddDate(0, 0, 1), monday, false, ""},
+		{"kitchen schedule replaces general", schedules, "K002", monday, monday.AddDate(0, 0, -3), false, ""},
+		{"kitchen schedule", schedules, "K002", monday.AddDate(0, 0, 1), monday.AddDate(0, 0, -3), true, "2025-06-15 10:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := Check(tt.schedules, tt.kitchen, tt.date, tt.now)
+			assert.Equal(t, tt.wantDeliver, st.CanDeliver, st.Reason)
+			if tt.wantOrderBy == "" {
+				assert.Nil(t, st.OrderBy)
+			} else if assert.NotNil(t, st.OrderBy) {
+				assert.Equal(t, tt.wantOrderBy, st.OrderBy.Format("2006-01-02 15:04"))
+			}
+		})
+	}
+}
+
+func TestParseCutoff(t *testing.T) {
+	h, m, err := ParseCutoff("07:30")
+	assert.NoError(t, err)
+	assert.Equal(t, 7, h)
+	assert.Equal(t, 30, m)
+	_, _, err = ParseCutoff("25:00")
+	assert.Error(t, err)
+}
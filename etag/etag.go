@@ -0,0 +1,75 @@
+// Package etag turns record versions into HTTP entity tags for optimistic concurrency.
+// Every mutable record carries a version, incremented by each change and sent as the ETag
+// of the record, e.g. "3". A change sends the ETag it last saw back in If-Match and is
+// refused when the record has changed since, so concurrent edits are never silently lost.
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrMissing is returned by Parse for an empty If-Match header
+	ErrMissing = errors.New("If-Match header missing")
+	// ErrInvalid is returned by Parse for an If-Match header that holds no version of ours
+	ErrInvalid = errors.New("If-Match header invalid")
+)
+
+// Format returns the entity tag of version
+func Format(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// Condition is an If-Match header: the versions a change may apply to
+type Condition struct {
+	// Any is set by If-Match: *, which matches every version
+	Any      bool
+	Versions []int
+}
+
+// Parse reads an If-Match header, a list of entity tags or *. Weak tags never match, as
+// If-Match compares strongly.
+func Parse(header string) (Condition, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return Condition{}, ErrMissing
+	}
+	if header == "*" {
+		return Condition{Any: true}, nil
+	}
+	var c Condition
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		unquoted, ok := strings.CutPrefix(tag, `"`)
+		if ok {
+			unquoted, ok = strings.CutSuffix(unquoted, `"`)
+		}
+		if !ok {
+			return Condition{}, ErrInvalid
+		}
+		version, err := strconv.Atoi(unquoted)
+		if err != nil {
+			return Condition{}, ErrInvalid
+		}
+		c.Versions = append(c.Versions, version)
+	}
+	return c, nil
+}
+
+// Matches reports whether a record at version may be changed
+func (c Condition) Matches(version int) bool {
+	if c.Any {
+		return true
+	}
+	for _, v := range c.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package etag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    Condition
+		wantErr error
+	}{
+		{`"3"`, Condition{Versions: []int{3}}, nil},
+		{` "3", "4" `, Condition{Versions: []int{3, 4}}, nil},
+		{`*`, Condition{Any: true}, nil},
+		{`W/"3"`, Condition{}, nil},
+		{``, Condition{}, ErrMissing},
+		{`3`, Condition{}, ErrInvalid},
+		{`"abc"`, Condition{}, ErrInvalid},
+		{`"3`, Condition{}, ErrInvalid},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.header)
+		assert.ErrorIs(t, err, tt.wantErr, tt.header)
+		assert.Equal(t, tt.want, got, tt.header)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	c, _ := Parse(Format(3))
+	assert.True(t, c.Matches(3))
+	assert.False(t, c.Matches(4))
+	assert.True(t, Condition{Any: true}.Matches(4))
+	weak, _ := Parse(`W/"3"`)
+	assert.False(t, weak.Matches(3), "If-Match compares strongly")
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuditLogHandler serves the audit log written by the audit package
+type AuditLogHandler struct {
+	DB *gorm.DB
+}
+
+func NewAuditLogHandler(db *gorm.DB) *AuditLogHandler {
+	return &AuditLogHandler{DB: db}
+}
+
+// GetAuditLogs lists audit entries, newest first.
+// Filters: actor_user_id, kitchen_id, entity_type, entity_id, action, request_id,
+// from_date and to_date (YYYY-MM-DD, inclusive). Callers bound to kitchens only see
+// entries of those kitchens.
+func (h *AuditLogHandler) GetAuditLogs(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetAuditLogs called", "user_id", uid)
+
+	var params models.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	params = models.GetPaginationParams(params.Page, params.PageSize, params.Search, params.SortBy, params.SortDir)
+	// The log grows without bound, so it is always paginated
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 50
+	}
+
+	base, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.AuditLog{}), "kitchen_id", c.Query("kitchen_id"))
+	if !ok {
+		return
+	}
+	for param, column := range map[string]string{
+		"actor_user_id": "actor_user_id",
+		"entity_type":   "entity_type",
+		"entity_id":     "entity_id",
+		"action":        "action",
+		"request_id":    "request_id",
+	} {
+		if v := c.Query(param); v != "" {
+			base = base.Where(column+" = ?", v)
+		}
+	}
+	if v := c.Query("from_date"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			apperr.Respond(c, apperr.Invalid("from_date", "date", "YYYY-MM-DD"))
+			return
+		}
+		base = base.Where("created_date >= ?", from)
+	}
+	if v := c.Query("to_date"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			apperr.Respond(c, apperr.Invalid("to_date", "date", "YYYY-MM-DD"))
+			return
+		}
+		base = base.Where("created_date < ?", to.AddDate(0, 0, 1))
+	}
+	base = base.Session(&gorm.Session{})
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		logger.From(c).Error("GetAuditLogs count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	if params.SortBy == "" {
+		params.SortBy, params.SortDir = "created_date", "desc"
+	}
+	query := utils.ApplySort(base, params.SortBy, params.SortDir, map[string]string{
+		"created_date":  "created_date",
+		"audit_id":      "audit_id",
+		"entity_type":   "entity_type",
+		"actor_user_id": "actor_user_id",
+	})
+	query = utils.ApplyPagination(query, params.Page, params.PageSize)
+
+	var items []models.AuditLog
+	if err := query.Find(&items).Error; err != nil {
+		logger.From(c).Error("GetAuditLogs query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResourceCollection{
+		Data: items,
+		Meta: models.CalculatePaginationMeta(params.Page, params.PageSize, total),
+	})
+}
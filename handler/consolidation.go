@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/selection"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ConsolidationOptions is the optional body shared by the consolidation endpoints.
+// SupplierTerms overrides the minimum order value / delivery fee stored on master_suppliers.
+type ConsolidationOptions struct {
+	MaxSuppliers  int                                `json:"maxSuppliers" binding:"gte=0"`
+	SupplierTerms map[string]selection.SupplierTerms `json:"supplierTerms"`
+}
+
+// consolidateSuppliers loads the candidates for items and runs the basket optimizer,
+// using the delivery terms stored on each supplier unless overridden in opts.
+// Suppliers that cannot deliver on deliveryDate are left out of the plan.
+func consolidateSuppliers(db *gorm.DB, kitchenID string, items []selection.Item, deliveryDate *time.Time, opts ConsolidationOptions) (selection.ConsolidationResult, error) {
+	candidates, err := loadSelectionCandidates(db, kitchenID, items, candidateOptions{
+		DeliveryDate:         deliveryDate,
+		ExcludeUndeliverable: true,
+	})
+	if err != nil {
+		return selection.ConsolidationResult{}, err
+	}
+
+	terms := make(map[string]selection.SupplierTerms)
+	for _, cands := range candidates {
+		for _, cand := range cands {
+			if s := cand.Price.Supplier; s != nil {
+				terms[s.SupplierID] = selection.SupplierTerms{
+					MinOrderValue: s.MinOrderValue,
+					DeliveryFee:   s.DeliveryFee,
+				}
+			}
+		}
+	}
+	for id, t := range opts.SupplierTerms {
+		terms[id] = t
+	}
+
+	return selection.Consolidate(items, candidates, selection.ConsolidationOptions{
+		MaxSuppliers: opts.MaxSuppliers,
+		Terms:        terms,
+	}), nil
+}
+
+// GetConsolidatedSuppliersForOrder returns the supplier plan with the lowest landed cost
+// (items + delivery fees + minimum order top-ups) for a saved order, compared with
+// buying every ingredient from its cheapest supplier
+func (h *OrderHandler) GetConsolidatedSuppliersForOrder(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	orderID := c.Param("id")
+	logger.From(c).Info("GetConsolidatedSuppliersForOrder called", "order_id", orderID, "user_id", uid)
+
+	var opts ConsolidationOptions
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&opts); err != nil {
+			logger.From(c).Error("GetConsolidatedSuppliersForOrder bind error", "error", err)
+			apperr.Respond(c, apperr.Bind(err))
+			return
+		}
+	}
+
+	var order models.Order
+	if err := h.DB.WithContext(c).First(&order, "order_id = ?", orderID).Error; err != nil {
+		logger.From(c).Error("GetConsolidatedSuppliersForOrder order not found", "order_id", orderID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
+		return
+	}
+	if !authorizeKitchen(c, order.KitchenID) {
+		return
+	}
+
+	items, err := orderSelectionItems(h.DB.WithContext(c), orderID)
+	if err != nil {
+		logger.From(c).Error("GetConsolidatedSuppliersForOrder ingredients query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	deliveryDate, err := parseDeliveryDate(order.OrderDate)
+	if err != nil {
+		logger.From(c).Warn("GetConsolidatedSuppliersForOrder invalid order date, delivery calendars not checked", "order_id", orderID, "order_date", order.OrderDate)
+		deliveryDate = nil
+	}
+
+	result, err := consolidateSuppliers(h.DB.WithContext(c), order.KitchenID, items, deliveryDate, opts)
+	if err != nil {
+		logger.From(c).Error("GetConsolidatedSuppliersForOrder consolidation error", "order_id", orderID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"orderId":   orderID,
+		"kitchenId": order.KitchenID,
+		"plan":      result,
+	})
+}
+
+// ConsolidatedSuppliersRequest is the body of GetConsolidatedSuppliersForIngredients
+type ConsolidatedSuppliersRequest struct {
+	ConsolidationOptions
+	KitchenID    string               `json:"kitchenId" binding:"required"`
+	DeliveryDate string               `json:"deliveryDate"`
+	Ingredients  []IngredientQuantity `json:"ingredients" binding:"required,min=1"`
+}
+
+// GetConsolidatedSuppliersForIngredients is the consolidation counterpart of
+// GetBestSuppliersForIngredients, for orders that haven't been saved yet
+func (h *OrderHandler) GetConsolidatedSuppliersForIngredients(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetConsolidatedSuppliersForIngredients called", "user_id", uid)
+
+	var request ConsolidatedSuppliersRequest
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.From(c).Error("GetConsolidatedSuppliersForIngredients bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	var kitchen models.Kitchen
+	if err := h.DB.WithContext(c).First(&kitchen, "kitchen_id = ?", request.KitchenID).Error; err != nil {
+		logger.From(c).Error("GetConsolidatedSuppliersForIngredients kitchen not found", "kitchen_id", request.KitchenID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeKitchenNotFound))
+		return
+	}
+	if !authorizeKitchen(c, request.KitchenID) {
+		return
+	}
+
+	items := make([]selection.Item, 0, len(request.Ingredients))
+	for _, reqIng := range request.Ingredients {
+		items = append(items, selection.Item{
+			IngredientID: reqIng.IngredientID,
+			Quantity:     reqIng.Quantity,
+			Unit:         reqIng.Unit,
+		})
+	}
+
+	var deliveryDate *time.Time
+	if request.DeliveryDate != "" {
+		date, err := parseDeliveryDate(request.DeliveryDate)
+		if err != nil {
+			apperr.Respond(c, apperr.Invalid("deliveryDate", "date", "YYYY-MM-DD"))
+			return
+		}
+		deliveryDate = date
+	}
+
+	result, err := consolidateSuppliers(h.DB.WithContext(c), request.KitchenID, items, deliveryDate, request.ConsolidationOptions)
+	if err != nil {
+		logger.From(c).Error("GetConsolidatedSuppliersForIngredients consolidation error", "kitchen_id", request.KitchenID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"kitchenId": request.KitchenID,
+		"plan":      result,
+	})
+}
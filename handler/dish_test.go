@@ -1,67 +1,95 @@
 package handler
 
 import (
+	"adong-be/models"
+	"adong-be/repository"
+	"adong-be/service"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestGetDishes_WithPagination(t *testing.T) {
+// dishRouter serves the dish list from twelve dishes kept in memory
+func dishRouter(t *testing.T) *gin.Engine {
+	t.Helper()
 	gin.SetMode(gin.TestMode)
+	var dishes []models.Dish
+	for i := 1; i <= 12; i++ {
+		dishes = append(dishes, models.Dish{DishID: fmt.Sprintf("MA%03d", i), DishName: fmt.Sprintf("Canh chua %d", i)})
+	}
+	dishes[0].DishName = "Gà kho gừng"
+	dishes[1].DishName = "Cơm gà Hải Nam"
+
+	h := NewDishHandler(service.NewRecipes(repository.NewMemoryRecipes(dishes, nil)))
+	router := gin.New()
+	router.GET("/dishes", h.GetDishes)
+	router.GET("/dishes/:id", h.GetDish)
+	return router
+}
 
-	// Setup
-	router := gin.Default()
-	router.GET("/dishes", GetDishes)
-
-	// Test case 1: Default pagination
-	req, _ := http.NewRequest("GET", "/dishes", nil)
+func getCollection(t *testing.T, router *gin.Engine, path string) (map[string]interface{}, []interface{}) {
+	t.Helper()
+	req, _ := http.NewRequest("GET", path, nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
-
-	assert.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
 
 	var response map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &response)
-
-	assert.Contains(t, response, "data")
-	assert.Contains(t, response, "meta")
-
-	meta := response["meta"].(map[string]interface{})
-	assert.Equal(t, float64(1), meta["current_page"])
-	assert.Equal(t, float64(10), meta["page_size"])
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Contains(t, response, "data")
+	require.Contains(t, response, "meta")
+	data, _ := response["data"].([]interface{})
+	return response["meta"].(map[string]interface{}), data
+}
 
-	// Test case 2: Custom pagination
-	req, _ = http.NewRequest("GET", "/dishes?page=2&page_size=5", nil)
-	w = httptest.NewRecorder()
-	router.ServeHTTP(w, req)
+func TestGetDishes_WithPagination(t *testing.T) {
+	router := dishRouter(t)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	// Without paging parameters every dish is returned
+	meta, data := getCollection(t, router, "/dishes")
+	assert.Len(t, data, 12)
+	assert.Equal(t, float64(12), meta["total"])
+	assert.Equal(t, float64(1), meta["last_page"])
 
-	json.Unmarshal(w.Body.Bytes(), &response)
-	meta = response["meta"].(map[string]interface{})
+	meta, data = getCollection(t, router, "/dishes?page=2&per_page=5")
+	assert.Len(t, data, 5)
 	assert.Equal(t, float64(2), meta["current_page"])
-	assert.Equal(t, float64(5), meta["page_size"])
+	assert.Equal(t, float64(5), meta["per_page"])
+	assert.Equal(t, float64(3), meta["last_page"])
+	assert.Equal(t, "MA006", data[0].(map[string]interface{})["dishId"])
+
+	_, data = getCollection(t, router, "/dishes?page=3&per_page=5&sort_by=dish_id&sort_dir=desc")
+	require.Len(t, data, 2)
+	assert.Equal(t, "MA002", data[0].(map[string]interface{})["dishId"])
 }
 
 func TestGetDishes_WithSearch(t *testing.T) {
-	gin.SetMode(gin.TestMode)
+	router := dishRouter(t)
 
-	router := gin.Default()
-	router.GET("/dishes", GetDishes)
+	meta, data := getCollection(t, router, "/dishes?search=gà")
+	assert.Len(t, data, 2)
+	assert.Equal(t, float64(2), meta["total"])
 
-	req, _ := http.NewRequest("GET", "/dishes?search=gà", nil)
-	w := httptest.NewRecorder()
-	router.ServeHTTP(w, req)
+	// Like ILIKE, the search ignores case
+	_, data = getCollection(t, router, "/dishes?search=CANH")
+	assert.Len(t, data, 10)
+}
 
-	assert.Equal(t, http.StatusOK, w.Code)
+func TestGetDish_NotFound(t *testing.T) {
+	req := httptest.NewRequest("GET", "/dishes/MA999", nil)
+	req.Header.Set("Accept-Language", "en")
+	w := httptest.NewRecorder()
+	dishRouter(t).ServeHTTP(w, req)
 
+	require.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
 	var response map[string]interface{}
-	json.Unmarshal(w.Body.Bytes(), &response)
-
-	assert.Contains(t, response, "data")
-	assert.Contains(t, response, "meta")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "DISH_NOT_FOUND", response["code"])
+	assert.Equal(t, "Dish not found", response["error"])
 }
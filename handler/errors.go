@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/auth/password"
+	"adong-be/repository"
+	"adong-be/service"
+	"adong-be/utils"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// serviceError translates the errors of the service and repository layers into API
+// errors. notFound is the code of a missing record.
+func serviceError(err error, notFound apperr.Code) error {
+	var stockErr *service.StockError
+	var roleErr *service.UnknownRoleError
+	var policyErr *password.PolicyError
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return apperr.Wrap(notFound, err)
+	case errors.Is(err, utils.ErrKitchenForbidden):
+		return apperr.Wrap(apperr.CodeKitchenForbidden, err)
+	case errors.Is(err, service.ErrAlreadyApproved):
+		return apperr.Wrap(apperr.CodeDocumentAlreadyApproved, err)
+	case errors.Is(err, service.ErrVersionMismatch):
+		return apperr.Wrap(apperr.CodeVersionMismatch, err)
+	case errors.As(err, &stockErr) && stockErr.Missing:
+		return apperr.Wrap(apperr.CodeStockMissing, err).With("ingredient_id", stockErr.IngredientID)
+	case errors.As(err, &stockErr):
+		return apperr.Wrap(apperr.CodeStockInsufficient, err).
+			With("ingredient_id", stockErr.IngredientID).
+			With("available", stockErr.Available).
+			With("required", stockErr.Required)
+	case errors.As(err, &roleErr):
+		return apperr.Invalid("role", "exists", roleErr.Role)
+	case errors.As(err, &policyErr):
+		return apperr.Wrap(apperr.CodePasswordPolicy, err).With("violations", policyErr.Violations)
+	case errors.Is(err, service.ErrPasswordRequired):
+		return apperr.Invalid("password", "required")
+	case errors.Is(err, repository.ErrResetTokenInvalid):
+		return apperr.Wrap(apperr.CodeResetTokenInvalid, err)
+	case errors.Is(err, service.ErrPasswordIncorrect):
+		return apperr.Wrap(apperr.CodePasswordIncorrect, err)
+	case errors.Is(err, service.ErrSamePassword):
+		return apperr.Invalid("newPassword", "differ", "oldPassword")
+	}
+	return err
+}
+
+// dbError translates the error of a query or repository lookup: a missing record becomes
+// notFound, anything else an internal error
+func dbError(err error, notFound apperr.Code) *apperr.Error {
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, repository.ErrNotFound) {
+		return apperr.Wrap(notFound, err)
+	}
+	return apperr.Internal(err)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/models"
 	"adong-be/utils"
 	"net/http"
@@ -43,7 +44,7 @@ type CreateRequestDetailInput struct {
 func (h *IngredientRequestHandler) GetAllRequests(c *gin.Context) {
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -64,11 +65,14 @@ func (h *IngredientRequestHandler) GetAllRequests(c *gin.Context) {
 	var requests []models.IngredientRequest
 	var total int64
 
-	countQuery := h.DB.Model(&models.IngredientRequest{})
-
-	if kitchenID != "" {
-		countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
+	// Restrict to the kitchens the caller may access
+	base, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.IngredientRequest{}), "kitchen_id", kitchenID)
+	if !ok {
+		return
 	}
+	base = base.Session(&gorm.Session{})
+
+	countQuery := base
 	if orderID != "" {
 		countQuery = countQuery.Where("order_id = ?", orderID)
 	}
@@ -83,15 +87,11 @@ func (h *IngredientRequestHandler) GetAllRequests(c *gin.Context) {
 	}
 
 	if err := countQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi đếm phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	query := h.DB.Model(&models.IngredientRequest{})
-
-	if kitchenID != "" {
-		query = query.Where("kitchen_id = ?", kitchenID)
-	}
+	query := base
 	if orderID != "" {
 		query = query.Where("order_id = ?", orderID)
 	}
@@ -123,7 +123,7 @@ func (h *IngredientRequestHandler) GetAllRequests(c *gin.Context) {
 		Preload("CreatedBy").
 		Preload("ApprovedBy").
 		Find(&requests).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -139,7 +139,7 @@ func (h *IngredientRequestHandler) GetRequestByID(c *gin.Context) {
 	requestID := c.Param("id")
 
 	var request models.IngredientRequest
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Order").
 		Preload("CreatedBy").
 		Preload("ApprovedBy").
@@ -147,14 +147,14 @@ func (h *IngredientRequestHandler) GetRequestByID(c *gin.Context) {
 		Preload("RequestDetails.Supplier").
 		Where("request_id = ?", requestID).
 		First(&request).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu yêu cầu"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin phiếu yêu cầu"})
-		}
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientRequestNotFound))
+		return
+	}
+	if !authorizeKitchen(c, request.KitchenID) {
 		return
 	}
 
+	setETag(c, request.Version)
 	c.JSON(http.StatusOK, gin.H{"data": request})
 }
 
@@ -162,7 +162,10 @@ func (h *IngredientRequestHandler) GetRequestByID(c *gin.Context) {
 func (h *IngredientRequestHandler) CreateRequest(c *gin.Context) {
 	var req CreateRequestInput
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
@@ -175,20 +178,20 @@ func (h *IngredientRequestHandler) CreateRequest(c *gin.Context) {
 
 	requestDate, err := time.Parse("2006-01-02", req.RequestDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày yêu cầu không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("requestDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
 	requiredDate, err := time.Parse("2006-01-02", req.RequiredDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày cần không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("requiredDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
 	// Generate request ID
 	requestID := generateRequestID(requestDate)
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -214,7 +217,22 @@ func (h *IngredientRequestHandler) CreateRequest(c *gin.Context) {
 
 	if err := tx.Create(&request).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	// Latest order time per supplier for delivery on the required date
+	var detailSupplierIDs []string
+	for _, detail := range req.RequestDetails {
+		if detail.SupplierID != nil {
+			detailSupplierIDs = append(detailSupplierIDs, *detail.SupplierID)
+		}
+	}
+	deadlines, err := checkDeliveries(tx, uniqueStrings(detailSupplierIDs), req.KitchenID,
+		time.Date(requiredDate.Year(), requiredDate.Month(), requiredDate.Day(), 0, 0, 0, 0, time.Local), time.Now())
+	if err != nil {
+		tx.Rollback()
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -238,10 +256,13 @@ func (h *IngredientRequestHandler) CreateRequest(c *gin.Context) {
 			TotalPrice:   totalPrice,
 			Notes:        detail.Notes,
 		}
+		if detail.SupplierID != nil {
+			requestDetail.OrderDeadline = deadlines[*detail.SupplierID].OrderBy
+		}
 
 		if err := tx.Create(&requestDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu yêu cầu"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -249,25 +270,26 @@ func (h *IngredientRequestHandler) CreateRequest(c *gin.Context) {
 	// Update total amount
 	if err := tx.Model(&request).Update("total_amount", totalAmount).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload with relationships
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Order").
 		Preload("RequestDetails.Ingredient").
 		Preload("RequestDetails.Supplier").
 		First(&request, "request_id = ?", requestID)
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Tạo phiếu yêu cầu thành công",
-		"data":    request,
+		"message":           "Tạo phiếu yêu cầu thành công",
+		"data":              request,
+		"supplierDeadlines": deadlines,
 	})
 }
 
@@ -284,10 +306,13 @@ func (h *IngredientRequestHandler) CreateRequestFromOrder(c *gin.Context) {
 
 	// Get order with selected suppliers
 	var order models.Order
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Where("order_id = ?", orderID).
 		First(&order).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy đơn hàng"})
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
+		return
+	}
+	if !authorizeKitchen(c, order.KitchenID) {
 		return
 	}
 
@@ -315,17 +340,17 @@ func (h *IngredientRequestHandler) CreateRequestFromOrder(c *gin.Context) {
 		WHERE oi.order_id = ?
 	`
 
-	if err := h.DB.Raw(query, orderID).Scan(&ingredients).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy nguyên liệu đơn hàng"})
+	if err := h.DB.WithContext(c).Raw(query, orderID).Scan(&ingredients).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if len(ingredients) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Đơn hàng không có nguyên liệu"})
+		apperr.Respond(c, apperr.New(apperr.CodeOrderHasNoIngredients))
 		return
 	}
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -351,7 +376,25 @@ func (h *IngredientRequestHandler) CreateRequestFromOrder(c *gin.Context) {
 
 	if err := tx.Create(&request).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	// Latest order time per supplier for delivery on the order date
+	deliveryDate, err := parseDeliveryDate(order.OrderDate)
+	if err != nil {
+		deliveryDate = &requiredDate
+	}
+	var orderSupplierIDs []string
+	for _, ing := range ingredients {
+		if ing.SupplierID != nil {
+			orderSupplierIDs = append(orderSupplierIDs, *ing.SupplierID)
+		}
+	}
+	deadlines, err := checkDeliveries(tx, uniqueStrings(orderSupplierIDs), order.KitchenID, *deliveryDate, time.Now())
+	if err != nil {
+		tx.Rollback()
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -374,10 +417,13 @@ func (h *IngredientRequestHandler) CreateRequestFromOrder(c *gin.Context) {
 			UnitPrice:    ing.UnitPrice,
 			TotalPrice:   totalPrice,
 		}
+		if ing.SupplierID != nil {
+			requestDetail.OrderDeadline = deadlines[*ing.SupplierID].OrderBy
+		}
 
 		if err := tx.Create(&requestDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu yêu cầu"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -385,75 +431,80 @@ func (h *IngredientRequestHandler) CreateRequestFromOrder(c *gin.Context) {
 	// Update total amount
 	if err := tx.Model(&request).Update("total_amount", totalAmount).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload with relationships
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Order").
 		Preload("RequestDetails.Ingredient").
 		Preload("RequestDetails.Supplier").
 		First(&request, "request_id = ?", requestID)
 
 	c.JSON(http.StatusCreated, gin.H{
-		"message": "Tạo phiếu yêu cầu từ đơn hàng thành công",
-		"data":    request,
+		"message":           "Tạo phiếu yêu cầu từ đơn hàng thành công",
+		"data":              request,
+		"supplierDeadlines": deadlines,
 	})
 }
 
 // UpdateRequest updates an existing ingredient request
 func (h *IngredientRequestHandler) UpdateRequest(c *gin.Context) {
 	requestID := c.Param("id")
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 
 	var req CreateRequestInput
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	var existingRequest models.IngredientRequest
-	if err := h.DB.Where("request_id = ?", requestID).First(&existingRequest).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu yêu cầu"})
+	if err := h.DB.WithContext(c).Where("request_id = ?", requestID).First(&existingRequest).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientRequestNotFound))
+		return
+	}
+	if !authorizeKitchen(c, existingRequest.KitchenID) || !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
 	if existingRequest.Status == "approved" || existingRequest.Status == "received" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể sửa phiếu yêu cầu đã duyệt hoặc đã nhận hàng"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
+		return
+	}
+	if !cond.Matches(existingRequest.Version) {
+		h.conflict(c, requestID)
 		return
 	}
 
 	requestDate, err := time.Parse("2006-01-02", req.RequestDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày yêu cầu không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("requestDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
 	requiredDate, err := time.Parse("2006-01-02", req.RequiredDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày cần không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("requiredDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	// Delete existing details
-	if err := tx.Where("request_id = ?", requestID).Delete(&models.IngredientRequestDetail{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa chi tiết cũ"})
-		return
-	}
-
 	// Update header
 	updates := map[string]interface{}{
 		"order_id":      req.OrderID,
@@ -461,11 +512,27 @@ func (h *IngredientRequestHandler) UpdateRequest(c *gin.Context) {
 		"request_date":  requestDate,
 		"required_date": requiredDate,
 		"notes":         req.Notes,
+		"version":       nextVersion,
 	}
 
-	if err := tx.Model(&existingRequest).Updates(updates).Error; err != nil {
+	// Only while still at the version read, a concurrent change waits for the row and then
+	// updates nothing
+	result := tx.Model(&existingRequest).Where("version = ?", existingRequest.Version).Updates(updates)
+	if result.Error != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		h.conflict(c, requestID)
+		return
+	}
+
+	// Delete existing details
+	if err := tx.Where("request_id = ?", requestID).Delete(&models.IngredientRequestDetail{}).Error; err != nil {
+		tx.Rollback()
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -492,7 +559,7 @@ func (h *IngredientRequestHandler) UpdateRequest(c *gin.Context) {
 
 		if err := tx.Create(&requestDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu yêu cầu"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -500,21 +567,22 @@ func (h *IngredientRequestHandler) UpdateRequest(c *gin.Context) {
 	// Update total amount
 	if err := tx.Model(&existingRequest).Update("total_amount", totalAmount).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Order").
 		Preload("RequestDetails.Ingredient").
 		Preload("RequestDetails.Supplier").
 		First(&existingRequest, "request_id = ?", requestID)
 
+	setETag(c, existingRequest.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Cập nhật phiếu yêu cầu thành công",
 		"data":    existingRequest,
@@ -530,15 +598,26 @@ func (h *IngredientRequestHandler) ApproveRequest(c *gin.Context) {
 			userID = v
 		}
 	}
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 
 	var request models.IngredientRequest
-	if err := h.DB.Where("request_id = ?", requestID).First(&request).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu yêu cầu"})
+	if err := h.DB.WithContext(c).Where("request_id = ?", requestID).First(&request).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientRequestNotFound))
+		return
+	}
+	if !authorizeKitchen(c, request.KitchenID) {
 		return
 	}
 
 	if request.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Phiếu yêu cầu đã được duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentAlreadyApproved))
+		return
+	}
+	if !cond.Matches(request.Version) {
+		h.conflict(c, requestID)
 		return
 	}
 
@@ -547,43 +626,70 @@ func (h *IngredientRequestHandler) ApproveRequest(c *gin.Context) {
 		"status":              "approved",
 		"approved_by_user_id": userID,
 		"approved_date":       now,
+		"version":             nextVersion,
 	}
 
-	if err := h.DB.Model(&request).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi duyệt phiếu yêu cầu"})
+	result := h.DB.WithContext(c).Model(&request).Where("version = ?", request.Version).Updates(updates)
+	if result.Error != nil {
+		apperr.Respond(c, apperr.Internal(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		h.conflict(c, requestID)
 		return
 	}
 
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Order").
 		Preload("ApprovedBy").
 		Preload("RequestDetails.Ingredient").
 		Preload("RequestDetails.Supplier").
 		First(&request, "request_id = ?", requestID)
 
+	setETag(c, request.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Duyệt phiếu yêu cầu thành công",
 		"data":    request,
 	})
 }
 
+// conflict answers a change of a request read at another version with its current state
+func (h *IngredientRequestHandler) conflict(c *gin.Context, requestID string) {
+	var current models.IngredientRequest
+	if err := h.DB.WithContext(c).Preload("Kitchen").
+		Preload("Order").
+		Preload("CreatedBy").
+		Preload("ApprovedBy").
+		Preload("RequestDetails.Ingredient").
+		Preload("RequestDetails.Supplier").
+		Where("request_id = ?", requestID).
+		First(&current).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientRequestNotFound))
+		return
+	}
+	versionConflict(c, current.Version, current)
+}
+
 // DeleteRequest deletes a pending request
 func (h *IngredientRequestHandler) DeleteRequest(c *gin.Context) {
 	requestID := c.Param("id")
 
 	var request models.IngredientRequest
-	if err := h.DB.Where("request_id = ?", requestID).First(&request).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu yêu cầu"})
+	if err := h.DB.WithContext(c).Where("request_id = ?", requestID).First(&request).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientRequestNotFound))
+		return
+	}
+	if !authorizeKitchen(c, request.KitchenID) {
 		return
 	}
 
 	if request.Status == "approved" || request.Status == "received" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể xóa phiếu yêu cầu đã duyệt hoặc đã nhận hàng"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
 		return
 	}
 
-	if err := h.DB.Delete(&request).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa phiếu yêu cầu"})
+	if err := h.DB.WithContext(c).Delete(&request).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
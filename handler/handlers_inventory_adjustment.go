@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/models"
+	"adong-be/service"
 	"adong-be/utils"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -12,11 +15,12 @@ import (
 )
 
 type InventoryAdjustmentHandler struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Inventory *service.Inventory
 }
 
-func NewInventoryAdjustmentHandler(db *gorm.DB) *InventoryAdjustmentHandler {
-	return &InventoryAdjustmentHandler{DB: db}
+func NewInventoryAdjustmentHandler(db *gorm.DB, inventory *service.Inventory) *InventoryAdjustmentHandler {
+	return &InventoryAdjustmentHandler{DB: db, Inventory: inventory}
 }
 
 // CreateAdjustmentRequest represents the request body for creating an adjustment
@@ -40,15 +44,9 @@ type CreateAdjustmentDetailRequest struct {
 
 // GetAllAdjustments retrieves all inventory adjustments with pagination and filters
 func (h *InventoryAdjustmentHandler) GetAllAdjustments(c *gin.Context) {
-	// Kitchen-based authorization
-	scope, err := utils.GetUserKitchenScope(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -69,39 +67,14 @@ func (h *InventoryAdjustmentHandler) GetAllAdjustments(c *gin.Context) {
 	var adjustments []models.InventoryAdjustment
 	var total int64
 
-	countQuery := h.DB.Model(&models.InventoryAdjustment{})
-
-	// Apply kitchen auth for count query
-	if scope.IsAdmin {
-		if kitchenID != "" {
-			countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
-		}
-	} else {
-		if len(scope.KitchenIDs) == 0 {
-			meta := models.CalculatePaginationMeta(params.Page, params.PageSize, 0)
-			c.JSON(http.StatusOK, models.ResourceCollection{
-				Data: []models.InventoryAdjustment{},
-				Meta: meta,
-			})
-			return
-		}
-		if kitchenID != "" {
-			allowed := false
-			for _, kid := range scope.KitchenIDs {
-				if kid == kitchenID {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Access to this kitchen is not allowed"})
-				return
-			}
-			countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
-		} else {
-			countQuery = countQuery.Where("kitchen_id IN ?", scope.KitchenIDs)
-		}
+	// Restrict to the kitchens the caller may access
+	base, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.InventoryAdjustment{}), "kitchen_id", kitchenID)
+	if !ok {
+		return
 	}
+	base = base.Session(&gorm.Session{})
+
+	countQuery := base
 	if adjustmentType != "" {
 		countQuery = countQuery.Where("adjustment_type = ?", adjustmentType)
 	}
@@ -116,24 +89,11 @@ func (h *InventoryAdjustmentHandler) GetAllAdjustments(c *gin.Context) {
 	}
 
 	if err := countQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi đếm phiếu kiểm kê"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	query := h.DB.Model(&models.InventoryAdjustment{})
-
-	// Apply same kitchen restriction to data query
-	if scope.IsAdmin {
-		if kitchenID != "" {
-			query = query.Where("kitchen_id = ?", kitchenID)
-		}
-	} else {
-		if kitchenID != "" {
-			query = query.Where("kitchen_id = ?", kitchenID)
-		} else {
-			query = query.Where("kitchen_id IN ?", scope.KitchenIDs)
-		}
-	}
+	query := base
 	if adjustmentType != "" {
 		query = query.Where("adjustment_type = ?", adjustmentType)
 	}
@@ -163,7 +123,7 @@ func (h *InventoryAdjustmentHandler) GetAllAdjustments(c *gin.Context) {
 		Preload("ApprovedBy").
 		Preload("CreatedBy").
 		Find(&adjustments).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách phiếu kiểm kê"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -179,20 +139,20 @@ func (h *InventoryAdjustmentHandler) GetAdjustmentByID(c *gin.Context) {
 	adjustmentID := c.Param("id")
 
 	var adjustment models.InventoryAdjustment
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Preload("ApprovedBy").
 		Preload("CreatedBy").
 		Preload("AdjustmentDetails.Ingredient").
 		Where("adjustment_id = ?", adjustmentID).
 		First(&adjustment).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu kiểm kê"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin phiếu kiểm kê"})
-		}
+		apperr.Respond(c, dbError(err, apperr.CodeAdjustmentNotFound))
+		return
+	}
+	if !authorizeKitchen(c, adjustment.KitchenID) {
 		return
 	}
 
+	setETag(c, adjustment.Version)
 	c.JSON(http.StatusOK, gin.H{"data": adjustment})
 }
 
@@ -200,7 +160,10 @@ func (h *InventoryAdjustmentHandler) GetAdjustmentByID(c *gin.Context) {
 func (h *InventoryAdjustmentHandler) CreateAdjustment(c *gin.Context) {
 	var req CreateAdjustmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
@@ -213,7 +176,7 @@ func (h *InventoryAdjustmentHandler) CreateAdjustment(c *gin.Context) {
 
 	adjustmentDate, err := time.Parse("2006-01-02", req.AdjustmentDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("adjustmentDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
@@ -227,14 +190,14 @@ func (h *InventoryAdjustmentHandler) CreateAdjustment(c *gin.Context) {
 		"other":     true, // Khác
 	}
 	if !validTypes[req.AdjustmentType] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Loại điều chỉnh không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("adjustmentType", "oneof", "count,damage,loss,found,expired,other"))
 		return
 	}
 
 	// Generate adjustment ID
 	adjustmentID := generateAdjustmentID(adjustmentDate)
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -259,7 +222,7 @@ func (h *InventoryAdjustmentHandler) CreateAdjustment(c *gin.Context) {
 
 	if err := tx.Create(&adjustment).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo phiếu kiểm kê"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -289,7 +252,7 @@ func (h *InventoryAdjustmentHandler) CreateAdjustment(c *gin.Context) {
 
 		if err := tx.Create(&adjustmentDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu kiểm kê"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -297,17 +260,17 @@ func (h *InventoryAdjustmentHandler) CreateAdjustment(c *gin.Context) {
 	// Update total value
 	if err := tx.Model(&adjustment).Update("total_value", totalValue).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng giá trị"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu kiểm kê"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload with relationships
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("AdjustmentDetails.Ingredient").
 		First(&adjustment, "adjustment_id = ?", adjustmentID)
 
@@ -320,55 +283,75 @@ func (h *InventoryAdjustmentHandler) CreateAdjustment(c *gin.Context) {
 // UpdateAdjustment updates an existing adjustment
 func (h *InventoryAdjustmentHandler) UpdateAdjustment(c *gin.Context) {
 	adjustmentID := c.Param("id")
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 
 	var req CreateAdjustmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	var existingAdjustment models.InventoryAdjustment
-	if err := h.DB.Where("adjustment_id = ?", adjustmentID).First(&existingAdjustment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu kiểm kê"})
+	if err := h.DB.WithContext(c).Where("adjustment_id = ?", adjustmentID).First(&existingAdjustment).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeAdjustmentNotFound))
+		return
+	}
+	if !authorizeKitchen(c, existingAdjustment.KitchenID) || !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
 	if existingAdjustment.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể sửa phiếu kiểm kê đã duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
+		return
+	}
+	if !cond.Matches(existingAdjustment.Version) {
+		h.conflict(c, adjustmentID)
 		return
 	}
 
 	adjustmentDate, err := time.Parse("2006-01-02", req.AdjustmentDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("adjustmentDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	// Delete existing details
-	if err := tx.Where("adjustment_id = ?", adjustmentID).Delete(&models.InventoryAdjustmentDetail{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa chi tiết cũ"})
-		return
-	}
-
 	// Update header
 	updates := map[string]interface{}{
 		"kitchen_id":      req.KitchenID,
 		"adjustment_date": adjustmentDate,
 		"adjustment_type": req.AdjustmentType,
 		"reason":          req.Reason,
+		"version":         nextVersion,
 	}
 
-	if err := tx.Model(&existingAdjustment).Updates(updates).Error; err != nil {
+	// Only while still at the version read, a concurrent change waits for the row and then
+	// updates nothing
+	result := tx.Model(&existingAdjustment).Where("version = ?", existingAdjustment.Version).Updates(updates)
+	if result.Error != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật phiếu kiểm kê"})
+		apperr.Respond(c, apperr.Internal(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		h.conflict(c, adjustmentID)
+		return
+	}
+
+	// Delete existing details
+	if err := tx.Where("adjustment_id = ?", adjustmentID).Delete(&models.InventoryAdjustmentDetail{}).Error; err != nil {
+		tx.Rollback()
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -398,7 +381,7 @@ func (h *InventoryAdjustmentHandler) UpdateAdjustment(c *gin.Context) {
 
 		if err := tx.Create(&adjustmentDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu kiểm kê"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -406,19 +389,20 @@ func (h *InventoryAdjustmentHandler) UpdateAdjustment(c *gin.Context) {
 	// Update total value
 	if err := tx.Model(&existingAdjustment).Update("total_value", totalValue).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng giá trị"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu kiểm kê"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("AdjustmentDetails.Ingredient").
 		First(&existingAdjustment, "adjustment_id = ?", adjustmentID)
 
+	setETag(c, existingAdjustment.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Cập nhật phiếu kiểm kê thành công",
 		"data":    existingAdjustment,
@@ -434,136 +418,67 @@ func (h *InventoryAdjustmentHandler) ApproveAdjustment(c *gin.Context) {
 			userID = v
 		}
 	}
-
-	var adjustment models.InventoryAdjustment
-	if err := h.DB.Preload("AdjustmentDetails").
-		Where("adjustment_id = ?", adjustmentID).
-		First(&adjustment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu kiểm kê"})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
 		return
 	}
-
-	if adjustment.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Phiếu kiểm kê đã được duyệt"})
+	cond, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	tx := h.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Update adjustment status
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":              "approved",
-		"approved_by_user_id": userID,
-		"approved_date":       now,
+	adjustment, err := h.Inventory.ApproveAdjustment(c, kitchens, adjustmentID, userID, cond)
+	if errors.Is(err, service.ErrVersionMismatch) {
+		h.conflict(c, adjustmentID)
+		return
 	}
-
-	if err := tx.Model(&adjustment).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi duyệt phiếu kiểm kê"})
-		return
-	}
-
-	// Update inventory stocks
-	for _, detail := range adjustment.AdjustmentDetails {
-		var stock models.InventoryStock
-		result := tx.Where("kitchen_id = ? AND ingredient_id = ?",
-			adjustment.KitchenID, detail.IngredientID).
-			First(&stock)
-
-		if result.Error == nil {
-			// Update existing stock to the adjusted quantity
-			if err := tx.Model(&stock).Updates(map[string]interface{}{
-				"quantity":     detail.QuantityAfter,
-				"unit":         detail.Unit,
-				"last_updated": now,
-			}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tồn kho"})
-				return
-			}
-		} else {
-			// Create new stock entry
-			stock = models.InventoryStock{
-				KitchenID:    adjustment.KitchenID,
-				IngredientID: detail.IngredientID,
-				Quantity:     detail.QuantityAfter,
-				Unit:         detail.Unit,
-				LastUpdated:  now,
-			}
-			if err := tx.Create(&stock).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo tồn kho"})
-				return
-			}
-		}
-
-		// Log transaction
-		transactionType := "ADJUSTMENT"
-		if detail.QuantityDifference > 0 {
-			transactionType = "ADJUSTMENT_IN"
-		} else if detail.QuantityDifference < 0 {
-			transactionType = "ADJUSTMENT_OUT"
-		}
-
-		transaction := models.InventoryTransaction{
-			KitchenID:       adjustment.KitchenID,
-			IngredientID:    detail.IngredientID,
-			TransactionType: transactionType,
-			TransactionDate: now,
-			Quantity:        detail.QuantityDifference,
-			Unit:            detail.Unit,
-			QuantityBefore:  detail.QuantityBefore,
-			QuantityAfter:   detail.QuantityAfter,
-			ReferenceType:   strPtr("ADJUSTMENT"),
-			ReferenceID:     &adjustmentID,
-			CreatedByUserID: &userID,
-		}
-		if err := tx.Create(&transaction).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi ghi log giao dịch"})
+	if err != nil {
+		var stockErr *service.StockError
+		if errors.As(err, &stockErr) {
+			apperr.Respond(c, apperr.Wrap(apperr.CodeStockCountNegative, err).With("ingredient_id", stockErr.IngredientID))
 			return
 		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi hoàn tất duyệt phiếu"})
+		apperr.Respond(c, serviceError(err, apperr.CodeAdjustmentNotFound))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
-		Preload("ApprovedBy").
-		Preload("AdjustmentDetails.Ingredient").
-		First(&adjustment, "adjustment_id = ?", adjustmentID)
-
+	setETag(c, adjustment.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Duyệt phiếu kiểm kê thành công",
 		"data":    adjustment,
 	})
 }
 
+// conflict answers a change of an adjustment read at another version with its current state
+func (h *InventoryAdjustmentHandler) conflict(c *gin.Context, adjustmentID string) {
+	current, err := h.Inventory.Repo.GetAdjustment(c, adjustmentID)
+	if err != nil {
+		apperr.Respond(c, serviceError(err, apperr.CodeAdjustmentNotFound))
+		return
+	}
+	versionConflict(c, current.Version, current)
+}
+
 // DeleteAdjustment deletes a draft adjustment
 func (h *InventoryAdjustmentHandler) DeleteAdjustment(c *gin.Context) {
 	adjustmentID := c.Param("id")
 
 	var adjustment models.InventoryAdjustment
-	if err := h.DB.Where("adjustment_id = ?", adjustmentID).First(&adjustment).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu kiểm kê"})
+	if err := h.DB.WithContext(c).Where("adjustment_id = ?", adjustmentID).First(&adjustment).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeAdjustmentNotFound))
+		return
+	}
+	if !authorizeKitchen(c, adjustment.KitchenID) {
 		return
 	}
 
 	if adjustment.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể xóa phiếu kiểm kê đã duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
 		return
 	}
 
-	if err := h.DB.Delete(&adjustment).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa phiếu kiểm kê"})
+	if err := h.DB.WithContext(c).Delete(&adjustment).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
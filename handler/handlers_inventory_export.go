@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/models"
+	"adong-be/service"
 	"adong-be/utils"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -12,11 +15,12 @@ import (
 )
 
 type InventoryExportHandler struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Inventory *service.Inventory
 }
 
-func NewInventoryExportHandler(db *gorm.DB) *InventoryExportHandler {
-	return &InventoryExportHandler{DB: db}
+func NewInventoryExportHandler(db *gorm.DB, inventory *service.Inventory) *InventoryExportHandler {
+	return &InventoryExportHandler{DB: db, Inventory: inventory}
 }
 
 // CreateExportRequest represents the request body for creating an export
@@ -44,7 +48,7 @@ type CreateExportDetailRequest struct {
 func (h *InventoryExportHandler) GetAllExports(c *gin.Context) {
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -65,11 +69,14 @@ func (h *InventoryExportHandler) GetAllExports(c *gin.Context) {
 	var exports []models.InventoryExport
 	var total int64
 
-	countQuery := h.DB.Model(&models.InventoryExport{})
-
-	if kitchenID != "" {
-		countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
+	// Restrict to the kitchens the caller may access
+	base, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.InventoryExport{}), "kitchen_id", kitchenID)
+	if !ok {
+		return
 	}
+	base = base.Session(&gorm.Session{})
+
+	countQuery := base
 	if exportType != "" {
 		countQuery = countQuery.Where("export_type = ?", exportType)
 	}
@@ -84,15 +91,11 @@ func (h *InventoryExportHandler) GetAllExports(c *gin.Context) {
 	}
 
 	if err := countQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi đếm phiếu xuất"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	query := h.DB.Model(&models.InventoryExport{})
-
-	if kitchenID != "" {
-		query = query.Where("kitchen_id = ?", kitchenID)
-	}
+	query := base
 	if exportType != "" {
 		query = query.Where("export_type = ?", exportType)
 	}
@@ -125,7 +128,7 @@ func (h *InventoryExportHandler) GetAllExports(c *gin.Context) {
 		Preload("ApprovedBy").
 		Preload("CreatedBy").
 		Find(&exports).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách phiếu xuất"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -141,7 +144,7 @@ func (h *InventoryExportHandler) GetExportByID(c *gin.Context) {
 	exportID := c.Param("id")
 
 	var exportRecord models.InventoryExport
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Preload("DestinationKitchen").
 		Preload("Order").
 		Preload("IssuedBy").
@@ -150,14 +153,14 @@ func (h *InventoryExportHandler) GetExportByID(c *gin.Context) {
 		Preload("ExportDetails.Ingredient").
 		Where("export_id = ?", exportID).
 		First(&exportRecord).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu xuất"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin phiếu xuất"})
-		}
+		apperr.Respond(c, dbError(err, apperr.CodeExportNotFound))
+		return
+	}
+	if !authorizeKitchen(c, exportRecord.KitchenID) {
 		return
 	}
 
+	setETag(c, exportRecord.Version)
 	c.JSON(http.StatusOK, gin.H{"data": exportRecord})
 }
 
@@ -165,7 +168,10 @@ func (h *InventoryExportHandler) GetExportByID(c *gin.Context) {
 func (h *InventoryExportHandler) CreateExport(c *gin.Context) {
 	var req CreateExportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
@@ -178,7 +184,7 @@ func (h *InventoryExportHandler) CreateExport(c *gin.Context) {
 
 	exportDate, err := time.Parse("2006-01-02", req.ExportDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("exportDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
@@ -191,14 +197,14 @@ func (h *InventoryExportHandler) CreateExport(c *gin.Context) {
 		"sample":     true, // Xuất mẫu
 	}
 	if !validTypes[req.ExportType] {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Loại xuất kho không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("exportType", "oneof", "production,transfer,disposal,return,sample"))
 		return
 	}
 
 	// Generate export ID
 	exportID := generateExportID(exportDate, req.ExportType)
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -225,7 +231,7 @@ func (h *InventoryExportHandler) CreateExport(c *gin.Context) {
 
 	if err := tx.Create(&exportRecord).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo phiếu xuất"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -252,7 +258,7 @@ func (h *InventoryExportHandler) CreateExport(c *gin.Context) {
 
 		if err := tx.Create(&exportDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu xuất"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -260,16 +266,16 @@ func (h *InventoryExportHandler) CreateExport(c *gin.Context) {
 	// Update total amount
 	if err := tx.Model(&exportRecord).Update("total_amount", totalAmount).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu xuất"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("DestinationKitchen").
 		Preload("ExportDetails.Ingredient").
 		First(&exportRecord, "export_id = ?", exportID)
@@ -283,44 +289,48 @@ func (h *InventoryExportHandler) CreateExport(c *gin.Context) {
 // UpdateExport updates an existing export
 func (h *InventoryExportHandler) UpdateExport(c *gin.Context) {
 	exportID := c.Param("id")
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 
 	var req CreateExportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	var existingExport models.InventoryExport
-	if err := h.DB.Where("export_id = ?", exportID).First(&existingExport).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu xuất"})
+	if err := h.DB.WithContext(c).Where("export_id = ?", exportID).First(&existingExport).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeExportNotFound))
+		return
+	}
+	if !authorizeKitchen(c, existingExport.KitchenID) || !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
 	if existingExport.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể sửa phiếu xuất đã duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
+		return
+	}
+	if !cond.Matches(existingExport.Version) {
+		h.conflict(c, exportID)
 		return
 	}
 
 	exportDate, err := time.Parse("2006-01-02", req.ExportDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("exportDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	// Delete existing details
-	if err := tx.Where("export_id = ?", exportID).Delete(&models.InventoryExportDetail{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa chi tiết cũ"})
-		return
-	}
-
 	// Update header
 	updates := map[string]interface{}{
 		"kitchen_id":             req.KitchenID,
@@ -329,11 +339,27 @@ func (h *InventoryExportHandler) UpdateExport(c *gin.Context) {
 		"destination_kitchen_id": req.DestinationKitchenID,
 		"order_id":               req.OrderID,
 		"notes":                  req.Notes,
+		"version":                nextVersion,
 	}
 
-	if err := tx.Model(&existingExport).Updates(updates).Error; err != nil {
+	// Only while still at the version read, a concurrent change waits for the row and then
+	// updates nothing
+	result := tx.Model(&existingExport).Where("version = ?", existingExport.Version).Updates(updates)
+	if result.Error != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật phiếu xuất"})
+		apperr.Respond(c, apperr.Internal(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		h.conflict(c, exportID)
+		return
+	}
+
+	// Delete existing details
+	if err := tx.Where("export_id = ?", exportID).Delete(&models.InventoryExportDetail{}).Error; err != nil {
+		tx.Rollback()
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -360,7 +386,7 @@ func (h *InventoryExportHandler) UpdateExport(c *gin.Context) {
 
 		if err := tx.Create(&exportDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu xuất"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -368,20 +394,21 @@ func (h *InventoryExportHandler) UpdateExport(c *gin.Context) {
 	// Update total amount
 	if err := tx.Model(&existingExport).Update("total_amount", totalAmount).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu xuất"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("DestinationKitchen").
 		Preload("ExportDetails.Ingredient").
 		First(&existingExport, "export_id = ?", exportID)
 
+	setETag(c, existingExport.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Cập nhật phiếu xuất thành công",
 		"data":    existingExport,
@@ -397,198 +424,62 @@ func (h *InventoryExportHandler) ApproveExport(c *gin.Context) {
 			userID = v
 		}
 	}
-
-	var exportRecord models.InventoryExport
-	if err := h.DB.Preload("ExportDetails").
-		Where("export_id = ?", exportID).
-		First(&exportRecord).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu xuất"})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
 		return
 	}
-
-	if exportRecord.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Phiếu xuất đã được duyệt"})
+	cond, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	tx := h.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Check stock availability
-	for _, detail := range exportRecord.ExportDetails {
-		var stock models.InventoryStock
-		if err := tx.Where("kitchen_id = ? AND ingredient_id = ?",
-			exportRecord.KitchenID, detail.IngredientID).
-			First(&stock).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":         "Nguyên liệu không tồn tại trong kho",
-				"ingredient_id": detail.IngredientID,
-			})
-			return
-		}
-
-		if stock.Quantity < detail.Quantity {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":         "Số lượng tồn kho không đủ",
-				"ingredient_id": detail.IngredientID,
-				"available":     stock.Quantity,
-				"required":      detail.Quantity,
-			})
-			return
-		}
-	}
-
-	// Update export status
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":              "approved",
-		"approved_by_user_id": userID,
-		"approved_date":       now,
-	}
-
-	if err := tx.Model(&exportRecord).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi duyệt phiếu xuất"})
+	exportRecord, err := h.Inventory.ApproveExport(c, kitchens, exportID, userID, cond)
+	if errors.Is(err, service.ErrVersionMismatch) {
+		h.conflict(c, exportID)
 		return
 	}
-
-	// Update inventory stocks
-	for _, detail := range exportRecord.ExportDetails {
-		var stock models.InventoryStock
-		tx.Where("kitchen_id = ? AND ingredient_id = ?",
-			exportRecord.KitchenID, detail.IngredientID).
-			First(&stock)
-
-		quantityBefore := stock.Quantity
-
-		// Decrease stock
-		if err := tx.Model(&stock).Updates(map[string]interface{}{
-			"quantity":     gorm.Expr("quantity - ?", detail.Quantity),
-			"last_updated": now,
-		}).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tồn kho"})
-			return
-		}
-
-		// Log transaction
-		transaction := models.InventoryTransaction{
-			KitchenID:       exportRecord.KitchenID,
-			IngredientID:    detail.IngredientID,
-			TransactionType: "EXPORT",
-			TransactionDate: now,
-			Quantity:        -detail.Quantity, // Negative for exports
-			Unit:            detail.Unit,
-			QuantityBefore:  quantityBefore,
-			QuantityAfter:   quantityBefore - detail.Quantity,
-			ReferenceType:   strPtr(exportRecord.ExportType),
-			ReferenceID:     &exportID,
-			CreatedByUserID: &userID,
-		}
-		if err := tx.Create(&transaction).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi ghi log giao dịch"})
-			return
-		}
-
-		// If transfer to another kitchen, create import record there
-		if exportRecord.ExportType == "transfer" && exportRecord.DestinationKitchenID != nil {
-			var destStock models.InventoryStock
-			destResult := tx.Where("kitchen_id = ? AND ingredient_id = ?",
-				*exportRecord.DestinationKitchenID, detail.IngredientID).
-				First(&destStock)
-
-			destQuantityBefore := 0.0
-			if destResult.Error == nil {
-				destQuantityBefore = destStock.Quantity
-				// Update destination stock
-				if err := tx.Model(&destStock).Updates(map[string]interface{}{
-					"quantity":     gorm.Expr("quantity + ?", detail.Quantity),
-					"unit":         detail.Unit,
-					"last_updated": now,
-				}).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật kho đích"})
-					return
-				}
-			} else {
-				// Create new stock entry at destination
-				destStock = models.InventoryStock{
-					KitchenID:    *exportRecord.DestinationKitchenID,
-					IngredientID: detail.IngredientID,
-					Quantity:     detail.Quantity,
-					Unit:         detail.Unit,
-					LastUpdated:  now,
-				}
-				if err := tx.Create(&destStock).Error; err != nil {
-					tx.Rollback()
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo tồn kho đích"})
-					return
-				}
-			}
-
-			// Log destination transaction
-			destTransaction := models.InventoryTransaction{
-				KitchenID:       *exportRecord.DestinationKitchenID,
-				IngredientID:    detail.IngredientID,
-				TransactionType: "TRANSFER_IN",
-				TransactionDate: now,
-				Quantity:        detail.Quantity,
-				Unit:            detail.Unit,
-				QuantityBefore:  destQuantityBefore,
-				QuantityAfter:   destQuantityBefore + detail.Quantity,
-				ReferenceType:   strPtr("EXPORT"),
-				ReferenceID:     &exportID,
-				CreatedByUserID: &userID,
-			}
-			if err := tx.Create(&destTransaction).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi ghi log chuyển kho"})
-				return
-			}
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi hoàn tất duyệt phiếu"})
+	if err != nil {
+		apperr.Respond(c, serviceError(err, apperr.CodeExportNotFound))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
-		Preload("DestinationKitchen").
-		Preload("ApprovedBy").
-		Preload("ExportDetails.Ingredient").
-		First(&exportRecord, "export_id = ?", exportID)
-
+	setETag(c, exportRecord.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Duyệt phiếu xuất thành công",
 		"data":    exportRecord,
 	})
 }
 
+// conflict answers a change of an export read at another version with its current state
+func (h *InventoryExportHandler) conflict(c *gin.Context, exportID string) {
+	current, err := h.Inventory.Repo.GetExport(c, exportID)
+	if err != nil {
+		apperr.Respond(c, serviceError(err, apperr.CodeExportNotFound))
+		return
+	}
+	versionConflict(c, current.Version, current)
+}
+
 // DeleteExport deletes a draft export
 func (h *InventoryExportHandler) DeleteExport(c *gin.Context) {
 	exportID := c.Param("id")
 
 	var exportRecord models.InventoryExport
-	if err := h.DB.Where("export_id = ?", exportID).First(&exportRecord).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu xuất"})
+	if err := h.DB.WithContext(c).Where("export_id = ?", exportID).First(&exportRecord).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeExportNotFound))
+		return
+	}
+	if !authorizeKitchen(c, exportRecord.KitchenID) {
 		return
 	}
 
 	if exportRecord.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể xóa phiếu xuất đã duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
 		return
 	}
 
-	if err := h.DB.Delete(&exportRecord).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa phiếu xuất"})
+	if err := h.DB.WithContext(c).Delete(&exportRecord).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"adong-be/apperr"
+	"adong-be/logger"
 	"adong-be/models"
+	"adong-be/service"
 	"adong-be/utils"
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,11 +17,12 @@ import (
 )
 
 type InventoryImportHandler struct {
-	DB *gorm.DB
+	DB        *gorm.DB
+	Inventory *service.Inventory
 }
 
-func NewInventoryImportHandler(db *gorm.DB) *InventoryImportHandler {
-	return &InventoryImportHandler{DB: db}
+func NewInventoryImportHandler(db *gorm.DB, inventory *service.Inventory) *InventoryImportHandler {
+	return &InventoryImportHandler{DB: db, Inventory: inventory}
 }
 
 // CreateImportRequest represents the request body for creating an import
@@ -44,15 +49,9 @@ type CreateImportDetailRequest struct {
 
 // GetAllImports retrieves all inventory imports with pagination and filters
 func (h *InventoryImportHandler) GetAllImports(c *gin.Context) {
-	// Kitchen-based authorization
-	scope, err := utils.GetUserKitchenScope(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -72,39 +71,14 @@ func (h *InventoryImportHandler) GetAllImports(c *gin.Context) {
 	var imports []models.InventoryImport
 	var total int64
 
-	countQuery := h.DB.Model(&models.InventoryImport{})
-
-	// Apply kitchen auth for count query
-	if scope.IsAdmin {
-		if kitchenID != "" {
-			countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
-		}
-	} else {
-		if len(scope.KitchenIDs) == 0 {
-			meta := models.CalculatePaginationMeta(params.Page, params.PageSize, 0)
-			c.JSON(http.StatusOK, models.ResourceCollection{
-				Data: []models.InventoryImport{},
-				Meta: meta,
-			})
-			return
-		}
-		if kitchenID != "" {
-			allowed := false
-			for _, kid := range scope.KitchenIDs {
-				if kid == kitchenID {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Access to this kitchen is not allowed"})
-				return
-			}
-			countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
-		} else {
-			countQuery = countQuery.Where("kitchen_id IN ?", scope.KitchenIDs)
-		}
+	// Restrict to the kitchens the caller may access
+	base, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.InventoryImport{}), "kitchen_id", kitchenID)
+	if !ok {
+		return
 	}
+	base = base.Session(&gorm.Session{})
+
+	countQuery := base
 	if status != "" {
 		countQuery = countQuery.Where("status = ?", status)
 	}
@@ -116,24 +90,11 @@ func (h *InventoryImportHandler) GetAllImports(c *gin.Context) {
 	}
 
 	if err := countQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi đếm phiếu nhập"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	query := h.DB.Model(&models.InventoryImport{})
-
-	// Apply same kitchen restriction to data query
-	if scope.IsAdmin {
-		if kitchenID != "" {
-			query = query.Where("kitchen_id = ?", kitchenID)
-		}
-	} else {
-		if kitchenID != "" {
-			query = query.Where("kitchen_id = ?", kitchenID)
-		} else {
-			query = query.Where("kitchen_id IN ?", scope.KitchenIDs)
-		}
-	}
+	query := base
 	if status != "" {
 		query = query.Where("status = ?", status)
 	}
@@ -162,7 +123,7 @@ func (h *InventoryImportHandler) GetAllImports(c *gin.Context) {
 		Preload("ApprovedBy").
 		Preload("CreatedBy").
 		Find(&imports).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách phiếu nhập"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -178,7 +139,7 @@ func (h *InventoryImportHandler) GetImportByID(c *gin.Context) {
 	importID := c.Param("id")
 
 	var importRecord models.InventoryImport
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Supplier").
 		Preload("Order").
 		Preload("ReceivedBy").
@@ -188,14 +149,14 @@ func (h *InventoryImportHandler) GetImportByID(c *gin.Context) {
 		Preload("ImportDetails.Supplier").
 		Where("import_id = ?", importID).
 		First(&importRecord).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu nhập"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin phiếu nhập"})
-		}
+		apperr.Respond(c, dbError(err, apperr.CodeImportNotFound))
+		return
+	}
+	if !authorizeKitchen(c, importRecord.KitchenID) {
 		return
 	}
 
+	setETag(c, importRecord.Version)
 	c.JSON(http.StatusOK, gin.H{"data": importRecord})
 }
 
@@ -203,7 +164,10 @@ func (h *InventoryImportHandler) GetImportByID(c *gin.Context) {
 func (h *InventoryImportHandler) CreateImport(c *gin.Context) {
 	var req CreateImportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
@@ -214,33 +178,31 @@ func (h *InventoryImportHandler) CreateImport(c *gin.Context) {
 		}
 	}
 
-	// Add logging
-	println("[CreateImport] Starting import creation for user:", userID)
-	println("[CreateImport] Number of details:", len(req.ImportDetails))
+	logger.From(c).Info("CreateImport called", "user_id", userID, "kitchen_id", req.KitchenID, "details", len(req.ImportDetails))
 
 	importDate, err := time.Parse("2006-01-02", req.ImportDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("importDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
 	// Generate import ID
 	importID := generateImportID(importDate)
 
-	// Start transaction with timeout
-	println("[CreateImport] Starting transaction")
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	// Start transaction with timeout. The context derives from c, whose values carry the
+	// caller and request the audit log records.
+	ctx, cancel := context.WithTimeout(c, 30*time.Second)
 	defer cancel()
 
 	tx := h.DB.WithContext(ctx).Begin()
 	if tx.Error != nil {
-		println("[CreateImport] Failed to begin transaction:", tx.Error.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi bắt đầu giao dịch"})
+		logger.From(c).Error("CreateImport transaction begin error", "error", tx.Error)
+		apperr.Respond(c, apperr.Internal(tx.Error))
 		return
 	}
 	defer func() {
 		if r := recover(); r != nil {
-			println("[CreateImport] Panic occurred, rolling back:", r)
+			logger.From(c).Error("CreateImport panic, rolling back", "panic", r)
 			tx.Rollback()
 		}
 	}()
@@ -262,20 +224,16 @@ func (h *InventoryImportHandler) CreateImport(c *gin.Context) {
 		CreatedByUserID: &userID,
 	}
 
-	println("[CreateImport] Creating import header:", importID)
 	if err := tx.Create(&importRecord).Error; err != nil {
-		println("[CreateImport] Failed to create import header:", err.Error())
+		logger.From(c).Error("CreateImport create header error", "import_id", importID, "error", err)
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo phiếu nhập"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
-	println("[CreateImport] Import header created successfully")
 
 	// Create import details and calculate total
 	var totalAmount float64
-	println("[CreateImport] Creating import details, count:", len(req.ImportDetails))
 	for i, detail := range req.ImportDetails {
-		println("[CreateImport] Creating detail", i+1, "for ingredient:", detail.IngredientID)
 		totalPrice := detail.Quantity * detail.UnitPrice
 		totalAmount += totalPrice
 
@@ -301,43 +259,38 @@ func (h *InventoryImportHandler) CreateImport(c *gin.Context) {
 		}
 
 		if err := tx.Create(&importDetail).Error; err != nil {
-			println("[CreateImport] Failed to create detail", i+1, ":", err.Error())
+			logger.From(c).Error("CreateImport create detail error", "import_id", importID, "index", i, "ingredient_id", detail.IngredientID, "error", err)
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu nhập"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
-		println("[CreateImport] Detail", i+1, "created successfully")
 	}
 
 	// Update total amount
-	println("[CreateImport] Updating total amount:", totalAmount)
 	if err := tx.Model(&importRecord).Update("total_amount", totalAmount).Error; err != nil {
-		println("[CreateImport] Failed to update total amount:", err.Error())
+		logger.From(c).Error("CreateImport update total error", "import_id", importID, "error", err)
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	println("[CreateImport] Committing transaction")
 	if err := tx.Commit().Error; err != nil {
-		println("[CreateImport] Failed to commit transaction:", err.Error())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu nhập"})
+		logger.From(c).Error("CreateImport commit error", "import_id", importID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
-	println("[CreateImport] Transaction committed successfully")
 
 	// Reload with relationships
-	println("[CreateImport] Reloading import with relationships")
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Supplier").
 		Preload("ImportDetails.Ingredient").
 		Preload("ImportDetails.Supplier").
 		First(&importRecord, "import_id = ?", importID).Error; err != nil {
-		println("[CreateImport] Failed to reload import:", err.Error())
+		logger.From(c).Warn("CreateImport reload error", "import_id", importID, "error", err)
 		// Don't fail here, just return what we have
 	}
 
-	println("[CreateImport] Import created successfully:", importID)
+	logger.From(c).Info("CreateImport success", "import_id", importID, "total_amount", totalAmount)
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Tạo phiếu nhập thành công",
 		"data":    importRecord,
@@ -347,44 +300,48 @@ func (h *InventoryImportHandler) CreateImport(c *gin.Context) {
 // UpdateImport updates an existing import
 func (h *InventoryImportHandler) UpdateImport(c *gin.Context) {
 	importID := c.Param("id")
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 
 	var req CreateImportRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	var existingImport models.InventoryImport
-	if err := h.DB.Where("import_id = ?", importID).First(&existingImport).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu nhập"})
+	if err := h.DB.WithContext(c).Where("import_id = ?", importID).First(&existingImport).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeImportNotFound))
+		return
+	}
+	if !authorizeKitchen(c, existingImport.KitchenID) || !authorizeKitchen(c, req.KitchenID) {
 		return
 	}
 
 	if existingImport.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể sửa phiếu nhập đã duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
+		return
+	}
+	if !cond.Matches(existingImport.Version) {
+		h.conflict(c, importID)
 		return
 	}
 
 	importDate, err := time.Parse("2006-01-02", req.ImportDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng ngày không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("importDate", "date", "YYYY-MM-DD"))
 		return
 	}
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
 		}
 	}()
 
-	// Delete existing details
-	if err := tx.Where("import_id = ?", importID).Delete(&models.InventoryImportDetail{}).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa chi tiết cũ"})
-		return
-	}
-
 	// Update header
 	updates := map[string]interface{}{
 		"kitchen_id":  req.KitchenID,
@@ -392,11 +349,27 @@ func (h *InventoryImportHandler) UpdateImport(c *gin.Context) {
 		"order_id":    req.OrderID,
 		"supplier_id": req.SupplierID,
 		"notes":       req.Notes,
+		"version":     nextVersion,
 	}
 
-	if err := tx.Model(&existingImport).Updates(updates).Error; err != nil {
+	// Only while still at the version read, a concurrent change waits for the row and then
+	// updates nothing
+	result := tx.Model(&existingImport).Where("version = ?", existingImport.Version).Updates(updates)
+	if result.Error != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật phiếu nhập"})
+		apperr.Respond(c, apperr.Internal(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		h.conflict(c, importID)
+		return
+	}
+
+	// Delete existing details
+	if err := tx.Where("import_id = ?", importID).Delete(&models.InventoryImportDetail{}).Error; err != nil {
+		tx.Rollback()
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -429,7 +402,7 @@ func (h *InventoryImportHandler) UpdateImport(c *gin.Context) {
 
 		if err := tx.Create(&importDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu nhập"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -437,21 +410,22 @@ func (h *InventoryImportHandler) UpdateImport(c *gin.Context) {
 	// Update total amount
 	if err := tx.Model(&existingImport).Update("total_amount", totalAmount).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu nhập"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Supplier").
 		Preload("ImportDetails.Ingredient").
 		Preload("ImportDetails.Supplier").
 		First(&existingImport, "import_id = ?", importID)
 
+	setETag(c, existingImport.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Cập nhật phiếu nhập thành công",
 		"data":    existingImport,
@@ -467,132 +441,62 @@ func (h *InventoryImportHandler) ApproveImport(c *gin.Context) {
 			userID = v
 		}
 	}
-
-	var importRecord models.InventoryImport
-	if err := h.DB.Preload("ImportDetails").
-		Where("import_id = ?", importID).
-		First(&importRecord).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu nhập"})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
 		return
 	}
-
-	if importRecord.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Phiếu nhập đã được duyệt"})
+	cond, ok := ifMatch(c)
+	if !ok {
 		return
 	}
 
-	tx := h.DB.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	// Update import status
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":              "approved",
-		"approved_by_user_id": userID,
-		"approved_date":       now,
-	}
-
-	if err := tx.Model(&importRecord).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi duyệt phiếu nhập"})
-		return
-	}
-
-	// Update inventory stocks
-	for _, detail := range importRecord.ImportDetails {
-		var stock models.InventoryStock
-		result := tx.Where("kitchen_id = ? AND ingredient_id = ?",
-			importRecord.KitchenID, detail.IngredientID).
-			First(&stock)
-
-		quantityBefore := 0.0
-		if result.Error == nil {
-			quantityBefore = stock.Quantity
-			// Update existing stock
-			if err := tx.Model(&stock).Updates(map[string]interface{}{
-				"quantity":     gorm.Expr("quantity + ?", detail.Quantity),
-				"unit":         detail.Unit,
-				"last_updated": now,
-			}).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tồn kho"})
-				return
-			}
-		} else {
-			// Create new stock entry
-			stock = models.InventoryStock{
-				KitchenID:    importRecord.KitchenID,
-				IngredientID: detail.IngredientID,
-				Quantity:     detail.Quantity,
-				Unit:         detail.Unit,
-				LastUpdated:  now,
-			}
-			if err := tx.Create(&stock).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo tồn kho"})
-				return
-			}
-		}
-
-		// Log transaction
-		transaction := models.InventoryTransaction{
-			KitchenID:       importRecord.KitchenID,
-			IngredientID:    detail.IngredientID,
-			TransactionType: "IMPORT",
-			TransactionDate: now,
-			Quantity:        detail.Quantity,
-			Unit:            detail.Unit,
-			QuantityBefore:  quantityBefore,
-			QuantityAfter:   quantityBefore + detail.Quantity,
-			ReferenceType:   strPtr("IMPORT"),
-			ReferenceID:     &importID,
-			CreatedByUserID: &userID,
-		}
-		if err := tx.Create(&transaction).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi ghi log giao dịch"})
-			return
-		}
+	importRecord, err := h.Inventory.ApproveImport(c, kitchens, importID, userID, cond)
+	if errors.Is(err, service.ErrVersionMismatch) {
+		h.conflict(c, importID)
+		return
 	}
-
-	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi hoàn tất duyệt phiếu"})
+	if err != nil {
+		apperr.Respond(c, serviceError(err, apperr.CodeImportNotFound))
 		return
 	}
 
-	h.DB.Preload("Kitchen").
-		Preload("Supplier").
-		Preload("ApprovedBy").
-		Preload("ImportDetails.Ingredient").
-		First(&importRecord, "import_id = ?", importID)
-
+	setETag(c, importRecord.Version)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Duyệt phiếu nhập thành công",
 		"data":    importRecord,
 	})
 }
 
+// conflict answers a change of an import read at another version with its current state
+func (h *InventoryImportHandler) conflict(c *gin.Context, importID string) {
+	current, err := h.Inventory.Repo.GetImport(c, importID)
+	if err != nil {
+		apperr.Respond(c, serviceError(err, apperr.CodeImportNotFound))
+		return
+	}
+	versionConflict(c, current.Version, current)
+}
+
 // DeleteImport deletes a draft import
 func (h *InventoryImportHandler) DeleteImport(c *gin.Context) {
 	importID := c.Param("id")
 
 	var importRecord models.InventoryImport
-	if err := h.DB.Where("import_id = ?", importID).First(&importRecord).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu nhập"})
+	if err := h.DB.WithContext(c).Where("import_id = ?", importID).First(&importRecord).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeImportNotFound))
+		return
+	}
+	if !authorizeKitchen(c, importRecord.KitchenID) {
 		return
 	}
 
 	if importRecord.Status == "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Không thể xóa phiếu nhập đã duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeDocumentNotEditable))
 		return
 	}
 
-	if err := h.DB.Delete(&importRecord).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi xóa phiếu nhập"})
+	if err := h.DB.WithContext(c).Delete(&importRecord).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -612,15 +516,18 @@ func (h *InventoryImportHandler) CreateImportFromRequest(c *gin.Context) {
 
 	// Get ingredient request with details
 	var request models.IngredientRequest
-	if err := h.DB.Preload("RequestDetails").
+	if err := h.DB.WithContext(c).Preload("RequestDetails").
 		Where("request_id = ?", requestID).
 		First(&request).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy phiếu yêu cầu"})
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientRequestNotFound))
+		return
+	}
+	if !authorizeKitchen(c, request.KitchenID) {
 		return
 	}
 
 	if request.Status != "approved" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Phiếu yêu cầu chưa được duyệt"})
+		apperr.Respond(c, apperr.New(apperr.CodeRequestNotApproved))
 		return
 	}
 
@@ -644,7 +551,7 @@ func (h *InventoryImportHandler) CreateImportFromRequest(c *gin.Context) {
 		}
 	}
 
-	tx := h.DB.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -668,7 +575,7 @@ func (h *InventoryImportHandler) CreateImportFromRequest(c *gin.Context) {
 
 	if err := tx.Create(&importRecord).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo phiếu nhập"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -695,7 +602,7 @@ func (h *InventoryImportHandler) CreateImportFromRequest(c *gin.Context) {
 
 		if err := tx.Create(&importDetail).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tạo chi tiết phiếu nhập"})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -703,24 +610,24 @@ func (h *InventoryImportHandler) CreateImportFromRequest(c *gin.Context) {
 	// Update total amount
 	if err := tx.Model(&importRecord).Update("total_amount", totalAmount).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật tổng tiền"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Update request status to received
-	if err := tx.Model(&request).Update("status", "received").Error; err != nil {
+	if err := tx.Model(&request).Updates(map[string]interface{}{"status": "received", "version": nextVersion}).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật trạng thái yêu cầu"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lưu phiếu nhập"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload with relationships
-	h.DB.Preload("Kitchen").
+	h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Supplier").
 		Preload("ImportDetails.Ingredient").
 		Preload("ImportDetails.Supplier").
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"adong-be/apperr"
+	"adong-be/models"
 	"net/http"
 	"time"
 
@@ -35,23 +37,26 @@ func (h *InventoryReportsHandler) GetStockMovementReport(c *gin.Context) {
 	toDate := c.Query("to_date")
 
 	if kitchenID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 	if fromDate == "" || toDate == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có from_date và to_date"})
+		apperr.Respond(c, apperr.Invalid("from_date", "required").Field("to_date", "required"))
 		return
 	}
 
 	fromDateTime, err := time.Parse("2006-01-02", fromDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng from_date không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("from_date", "date", "YYYY-MM-DD"))
 		return
 	}
 
 	toDateTime, err := time.Parse("2006-01-02", toDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Định dạng to_date không hợp lệ"})
+		apperr.Respond(c, apperr.Invalid("to_date", "date", "YYYY-MM-DD"))
 		return
 	}
 
@@ -99,8 +104,8 @@ func (h *InventoryReportsHandler) GetStockMovementReport(c *gin.Context) {
 		ORDER BY os.ingredient_name
 	`
 
-	if err := h.DB.Raw(query, fromDateTime, kitchenID, kitchenID, fromDateTime, toDateTime).Scan(&movements).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy báo cáo xuất nhập tồn"})
+	if err := h.DB.WithContext(c).Raw(query, fromDateTime, kitchenID, kitchenID, fromDateTime, toDateTime).Scan(&movements).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -131,7 +136,10 @@ func (h *InventoryReportsHandler) GetExpiryAlerts(c *gin.Context) {
 	daysAhead := c.DefaultQuery("days_ahead", "30") // Default 30 days
 
 	if kitchenID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 
@@ -159,8 +167,8 @@ func (h *InventoryReportsHandler) GetExpiryAlerts(c *gin.Context) {
 		ORDER BY iid.expiry_date ASC, i.ingredient_name
 	`
 
-	if err := h.DB.Raw(query, kitchenID).Scan(&alerts).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy cảnh báo hết hạn"})
+	if err := h.DB.WithContext(c).Raw(query, kitchenID).Scan(&alerts).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -186,11 +194,14 @@ func (h *InventoryReportsHandler) GetStockValueTrend(c *gin.Context) {
 	interval := c.DefaultQuery("interval", "day") // day, week, month
 
 	if kitchenID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 	if fromDate == "" || toDate == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có from_date và to_date"})
+		apperr.Respond(c, apperr.Invalid("from_date", "required").Field("to_date", "required"))
 		return
 	}
 
@@ -206,6 +217,7 @@ func (h *InventoryReportsHandler) GetStockValueTrend(c *gin.Context) {
 
 	var trends []StockValueTrend
 
+	// Only confirmed supplier products price the stock, as in supplier selection
 	query := `
 		WITH date_series AS (
 			SELECT generate_series(
@@ -229,6 +241,7 @@ func (h *InventoryReportsHandler) GetStockValueTrend(c *gin.Context) {
 							  FROM supplier_price_list spl
 							  WHERE spl.ingredient_id = it.ingredient_id
 							  	AND spl.active = true
+							  	AND spl.mapping_status = ?
 							  LIMIT 1), 0)
 				), 0) as total_value,
 				COUNT(DISTINCT it.ingredient_id) as total_items
@@ -245,8 +258,8 @@ func (h *InventoryReportsHandler) GetStockValueTrend(c *gin.Context) {
 		ORDER BY date
 	`
 
-	if err := h.DB.Raw(query, fromDate, toDate, kitchenID, kitchenID, dateFormat, dateFormat).Scan(&trends).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy xu hướng giá trị tồn kho"})
+	if err := h.DB.WithContext(c).Raw(query, fromDate, toDate, kitchenID, models.MappingStatusConfirmed, kitchenID, dateFormat, dateFormat).Scan(&trends).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -273,7 +286,10 @@ func (h *InventoryReportsHandler) GetTransactionSummary(c *gin.Context) {
 	toDate := c.Query("to_date")
 
 	if kitchenID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 
@@ -301,8 +317,8 @@ func (h *InventoryReportsHandler) GetTransactionSummary(c *gin.Context) {
 
 	query += " GROUP BY transaction_type ORDER BY transaction_type"
 
-	if err := h.DB.Raw(query, params...).Scan(&summary).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy tổng hợp giao dịch"})
+	if err := h.DB.WithContext(c).Raw(query, params...).Scan(&summary).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -329,7 +345,10 @@ func (h *InventoryReportsHandler) GetTopConsumedIngredients(c *gin.Context) {
 	limit := c.DefaultQuery("limit", "10")
 
 	if kitchenID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 
@@ -364,8 +383,8 @@ func (h *InventoryReportsHandler) GetTopConsumedIngredients(c *gin.Context) {
 		ORDER BY total_consumed DESC
 		LIMIT ` + limit
 
-	if err := h.DB.Raw(query, params...).Scan(&topIngredients).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy nguyên liệu tiêu thụ nhiều nhất"})
+	if err := h.DB.WithContext(c).Raw(query, params...).Scan(&topIngredients).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
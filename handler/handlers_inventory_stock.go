@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/models"
 	"adong-be/utils"
 	"net/http"
@@ -19,16 +20,9 @@ func NewInventoryStockHandler(db *gorm.DB) *InventoryStockHandler {
 
 // GetAllStocks retrieves all inventory stocks with pagination and filters
 func (h *InventoryStockHandler) GetAllStocks(c *gin.Context) {
-	// Kitchen-based authorization
-	scope, err := utils.GetUserKitchenScope(c)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -46,40 +40,14 @@ func (h *InventoryStockHandler) GetAllStocks(c *gin.Context) {
 	var stocks []models.InventoryStock
 	var total int64
 
-	countQuery := h.DB.Model(&models.InventoryStock{})
-
-	// Apply kitchen authorization
-	if scope.IsAdmin {
-		if kitchenID != "" {
-			countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
-		}
-	} else {
-		if len(scope.KitchenIDs) == 0 {
-			// No accessible kitchens -> empty result
-			meta := models.CalculatePaginationMeta(params.Page, params.PageSize, 0)
-			c.JSON(http.StatusOK, models.ResourceCollection{
-				Data: []models.InventoryStock{},
-				Meta: meta,
-			})
-			return
-		}
-		if kitchenID != "" {
-			allowed := false
-			for _, kid := range scope.KitchenIDs {
-				if kid == kitchenID {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Access to this kitchen is not allowed"})
-				return
-			}
-			countQuery = countQuery.Where("kitchen_id = ?", kitchenID)
-		} else {
-			countQuery = countQuery.Where("kitchen_id IN ?", scope.KitchenIDs)
-		}
+	// Restrict to the kitchens the caller may access
+	base, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.InventoryStock{}), "inventory_stocks.kitchen_id", kitchenID)
+	if !ok {
+		return
 	}
+	base = base.Session(&gorm.Session{})
+
+	countQuery := base
 
 	if params.Search != "" {
 		countQuery = countQuery.Joins("JOIN master_ingredients ON master_ingredients.ingredient_id = inventory_stocks.ingredient_id").
@@ -91,24 +59,11 @@ func (h *InventoryStockHandler) GetAllStocks(c *gin.Context) {
 	}
 
 	if err := countQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi đếm tồn kho"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	query := h.DB.Model(&models.InventoryStock{})
-
-	// Apply same kitchen restriction to data query
-	if scope.IsAdmin {
-		if kitchenID != "" {
-			query = query.Where("kitchen_id = ?", kitchenID)
-		}
-	} else {
-		if kitchenID != "" {
-			query = query.Where("kitchen_id = ?", kitchenID)
-		} else {
-			query = query.Where("kitchen_id IN ?", scope.KitchenIDs)
-		}
-	}
+	query := base
 
 	if params.Search != "" {
 		query = query.Joins("JOIN master_ingredients ON master_ingredients.ingredient_id = inventory_stocks.ingredient_id").
@@ -133,7 +88,7 @@ func (h *InventoryStockHandler) GetAllStocks(c *gin.Context) {
 	if err := query.Preload("Kitchen").
 		Preload("Ingredient").
 		Find(&stocks).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy danh sách tồn kho"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -149,15 +104,14 @@ func (h *InventoryStockHandler) GetStockByID(c *gin.Context) {
 	stockID := c.Param("id")
 
 	var stock models.InventoryStock
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Ingredient").
 		Where("stock_id = ?", stockID).
 		First(&stock).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tồn kho"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin tồn kho"})
-		}
+		apperr.Respond(c, dbError(err, apperr.CodeStockNotFound))
+		return
+	}
+	if !authorizeKitchen(c, stock.KitchenID) {
 		return
 	}
 
@@ -170,20 +124,19 @@ func (h *InventoryStockHandler) GetStockByKitchenAndIngredient(c *gin.Context) {
 	ingredientID := c.Query("ingredient_id")
 
 	if kitchenID == "" || ingredientID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id và ingredient_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required").Field("ingredient_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 
 	var stock models.InventoryStock
-	if err := h.DB.Preload("Kitchen").
+	if err := h.DB.WithContext(c).Preload("Kitchen").
 		Preload("Ingredient").
 		Where("kitchen_id = ? AND ingredient_id = ?", kitchenID, ingredientID).
 		First(&stock).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tồn kho"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy thông tin tồn kho"})
-		}
+		apperr.Respond(c, dbError(err, apperr.CodeStockNotFound))
 		return
 	}
 
@@ -201,13 +154,16 @@ func (h *InventoryStockHandler) UpdateStockLevels(c *gin.Context) {
 
 	var req UpdateStockLevelsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	var stock models.InventoryStock
-	if err := h.DB.Where("stock_id = ?", stockID).First(&stock).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Không tìm thấy tồn kho"})
+	if err := h.DB.WithContext(c).Where("stock_id = ?", stockID).First(&stock).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeStockNotFound))
+		return
+	}
+	if !authorizeKitchen(c, stock.KitchenID) {
 		return
 	}
 
@@ -216,12 +172,12 @@ func (h *InventoryStockHandler) UpdateStockLevels(c *gin.Context) {
 		"max_stock_level": req.MaxStockLevel,
 	}
 
-	if err := h.DB.Model(&stock).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi cập nhật mức tồn"})
+	if err := h.DB.WithContext(c).Model(&stock).Updates(updates).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	h.DB.Preload("Kitchen").Preload("Ingredient").First(&stock, "stock_id = ?", stockID)
+	h.DB.WithContext(c).Preload("Kitchen").Preload("Ingredient").First(&stock, "stock_id = ?", stockID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Cập nhật mức tồn thành công",
@@ -234,18 +190,17 @@ func (h *InventoryStockHandler) GetLowStockAlerts(c *gin.Context) {
 	kitchenID := c.Query("kitchen_id")
 
 	var stocks []models.InventoryStock
-	query := h.DB.Model(&models.InventoryStock{}).
-		Where("min_stock_level IS NOT NULL AND quantity < min_stock_level")
-
-	if kitchenID != "" {
-		query = query.Where("kitchen_id = ?", kitchenID)
+	query, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.InventoryStock{}), "kitchen_id", kitchenID)
+	if !ok {
+		return
 	}
+	query = query.Where("min_stock_level IS NOT NULL AND quantity < min_stock_level")
 
 	if err := query.Preload("Kitchen").
 		Preload("Ingredient").
 		Order("(quantity / NULLIF(min_stock_level, 0))").
 		Find(&stocks).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy cảnh báo tồn kho"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -259,7 +214,7 @@ func (h *InventoryStockHandler) GetLowStockAlerts(c *gin.Context) {
 func (h *InventoryStockHandler) GetStockTransactions(c *gin.Context) {
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -278,14 +233,17 @@ func (h *InventoryStockHandler) GetStockTransactions(c *gin.Context) {
 	toDate := c.Query("to_date")
 
 	if kitchenID == "" || ingredientID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id và ingredient_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required").Field("ingredient_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 
 	var transactions []models.InventoryTransaction
 	var total int64
 
-	countQuery := h.DB.Model(&models.InventoryTransaction{}).
+	countQuery := h.DB.WithContext(c).Model(&models.InventoryTransaction{}).
 		Where("kitchen_id = ? AND ingredient_id = ?", kitchenID, ingredientID)
 
 	if transactionType != "" {
@@ -299,11 +257,11 @@ func (h *InventoryStockHandler) GetStockTransactions(c *gin.Context) {
 	}
 
 	if err := countQuery.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi đếm giao dịch"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
-	query := h.DB.Model(&models.InventoryTransaction{}).
+	query := h.DB.WithContext(c).Model(&models.InventoryTransaction{}).
 		Where("kitchen_id = ? AND ingredient_id = ?", kitchenID, ingredientID)
 
 	if transactionType != "" {
@@ -331,7 +289,7 @@ func (h *InventoryStockHandler) GetStockTransactions(c *gin.Context) {
 		Preload("Ingredient").
 		Preload("CreatedBy").
 		Find(&transactions).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi lấy lịch sử giao dịch"})
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -347,7 +305,10 @@ func (h *InventoryStockHandler) GetStockSummary(c *gin.Context) {
 	kitchenID := c.Query("kitchen_id")
 
 	if kitchenID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 
@@ -361,23 +322,23 @@ func (h *InventoryStockHandler) GetStockSummary(c *gin.Context) {
 	var summary Summary
 
 	// Total items
-	h.DB.Model(&models.InventoryStock{}).
+	h.DB.WithContext(c).Model(&models.InventoryStock{}).
 		Where("kitchen_id = ?", kitchenID).
 		Count(&summary.TotalItems)
 
 	// Low stock items
-	h.DB.Model(&models.InventoryStock{}).
+	h.DB.WithContext(c).Model(&models.InventoryStock{}).
 		Where("kitchen_id = ? AND min_stock_level IS NOT NULL AND quantity < min_stock_level", kitchenID).
 		Count(&summary.LowStockItems)
 
 	// Out of stock items
-	h.DB.Model(&models.InventoryStock{}).
+	h.DB.WithContext(c).Model(&models.InventoryStock{}).
 		Where("kitchen_id = ? AND quantity = 0", kitchenID).
 		Count(&summary.OutOfStockItems)
 
 	// Total value - would need to join with latest prices
 	// This is a simplified version
-	h.DB.Model(&models.InventoryStock{}).
+	h.DB.WithContext(c).Model(&models.InventoryStock{}).
 		Select("COALESCE(SUM(quantity), 0)").
 		Where("kitchen_id = ?", kitchenID).
 		Scan(&summary.TotalValue)
@@ -390,7 +351,10 @@ func (h *InventoryStockHandler) GetStockValuation(c *gin.Context) {
 	kitchenID := c.Query("kitchen_id")
 
 	if kitchenID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cần có kitchen_id"})
+		apperr.Respond(c, apperr.Invalid("kitchen_id", "required"))
+		return
+	}
+	if !authorizeKitchen(c, kitchenID) {
 		return
 	}
 
@@ -405,6 +369,7 @@ func (h *InventoryStockHandler) GetStockValuation(c *gin.Context) {
 
 	var items []ValuationItem
 
+	// Only confirmed supplier products price the stock, as in supplier selection
 	query := `
 		SELECT 
 			s.ingredient_id,
@@ -415,14 +380,14 @@ func (h *InventoryStockHandler) GetStockValuation(c *gin.Context) {
 			s.quantity * COALESCE(AVG(p.unit_price), 0) as total_value
 		FROM inventory_stocks s
 		JOIN master_ingredients i ON i.ingredient_id = s.ingredient_id
-		LEFT JOIN supplier_price_list p ON p.ingredient_id = s.ingredient_id AND p.active = true
+		LEFT JOIN supplier_price_list p ON p.ingredient_id = s.ingredient_id AND p.active = true AND p.mapping_status = ?
 		WHERE s.kitchen_id = ?
 		GROUP BY s.ingredient_id, i.ingredient_name, s.quantity, s.unit
 		ORDER BY total_value DESC
 	`
 
-	if err := h.DB.Raw(query, kitchenID).Scan(&items).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Lỗi khi tính giá trị tồn kho"})
+	if err := h.DB.WithContext(c).Raw(query, models.MappingStatusConfirmed, kitchenID).Scan(&items).Error; err != nil {
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
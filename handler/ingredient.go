@@ -1,23 +1,32 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/logger"
 	"adong-be/models"
-	"adong-be/store"
 	"adong-be/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type IngredientHandler struct {
+	DB *gorm.DB
+}
+
+func NewIngredientHandler(db *gorm.DB) *IngredientHandler {
+	return &IngredientHandler{DB: db}
+}
+
 // GetIngredients with pagination and search - Returns ResourceCollection format
-func GetIngredients(c *gin.Context) {
+func (h *IngredientHandler) GetIngredients(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetIngredients called", "user_id", uid)
+	logger.From(c).Info("GetIngredients called", "user_id", uid)
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		logger.Log.Error("GetIngredients bind query error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetIngredients bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 	params = models.GetPaginationParams(
@@ -29,7 +38,7 @@ func GetIngredients(c *gin.Context) {
 	)
 
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.Ingredient{})
+	countDB := h.DB.WithContext(c).Model(&models.Ingredient{})
 
 	searchConfig := utils.SearchConfig{
 		Fields: []string{"ingredient_name", "ingredient_id"},
@@ -38,13 +47,13 @@ func GetIngredients(c *gin.Context) {
 	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetIngredients count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetIngredients count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var items []models.Ingredient
-	db := store.DB.GormClient.Model(&models.Ingredient{})
+	db := h.DB.WithContext(c).Model(&models.Ingredient{})
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
 	allowedSortFields := map[string]string{
@@ -57,8 +66,8 @@ func GetIngredients(c *gin.Context) {
 	db = utils.ApplyPagination(db, params.Page, params.PageSize)
 
 	if err := db.Find(&items).Error; err != nil {
-		logger.Log.Error("GetIngredients query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetIngredients query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -69,66 +78,66 @@ func GetIngredients(c *gin.Context) {
 	})
 }
 
-func GetIngredient(c *gin.Context) {
+func (h *IngredientHandler) GetIngredient(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetIngredient called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("GetIngredient called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
 	var item models.Ingredient
-	if err := store.DB.GormClient.First(&item, "ingredient_id = ?", id).Error; err != nil {
-		logger.Log.Error("GetIngredient not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
+	if err := h.DB.WithContext(c).First(&item, "ingredient_id = ?", id).Error; err != nil {
+		logger.From(c).Error("GetIngredient not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientNotFound))
 		return
 	}
 	c.JSON(http.StatusOK, item)
 }
 
-func CreateIngredient(c *gin.Context) {
+func (h *IngredientHandler) CreateIngredient(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("CreateIngredient called", "user_id", uid)
+	logger.From(c).Info("CreateIngredient called", "user_id", uid)
 	var item models.Ingredient
 	if err := c.ShouldBindJSON(&item); err != nil {
-		logger.Log.Error("CreateIngredient bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("CreateIngredient bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-	if err := store.DB.GormClient.Create(&item).Error; err != nil {
-		logger.Log.Error("CreateIngredient db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Create(&item).Error; err != nil {
+		logger.From(c).Error("CreateIngredient db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusCreated, item)
 }
 
-func UpdateIngredient(c *gin.Context) {
+func (h *IngredientHandler) UpdateIngredient(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("UpdateIngredient called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("UpdateIngredient called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
 	var item models.Ingredient
-	if err := store.DB.GormClient.First(&item, "ingredient_id = ?", id).Error; err != nil {
-		logger.Log.Error("UpdateIngredient not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found"})
+	if err := h.DB.WithContext(c).First(&item, "ingredient_id = ?", id).Error; err != nil {
+		logger.From(c).Error("UpdateIngredient not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeIngredientNotFound))
 		return
 	}
 	if err := c.ShouldBindJSON(&item); err != nil {
-		logger.Log.Error("UpdateIngredient bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("UpdateIngredient bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-	if err := store.DB.GormClient.Save(&item).Error; err != nil {
-		logger.Log.Error("UpdateIngredient db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Save(&item).Error; err != nil {
+		logger.From(c).Error("UpdateIngredient db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, item)
 }
 
-func DeleteIngredient(c *gin.Context) {
+func (h *IngredientHandler) DeleteIngredient(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("DeleteIngredient called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("DeleteIngredient called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-	if err := store.DB.GormClient.Delete(&models.Ingredient{}, "ingredient_id = ?", id).Error; err != nil {
-		logger.Log.Error("DeleteIngredient db error", "id", id, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Delete(&models.Ingredient{}, "ingredient_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteIngredient db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Ingredient deleted successfully"})
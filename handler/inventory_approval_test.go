@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"adong-be/etag"
+	"adong-be/models"
+	"adong-be/rbac"
+	"adong-be/repository"
+	"adong-be/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// approvalRouter serves the approval routes over inventory kept in memory, as a storekeeper of K001
+func approvalRouter(repo *repository.MemoryInventory) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("identity", "storekeeper01")
+		c.Set(rbac.ScopeKey, rbac.Scope{KitchenIDs: []string{"K001"}})
+	})
+
+	inventory := service.NewInventory(repo)
+	r.PUT("/imports/:id/approve", NewInventoryImportHandler(nil, inventory).ApproveImport)
+	r.PUT("/exports/:id/approve", NewInventoryExportHandler(nil, inventory).ApproveExport)
+	r.PUT("/adjustments/:id/approve", NewInventoryAdjustmentHandler(nil, inventory).ApproveAdjustment)
+	return r
+}
+
+func TestInventoryApproval(t *testing.T) {
+	tests := []struct {
+		name      string
+		path      string
+		ifMatch   string
+		wantCode  int
+		wantError string
+		wantStock float64
+	}{
+		{"approve import", "/imports/NK001/approve", `"2"`, http.StatusOK, "", 15},
+		{"import already approved", "/imports/NK002/approve", `"1"`, http.StatusBadRequest, "DOCUMENT_ALREADY_APPROVED", 10},
+		{"import of another kitchen", "/imports/NK003/approve", `"1"`, http.StatusForbidden, "KITCHEN_FORBIDDEN", 10},
+		{"missing import", "/imports/NK999/approve", `"1"`, http.StatusNotFound, "IMPORT_NOT_FOUND", 10},
+		{"import changed since read", "/imports/NK001/approve", `"1"`, http.StatusPreconditionFailed, "VERSION_MISMATCH", 10},
+		{"import without If-Match", "/imports/NK001/approve", "", http.StatusPreconditionRequired, "PRECONDITION_REQUIRED", 10},
+		{"approve export", "/exports/XK001/approve", `"1"`, http.StatusOK, "", 6},
+		{"export above stock", "/exports/XK002/approve", `"1"`, http.StatusBadRequest, "STOCK_INSUFFICIENT", 10},
+		{"approve adjustment", "/adjustments/DC001/approve", "*", http.StatusOK, "", 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewMemoryInventory(repository.InventoryData{
+				Imports: []models.InventoryImport{
+					{ImportID: "NK001", KitchenID: "K001", Status: "draft", Version: 2, ImportDetails: []models.InventoryImportDetail{{IngredientID: "NL001", Quantity: 5, Unit: "kg"}}},
+					{ImportID: "NK002", KitchenID: "K001", Status: "approved", Version: 1, ImportDetails: []models.InventoryImportDetail{{IngredientID: "NL001", Quantity: 5, Unit: "kg"}}},
+					{ImportID: "NK003", KitchenID: "K002", Status: "draft", Version: 1, ImportDetails: []models.InventoryImportDetail{{IngredientID: "NL001", Quantity: 5, Unit: "kg"}}},
+				},
+				Exports: []models.InventoryExport{
+					{ExportID: "XK001", KitchenID: "K001", ExportType: "usage", Status: "draft", Version: 1, ExportDetails: []models.InventoryExportDetail{{IngredientID: "NL001", Quantity: 4, Unit: "kg"}}},
+					{ExportID: "XK002", KitchenID: "K001", ExportType: "usage", Status: "draft", Version: 1, ExportDetails: []models.InventoryExportDetail{{IngredientID: "NL001", Quantity: 11, Unit: "kg"}}},
+				},
+				Adjustments: []models.InventoryAdjustment{
+					{AdjustmentID: "DC001", KitchenID: "K001", Status: "draft", Version: 1, AdjustmentDetails: []models.InventoryAdjustmentDetail{{IngredientID: "NL001", QuantityBefore: 10, QuantityAfter: 8, QuantityDifference: -2, Unit: "kg"}}},
+				},
+				Stocks: []models.InventoryStock{{KitchenID: "K001", IngredientID: "NL001", Quantity: 10, Unit: "kg"}},
+			})
+
+			req := httptest.NewRequest("PUT", tt.path, nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			approvalRouter(repo).ServeHTTP(w, req)
+
+			require.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			if tt.wantError != "" {
+				assert.Equal(t, tt.wantError, body["code"])
+			}
+			if tt.wantCode == http.StatusOK {
+				data := body["data"].(map[string]interface{})
+				assert.Equal(t, "approved", data["status"])
+				assert.Equal(t, "storekeeper01", data["approvedByUserId"])
+				assert.Equal(t, etag.Format(int(data["version"].(float64))), w.Header().Get("ETag"))
+			}
+			if tt.wantCode == http.StatusPreconditionFailed {
+				assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+				current := body["details"].(map[string]interface{})["current"].(map[string]interface{})
+				assert.Equal(t, "draft", current["status"], "the current state to show before trying again")
+			}
+
+			stock, err := repo.GetStock(req.Context(), "K001", "NL001")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStock, stock.Quantity)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"adong-be/audit"
+	"adong-be/models"
+	"adong-be/rbac"
+	"adong-be/repository"
+	"adong-be/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// auditEntries records the audit log entries written on db. A dry run affects no rows, so
+// creates are counted as one row for the audit callback to log them.
+func auditEntries(t *testing.T, db *gorm.DB) *[]models.AuditLog {
+	t.Helper()
+	require.NoError(t, audit.Register(db))
+	var entries []models.AuditLog
+	require.NoError(t, db.Callback().Create().After("gorm:create").Before("audit:after_create").
+		Register("test:rows_affected", func(tx *gorm.DB) { tx.RowsAffected = 1 }))
+	require.NoError(t, db.Callback().Create().After("gorm:create").Register("test:audit_entries", func(tx *gorm.DB) {
+		if written, ok := tx.Statement.Dest.(*[]models.AuditLog); ok {
+			entries = append(entries, *written...)
+		}
+	}))
+	return &entries
+}
+
+func TestCreateImport_AuditLogNamesTheCaller(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dryRunDB(t)
+	entries := auditEntries(t, db)
+
+	r := gin.New()
+	r.Use(audit.Middleware())
+	r.Use(func(c *gin.Context) {
+		c.Set("identity", "storekeeper01")
+		c.Set(rbac.ScopeKey, rbac.Scope{KitchenIDs: []string{"K001"}})
+	})
+	imports := NewInventoryImportHandler(db, service.NewInventory(repository.NewPostgresInventory(db)))
+	r.POST("/imports", imports.CreateImport)
+
+	body := `{"kitchenId":"K001","importDate":"2024-05-20","importDetails":[{"ingredientId":"NL001","quantity":1,"unitPrice":1000}]}`
+	req := httptest.NewRequest("POST", "/imports", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(audit.RequestIDHeader, "req-1")
+	req.Header.Set("User-Agent", "pos")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	require.NotEmpty(t, *entries)
+	for _, entry := range *entries {
+		require.NotNil(t, entry.ActorUserID, entry.EntityType)
+		assert.Equal(t, "storekeeper01", *entry.ActorUserID)
+		assert.Equal(t, "req-1", entry.RequestID)
+		assert.Equal(t, "pos", entry.UserAgent)
+		assert.NotEmpty(t, entry.IPAddress)
+	}
+}
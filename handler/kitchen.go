@@ -1,24 +1,33 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/logger"
 	"adong-be/models"
-	"adong-be/store"
 	"adong-be/utils"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type KitchenHandler struct {
+	DB *gorm.DB
+}
+
+func NewKitchenHandler(db *gorm.DB) *KitchenHandler {
+	return &KitchenHandler{DB: db}
+}
+
 // GetKitchens with pagination and search - Returns ResourceCollection format
-func GetKitchens(c *gin.Context) {
+func (h *KitchenHandler) GetKitchens(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetKitchens called", "user_id", uid)
+	logger.From(c).Info("GetKitchens called", "user_id", uid)
 
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		logger.Log.Error("GetKitchens bind query error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetKitchens bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -31,7 +40,7 @@ func GetKitchens(c *gin.Context) {
 	)
 
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.Kitchen{})
+	countDB := h.DB.WithContext(c).Model(&models.Kitchen{})
 
 	searchConfig := utils.SearchConfig{
 		Fields: []string{"kitchen_name", "kitchen_id", "address"},
@@ -40,13 +49,13 @@ func GetKitchens(c *gin.Context) {
 	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetKitchens count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetKitchens count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var items []models.Kitchen
-	db := store.DB.GormClient.Model(&models.Kitchen{})
+	db := h.DB.WithContext(c).Model(&models.Kitchen{})
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
 	allowedSortFields := map[string]string{
@@ -59,8 +68,8 @@ func GetKitchens(c *gin.Context) {
 	db = utils.ApplyPagination(db, params.Page, params.PageSize)
 
 	if err := db.Find(&items).Error; err != nil {
-		logger.Log.Error("GetKitchens query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetKitchens query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -71,14 +80,14 @@ func GetKitchens(c *gin.Context) {
 	})
 }
 
-func GetKitchen(c *gin.Context) {
+func (h *KitchenHandler) GetKitchen(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetKitchen called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("GetKitchen called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
 	var item models.Kitchen
-	if err := store.DB.GormClient.First(&item, "kitchen_id = ?", id).Error; err != nil {
-		logger.Log.Error("GetKitchen not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen not found"})
+	if err := h.DB.WithContext(c).First(&item, "kitchen_id = ?", id).Error; err != nil {
+		logger.From(c).Error("GetKitchen not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeKitchenNotFound))
 		return
 	}
 	c.JSON(http.StatusOK, item)
@@ -86,24 +95,24 @@ func GetKitchen(c *gin.Context) {
 
 // GetMyKitchens returns the list of kitchens the current user can access.
 // Admin users receive all kitchens, other roles only their assigned kitchens.
-func GetMyKitchens(c *gin.Context) {
+func (h *KitchenHandler) GetMyKitchens(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetMyKitchens called", "user_id", uid)
+	logger.From(c).Info("GetMyKitchens called", "user_id", uid)
 
 	scope, err := utils.GetUserKitchenScope(c)
 	if err != nil {
-		logger.Log.Error("GetMyKitchens auth scope error", "error", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		logger.From(c).Error("GetMyKitchens auth scope error", "error", err)
+		apperr.Respond(c, apperr.New(apperr.CodeUnauthorized))
 		return
 	}
 
 	var kitchens []models.Kitchen
-	db := store.DB.GormClient.Model(&models.Kitchen{})
+	db := h.DB.WithContext(c).Model(&models.Kitchen{})
 
 	if scope.IsAdmin {
 		if err := db.Find(&kitchens).Error; err != nil {
-			logger.Log.Error("GetMyKitchens query error (admin)", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			logger.From(c).Error("GetMyKitchens query error (admin)", "error", err)
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	} else {
@@ -112,8 +121,8 @@ func GetMyKitchens(c *gin.Context) {
 			return
 		}
 		if err := db.Where("kitchen_id IN ?", scope.KitchenIDs).Find(&kitchens).Error; err != nil {
-			logger.Log.Error("GetMyKitchens query error", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			logger.From(c).Error("GetMyKitchens query error", "error", err)
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
@@ -121,53 +130,53 @@ func GetMyKitchens(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": kitchens})
 }
 
-func CreateKitchen(c *gin.Context) {
+func (h *KitchenHandler) CreateKitchen(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("CreateKitchen called", "user_id", uid)
+	logger.From(c).Info("CreateKitchen called", "user_id", uid)
 	var item models.Kitchen
 	if err := c.ShouldBindJSON(&item); err != nil {
-		logger.Log.Error("CreateKitchen bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("CreateKitchen bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-	if err := store.DB.GormClient.Create(&item).Error; err != nil {
-		logger.Log.Error("CreateKitchen db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Create(&item).Error; err != nil {
+		logger.From(c).Error("CreateKitchen db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusCreated, item)
 }
 
-func UpdateKitchen(c *gin.Context) {
+func (h *KitchenHandler) UpdateKitchen(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("UpdateKitchen called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("UpdateKitchen called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
 	var item models.Kitchen
-	if err := store.DB.GormClient.First(&item, "kitchen_id = ?", id).Error; err != nil {
-		logger.Log.Error("UpdateKitchen not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen not found"})
+	if err := h.DB.WithContext(c).First(&item, "kitchen_id = ?", id).Error; err != nil {
+		logger.From(c).Error("UpdateKitchen not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeKitchenNotFound))
 		return
 	}
 	if err := c.ShouldBindJSON(&item); err != nil {
-		logger.Log.Error("UpdateKitchen bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("UpdateKitchen bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-	if err := store.DB.GormClient.Save(&item).Error; err != nil {
-		logger.Log.Error("UpdateKitchen db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Save(&item).Error; err != nil {
+		logger.From(c).Error("UpdateKitchen db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, item)
 }
 
-func DeleteKitchen(c *gin.Context) {
+func (h *KitchenHandler) DeleteKitchen(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("DeleteKitchen called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("DeleteKitchen called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-	if err := store.DB.GormClient.Delete(&models.Kitchen{}, "kitchen_id = ?", id).Error; err != nil {
-		logger.Log.Error("DeleteKitchen db error", "id", id, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Delete(&models.Kitchen{}, "kitchen_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteKitchen db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Kitchen deleted successfully"})
@@ -178,21 +187,21 @@ func DeleteKitchen(c *gin.Context) {
 // ============================================================================
 
 // GetKitchenFavoriteSuppliers returns all favorite suppliers for a kitchen
-func GetKitchenFavoriteSuppliers(c *gin.Context) {
+func (h *KitchenHandler) GetKitchenFavoriteSuppliers(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	kitchenID := c.Param("id")
-	logger.Log.Info("GetKitchenFavoriteSuppliers called", "kitchen_id", kitchenID, "user_id", uid)
+	logger.From(c).Info("GetKitchenFavoriteSuppliers called", "kitchen_id", kitchenID, "user_id", uid)
 
 	// Validate kitchen exists
 	var kitchen models.Kitchen
-	if err := store.DB.GormClient.First(&kitchen, "kitchen_id = ?", kitchenID).Error; err != nil {
-		logger.Log.Error("GetKitchenFavoriteSuppliers kitchen not found", "kitchen_id", kitchenID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen not found"})
+	if err := h.DB.WithContext(c).First(&kitchen, "kitchen_id = ?", kitchenID).Error; err != nil {
+		logger.From(c).Error("GetKitchenFavoriteSuppliers kitchen not found", "kitchen_id", kitchenID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeKitchenNotFound))
 		return
 	}
 
 	var favorites []models.KitchenFavoriteSupplier
-	query := store.DB.GormClient.
+	query := h.DB.WithContext(c).
 		Where("kitchen_id = ?", kitchenID).
 		Preload("Supplier").
 		Preload("CreatedBy")
@@ -201,16 +210,16 @@ func GetKitchenFavoriteSuppliers(c *gin.Context) {
 	query = query.Order("COALESCE(display_order, 999999), created_date ASC")
 
 	if err := query.Find(&favorites).Error; err != nil {
-		logger.Log.Error("GetKitchenFavoriteSuppliers db error", "kitchen_id", kitchenID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetKitchenFavoriteSuppliers db error", "kitchen_id", kitchenID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Count total favorites for meta info
 	var total int64
-	if err := store.DB.GormClient.Model(&models.KitchenFavoriteSupplier{}).Where("kitchen_id = ?", kitchenID).Count(&total).Error; err != nil {
-		logger.Log.Error("GetKitchenFavoriteSuppliers count error", "kitchen_id", kitchenID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Model(&models.KitchenFavoriteSupplier{}).Where("kitchen_id = ?", kitchenID).Count(&total).Error; err != nil {
+		logger.From(c).Error("GetKitchenFavoriteSuppliers count error", "kitchen_id", kitchenID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -223,21 +232,21 @@ func GetKitchenFavoriteSuppliers(c *gin.Context) {
 }
 
 // GetKitchenFavoriteSupplier returns a single favorite supplier by ID
-func GetKitchenFavoriteSupplier(c *gin.Context) {
+func (h *KitchenHandler) GetKitchenFavoriteSupplier(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	kitchenID := c.Param("id")
 	favoriteID := c.Param("favoriteId")
-	logger.Log.Info("GetKitchenFavoriteSupplier called", "kitchen_id", kitchenID, "favorite_id", favoriteID, "user_id", uid)
+	logger.From(c).Info("GetKitchenFavoriteSupplier called", "kitchen_id", kitchenID, "favorite_id", favoriteID, "user_id", uid)
 
 	var favorite models.KitchenFavoriteSupplier
-	if err := store.DB.GormClient.
+	if err := h.DB.WithContext(c).
 		Where("favorite_id = ? AND kitchen_id = ?", favoriteID, kitchenID).
 		Preload("Kitchen").
 		Preload("Supplier").
 		Preload("CreatedBy").
 		First(&favorite).Error; err != nil {
-		logger.Log.Error("GetKitchenFavoriteSupplier not found", "kitchen_id", kitchenID, "favorite_id", favoriteID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Favorite supplier not found"})
+		logger.From(c).Error("GetKitchenFavoriteSupplier not found", "kitchen_id", kitchenID, "favorite_id", favoriteID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeFavoriteSupplierNotFound))
 		return
 	}
 
@@ -245,10 +254,10 @@ func GetKitchenFavoriteSupplier(c *gin.Context) {
 }
 
 // CreateKitchenFavoriteSupplier adds a supplier to a kitchen's favorites
-func CreateKitchenFavoriteSupplier(c *gin.Context) {
+func (h *KitchenHandler) CreateKitchenFavoriteSupplier(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	kitchenID := c.Param("id")
-	logger.Log.Info("CreateKitchenFavoriteSupplier called", "kitchen_id", kitchenID, "user_id", uid)
+	logger.From(c).Info("CreateKitchenFavoriteSupplier called", "kitchen_id", kitchenID, "user_id", uid)
 
 	// Get user ID from authentication middleware
 	var userID string
@@ -260,8 +269,8 @@ func CreateKitchenFavoriteSupplier(c *gin.Context) {
 
 	var favorite models.KitchenFavoriteSupplier
 	if err := c.ShouldBindJSON(&favorite); err != nil {
-		logger.Log.Error("CreateKitchenFavoriteSupplier bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("CreateKitchenFavoriteSupplier bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -271,37 +280,37 @@ func CreateKitchenFavoriteSupplier(c *gin.Context) {
 
 	// Validate kitchen exists
 	var kitchen models.Kitchen
-	if err := store.DB.GormClient.First(&kitchen, "kitchen_id = ?", kitchenID).Error; err != nil {
-		logger.Log.Error("CreateKitchenFavoriteSupplier kitchen not found", "kitchen_id", kitchenID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen not found"})
+	if err := h.DB.WithContext(c).First(&kitchen, "kitchen_id = ?", kitchenID).Error; err != nil {
+		logger.From(c).Error("CreateKitchenFavoriteSupplier kitchen not found", "kitchen_id", kitchenID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeKitchenNotFound))
 		return
 	}
 
 	// Validate supplier exists
 	var supplier models.Supplier
-	if err := store.DB.GormClient.First(&supplier, "supplier_id = ?", favorite.SupplierID).Error; err != nil {
-		logger.Log.Error("CreateKitchenFavoriteSupplier supplier not found", "supplier_id", favorite.SupplierID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found"})
+	if err := h.DB.WithContext(c).First(&supplier, "supplier_id = ?", favorite.SupplierID).Error; err != nil {
+		logger.From(c).Error("CreateKitchenFavoriteSupplier supplier not found", "supplier_id", favorite.SupplierID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeSupplierNotFound))
 		return
 	}
 
 	// Check if favorite already exists (unique constraint: kitchen_id + supplier_id)
 	var existing models.KitchenFavoriteSupplier
-	if err := store.DB.GormClient.Where("kitchen_id = ? AND supplier_id = ?", kitchenID, favorite.SupplierID).First(&existing).Error; err == nil {
-		logger.Log.Error("CreateKitchenFavoriteSupplier duplicate favorite", "kitchen_id", kitchenID, "supplier_id", favorite.SupplierID)
-		c.JSON(http.StatusConflict, gin.H{"error": "This supplier is already in the kitchen's favorites"})
+	if err := h.DB.WithContext(c).Where("kitchen_id = ? AND supplier_id = ?", kitchenID, favorite.SupplierID).First(&existing).Error; err == nil {
+		logger.From(c).Error("CreateKitchenFavoriteSupplier duplicate favorite", "kitchen_id", kitchenID, "supplier_id", favorite.SupplierID)
+		apperr.Respond(c, apperr.New(apperr.CodeFavoriteSupplierExists))
 		return
 	}
 
 	// Create favorite
-	if err := store.DB.GormClient.Create(&favorite).Error; err != nil {
-		logger.Log.Error("CreateKitchenFavoriteSupplier db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Create(&favorite).Error; err != nil {
+		logger.From(c).Error("CreateKitchenFavoriteSupplier db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload with relations
-	store.DB.GormClient.
+	h.DB.WithContext(c).
 		Preload("Kitchen").
 		Preload("Supplier").
 		Preload("CreatedBy").
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/repository"
+	"adong-be/utils"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// kitchenScope loads the kitchens the caller may access for the current route,
+// writing 401 when it can't be determined
+func kitchenScope(c *gin.Context) (*utils.UserKitchenScope, bool) {
+	scope, err := utils.GetUserKitchenScope(c)
+	if err != nil {
+		logger.From(c).Error("kitchen scope error", "path", c.FullPath(), "error", err)
+		apperr.Respond(c, apperr.Wrap(apperr.CodeUnauthorized, err))
+		return nil, false
+	}
+	return scope, true
+}
+
+// authorizeKitchen writes 403 unless the caller may access kitchenID on the current route
+func authorizeKitchen(c *gin.Context, kitchenID string) bool {
+	scope, ok := kitchenScope(c)
+	if !ok {
+		return false
+	}
+	if !scope.Allows(kitchenID) {
+		kitchenDenied(c, kitchenID)
+		return false
+	}
+	return true
+}
+
+// scopeQuery restricts db on column to the caller's kitchens, or to kitchenID when given.
+// It writes 401/403 and returns false when the query must not run.
+func scopeQuery(c *gin.Context, db *gorm.DB, column, kitchenID string) (*gorm.DB, bool) {
+	scope, ok := kitchenScope(c)
+	if !ok {
+		return nil, false
+	}
+	scoped, err := scope.Filter(db, column, kitchenID)
+	if errors.Is(err, utils.ErrKitchenForbidden) {
+		apperr.Respond(c, apperr.Wrap(apperr.CodeKitchenForbidden, err))
+		return nil, false
+	}
+	return scoped, true
+}
+
+// authorizeOrder checks that orderID exists (404) and belongs to one of the caller's kitchens (403)
+func authorizeOrder(c *gin.Context, db *gorm.DB, orderID string) bool {
+	var order models.Order
+	if err := db.Select("order_id", "kitchen_id").First(&order, "order_id = ?", orderID).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
+		return false
+	}
+	return authorizeKitchen(c, order.KitchenID)
+}
+
+// callerKitchens returns the kitchens the caller may access on the current route, narrowed
+// to kitchenID when given. It writes 401/403 and returns false when the request must stop.
+func callerKitchens(c *gin.Context, kitchenID string) (repository.Kitchens, bool) {
+	scope, ok := kitchenScope(c)
+	if !ok {
+		return repository.Kitchens{}, false
+	}
+	kitchens := repository.Kitchens{All: scope.IsAdmin, IDs: scope.KitchenIDs}
+	if kitchenID == "" {
+		return kitchens, true
+	}
+	if kitchens, ok = kitchens.Only(kitchenID); !ok {
+		kitchenDenied(c, kitchenID)
+		return repository.Kitchens{}, false
+	}
+	return kitchens, true
+}
+
+// kitchenDenied writes the 403 of a kitchen outside the caller's scope
+func kitchenDenied(c *gin.Context, kitchenID string) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Warn("kitchen access denied", "kitchen_id", kitchenID, "user_id", uid, "path", c.FullPath())
+	apperr.Respond(c, apperr.New(apperr.CodeKitchenForbidden).With("kitchen_id", kitchenID))
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"adong-be/models"
+	"adong-be/rbac"
+	"adong-be/repository"
+	"adong-be/service"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds SQL without connecting, so handlers run up to their queries without a database
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: &dryRunConn{}}),
+		&gorm.Config{DisableAutomaticPing: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("open dry-run db: %v", err)
+	}
+	return db
+}
+
+// dryRunConn is the connection of a dry run. Transactions begin and commit, statements are
+// never sent.
+type dryRunConn struct{}
+
+var errDryRun = errors.New("dry run has no database")
+
+func (*dryRunConn) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, errDryRun
+}
+
+func (*dryRunConn) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, errDryRun
+}
+
+func (*dryRunConn) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, errDryRun
+}
+
+func (*dryRunConn) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func (c *dryRunConn) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return c, nil
+}
+
+func (*dryRunConn) Commit() error   { return nil }
+func (*dryRunConn) Rollback() error { return nil }
+
+// scopedRouter serves requests as a user holding the route permission in K001 only
+func scopedRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	db := dryRunDB(t)
+
+	// A recipe standard read by its ID belongs to K002
+	err := db.Callback().Query().After("gorm:query").Register("test:kitchen", func(tx *gorm.DB) {
+		if recipe, ok := tx.Statement.Dest.(*models.RecipeStandard); ok {
+			recipe.KitchenID = "K002"
+		}
+	})
+	if err != nil {
+		t.Fatalf("register callback: %v", err)
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("identity", "storekeeper01")
+		c.Set(rbac.ScopeKey, rbac.Scope{KitchenIDs: []string{"K001"}})
+	})
+
+	inventory := service.NewInventory(repository.NewPostgresInventory(db))
+	imports := NewInventoryImportHandler(db, inventory)
+	r.GET("/imports", imports.GetAllImports)
+	r.POST("/imports", imports.CreateImport)
+	exports := NewInventoryExportHandler(db, inventory)
+	r.GET("/exports", exports.GetAllExports)
+	adjustments := NewInventoryAdjustmentHandler(db, inventory)
+	r.GET("/adjustments", adjustments.GetAllAdjustments)
+	requests := NewIngredientRequestHandler(db)
+	r.GET("/requests", requests.GetAllRequests)
+	stock := NewInventoryStockHandler(db)
+	r.GET("/stocks", stock.GetAllStocks)
+	r.GET("/stocks/summary", stock.GetStockSummary)
+	r.GET("/stocks/alerts/low", stock.GetLowStockAlerts)
+	reports := NewInventoryReportsHandler(db)
+	r.GET("/reports/stock-movement", reports.GetStockMovementReport)
+	r.GET("/reports/expiry-alerts", reports.GetExpiryAlerts)
+	recipes := NewRecipeStandardHandler(db)
+	r.GET("/recipe-standards", recipes.GetRecipeStandards)
+	r.GET("/recipe-standards/:id", recipes.GetRecipeStandard)
+	r.POST("/recipe-standards", recipes.CreateRecipeStandard)
+	r.POST("/recipe-standards/bulk", recipes.CreateRecipeStandardsBulk)
+	r.PUT("/recipe-standards/:id", recipes.UpdateRecipeStandard)
+	r.DELETE("/recipe-standards/:id", recipes.DeleteRecipeStandard)
+	r.GET("/recipe-standards/kitchen/:kitchenId", recipes.GetRecipeStandardsByKitchen)
+	r.GET("/recipe-standards/dish/:dishId/kitchen/:kitchenId", recipes.GetRecipeStandardsByDishAndKitchen)
+	dishes := NewDishHandler(service.NewRecipes(repository.NewPostgresRecipes(db)))
+	r.GET("/recipe-standards/dish/:dishId", dishes.GetRecipeStandardsByDish)
+	orders := NewOrderHandler(db, service.NewOrders(repository.NewPostgresOrders(db)))
+	r.GET("/orders", orders.GetOrders)
+	return r
+}
+
+func TestKitchenScope_CrossKitchenAccessForbidden(t *testing.T) {
+	r := scopedRouter(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"list imports", "GET", "/imports?kitchen_id=K002", ""},
+		{"create import", "POST", "/imports", `{"kitchenId":"K002","importDate":"2024-05-20","importDetails":[{"ingredientId":"NL001","quantity":1,"unitPrice":1000}]}`},
+		{"list exports", "GET", "/exports?kitchen_id=K002", ""},
+		{"list adjustments", "GET", "/adjustments?kitchen_id=K002", ""},
+		{"list requests", "GET", "/requests?kitchen_id=K002", ""},
+		{"list stocks", "GET", "/stocks?kitchen_id=K002", ""},
+		{"stock summary", "GET", "/stocks/summary?kitchen_id=K002", ""},
+		{"low stock alerts", "GET", "/stocks/alerts/low?kitchen_id=K002", ""},
+		{"stock movement report", "GET", "/reports/stock-movement?kitchen_id=K002&from_date=2024-01-01&to_date=2024-01-31", ""},
+		{"expiry alerts", "GET", "/reports/expiry-alerts?kitchen_id=K002", ""},
+		{"list orders", "GET", "/orders?kitchen_id=K002", ""},
+		{"list recipe standards", "GET", "/recipe-standards?kitchen_id=K002", ""},
+		{"get recipe standard", "GET", "/recipe-standards/7", ""},
+		{"create recipe standard", "POST", "/recipe-standards", `{"dishId":"MA001","kitchenId":"K002","ingredientId":"NL001"}`},
+		{"create recipe standards", "POST", "/recipe-standards/bulk", `[{"dishId":"MA001","kitchenId":"K002","ingredientId":"NL001"}]`},
+		{"update recipe standard", "PUT", "/recipe-standards/7", `{"note":"Bỏ hành"}`},
+		{"delete recipe standard", "DELETE", "/recipe-standards/7", ""},
+		{"recipe standards of a kitchen", "GET", "/recipe-standards/kitchen/K002", ""},
+		{"recipe standards of a dish in a kitchen", "GET", "/recipe-standards/dish/MA001/kitchen/K002", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", "*")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestKitchenScope_OwnKitchenAllowed(t *testing.T) {
+	r := scopedRouter(t)
+
+	for _, path := range []string{
+		"/imports?kitchen_id=K001",
+		"/imports",
+		"/exports?kitchen_id=K001",
+		"/adjustments",
+		"/requests?kitchen_id=K001",
+		"/stocks?kitchen_id=K001",
+		"/orders?kitchen_id=K001",
+		"/recipe-standards",
+		"/recipe-standards/kitchen/K001",
+		"/recipe-standards/dish/MA001",
+	} {
+		t.Run(path, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/auth"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LoginLockHandler lets admins inspect failed logins and lift lockouts
+type LoginLockHandler struct {
+	DB    *gorm.DB
+	Guard *auth.LoginGuard
+}
+
+func NewLoginLockHandler(db *gorm.DB, guard *auth.LoginGuard) *LoginLockHandler {
+	return &LoginLockHandler{DB: db, Guard: guard}
+}
+
+// GetLoginStatus returns the failed login counter of a user and whether they are locked out
+func (h *LoginLockHandler) GetLoginStatus(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetLoginStatus called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var user models.User
+	if err := h.DB.WithContext(c).First(&user, "user_id = ?", id).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeUserNotFound))
+		return
+	}
+	entry, locked, err := h.Guard.Status(c, user.UserName)
+	if err != nil {
+		logger.From(c).Error("GetLoginStatus limiter error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	var lastFailure *time.Time
+	if entry.Failures > 0 {
+		lastFailure = &entry.LastFailure
+	}
+	c.JSON(http.StatusOK, gin.H{"failures": entry.Failures, "lastFailure": lastFailure, "locked": locked})
+}
+
+// UnlockUser clears the failed login counter of a user, ending a lockout
+func (h *LoginLockHandler) UnlockUser(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("UnlockUser called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var user models.User
+	if err := h.DB.WithContext(c).First(&user, "user_id = ?", id).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeUserNotFound))
+		return
+	}
+	if err := h.Guard.Unlock(c, user.UserName); err != nil {
+		logger.From(c).Error("UnlockUser limiter error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	logger.From(c).Info("user login unlocked", "id", id, "by", uid)
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// GetFailedLogins lists rejected login attempts, newest first.
+// Filters: user_name, user_id, ip_address, reason, from_date and to_date (YYYY-MM-DD, inclusive).
+func (h *LoginLockHandler) GetFailedLogins(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetFailedLogins called", "user_id", uid)
+
+	var params models.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	params = models.GetPaginationParams(params.Page, params.PageSize, params.Search, params.SortBy, params.SortDir)
+	// The log grows without bound, so it is always paginated
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.PageSize < 1 {
+		params.PageSize = 50
+	}
+
+	base := h.DB.WithContext(c).Model(&models.FailedLogin{})
+	for _, column := range []string{"user_name", "user_id", "ip_address", "reason"} {
+		if v := c.Query(column); v != "" {
+			base = base.Where(column+" = ?", v)
+		}
+	}
+	if v := c.Query("from_date"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			apperr.Respond(c, apperr.Invalid("from_date", "date", "YYYY-MM-DD"))
+			return
+		}
+		base = base.Where("created_date >= ?", from)
+	}
+	if v := c.Query("to_date"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			apperr.Respond(c, apperr.Invalid("to_date", "date", "YYYY-MM-DD"))
+			return
+		}
+		base = base.Where("created_date < ?", to.AddDate(0, 0, 1))
+	}
+	base = base.Session(&gorm.Session{})
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		logger.From(c).Error("GetFailedLogins count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	if params.SortBy == "" {
+		params.SortBy, params.SortDir = "created_date", "desc"
+	}
+	query := utils.ApplySort(base, params.SortBy, params.SortDir, map[string]string{
+		"created_date": "created_date",
+		"user_name":    "user_name",
+		"ip_address":   "ip_address",
+	})
+	query = utils.ApplyPagination(query, params.Page, params.PageSize)
+
+	var items []models.FailedLogin
+	if err := query.Find(&items).Error; err != nil {
+		logger.From(c).Error("GetFailedLogins query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ResourceCollection{
+		Data: items,
+		Meta: models.CalculatePaginationMeta(params.Page, params.PageSize, total),
+	})
+}
@@ -1,161 +1,64 @@
 package handler
 
 import (
+	"adong-be/apperr"
+	"adong-be/delivery"
 	"adong-be/logger"
 	"adong-be/models"
-	"adong-be/store"
-	"adong-be/utils"
+	"adong-be/repository"
+	"adong-be/selection"
+	"adong-be/service"
 	"errors"
+	"fmt"
 	"net/http"
 	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-func GetOrders(c *gin.Context) {
-	uid, _ := c.Get("identity")
-	logger.Log.Info("GetOrders called", "user_id", uid)
+type OrderHandler struct {
+	DB     *gorm.DB
+	Orders *service.Orders
+}
 
-	// Load user scope for kitchen-based authorization
-	scope, err := utils.GetUserKitchenScope(c)
-	if err != nil {
-		logger.Log.Error("GetOrders auth scope error", "error", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
+func NewOrderHandler(db *gorm.DB, orders *service.Orders) *OrderHandler {
+	return &OrderHandler{DB: db, Orders: orders}
+}
+
+func (h *OrderHandler) GetOrders(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetOrders called", "user_id", uid)
 
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		logger.Log.Error("GetOrders bind query error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetOrders bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	params = models.GetPaginationParams(params.Page, params.PageSize, params.Search, params.SortBy, params.SortDir)
 
-	kitchenID := c.Query("kitchen_id")
-	status := c.Query("status")
-	fromDate := c.Query("from_date")
-	toDate := c.Query("to_date")
-	dishID := c.Query("dish_id")
-	ingredientID := c.Query("ingredient_id")
-
-	var orders []models.Order
-	var total int64
-
-	dataDB := store.DB.GormClient.Model(&models.Order{})
-	countDB := store.DB.GormClient.Model(&models.Order{})
-
-	if dishID != "" || ingredientID != "" {
-		countDB = countDB.Distinct("orders.order_id")
-		dataDB = dataDB.Distinct("orders.order_id")
-	}
-
-	if params.Search != "" {
-		dataDB = dataDB.Where("note ILIKE ? OR order_id ILIKE ?", "%"+params.Search+"%", "%"+params.Search+"%")
-		countDB = countDB.Where("note ILIKE ? OR order_id ILIKE ?", "%"+params.Search+"%", "%"+params.Search+"%")
-	}
-	// Kitchen-based authorization:
-	// - Admin: can optionally filter by any kitchen_id or see all
-	// - Non-admin: can only see orders for their assigned kitchens
-	if scope.IsAdmin {
-		if kitchenID != "" {
-			dataDB = dataDB.Where("kitchen_id = ?", kitchenID)
-			countDB = countDB.Where("kitchen_id = ?", kitchenID)
-		}
-	} else {
-		// Non-admin: restrict to allowed kitchens
-		if len(scope.KitchenIDs) == 0 {
-			// No kitchens assigned -> no data
-			c.JSON(http.StatusOK, models.ResourceCollection{
-				Data: []models.OrderDTO{},
-				Meta: models.CalculatePaginationMeta(params.Page, params.PageSize, 0),
-			})
-			return
-		}
-		if kitchenID != "" {
-			// Ensure requested kitchen is in user's allowed list
-			allowed := false
-			for _, kid := range scope.KitchenIDs {
-				if kid == kitchenID {
-					allowed = true
-					break
-				}
-			}
-			if !allowed {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Access to this kitchen is not allowed"})
-				return
-			}
-			dataDB = dataDB.Where("kitchen_id = ?", kitchenID)
-			countDB = countDB.Where("kitchen_id = ?", kitchenID)
-		} else {
-			// No explicit kitchen_id -> restrict to all user's kitchens
-			dataDB = dataDB.Where("kitchen_id IN ?", scope.KitchenIDs)
-			countDB = countDB.Where("kitchen_id IN ?", scope.KitchenIDs)
-		}
-	}
-	if status != "" {
-		dataDB = dataDB.Where("status = ?", status)
-		countDB = countDB.Where("status = ?", status)
-	}
-	if fromDate != "" {
-		if t, err := time.Parse("2006-01-02", fromDate); err == nil {
-			dataDB = dataDB.Where("order_date >= ?", t)
-			countDB = countDB.Where("order_date >= ?", t)
-		} else {
-			dataDB = dataDB.Where("order_date >= ?", fromDate)
-			countDB = countDB.Where("order_date >= ?", fromDate)
-		}
-	}
-	if toDate != "" {
-		if t, err := time.Parse("2006-01-02", toDate); err == nil {
-			dataDB = dataDB.Where("order_date < ?", t.Add(24*time.Hour))
-			countDB = countDB.Where("order_date < ?", t.Add(24*time.Hour))
-		} else {
-			dataDB = dataDB.Where("order_date <= ?", toDate)
-			countDB = countDB.Where("order_date <= ?", toDate)
-		}
-	}
-	if dishID != "" {
-		dataDB = dataDB.Joins("JOIN order_details od ON od.order_id = orders.order_id").Where("od.dish_id = ?", dishID)
-		countDB = countDB.Joins("JOIN order_details od ON od.order_id = orders.order_id").Where("od.dish_id = ?", dishID)
-	}
-	if ingredientID != "" {
-		dataDB = dataDB.Joins("JOIN order_details od2 ON od2.order_id = orders.order_id").
-			Joins("JOIN order_ingredients oi ON oi.order_detail_id = od2.order_detail_id").
-			Where("oi.ingredient_id = ?", ingredientID)
-		countDB = countDB.Joins("JOIN order_details od2 ON od2.order_id = orders.order_id").
-			Joins("JOIN order_ingredients oi ON oi.order_detail_id = od2.order_detail_id").
-			Where("oi.ingredient_id = ?", ingredientID)
-	}
-
-	if err := countDB.Distinct("orders.order_id").Count(&total).Error; err != nil {
-		logger.Log.Error("GetOrders count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// Kitchen-based authorization: restrict to the kitchens the caller may access,
+	// an explicit kitchen_id outside of them is refused
+	kitchens, ok := callerKitchens(c, c.Query("kitchen_id"))
+	if !ok {
 		return
 	}
-
-	allowedSort := map[string]string{
-		"order_id":     "orders.order_id",
-		"order_date":   "orders.order_date",
-		"status":       "orders.status",
-		"created_date": "orders.created_date",
+	filter := repository.OrderFilter{
+		Kitchens:     kitchens,
+		Status:       c.Query("status"),
+		FromDate:     c.Query("from_date"),
+		ToDate:       c.Query("to_date"),
+		DishID:       c.Query("dish_id"),
+		IngredientID: c.Query("ingredient_id"),
 	}
-	dataDB = utils.ApplySort(dataDB, params.SortBy, params.SortDir, allowedSort)
-	dataDB = utils.ApplyPagination(dataDB, params.Page, params.PageSize)
-
-	if err := dataDB.Select("orders.*").
-		Preload("Kitchen").
-		Preload("CreatedBy").
-		Preload("Details.Dish").
-		Preload("Details.Ingredients.Ingredient").
-		Preload("SupplementaryFoods.Ingredient").
-		Find(&orders).Error; err != nil {
-		logger.Log.Error("GetOrders query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+
+	orders, total, err := h.Orders.List(c, filter, params)
+	if err != nil {
+		logger.From(c).Error("GetOrders query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -168,180 +71,105 @@ func GetOrders(c *gin.Context) {
 	c.JSON(http.StatusOK, models.ResourceCollection{Data: dtos, Meta: meta})
 }
 
-func GetOrder(c *gin.Context) {
+func (h *OrderHandler) GetOrder(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetOrder called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("GetOrder called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-	var order models.Order
-	if err := store.DB.GormClient.
-		Preload("Kitchen").
-		Preload("CreatedBy").
-		Preload("Details.Dish").
-		Preload("Details.Ingredients.Ingredient").
-		Preload("SupplementaryFoods.Ingredient").
-		First(&order, "order_id = ?", id).Error; err != nil {
-		logger.Log.Error("GetOrder not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
+		return
+	}
+	order, err := h.Orders.Get(c, kitchens, id)
+	if err != nil {
+		logger.From(c).Error("GetOrder error", "id", id, "error", err)
+		apperr.Respond(c, serviceError(err, apperr.CodeOrderNotFound))
 		return
 	}
 
-	dto := convertOrderToDTO(&order, true)
+	dto := convertOrderToDTO(order, true)
+	setETag(c, order.Version)
 	c.JSON(http.StatusOK, dto)
 }
 
-func CreateOrder(c *gin.Context) {
+func (h *OrderHandler) CreateOrder(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("CreateOrder called", "user_id", uid)
+	logger.From(c).Info("CreateOrder called", "user_id", uid)
 	var order models.Order
 	if err := c.ShouldBindJSON(&order); err != nil {
-		logger.Log.Error("CreateOrder bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("CreateOrder bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-
-	if identity, ok := c.Get("identity"); ok {
-		if v, ok2 := identity.(string); ok2 {
-			order.CreatedByUserID = v
-		}
-	}
-
-	if order.OrderID == "" {
-		order.OrderID = uuid.New().String()
-		logger.Log.Info("CreateOrder auto-generated OrderID", "orderId", order.OrderID)
-	}
-
-	tx := store.DB.GormClient.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
-
-	details := order.Details
-	supplementaryFoods := order.SupplementaryFoods
-	order.Details = nil
-	order.SupplementaryFoods = nil
-
-	if err := tx.Create(&order).Error; err != nil {
-		logger.Log.Error("CreateOrder create header error", "error", err)
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
 		return
 	}
 
-	for i := range details {
-		details[i].OrderID = order.OrderID
-		details[i].OrderDetailID = 0
-
-		ingredients := details[i].Ingredients
-		details[i].Ingredients = nil
-
-		if err := tx.Create(&details[i]).Error; err != nil {
-			logger.Log.Error("CreateOrder create detail error", "error", err)
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-
-		for j := range ingredients {
-			ingredients[j].OrderDetailID = details[i].OrderDetailID
-			ingredients[j].OrderIngredientID = 0
-
-			if ingredients[j].Quantity <= 0 {
-				if ingredients[j].StandardPerPortion > 0 && details[i].Portions > 0 {
-					ingredients[j].Quantity = ingredients[j].StandardPerPortion * float64(details[i].Portions)
-				} else {
-					logger.Log.Warn("CreateOrder skipping ingredient with invalid quantity", "ingredient_id", ingredients[j].IngredientID)
-					continue
-				}
-			}
-
-			if err := tx.Create(&ingredients[j]).Error; err != nil {
-				logger.Log.Error("CreateOrder create ingredient error", "error", err)
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-				return
-			}
-		}
-	}
-
-	for i := range supplementaryFoods {
-		supplementaryFoods[i].OrderID = order.OrderID
-		supplementaryFoods[i].SupplementaryID = 0
-
-		if supplementaryFoods[i].Quantity <= 0 {
-			if supplementaryFoods[i].StandardPerPortion > 0 && supplementaryFoods[i].Portions > 0 {
-				supplementaryFoods[i].Quantity = supplementaryFoods[i].StandardPerPortion * float64(supplementaryFoods[i].Portions)
-			} else {
-				logger.Log.Warn("CreateOrder skipping supplementary with invalid quantity", "ingredient_id", supplementaryFoods[i].IngredientID)
-				continue
-			}
-		}
-
-		if err := tx.Create(&supplementaryFoods[i]).Error; err != nil {
-			logger.Log.Error("CreateOrder create supplementary error", "error", err)
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
-		}
-	}
-
-	if err := tx.Commit().Error; err != nil {
-		logger.Log.Error("CreateOrder commit error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	userID, _ := uid.(string)
+	created, err := h.Orders.Create(c, kitchens, &order, userID)
+	if err != nil {
+		logger.From(c).Error("CreateOrder error", "error", err)
+		apperr.Respond(c, serviceError(err, apperr.CodeOrderNotFound))
 		return
 	}
 
-	store.DB.GormClient.
-		Preload("Kitchen").
-		Preload("CreatedBy").
-		Preload("Details.Dish").
-		Preload("Details.Ingredients.Ingredient").
-		Preload("SupplementaryFoods.Ingredient").
-		First(&order, "order_id = ?", order.OrderID)
-
-	dto := convertOrderToDTO(&order, true)
+	dto := convertOrderToDTO(created, true)
 	c.JSON(http.StatusCreated, dto)
 }
 
-func UpdateOrderStatus(c *gin.Context) {
+// OrderStatusRequest is the body of UpdateOrderStatus
+type OrderStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+}
+
+func (h *OrderHandler) UpdateOrderStatus(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("UpdateOrderStatus called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("UpdateOrderStatus called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-
-	var req struct {
-		Status string `json:"status" binding:"required"`
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
 	}
+
+	var req OrderStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Log.Error("UpdateOrderStatus bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("UpdateOrderStatus bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-
-	var order models.Order
-	if err := store.DB.GormClient.First(&order, "order_id = ?", id).Error; err != nil {
-		logger.Log.Error("UpdateOrderStatus not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
 		return
 	}
 
-	order.Status = req.Status
-	if err := store.DB.GormClient.Save(&order).Error; err != nil {
-		logger.Log.Error("UpdateOrderStatus db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, err := h.Orders.UpdateStatus(c, kitchens, id, req.Status, cond)
+	if errors.Is(err, service.ErrVersionMismatch) {
+		if current, err := h.Orders.Get(c, kitchens, id); err == nil {
+			versionConflict(c, current.Version, convertOrderToDTO(current, true))
+			return
+		}
+	}
+	if err != nil {
+		logger.From(c).Error("UpdateOrderStatus error", "id", id, "error", err)
+		apperr.Respond(c, serviceError(err, apperr.CodeOrderNotFound))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully", "status": order.Status})
+	setETag(c, version)
+	c.JSON(http.StatusOK, gin.H{"message": "Order status updated successfully", "status": req.Status})
 }
 
-func DeleteOrder(c *gin.Context) {
+func (h *OrderHandler) DeleteOrder(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("DeleteOrder called", "id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("DeleteOrder called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-	if err := store.DB.GormClient.Delete(&models.Order{}, "order_id = ?", id).Error; err != nil {
-		logger.Log.Error("DeleteOrder db error", "id", id, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
+		return
+	}
+	if err := h.Orders.Delete(c, kitchens, id); err != nil {
+		logger.From(c).Error("DeleteOrder error", "id", id, "error", err)
+		apperr.Respond(c, serviceError(err, apperr.CodeOrderNotFound))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Order deleted successfully"})
@@ -354,10 +182,13 @@ type IngredientTotal struct {
 	TotalQuantity  float64 `json:"totalQuantity"`
 }
 
-func GetOrderIngredientsSummary(c *gin.Context) {
+func (h *OrderHandler) GetOrderIngredientsSummary(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetOrderIngredientsSummary called", "order_id", c.Param("id"), "user_id", uid)
+	logger.From(c).Info("GetOrderIngredientsSummary called", "order_id", c.Param("id"), "user_id", uid)
 	orderID := c.Param("id")
+	if !authorizeOrder(c, h.DB.WithContext(c), orderID) {
+		return
+	}
 
 	var results []IngredientTotal
 	sql := `
@@ -383,20 +214,23 @@ func GetOrderIngredientsSummary(c *gin.Context) {
         GROUP BY x.ingredient_id, mi.ingredient_name, x.unit
         ORDER BY mi.ingredient_name`
 
-	if err := store.DB.GormClient.Raw(sql, orderID, orderID).Scan(&results).Error; err != nil {
-		logger.Log.Error("GetOrderIngredientsSummary db error", "order_id", orderID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Raw(sql, orderID, orderID).Scan(&results).Error; err != nil {
+		logger.From(c).Error("GetOrderIngredientsSummary db error", "order_id", orderID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, results)
 }
 
-func GetOrderIngredientSummary(c *gin.Context) {
+func (h *OrderHandler) GetOrderIngredientSummary(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetOrderIngredientSummary called", "order_id", c.Param("id"), "ingredient_id", c.Param("ingredientId"), "user_id", uid)
+	logger.From(c).Info("GetOrderIngredientSummary called", "order_id", c.Param("id"), "ingredient_id", c.Param("ingredientId"), "user_id", uid)
 	orderID := c.Param("id")
 	ingredientID := c.Param("ingredientId")
+	if !authorizeOrder(c, h.DB.WithContext(c), orderID) {
+		return
+	}
 
 	var result IngredientTotal
 	sql := `
@@ -422,9 +256,9 @@ func GetOrderIngredientSummary(c *gin.Context) {
         GROUP BY x.ingredient_id, mi.ingredient_name, x.unit
         ORDER BY mi.ingredient_name`
 
-	if err := store.DB.GormClient.Raw(sql, orderID, ingredientID, orderID, ingredientID).Scan(&result).Error; err != nil {
-		logger.Log.Error("GetOrderIngredientSummary db error", "order_id", orderID, "ingredient_id", ingredientID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Raw(sql, orderID, ingredientID, orderID, ingredientID).Scan(&result).Error; err != nil {
+		logger.From(c).Error("GetOrderIngredientSummary db error", "order_id", orderID, "ingredient_id", ingredientID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -437,11 +271,16 @@ type SupplierOption struct {
 	SupplierID    string  `json:"supplierId"`
 	SupplierName  string  `json:"supplierName"`
 	UnitPrice     float64 `json:"unitPrice"`
+	ListPrice     float64 `json:"listPrice"`
+	PriceSource   string  `json:"priceSource"`
+	PriceNote     string  `json:"priceNote"`
 	Unit          string  `json:"unit"`
 	Specification string  `json:"specification"`
 	IsFavorite    bool    `json:"isFavorite"`
 	IsLowestPrice bool    `json:"isLowestPrice"`
 	TotalCost     float64 `json:"totalCost"`
+	// Delivery is set when a delivery date was checked against the supplier's calendar
+	Delivery *delivery.Status `json:"delivery,omitempty"`
 }
 
 type IngredientSuppliers struct {
@@ -450,311 +289,136 @@ type IngredientSuppliers struct {
 	TotalQuantity  float64         `json:"totalQuantity"`
 	Unit           string          `json:"unit"`
 	BestSupplier   *SupplierOption `json:"bestSupplier"`
+	Strategy       string          `json:"strategy"`
+	RuleID         int             `json:"ruleId,omitempty"`
+	Explanation    string          `json:"explanation"`
 }
 
 // GetBestSuppliersForOrder returns best supplier recommendations for all ingredients
-func GetBestSuppliersForOrder(c *gin.Context) {
+func (h *OrderHandler) GetBestSuppliersForOrder(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	orderID := c.Param("id")
-	logger.Log.Info("GetBestSuppliersForOrder called", "order_id", orderID, "user_id", uid)
+	logger.From(c).Info("GetBestSuppliersForOrder called", "order_id", orderID, "user_id", uid)
 
 	var order models.Order
-	if err := store.DB.GormClient.First(&order, "order_id = ?", orderID).Error; err != nil {
-		logger.Log.Error("GetBestSuppliersForOrder order not found", "order_id", orderID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	if err := h.DB.WithContext(c).First(&order, "order_id = ?", orderID).Error; err != nil {
+		logger.From(c).Error("GetBestSuppliersForOrder order not found", "order_id", orderID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
 		return
 	}
-
-	var ingredients []IngredientTotal
-	sql := `
-        SELECT DISTINCT x.ingredient_id AS ingredient_id,
-               COALESCE(mi.ingredient_name, '') AS ingredient_name,
-               x.unit AS unit,
-               COALESCE(SUM(x.total_qty)::double precision, 0) AS total_quantity
-        FROM (
-            SELECT oi.ingredient_id,
-                   oi.unit,
-                   COALESCE(oi.quantity, oi.standard_per_portion * od.portions) AS total_qty
-            FROM order_ingredients oi
-            JOIN order_details od ON od.order_detail_id = oi.order_detail_id
-            WHERE od.order_id = ?
-            UNION ALL
-            SELECT osf.ingredient_id,
-                   osf.unit,
-                   COALESCE(osf.quantity, osf.standard_per_portion * osf.portions) AS total_qty
-            FROM order_supplementary_foods osf
-            WHERE osf.order_id = ?
-        ) x
-        LEFT JOIN master_ingredients mi ON mi.ingredient_id = x.ingredient_id
-        GROUP BY x.ingredient_id, mi.ingredient_name, x.unit`
-
-	if err := store.DB.GormClient.Raw(sql, orderID, orderID).Scan(&ingredients).Error; err != nil {
-		logger.Log.Error("GetBestSuppliersForOrder ingredients query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !authorizeKitchen(c, order.KitchenID) {
 		return
 	}
 
-	var favorites []models.KitchenFavoriteSupplier
-	store.DB.GormClient.Where("kitchen_id = ?", order.KitchenID).Find(&favorites)
-
-	favoriteMap := make(map[string]bool)
-	for _, fav := range favorites {
-		favoriteMap[fav.SupplierID] = true
+	items, err := orderSelectionItems(h.DB.WithContext(c), orderID)
+	if err != nil {
+		logger.From(c).Error("GetBestSuppliersForOrder ingredients query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
 	}
 
-	var results []IngredientSuppliers
-
-	for _, ing := range ingredients {
-		var prices []models.SupplierPrice
-		if err := store.DB.GormClient.
-			Preload("Supplier").
-			Where("ingredient_id = ? AND active = true", ing.IngredientID).
-			Where("(effective_from IS NULL OR effective_from <= NOW())").
-			Where("(effective_to IS NULL OR effective_to >= NOW())").
-			Order("unit_price ASC").
-			Find(&prices).Error; err != nil {
-			logger.Log.Error("GetBestSuppliersForOrder prices query error", "ingredient_id", ing.IngredientID, "error", err)
-			continue
-		}
-
-		if len(prices) == 0 {
-			logger.Log.Warn("GetBestSuppliersForOrder no prices found", "ingredient_id", ing.IngredientID)
-			results = append(results, IngredientSuppliers{
-				IngredientID:   ing.IngredientID,
-				IngredientName: ing.IngredientName,
-				TotalQuantity:  ing.TotalQuantity,
-				Unit:           ing.Unit,
-				BestSupplier:   nil,
-			})
-			continue
-		}
-
-		lowestPrice := prices[0].UnitPrice
-
-		var allSuppliers []SupplierOption
-		var bestSupplier *SupplierOption
-		var favoriteSuppliers []SupplierOption
-
-		for _, price := range prices {
-			isFavorite := favoriteMap[price.SupplierID]
-			isLowestPrice := (price.UnitPrice == lowestPrice)
-			totalCost := ing.TotalQuantity * price.UnitPrice
-
-			option := SupplierOption{
-				ProductID:     price.ProductID,
-				ProductName:   price.ProductName,
-				SupplierID:    price.SupplierID,
-				UnitPrice:     price.UnitPrice,
-				Unit:          price.Unit,
-				Specification: price.Specification,
-				IsFavorite:    isFavorite,
-				IsLowestPrice: isLowestPrice,
-				TotalCost:     totalCost,
-			}
-
-			if price.Supplier != nil {
-				option.SupplierName = price.Supplier.SupplierName
-			}
-
-			allSuppliers = append(allSuppliers, option)
-
-			if isFavorite {
-				favoriteSuppliers = append(favoriteSuppliers, option)
-			}
-		}
+	opts := candidateOptions{ExcludeUndeliverable: undeliverableExcluded(c)}
+	if date, err := parseDeliveryDate(order.OrderDate); err == nil {
+		opts.DeliveryDate = date
+	} else {
+		logger.From(c).Warn("GetBestSuppliersForOrder invalid order date, delivery calendars not checked", "order_id", orderID, "order_date", order.OrderDate)
+	}
 
-		if len(favoriteSuppliers) > 0 {
-			bestSupplier = &favoriteSuppliers[0]
-		} else {
-			// Create best supplier from the lowest price option
-			price := prices[0]
-			isFavorite := favoriteMap[price.SupplierID]
-			totalCost := ing.TotalQuantity * price.UnitPrice
-
-			bestSupplier = &SupplierOption{
-				ProductID:     price.ProductID,
-				ProductName:   price.ProductName,
-				SupplierID:    price.SupplierID,
-				UnitPrice:     price.UnitPrice,
-				Unit:          price.Unit,
-				Specification: price.Specification,
-				IsFavorite:    isFavorite,
-				IsLowestPrice: true,
-				TotalCost:     totalCost,
-			}
+	decisions, err := selectSuppliers(h.DB.WithContext(c), order.KitchenID, items, opts)
+	if err != nil {
+		logger.From(c).Error("GetBestSuppliersForOrder selection error", "order_id", orderID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
 
-			if price.Supplier != nil {
-				bestSupplier.SupplierName = price.Supplier.SupplierName
-			}
+	results := make([]IngredientSuppliers, 0, len(decisions))
+	for _, d := range decisions {
+		if d.Selected == nil {
+			logger.From(c).Warn("GetBestSuppliersForOrder no prices found", "ingredient_id", d.Item.IngredientID)
 		}
-
-		results = append(results, IngredientSuppliers{
-			IngredientID:   ing.IngredientID,
-			IngredientName: ing.IngredientName,
-			TotalQuantity:  ing.TotalQuantity,
-			Unit:           ing.Unit,
-			BestSupplier:   bestSupplier,
-		})
+		results = append(results, toIngredientSuppliers(d))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"orderId":     orderID,
 		"kitchenId":   order.KitchenID,
+		"orderDate":   order.OrderDate,
 		"ingredients": results,
 	})
 }
 
+// IngredientQuantity is a quantity of an ingredient to find suppliers for
+type IngredientQuantity struct {
+	IngredientID string  `json:"ingredientId" binding:"required"`
+	Quantity     float64 `json:"quantity" binding:"required,gt=0"`
+	Unit         string  `json:"unit" binding:"required"`
+}
+
+// BestSuppliersRequest is the body of GetBestSuppliersForIngredients
+type BestSuppliersRequest struct {
+	KitchenID string `json:"kitchenId" binding:"required"`
+	// DeliveryDate (YYYY-MM-DD) enables the supplier delivery calendar check
+	DeliveryDate string               `json:"deliveryDate"`
+	Ingredients  []IngredientQuantity `json:"ingredients" binding:"required,min=1"`
+}
+
 // GetBestSuppliersForIngredients returns best supplier recommendations for a list of ingredients
 // This endpoint is for orders that haven't been saved yet
-func GetBestSuppliersForIngredients(c *gin.Context) {
+func (h *OrderHandler) GetBestSuppliersForIngredients(c *gin.Context) {
 	uid, _ := c.Get("identity")
-	logger.Log.Info("GetBestSuppliersForIngredients called", "user_id", uid)
-
-	var request struct {
-		KitchenID   string `json:"kitchenId" binding:"required"`
-		Ingredients []struct {
-			IngredientID string  `json:"ingredientId" binding:"required"`
-			Quantity     float64 `json:"quantity" binding:"required,gt=0"`
-			Unit         string  `json:"unit" binding:"required"`
-		} `json:"ingredients" binding:"required,min=1"`
-	}
+	logger.From(c).Info("GetBestSuppliersForIngredients called", "user_id", uid)
+
+	var request BestSuppliersRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		logger.Log.Error("GetBestSuppliersForIngredients bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetBestSuppliersForIngredients bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	// Validate kitchen exists
 	var kitchen models.Kitchen
-	if err := store.DB.GormClient.First(&kitchen, "kitchen_id = ?", request.KitchenID).Error; err != nil {
-		logger.Log.Error("GetBestSuppliersForIngredients kitchen not found", "kitchen_id", request.KitchenID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen not found"})
+	if err := h.DB.WithContext(c).First(&kitchen, "kitchen_id = ?", request.KitchenID).Error; err != nil {
+		logger.From(c).Error("GetBestSuppliersForIngredients kitchen not found", "kitchen_id", request.KitchenID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeKitchenNotFound))
 		return
 	}
-
-	// Get favorite suppliers for the kitchen
-	var favorites []models.KitchenFavoriteSupplier
-	store.DB.GormClient.Where("kitchen_id = ?", request.KitchenID).Find(&favorites)
-
-	favoriteMap := make(map[string]bool)
-	for _, fav := range favorites {
-		favoriteMap[fav.SupplierID] = true
+	if !authorizeKitchen(c, request.KitchenID) {
+		return
 	}
 
-	var results []IngredientSuppliers
-
-	// Process each ingredient from the request
+	items := make([]selection.Item, 0, len(request.Ingredients))
 	for _, reqIng := range request.Ingredients {
-		// Validate ingredient exists and get its name
-		var ingredient models.Ingredient
-		if err := store.DB.GormClient.First(&ingredient, "ingredient_id = ?", reqIng.IngredientID).Error; err != nil {
-			logger.Log.Warn("GetBestSuppliersForIngredients ingredient not found", "ingredient_id", reqIng.IngredientID, "error", err)
-			results = append(results, IngredientSuppliers{
-				IngredientID:   reqIng.IngredientID,
-				IngredientName: "",
-				TotalQuantity:  reqIng.Quantity,
-				Unit:           reqIng.Unit,
-				BestSupplier:   nil,
-			})
-			continue
-		}
-
-		// Get supplier prices for this ingredient
-		var prices []models.SupplierPrice
-		if err := store.DB.GormClient.
-			Preload("Supplier").
-			Where("ingredient_id = ? AND active = true", reqIng.IngredientID).
-			Where("(effective_from IS NULL OR effective_from <= NOW())").
-			Where("(effective_to IS NULL OR effective_to >= NOW())").
-			Order("unit_price ASC").
-			Find(&prices).Error; err != nil {
-			logger.Log.Error("GetBestSuppliersForIngredients prices query error", "ingredient_id", reqIng.IngredientID, "error", err)
-			results = append(results, IngredientSuppliers{
-				IngredientID:   reqIng.IngredientID,
-				IngredientName: ingredient.IngredientName,
-				TotalQuantity:  reqIng.Quantity,
-				Unit:           reqIng.Unit,
-				BestSupplier:   nil,
-			})
-			continue
-		}
-
-		if len(prices) == 0 {
-			logger.Log.Warn("GetBestSuppliersForIngredients no prices found", "ingredient_id", reqIng.IngredientID)
-			results = append(results, IngredientSuppliers{
-				IngredientID:   reqIng.IngredientID,
-				IngredientName: ingredient.IngredientName,
-				TotalQuantity:  reqIng.Quantity,
-				Unit:           reqIng.Unit,
-				BestSupplier:   nil,
-			})
-			continue
-		}
-
-		lowestPrice := prices[0].UnitPrice
-
-		var bestSupplier *SupplierOption
-		var favoriteSuppliers []SupplierOption
-
-		for _, price := range prices {
-			isFavorite := favoriteMap[price.SupplierID]
-			isLowestPrice := (price.UnitPrice == lowestPrice)
-			totalCost := reqIng.Quantity * price.UnitPrice
-
-			option := SupplierOption{
-				ProductID:     price.ProductID,
-				ProductName:   price.ProductName,
-				SupplierID:    price.SupplierID,
-				UnitPrice:     price.UnitPrice,
-				Unit:          price.Unit,
-				Specification: price.Specification,
-				IsFavorite:    isFavorite,
-				IsLowestPrice: isLowestPrice,
-				TotalCost:     totalCost,
-			}
-
-			if price.Supplier != nil {
-				option.SupplierName = price.Supplier.SupplierName
-			}
+		items = append(items, selection.Item{
+			IngredientID: reqIng.IngredientID,
+			Quantity:     reqIng.Quantity,
+			Unit:         reqIng.Unit,
+		})
+	}
 
-			if isFavorite {
-				favoriteSuppliers = append(favoriteSuppliers, option)
-			}
+	opts := candidateOptions{ExcludeUndeliverable: undeliverableExcluded(c)}
+	if request.DeliveryDate != "" {
+		date, err := parseDeliveryDate(request.DeliveryDate)
+		if err != nil {
+			apperr.Respond(c, apperr.Invalid("deliveryDate", "date", "YYYY-MM-DD"))
+			return
 		}
+		opts.DeliveryDate = date
+	}
 
-		if len(favoriteSuppliers) > 0 {
-			bestSupplier = &favoriteSuppliers[0]
-		} else {
-			// Create best supplier from the lowest price option
-			price := prices[0]
-			isFavorite := favoriteMap[price.SupplierID]
-			totalCost := reqIng.Quantity * price.UnitPrice
-
-			bestSupplier = &SupplierOption{
-				ProductID:     price.ProductID,
-				ProductName:   price.ProductName,
-				SupplierID:    price.SupplierID,
-				UnitPrice:     price.UnitPrice,
-				Unit:          price.Unit,
-				Specification: price.Specification,
-				IsFavorite:    isFavorite,
-				IsLowestPrice: true,
-				TotalCost:     totalCost,
-			}
+	decisions, err := selectSuppliers(h.DB.WithContext(c), request.KitchenID, items, opts)
+	if err != nil {
+		logger.From(c).Error("GetBestSuppliersForIngredients selection error", "kitchen_id", request.KitchenID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
 
-			if price.Supplier != nil {
-				bestSupplier.SupplierName = price.Supplier.SupplierName
-			}
+	results := make([]IngredientSuppliers, 0, len(decisions))
+	for _, d := range decisions {
+		if d.Selected == nil {
+			logger.From(c).Warn("GetBestSuppliersForIngredients no prices found", "ingredient_id", d.Item.IngredientID)
 		}
-
-		results = append(results, IngredientSuppliers{
-			IngredientID:   reqIng.IngredientID,
-			IngredientName: ingredient.IngredientName,
-			TotalQuantity:  reqIng.Quantity,
-			Unit:           reqIng.Unit,
-			BestSupplier:   bestSupplier,
-		})
+		results = append(results, toIngredientSuppliers(d))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -763,10 +427,28 @@ func GetBestSuppliersForIngredients(c *gin.Context) {
 	})
 }
 
-func SaveOrderIngredientsWithSupplier(c *gin.Context) {
+// SupplierSelection is the supplier product chosen for an ingredient of an order
+type SupplierSelection struct {
+	IngredientID       string  `json:"ingredientId" binding:"required"`
+	SelectedSupplierID string  `json:"selectedSupplierId" binding:"required"`
+	SelectedProductID  int     `json:"selectedProductId" binding:"required"`
+	Quantity           float64 `json:"quantity" binding:"required,gt=0"`
+	Unit               string  `json:"unit" binding:"required"`
+	// UnitPrice overrides the price; when omitted it is resolved from the supplier's
+	// tiers, kitchen contract prices and promotions for this quantity
+	UnitPrice *float64 `json:"unitPrice" binding:"omitempty,gte=0"`
+	Notes     string   `json:"notes"`
+}
+
+// SupplierSelectionsRequest is the body of SaveOrderIngredientsWithSupplier
+type SupplierSelectionsRequest struct {
+	Selections []SupplierSelection `json:"selections" binding:"required,min=1"`
+}
+
+func (h *OrderHandler) SaveOrderIngredientsWithSupplier(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	orderID := c.Param("id")
-	logger.Log.Info("SaveOrderIngredientsWithSupplier called", "order_id", orderID, "user_id", uid)
+	logger.From(c).Info("SaveOrderIngredientsWithSupplier called", "order_id", orderID, "user_id", uid)
 
 	var userID string
 	if identity, ok := c.Get("identity"); ok {
@@ -775,58 +457,61 @@ func SaveOrderIngredientsWithSupplier(c *gin.Context) {
 		}
 	}
 
-	var request struct {
-		Selections []struct {
-			IngredientID       string  `json:"ingredientId" binding:"required"`
-			SelectedSupplierID string  `json:"selectedSupplierId" binding:"required"`
-			SelectedProductID  int     `json:"selectedProductId" binding:"required"`
-			Quantity           float64 `json:"quantity" binding:"required,gt=0"`
-			Unit               string  `json:"unit" binding:"required"`
-			UnitPrice          float64 `json:"unitPrice" binding:"required,gte=0"`
-			Notes              string  `json:"notes"`
-		} `json:"selections" binding:"required,min=1"`
-	}
+	var request SupplierSelectionsRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
-		logger.Log.Error("SaveOrderIngredientsWithSupplier bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("SaveOrderIngredientsWithSupplier bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	var order models.Order
-	if err := store.DB.GormClient.First(&order, "order_id = ?", orderID).Error; err != nil {
-		logger.Log.Error("SaveOrderIngredientsWithSupplier order not found", "order_id", orderID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	if err := h.DB.WithContext(c).First(&order, "order_id = ?", orderID).Error; err != nil {
+		logger.From(c).Error("SaveOrderIngredientsWithSupplier order not found", "order_id", orderID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
+		return
+	}
+	if !authorizeKitchen(c, order.KitchenID) {
 		return
 	}
 
 	for i, sel := range request.Selections {
 		var ingredient models.Ingredient
-		if err := store.DB.GormClient.First(&ingredient, "ingredient_id = ?", sel.IngredientID).Error; err != nil {
-			logger.Log.Error("SaveOrderIngredientsWithSupplier ingredient not found", "ingredient_id", sel.IngredientID, "error", err)
-			c.JSON(http.StatusNotFound, gin.H{"error": "Ingredient not found: " + sel.IngredientID})
+		if err := h.DB.WithContext(c).First(&ingredient, "ingredient_id = ?", sel.IngredientID).Error; err != nil {
+			logger.From(c).Error("SaveOrderIngredientsWithSupplier ingredient not found", "ingredient_id", sel.IngredientID, "error", err)
+			apperr.Respond(c, dbError(err, apperr.CodeIngredientNotFound).With("ingredient_id", sel.IngredientID))
 			return
 		}
 
 		var supplier models.Supplier
-		if err := store.DB.GormClient.First(&supplier, "supplier_id = ?", sel.SelectedSupplierID).Error; err != nil {
-			logger.Log.Error("SaveOrderIngredientsWithSupplier supplier not found", "supplier_id", sel.SelectedSupplierID, "error", err)
-			c.JSON(http.StatusNotFound, gin.H{"error": "Supplier not found: " + sel.SelectedSupplierID})
+		if err := h.DB.WithContext(c).First(&supplier, "supplier_id = ?", sel.SelectedSupplierID).Error; err != nil {
+			logger.From(c).Error("SaveOrderIngredientsWithSupplier supplier not found", "supplier_id", sel.SelectedSupplierID, "error", err)
+			apperr.Respond(c, dbError(err, apperr.CodeSupplierNotFound).With("supplier_id", sel.SelectedSupplierID))
 			return
 		}
 
 		var product models.SupplierPrice
-		if err := store.DB.GormClient.First(&product, "product_id = ? AND supplier_id = ? AND ingredient_id = ?",
+		if err := h.DB.WithContext(c).First(&product, "product_id = ? AND supplier_id = ? AND ingredient_id = ?",
 			sel.SelectedProductID, sel.SelectedSupplierID, sel.IngredientID).Error; err != nil {
-			logger.Log.Error("SaveOrderIngredientsWithSupplier product mismatch",
+			logger.From(c).Error("SaveOrderIngredientsWithSupplier product mismatch",
 				"product_id", sel.SelectedProductID,
 				"supplier_id", sel.SelectedSupplierID,
 				"ingredient_id", sel.IngredientID,
 				"error", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Product not found or does not match supplier/ingredient"})
+			apperr.Respond(c, dbError(err, apperr.CodeProductNotFound).With("product_id", sel.SelectedProductID))
 			return
 		}
 
+		if sel.UnitPrice == nil {
+			quote, err := quoteSupplierPrice(h.DB.WithContext(c), product, order.KitchenID, sel.Quantity, time.Now())
+			if err != nil {
+				logger.From(c).Error("SaveOrderIngredientsWithSupplier price quote error", "product_id", product.ProductID, "error", err)
+				apperr.Respond(c, apperr.Internal(err))
+				return
+			}
+			request.Selections[i].UnitPrice = &quote.UnitPrice
+		}
+
 		var presentCount int64
 		presentSQL := `
 			SELECT COUNT(*) AS cnt FROM (
@@ -839,29 +524,29 @@ func SaveOrderIngredientsWithSupplier(c *gin.Context) {
 				FROM order_supplementary_foods osf
 				WHERE osf.order_id = ? AND osf.ingredient_id = ?
 			) x`
-		if err := store.DB.GormClient.Raw(presentSQL, orderID, sel.IngredientID, orderID, sel.IngredientID).Scan(&presentCount).Error; err != nil {
-			logger.Log.Error("SaveOrderIngredientsWithSupplier validate ingredient error",
+		if err := h.DB.WithContext(c).Raw(presentSQL, orderID, sel.IngredientID, orderID, sel.IngredientID).Scan(&presentCount).Error; err != nil {
+			logger.From(c).Error("SaveOrderIngredientsWithSupplier validate ingredient error",
 				"order_id", orderID, "ingredient_id", sel.IngredientID, "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 		if presentCount == 0 {
-			logger.Log.Error("SaveOrderIngredientsWithSupplier ingredient not in order",
+			logger.From(c).Error("SaveOrderIngredientsWithSupplier ingredient not in order",
 				"order_id", orderID, "ingredient_id", sel.IngredientID)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Ingredient does not belong to the order: " + sel.IngredientID})
+			apperr.Respond(c, apperr.New(apperr.CodeIngredientNotInOrder).With("ingredient_id", sel.IngredientID))
 			return
 		}
 
 		for j := i + 1; j < len(request.Selections); j++ {
 			if request.Selections[j].IngredientID == sel.IngredientID {
-				logger.Log.Error("SaveOrderIngredientsWithSupplier duplicate ingredient", "ingredient_id", sel.IngredientID)
-				c.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate ingredient_id in request: " + sel.IngredientID})
+				logger.From(c).Error("SaveOrderIngredientsWithSupplier duplicate ingredient", "ingredient_id", sel.IngredientID)
+				apperr.Respond(c, apperr.Invalid(fmt.Sprintf("selections[%d].ingredientId", j), "unique"))
 				return
 			}
 		}
 	}
 
-	tx := store.DB.GormClient.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	defer func() {
 		if r := recover(); r != nil {
 			tx.Rollback()
@@ -870,7 +555,7 @@ func SaveOrderIngredientsWithSupplier(c *gin.Context) {
 
 	var savedSelections []models.OrderIngredientSupplier
 	for _, sel := range request.Selections {
-		totalCost := sel.Quantity * sel.UnitPrice
+		totalCost := sel.Quantity * *sel.UnitPrice
 
 		var existing models.OrderIngredientSupplier
 		findErr := tx.Where("order_id = ? AND ingredient_id = ?", orderID, sel.IngredientID).First(&existing).Error
@@ -883,38 +568,38 @@ func SaveOrderIngredientsWithSupplier(c *gin.Context) {
 				SelectedProductID:  sel.SelectedProductID,
 				Quantity:           sel.Quantity,
 				Unit:               sel.Unit,
-				UnitPrice:          sel.UnitPrice,
+				UnitPrice:          *sel.UnitPrice,
 				TotalCost:          totalCost,
 				SelectedByUserID:   userID,
 				Notes:              sel.Notes,
 			}
 
 			if err := tx.Create(&newSelection).Error; err != nil {
-				logger.Log.Error("SaveOrderIngredientsWithSupplier create error", "error", err)
+				logger.From(c).Error("SaveOrderIngredientsWithSupplier create error", "error", err)
 				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				apperr.Respond(c, apperr.Internal(err))
 				return
 			}
 			savedSelections = append(savedSelections, newSelection)
 		} else if findErr != nil {
-			logger.Log.Error("SaveOrderIngredientsWithSupplier find error", "error", findErr)
+			logger.From(c).Error("SaveOrderIngredientsWithSupplier find error", "error", findErr)
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": findErr.Error()})
+			apperr.Respond(c, apperr.Internal(findErr))
 			return
 		} else {
 			existing.SelectedSupplierID = sel.SelectedSupplierID
 			existing.SelectedProductID = sel.SelectedProductID
 			existing.Quantity = sel.Quantity
 			existing.Unit = sel.Unit
-			existing.UnitPrice = sel.UnitPrice
+			existing.UnitPrice = *sel.UnitPrice
 			existing.TotalCost = totalCost
 			existing.SelectedByUserID = userID
 			existing.Notes = sel.Notes
 
 			if err := tx.Save(&existing).Error; err != nil {
-				logger.Log.Error("SaveOrderIngredientsWithSupplier update error", "error", err)
+				logger.From(c).Error("SaveOrderIngredientsWithSupplier update error", "error", err)
 				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				apperr.Respond(c, apperr.Internal(err))
 				return
 			}
 			savedSelections = append(savedSelections, existing)
@@ -922,20 +607,20 @@ func SaveOrderIngredientsWithSupplier(c *gin.Context) {
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		logger.Log.Error("SaveOrderIngredientsWithSupplier commit error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("SaveOrderIngredientsWithSupplier commit error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var responseSelections []models.OrderIngredientSupplier
-	if err := store.DB.GormClient.
+	if err := h.DB.WithContext(c).
 		Preload("Ingredient").
 		Preload("SelectedSupplier").
 		Preload("SelectedProduct").
 		Preload("SelectedBy").
 		Where("order_id = ?", orderID).
 		Find(&responseSelections).Error; err != nil {
-		logger.Log.Error("SaveOrderIngredientsWithSupplier reload error", "error", err)
+		logger.From(c).Error("SaveOrderIngredientsWithSupplier reload error", "error", err)
 		responseSelections = savedSelections
 	}
 
@@ -948,22 +633,25 @@ func SaveOrderIngredientsWithSupplier(c *gin.Context) {
 }
 
 // GetOrderSelectedSuppliers returns all selected suppliers and their details for an order
-func GetOrderSelectedSuppliers(c *gin.Context) {
+func (h *OrderHandler) GetOrderSelectedSuppliers(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	orderID := c.Param("id")
-	logger.Log.Info("GetOrderSelectedSuppliers called", "order_id", orderID, "user_id", uid)
+	logger.From(c).Info("GetOrderSelectedSuppliers called", "order_id", orderID, "user_id", uid)
 
 	// Validate order exists
 	var order models.Order
-	if err := store.DB.GormClient.First(&order, "order_id = ?", orderID).Error; err != nil {
-		logger.Log.Error("GetOrderSelectedSuppliers order not found", "order_id", orderID, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+	if err := h.DB.WithContext(c).First(&order, "order_id = ?", orderID).Error; err != nil {
+		logger.From(c).Error("GetOrderSelectedSuppliers order not found", "order_id", orderID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
+		return
+	}
+	if !authorizeKitchen(c, order.KitchenID) {
 		return
 	}
 
 	// Get all selected suppliers for this order with all related data
 	var selections []models.OrderIngredientSupplier
-	if err := store.DB.GormClient.
+	if err := h.DB.WithContext(c).
 		Preload("Ingredient").
 		Preload("SelectedSupplier").
 		Preload("SelectedProduct").
@@ -971,8 +659,8 @@ func GetOrderSelectedSuppliers(c *gin.Context) {
 		Where("order_id = ?", orderID).
 		Order("ingredient_id ASC").
 		Find(&selections).Error; err != nil {
-		logger.Log.Error("GetOrderSelectedSuppliers query error", "order_id", orderID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetOrderSelectedSuppliers query error", "order_id", orderID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -985,30 +673,29 @@ func GetOrderSelectedSuppliers(c *gin.Context) {
 }
 
 // GetSuppliersWithOrderHighlight returns all suppliers with flags indicating which are used in the order
-func GetSuppliersWithOrderHighlight(c *gin.Context) {
+func (h *OrderHandler) GetSuppliersWithOrderHighlight(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	orderID := c.Param("id")
-	logger.Log.Info("GetSuppliersWithOrderHighlight called", "order_id", orderID, "user_id", uid)
+	logger.From(c).Info("GetSuppliersWithOrderHighlight called", "order_id", orderID, "user_id", uid)
 
 	// Validate order exists
 	var order models.Order
-	if err := store.DB.GormClient.First(&order, "order_id = ?", orderID).Error; err != nil {
-		logger.Log.Error("GetSuppliersWithOrderHighlight order not found", "order_id", orderID, "error", err)
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+	if err := h.DB.WithContext(c).First(&order, "order_id = ?", orderID).Error; err != nil {
+		logger.From(c).Error("GetSuppliersWithOrderHighlight order not found", "order_id", orderID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
+		return
+	}
+	if !authorizeKitchen(c, order.KitchenID) {
 		return
 	}
 
 	// Get all suppliers from order_ingredient_suppliers for this order
 	var orderSuppliers []models.OrderIngredientSupplier
-	if err := store.DB.GormClient.
+	if err := h.DB.WithContext(c).
 		Where("order_id = ?", orderID).
 		Find(&orderSuppliers).Error; err != nil {
-		logger.Log.Error("GetSuppliersWithOrderHighlight query order suppliers error", "order_id", orderID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSuppliersWithOrderHighlight query order suppliers error", "order_id", orderID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -1022,9 +709,9 @@ func GetSuppliersWithOrderHighlight(c *gin.Context) {
 
 	// Get all suppliers from master_suppliers table
 	var allSuppliers []models.Supplier
-	if err := store.DB.GormClient.Find(&allSuppliers).Error; err != nil {
-		logger.Log.Error("GetSuppliersWithOrderHighlight query all suppliers error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Find(&allSuppliers).Error; err != nil {
+		logger.From(c).Error("GetSuppliersWithOrderHighlight query all suppliers error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -1078,26 +765,25 @@ func GetSuppliersWithOrderHighlight(c *gin.Context) {
 
 // GetOrderSuppliersForInventory returns order suppliers formatted for inventory import/export operations
 // Optional query parameter: supplier_id - filters ingredients to only those from this supplier
-func GetOrderSuppliersForInventory(c *gin.Context) {
+func (h *OrderHandler) GetOrderSuppliersForInventory(c *gin.Context) {
 	uid, _ := c.Get("identity")
 	orderID := c.Param("id")
 	supplierID := c.Query("supplier_id") // Optional filter
-	logger.Log.Info("GetOrderSuppliersForInventory called", "order_id", orderID, "supplier_id", supplierID, "user_id", uid)
+	logger.From(c).Info("GetOrderSuppliersForInventory called", "order_id", orderID, "supplier_id", supplierID, "user_id", uid)
 
 	// Validate order exists
 	var order models.Order
-	if err := store.DB.GormClient.First(&order, "order_id = ?", orderID).Error; err != nil {
-		logger.Log.Error("GetOrderSuppliersForInventory order not found", "order_id", orderID, "error", err)
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+	if err := h.DB.WithContext(c).First(&order, "order_id = ?", orderID).Error; err != nil {
+		logger.From(c).Error("GetOrderSuppliersForInventory order not found", "order_id", orderID, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeOrderNotFound))
+		return
+	}
+	if !authorizeKitchen(c, order.KitchenID) {
 		return
 	}
 
 	// Build query for selected suppliers
-	query := store.DB.GormClient.
+	query := h.DB.WithContext(c).
 		Preload("Ingredient").
 		Preload("SelectedSupplier").
 		Where("order_id = ?", orderID)
@@ -1105,14 +791,14 @@ func GetOrderSuppliersForInventory(c *gin.Context) {
 	// Filter by supplier if provided
 	if supplierID != "" {
 		query = query.Where("selected_supplier_id = ?", supplierID)
-		logger.Log.Info("Filtering by supplier", "supplier_id", supplierID)
+		logger.From(c).Info("Filtering by supplier", "supplier_id", supplierID)
 	}
 
 	// Get selected suppliers for this order
 	var selections []models.OrderIngredientSupplier
 	if err := query.Order("ingredient_id ASC").Find(&selections).Error; err != nil {
-		logger.Log.Error("GetOrderSuppliersForInventory query error", "order_id", orderID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetOrderSuppliersForInventory query error", "order_id", orderID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -1158,6 +844,7 @@ func convertOrderToDTO(o *models.Order, includeChildren bool) models.OrderDTO {
 		CreatedByUserID: o.CreatedByUserID,
 		CreatedDate:     o.CreatedDate,
 		ModifiedDate:    o.ModifiedDate,
+		Version:         o.Version,
 	}
 	if o.Kitchen != nil {
 		dto.KitchenName = o.Kitchen.KitchenName
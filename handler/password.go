@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/service"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// forgotPasswordMessage is returned whether or not the account exists, so the endpoint
+// cannot be used to find out which emails are registered
+const forgotPasswordMessage = "If the account exists, a password reset link has been sent"
+
+// PasswordHandler serves the forgot, reset and change password flows. Every password
+// change revokes all sessions of the user.
+type PasswordHandler struct {
+	Passwords *service.Passwords
+}
+
+func NewPasswordHandler(passwords *service.Passwords) *PasswordHandler {
+	return &PasswordHandler{Passwords: passwords}
+}
+
+type ForgotPasswordRequest struct {
+	// Login is the user name or the email address of the account
+	Login string `json:"login" binding:"required"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// ForgotPassword emails a reset link to the account matching the login. The response is
+// the same whether or not the account exists.
+func (h *PasswordHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	logger.From(c).Info("ForgotPassword called", "ip", c.ClientIP())
+
+	h.Passwords.Forgot(c, req.Login)
+	c.JSON(http.StatusAccepted, gin.H{"message": forgotPasswordMessage})
+}
+
+// ResetPassword sets a new password with a token from a reset link. The token is consumed
+// and every session of the user is revoked.
+func (h *PasswordHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	userID, err := h.Passwords.Reset(c, req.Token, req.NewPassword)
+	if err != nil {
+		apperr.Respond(c, serviceError(err, apperr.CodeResetTokenInvalid))
+		return
+	}
+	logger.From(c).Info("password reset with token", "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please sign in again"})
+}
+
+// ChangePassword lets the signed-in user change their password by confirming the current
+// one. All sessions, including the current one, are revoked.
+func (h *PasswordHandler) ChangePassword(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("ChangePassword called", "user_id", uid)
+	userID, _ := uid.(string)
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	if err := h.Passwords.Change(c, userID, req.OldPassword, req.NewPassword); err != nil {
+		if errors.Is(err, service.ErrPasswordIncorrect) {
+			logger.From(c).Warn("ChangePassword wrong current password", "user_id", userID)
+		}
+		apperr.Respond(c, serviceError(err, apperr.CodeUnauthorized))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed, please sign in again"})
+}
+
+// AdminResetPassword forces a user to choose a new password: the current password stops
+// working, every session is revoked and a reset link is emailed. Without an email address
+// the link is returned to the admin to hand over.
+func (h *PasswordHandler) AdminResetPassword(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("AdminResetPassword called", "id", c.Param("id"), "user_id", uid)
+	adminID, _ := uid.(string)
+
+	reset, err := h.Passwords.AdminReset(c, adminID, c.Param("id"))
+	if err != nil {
+		apperr.Respond(c, serviceError(err, apperr.CodeUserNotFound))
+		return
+	}
+	if reset.EmailSent {
+		c.JSON(http.StatusOK, gin.H{"message": "Password reset, a reset link has been emailed", "emailSent": true, "expiresAt": reset.ExpiresAt})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset, hand the reset link to the user", "emailSent": false, "resetUrl": reset.URL, "expiresAt": reset.ExpiresAt})
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"adong-be/auth/password"
+	"adong-be/mail"
+	"adong-be/models"
+	"adong-be/repository"
+	"adong-be/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// revokedSessions records the users signed out everywhere
+type revokedSessions []string
+
+func (r *revokedSessions) RevokeAllUserTokens(userID string) error {
+	*r = append(*r, userID)
+	return nil
+}
+
+// sentMail keeps the emails sent instead of delivering them
+type sentMail []mail.Message
+
+func (m *sentMail) Send(ctx context.Context, msg mail.Message) error {
+	*m = append(*m, msg)
+	return nil
+}
+
+// passwordRouter serves the password routes over bep01 (U1) kept in memory, signed in as U1
+func passwordRouter(t *testing.T) (*gin.Engine, *repository.MemoryPasswords, *revokedSessions, *sentMail) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	hash, err := password.Hash("Adong2024")
+	require.NoError(t, err)
+	active := true
+	repo := repository.NewMemoryPasswords([]models.User{
+		{UserID: "U1", UserName: "bep01", Email: "bep01@adong.vn", Password: hash, Active: &active},
+	})
+	sessions, mailer := &revokedSessions{}, &sentMail{}
+	h := NewPasswordHandler(service.NewPasswords(repo, sessions, mailer, password.DefaultPolicy(), time.Hour, "https://app.adong.vn/reset-password"))
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set("identity", "U1") })
+	r.POST("/auth/forgot-password", h.ForgotPassword)
+	r.POST("/auth/reset-password", h.ResetPassword)
+	r.POST("/auth/change-password", h.ChangePassword)
+	r.POST("/users/:id/reset-password", h.AdminResetPassword)
+	return r, repo, sessions, mailer
+}
+
+// tokenPattern finds the token of a reset link in an email
+var tokenPattern = regexp.MustCompile(`[?&]token=([A-Za-z0-9_-]+)`)
+
+func postJSON(r *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return body.Code
+}
+
+// The answer does not tell whether the account exists or a link was just sent
+func TestForgotPassword_SameAnswer(t *testing.T) {
+	r, _, _, mailer := passwordRouter(t)
+
+	sent := postJSON(r, "/auth/forgot-password", `{"login":"bep01"}`)
+	unknown := postJSON(r, "/auth/forgot-password", `{"login":"nobody@adong.vn"}`)
+	throttled := postJSON(r, "/auth/forgot-password", `{"login":"bep01@adong.vn"}`)
+
+	assert.Len(t, *mailer, 1)
+	for _, w := range []*httptest.ResponseRecorder{sent, unknown, throttled} {
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, sent.Body.String(), w.Body.String())
+	}
+}
+
+func TestResetPassword_InvalidToken(t *testing.T) {
+	r, repo, sessions, _ := passwordRouter(t)
+	require.NoError(t, repo.CreateResetToken(context.Background(), &models.PasswordResetToken{
+		UserID:    "U1",
+		TokenHash: password.HashToken("expired-token"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}))
+
+	for _, token := range []string{"expired-token", "never-issued"} {
+		w := postJSON(r, "/auth/reset-password", `{"token":"`+token+`","newPassword":"NewAdong2025"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "RESET_TOKEN_INVALID", errorCode(t, w))
+	}
+	assert.Empty(t, *sessions)
+}
+
+// Every way of setting a password signs the user out everywhere
+func TestPasswordChanges_RevokeSessions(t *testing.T) {
+	r, _, sessions, mailer := passwordRouter(t)
+
+	w := postJSON(r, "/auth/change-password", `{"oldPassword":"wrong","newPassword":"NewAdong2025"}`)
+	assert.Equal(t, "PASSWORD_INCORRECT", errorCode(t, w))
+	assert.Empty(t, *sessions)
+
+	w = postJSON(r, "/auth/change-password", `{"oldPassword":"Adong2024","newPassword":"NewAdong2025"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, revokedSessions{"U1"}, *sessions)
+
+	w = postJSON(r, "/users/U1/reset-password", `{}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, revokedSessions{"U1", "U1"}, *sessions)
+	require.Len(t, *mailer, 1)
+
+	token := tokenPattern.FindStringSubmatch((*mailer)[0].Body)
+	require.Len(t, token, 2, (*mailer)[0].Body)
+	reset := `{"token":"` + token[1] + `","newPassword":"OtherAdong2025"}`
+	w = postJSON(r, "/auth/reset-password", reset)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, revokedSessions{"U1", "U1", "U1"}, *sessions)
+
+	w = postJSON(r, "/auth/reset-password", reset)
+	assert.Equal(t, "RESET_TOKEN_INVALID", errorCode(t, w), "a token works only once")
+	assert.Len(t, *sessions, 3)
+}
@@ -1,22 +1,32 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/logger"
 	"adong-be/models"
-	"adong-be/store"
 	"adong-be/utils"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type RecipeStandardHandler struct {
+	DB *gorm.DB
+}
+
+func NewRecipeStandardHandler(db *gorm.DB) *RecipeStandardHandler {
+	return &RecipeStandardHandler{DB: db}
+}
+
 // GetRecipeStandards with pagination and search - Returns ResourceCollection format with DTOs
-func GetRecipeStandards(c *gin.Context) {
-	logger.Log.Info("GetRecipeStandards called")
+func (h *RecipeStandardHandler) GetRecipeStandards(c *gin.Context) {
+	logger.From(c).Info("GetRecipeStandards called")
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		logger.Log.Error("GetRecipeStandards bind query error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetRecipeStandards bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -28,8 +38,15 @@ func GetRecipeStandards(c *gin.Context) {
 		params.SortDir,
 	)
 
+	// Restrict to the kitchens the caller may access
+	base, ok := scopeQuery(c, h.DB.WithContext(c).Model(&models.RecipeStandard{}), "kitchen_id", c.Query("kitchen_id"))
+	if !ok {
+		return
+	}
+	base = base.Session(&gorm.Session{})
+
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.RecipeStandard{})
+	countDB := base
 
 	searchConfig := utils.SearchConfig{
 		Fields: []string{"dish_id", "ingredient_id", "kitchen_id"},
@@ -38,13 +55,13 @@ func GetRecipeStandards(c *gin.Context) {
 	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetRecipeStandards count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetRecipeStandards count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var recipes []models.RecipeStandard
-	db := store.DB.GormClient.Model(&models.RecipeStandard{})
+	db := base
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
 	allowedSortFields := map[string]string{
@@ -61,8 +78,8 @@ func GetRecipeStandards(c *gin.Context) {
 	db = db.Preload("Dish").Preload("Kitchen").Preload("Ingredient").Preload("UpdatedBy")
 
 	if err := db.Find(&recipes).Error; err != nil {
-		logger.Log.Error("GetRecipeStandards query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetRecipeStandards query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -76,44 +93,51 @@ func GetRecipeStandards(c *gin.Context) {
 	})
 }
 
-func GetRecipeStandard(c *gin.Context) {
-	logger.Log.Info("GetRecipeStandard called", "id", c.Param("id"))
+func (h *RecipeStandardHandler) GetRecipeStandard(c *gin.Context) {
+	logger.From(c).Info("GetRecipeStandard called", "id", c.Param("id"))
 	id := c.Param("id")
 	var recipe models.RecipeStandard
 
 	// Preload related entities
-	if err := store.DB.GormClient.
+	if err := h.DB.WithContext(c).
 		Preload("Dish").
 		Preload("Kitchen").
 		Preload("Ingredient").
 		Preload("UpdatedBy").
 		First(&recipe, "recipe_id = ?", id).Error; err != nil {
-		logger.Log.Error("GetRecipeStandard not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe standard not found"})
+		logger.From(c).Error("GetRecipeStandard not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeRecipeStandardNotFound))
+		return
+	}
+	if !authorizeKitchen(c, recipe.KitchenID) {
 		return
 	}
 
 	// Convert to DTO and return
 	dto := recipe.ToDTO()
+	setETag(c, recipe.Version)
 	c.JSON(http.StatusOK, dto)
 }
 
-func CreateRecipeStandard(c *gin.Context) {
-	logger.Log.Info("CreateRecipeStandard called")
+func (h *RecipeStandardHandler) CreateRecipeStandard(c *gin.Context) {
+	logger.From(c).Info("CreateRecipeStandard called")
 	var recipe models.RecipeStandard
 	if err := c.ShouldBindJSON(&recipe); err != nil {
-		logger.Log.Error("CreateRecipeStandard bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("CreateRecipeStandard bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-	if err := store.DB.GormClient.Create(&recipe).Error; err != nil {
-		logger.Log.Error("CreateRecipeStandard db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if !authorizeKitchen(c, recipe.KitchenID) {
+		return
+	}
+	if err := h.DB.WithContext(c).Create(&recipe).Error; err != nil {
+		logger.From(c).Error("CreateRecipeStandard db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload with relationships
-	store.DB.GormClient.
+	h.DB.WithContext(c).
 		Preload("Dish").
 		Preload("Kitchen").
 		Preload("Ingredient").
@@ -126,18 +150,18 @@ func CreateRecipeStandard(c *gin.Context) {
 }
 
 // CreateRecipeStandardsBulk creates multiple recipe standards for a dish at once
-func CreateRecipeStandardsBulk(c *gin.Context) {
-	logger.Log.Info("CreateRecipeStandardsBulk called")
+func (h *RecipeStandardHandler) CreateRecipeStandardsBulk(c *gin.Context) {
+	logger.From(c).Info("CreateRecipeStandardsBulk called")
 
 	var recipes []models.RecipeStandard
 	if err := c.ShouldBindJSON(&recipes); err != nil {
-		logger.Log.Error("CreateRecipeStandardsBulk bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("CreateRecipeStandardsBulk bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	if len(recipes) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No recipes provided"})
+		apperr.Respond(c, apperr.Invalid("recipes", "min", "1"))
 		return
 	}
 
@@ -146,27 +170,31 @@ func CreateRecipeStandardsBulk(c *gin.Context) {
 	kitchenID := recipes[0].KitchenID
 	for i, recipe := range recipes {
 		if recipe.DishID != dishID {
-			logger.Log.Error("CreateRecipeStandardsBulk validation error", "error", "All recipes must have the same dish_id")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "All recipes must have the same dish_id"})
+			logger.From(c).Error("CreateRecipeStandardsBulk validation error", "error", "All recipes must have the same dish_id")
+			apperr.Respond(c, apperr.Invalid("dish_id", "same"))
 			return
 		}
 		if recipe.KitchenID != kitchenID {
-			logger.Log.Error("CreateRecipeStandardsBulk validation error", "error", "All recipes must have the same kitchen_id")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "All recipes must have the same kitchen_id"})
+			logger.From(c).Error("CreateRecipeStandardsBulk validation error", "error", "All recipes must have the same kitchen_id")
+			apperr.Respond(c, apperr.Invalid("kitchen_id", "same"))
 			return
 		}
 		if recipe.IngredientID == "" {
-			logger.Log.Error("CreateRecipeStandardsBulk validation error", "index", i, "error", "ingredient_id is required")
-			c.JSON(http.StatusBadRequest, gin.H{"error": "All recipes must have ingredient_id"})
+			logger.From(c).Error("CreateRecipeStandardsBulk validation error", "index", i, "error", "ingredient_id is required")
+			apperr.Respond(c, apperr.Invalid(fmt.Sprintf("[%d].ingredient_id", i), "required"))
 			return
 		}
 	}
 
+	if !authorizeKitchen(c, kitchenID) {
+		return
+	}
+
 	// Use transaction to ensure all-or-nothing
-	tx := store.DB.GormClient.Begin()
+	tx := h.DB.WithContext(c).Begin()
 	if tx.Error != nil {
-		logger.Log.Error("CreateRecipeStandardsBulk transaction begin error", "error", tx.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		logger.From(c).Error("CreateRecipeStandardsBulk transaction begin error", "error", tx.Error)
+		apperr.Respond(c, apperr.Internal(tx.Error))
 		return
 	}
 
@@ -174,36 +202,36 @@ func CreateRecipeStandardsBulk(c *gin.Context) {
 	for i := range recipes {
 		if err := tx.Create(&recipes[i]).Error; err != nil {
 			tx.Rollback()
-			logger.Log.Error("CreateRecipeStandardsBulk create error", "index", i, "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			logger.From(c).Error("CreateRecipeStandardsBulk create error", "index", i, "error", err)
+			apperr.Respond(c, apperr.Internal(err))
 			return
 		}
 	}
 
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
-		logger.Log.Error("CreateRecipeStandardsBulk commit error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		logger.From(c).Error("CreateRecipeStandardsBulk commit error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload all recipes with relationships
 	var createdRecipes []models.RecipeStandard
-	if err := store.DB.GormClient.
+	if err := h.DB.WithContext(c).
 		Preload("Dish").
 		Preload("Kitchen").
 		Preload("Ingredient").
 		Preload("UpdatedBy").
 		Where("dish_id = ? AND kitchen_id = ?", dishID, kitchenID).
 		Find(&createdRecipes).Error; err != nil {
-		logger.Log.Error("CreateRecipeStandardsBulk reload error", "error", err)
+		logger.From(c).Error("CreateRecipeStandardsBulk reload error", "error", err)
 		// Still return success since recipes were created
 	}
 
 	// Convert to DTOs
 	dtos := models.ConvertRecipeStandardsToDTO(createdRecipes)
 
-	logger.Log.Info("CreateRecipeStandardsBulk success", "count", len(recipes))
+	logger.From(c).Info("CreateRecipeStandardsBulk success", "count", len(recipes))
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Recipe standards created successfully",
 		"count":   len(recipes),
@@ -211,58 +239,144 @@ func CreateRecipeStandardsBulk(c *gin.Context) {
 	})
 }
 
-func UpdateRecipeStandard(c *gin.Context) {
-	logger.Log.Info("UpdateRecipeStandard called", "id", c.Param("id"))
+// UpdateRecipeStandardRequest is the body of UpdateRecipeStandard. Fields left out keep their
+// value; the recipe standard is the one in the URL and stays in its kitchen.
+type UpdateRecipeStandardRequest struct {
+	DishID       *string  `json:"dishId"`
+	IngredientID *string  `json:"ingredientId"`
+	Unit         *string  `json:"unit"`
+	StandardPer1 *float64 `json:"standardPer1"`
+	Note         *string  `json:"note"`
+	Amount       *float64 `json:"amount"`
+}
+
+// updates returns the columns the request changes
+func (r UpdateRecipeStandardRequest) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	if r.DishID != nil {
+		updates["dish_id"] = *r.DishID
+	}
+	if r.IngredientID != nil {
+		updates["ingredient_id"] = *r.IngredientID
+	}
+	if r.Unit != nil {
+		updates["unit"] = *r.Unit
+	}
+	if r.StandardPer1 != nil {
+		updates["quantity_per_serving"] = *r.StandardPer1
+	}
+	if r.Note != nil {
+		updates["notes"] = *r.Note
+	}
+	if r.Amount != nil {
+		updates["cost"] = *r.Amount
+	}
+	return updates
+}
+
+func (h *RecipeStandardHandler) UpdateRecipeStandard(c *gin.Context) {
+	logger.From(c).Info("UpdateRecipeStandard called", "id", c.Param("id"))
 	id := c.Param("id")
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 	var recipe models.RecipeStandard
-	if err := store.DB.GormClient.First(&recipe, "recipe_id = ?", id).Error; err != nil {
-		logger.Log.Error("UpdateRecipeStandard not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Recipe standard not found"})
+	if err := h.DB.WithContext(c).First(&recipe, "recipe_id = ?", id).Error; err != nil {
+		logger.From(c).Error("UpdateRecipeStandard not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeRecipeStandardNotFound))
 		return
 	}
-	if err := c.ShouldBindJSON(&recipe); err != nil {
-		logger.Log.Error("UpdateRecipeStandard bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !authorizeKitchen(c, recipe.KitchenID) {
+		return
+	}
+	if !cond.Matches(recipe.Version) {
+		h.recipeStandardConflict(c, id)
 		return
 	}
-	if err := store.DB.GormClient.Save(&recipe).Error; err != nil {
-		logger.Log.Error("UpdateRecipeStandard db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var req UpdateRecipeStandardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.From(c).Error("UpdateRecipeStandard bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	updates := req.updates()
+	if uid, ok := c.Get("identity"); ok {
+		updates["updated_by_user_id"] = uid
+	}
+	updates["version"] = nextVersion
+	// Only while still at the version read, a concurrent change waits for the row and then
+	// updates nothing
+	result := h.DB.WithContext(c).Model(&models.RecipeStandard{}).
+		Where("recipe_id = ? AND version = ?", id, recipe.Version).Updates(updates)
+	if result.Error != nil {
+		logger.From(c).Error("UpdateRecipeStandard db error", "error", result.Error)
+		apperr.Respond(c, apperr.Internal(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		h.recipeStandardConflict(c, id)
 		return
 	}
 
 	// Reload with relationships
-	store.DB.GormClient.
+	h.DB.WithContext(c).
 		Preload("Dish").
 		Preload("Kitchen").
 		Preload("Ingredient").
 		Preload("UpdatedBy").
-		First(&recipe, "recipe_id = ?", recipe.StandardID)
+		First(&recipe, "recipe_id = ?", id)
 
 	// Return DTO
 	dto := recipe.ToDTO()
+	setETag(c, recipe.Version)
 	c.JSON(http.StatusOK, dto)
 }
 
-func DeleteRecipeStandard(c *gin.Context) {
-	logger.Log.Info("DeleteRecipeStandard called", "id", c.Param("id"))
+// recipeStandardConflict answers a change of a recipe standard read at another version with
+// its current state
+func (h *RecipeStandardHandler) recipeStandardConflict(c *gin.Context, id string) {
+	var current models.RecipeStandard
+	if err := h.DB.WithContext(c).
+		Preload("Dish").
+		Preload("Kitchen").
+		Preload("Ingredient").
+		Preload("UpdatedBy").
+		First(&current, "recipe_id = ?", id).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeRecipeStandardNotFound))
+		return
+	}
+	versionConflict(c, current.Version, current.ToDTO())
+}
+
+func (h *RecipeStandardHandler) DeleteRecipeStandard(c *gin.Context) {
+	logger.From(c).Info("DeleteRecipeStandard called", "id", c.Param("id"))
 	id := c.Param("id")
-	if err := store.DB.GormClient.Delete(&models.RecipeStandard{}, "recipe_id = ?", id).Error; err != nil {
-		logger.Log.Error("DeleteRecipeStandard db error", "id", id, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var recipe models.RecipeStandard
+	if err := h.DB.WithContext(c).Select("recipe_id", "kitchen_id").First(&recipe, "recipe_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteRecipeStandard not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeRecipeStandardNotFound))
+		return
+	}
+	if !authorizeKitchen(c, recipe.KitchenID) {
+		return
+	}
+	if err := h.DB.WithContext(c).Delete(&models.RecipeStandard{}, "recipe_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteRecipeStandard db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Recipe standard deleted successfully"})
 }
 
 // GetRecipeStandardsByDish with pagination and search - Returns ResourceCollection format with DTOs
-func GetRecipeStandardsByDish(c *gin.Context) {
-	logger.Log.Info("GetRecipeStandardsByDish called", "dishId", c.Param("dishId"))
+func (h *DishHandler) GetRecipeStandardsByDish(c *gin.Context) {
+	logger.From(c).Info("GetRecipeStandardsByDish called", "dishId", c.Param("dishId"))
 	dishId := c.Param("dishId")
 
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -274,38 +388,14 @@ func GetRecipeStandardsByDish(c *gin.Context) {
 		params.SortDir,
 	)
 
-	var total int64
-	countDB := store.DB.GormClient.Model(&models.RecipeStandard{}).Where("dish_id = ?", dishId)
-
-	searchConfig := utils.SearchConfig{
-		Fields: []string{"ingredient_id"},
-		Fuzzy:  true,
-	}
-	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
-
-	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetRecipeStandardsByDish count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	kitchens, ok := callerKitchens(c, "")
+	if !ok {
 		return
 	}
-
-	var recipes []models.RecipeStandard
-	db := store.DB.GormClient.Model(&models.RecipeStandard{}).Where("dish_id = ?", dishId)
-	db = utils.ApplySearch(db, params.Search, searchConfig)
-
-	allowedSortFields := map[string]string{
-		"ingredientId": "ingredient_id",
-		"standardPer1": "quantity_per_serving",
-	}
-	db = utils.ApplySort(db, params.SortBy, params.SortDir, allowedSortFields)
-	db = utils.ApplyPagination(db, params.Page, params.PageSize)
-
-	// Preload related entities to get names
-	db = db.Preload("Dish").Preload("Kitchen").Preload("Ingredient").Preload("UpdatedBy")
-
-	if err := db.Find(&recipes).Error; err != nil {
-		logger.Log.Error("GetRecipeStandardsByDish query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	recipes, total, err := h.Recipes.ListStandards(c, dishId, kitchens, params)
+	if err != nil {
+		logger.From(c).Error("GetRecipeStandardsByDish query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -320,13 +410,16 @@ func GetRecipeStandardsByDish(c *gin.Context) {
 }
 
 // GetRecipeStandardsByKitchen with pagination and search - Returns ResourceCollection format with DTOs
-func GetRecipeStandardsByKitchen(c *gin.Context) {
-	logger.Log.Info("GetRecipeStandardsByKitchen called", "kitchenId", c.Param("kitchenId"))
+func (h *RecipeStandardHandler) GetRecipeStandardsByKitchen(c *gin.Context) {
+	logger.From(c).Info("GetRecipeStandardsByKitchen called", "kitchenId", c.Param("kitchenId"))
 	kitchenId := c.Param("kitchenId")
+	if !authorizeKitchen(c, kitchenId) {
+		return
+	}
 
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -339,7 +432,7 @@ func GetRecipeStandardsByKitchen(c *gin.Context) {
 	)
 
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.RecipeStandard{}).Where("kitchen_id = ?", kitchenId)
+	countDB := h.DB.WithContext(c).Model(&models.RecipeStandard{}).Where("kitchen_id = ?", kitchenId)
 
 	searchConfig := utils.SearchConfig{
 		Fields: []string{"dish_id", "ingredient_id"},
@@ -348,13 +441,13 @@ func GetRecipeStandardsByKitchen(c *gin.Context) {
 	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetRecipeStandardsByKitchen count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetRecipeStandardsByKitchen count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var recipes []models.RecipeStandard
-	db := store.DB.GormClient.Model(&models.RecipeStandard{}).Where("kitchen_id = ?", kitchenId)
+	db := h.DB.WithContext(c).Model(&models.RecipeStandard{}).Where("kitchen_id = ?", kitchenId)
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
 	allowedSortFields := map[string]string{
@@ -369,8 +462,8 @@ func GetRecipeStandardsByKitchen(c *gin.Context) {
 	db = db.Preload("Dish").Preload("Kitchen").Preload("Ingredient").Preload("UpdatedBy")
 
 	if err := db.Find(&recipes).Error; err != nil {
-		logger.Log.Error("GetRecipeStandardsByKitchen query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetRecipeStandardsByKitchen query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -385,14 +478,17 @@ func GetRecipeStandardsByKitchen(c *gin.Context) {
 }
 
 // GetRecipeStandardsByDishAndKitchen with pagination and search - Returns ResourceCollection format with DTOs
-func GetRecipeStandardsByDishAndKitchen(c *gin.Context) {
-	logger.Log.Info("GetRecipeStandardsByDishAndKitchen called", "dishId", c.Param("dishId"), "kitchenId", c.Param("kitchenId"))
+func (h *RecipeStandardHandler) GetRecipeStandardsByDishAndKitchen(c *gin.Context) {
+	logger.From(c).Info("GetRecipeStandardsByDishAndKitchen called", "dishId", c.Param("dishId"), "kitchenId", c.Param("kitchenId"))
 	dishId := c.Param("dishId")
 	kitchenId := c.Param("kitchenId")
+	if !authorizeKitchen(c, kitchenId) {
+		return
+	}
 
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -405,7 +501,7 @@ func GetRecipeStandardsByDishAndKitchen(c *gin.Context) {
 	)
 
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.RecipeStandard{}).
+	countDB := h.DB.WithContext(c).Model(&models.RecipeStandard{}).
 		Where("dish_id = ? AND kitchen_id = ?", dishId, kitchenId)
 
 	searchConfig := utils.SearchConfig{
@@ -415,13 +511,13 @@ func GetRecipeStandardsByDishAndKitchen(c *gin.Context) {
 	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetRecipeStandardsByDishAndKitchen count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetRecipeStandardsByDishAndKitchen count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var recipes []models.RecipeStandard
-	db := store.DB.GormClient.Model(&models.RecipeStandard{}).
+	db := h.DB.WithContext(c).Model(&models.RecipeStandard{}).
 		Where("dish_id = ? AND kitchen_id = ?", dishId, kitchenId)
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
@@ -436,8 +532,8 @@ func GetRecipeStandardsByDishAndKitchen(c *gin.Context) {
 	db = db.Preload("Dish").Preload("Kitchen").Preload("Ingredient").Preload("UpdatedBy")
 
 	if err := db.Find(&recipes).Error; err != nil {
-		logger.Log.Error("GetRecipeStandardsByDishAndKitchen query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetRecipeStandardsByDishAndKitchen query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"adong-be/rbac"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// updateStatements records the UPDATE statements run on db, with their values inlined
+func updateStatements(t *testing.T, db *gorm.DB) *[]string {
+	t.Helper()
+	var statements []string
+	err := db.Callback().Update().After("gorm:update").Register("test:record_update", func(tx *gorm.DB) {
+		statements = append(statements, tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...))
+	})
+	require.NoError(t, err)
+	return &statements
+}
+
+// putJSON sends body to path on r with If-Match: *
+func putJSON(r *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("PUT", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", "*")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestUpdateRecipeStandard_UpdatesTheRecordInTheURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dryRunDB(t)
+	statements := updateStatements(t, db)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(rbac.ScopeKey, rbac.Scope{AllKitchens: true}) })
+	r.PUT("/recipe-standards/:id", NewRecipeStandardHandler(db).UpdateRecipeStandard)
+
+	putJSON(r, "/recipe-standards/7", `{"standardId":99,"kitchenId":"K002","version":5,"note":"Bỏ hành"}`)
+
+	require.Len(t, *statements, 1)
+	sql := (*statements)[0]
+	assert.Contains(t, sql, "recipe_id = '7'")
+	assert.NotContains(t, sql, "99")
+	assert.NotContains(t, sql, "kitchen_id")
+	assert.Contains(t, sql, `"notes"='Bỏ hành'`)
+	// Fields left out of the body keep their value
+	assert.NotContains(t, sql, "quantity_per_serving")
+	assert.NotContains(t, sql, "created_date")
+}
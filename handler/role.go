@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/rbac"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RoleHandler manages roles and the permissions granted to them. Every change
+// invalidates the authorizer so it takes effect on the next request.
+type RoleHandler struct {
+	DB         *gorm.DB
+	Authorizer *rbac.Authorizer
+}
+
+func NewRoleHandler(db *gorm.DB, authorizer *rbac.Authorizer) *RoleHandler {
+	return &RoleHandler{DB: db, Authorizer: authorizer}
+}
+
+// RoleRequest is the body of CreateRole / UpdateRole
+type RoleRequest struct {
+	RoleName         string   `json:"roleName"`
+	Description      string   `json:"description"`
+	IsSuperuser      bool     `json:"isSuperuser"`
+	RequireTwoFactor bool     `json:"requireTwoFactor"` // users of the role must sign in with a TOTP code
+	Permissions      []string `json:"permissions"`
+}
+
+func (r RoleRequest) validatePermissions() error {
+	for i, p := range r.Permissions {
+		if !rbac.Known(p) {
+			return apperr.Invalid(fmt.Sprintf("permissions[%d]", i), "exists", p)
+		}
+	}
+	return nil
+}
+
+func rolePermissions(roleName string, keys []string) []models.RolePermission {
+	perms := make([]models.RolePermission, 0, len(keys))
+	for _, key := range uniqueStrings(keys) {
+		perms = append(perms, models.RolePermission{RoleName: roleName, PermissionKey: key})
+	}
+	return perms
+}
+
+// GetPermissions returns the permission catalogue roles are built from
+func (h *RoleHandler) GetPermissions(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetPermissions called", "user_id", uid)
+	c.JSON(http.StatusOK, gin.H{"data": rbac.Catalogue})
+}
+
+// GetRoles lists every role with its permissions
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetRoles called", "user_id", uid)
+
+	var roles []models.Role
+	if err := h.DB.WithContext(c).Preload("Permissions").Order("role_name ASC").Find(&roles).Error; err != nil {
+		logger.From(c).Error("GetRoles query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetRole called", "name", c.Param("name"), "user_id", uid)
+	name := c.Param("name")
+
+	var role models.Role
+	if err := h.DB.WithContext(c).Preload("Permissions").First(&role, "role_name = ?", name).Error; err != nil {
+		logger.From(c).Error("GetRole not found", "name", name, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeRoleNotFound))
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("CreateRole called", "user_id", uid)
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.From(c).Error("CreateRole bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if req.RoleName == "" {
+		apperr.Respond(c, apperr.Invalid("roleName", "required"))
+		return
+	}
+	if err := req.validatePermissions(); err != nil {
+		apperr.Respond(c, err)
+		return
+	}
+
+	var count int64
+	h.DB.WithContext(c).Model(&models.Role{}).Where("role_name = ?", req.RoleName).Count(&count)
+	if count > 0 {
+		apperr.Respond(c, apperr.New(apperr.CodeRoleExists))
+		return
+	}
+
+	role := models.Role{
+		RoleName:         req.RoleName,
+		Description:      req.Description,
+		IsSuperuser:      req.IsSuperuser,
+		RequireTwoFactor: req.RequireTwoFactor,
+		Permissions:      rolePermissions(req.RoleName, req.Permissions),
+	}
+	if err := h.DB.WithContext(c).Create(&role).Error; err != nil {
+		logger.From(c).Error("CreateRole db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	h.Authorizer.Invalidate()
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes the description and flags and replaces the permission set of a role
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("UpdateRole called", "name", c.Param("name"), "user_id", uid)
+	name := c.Param("name")
+
+	var role models.Role
+	if err := h.DB.WithContext(c).First(&role, "role_name = ?", name).Error; err != nil {
+		logger.From(c).Error("UpdateRole not found", "name", name, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeRoleNotFound))
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.From(c).Error("UpdateRole bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if err := req.validatePermissions(); err != nil {
+		apperr.Respond(c, err)
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		// The last superuser role can't be downgraded, nobody could manage roles anymore
+		if role.IsSuperuser && !req.IsSuperuser {
+			if err := ensureOtherSuperuser(tx, role.RoleName); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&role).Updates(map[string]interface{}{
+			"description":        req.Description,
+			"is_superuser":       req.IsSuperuser,
+			"require_two_factor": req.RequireTwoFactor,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("role_name = ?", role.RoleName).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		perms := rolePermissions(role.RoleName, req.Permissions)
+		if len(perms) == 0 {
+			return nil
+		}
+		return tx.Create(&perms).Error
+	})
+	if errors.Is(err, errLastSuperuser) {
+		apperr.Respond(c, apperr.Wrap(apperr.CodeLastSuperuser, err))
+		return
+	}
+	if err != nil {
+		logger.From(c).Error("UpdateRole db error", "name", name, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	h.Authorizer.Invalidate()
+
+	h.DB.WithContext(c).Preload("Permissions").First(&role, "role_name = ?", name)
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole removes a role that no user is assigned to
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("DeleteRole called", "name", c.Param("name"), "user_id", uid)
+	name := c.Param("name")
+
+	var role models.Role
+	if err := h.DB.WithContext(c).First(&role, "role_name = ?", name).Error; err != nil {
+		logger.From(c).Error("DeleteRole not found", "name", name, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeRoleNotFound))
+		return
+	}
+
+	var users int64
+	if err := h.DB.WithContext(c).Model(&models.User{}).Where("role = ?", name).Count(&users).Error; err != nil {
+		logger.From(c).Error("DeleteRole count users error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if users > 0 {
+		apperr.Respond(c, apperr.New(apperr.CodeRoleInUse).With("users", users))
+		return
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if role.IsSuperuser {
+			if err := ensureOtherSuperuser(tx, role.RoleName); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("role_name = ?", name).Delete(&models.RolePermission{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Role{}, "role_name = ?", name).Error
+	})
+	if errors.Is(err, errLastSuperuser) {
+		apperr.Respond(c, apperr.Wrap(apperr.CodeLastSuperuser, err))
+		return
+	}
+	if err != nil {
+		logger.From(c).Error("DeleteRole db error", "name", name, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	h.Authorizer.Invalidate()
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+var errLastSuperuser = errors.New("at least one superuser role must remain")
+
+func ensureOtherSuperuser(tx *gorm.DB, roleName string) error {
+	var count int64
+	if err := tx.Model(&models.Role{}).
+		Where("is_superuser = true AND role_name <> ?", roleName).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return errLastSuperuser
+	}
+	return nil
+}
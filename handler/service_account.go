@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/auth/apikey"
+	"adong-be/auth/password"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/rbac"
+	"adong-be/store"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ServiceAccountHandler manages service accounts, the users integrations such as the POS and
+// accounting scripts act as, and their API keys. A service account has a role and kitchen
+// assignments like any user but cannot sign in with a password.
+type ServiceAccountHandler struct {
+	Store *store.Store
+}
+
+func NewServiceAccountHandler(st *store.Store) *ServiceAccountHandler {
+	return &ServiceAccountHandler{Store: st}
+}
+
+// ServiceAccountRequest is the body of CreateServiceAccount. KitchenIDs restricts the
+// account to those kitchens; further changes go through PUT /api/users/:id/kitchens.
+type ServiceAccountRequest struct {
+	UserID     string   `json:"userId"`
+	UserName   string   `json:"userName" binding:"required"`
+	FullName   string   `json:"fullName" binding:"required"`
+	Role       string   `json:"role" binding:"required"`
+	KitchenIDs []string `json:"kitchenIds"`
+}
+
+// APIKeyRequest is the body of CreateAPIKey
+type APIKeyRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	Permissions []string   `json:"permissions" binding:"required"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+}
+
+// RotateAPIKeyRequest is the body of RotateAPIKey. GracePeriod (e.g. "24h") keeps the old key
+// working while the integration switches over; without it the old key stops at once.
+type RotateAPIKeyRequest struct {
+	GracePeriod string `json:"gracePeriod"`
+}
+
+func (r APIKeyRequest) validate() error {
+	if len(r.Permissions) == 0 {
+		return errors.New("permissions must list at least one permission")
+	}
+	for _, p := range r.Permissions {
+		if !rbac.Known(p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.After(time.Now()) {
+		return errors.New("expiresAt must be in the future")
+	}
+	return nil
+}
+
+// serviceAccount loads the service account of the :id parameter, writing a 404 otherwise
+func (h *ServiceAccountHandler) serviceAccount(c *gin.Context) (*models.User, bool) {
+	var user models.User
+	err := h.Store.GormClient.WithContext(c).
+		First(&user, "user_id = ? AND account_type = ?", c.Param("id"), models.AccountTypeService).Error
+	if err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeServiceAccountNotFound))
+		return nil, false
+	}
+	return &user, true
+}
+
+// apiKeyID parses the :keyId parameter, writing a 400 otherwise
+func apiKeyID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("keyId"), 10, 64)
+	if err != nil {
+		apperr.Respond(c, apperr.Invalid("keyId", "type", "int"))
+		return 0, false
+	}
+	return id, true
+}
+
+// issueAPIKey builds a new key for user and returns it with the plain key; the caller stores it
+func (h *ServiceAccountHandler) issueAPIKey(c *gin.Context, user *models.User, name string, permissions []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	raw, prefix, hash, err := apikey.Generate()
+	if err != nil {
+		return nil, "", err
+	}
+	uid, _ := c.Get("identity")
+	createdBy, _ := uid.(string)
+	key := &models.APIKey{
+		UserID:          user.UserID,
+		Name:            name,
+		Prefix:          prefix,
+		SecretHash:      hash,
+		ExpiresAt:       expiresAt,
+		CreatedByUserID: &createdBy,
+	}
+	for _, p := range uniqueStrings(permissions) {
+		key.Permissions = append(key.Permissions, models.APIKeyPermission{PermissionKey: p})
+	}
+	return key, raw, nil
+}
+
+// GetServiceAccounts lists the service accounts with their kitchens
+func (h *ServiceAccountHandler) GetServiceAccounts(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetServiceAccounts called", "user_id", uid)
+
+	var items []models.User
+	if err := h.Store.GormClient.WithContext(c).Preload("Kitchens").
+		Where("account_type = ?", models.AccountTypeService).
+		Order("user_name").Find(&items).Error; err != nil {
+		logger.From(c).Error("GetServiceAccounts query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// CreateServiceAccount creates a service account. It has no usable password.
+func (h *ServiceAccountHandler) CreateServiceAccount(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("CreateServiceAccount called", "user_id", uid)
+
+	var req ServiceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.From(c).Error("CreateServiceAccount bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if !validUserRole(c, h.Store.GormClient, req.Role) {
+		return
+	}
+	kitchenIDs := uniqueStrings(req.KitchenIDs)
+	if len(kitchenIDs) > 0 {
+		var found int64
+		if err := h.Store.GormClient.WithContext(c).Model(&models.Kitchen{}).Where("kitchen_id IN ?", kitchenIDs).Count(&found).Error; err != nil {
+			logger.From(c).Error("CreateServiceAccount kitchen check error", "error", err)
+			apperr.Respond(c, apperr.Internal(err))
+			return
+		}
+		if int(found) != len(kitchenIDs) {
+			apperr.Respond(c, apperr.Invalid("kitchenIds", "exists"))
+			return
+		}
+	}
+	locked, err := password.Unusable()
+	if err != nil {
+		logger.From(c).Error("CreateServiceAccount password error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	active := true
+	user := models.User{
+		UserID:      req.UserID,
+		UserName:    req.UserName,
+		Password:    locked,
+		FullName:    req.FullName,
+		Role:        req.Role,
+		Active:      &active,
+		AccountType: models.AccountTypeService,
+	}
+	if user.UserID == "" {
+		user.UserID = uuid.New().String()
+	}
+	err = h.Store.GormClient.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		for _, kitchenID := range kitchenIDs {
+			if err := tx.Create(&models.UserKitchen{UserID: user.UserID, KitchenID: kitchenID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.From(c).Error("CreateServiceAccount db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusCreated, user)
+}
+
+// DeleteServiceAccount deactivates a service account and revokes all its keys. The row is kept
+// because documents and the audit log refer to it.
+func (h *ServiceAccountHandler) DeleteServiceAccount(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("DeleteServiceAccount called", "id", c.Param("id"), "user_id", uid)
+	user, ok := h.serviceAccount(c)
+	if !ok {
+		return
+	}
+
+	err := h.Store.GormClient.WithContext(c).Model(user).Update("active", false).Error
+	if err == nil {
+		err = h.Store.RevokeAllAPIKeys(c, user.UserID)
+	}
+	if err != nil {
+		logger.From(c).Error("DeleteServiceAccount db error", "id", user.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Service account deactivated"})
+}
+
+// GetAPIKeys lists the keys of a service account. Secrets are never returned.
+func (h *ServiceAccountHandler) GetAPIKeys(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetAPIKeys called", "id", c.Param("id"), "user_id", uid)
+	user, ok := h.serviceAccount(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.Store.ListAPIKeys(c, user.UserID)
+	if err != nil {
+		logger.From(c).Error("GetAPIKeys query error", "id", user.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+// CreateAPIKey issues a key scoped to the requested permissions. The plain key is only part
+// of this response.
+func (h *ServiceAccountHandler) CreateAPIKey(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("CreateAPIKey called", "id", c.Param("id"), "user_id", uid)
+	user, ok := h.serviceAccount(c)
+	if !ok {
+		return
+	}
+
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.From(c).Error("CreateAPIKey bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if err := req.validate(); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if user.Active == nil || !*user.Active {
+		apperr.Respond(c, apperr.New(apperr.CodeServiceAccountInactive))
+		return
+	}
+
+	key, raw, err := h.issueAPIKey(c, user, req.Name, req.Permissions, req.ExpiresAt)
+	if err == nil {
+		err = h.Store.CreateAPIKey(c, key)
+	}
+	if err != nil {
+		logger.From(c).Error("CreateAPIKey db error", "id", user.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": raw, "apiKey": key})
+}
+
+// RotateAPIKey replaces a key by a new one with the same name, permissions and lifetime. The
+// old key keeps working for the requested grace period.
+func (h *ServiceAccountHandler) RotateAPIKey(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("RotateAPIKey called", "id", c.Param("id"), "key_id", c.Param("keyId"), "user_id", uid)
+	user, ok := h.serviceAccount(c)
+	if !ok {
+		return
+	}
+	keyID, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+
+	var req RotateAPIKeyRequest
+	// The body is optional
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	var grace time.Duration
+	if req.GracePeriod != "" {
+		d, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || d < 0 || d > 7*24*time.Hour {
+			apperr.Respond(c, apperr.Invalid("gracePeriod", "duration", "168h"))
+			return
+		}
+		grace = d
+	}
+
+	var old models.APIKey
+	if err := h.Store.GormClient.WithContext(c).Preload("Permissions").
+		First(&old, "key_id = ? AND user_id = ?", keyID, user.UserID).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeAPIKeyNotFound))
+		return
+	}
+	now := time.Now()
+	if !old.Usable(now) {
+		apperr.Respond(c, apperr.New(apperr.CodeAPIKeyRevoked))
+		return
+	}
+
+	// The new key gets the lifetime the old one was issued with
+	var expiresAt *time.Time
+	if old.ExpiresAt != nil {
+		t := now.Add(old.ExpiresAt.Sub(old.CreatedDate))
+		expiresAt = &t
+	}
+	key, raw, err := h.issueAPIKey(c, user, old.Name, old.PermissionKeys(), expiresAt)
+	if err == nil {
+		err = h.Store.RotateAPIKey(c, &old, key, now.Add(grace))
+	}
+	if err != nil {
+		logger.From(c).Error("RotateAPIKey db error", "key_id", keyID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"key": raw, "apiKey": key, "previousKeyValidUntil": now.Add(grace)})
+}
+
+// RevokeAPIKey stops a key from working immediately
+func (h *ServiceAccountHandler) RevokeAPIKey(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("RevokeAPIKey called", "id", c.Param("id"), "key_id", c.Param("keyId"), "user_id", uid)
+	user, ok := h.serviceAccount(c)
+	if !ok {
+		return
+	}
+	keyID, ok := apiKeyID(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.Store.RevokeAPIKey(c, user.UserID, keyID)
+	if err != nil {
+		logger.From(c).Error("RevokeAPIKey db error", "key_id", keyID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if !revoked {
+		apperr.Respond(c, apperr.New(apperr.CodeAPIKeyNotFound))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
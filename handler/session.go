@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/store"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionHandler lets admins see where a user is signed in and sign them out remotely
+type SessionHandler struct {
+	Store *store.Store
+}
+
+func NewSessionHandler(st *store.Store) *SessionHandler {
+	return &SessionHandler{Store: st}
+}
+
+// GetUserSessions lists the sessions a user is still signed in with, most recently active first
+func (h *SessionHandler) GetUserSessions(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetUserSessions called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var user models.User
+	if err := h.Store.GormClient.WithContext(c).First(&user, "user_id = ?", id).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeUserNotFound))
+		return
+	}
+	sessions, err := h.Store.ListUserSessions(c, id)
+	if err != nil {
+		logger.From(c).Error("GetUserSessions db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeUserSession signs a user out of one session
+func (h *SessionHandler) RevokeUserSession(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("RevokeUserSession called", "id", c.Param("id"), "session_id", c.Param("sessionId"), "user_id", uid)
+	id, sessionID := c.Param("id"), c.Param("sessionId")
+
+	ended, err := h.Store.EndUserSession(c, id, sessionID)
+	if err != nil {
+		logger.From(c).Error("RevokeUserSession db error", "id", id, "session_id", sessionID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if !ended {
+		apperr.Respond(c, dbError(err, apperr.CodeSessionNotFound))
+		return
+	}
+	logger.From(c).Info("session revoked", "id", id, "session_id", sessionID, "by", uid)
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeUserSessions signs a user out everywhere
+func (h *SessionHandler) RevokeUserSessions(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("RevokeUserSessions called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var user models.User
+	if err := h.Store.GormClient.WithContext(c).First(&user, "user_id = ?", id).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeUserNotFound))
+		return
+	}
+	ended, err := h.Store.EndAllUserSessions(c, id)
+	if err != nil {
+		logger.From(c).Error("RevokeUserSessions db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	logger.From(c).Info("user sessions revoked", "id", id, "count", ended, "by", uid)
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked", "revoked": ended})
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/delivery"
+	"adong-be/logger"
+	"adong-be/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// parseDeliveryDate parses a YYYY-MM-DD date (a longer timestamp is cut to its date part)
+// in the server's local time zone
+func parseDeliveryDate(s string) (*time.Time, error) {
+	if len(s) > 10 {
+		s = s[:10]
+	}
+	d, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// undeliverableExcluded reads ?undeliverable=exclude|flag; suppliers that cannot deliver
+// are excluded by default and only flagged with "flag"
+func undeliverableExcluded(c *gin.Context) bool {
+	return c.DefaultQuery("undeliverable", "exclude") != "flag"
+}
+
+// checkDeliveries evaluates the delivery calendar of each supplier for kitchenID and deliveryDate
+func checkDeliveries(db *gorm.DB, supplierIDs []string, kitchenID string, deliveryDate, now time.Time) (map[string]delivery.Status, error) {
+	statuses := make(map[string]delivery.Status, len(supplierIDs))
+	if len(supplierIDs) == 0 {
+		return statuses, nil
+	}
+
+	var schedules []models.SupplierDeliverySchedule
+	if err := db.Where("supplier_id IN ? AND active = true", supplierIDs).Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	bySupplier := make(map[string][]models.SupplierDeliverySchedule)
+	for _, s := range schedules {
+		bySupplier[s.SupplierID] = append(bySupplier[s.SupplierID], s)
+	}
+	for _, id := range supplierIDs {
+		statuses[id] = delivery.Check(bySupplier[id], kitchenID, deliveryDate, now)
+	}
+	return statuses, nil
+}
+
+// GetSupplierDeliverySchedules lists the delivery schedule of a supplier, general and per kitchen
+func (h *SupplierHandler) GetSupplierDeliverySchedules(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetSupplierDeliverySchedules called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var schedules []models.SupplierDeliverySchedule
+	if err := h.DB.WithContext(c).Where("supplier_id = ?", id).
+		Order("kitchen_id NULLS FIRST, weekday ASC").
+		Find(&schedules).Error; err != nil {
+		logger.From(c).Error("GetSupplierDeliverySchedules db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"supplierId": id, "schedules": schedules})
+}
+
+// DeliverySchedulesRequest is the body of ReplaceSupplierDeliverySchedules
+type DeliverySchedulesRequest struct {
+	Schedules []models.SupplierDeliverySchedule `json:"schedules" binding:"dive"`
+}
+
+// ReplaceSupplierDeliverySchedules replaces the whole delivery schedule of a supplier.
+// An empty list means the supplier delivers every day.
+func (h *SupplierHandler) ReplaceSupplierDeliverySchedules(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("ReplaceSupplierDeliverySchedules called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var supplier models.Supplier
+	if err := h.DB.WithContext(c).First(&supplier, "supplier_id = ?", id).Error; err != nil {
+		logger.From(c).Error("ReplaceSupplierDeliverySchedules not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeSupplierNotFound))
+		return
+	}
+
+	var request DeliverySchedulesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.From(c).Error("ReplaceSupplierDeliverySchedules bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	for i := range request.Schedules {
+		s := &request.Schedules[i]
+		if _, _, err := delivery.ParseCutoff(s.CutoffTime); err != nil {
+			apperr.Respond(c, apperr.Bind(err))
+			return
+		}
+		s.ScheduleID = 0
+		s.SupplierID = supplier.SupplierID
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("supplier_id = ?", supplier.SupplierID).Delete(&models.SupplierDeliverySchedule{}).Error; err != nil {
+			return err
+		}
+		if len(request.Schedules) == 0 {
+			return nil
+		}
+		return tx.Create(&request.Schedules).Error
+	})
+	if err != nil {
+		logger.From(c).Error("ReplaceSupplierDeliverySchedules db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"supplierId": supplier.SupplierID, "schedules": request.Schedules})
+}
+
+// CheckSupplierDelivery answers whether a supplier can deliver to ?kitchen_id= on ?date=
+// and until when the order must be placed
+func (h *SupplierHandler) CheckSupplierDelivery(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("CheckSupplierDelivery called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	date, err := parseDeliveryDate(c.Query("date"))
+	if err != nil {
+		apperr.Respond(c, apperr.Invalid("date", "date", "YYYY-MM-DD"))
+		return
+	}
+	kitchenID := c.Query("kitchen_id")
+
+	statuses, err := checkDeliveries(h.DB.WithContext(c), []string{id}, kitchenID, *date, time.Now())
+	if err != nil {
+		logger.From(c).Error("CheckSupplierDelivery db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"supplierId":   id,
+		"kitchenId":    kitchenID,
+		"deliveryDate": date.Format("2006-01-02"),
+		"delivery":     statuses[id],
+	})
+}
@@ -1,9 +1,9 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/logger"
 	"adong-be/models"
-	"adong-be/store"
 	"adong-be/utils"
 	"net/http"
 	"time"
@@ -12,19 +12,27 @@ import (
 	"gorm.io/gorm"
 )
 
-func GetSupplierPrices(c *gin.Context) {
-	logger.Log.Info("GetSupplierPrices called")
+type SupplierPriceHandler struct {
+	DB *gorm.DB
+}
+
+func NewSupplierPriceHandler(db *gorm.DB) *SupplierPriceHandler {
+	return &SupplierPriceHandler{DB: db}
+}
+
+func (h *SupplierPriceHandler) GetSupplierPrices(c *gin.Context) {
+	logger.From(c).Info("GetSupplierPrices called")
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		logger.Log.Error("GetSupplierPrices bind query error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSupplierPrices bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
 	// Get date range parameters
 	effectiveFrom := c.Query("effective_from")
 	effectiveTo := c.Query("effective_to")
-	logger.Log.Debug("receive query", "Effective From:", effectiveFrom, "Effective To:", effectiveTo)
+	logger.From(c).Debug("receive query", "Effective From:", effectiveFrom, "Effective To:", effectiveTo)
 
 	params = models.GetPaginationParams(
 		params.Page,
@@ -35,7 +43,7 @@ func GetSupplierPrices(c *gin.Context) {
 	)
 
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.SupplierPrice{})
+	countDB := h.DB.WithContext(c).Model(&models.SupplierPrice{})
 
 	searchConfig := utils.SearchConfig{
 		Fields: []string{"product_name", "ingredient_id", "supplier_id",
@@ -48,13 +56,13 @@ func GetSupplierPrices(c *gin.Context) {
 	countDB = applyDateRangeFilter(countDB, effectiveFrom, effectiveTo)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetSupplierPrices count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSupplierPrices count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var prices []models.SupplierPrice
-	db := store.DB.GormClient.Model(&models.SupplierPrice{})
+	db := h.DB.WithContext(c).Model(&models.SupplierPrice{})
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
 	// Apply date range filters for data query
@@ -76,8 +84,8 @@ func GetSupplierPrices(c *gin.Context) {
 	db = db.Preload("Ingredient").Preload("Supplier")
 
 	if err := db.Find(&prices).Error; err != nil {
-		logger.Log.Error("GetSupplierPrices query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSupplierPrices query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -145,34 +153,35 @@ func applyDateRangeFilter(db *gorm.DB, effectiveFrom, effectiveTo string) *gorm.
 	return db
 }
 
-func GetSupplierPrice(c *gin.Context) {
-	logger.Log.Info("GetSupplierPrice called", "id", c.Param("id"))
+func (h *SupplierPriceHandler) GetSupplierPrice(c *gin.Context) {
+	logger.From(c).Info("GetSupplierPrice called", "id", c.Param("id"))
 	id := c.Param("id")
 	var price models.SupplierPrice
 
 	// Preload related entities to get names
-	if err := store.DB.GormClient.
+	if err := h.DB.WithContext(c).
 		Preload("Ingredient").
 		Preload("Supplier").
 		First(&price, "product_id = ?", id).Error; err != nil {
-		logger.Log.Error("GetSupplierPrice not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier price not found"})
+		logger.From(c).Error("GetSupplierPrice not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeSupplierPriceNotFound))
 		return
 	}
 
 	// Convert to DTO and return
 	dto := price.ToDTO()
+	setETag(c, price.Version)
 	c.JSON(http.StatusOK, dto)
 }
 
 // GetSupplierPricesByIngredient - Get all supplier prices for a specific ingredient
-func GetSupplierPricesByIngredient(c *gin.Context) {
-	logger.Log.Info("GetSupplierPricesByIngredient called", "ingredientId", c.Param("ingredientId"))
+func (h *SupplierPriceHandler) GetSupplierPricesByIngredient(c *gin.Context) {
+	logger.From(c).Info("GetSupplierPricesByIngredient called", "ingredientId", c.Param("ingredientId"))
 	ingredientId := c.Param("ingredientId")
 
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -185,7 +194,7 @@ func GetSupplierPricesByIngredient(c *gin.Context) {
 	)
 
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.SupplierPrice{}).Where("ingredient_id = ?", ingredientId)
+	countDB := h.DB.WithContext(c).Model(&models.SupplierPrice{}).Where("ingredient_id = ?", ingredientId)
 
 	searchConfig := utils.SearchConfig{
 		Fields: []string{"tensanpham", "nhacungcapid", "phanloai"},
@@ -194,13 +203,13 @@ func GetSupplierPricesByIngredient(c *gin.Context) {
 	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetSupplierPricesByIngredient count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSupplierPricesByIngredient count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var prices []models.SupplierPrice
-	db := store.DB.GormClient.Model(&models.SupplierPrice{}).Where("ingredient_id = ?", ingredientId)
+	db := h.DB.WithContext(c).Model(&models.SupplierPrice{}).Where("ingredient_id = ?", ingredientId)
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
 	allowedSortFields := map[string]string{
@@ -217,8 +226,8 @@ func GetSupplierPricesByIngredient(c *gin.Context) {
 	db = db.Preload("Ingredient").Preload("Supplier")
 
 	if err := db.Find(&prices).Error; err != nil {
-		logger.Log.Error("GetSupplierPricesByIngredient query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSupplierPricesByIngredient query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -233,13 +242,13 @@ func GetSupplierPricesByIngredient(c *gin.Context) {
 }
 
 // GetSupplierPricesBySupplier - Get all supplier prices for a specific supplier
-func GetSupplierPricesBySupplier(c *gin.Context) {
-	logger.Log.Info("GetSupplierPricesBySupplier called", "supplierId", c.Param("supplierId"))
+func (h *SupplierPriceHandler) GetSupplierPricesBySupplier(c *gin.Context) {
+	logger.From(c).Info("GetSupplierPricesBySupplier called", "supplierId", c.Param("supplierId"))
 	supplierId := c.Param("supplierId")
 
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -252,7 +261,7 @@ func GetSupplierPricesBySupplier(c *gin.Context) {
 	)
 
 	var total int64
-	countDB := store.DB.GormClient.Model(&models.SupplierPrice{}).Where("supplier_id = ?", supplierId)
+	countDB := h.DB.WithContext(c).Model(&models.SupplierPrice{}).Where("supplier_id = ?", supplierId)
 
 	searchConfig := utils.SearchConfig{
 		Fields: []string{"tensanpham", "nguyenlieuid", "phanloai"},
@@ -261,13 +270,13 @@ func GetSupplierPricesBySupplier(c *gin.Context) {
 	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
 
 	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetSupplierPricesBySupplier count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSupplierPricesBySupplier count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	var prices []models.SupplierPrice
-	db := store.DB.GormClient.Model(&models.SupplierPrice{}).Where("supplier_id = ?", supplierId)
+	db := h.DB.WithContext(c).Model(&models.SupplierPrice{}).Where("supplier_id = ?", supplierId)
 	db = utils.ApplySearch(db, params.Search, searchConfig)
 
 	allowedSortFields := map[string]string{
@@ -284,8 +293,8 @@ func GetSupplierPricesBySupplier(c *gin.Context) {
 	db = db.Preload("Ingredient").Preload("Supplier")
 
 	if err := db.Find(&prices).Error; err != nil {
-		logger.Log.Error("GetSupplierPricesBySupplier query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetSupplierPricesBySupplier query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -299,22 +308,29 @@ func GetSupplierPricesBySupplier(c *gin.Context) {
 	})
 }
 
-func CreateSupplierPrice(c *gin.Context) {
-	logger.Log.Info("CreateSupplierPrice called")
+func (h *SupplierPriceHandler) CreateSupplierPrice(c *gin.Context) {
+	logger.From(c).Info("CreateSupplierPrice called")
 	var price models.SupplierPrice
 	if err := c.ShouldBindJSON(&price); err != nil {
-		logger.Log.Error("CreateSupplierPrice bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("CreateSupplierPrice bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-	if err := store.DB.GormClient.Create(&price).Error; err != nil {
-		logger.Log.Error("CreateSupplierPrice db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// New products go to the mapping queue, only a confirmed mapping is used by supplier
+	// selection
+	price.MappingStatus = models.MappingStatusPending
+	if price.IngredientID == nil || *price.IngredientID == "" {
+		price.IngredientID = nil
+		price.MappingStatus = models.MappingStatusUnmapped
+	}
+	if err := h.DB.WithContext(c).Create(&price).Error; err != nil {
+		logger.From(c).Error("CreateSupplierPrice db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
 	// Reload with relationships to get names
-	store.DB.GormClient.
+	h.DB.WithContext(c).
 		Preload("Ingredient").
 		Preload("Supplier").
 		First(&price, "product_id = ?", price.ProductID)
@@ -324,43 +340,154 @@ func CreateSupplierPrice(c *gin.Context) {
 	c.JSON(http.StatusCreated, dto)
 }
 
-func UpdateSupplierPrice(c *gin.Context) {
-	logger.Log.Info("UpdateSupplierPrice called", "id", c.Param("id"))
+// UpdateSupplierPriceRequest is the body of UpdateSupplierPrice. Fields left out keep their
+// value; the product is the one in the URL. The mapping status follows the ingredient and is
+// only confirmed through ConfirmSupplierProductMapping.
+type UpdateSupplierPriceRequest struct {
+	ProductName   *string    `json:"productName"`
+	IngredientID  *string    `json:"ingredientId"`
+	Category      *string    `json:"category"`
+	SupplierID    *string    `json:"supplierId"`
+	Manufacturer  *string    `json:"manufacturer"`
+	Unit          *string    `json:"unit"`
+	Specification *string    `json:"specification"`
+	UnitPrice     *float64   `json:"unitPrice"`
+	PricePer1     *float64   `json:"pricePer1"`
+	EffectiveFrom *time.Time `json:"effectiveFrom"`
+	EffectiveTo   *time.Time `json:"effectiveTo"`
+	Active        *bool      `json:"active"`
+	NewPrice      *float64   `json:"newPrice"`
+	Promotion     *string    `json:"promotion"`
+}
+
+// updates returns the columns the request changes
+func (r UpdateSupplierPriceRequest) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	if r.ProductName != nil {
+		updates["product_name"] = *r.ProductName
+	}
+	if r.IngredientID != nil {
+		updates["ingredient_id"] = *r.IngredientID
+	}
+	if r.Category != nil {
+		updates["classification"] = *r.Category
+	}
+	if r.SupplierID != nil {
+		updates["supplier_id"] = *r.SupplierID
+	}
+	if r.Manufacturer != nil {
+		updates["manufacturer_name"] = *r.Manufacturer
+	}
+	if r.Unit != nil {
+		updates["unit"] = *r.Unit
+	}
+	if r.Specification != nil {
+		updates["specification"] = *r.Specification
+	}
+	if r.UnitPrice != nil {
+		updates["unit_price"] = *r.UnitPrice
+	}
+	if r.PricePer1 != nil {
+		updates["price_per_item"] = *r.PricePer1
+	}
+	if r.EffectiveFrom != nil {
+		updates["effective_from"] = *r.EffectiveFrom
+	}
+	if r.EffectiveTo != nil {
+		updates["effective_to"] = *r.EffectiveTo
+	}
+	if r.Active != nil {
+		updates["active"] = *r.Active
+	}
+	if r.NewPrice != nil {
+		updates["new_buying_price"] = *r.NewPrice
+	}
+	if r.Promotion != nil {
+		updates["promotion"] = *r.Promotion
+	}
+	return updates
+}
+
+func (h *SupplierPriceHandler) UpdateSupplierPrice(c *gin.Context) {
+	logger.From(c).Info("UpdateSupplierPrice called", "id", c.Param("id"))
 	id := c.Param("id")
+	cond, ok := ifMatch(c)
+	if !ok {
+		return
+	}
 	var price models.SupplierPrice
-	if err := store.DB.GormClient.First(&price, "product_id = ?", id).Error; err != nil {
-		logger.Log.Error("UpdateSupplierPrice not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "Supplier price not found"})
+	if err := h.DB.WithContext(c).First(&price, "product_id = ?", id).Error; err != nil {
+		logger.From(c).Error("UpdateSupplierPrice not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeSupplierPriceNotFound))
 		return
 	}
-	if err := c.ShouldBindJSON(&price); err != nil {
-		logger.Log.Error("UpdateSupplierPrice bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !cond.Matches(price.Version) {
+		h.supplierPriceConflict(c, id)
+		return
+	}
+	var req UpdateSupplierPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.From(c).Error("UpdateSupplierPrice bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
-	if err := store.DB.GormClient.Save(&price).Error; err != nil {
-		logger.Log.Error("UpdateSupplierPrice db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	updates := req.updates()
+	// A changed ingredient goes back to the mapping queue
+	if req.IngredientID != nil && (price.IngredientID == nil || *price.IngredientID != *req.IngredientID) {
+		if *req.IngredientID == "" {
+			updates["ingredient_id"] = nil
+			updates["mapping_status"] = models.MappingStatusUnmapped
+		} else {
+			updates["mapping_status"] = models.MappingStatusPending
+		}
+	}
+	updates["version"] = nextVersion
+	// Only while still at the version read, a concurrent change waits for the row and then
+	// updates nothing
+	result := h.DB.WithContext(c).Model(&models.SupplierPrice{}).
+		Where("product_id = ? AND version = ?", id, price.Version).Updates(updates)
+	if result.Error != nil {
+		logger.From(c).Error("UpdateSupplierPrice db error", "error", result.Error)
+		apperr.Respond(c, apperr.Internal(result.Error))
+		return
+	}
+	if result.RowsAffected == 0 {
+		h.supplierPriceConflict(c, id)
 		return
 	}
 
 	// Reload with relationships to get names
-	store.DB.GormClient.
+	h.DB.WithContext(c).
 		Preload("Ingredient").
 		Preload("Supplier").
-		First(&price, "product_id = ?", price.ProductID)
+		First(&price, "product_id = ?", id)
 
 	// Return DTO with names
 	dto := price.ToDTO()
+	setETag(c, price.Version)
 	c.JSON(http.StatusOK, dto)
 }
 
-func DeleteSupplierPrice(c *gin.Context) {
-	logger.Log.Info("DeleteSupplierPrice called", "id", c.Param("id"))
+// supplierPriceConflict answers a change of a supplier price read at another version with its
+// current state
+func (h *SupplierPriceHandler) supplierPriceConflict(c *gin.Context, id string) {
+	var current models.SupplierPrice
+	if err := h.DB.WithContext(c).
+		Preload("Ingredient").
+		Preload("Supplier").
+		First(&current, "product_id = ?", id).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeSupplierPriceNotFound))
+		return
+	}
+	versionConflict(c, current.Version, current.ToDTO())
+}
+
+func (h *SupplierPriceHandler) DeleteSupplierPrice(c *gin.Context) {
+	logger.From(c).Info("DeleteSupplierPrice called", "id", c.Param("id"))
 	id := c.Param("id")
-	if err := store.DB.GormClient.Delete(&models.SupplierPrice{}, "product_id = ?", id).Error; err != nil {
-		logger.Log.Error("DeleteSupplierPrice db error", "id", id, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.DB.WithContext(c).Delete(&models.SupplierPrice{}, "product_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteSupplierPrice db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Supplier price deleted successfully"})
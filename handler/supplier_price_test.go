@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateSupplierPrice_UpdatesTheRecordInTheURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dryRunDB(t)
+	statements := updateStatements(t, db)
+	r := gin.New()
+	r.PUT("/supplier-prices/:id", NewSupplierPriceHandler(db).UpdateSupplierPrice)
+
+	putJSON(r, "/supplier-prices/12", `{"productId":99,"version":5,"unitPrice":45000}`)
+
+	require.Len(t, *statements, 1)
+	sql := (*statements)[0]
+	assert.Contains(t, sql, "product_id = '12'")
+	assert.NotContains(t, sql, "99")
+	assert.Contains(t, sql, `"unit_price"=45000`)
+	// Fields left out of the body keep their value
+	assert.NotContains(t, sql, "product_name")
+	assert.NotContains(t, sql, "supplier_id")
+	assert.NotContains(t, sql, "created_date")
+}
+
+func TestUpdateSupplierPrice_ChangedIngredientNeedsConfirmation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := dryRunDB(t)
+	statements := updateStatements(t, db)
+	r := gin.New()
+	r.PUT("/supplier-prices/:id", NewSupplierPriceHandler(db).UpdateSupplierPrice)
+
+	putJSON(r, "/supplier-prices/12", `{"ingredientId":"NL002","mappingStatus":"confirmed"}`)
+
+	require.Len(t, *statements, 1)
+	sql := (*statements)[0]
+	assert.Contains(t, sql, `"ingredient_id"='NL002'`)
+	assert.Contains(t, sql, `"mapping_status"='pending'`)
+	assert.NotContains(t, sql, "confirmed")
+}
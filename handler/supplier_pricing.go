@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/pricing"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadPriceTerms loads the quantity tiers, contract prices and promotions of the given products
+func loadPriceTerms(db *gorm.DB, productIDs []int) (map[int]pricing.Terms, error) {
+	terms := make(map[int]pricing.Terms)
+	if len(productIDs) == 0 {
+		return terms, nil
+	}
+
+	var tiers []models.SupplierPriceTier
+	if err := db.Where("product_id IN ?", productIDs).Order("min_quantity ASC").Find(&tiers).Error; err != nil {
+		return nil, err
+	}
+	var contracts []models.SupplierContractPrice
+	if err := db.Where("product_id IN ? AND active = true", productIDs).Find(&contracts).Error; err != nil {
+		return nil, err
+	}
+	var promotions []models.SupplierPromotion
+	if err := db.Where("product_id IN ? AND active = true", productIDs).Find(&promotions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, t := range tiers {
+		pt := terms[t.ProductID]
+		pt.Tiers = append(pt.Tiers, t)
+		terms[t.ProductID] = pt
+	}
+	for _, c := range contracts {
+		pt := terms[c.ProductID]
+		pt.Contracts = append(pt.Contracts, c)
+		terms[c.ProductID] = pt
+	}
+	for _, p := range promotions {
+		pt := terms[p.ProductID]
+		pt.Promotions = append(pt.Promotions, p)
+		terms[p.ProductID] = pt
+	}
+	return terms, nil
+}
+
+// quoteSupplierPrice resolves the unit price of a supplier product for a kitchen and quantity
+func quoteSupplierPrice(db *gorm.DB, price models.SupplierPrice, kitchenID string, quantity float64, at time.Time) (pricing.Quote, error) {
+	terms, err := loadPriceTerms(db, []int{price.ProductID})
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+	return pricing.Resolve(price, terms[price.ProductID], kitchenID, quantity, at), nil
+}
+
+// findSupplierPrice loads the supplier price from the :id path parameter, writing 404 if missing
+func (h *SupplierPriceHandler) findSupplierPrice(c *gin.Context) (models.SupplierPrice, bool) {
+	id := c.Param("id")
+	var price models.SupplierPrice
+	if err := h.DB.WithContext(c).First(&price, "product_id = ?", id).Error; err != nil {
+		logger.From(c).Error("Supplier price not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeSupplierPriceNotFound))
+		return price, false
+	}
+	return price, true
+}
+
+// GetSupplierPriceTerms returns the tiers, contract prices and promotions of a supplier product
+func (h *SupplierPriceHandler) GetSupplierPriceTerms(c *gin.Context) {
+	logger.From(c).Info("GetSupplierPriceTerms called", "id", c.Param("id"))
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	var tiers []models.SupplierPriceTier
+	var contracts []models.SupplierContractPrice
+	var promotions []models.SupplierPromotion
+	db := h.DB.WithContext(c)
+	if err := db.Where("product_id = ?", price.ProductID).Order("min_quantity ASC").Find(&tiers).Error; err != nil {
+		logger.From(c).Error("GetSupplierPriceTerms tiers error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if err := db.Preload("Kitchen").Where("product_id = ?", price.ProductID).Order("kitchen_id, effective_from").Find(&contracts).Error; err != nil {
+		logger.From(c).Error("GetSupplierPriceTerms contracts error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if err := db.Where("product_id = ?", price.ProductID).Order("starts_at DESC").Find(&promotions).Error; err != nil {
+		logger.From(c).Error("GetSupplierPriceTerms promotions error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"productId":  price.ProductID,
+		"listPrice":  price.UnitPrice,
+		"tiers":      tiers,
+		"contracts":  contracts,
+		"promotions": promotions,
+	})
+}
+
+// GetSupplierPriceQuote returns the effective unit price of a supplier product
+// for ?kitchen_id=&quantity=&date= (date defaults to now, format YYYY-MM-DD)
+func (h *SupplierPriceHandler) GetSupplierPriceQuote(c *gin.Context) {
+	logger.From(c).Info("GetSupplierPriceQuote called", "id", c.Param("id"))
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	quantity := 1.0
+	if q := c.Query("quantity"); q != "" {
+		v, err := strconv.ParseFloat(q, 64)
+		if err != nil || v <= 0 {
+			apperr.Respond(c, apperr.Invalid("quantity", "gt", "0"))
+			return
+		}
+		quantity = v
+	}
+	at := time.Now()
+	if d := c.Query("date"); d != "" {
+		v, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			apperr.Respond(c, apperr.Invalid("date", "date", "YYYY-MM-DD"))
+			return
+		}
+		at = v
+	}
+
+	quote, err := quoteSupplierPrice(h.DB.WithContext(c), price, c.Query("kitchen_id"), quantity, at)
+	if err != nil {
+		logger.From(c).Error("GetSupplierPriceQuote error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"quote":     quote,
+		"quantity":  quantity,
+		"totalCost": quote.UnitPrice * quantity,
+	})
+}
+
+// PriceTiersRequest is the body of ReplaceSupplierPriceTiers
+type PriceTiersRequest struct {
+	Tiers []models.SupplierPriceTier `json:"tiers" binding:"dive"`
+}
+
+// ReplaceSupplierPriceTiers replaces all quantity tiers of a supplier product
+func (h *SupplierPriceHandler) ReplaceSupplierPriceTiers(c *gin.Context) {
+	logger.From(c).Info("ReplaceSupplierPriceTiers called", "id", c.Param("id"))
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	var request PriceTiersRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.From(c).Error("ReplaceSupplierPriceTiers bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	seen := make(map[float64]bool)
+	for i := range request.Tiers {
+		t := &request.Tiers[i]
+		if t.UnitPrice == nil && t.DiscountPercent == nil {
+			apperr.Respond(c, apperr.Invalid(fmt.Sprintf("tiers[%d]", i), "one", "unitPrice,discountPercent"))
+			return
+		}
+		if seen[t.MinQuantity] {
+			apperr.Respond(c, apperr.Invalid(fmt.Sprintf("tiers[%d].minQuantity", i), "unique"))
+			return
+		}
+		seen[t.MinQuantity] = true
+		t.TierID = 0
+		t.ProductID = price.ProductID
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("product_id = ?", price.ProductID).Delete(&models.SupplierPriceTier{}).Error; err != nil {
+			return err
+		}
+		if len(request.Tiers) == 0 {
+			return nil
+		}
+		return tx.Create(&request.Tiers).Error
+	})
+	if err != nil {
+		logger.From(c).Error("ReplaceSupplierPriceTiers db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"productId": price.ProductID, "tiers": request.Tiers})
+}
+
+func (h *SupplierPriceHandler) CreateSupplierContractPrice(c *gin.Context) {
+	logger.From(c).Info("CreateSupplierContractPrice called", "id", c.Param("id"))
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	var contract models.SupplierContractPrice
+	if err := c.ShouldBindJSON(&contract); err != nil {
+		logger.From(c).Error("CreateSupplierContractPrice bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if contract.EffectiveFrom != nil && contract.EffectiveTo != nil && contract.EffectiveTo.Before(*contract.EffectiveFrom) {
+		apperr.Respond(c, apperr.Invalid("effectiveTo", "after", "effectiveFrom"))
+		return
+	}
+	contract.ContractID = 0
+	contract.ProductID = price.ProductID
+	if err := h.DB.WithContext(c).Create(&contract).Error; err != nil {
+		logger.From(c).Error("CreateSupplierContractPrice db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusCreated, contract)
+}
+
+func (h *SupplierPriceHandler) DeleteSupplierContractPrice(c *gin.Context) {
+	logger.From(c).Info("DeleteSupplierContractPrice called", "id", c.Param("id"))
+	id := c.Param("id")
+	if err := h.DB.WithContext(c).Delete(&models.SupplierContractPrice{}, "contract_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteSupplierContractPrice db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Contract price deleted successfully"})
+}
+
+func (h *SupplierPriceHandler) CreateSupplierPromotion(c *gin.Context) {
+	logger.From(c).Info("CreateSupplierPromotion called", "id", c.Param("id"))
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	var promo models.SupplierPromotion
+	if err := c.ShouldBindJSON(&promo); err != nil {
+		logger.From(c).Error("CreateSupplierPromotion bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if promo.PromoPrice == nil && promo.DiscountPercent == nil {
+		apperr.Respond(c, apperr.Invalid("promotion", "one", "promoPrice,discountPercent"))
+		return
+	}
+	promo.PromotionID = 0
+	promo.ProductID = price.ProductID
+	if err := h.DB.WithContext(c).Create(&promo).Error; err != nil {
+		logger.From(c).Error("CreateSupplierPromotion db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusCreated, promo)
+}
+
+func (h *SupplierPriceHandler) DeleteSupplierPromotion(c *gin.Context) {
+	logger.From(c).Info("DeleteSupplierPromotion called", "id", c.Param("id"))
+	id := c.Param("id")
+	if err := h.DB.WithContext(c).Delete(&models.SupplierPromotion{}, "promotion_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteSupplierPromotion db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Promotion deleted successfully"})
+}
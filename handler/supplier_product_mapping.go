@@ -0,0 +1,353 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/matching"
+	"adong-be/models"
+	"adong-be/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultSuggestionLimit = 5
+
+// UnmappedProduct is a supplier product waiting in the mapping queue with its best suggestions
+type UnmappedProduct struct {
+	models.SupplierPriceDTO
+	Suggestions []matching.Suggestion `json:"suggestions"`
+}
+
+// loadMatchingIngredients returns the active master ingredients as matching candidates
+func loadMatchingIngredients(db *gorm.DB) ([]matching.Ingredient, error) {
+	var ingredients []models.Ingredient
+	if err := db.Select("ingredient_id, ingredient_name, unit").Where("active = true").Find(&ingredients).Error; err != nil {
+		return nil, err
+	}
+	out := make([]matching.Ingredient, 0, len(ingredients))
+	for _, ing := range ingredients {
+		out = append(out, matching.Ingredient{
+			IngredientID:   ing.IngredientID,
+			IngredientName: ing.IngredientName,
+			Unit:           ing.Unit,
+		})
+	}
+	return out, nil
+}
+
+// loadRejections returns the rejected ingredient ids per product
+func loadRejections(db *gorm.DB, productIDs []int) (map[int]map[string]bool, error) {
+	rejected := make(map[int]map[string]bool)
+	if len(productIDs) == 0 {
+		return rejected, nil
+	}
+	var rows []models.SupplierProductMappingRejection
+	if err := db.Where("product_id IN ?", productIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range rows {
+		if rejected[r.ProductID] == nil {
+			rejected[r.ProductID] = make(map[string]bool)
+		}
+		rejected[r.ProductID][r.IngredientID] = true
+	}
+	return rejected, nil
+}
+
+// GetUnmappedSupplierProducts lists supplier products that are not confirmed yet
+// (status unmapped or pending) with suggested ingredient matches
+func (h *SupplierPriceHandler) GetUnmappedSupplierProducts(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetUnmappedSupplierProducts called", "user_id", uid)
+
+	var params models.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logger.From(c).Error("GetUnmappedSupplierProducts bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	params = models.GetPaginationParams(
+		params.Page,
+		params.PageSize,
+		params.Search,
+		params.SortBy,
+		params.SortDir,
+	)
+
+	db := h.DB.WithContext(c).Model(&models.SupplierPrice{}).
+		Where("mapping_status <> ?", models.MappingStatusConfirmed)
+	if supplierID := c.Query("supplier_id"); supplierID != "" {
+		db = db.Where("supplier_id = ?", supplierID)
+	}
+	db = utils.ApplySearch(db, params.Search, utils.SearchConfig{
+		Fields: []string{"product_name", "manufacturer_name", "classification"},
+		Fuzzy:  true,
+	})
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		logger.From(c).Error("GetUnmappedSupplierProducts count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	var products []models.SupplierPrice
+	if err := utils.ApplyPagination(db.Preload("Supplier").Preload("Ingredient").Order("created_date ASC, product_id ASC"),
+		params.Page, params.PageSize).Find(&products).Error; err != nil {
+		logger.From(c).Error("GetUnmappedSupplierProducts query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	ingredients, err := loadMatchingIngredients(h.DB.WithContext(c))
+	if err != nil {
+		logger.From(c).Error("GetUnmappedSupplierProducts ingredients error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	productIDs := make([]int, 0, len(products))
+	for _, p := range products {
+		productIDs = append(productIDs, p.ProductID)
+	}
+	rejected, err := loadRejections(h.DB.WithContext(c), productIDs)
+	if err != nil {
+		logger.From(c).Error("GetUnmappedSupplierProducts rejections error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	data := make([]UnmappedProduct, 0, len(products))
+	for _, p := range products {
+		data = append(data, UnmappedProduct{
+			SupplierPriceDTO: p.ToDTO(),
+			Suggestions:      matching.Suggest(p.ProductName, p.Unit, ingredients, rejected[p.ProductID], 3),
+		})
+	}
+
+	meta := models.CalculatePaginationMeta(params.Page, params.PageSize, total)
+	c.JSON(http.StatusOK, models.ResourceCollection{
+		Data: data,
+		Meta: meta,
+	})
+}
+
+// GetSupplierProductSuggestions returns ranked ingredient suggestions for one product (?limit=)
+func (h *SupplierPriceHandler) GetSupplierProductSuggestions(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetSupplierProductSuggestions called", "id", c.Param("id"), "user_id", uid)
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultSuggestionLimit
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = l
+	}
+
+	ingredients, err := loadMatchingIngredients(h.DB.WithContext(c))
+	if err != nil {
+		logger.From(c).Error("GetSupplierProductSuggestions ingredients error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	rejected, err := loadRejections(h.DB.WithContext(c), []int{price.ProductID})
+	if err != nil {
+		logger.From(c).Error("GetSupplierProductSuggestions rejections error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"productId":   price.ProductID,
+		"productName": price.ProductName,
+		"unit":        price.Unit,
+		"suggestions": matching.Suggest(price.ProductName, price.Unit, ingredients, rejected[price.ProductID], limit),
+	})
+}
+
+// GetSupplierProductMappings returns the primary and additional ingredients of a product
+func (h *SupplierPriceHandler) GetSupplierProductMappings(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetSupplierProductMappings called", "id", c.Param("id"), "user_id", uid)
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	var extra []models.SupplierProductIngredient
+	if err := h.DB.WithContext(c).Preload("Ingredient").Where("product_id = ?", price.ProductID).Find(&extra).Error; err != nil {
+		logger.From(c).Error("GetSupplierProductMappings query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"productId":          price.ProductID,
+		"mappingStatus":      price.MappingStatus,
+		"ingredientId":       price.IngredientID,
+		"additionalMappings": extra,
+	})
+}
+
+// ConfirmMappingRequest is the body of ConfirmSupplierProductMapping
+type ConfirmMappingRequest struct {
+	IngredientID            string   `json:"ingredientId" binding:"required"`
+	AdditionalIngredientIDs []string `json:"additionalIngredientIds"`
+}
+
+// ConfirmSupplierProductMapping maps a product to its primary ingredient and optional
+// equivalent ingredients, and marks the mapping as confirmed
+func (h *SupplierPriceHandler) ConfirmSupplierProductMapping(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("ConfirmSupplierProductMapping called", "id", c.Param("id"), "user_id", uid)
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	var request ConfirmMappingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.From(c).Error("ConfirmSupplierProductMapping bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	ids := []string{request.IngredientID}
+	for _, id := range request.AdditionalIngredientIDs {
+		if id != request.IngredientID {
+			ids = append(ids, id)
+		}
+	}
+	var found int64
+	if err := h.DB.WithContext(c).Model(&models.Ingredient{}).Where("ingredient_id IN ?", ids).Count(&found).Error; err != nil {
+		logger.From(c).Error("ConfirmSupplierProductMapping ingredient lookup error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if int(found) != len(uniqueStrings(ids)) {
+		apperr.Respond(c, apperr.Invalid("ingredientId", "exists"))
+		return
+	}
+
+	var userID *string
+	if v, ok := uid.(string); ok {
+		userID = &v
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.SupplierPrice{}).Where("product_id = ?", price.ProductID).Updates(map[string]interface{}{
+			"ingredient_id":  request.IngredientID,
+			"mapping_status": models.MappingStatusConfirmed,
+			"version":        nextVersion,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ?", price.ProductID).Delete(&models.SupplierProductIngredient{}).Error; err != nil {
+			return err
+		}
+		for _, id := range uniqueStrings(ids[1:]) {
+			if err := tx.Create(&models.SupplierProductIngredient{
+				ProductID:      price.ProductID,
+				IngredientID:   id,
+				MappedByUserID: userID,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		// A confirmed ingredient is no longer a rejected one
+		return tx.Where("product_id = ? AND ingredient_id IN ?", price.ProductID, ids).
+			Delete(&models.SupplierProductMappingRejection{}).Error
+	})
+	if err != nil {
+		logger.From(c).Error("ConfirmSupplierProductMapping db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":                 "Mapping confirmed",
+		"productId":               price.ProductID,
+		"ingredientId":            request.IngredientID,
+		"additionalIngredientIds": uniqueStrings(ids[1:]),
+	})
+}
+
+// RejectMappingRequest is the body of RejectSupplierProductMapping
+type RejectMappingRequest struct {
+	IngredientID string `json:"ingredientId" binding:"required"`
+}
+
+// RejectSupplierProductMapping rejects an ingredient for a product. The ingredient is not
+// suggested again; if it was the current primary mapping the product goes back to the queue.
+func (h *SupplierPriceHandler) RejectSupplierProductMapping(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("RejectSupplierProductMapping called", "id", c.Param("id"), "user_id", uid)
+	price, ok := h.findSupplierPrice(c)
+	if !ok {
+		return
+	}
+
+	var request RejectMappingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		logger.From(c).Error("RejectSupplierProductMapping bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	var userID *string
+	if v, ok := uid.(string); ok {
+		userID = &v
+	}
+
+	status := price.MappingStatus
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.SupplierProductMappingRejection{
+			ProductID:        price.ProductID,
+			IngredientID:     request.IngredientID,
+			RejectedByUserID: userID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("product_id = ? AND ingredient_id = ?", price.ProductID, request.IngredientID).
+			Delete(&models.SupplierProductIngredient{}).Error; err != nil {
+			return err
+		}
+		if price.IngredientID != nil && *price.IngredientID == request.IngredientID {
+			status = models.MappingStatusUnmapped
+			return tx.Model(&models.SupplierPrice{}).Where("product_id = ?", price.ProductID).Updates(map[string]interface{}{
+				"ingredient_id":  nil,
+				"mapping_status": status,
+				"version":        nextVersion,
+			}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		logger.From(c).Error("RejectSupplierProductMapping db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "Mapping rejected",
+		"productId":     price.ProductID,
+		"mappingStatus": status,
+	})
+}
+
+func uniqueStrings(in []string) []string {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, s := range in {
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"adong-be/delivery"
+	"adong-be/models"
+	"adong-be/pricing"
+	"adong-be/selection"
+	"slices"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// candidateOptions narrows or annotates the candidates loaded for supplier selection
+type candidateOptions struct {
+	// DeliveryDate, when set, checks each supplier's delivery calendar for that date
+	DeliveryDate *time.Time
+	// ExcludeUndeliverable drops suppliers that cannot deliver on DeliveryDate instead of only flagging them
+	ExcludeUndeliverable bool
+}
+
+// selectSuppliers loads the selection rules, kitchen favorites and current supplier
+// prices for the given items and runs the selection engine over them.
+// Missing ingredient metadata (name, type, material group) is filled in from master_ingredients.
+func selectSuppliers(db *gorm.DB, kitchenID string, items []selection.Item, opts candidateOptions) ([]selection.Decision, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	candidates, err := loadSelectionCandidates(db, kitchenID, items, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules []models.SupplierSelectionRule
+	if err := db.Where("active = true AND (kitchen_id IS NULL OR kitchen_id = ?)", kitchenID).
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	engine := selection.NewEngine(rules)
+	return engine.Select(kitchenID, items, candidates), nil
+}
+
+// loadSelectionCandidates fills in ingredient metadata on items and returns the
+// currently effective supplier prices per ingredient, flagged with the kitchen's favorites
+// and, when opts.DeliveryDate is set, with each supplier's delivery status
+func loadSelectionCandidates(db *gorm.DB, kitchenID string, items []selection.Item, opts candidateOptions) (map[string][]selection.Candidate, error) {
+
+	ingredientIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		ingredientIDs = append(ingredientIDs, item.IngredientID)
+	}
+
+	var ingredients []models.Ingredient
+	if err := db.Where("ingredient_id IN ?", ingredientIDs).Find(&ingredients).Error; err != nil {
+		return nil, err
+	}
+	ingredientMap := make(map[string]models.Ingredient, len(ingredients))
+	for _, ing := range ingredients {
+		ingredientMap[ing.IngredientID] = ing
+	}
+	for i := range items {
+		ing, ok := ingredientMap[items[i].IngredientID]
+		if !ok {
+			continue
+		}
+		if items[i].IngredientName == "" {
+			items[i].IngredientName = ing.IngredientName
+		}
+		if ing.IngredientTypeID != nil {
+			items[i].IngredientTypeID = *ing.IngredientTypeID
+		}
+		items[i].MaterialGroup = ing.MaterialGroup
+	}
+
+	var favorites []models.KitchenFavoriteSupplier
+	if err := db.Where("kitchen_id = ?", kitchenID).Find(&favorites).Error; err != nil {
+		return nil, err
+	}
+	favoriteRank := make(map[string]int, len(favorites))
+	for _, fav := range favorites {
+		favoriteRank[fav.SupplierID] = fav.DisplayOrder
+	}
+
+	// Only confirmed products are used: primary mappings plus products mapped to the
+	// ingredient as an equivalent through supplier_product_ingredients
+	var prices []models.SupplierPrice
+	if err := db.Preload("Supplier").
+		Where("active = true AND mapping_status = ?", models.MappingStatusConfirmed).
+		Where("(ingredient_id IN ? OR product_id IN (SELECT product_id FROM supplier_product_ingredients WHERE ingredient_id IN ?))",
+			ingredientIDs, ingredientIDs).
+		Where("(effective_from IS NULL OR effective_from <= NOW())").
+		Where("(effective_to IS NULL OR effective_to >= NOW())").
+		Order("unit_price ASC").
+		Find(&prices).Error; err != nil {
+		return nil, err
+	}
+
+	productIDs := make([]int, 0, len(prices))
+	for _, price := range prices {
+		productIDs = append(productIDs, price.ProductID)
+	}
+	terms, err := loadPriceTerms(db, productIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := make(map[string]bool, len(ingredientIDs))
+	for _, id := range ingredientIDs {
+		wanted[id] = true
+	}
+	mappedTo := make(map[int][]string, len(prices))
+	for _, price := range prices {
+		if price.IngredientID != nil && wanted[*price.IngredientID] {
+			mappedTo[price.ProductID] = append(mappedTo[price.ProductID], *price.IngredientID)
+		}
+	}
+	if len(productIDs) > 0 {
+		var extra []models.SupplierProductIngredient
+		if err := db.Where("product_id IN ? AND ingredient_id IN ?", productIDs, ingredientIDs).Find(&extra).Error; err != nil {
+			return nil, err
+		}
+		for _, m := range extra {
+			if !slices.Contains(mappedTo[m.ProductID], m.IngredientID) {
+				mappedTo[m.ProductID] = append(mappedTo[m.ProductID], m.IngredientID)
+			}
+		}
+	}
+
+	quantities := make(map[string]float64, len(items))
+	for _, item := range items {
+		quantities[item.IngredientID] += item.Quantity
+	}
+
+	now := time.Now()
+	var deliveryStatus map[string]delivery.Status
+	if opts.DeliveryDate != nil {
+		supplierIDs := make([]string, 0, len(prices))
+		for _, price := range prices {
+			supplierIDs = append(supplierIDs, price.SupplierID)
+		}
+		deliveryStatus, err = checkDeliveries(db, uniqueStrings(supplierIDs), kitchenID, *opts.DeliveryDate, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	candidates := make(map[string][]selection.Candidate)
+	for _, price := range prices {
+		rank, isFavorite := favoriteRank[price.SupplierID]
+		var status *delivery.Status
+		if st, ok := deliveryStatus[price.SupplierID]; ok {
+			if opts.ExcludeUndeliverable && !st.CanDeliver {
+				continue
+			}
+			status = &st
+		}
+		for _, ingredientID := range mappedTo[price.ProductID] {
+			quote := pricing.Resolve(price, terms[price.ProductID], kitchenID, quantities[ingredientID], now)
+			candidates[ingredientID] = append(candidates[ingredientID], selection.Candidate{
+				Price:        price,
+				UnitPrice:    quote.UnitPrice,
+				PriceSource:  quote.Source,
+				PriceNote:    quote.Note,
+				IsFavorite:   isFavorite,
+				FavoriteRank: rank,
+				Delivery:     status,
+			})
+		}
+	}
+
+	return candidates, nil
+}
+
+// orderSelectionItems sums the ingredient quantities of an order (dishes and supplementary foods)
+// into selection items
+func orderSelectionItems(db *gorm.DB, orderID string) ([]selection.Item, error) {
+	var ingredients []IngredientTotal
+	sql := `
+        SELECT DISTINCT x.ingredient_id AS ingredient_id,
+               COALESCE(mi.ingredient_name, '') AS ingredient_name,
+               x.unit AS unit,
+               COALESCE(SUM(x.total_qty)::double precision, 0) AS total_quantity
+        FROM (
+            SELECT oi.ingredient_id,
+                   oi.unit,
+                   COALESCE(oi.quantity, oi.standard_per_portion * od.portions) AS total_qty
+            FROM order_ingredients oi
+            JOIN order_details od ON od.order_detail_id = oi.order_detail_id
+            WHERE od.order_id = ?
+            UNION ALL
+            SELECT osf.ingredient_id,
+                   osf.unit,
+                   COALESCE(osf.quantity, osf.standard_per_portion * osf.portions) AS total_qty
+            FROM order_supplementary_foods osf
+            WHERE osf.order_id = ?
+        ) x
+        LEFT JOIN master_ingredients mi ON mi.ingredient_id = x.ingredient_id
+        GROUP BY x.ingredient_id, mi.ingredient_name, x.unit`
+
+	if err := db.Raw(sql, orderID, orderID).Scan(&ingredients).Error; err != nil {
+		return nil, err
+	}
+
+	items := make([]selection.Item, 0, len(ingredients))
+	for _, ing := range ingredients {
+		items = append(items, selection.Item{
+			IngredientID:   ing.IngredientID,
+			IngredientName: ing.IngredientName,
+			Quantity:       ing.TotalQuantity,
+			Unit:           ing.Unit,
+		})
+	}
+	return items, nil
+}
+
+// toSupplierOption converts the engine's choice into the SupplierOption returned to the frontend
+func toSupplierOption(d selection.Decision) *SupplierOption {
+	if d.Selected == nil {
+		return nil
+	}
+	cand := d.Selected
+	return &SupplierOption{
+		ProductID:     cand.Price.ProductID,
+		ProductName:   cand.Price.ProductName,
+		SupplierID:    cand.Price.SupplierID,
+		SupplierName:  cand.SupplierName(),
+		UnitPrice:     cand.UnitPrice,
+		ListPrice:     cand.Price.UnitPrice,
+		PriceSource:   cand.PriceSource,
+		PriceNote:     cand.PriceNote,
+		Unit:          cand.Price.Unit,
+		Specification: cand.Price.Specification,
+		IsFavorite:    cand.IsFavorite,
+		IsLowestPrice: cand.UnitPrice == d.LowestPrice,
+		Delivery:      cand.Delivery,
+		TotalCost:     d.Item.Quantity * cand.UnitPrice,
+	}
+}
+
+// toIngredientSuppliers converts an engine decision into the best-supplier response line
+func toIngredientSuppliers(d selection.Decision) IngredientSuppliers {
+	return IngredientSuppliers{
+		IngredientID:   d.Item.IngredientID,
+		IngredientName: d.Item.IngredientName,
+		TotalQuantity:  d.Item.Quantity,
+		Unit:           d.Item.Unit,
+		BestSupplier:   toSupplierOption(d),
+		Strategy:       d.Strategy,
+		RuleID:         d.RuleID,
+		Explanation:    d.Explanation,
+	}
+}
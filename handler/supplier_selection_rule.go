@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/selection"
+	"adong-be/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SupplierSelectionRuleHandler struct {
+	DB *gorm.DB
+}
+
+func NewSupplierSelectionRuleHandler(db *gorm.DB) *SupplierSelectionRuleHandler {
+	return &SupplierSelectionRuleHandler{DB: db}
+}
+
+// GetSupplierSelectionRules lists selection rules, optionally filtered by kitchen_id
+func (h *SupplierSelectionRuleHandler) GetSupplierSelectionRules(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetSupplierSelectionRules called", "user_id", uid)
+
+	var params models.PaginationParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		logger.From(c).Error("GetSupplierSelectionRules bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	params = models.GetPaginationParams(
+		params.Page,
+		params.PageSize,
+		params.Search,
+		params.SortBy,
+		params.SortDir,
+	)
+
+	kitchenID := c.Query("kitchen_id")
+
+	var total int64
+	countDB := h.DB.WithContext(c).Model(&models.SupplierSelectionRule{})
+	db := h.DB.WithContext(c).Model(&models.SupplierSelectionRule{})
+	if kitchenID != "" {
+		countDB = countDB.Where("kitchen_id = ?", kitchenID)
+		db = db.Where("kitchen_id = ?", kitchenID)
+	}
+
+	searchConfig := utils.SearchConfig{
+		Fields: []string{"material_group", "strategy", "notes"},
+		Fuzzy:  true,
+	}
+	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
+	db = utils.ApplySearch(db, params.Search, searchConfig)
+
+	if err := countDB.Count(&total).Error; err != nil {
+		logger.From(c).Error("GetSupplierSelectionRules count error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	allowedSortFields := map[string]string{
+		"rule_id":        "rule_id",
+		"kitchen_id":     "kitchen_id",
+		"material_group": "material_group",
+		"strategy":       "strategy",
+		"priority":       "priority",
+	}
+	db = utils.ApplySort(db, params.SortBy, params.SortDir, allowedSortFields)
+	if params.SortBy == "" {
+		db = db.Order("priority DESC, rule_id ASC")
+	}
+	db = utils.ApplyPagination(db, params.Page, params.PageSize)
+
+	var rules []models.SupplierSelectionRule
+	if err := db.Preload("Kitchen").Preload("IngredientType").Find(&rules).Error; err != nil {
+		logger.From(c).Error("GetSupplierSelectionRules query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+
+	meta := models.CalculatePaginationMeta(params.Page, params.PageSize, total)
+	c.JSON(http.StatusOK, models.ResourceCollection{
+		Data: rules,
+		Meta: meta,
+	})
+}
+
+// GetSupplierSelectionStrategies lists the strategy names a rule can use
+func (h *SupplierSelectionRuleHandler) GetSupplierSelectionStrategies(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"strategies": selection.Names()})
+}
+
+func (h *SupplierSelectionRuleHandler) GetSupplierSelectionRule(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetSupplierSelectionRule called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+	var rule models.SupplierSelectionRule
+	if err := h.DB.WithContext(c).
+		Preload("Kitchen").
+		Preload("IngredientType").
+		First(&rule, "rule_id = ?", id).Error; err != nil {
+		logger.From(c).Error("GetSupplierSelectionRule not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeSelectionRuleNotFound))
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *SupplierSelectionRuleHandler) CreateSupplierSelectionRule(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("CreateSupplierSelectionRule called", "user_id", uid)
+	var rule models.SupplierSelectionRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		logger.From(c).Error("CreateSupplierSelectionRule bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if _, ok := selection.Lookup(rule.Strategy); !ok {
+		apperr.Respond(c, apperr.Invalid("strategy", "oneof", strings.Join(selection.Names(), ",")))
+		return
+	}
+
+	rule.RuleID = 0
+	if v, ok := uid.(string); ok {
+		rule.UpdatedByUserID = &v
+	}
+	if err := h.DB.WithContext(c).Create(&rule).Error; err != nil {
+		logger.From(c).Error("CreateSupplierSelectionRule db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *SupplierSelectionRuleHandler) UpdateSupplierSelectionRule(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("UpdateSupplierSelectionRule called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+	var rule models.SupplierSelectionRule
+	if err := h.DB.WithContext(c).First(&rule, "rule_id = ?", id).Error; err != nil {
+		logger.From(c).Error("UpdateSupplierSelectionRule not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeSelectionRuleNotFound))
+		return
+	}
+	ruleID := rule.RuleID
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		logger.From(c).Error("UpdateSupplierSelectionRule bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	if _, ok := selection.Lookup(rule.Strategy); !ok {
+		apperr.Respond(c, apperr.Invalid("strategy", "oneof", strings.Join(selection.Names(), ",")))
+		return
+	}
+
+	rule.RuleID = ruleID
+	if v, ok := uid.(string); ok {
+		rule.UpdatedByUserID = &v
+	}
+	if err := h.DB.WithContext(c).Save(&rule).Error; err != nil {
+		logger.From(c).Error("UpdateSupplierSelectionRule db error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *SupplierSelectionRuleHandler) DeleteSupplierSelectionRule(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("DeleteSupplierSelectionRule called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+	if err := h.DB.WithContext(c).Delete(&models.SupplierSelectionRule{}, "rule_id = ?", id).Error; err != nil {
+		logger.From(c).Error("DeleteSupplierSelectionRule db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Selection rule deleted successfully"})
+}
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/auth/password"
+	"adong-be/auth/totp"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/store"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorHandler serves TOTP enrollment and management. Enrollment authenticates with the
+// password instead of a token, so users whose role requires two-factor authentication can
+// enroll before their first login.
+type TwoFactorHandler struct {
+	Store  *store.Store
+	Issuer string
+}
+
+func NewTwoFactorHandler(st *store.Store, issuer string) *TwoFactorHandler {
+	return &TwoFactorHandler{Store: st, Issuer: issuer}
+}
+
+type TwoFactorEnrollRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type TwoFactorConfirmRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// checkCredentials returns the active user matching username and password, writing a 401
+// otherwise
+func (h *TwoFactorHandler) checkCredentials(c *gin.Context, username, pw string) (*models.User, bool) {
+	user, err := h.Store.GetUserForLogin(username)
+	if err != nil || user.Active == nil || !*user.Active || !password.Verify(user.Password, pw) {
+		logger.From(c).Warn("two-factor enrollment with invalid credentials", "username", username, "ip", c.ClientIP())
+		apperr.Respond(c, apperr.New(apperr.CodeInvalidCredentials))
+		return nil, false
+	}
+	return user, true
+}
+
+// checkCode verifies a TOTP code of an enabled second factor and marks it used, writing a
+// 400 when it is wrong or already used
+func (h *TwoFactorHandler) checkCode(c *gin.Context, tf *models.UserTwoFactor, code string) bool {
+	step, valid := totp.Validate(tf.TOTPSecret, code, time.Now())
+	if !valid {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorCodeInvalid))
+		return false
+	}
+	ok, err := h.Store.UseTOTPStep(c, tf.UserID, step)
+	if err != nil {
+		logger.From(c).Error("two-factor use step error", "user_id", tf.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return false
+	}
+	if !ok {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorCodeInvalid))
+		return false
+	}
+	return true
+}
+
+// enabledTwoFactor loads the second factor of the signed-in user, writing a 409 when it is
+// not enabled
+func (h *TwoFactorHandler) enabledTwoFactor(c *gin.Context, userID string) (*models.UserTwoFactor, bool) {
+	tf, err := h.Store.GetTwoFactor(c, userID)
+	if err != nil {
+		logger.From(c).Error("two-factor lookup error", "user_id", userID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return nil, false
+	}
+	if !tf.Enabled() {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorNotEnabled))
+		return nil, false
+	}
+	return tf, true
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes to store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := totp.NewRecoveryCodes(totp.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = password.HashToken(totp.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// GetTwoFactorStatus tells the signed-in user whether two-factor authentication is enabled,
+// required by their role and how many recovery codes are left
+func (h *TwoFactorHandler) GetTwoFactorStatus(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetTwoFactorStatus called", "user_id", uid)
+	userID, _ := uid.(string)
+
+	var user models.User
+	if err := h.Store.GormClient.WithContext(c).First(&user, "user_id = ?", userID).Error; err != nil {
+		apperr.Respond(c, apperr.New(apperr.CodeUnauthorized))
+		return
+	}
+	tf, err := h.Store.GetTwoFactor(c, userID)
+	if err != nil {
+		logger.From(c).Error("GetTwoFactorStatus lookup error", "user_id", userID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	required, err := h.Store.RequiresTwoFactor(c, userID, user.Role)
+	if err != nil {
+		logger.From(c).Error("GetTwoFactorStatus requirement error", "user_id", userID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	var left int64
+	if tf.Enabled() {
+		if left, err = h.Store.CountRecoveryCodes(c, userID); err != nil {
+			logger.From(c).Error("GetTwoFactorStatus count codes error", "user_id", userID, "error", err)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": tf.Enabled(), "required": required, "recoveryCodesLeft": left})
+}
+
+// EnrollTwoFactor creates a new TOTP secret for the user and returns it with the otpauth://
+// URI to render as a QR code. The secret is only used once ConfirmTwoFactor succeeds.
+func (h *TwoFactorHandler) EnrollTwoFactor(c *gin.Context) {
+	var req TwoFactorEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	logger.From(c).Info("EnrollTwoFactor called", "username", req.Username, "ip", c.ClientIP())
+	user, ok := h.checkCredentials(c, req.Username, req.Password)
+	if !ok {
+		return
+	}
+
+	tf, err := h.Store.GetTwoFactor(c, user.UserID)
+	if err != nil {
+		logger.From(c).Error("EnrollTwoFactor lookup error", "user_id", user.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if tf.Enabled() {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorAlreadyEnabled))
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err == nil {
+		err = h.Store.SaveTwoFactorSecret(c, user.UserID, secret)
+	}
+	if err != nil {
+		logger.From(c).Error("EnrollTwoFactor save error", "user_id", user.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":     secret,
+		"otpauthUri": totp.ProvisioningURI(h.Issuer, user.UserName, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the user proves their app produces
+// valid codes. The recovery codes are returned only in this response.
+func (h *TwoFactorHandler) ConfirmTwoFactor(c *gin.Context) {
+	var req TwoFactorConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	logger.From(c).Info("ConfirmTwoFactor called", "username", req.Username, "ip", c.ClientIP())
+	user, ok := h.checkCredentials(c, req.Username, req.Password)
+	if !ok {
+		return
+	}
+
+	tf, err := h.Store.GetTwoFactor(c, user.UserID)
+	if err != nil {
+		logger.From(c).Error("ConfirmTwoFactor lookup error", "user_id", user.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if tf == nil || tf.Enabled() {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorNotEnrolling))
+		return
+	}
+	step, valid := totp.Validate(tf.TOTPSecret, req.Code, time.Now())
+	if !valid {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorCodeInvalid))
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = h.Store.EnableTwoFactor(c, user.UserID, step, hashes)
+	}
+	if errors.Is(err, store.ErrTwoFactorNotEnrolled) {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorNotEnrolling))
+		return
+	}
+	if err != nil {
+		logger.From(c).Error("ConfirmTwoFactor enable error", "user_id", user.UserID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	logger.From(c).Info("two-factor authentication enabled", "user_id", user.UserID)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication enabled", "recoveryCodes": codes})
+}
+
+// DisableTwoFactor turns two-factor authentication off for the signed-in user after they
+// confirm both their password and a current code. Users whose role requires it cannot.
+func (h *TwoFactorHandler) DisableTwoFactor(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("DisableTwoFactor called", "user_id", uid)
+	userID, _ := uid.(string)
+
+	var req TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	var user models.User
+	if err := h.Store.GormClient.WithContext(c).First(&user, "user_id = ?", userID).Error; err != nil {
+		apperr.Respond(c, apperr.New(apperr.CodeUnauthorized))
+		return
+	}
+	required, err := h.Store.RequiresTwoFactor(c, userID, user.Role)
+	if err != nil {
+		logger.From(c).Error("DisableTwoFactor requirement error", "user_id", userID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if required {
+		apperr.Respond(c, apperr.New(apperr.CodeTwoFactorEnrollmentRequired))
+		return
+	}
+	if !password.Verify(user.Password, req.Password) {
+		apperr.Respond(c, apperr.New(apperr.CodePasswordIncorrect))
+		return
+	}
+	tf, ok := h.enabledTwoFactor(c, userID)
+	if !ok || !h.checkCode(c, tf, req.Code) {
+		return
+	}
+
+	if err := h.Store.DisableTwoFactor(c, userID); err != nil {
+		logger.From(c).Error("DisableTwoFactor db error", "user_id", userID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	logger.From(c).Info("two-factor authentication disabled", "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the signed-in user, e.g. after most
+// of them were used. The previous codes stop working.
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("RegenerateRecoveryCodes called", "user_id", uid)
+	userID, _ := uid.(string)
+
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+	tf, ok := h.enabledTwoFactor(c, userID)
+	if !ok || !h.checkCode(c, tf, req.Code) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err == nil {
+		err = h.Store.ReplaceRecoveryCodes(c, userID, hashes)
+	}
+	if err != nil {
+		logger.From(c).Error("RegenerateRecoveryCodes db error", "user_id", userID, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// AdminResetTwoFactor removes the second factor of a user who lost both their phone and
+// their recovery codes, and revokes their sessions. They enroll again on next login.
+func (h *TwoFactorHandler) AdminResetTwoFactor(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("AdminResetTwoFactor called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var user models.User
+	if err := h.Store.GormClient.WithContext(c).First(&user, "user_id = ?", id).Error; err != nil {
+		apperr.Respond(c, dbError(err, apperr.CodeUserNotFound))
+		return
+	}
+	if err := h.Store.DisableTwoFactor(c, user.UserID); err != nil {
+		logger.From(c).Error("AdminResetTwoFactor db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	if err := h.Store.RevokeAllUserTokens(user.UserID); err != nil {
+		logger.From(c).Error("AdminResetTwoFactor revoke error", "id", id, "error", err)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
@@ -1,23 +1,33 @@
 package handler
 
 import (
+	"adong-be/apperr"
 	"adong-be/models"
 	"adong-be/logger"
-	"adong-be/store"
-	"adong-be/utils"
+	"adong-be/service"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+type UserHandler struct {
+	DB    *gorm.DB
+	Users *service.Users
+}
+
+func NewUserHandler(db *gorm.DB, users *service.Users) *UserHandler {
+	return &UserHandler{DB: db, Users: users}
+}
+
 // GetUsers with pagination and search - Returns ResourceCollection format
-func GetUsers(c *gin.Context) {
-    uid, _ := c.Get("identity")
-    logger.Log.Info("GetUsers called", "user_id", uid)
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetUsers called", "user_id", uid)
 	var params models.PaginationParams
 	if err := c.ShouldBindQuery(&params); err != nil {
-		logger.Log.Error("GetUsers bind query error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.From(c).Error("GetUsers bind query error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
@@ -29,38 +39,10 @@ func GetUsers(c *gin.Context) {
 		params.SortDir,
 	)
 
-	var total int64
-	countDB := store.DB.GormClient.Model(&models.User{})
-
-	searchConfig := utils.SearchConfig{
-		Fields: []string{"user_id", "user_name", "full_name", "email", "phone"},
-		Fuzzy:  true,
-	}
-	countDB = utils.ApplySearch(countDB, params.Search, searchConfig)
-
-	if err := countDB.Count(&total).Error; err != nil {
-		logger.Log.Error("GetUsers count error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
-	var items []models.User
-	db := store.DB.GormClient.Model(&models.User{})
-	db = utils.ApplySearch(db, params.Search, searchConfig)
-
-	allowedSortFields := map[string]string{
-		"user_id":   "user_id",
-		"user_name": "user_name",
-		"full_name": "full_name",
-		"email":     "email",
-		"role":      "role",
-	}
-	db = utils.ApplySort(db, params.SortBy, params.SortDir, allowedSortFields)
-	db = utils.ApplyPagination(db, params.Page, params.PageSize)
-
-	if err := db.Find(&items).Error; err != nil {
-		logger.Log.Error("GetUsers query error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	items, total, err := h.Users.List(c, params)
+	if err != nil {
+		logger.From(c).Error("GetUsers query error", "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 
@@ -71,115 +53,97 @@ func GetUsers(c *gin.Context) {
 	})
 }
 
-func GetUser(c *gin.Context) {
-    uid, _ := c.Get("identity")
-    logger.Log.Info("GetUser called", "id", c.Param("id"), "user_id", uid)
+func (h *UserHandler) GetUser(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetUser called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-	var item models.User
-	if err := store.DB.GormClient.First(&item, "user_id = ?", id).Error; err != nil {
-		logger.Log.Error("GetUser not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	item, err := h.Users.Get(c, id)
+	if err != nil {
+		logger.From(c).Error("GetUser not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeUserNotFound))
 		return
 	}
 	c.JSON(http.StatusOK, item)
 }
 
-func CreateUser(c *gin.Context) {
-    uid, _ := c.Get("identity")
-    logger.Log.Info("CreateUser called", "user_id", uid)
-	var item models.User
-	if err := c.ShouldBindJSON(&item); err != nil {
-		logger.Log.Error("CreateUser bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+// UserInput is the body of CreateUser / UpdateUser. The password is write-only: it is
+// checked against the password policy, stored as a bcrypt hash and never returned.
+type UserInput struct {
+	models.User
+	Password string `json:"password"`
+}
 
-	// Store both plain text and hashed password
-	if item.Password != "" {
-		// Save plain password
-		item.PlainPassword = item.Password
-
-		// Hash password before storing
-		hashedPassword, err := store.HashPassword(item.Password)
-		if err != nil {
-			logger.Log.Error("CreateUser hash password error", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-			return
-		}
-		item.Password = hashedPassword
+// validUserRole rejects roles that are not defined in the roles table
+func validUserRole(c *gin.Context, db *gorm.DB, role string) bool {
+	if role == "" {
+		return true
+	}
+	var count int64
+	if err := db.WithContext(c).Model(&models.Role{}).Where("role_name = ?", role).Count(&count).Error; err != nil {
+		logger.From(c).Error("validate role error", "role", role, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return false
 	}
+	if count == 0 {
+		apperr.Respond(c, apperr.Invalid("role", "exists", role))
+		return false
+	}
+	return true
+}
 
-	if err := store.DB.GormClient.Create(&item).Error; err != nil {
-		logger.Log.Error("CreateUser db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("CreateUser called", "user_id", uid)
+	var input UserInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		logger.From(c).Error("CreateUser bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
-	// Don't return passwords in response
-	item.Password = ""
-	item.PlainPassword = ""
+	item := input.User
+	if err := h.Users.Create(c, &item, input.Password); err != nil {
+		logger.From(c).Error("CreateUser error", "error", err)
+		apperr.Respond(c, serviceError(err, apperr.CodeUserNotFound))
+		return
+	}
 	c.JSON(http.StatusCreated, item)
 }
 
-func UpdateUser(c *gin.Context) {
-    uid, _ := c.Get("identity")
-    logger.Log.Info("UpdateUser called", "id", c.Param("id"), "user_id", uid)
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("UpdateUser called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-	var item models.User
-	if err := store.DB.GormClient.First(&item, "user_id = ?", id).Error; err != nil {
-		logger.Log.Error("UpdateUser not found", "id", id, "error", err)
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	item, err := h.Users.Get(c, id)
+	if err != nil {
+		logger.From(c).Error("UpdateUser not found", "id", id, "error", err)
+		apperr.Respond(c, dbError(err, apperr.CodeUserNotFound))
 		return
 	}
 
-	// Store old passwords
-	oldPassword := item.Password
-	oldPlainPassword := item.PlainPassword
-
-	if err := c.ShouldBindJSON(&item); err != nil {
-		logger.Log.Error("UpdateUser bind error", "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	input := UserInput{User: *item}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		logger.From(c).Error("UpdateUser bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
 		return
 	}
 
-	// Hash new password if provided, otherwise keep old password
-	if item.Password != "" && item.Password != oldPassword {
-		// Save plain password
-		item.PlainPassword = item.Password
-
-		// Hash password
-		hashedPassword, err := store.HashPassword(item.Password)
-		if err != nil {
-			logger.Log.Error("UpdateUser hash password error", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-			return
-		}
-		item.Password = hashedPassword
-	} else {
-		// Keep old passwords
-		item.Password = oldPassword
-		item.PlainPassword = oldPlainPassword
-	}
-
-	if err := store.DB.GormClient.Save(&item).Error; err != nil {
-		logger.Log.Error("UpdateUser db error", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	updated := input.User
+	if err := h.Users.Update(c, *item, &updated, input.Password); err != nil {
+		logger.From(c).Error("UpdateUser error", "id", id, "error", err)
+		apperr.Respond(c, serviceError(err, apperr.CodeUserNotFound))
 		return
 	}
-
-	// Don't return passwords in response
-	item.Password = ""
-	item.PlainPassword = ""
-	c.JSON(http.StatusOK, item)
+	c.JSON(http.StatusOK, updated)
 }
 
-func DeleteUser(c *gin.Context) {
-    uid, _ := c.Get("identity")
-    logger.Log.Info("DeleteUser called", "id", c.Param("id"), "user_id", uid)
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("DeleteUser called", "id", c.Param("id"), "user_id", uid)
 	id := c.Param("id")
-	if err := store.DB.GormClient.Delete(&models.User{}, "user_id = ?", id).Error; err != nil {
-		logger.Log.Error("DeleteUser db error", "id", id, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.Users.Delete(c, id); err != nil {
+		logger.From(c).Error("DeleteUser db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
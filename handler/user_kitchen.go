@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UserKitchenRequest - One kitchen assignment; an empty roleName keeps the user's global role
+type UserKitchenRequest struct {
+	KitchenID string `json:"kitchenId" binding:"required"`
+	RoleName  string `json:"roleName"`
+}
+
+// GetUserKitchens lists the kitchens assigned to a user with their per-kitchen role
+func (h *UserHandler) GetUserKitchens(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("GetUserKitchens called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var count int64
+	h.DB.WithContext(c).Model(&models.User{}).Where("user_id = ?", id).Count(&count)
+	if count == 0 {
+		apperr.Respond(c, apperr.New(apperr.CodeUserNotFound))
+		return
+	}
+
+	var items []models.UserKitchen
+	if err := h.DB.WithContext(c).Preload("Kitchen").Where("user_id = ?", id).
+		Order("kitchen_id").Find(&items).Error; err != nil {
+		logger.From(c).Error("GetUserKitchens query error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
+
+// ReplaceUserKitchens replaces every kitchen assignment of a user
+func (h *UserHandler) ReplaceUserKitchens(c *gin.Context) {
+	uid, _ := c.Get("identity")
+	logger.From(c).Info("ReplaceUserKitchens called", "id", c.Param("id"), "user_id", uid)
+	id := c.Param("id")
+
+	var req []UserKitchenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.From(c).Error("ReplaceUserKitchens bind error", "error", err)
+		apperr.Respond(c, apperr.Bind(err))
+		return
+	}
+
+	var count int64
+	h.DB.WithContext(c).Model(&models.User{}).Where("user_id = ?", id).Count(&count)
+	if count == 0 {
+		apperr.Respond(c, apperr.New(apperr.CodeUserNotFound))
+		return
+	}
+
+	items := make([]models.UserKitchen, 0, len(req))
+	seen := make(map[string]bool, len(req))
+	for _, r := range req {
+		if r.KitchenID == "" {
+			apperr.Respond(c, apperr.Invalid("kitchenId", "required"))
+			return
+		}
+		if seen[r.KitchenID] {
+			apperr.Respond(c, apperr.Invalid("kitchenId", "unique", r.KitchenID))
+			return
+		}
+		seen[r.KitchenID] = true
+		if !validUserRole(c, h.DB, r.RoleName) {
+			return
+		}
+		item := models.UserKitchen{UserID: id, KitchenID: r.KitchenID}
+		if r.RoleName != "" {
+			role := r.RoleName
+			item.RoleName = &role
+		}
+		items = append(items, item)
+	}
+
+	if len(seen) > 0 {
+		kitchenIDs := make([]string, 0, len(seen))
+		for k := range seen {
+			kitchenIDs = append(kitchenIDs, k)
+		}
+		var found int64
+		if err := h.DB.WithContext(c).Model(&models.Kitchen{}).Where("kitchen_id IN ?", kitchenIDs).Count(&found).Error; err != nil {
+			logger.From(c).Error("ReplaceUserKitchens kitchen lookup error", "error", err)
+			apperr.Respond(c, apperr.Internal(err))
+			return
+		}
+		if int(found) != len(kitchenIDs) {
+			apperr.Respond(c, apperr.Invalid("kitchenId", "exists"))
+			return
+		}
+	}
+
+	err := h.DB.WithContext(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", id).Delete(&models.UserKitchen{}).Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.Omit("Kitchen").Create(&items).Error
+	})
+	if err != nil {
+		logger.From(c).Error("ReplaceUserKitchens db error", "id", id, "error", err)
+		apperr.Respond(c, apperr.Internal(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": items})
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"adong-be/apperr"
+	"adong-be/etag"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// nextVersion increments the version of the records an update changes
+var nextVersion = gorm.Expr("version + 1")
+
+// setETag sends version as the ETag of the record in the response
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", etag.Format(version))
+}
+
+// ifMatch reads the If-Match header a change of a versioned record requires, writing 428
+// when it is missing and 400 when it holds no ETag the API sent
+func ifMatch(c *gin.Context) (etag.Condition, bool) {
+	cond, err := etag.Parse(c.GetHeader("If-Match"))
+	switch {
+	case errors.Is(err, etag.ErrMissing):
+		apperr.Respond(c, apperr.Wrap(apperr.CodePreconditionRequired, err))
+		return cond, false
+	case err != nil:
+		apperr.Respond(c, apperr.Invalid("If-Match", "etag"))
+		return cond, false
+	}
+	return cond, true
+}
+
+// versionConflict writes 412 with the current state of the record and its ETag, so the
+// client can show what changed before trying again
+func versionConflict(c *gin.Context, version int, current any) {
+	setETag(c, version)
+	apperr.Respond(c, apperr.New(apperr.CodeVersionMismatch).With("current", current))
+}
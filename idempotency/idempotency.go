@@ -0,0 +1,185 @@
+// Package idempotency makes retried requests safe. A client sends the same Idempotency-Key
+// header with every attempt of a request; the first attempt runs and its response is stored
+// with a hash of the request, later attempts get the stored response back instead of
+// creating or approving a second time. A key reused for a different request is rejected, and
+// an attempt arriving while the first one still runs is told to retry later. Memory keeps
+// the keys in process; Postgres shares them between replicas through the idempotency_keys
+// table.
+package idempotency
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Header carries the key chosen by the client, e.g. a UUID
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set to "true" on stored responses sent again
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// Retention is how long keys and their responses are kept
+	Retention = 24 * time.Hour
+	// LockTimeout is how long a request may hold its key before it is considered abandoned,
+	// e.g. by a replica that crashed, and the key may be used again
+	LockTimeout = 5 * time.Minute
+
+	maxKeyLength = 255
+)
+
+// Record is the request stored under a key and, once it is done, its response. Besides the
+// body the response keeps the headers a client acts on: the ETag of a created or approved
+// document for its next If-Match, and the Location of a created one.
+type Record struct {
+	RequestHash string
+	Done        bool
+	Status      int
+	ContentType string
+	ETag        string
+	Location    string
+	Body        []byte
+}
+
+// Store keeps the keys of one or more replicas. Keys are scoped by owner, the user sending
+// the request, so users cannot see each other's responses.
+type Store interface {
+	// Claim stores a new record with hash under key unless the key is taken. It returns the
+	// record of the key and whether it was created by this call. Records older than
+	// Retention, and unfinished records older than LockTimeout, are replaced.
+	Claim(ctx context.Context, owner, key, hash string, now time.Time) (Record, bool, error)
+	// Complete stores the response of a claimed key: the status, headers and body of resp
+	Complete(ctx context.Context, owner, key string, resp Record) error
+	// Release deletes a claimed key whose request failed, so it can be retried with the key
+	Release(ctx context.Context, owner, key string) error
+}
+
+// Middleware runs the request once per Idempotency-Key and replays its response for the
+// retries. Requests without the header run as usual. Only successful responses are stored:
+// a failed request changed nothing, so its key is released and a retry runs again.
+func Middleware(s Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(Header)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if !validKey(key) {
+			apperr.Respond(c, apperr.New(apperr.CodeIdempotencyKeyInvalid))
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apperr.Respond(c, apperr.Wrap(apperr.CodeInvalidRequest, err))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		owner := c.GetString("identity")
+		hash := requestHash(c.Request, body)
+		rec, claimed, err := s.Claim(c, owner, key, hash, time.Now())
+		if err != nil {
+			apperr.Respond(c, apperr.Internal(err))
+			return
+		}
+		if !claimed {
+			switch {
+			case rec.RequestHash != hash:
+				apperr.Respond(c, apperr.New(apperr.CodeIdempotencyKeyReused))
+			case !rec.Done:
+				apperr.Respond(c, apperr.New(apperr.CodeIdempotencyInProgress))
+			default:
+				c.Header(ReplayedHeader, "true")
+				if rec.ETag != "" {
+					c.Header("ETag", rec.ETag)
+				}
+				if rec.Location != "" {
+					c.Header("Location", rec.Location)
+				}
+				c.Data(rec.Status, rec.ContentType, rec.Body)
+				c.Abort()
+			}
+			return
+		}
+
+		// The outcome is stored even when the client has gone away, that is when it retries
+		ctx := context.WithoutCancel(c)
+		w := &recorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		stored := false
+		defer func() {
+			if stored {
+				return
+			}
+			if err := s.Release(ctx, owner, key); err != nil {
+				logger.From(c).Error("release idempotency key error", "key", key, "error", err)
+			}
+		}()
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusBadRequest {
+			return
+		}
+		header := c.Writer.Header()
+		resp := Record{
+			Status:      status,
+			ContentType: header.Get("Content-Type"),
+			ETag:        header.Get("ETag"),
+			Location:    header.Get("Location"),
+			Body:        w.body.Bytes(),
+		}
+		if err := s.Complete(ctx, owner, key, resp); err != nil {
+			logger.From(c).Error("store idempotent response error", "key", key, "error", err)
+			return
+		}
+		stored = true
+	}
+}
+
+// validKey accepts 1 to 255 printable ASCII characters
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '!' || key[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// requestHash identifies a request by its method, path, query and body
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder keeps a copy of the response body
+type recorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,130 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newEngine serves POST /imports, creating a draft per call unless fail is set. The draft is
+// answered with its Location and ETag.
+func newEngine(s Store, created *int, fail *bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("identity", c.GetHeader("X-User"))
+	})
+	r.POST("/imports", Middleware(s), func(c *gin.Context) {
+		if *fail {
+			c.JSON(http.StatusBadRequest, gin.H{"code": "STOCK_INSUFFICIENT"})
+			return
+		}
+		*created++
+		c.Header("Location", fmt.Sprintf("/api/inventory/imports/%d", *created))
+		c.Header("ETag", `"1"`)
+		c.JSON(http.StatusCreated, gin.H{"importId": *created})
+	})
+	return r
+}
+
+func post(r *gin.Engine, user, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/imports", strings.NewReader(body))
+	req.Header.Set("X-User", user)
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	created, fail := 0, false
+	s := NewMemory()
+	r := newEngine(s, &created, &fail)
+
+	first := post(r, "bep01", "k1", `{"kitchenId":"K1"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(ReplayedHeader))
+
+	retry := post(r, "bep01", "k1", `{"kitchenId":"K1"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(ReplayedHeader))
+	assert.Equal(t, "application/json; charset=utf-8", retry.Header().Get("Content-Type"))
+	assert.Equal(t, `"1"`, retry.Header().Get("ETag"), "the retry can send If-Match for the draft")
+	assert.Equal(t, "/api/inventory/imports/1", retry.Header().Get("Location"))
+	assert.Equal(t, 1, created, "the retry did not create a second draft")
+
+	other := post(r, "bep01", "k1", `{"kitchenId":"K2"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, other.Code)
+	assert.Contains(t, other.Body.String(), "IDEMPOTENCY_KEY_REUSED")
+
+	assert.Equal(t, http.StatusCreated, post(r, "bep02", "k1", `{"kitchenId":"K1"}`).Code, "keys are per user")
+	assert.Equal(t, http.StatusCreated, post(r, "bep01", "", `{"kitchenId":"K1"}`).Code)
+	assert.Equal(t, 3, created)
+
+	invalid := post(r, "bep01", "key with spaces", `{}`)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	assert.Contains(t, invalid.Body.String(), "IDEMPOTENCY_KEY_INVALID")
+}
+
+// A failed request changed nothing, its retry runs again
+func TestMiddlewareReleasesFailures(t *testing.T) {
+	created, fail := 0, true
+	r := newEngine(NewMemory(), &created, &fail)
+
+	assert.Equal(t, http.StatusBadRequest, post(r, "bep01", "k1", `{}`).Code)
+	fail = false
+	retry := post(r, "bep01", "k1", `{}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get(ReplayedHeader))
+	assert.Equal(t, 1, created)
+}
+
+// An attempt arriving while the first one runs is rejected, not run twice
+func TestMiddlewareInProgress(t *testing.T) {
+	created, fail := 0, false
+	s := NewMemory()
+	r := newEngine(s, &created, &fail)
+
+	body := `{"approve":true}`
+	req := httptest.NewRequest(http.MethodPost, "/imports", strings.NewReader(body))
+	_, claimed, err := s.Claim(context.Background(), "bep01", "k1", requestHash(req, []byte(body)), time.Now())
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	w := post(r, "bep01", "k1", body)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "IDEMPOTENCY_IN_PROGRESS")
+	assert.Equal(t, 0, created)
+}
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	now := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	_, claimed, _ := m.Claim(ctx, "bep01", "abandoned", "h1", now)
+	require.True(t, claimed)
+	_, claimed, _ = m.Claim(ctx, "bep01", "abandoned", "h1", now.Add(LockTimeout-time.Second))
+	assert.False(t, claimed)
+	_, claimed, _ = m.Claim(ctx, "bep01", "abandoned", "h2", now.Add(LockTimeout))
+	assert.True(t, claimed, "an unfinished request is given up after LockTimeout")
+
+	m.Claim(ctx, "bep01", "done", "h1", now)
+	require.NoError(t, m.Complete(ctx, "bep01", "done", Record{Status: http.StatusCreated, ContentType: "application/json", ETag: `"1"`, Body: []byte(`{}`)}))
+	rec, claimed, _ := m.Claim(ctx, "bep01", "done", "h1", now.Add(Retention-time.Second))
+	assert.False(t, claimed)
+	assert.Equal(t, Record{RequestHash: "h1", Done: true, Status: http.StatusCreated, ContentType: "application/json", ETag: `"1"`, Body: []byte(`{}`)}, rec)
+	_, claimed, _ = m.Claim(ctx, "bep01", "done", "h1", now.Add(Retention))
+	assert.True(t, claimed)
+}
@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps the keys of a single process. Keys are lost on restart and are not shared
+// between replicas; use Postgres when running more than one instance.
+type Memory struct {
+	mu        sync.Mutex
+	records   map[memoryKey]memoryRecord
+	lastPrune time.Time
+}
+
+type memoryKey struct{ owner, key string }
+
+type memoryRecord struct {
+	Record
+	created time.Time
+}
+
+// NewMemory returns an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{records: make(map[memoryKey]memoryRecord)}
+}
+
+func (m *Memory) Claim(ctx context.Context, owner, key, hash string, now time.Time) (Record, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now)
+
+	k := memoryKey{owner, key}
+	if r, ok := m.records[k]; ok && !expired(r.Done, r.created, now) {
+		return r.Record, false, nil
+	}
+	r := memoryRecord{Record: Record{RequestHash: hash}, created: now}
+	m.records[k] = r
+	return r.Record, true, nil
+}
+
+func (m *Memory) Complete(ctx context.Context, owner, key string, resp Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := memoryKey{owner, key}
+	r, ok := m.records[k]
+	if !ok {
+		return nil
+	}
+	resp.RequestHash, resp.Done = r.RequestHash, true
+	r.Record = resp
+	m.records[k] = r
+	return nil
+}
+
+func (m *Memory) Release(ctx context.Context, owner, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, memoryKey{owner, key})
+	return nil
+}
+
+// prune drops expired records, at most once per hour
+func (m *Memory) prune(now time.Time) {
+	if now.Sub(m.lastPrune) < time.Hour {
+		return
+	}
+	for k, r := range m.records {
+		if now.Sub(r.created) >= Retention {
+			delete(m.records, k)
+		}
+	}
+	m.lastPrune = now
+}
+
+// expired reports whether a record created at created may be replaced at now
+func expired(done bool, created, now time.Time) bool {
+	age := now.Sub(created)
+	return age >= Retention || !done && age >= LockTimeout
+}
@@ -0,0 +1,99 @@
+package idempotency
+
+import (
+	"adong-be/logger"
+	"adong-be/models"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Postgres keeps the keys in the idempotency_keys table so a retry reaching another replica
+// is recognised. Claim is a single upsert, of concurrent attempts only one claims the key.
+type Postgres struct {
+	DB *gorm.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewPostgres returns a store keeping its keys through db
+func NewPostgres(db *gorm.DB) *Postgres {
+	return &Postgres{DB: db}
+}
+
+func (p *Postgres) Claim(ctx context.Context, owner, key, hash string, now time.Time) (Record, bool, error) {
+	p.prune(ctx, now)
+
+	db := p.DB.WithContext(ctx)
+	res := db.Exec(`
+		INSERT INTO idempotency_keys (owner, idempotency_key, request_hash, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (owner, idempotency_key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			content_type = NULL,
+			etag = NULL,
+			location = NULL,
+			response_body = NULL,
+			created_at = EXCLUDED.created_at
+		WHERE idempotency_keys.created_at <= ?
+			OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= ?)`,
+		owner, key, hash, now, now.Add(-Retention), now.Add(-LockTimeout))
+	if res.Error != nil {
+		return Record{}, false, res.Error
+	}
+	if res.RowsAffected == 1 {
+		return Record{RequestHash: hash}, true, nil
+	}
+
+	var row models.IdempotencyKey
+	err := db.First(&row, "owner = ? AND idempotency_key = ?", owner, key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// Released since the insert, the request holding it failed: as good as in progress
+		return Record{RequestHash: hash}, false, nil
+	}
+	if err != nil {
+		return Record{}, false, err
+	}
+	r := Record{RequestHash: row.RequestHash, ContentType: row.ContentType, ETag: row.ETag, Location: row.Location, Body: row.ResponseBody}
+	if row.StatusCode != nil {
+		r.Done, r.Status = true, *row.StatusCode
+	}
+	return r, false, nil
+}
+
+func (p *Postgres) Complete(ctx context.Context, owner, key string, resp Record) error {
+	return p.DB.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("owner = ? AND idempotency_key = ?", owner, key).
+		Updates(map[string]interface{}{
+			"status_code":   resp.Status,
+			"content_type":  resp.ContentType,
+			"etag":          resp.ETag,
+			"location":      resp.Location,
+			"response_body": resp.Body,
+		}).Error
+}
+
+func (p *Postgres) Release(ctx context.Context, owner, key string) error {
+	return p.DB.WithContext(ctx).Where("owner = ? AND idempotency_key = ?", owner, key).Delete(&models.IdempotencyKey{}).Error
+}
+
+// prune deletes expired keys, at most once per hour and replica
+func (p *Postgres) prune(ctx context.Context, now time.Time) {
+	p.mu.Lock()
+	due := now.Sub(p.lastPrune) >= time.Hour
+	if due {
+		p.lastPrune = now
+	}
+	p.mu.Unlock()
+	if !due {
+		return
+	}
+	if err := p.DB.WithContext(ctx).Where("created_at <= ?", now.Add(-Retention)).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		logger.Log.Error("prune idempotency keys error", "error", err)
+	}
+}
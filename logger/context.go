@@ -0,0 +1,21 @@
+package logger
+
+import "context"
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l LoggerI) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// From returns the logger of the request ctx belongs to, which adds the request and trace
+// IDs to every line, or Log outside requests
+func From(ctx context.Context) LoggerI {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(LoggerI); ok {
+			return l
+		}
+	}
+	return Log
+}
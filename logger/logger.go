@@ -11,6 +11,8 @@ type LoggerI interface {
 	Warn(msg string, args ...interface{})
 	Error(msg string, args ...interface{})
 	Debug(msg string, args ...interface{})
+	// With returns a logger adding args to every line
+	With(args ...interface{}) LoggerI
 
 }
 
@@ -50,6 +52,10 @@ func (l *Logger) Debug(msg string, args ...interface{}) {
 	l.SugaredLogger.With(args...).Debug(msg)
 }
 
+func (l *Logger) With(args ...interface{}) LoggerI {
+	return &Logger{SugaredLogger: l.SugaredLogger.With(args...)}
+}
+
 var (
 	Log  LoggerI = NewLogger()
 )
\ No newline at end of file
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"adong-be/config"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSender(t *testing.T) {
+	dir := t.TempDir()
+	sender := New(config.MailConfig{Driver: config.MailDriverFile, FileDir: dir, From: "no-reply@example.com"})
+
+	err := sender.Send(context.Background(), Message{To: "an@example.com", Subject: "Đặt lại mật khẩu", Body: "line 1\nline 2"})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	content := string(data)
+	assert.Contains(t, content, "To: an@example.com\r\n")
+	assert.Contains(t, content, "Subject: =?UTF-8?q?")
+	assert.True(t, strings.HasSuffix(content, "line 1\r\nline 2"))
+}
+
+func TestRejectsHeaderInjection(t *testing.T) {
+	sender := New(config.MailConfig{Driver: config.MailDriverFile, FileDir: t.TempDir(), From: "no-reply@example.com"})
+	err := sender.Send(context.Background(), Message{To: "a@example.com\r\nBcc: all@example.com", Subject: "x"})
+	assert.Error(t, err)
+}
+
+func TestNewSelectsDriver(t *testing.T) {
+	assert.IsType(t, LogSender{}, New(config.MailConfig{Driver: config.MailDriverLog}))
+	assert.IsType(t, &SMTPSender{}, New(config.MailConfig{Driver: config.MailDriverSMTP, SMTPHost: "smtp.example.com", SMTPPort: 587}))
+}
@@ -0,0 +1,189 @@
+// Package matching suggests master ingredients for supplier products using
+// diacritic-insensitive name similarity and unit compatibility.
+package matching
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	nameWeight = 0.8
+	unitWeight = 0.2
+
+	// MinScore is the lowest score returned as a suggestion
+	MinScore = 0.35
+)
+
+// Ingredient is a master ingredient that a product can be mapped to
+type Ingredient struct {
+	IngredientID   string
+	IngredientName string
+	Unit           string
+}
+
+// Suggestion is a scored ingredient match for a product
+type Suggestion struct {
+	IngredientID   string  `json:"ingredientId"`
+	IngredientName string  `json:"ingredientName"`
+	Unit           string  `json:"unit"`
+	Score          float64 `json:"score"`
+	NameScore      float64 `json:"nameScore"`
+	UnitCompatible bool    `json:"unitCompatible"`
+}
+
+// Normalize lowercases s, strips Vietnamese diacritics (including đ) and
+// collapses everything that is not a letter or digit into single spaces
+func Normalize(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	out, _, err := transform.String(t, strings.ToLower(s))
+	if err != nil {
+		out = strings.ToLower(s)
+	}
+	out = strings.NewReplacer("đ", "d", "Đ", "d").Replace(out)
+
+	var b strings.Builder
+	space := true
+	for _, r := range out {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// NameSimilarity scores two names between 0 and 1. It combines word overlap and
+// character bigram overlap of the normalized names; a name whose words are all
+// contained in the other (e.g. "thit heo" in "thit heo ba chi loai 1") scores at least 0.85.
+func NameSimilarity(a, b string) float64 {
+	na, nb := Normalize(a), Normalize(b)
+	if na == "" || nb == "" {
+		return 0
+	}
+	if na == nb {
+		return 1
+	}
+
+	wa, wb := strings.Fields(na), strings.Fields(nb)
+	score := 0.5*dice(wa, wb) + 0.5*dice(bigrams(na), bigrams(nb))
+	if containsAll(wa, wb) || containsAll(wb, wa) {
+		score = max(score, 0.85)
+	}
+	return score
+}
+
+// unitGroups maps normalized unit spellings to a dimension; units in the same
+// dimension can be converted into each other
+var unitGroups = map[string]string{
+	"kg": "mass", "kilogram": "mass", "g": "mass", "gr": "mass", "gram": "mass", "gam": "mass",
+	"lang": "mass", "yen": "mass", "ta": "mass", "tan": "mass",
+	"l": "volume", "lit": "volume", "litre": "volume", "liter": "volume", "ml": "volume", "cc": "volume",
+}
+
+// UnitsCompatible reports whether a product sold in productUnit can be used for an
+// ingredient measured in ingredientUnit: the same unit, or two units of the same dimension
+func UnitsCompatible(productUnit, ingredientUnit string) bool {
+	pu, iu := Normalize(productUnit), Normalize(ingredientUnit)
+	if pu == "" || iu == "" {
+		return false
+	}
+	if pu == iu {
+		return true
+	}
+	gp, ok1 := unitGroups[pu]
+	gi, ok2 := unitGroups[iu]
+	return ok1 && ok2 && gp == gi
+}
+
+// Suggest returns up to limit ingredients ranked by how well they match the product.
+// Ingredients listed in rejected are skipped.
+func Suggest(productName, productUnit string, ingredients []Ingredient, rejected map[string]bool, limit int) []Suggestion {
+	suggestions := make([]Suggestion, 0)
+	for _, ing := range ingredients {
+		if rejected[ing.IngredientID] {
+			continue
+		}
+		nameScore := NameSimilarity(productName, ing.IngredientName)
+		compatible := UnitsCompatible(productUnit, ing.Unit)
+		unitScore := 0.0
+		if compatible {
+			unitScore = 1
+		}
+		score := nameWeight*nameScore + unitWeight*unitScore
+		if score < MinScore {
+			continue
+		}
+		suggestions = append(suggestions, Suggestion{
+			IngredientID:   ing.IngredientID,
+			IngredientName: ing.IngredientName,
+			Unit:           ing.Unit,
+			Score:          score,
+			NameScore:      nameScore,
+			UnitCompatible: compatible,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].IngredientID < suggestions[j].IngredientID
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+func bigrams(s string) []string {
+	r := []rune(strings.ReplaceAll(s, " ", ""))
+	if len(r) < 2 {
+		return []string{string(r)}
+	}
+	out := make([]string, 0, len(r)-1)
+	for i := 0; i < len(r)-1; i++ {
+		out = append(out, string(r[i:i+2]))
+	}
+	return out
+}
+
+// dice is the Sørensen–Dice coefficient of two multisets
+func dice(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	counts := make(map[string]int, len(a))
+	for _, x := range a {
+		counts[x]++
+	}
+	common := 0
+	for _, x := range b {
+		if counts[x] > 0 {
+			counts[x]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+func containsAll(haystack, needles []string) bool {
+	set := make(map[string]bool, len(haystack))
+	for _, w := range haystack {
+		set[w] = true
+	}
+	for _, w := range needles {
+		if !set[w] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package matching
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"Thịt heo ba chỉ":    "thit heo ba chi",
+		"ĐẬU HŨ  (non)":      "dau hu non",
+		"Rau muống - loại 1": "rau muong loai 1",
+		"Cà chua Đà Lạt":     "ca chua da lat",
+		"":                   "",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, Normalize(in), in)
+	}
+}
+
+func TestUnitsCompatible(t *testing.T) {
+	assert.True(t, UnitsCompatible("kg", "Kg"))
+	assert.True(t, UnitsCompatible("gram", "kg"))
+	assert.True(t, UnitsCompatible("Lít", "ml"))
+	assert.True(t, UnitsCompatible("Bó", "bó"))
+	assert.False(t, UnitsCompatible("kg", "lít"))
+	assert.False(t, UnitsCompatible("hộp", "kg"))
+	assert.False(t, UnitsCompatible("", "kg"))
+}
+
+func TestSuggest(t *testing.T) {
+	ingredients := []Ingredient{
+		{IngredientID: "NL001", IngredientName: "Thịt heo ba chỉ", Unit: "kg"},
+		{IngredientID: "NL002", IngredientName: "Thịt bò", Unit: "kg"},
+		{IngredientID: "NL003", IngredientName: "Rau muống", Unit: "bó"},
+		{IngredientID: "NL004", IngredientName: "Thit heo ba chi", Unit: "hộp"},
+	}
+
+	got := Suggest("THIT HEO BA CHỈ LOẠI 1", "Kg", ingredients, nil, 3)
+	if assert.NotEmpty(t, got) {
+		assert.Equal(t, "NL001", got[0].IngredientID)
+		assert.True(t, got[0].UnitCompatible)
+	}
+	// same name but incompatible unit ranks lower
+	ids := []string{}
+	for _, s := range got {
+		ids = append(ids, s.IngredientID)
+	}
+	assert.Contains(t, ids, "NL004")
+	assert.NotContains(t, ids, "NL003")
+
+	got = Suggest("Thịt heo ba chỉ", "kg", ingredients, map[string]bool{"NL001": true}, 3)
+	if assert.NotEmpty(t, got) {
+		assert.NotEqual(t, "NL001", got[0].IngredientID)
+	}
+
+	assert.Empty(t, Suggest("Nước mắm", "chai", ingredients, nil, 3))
+}
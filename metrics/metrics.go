@@ -0,0 +1,127 @@
+// Package metrics holds the Prometheus metrics of the API: requests by route and status,
+// the database pool, business events and the outcome of background jobs. They are
+// registered on Registry, which /metrics serves.
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "adong"
+
+// Registry holds every metric of the process, including the Go runtime and process ones
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to answer HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// OrdersCreated counts orders stored
+	OrdersCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "orders_created_total",
+		Help:      "Orders created.",
+	})
+
+	// ImportsApproved counts inventory imports approved, i.e. goods received into stock
+	ImportsApproved = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "inventory_imports_approved_total",
+		Help:      "Inventory imports approved.",
+	})
+
+	// StockOuts counts movements of approved documents that left a stock at or below zero
+	StockOuts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "inventory_stock_outs_total",
+		Help:      "Stock movements leaving an ingredient of a kitchen at or below zero.",
+	})
+
+	jobRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "job_runs_total",
+		Help:      "Background job runs by job and outcome (success or failure).",
+	}, []string{"job", "outcome"})
+
+	jobDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "job_duration_seconds",
+		Help:      "Time background job runs took by job.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 4, 8),
+	}, []string{"job"})
+
+	jobLastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "job_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful run of each background job.",
+	}, []string{"job"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requests, requestDuration,
+		OrdersCreated, ImportsApproved, StockOuts,
+		jobRuns, jobDuration, jobLastSuccess,
+	)
+}
+
+// unmatchedRoute labels requests that matched no route, so scanners probing random paths
+// cannot grow the number of series
+const unmatchedRoute = "unmatched"
+
+// Middleware counts and times every request by its route pattern, e.g. /api/orders/:id
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		requests.WithLabelValues(c.Request.Method, route, status).Inc()
+		requestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves Registry in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	h := promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+	return gin.WrapH(h)
+}
+
+// RegisterDB exports the connection pool statistics of db, labelled db_name="postgres"
+func RegisterDB(db *sql.DB) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, "postgres"))
+}
+
+// ObserveJob records a run of the background job started at start, failed when err is set
+func ObserveJob(job string, start time.Time, err error) {
+	jobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+	if err != nil {
+		jobRuns.WithLabelValues(job, "failure").Inc()
+		return
+	}
+	jobRuns.WithLabelValues(job, "success").Inc()
+	jobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/orders/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	for _, target := range []string{"/orders/1", "/orders/2", "/wp-admin"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(requests.WithLabelValues("GET", "/orders/:id", "204")), "requests are labelled by route, not path")
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues("GET", unmatchedRoute, "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(requestDuration))
+}
+
+func TestObserveJob(t *testing.T) {
+	start := time.Now()
+	ObserveJob("test", start, nil)
+	ObserveJob("test", start, errors.New("database gone"))
+	ObserveJob("test", start, nil)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(jobRuns.WithLabelValues("test", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(jobRuns.WithLabelValues("test", "failure")))
+	assert.Greater(t, testutil.ToFloat64(jobLastSuccess.WithLabelValues("test")), 0.0)
+}
+
+func TestHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	OrdersCreated.Inc()
+	r := gin.New()
+	r.GET("/metrics", Handler())
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "adong_orders_created_total 1")
+	assert.Contains(t, w.Body.String(), "go_goroutines")
+}
@@ -1,116 +1,357 @@
+// Package migrate applies the numbered schema migrations in migrations/. Each migration has
+// an up and a down file, runs in its own transaction and is recorded in schema_migrations
+// with the checksum of its up file. A PostgreSQL advisory lock serializes replicas starting
+// at the same time and the migrate command.
 package migrate
 
 import (
-	"embed"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sort"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-//go:embed sql/*.sql
-var sqlFiles embed.FS
+// lockKey identifies the advisory lock held while migrating
+const lockKey int64 = 0x61646f6e67 // "adong"
 
-// AutoMigrate runs database migrations in order
-func AutoMigrate(db *gorm.DB) error {
-	log.Println("Starting database auto-migration...")
+// baselineVersion is the last migration whose schema was created before versions were
+// tracked: databases set up from db.sql and the admin user script are recorded as being at
+// this version the first time the migrator sees them
+const baselineVersion = 2
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS public.schema_migrations
+(
+    version bigint NOT NULL,
+    name character varying(255) NOT NULL,
+    checksum character(64) NOT NULL,
+    applied_at timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT schema_migrations_pkey PRIMARY KEY (version)
+)`
+
+// ErrUnknownVersion is returned when a target version has no migration
+var ErrUnknownVersion = errors.New("unknown migration version")
+
+// Migrator - Applies and reverts migrations against one database
+type Migrator struct {
+	DB         *gorm.DB
+	Migrations []Migration
+	Logf       func(format string, args ...any)
+}
 
-	// Check if database needs initialization by checking for master_users table
-	var tableExists bool
-	err := db.Raw("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'master_users')").Scan(&tableExists).Error
+// New returns a migrator for the migrations embedded in the binary
+func New(db *gorm.DB) (*Migrator, error) {
+	migrations, err := Embedded()
 	if err != nil {
-		return fmt.Errorf("failed to check if database is initialized: %w", err)
+		return nil, err
 	}
+	return &Migrator{DB: db, Migrations: migrations, Logf: log.Printf}, nil
+}
 
-	if tableExists {
-		log.Println("Database already initialized, skipping migration")
-		return nil
+// AutoMigrate applies every pending migration, it runs at startup
+func AutoMigrate(db *gorm.DB) error {
+	m, err := New(db)
+	if err != nil {
+		return err
 	}
+	return m.Up(context.Background())
+}
 
-	log.Println("Database not initialized, running schema creation...")
+// Status - State of one migration in the database
+type Status struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Modified is set when the up file changed after the migration was applied
+	Modified bool
+	// Unknown is set for an applied version this build has no files for
+	Unknown bool
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
 
-	// Read and execute migration files in order
-	migrations := []struct {
-		name string
-		path string
-	}{
-		{"schema", "sql/db.sql"},
-		{"admin_user", "sql/init_admin_user.sql"},
+// Latest returns the highest known version, 0 without migrations
+func (m *Migrator) Latest() int {
+	if len(m.Migrations) == 0 {
+		return 0
 	}
+	return m.Migrations[len(m.Migrations)-1].Version
+}
 
-	for _, migration := range migrations {
-		log.Printf("Running migration: %s", migration.name)
+// Up applies every pending migration
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
 
-		content, err := sqlFiles.ReadFile(migration.path)
-		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", migration.path, err)
+// Down reverts the steps most recent migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("down needs at least one step, got %d", steps)
+	}
+	return m.withLock(ctx, func(conn *gorm.DB, applied []appliedMigration) error {
+		if err := m.verify(applied); err != nil {
+			return err
 		}
+		target := 0
+		if steps < len(applied) {
+			target = applied[len(applied)-steps-1].Version
+		}
+		return m.migrate(conn, applied, target)
+	})
+}
 
-		// Execute the SQL file
-		if err := db.Exec(string(content)).Error; err != nil {
-			return fmt.Errorf("failed to execute migration %s: %w", migration.name, err)
+// To applies or reverts migrations until the database is at version; 0 reverts everything
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.withLock(ctx, func(conn *gorm.DB, applied []appliedMigration) error {
+		if err := m.verify(applied); err != nil {
+			return err
 		}
+		return m.migrate(conn, applied, version)
+	})
+}
+
+// Status lists every known migration and every applied one, by version
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.withLock(ctx, func(conn *gorm.DB, applied []appliedMigration) error {
+		statuses = m.status(applied)
+		return nil
+	})
+	return statuses, err
+}
 
-		log.Printf("Migration %s completed successfully", migration.name)
+func (m *Migrator) status(applied []appliedMigration) []Status {
+	byVersion := make(map[int]appliedMigration, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+
+	var statuses []Status
+	for _, mig := range m.Migrations {
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := byVersion[mig.Version]; ok {
+			appliedAt := a.AppliedAt
+			s.AppliedAt = &appliedAt
+			s.Modified = a.Checksum != mig.Checksum
+			delete(byVersion, mig.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for _, a := range applied {
+		if _, ok := byVersion[a.Version]; ok {
+			appliedAt := a.AppliedAt
+			statuses = append(statuses, Status{Version: a.Version, Name: a.Name, AppliedAt: &appliedAt, Unknown: true})
+		}
 	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses
+}
 
-	log.Println("Database auto-migration completed successfully")
+// verify refuses to migrate a database whose history does not match the files: a changed
+// migration would leave databases migrated before and after the change different, and an
+// unknown one means a newer build already migrated the database
+func (m *Migrator) verify(applied []appliedMigration) error {
+	for _, a := range applied {
+		mig := m.find(a.Version)
+		if mig == nil {
+			return fmt.Errorf("migration %04d_%s is applied but unknown to this build", a.Version, a.Name)
+		}
+		if mig.Checksum != a.Checksum {
+			return fmt.Errorf("migration %s was modified after it was applied", mig.fileName("up"))
+		}
+	}
 	return nil
 }
 
-// RunMigrations executes all SQL migrations in the sql directory
-// This is a more flexible version that automatically discovers and runs all .sql files
-func RunMigrations(db *gorm.DB) error {
-	log.Println("Starting database migrations...")
+// migrate applies the pending migrations up to target, oldest first, then reverts the
+// applied ones above it, newest first
+func (m *Migrator) migrate(conn *gorm.DB, applied []appliedMigration, target int) error {
+	up, down := plan(m.Migrations, applied, target)
+	if len(up) == 0 && len(down) == 0 {
+		m.Logf("Database schema is up to date at version %d", target)
+		return nil
+	}
+	for _, mig := range up {
+		m.Logf("Applying migration %s", mig.fileName("up"))
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(mig.Up).Error; err != nil {
+				return err
+			}
+			return tx.Exec("INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)",
+				mig.Version, mig.Name, mig.Checksum).Error
+		})
+		if err != nil {
+			return fmt.Errorf("apply migration %s: %w", mig.fileName("up"), err)
+		}
+	}
+	for _, mig := range down {
+		m.Logf("Reverting migration %s", mig.fileName("down"))
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(mig.Down).Error; err != nil {
+				return err
+			}
+			return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", mig.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("revert migration %s: %w", mig.fileName("down"), err)
+		}
+	}
+	m.Logf("Database schema migrated to version %d", target)
+	return nil
+}
 
-	// Read all SQL files from the embedded directory
-	entries, err := sqlFiles.ReadDir("sql")
-	if err != nil {
-		return fmt.Errorf("failed to read sql directory: %w", err)
+// plan returns the migrations to apply and to revert to reach target. Pending migrations
+// below the highest applied version, e.g. from a merged branch, are applied too.
+func plan(migrations []Migration, applied []appliedMigration, target int) (up, down []Migration) {
+	isApplied := make(map[int]bool, len(applied))
+	for _, a := range applied {
+		isApplied[a.Version] = true
+	}
+	for _, mig := range migrations {
+		if mig.Version <= target && !isApplied[mig.Version] {
+			up = append(up, mig)
+		}
 	}
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if mig := migrations[i]; mig.Version > target && isApplied[mig.Version] {
+			down = append(down, mig)
+		}
+	}
+	return up, down
+}
 
-	// Sort files alphabetically to ensure consistent execution order
-	var sqlFileNames []string
-	for _, entry := range entries {
-		if !entry.IsDir() && len(entry.Name()) > 4 && entry.Name()[len(entry.Name())-4:] == ".sql" {
-			sqlFileNames = append(sqlFileNames, entry.Name())
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.Migrations {
+		if m.Migrations[i].Version == version {
+			return &m.Migrations[i]
 		}
 	}
-	sort.Strings(sqlFileNames)
+	return nil
+}
 
-	// Execute each SQL file
-	for _, fileName := range sqlFileNames {
-		log.Printf("Executing migration: %s", fileName)
+// withLock runs fn on one connection holding the migration lock, with the applied
+// migrations read after the lock was taken
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB, applied []appliedMigration) error) error {
+	return m.DB.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", lockKey).Error; err != nil {
+				m.Logf("Failed to release migration lock: %v", err)
+			}
+		}()
 
-		content, err := sqlFiles.ReadFile("sql/" + fileName)
+		if err := conn.Exec(createMigrationsTable).Error; err != nil {
+			return fmt.Errorf("create schema_migrations: %w", err)
+		}
+		applied, err := m.applied(conn)
 		if err != nil {
-			return fmt.Errorf("failed to read migration file %s: %w", fileName, err)
+			return err
 		}
+		if len(applied) == 0 {
+			if applied, err = m.baseline(conn); err != nil {
+				return err
+			}
+		}
+		return fn(conn, applied)
+	})
+}
 
-		if err := db.Exec(string(content)).Error; err != nil {
-			return fmt.Errorf("failed to execute migration %s: %w", fileName, err)
+func (m *Migrator) applied(conn *gorm.DB) ([]appliedMigration, error) {
+	var applied []appliedMigration
+	err := conn.Raw("SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version").
+		Scan(&applied).Error
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	return applied, nil
+}
+
+// baseline records the migrations up to baselineVersion as applied on a database created
+// before versions were tracked. The later migrations are idempotent and simply run again.
+func (m *Migrator) baseline(conn *gorm.DB) ([]appliedMigration, error) {
+	var initialized bool
+	err := conn.Raw("SELECT EXISTS (SELECT FROM information_schema.tables WHERE table_schema = 'public' AND table_name = 'master_users')").
+		Scan(&initialized).Error
+	if err != nil {
+		return nil, fmt.Errorf("check for an existing schema: %w", err)
+	}
+	if !initialized {
+		return nil, nil
+	}
+
+	m.Logf("Existing schema without version history, recording it as version %d", baselineVersion)
+	for _, mig := range m.Migrations {
+		if mig.Version > baselineVersion {
+			break
+		}
+		if err := conn.Exec("INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)",
+			mig.Version, mig.Name, mig.Checksum).Error; err != nil {
+			return nil, fmt.Errorf("record baseline: %w", err)
 		}
+	}
+	return m.applied(conn)
+}
 
-		log.Printf("Migration %s completed successfully", fileName)
+// Check reports whether this build can serve the database: an error names a modified or
+// pending migration. Migrations applied by a newer build are logged but don't fail it, so
+// the old replicas keep serving during a rolling deploy. It only reads schema_migrations and
+// takes no lock, so readiness probes can call it while another replica migrates.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.applied(m.DB.WithContext(ctx))
+	if err != nil {
+		return err
 	}
+	return m.check(applied)
+}
 
-	log.Println("All migrations completed successfully")
+// check is Check on the migrations applied to the database
+func (m *Migrator) check(applied []appliedMigration) error {
+	var pending int
+	var unknown []string
+	for _, s := range m.status(applied) {
+		switch {
+		case s.Unknown:
+			unknown = append(unknown, fmt.Sprintf("%04d_%s", s.Version, s.Name))
+		case s.Modified:
+			return fmt.Errorf("migration %s was modified after it was applied", m.find(s.Version).fileName("up"))
+		case s.AppliedAt == nil:
+			pending++
+		}
+	}
+	if len(unknown) > 0 {
+		m.Logf("Migrations %s are applied but unknown to this build", strings.Join(unknown, ", "))
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations pending, latest is %d", pending, m.Latest())
+	}
 	return nil
 }
 
-// CheckDatabaseHealth verifies database connection and basic functionality
-func CheckDatabaseHealth(db *gorm.DB) error {
-	// Check database connection
+// CheckDatabaseHealth verifies the database answers within the deadline of ctx
+func CheckDatabaseHealth(ctx context.Context, db *gorm.DB) error {
 	sqlDB, err := db.DB()
 	if err != nil {
 		return fmt.Errorf("failed to get database connection: %w", err)
 	}
 
-	if err := sqlDB.Ping(); err != nil {
+	if err := sqlDB.PingContext(ctx); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
-
-	log.Println("Database health check passed")
 	return nil
 }
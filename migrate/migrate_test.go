@@ -0,0 +1,237 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Embedded()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, "initial_schema", migrations[0].Name)
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "versions must have no gaps")
+		// Each migration runs in a transaction of its own
+		for _, sql := range []string{m.Up, m.Down} {
+			for _, line := range strings.Split(sql, "\n") {
+				switch strings.TrimSpace(line) {
+				case "BEGIN;", "COMMIT;", "END;":
+					t.Errorf("migration %04d_%s controls its own transaction", m.Version, m.Name)
+				}
+			}
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_notes.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN notes text;")},
+		"0002_add_notes.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN notes;")},
+		"0001_create_t.up.sql":    {Data: []byte("CREATE TABLE t (id int);")},
+		"0001_create_t.down.sql":  {Data: []byte("DROP TABLE t;")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_t", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE t;", migrations[0].Down)
+	assert.Len(t, migrations[0].Checksum, 64)
+	assert.NotEqual(t, migrations[0].Checksum, migrations[1].Checksum)
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"missing down", fstest.MapFS{
+			"0001_a.up.sql": {Data: []byte("SELECT 1;")},
+		}, "0001_a.down.sql"},
+		{"empty up", fstest.MapFS{
+			"0001_a.up.sql":   {Data: []byte("  \n")},
+			"0001_a.down.sql": {Data: []byte("SELECT 1;")},
+		}, "0001_a.up.sql"},
+		{"two names", fstest.MapFS{
+			"0001_a.up.sql":   {Data: []byte("SELECT 1;")},
+			"0001_b.down.sql": {Data: []byte("SELECT 1;")},
+		}, "two names"},
+		{"bad file name", fstest.MapFS{
+			"schema.sql": {Data: []byte("SELECT 1;")},
+		}, "schema.sql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.files)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func versions(migrations []Migration) []int {
+	var out []int
+	for _, m := range migrations {
+		out = append(out, m.Version)
+	}
+	return out
+}
+
+func TestPlan(t *testing.T) {
+	migrations := []Migration{{Version: 1}, {Version: 2}, {Version: 3}, {Version: 4}}
+	applied := func(vs ...int) []appliedMigration {
+		var out []appliedMigration
+		for _, v := range vs {
+			out = append(out, appliedMigration{Version: v})
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		applied  []appliedMigration
+		target   int
+		wantUp   []int
+		wantDown []int
+	}{
+		{"fresh database", nil, 4, []int{1, 2, 3, 4}, nil},
+		{"up to date", applied(1, 2, 3, 4), 4, nil, nil},
+		{"partial", applied(1, 2), 3, []int{3}, nil},
+		{"pending below the newest applied", applied(1, 2, 4), 4, []int{3}, nil},
+		{"down", applied(1, 2, 3, 4), 2, nil, []int{4, 3}},
+		{"down to nothing", applied(1, 2), 0, nil, []int{2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up, down := plan(migrations, tt.applied, tt.target)
+			assert.Equal(t, tt.wantUp, versions(up))
+			assert.Equal(t, tt.wantDown, versions(down))
+		})
+	}
+}
+
+func TestVerifyAndStatus(t *testing.T) {
+	m := &Migrator{Migrations: []Migration{
+		{Version: 1, Name: "a", Checksum: "aaa"},
+		{Version: 2, Name: "b", Checksum: "bbb"},
+	}}
+	now := time.Now()
+
+	assert.NoError(t, m.verify([]appliedMigration{{Version: 1, Checksum: "aaa"}}))
+
+	modified := []appliedMigration{{Version: 1, Name: "a", Checksum: "changed", AppliedAt: now}}
+	assert.ErrorContains(t, m.verify(modified), "modified")
+	statuses := m.status(modified)
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Modified)
+	assert.Nil(t, statuses[1].AppliedAt)
+
+	unknown := []appliedMigration{{Version: 3, Name: "c", Checksum: "ccc", AppliedAt: now}}
+	assert.ErrorContains(t, m.verify(unknown), "unknown")
+	statuses = m.status(unknown)
+	require.Len(t, statuses, 3)
+	assert.True(t, statuses[2].Unknown)
+}
+
+func TestCheck(t *testing.T) {
+	m := &Migrator{Migrations: []Migration{
+		{Version: 1, Name: "a", Checksum: "aaa"},
+		{Version: 2, Name: "b", Checksum: "bbb"},
+	}, Logf: t.Logf}
+	now := time.Now()
+	current := []appliedMigration{
+		{Version: 1, Name: "a", Checksum: "aaa", AppliedAt: now},
+		{Version: 2, Name: "b", Checksum: "bbb", AppliedAt: now},
+	}
+
+	assert.NoError(t, m.check(current))
+	assert.NoError(t, m.check(append(current, appliedMigration{Version: 3, Name: "c", Checksum: "ccc", AppliedAt: now})),
+		"a migration applied by a newer build must not make this one unready")
+	assert.ErrorContains(t, m.check(current[:1]), "1 migrations pending")
+	modified := []appliedMigration{current[0], {Version: 2, Name: "b", Checksum: "changed", AppliedAt: now}}
+	assert.ErrorContains(t, m.check(modified), "modified")
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0007_existing.up.sql"), []byte("SELECT 1;"), 0o644))
+
+	up, down, err := Create(dir, "Add Order-Notes")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0008_add_order_notes.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "0008_add_order_notes.down.sql"), down)
+	assert.FileExists(t, down)
+
+	_, _, err = Create(dir, "drop; table")
+	assert.Error(t, err)
+}
+
+// TestUpAndDown applies every migration to an empty database, reverts them all and applies
+// them again. It needs a PostgreSQL server: TEST_DATABASE_URL is a postgres:// URL of a user
+// allowed to create databases, the test works in a scratch database of its own.
+func TestUpAndDown(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	ctx := context.Background()
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("adong_migrate_test_%d", time.Now().UnixNano())
+	require.NoError(t, admin.Exec("CREATE DATABASE "+name).Error)
+	t.Cleanup(func() {
+		if err := admin.Exec("DROP DATABASE IF EXISTS " + name + " WITH (FORCE)").Error; err != nil {
+			t.Logf("drop %s: %v", name, err)
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	u.Path = "/" + name
+	db, err := gorm.Open(postgres.Open(u.String()), &gorm.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	m, err := New(db)
+	require.NoError(t, err)
+	m.Logf = t.Logf
+
+	tables := func() []string {
+		var names []string
+		require.NoError(t, db.Raw("SELECT table_name FROM information_schema.tables WHERE table_schema = 'public' AND table_name <> 'schema_migrations' ORDER BY table_name").
+			Scan(&names).Error)
+		return names
+	}
+
+	for round := 1; round <= 2; round++ {
+		require.NoError(t, m.Up(ctx), "up, round %d", round)
+		require.NoError(t, m.Check(ctx))
+		assert.NotEmpty(t, tables())
+
+		require.NoError(t, m.To(ctx, 0), "down, round %d", round)
+		assert.Empty(t, tables(), "down migrations leave tables behind")
+		statuses, err := m.Status(ctx)
+		require.NoError(t, err)
+		for _, s := range statuses {
+			assert.Nil(t, s.AppliedAt, "%04d_%s still applied", s.Version, s.Name)
+		}
+	}
+}
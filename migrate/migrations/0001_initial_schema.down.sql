@@ -0,0 +1,26 @@
+-- Drops the initial schema together with everything that depends on it.
+
+DROP TABLE IF EXISTS public.auth_token_pairs CASCADE;
+DROP TABLE IF EXISTS public.auth_user_sessions CASCADE;
+DROP TABLE IF EXISTS public.dish_recipe_standards CASCADE;
+DROP TABLE IF EXISTS public.inventory_adjustment_details CASCADE;
+DROP TABLE IF EXISTS public.inventory_adjustments CASCADE;
+DROP TABLE IF EXISTS public.inventory_export_details CASCADE;
+DROP TABLE IF EXISTS public.inventory_exports CASCADE;
+DROP TABLE IF EXISTS public.inventory_import_details CASCADE;
+DROP TABLE IF EXISTS public.inventory_imports CASCADE;
+DROP TABLE IF EXISTS public.inventory_stocks CASCADE;
+DROP TABLE IF EXISTS public.inventory_transactions CASCADE;
+DROP TABLE IF EXISTS public.kitchen_favorite_suppliers CASCADE;
+DROP TABLE IF EXISTS public.order_details CASCADE;
+DROP TABLE IF EXISTS public.order_ingredient_suppliers CASCADE;
+DROP TABLE IF EXISTS public.order_ingredients CASCADE;
+DROP TABLE IF EXISTS public.order_supplementary_foods CASCADE;
+DROP TABLE IF EXISTS public.orders CASCADE;
+DROP TABLE IF EXISTS public.supplier_price_list CASCADE;
+DROP TABLE IF EXISTS public.master_dishes CASCADE;
+DROP TABLE IF EXISTS public.master_ingredients CASCADE;
+DROP TABLE IF EXISTS public.ingredient_types CASCADE;
+DROP TABLE IF EXISTS public.master_suppliers CASCADE;
+DROP TABLE IF EXISTS public.master_kitchens CASCADE;
+DROP TABLE IF EXISTS public.master_users CASCADE;
@@ -1,7 +1,5 @@
 -- This script was generated by the ERD tool in pgAdmin 4.
 -- Please log an issue at https://github.com/pgadmin-org/pgadmin4/issues/new/choose if you find any bugs, including reproduction steps.
-BEGIN;
-
 
 CREATE TABLE IF NOT EXISTS public.auth_token_pairs
 (
@@ -298,7 +296,7 @@ COMMENT ON COLUMN public.master_users.password
     IS 'Hashed password using bcrypt';
 
 COMMENT ON COLUMN public.master_users.plain_password
-    IS 'Plain text password (optional, for debugging/recovery purposes)';
+    IS 'Deprecated: legacy plain text password, wiped on next login and after the migration window';
 
 CREATE TABLE IF NOT EXISTS public.order_details
 (
@@ -403,7 +401,6 @@ ALTER TABLE IF EXISTS public.auth_token_pairs
 CREATE INDEX IF NOT EXISTS idx_token_user
     ON public.auth_token_pairs(user_id);
 
-
 ALTER TABLE IF EXISTS public.auth_user_sessions
     ADD CONSTRAINT fk_session_user FOREIGN KEY (user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
@@ -412,7 +409,6 @@ ALTER TABLE IF EXISTS public.auth_user_sessions
 CREATE INDEX IF NOT EXISTS idx_session_user
     ON public.auth_user_sessions(user_id);
 
-
 ALTER TABLE IF EXISTS public.dish_recipe_standards
     ADD CONSTRAINT fk_recipe_dish FOREIGN KEY (dish_id)
     REFERENCES public.master_dishes (dish_id) MATCH SIMPLE
@@ -421,7 +417,6 @@ ALTER TABLE IF EXISTS public.dish_recipe_standards
 CREATE INDEX IF NOT EXISTS idx_recipe_dish
     ON public.dish_recipe_standards(dish_id);
 
-
 ALTER TABLE IF EXISTS public.dish_recipe_standards
     ADD CONSTRAINT fk_recipe_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -430,7 +425,6 @@ ALTER TABLE IF EXISTS public.dish_recipe_standards
 CREATE INDEX IF NOT EXISTS idx_recipe_ingredient
     ON public.dish_recipe_standards(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.dish_recipe_standards
     ADD CONSTRAINT fk_recipe_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -439,14 +433,12 @@ ALTER TABLE IF EXISTS public.dish_recipe_standards
 CREATE INDEX IF NOT EXISTS idx_recipe_kitchen
     ON public.dish_recipe_standards(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.dish_recipe_standards
     ADD CONSTRAINT fk_recipe_user FOREIGN KEY (updated_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_adjustment_details
     ADD CONSTRAINT fk_adjustment_detail_adjustment FOREIGN KEY (adjustment_id)
     REFERENCES public.inventory_adjustments (adjustment_id) MATCH SIMPLE
@@ -455,7 +447,6 @@ ALTER TABLE IF EXISTS public.inventory_adjustment_details
 CREATE INDEX IF NOT EXISTS idx_adjustment_details_adjustment
     ON public.inventory_adjustment_details(adjustment_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_adjustment_details
     ADD CONSTRAINT fk_adjustment_detail_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -464,21 +455,18 @@ ALTER TABLE IF EXISTS public.inventory_adjustment_details
 CREATE INDEX IF NOT EXISTS idx_adjustment_details_ingredient
     ON public.inventory_adjustment_details(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_adjustments
     ADD CONSTRAINT fk_adjustment_approved_by FOREIGN KEY (approved_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_adjustments
     ADD CONSTRAINT fk_adjustment_created_by FOREIGN KEY (created_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_adjustments
     ADD CONSTRAINT fk_adjustment_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -487,7 +475,6 @@ ALTER TABLE IF EXISTS public.inventory_adjustments
 CREATE INDEX IF NOT EXISTS idx_adjustments_kitchen
     ON public.inventory_adjustments(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_export_details
     ADD CONSTRAINT fk_export_detail_export FOREIGN KEY (export_id)
     REFERENCES public.inventory_exports (export_id) MATCH SIMPLE
@@ -496,7 +483,6 @@ ALTER TABLE IF EXISTS public.inventory_export_details
 CREATE INDEX IF NOT EXISTS idx_export_details_export
     ON public.inventory_export_details(export_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_export_details
     ADD CONSTRAINT fk_export_detail_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -505,35 +491,30 @@ ALTER TABLE IF EXISTS public.inventory_export_details
 CREATE INDEX IF NOT EXISTS idx_export_details_ingredient
     ON public.inventory_export_details(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_exports
     ADD CONSTRAINT fk_export_approved_by FOREIGN KEY (approved_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_exports
     ADD CONSTRAINT fk_export_created_by FOREIGN KEY (created_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_exports
     ADD CONSTRAINT fk_export_destination FOREIGN KEY (destination_kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE RESTRICT;
 
-
 ALTER TABLE IF EXISTS public.inventory_exports
     ADD CONSTRAINT fk_export_issued_by FOREIGN KEY (issued_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_exports
     ADD CONSTRAINT fk_export_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -542,7 +523,6 @@ ALTER TABLE IF EXISTS public.inventory_exports
 CREATE INDEX IF NOT EXISTS idx_exports_kitchen
     ON public.inventory_exports(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_exports
     ADD CONSTRAINT fk_export_order FOREIGN KEY (order_id)
     REFERENCES public.orders (order_id) MATCH SIMPLE
@@ -551,7 +531,6 @@ ALTER TABLE IF EXISTS public.inventory_exports
 CREATE INDEX IF NOT EXISTS idx_exports_order
     ON public.inventory_exports(order_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_import_details
     ADD CONSTRAINT fk_import_detail_import FOREIGN KEY (import_id)
     REFERENCES public.inventory_imports (import_id) MATCH SIMPLE
@@ -560,7 +539,6 @@ ALTER TABLE IF EXISTS public.inventory_import_details
 CREATE INDEX IF NOT EXISTS idx_import_details_import
     ON public.inventory_import_details(import_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_import_details
     ADD CONSTRAINT fk_import_detail_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -569,7 +547,6 @@ ALTER TABLE IF EXISTS public.inventory_import_details
 CREATE INDEX IF NOT EXISTS idx_import_details_ingredient
     ON public.inventory_import_details(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_import_details
     ADD CONSTRAINT fk_import_detail_supplier FOREIGN KEY (supplier_id)
     REFERENCES public.master_suppliers (supplier_id) MATCH SIMPLE
@@ -578,21 +555,18 @@ ALTER TABLE IF EXISTS public.inventory_import_details
 CREATE INDEX IF NOT EXISTS idx_import_details_supplier
     ON public.inventory_import_details(supplier_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_imports
     ADD CONSTRAINT fk_import_approved_by FOREIGN KEY (approved_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_imports
     ADD CONSTRAINT fk_import_created_by FOREIGN KEY (created_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_imports
     ADD CONSTRAINT fk_import_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -601,7 +575,6 @@ ALTER TABLE IF EXISTS public.inventory_imports
 CREATE INDEX IF NOT EXISTS idx_imports_kitchen
     ON public.inventory_imports(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_imports
     ADD CONSTRAINT fk_import_order FOREIGN KEY (order_id)
     REFERENCES public.orders (order_id) MATCH SIMPLE
@@ -610,14 +583,12 @@ ALTER TABLE IF EXISTS public.inventory_imports
 CREATE INDEX IF NOT EXISTS idx_imports_order
     ON public.inventory_imports(order_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_imports
     ADD CONSTRAINT fk_import_received_by FOREIGN KEY (received_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_imports
     ADD CONSTRAINT fk_import_supplier FOREIGN KEY (supplier_id)
     REFERENCES public.master_suppliers (supplier_id) MATCH SIMPLE
@@ -626,7 +597,6 @@ ALTER TABLE IF EXISTS public.inventory_imports
 CREATE INDEX IF NOT EXISTS idx_imports_supplier
     ON public.inventory_imports(supplier_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_stocks
     ADD CONSTRAINT fk_stock_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -635,7 +605,6 @@ ALTER TABLE IF EXISTS public.inventory_stocks
 CREATE INDEX IF NOT EXISTS idx_stocks_ingredient
     ON public.inventory_stocks(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_stocks
     ADD CONSTRAINT fk_stock_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -644,14 +613,12 @@ ALTER TABLE IF EXISTS public.inventory_stocks
 CREATE INDEX IF NOT EXISTS idx_stocks_kitchen
     ON public.inventory_stocks(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_transactions
     ADD CONSTRAINT fk_transaction_created_by FOREIGN KEY (created_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.inventory_transactions
     ADD CONSTRAINT fk_transaction_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -660,7 +627,6 @@ ALTER TABLE IF EXISTS public.inventory_transactions
 CREATE INDEX IF NOT EXISTS idx_transactions_ingredient
     ON public.inventory_transactions(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.inventory_transactions
     ADD CONSTRAINT fk_transaction_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -669,7 +635,6 @@ ALTER TABLE IF EXISTS public.inventory_transactions
 CREATE INDEX IF NOT EXISTS idx_transactions_kitchen
     ON public.inventory_transactions(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.kitchen_favorite_suppliers
     ADD CONSTRAINT fk_favorite_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -678,7 +643,6 @@ ALTER TABLE IF EXISTS public.kitchen_favorite_suppliers
 CREATE INDEX IF NOT EXISTS idx_favorite_kitchen
     ON public.kitchen_favorite_suppliers(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.kitchen_favorite_suppliers
     ADD CONSTRAINT fk_favorite_supplier FOREIGN KEY (supplier_id)
     REFERENCES public.master_suppliers (supplier_id) MATCH SIMPLE
@@ -687,28 +651,24 @@ ALTER TABLE IF EXISTS public.kitchen_favorite_suppliers
 CREATE INDEX IF NOT EXISTS idx_favorite_supplier
     ON public.kitchen_favorite_suppliers(supplier_id);
 
-
 ALTER TABLE IF EXISTS public.kitchen_favorite_suppliers
     ADD CONSTRAINT fk_favorite_user FOREIGN KEY (created_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.master_ingredients
     ADD CONSTRAINT fk_ingredient_type FOREIGN KEY (ingredient_type_id)
     REFERENCES public.ingredient_types (ingredient_type_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.master_users
     ADD CONSTRAINT fk_users_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.order_details
     ADD CONSTRAINT fk_detail_dish FOREIGN KEY (dish_id)
     REFERENCES public.master_dishes (dish_id) MATCH SIMPLE
@@ -717,7 +677,6 @@ ALTER TABLE IF EXISTS public.order_details
 CREATE INDEX IF NOT EXISTS idx_order_details_dish
     ON public.order_details(dish_id);
 
-
 ALTER TABLE IF EXISTS public.order_details
     ADD CONSTRAINT fk_detail_order FOREIGN KEY (order_id)
     REFERENCES public.orders (order_id) MATCH SIMPLE
@@ -726,7 +685,6 @@ ALTER TABLE IF EXISTS public.order_details
 CREATE INDEX IF NOT EXISTS idx_order_details_order
     ON public.order_details(order_id);
 
-
 ALTER TABLE IF EXISTS public.order_ingredient_suppliers
     ADD CONSTRAINT fk_ois_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -735,7 +693,6 @@ ALTER TABLE IF EXISTS public.order_ingredient_suppliers
 CREATE INDEX IF NOT EXISTS idx_ois_ingredient
     ON public.order_ingredient_suppliers(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.order_ingredient_suppliers
     ADD CONSTRAINT fk_ois_order FOREIGN KEY (order_id)
     REFERENCES public.orders (order_id) MATCH SIMPLE
@@ -744,7 +701,6 @@ ALTER TABLE IF EXISTS public.order_ingredient_suppliers
 CREATE INDEX IF NOT EXISTS idx_ois_order
     ON public.order_ingredient_suppliers(order_id);
 
-
 ALTER TABLE IF EXISTS public.order_ingredient_suppliers
     ADD CONSTRAINT fk_ois_product FOREIGN KEY (selected_product_id)
     REFERENCES public.supplier_price_list (product_id) MATCH SIMPLE
@@ -753,7 +709,6 @@ ALTER TABLE IF EXISTS public.order_ingredient_suppliers
 CREATE INDEX IF NOT EXISTS idx_ois_product
     ON public.order_ingredient_suppliers(selected_product_id);
 
-
 ALTER TABLE IF EXISTS public.order_ingredient_suppliers
     ADD CONSTRAINT fk_ois_supplier FOREIGN KEY (selected_supplier_id)
     REFERENCES public.master_suppliers (supplier_id) MATCH SIMPLE
@@ -762,14 +717,12 @@ ALTER TABLE IF EXISTS public.order_ingredient_suppliers
 CREATE INDEX IF NOT EXISTS idx_ois_supplier
     ON public.order_ingredient_suppliers(selected_supplier_id);
 
-
 ALTER TABLE IF EXISTS public.order_ingredient_suppliers
     ADD CONSTRAINT fk_ois_user FOREIGN KEY (selected_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.order_ingredients
     ADD CONSTRAINT fk_order_ing_detail FOREIGN KEY (order_detail_id)
     REFERENCES public.order_details (order_detail_id) MATCH SIMPLE
@@ -778,7 +731,6 @@ ALTER TABLE IF EXISTS public.order_ingredients
 CREATE INDEX IF NOT EXISTS idx_order_ing_detail
     ON public.order_ingredients(order_detail_id);
 
-
 ALTER TABLE IF EXISTS public.order_ingredients
     ADD CONSTRAINT fk_order_ing_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -787,7 +739,6 @@ ALTER TABLE IF EXISTS public.order_ingredients
 CREATE INDEX IF NOT EXISTS idx_order_ing_ingredient
     ON public.order_ingredients(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.order_supplementary_foods
     ADD CONSTRAINT fk_supp_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -796,7 +747,6 @@ ALTER TABLE IF EXISTS public.order_supplementary_foods
 CREATE INDEX IF NOT EXISTS idx_supplementary_ingredient
     ON public.order_supplementary_foods(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.order_supplementary_foods
     ADD CONSTRAINT fk_supp_order FOREIGN KEY (order_id)
     REFERENCES public.orders (order_id) MATCH SIMPLE
@@ -805,7 +755,6 @@ ALTER TABLE IF EXISTS public.order_supplementary_foods
 CREATE INDEX IF NOT EXISTS idx_supplementary_order
     ON public.order_supplementary_foods(order_id);
 
-
 ALTER TABLE IF EXISTS public.orders
     ADD CONSTRAINT fk_order_kitchen FOREIGN KEY (kitchen_id)
     REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
@@ -814,14 +763,12 @@ ALTER TABLE IF EXISTS public.orders
 CREATE INDEX IF NOT EXISTS idx_orders_kitchen
     ON public.orders(kitchen_id);
 
-
 ALTER TABLE IF EXISTS public.orders
     ADD CONSTRAINT fk_order_user FOREIGN KEY (created_by_user_id)
     REFERENCES public.master_users (user_id) MATCH SIMPLE
     ON UPDATE CASCADE
     ON DELETE SET NULL;
 
-
 ALTER TABLE IF EXISTS public.supplier_price_list
     ADD CONSTRAINT fk_price_ingredient FOREIGN KEY (ingredient_id)
     REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
@@ -830,7 +777,6 @@ ALTER TABLE IF EXISTS public.supplier_price_list
 CREATE INDEX IF NOT EXISTS idx_supplier_price_ingredient
     ON public.supplier_price_list(ingredient_id);
 
-
 ALTER TABLE IF EXISTS public.supplier_price_list
     ADD CONSTRAINT fk_price_supplier FOREIGN KEY (supplier_id)
     REFERENCES public.master_suppliers (supplier_id) MATCH SIMPLE
@@ -839,7 +785,6 @@ ALTER TABLE IF EXISTS public.supplier_price_list
 CREATE INDEX IF NOT EXISTS idx_supplier_price_supplier
     ON public.supplier_price_list(supplier_id);
 
-
 -- Create indexes for legacy_id columns in master data tables
 CREATE INDEX IF NOT EXISTS idx_master_dishes_legacy_id
     ON public.master_dishes(legacy_id);
@@ -855,5 +800,3 @@ CREATE INDEX IF NOT EXISTS idx_master_suppliers_legacy_id
 
 CREATE INDEX IF NOT EXISTS idx_master_users_legacy_id
     ON public.master_users(legacy_id);
-
-END;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Removes the default admin user.
+
+DELETE FROM master_users WHERE user_id = '1' AND user_name = 'admin';
@@ -4,8 +4,6 @@
 -- Email: admin@adong.com
 -- Role: Admin
 
-BEGIN;
-
 -- Clear all existing users and related data
 DELETE FROM auth_user_sessions;
 DELETE FROM auth_token_pairs;
@@ -16,7 +14,6 @@ INSERT INTO master_users (
     user_id,
     user_name,
     password,
-    plain_password,
     full_name,
     role,
     email,
@@ -28,7 +25,6 @@ INSERT INTO master_users (
     '1',  -- Simple admin user ID
     'admin',
     '$2a$10$K0EKWc0uOdtRnUY2jpcEUe4mBZgAeRYgQliXuEplk48x43YmwTTiu',  -- bcrypt hash of 'admin@adong'
-    'admin@adong',  -- Store plain password for fallback
     'Administrator',
     'Admin',
     'admin@adong.com',
@@ -37,5 +33,3 @@ INSERT INTO master_users (
     CURRENT_TIMESTAMP,
     CURRENT_TIMESTAMP
 );
-
-COMMIT;
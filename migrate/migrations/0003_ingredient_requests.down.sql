@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.ingredient_request_details;
+DROP TABLE IF EXISTS public.ingredient_requests;
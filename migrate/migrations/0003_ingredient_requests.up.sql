@@ -0,0 +1,66 @@
+-- Ingredient purchase requests
+-- Requests created from an order, one detail per ingredient with the supplier chosen
+-- for it. These tables used to be created outside the migrations, so the statements
+-- leave an existing table untouched.
+
+CREATE TABLE IF NOT EXISTS public.ingredient_requests
+(
+    request_id character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    order_id character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    kitchen_id character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    request_date date NOT NULL,
+    required_date date,
+    status character varying(50) COLLATE pg_catalog."default" NOT NULL DEFAULT 'pending'::character varying,
+    total_amount numeric(15, 2) DEFAULT 0,
+    notes text COLLATE pg_catalog."default",
+    created_by_user_id character varying(50) COLLATE pg_catalog."default",
+    approved_by_user_id character varying(50) COLLATE pg_catalog."default",
+    approved_date timestamp without time zone,
+    created_date timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ingredient_requests_pkey PRIMARY KEY (request_id),
+    CONSTRAINT fk_ingredient_request_order FOREIGN KEY (order_id)
+        REFERENCES public.orders (order_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT fk_ingredient_request_kitchen FOREIGN KEY (kitchen_id)
+        REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingredient_requests_order
+    ON public.ingredient_requests(order_id);
+CREATE INDEX IF NOT EXISTS idx_ingredient_requests_kitchen
+    ON public.ingredient_requests(kitchen_id);
+
+CREATE TABLE IF NOT EXISTS public.ingredient_request_details
+(
+    request_detail_id integer NOT NULL GENERATED ALWAYS AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 2147483647 CACHE 1 ),
+    request_id character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    ingredient_id character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    quantity numeric(15, 4) NOT NULL,
+    unit character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    supplier_id character varying(50) COLLATE pg_catalog."default",
+    unit_price numeric(15, 2),
+    total_price numeric(15, 2),
+    notes text COLLATE pg_catalog."default",
+    created_date timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ingredient_request_details_pkey PRIMARY KEY (request_detail_id),
+    CONSTRAINT fk_ingredient_request_detail_request FOREIGN KEY (request_id)
+        REFERENCES public.ingredient_requests (request_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT fk_ingredient_request_detail_ingredient FOREIGN KEY (ingredient_id)
+        REFERENCES public.master_ingredients (ingredient_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE RESTRICT,
+    CONSTRAINT fk_ingredient_request_detail_supplier FOREIGN KEY (supplier_id)
+        REFERENCES public.master_suppliers (supplier_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingredient_request_details_request
+    ON public.ingredient_request_details(request_id);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.supplier_selection_rules;
@@ -0,0 +1,55 @@
+-- Supplier selection rules
+-- Replaces the hardcoded ingredient type / material group lists that used to live
+-- in handler.shouldUseFavoriteStrategy. A rule with NULL kitchen_id applies to every
+-- kitchen; a rule with neither ingredient_type_id nor material_group is a default.
+
+CREATE TABLE IF NOT EXISTS public.supplier_selection_rules
+(
+    rule_id integer NOT NULL GENERATED ALWAYS AS IDENTITY ( INCREMENT 1 START 1 MINVALUE 1 MAXVALUE 2147483647 CACHE 1 ),
+    kitchen_id character varying(50) COLLATE pg_catalog."default",
+    ingredient_type_id character varying(50) COLLATE pg_catalog."default",
+    material_group character varying(255) COLLATE pg_catalog."default",
+    strategy character varying(50) COLLATE pg_catalog."default" NOT NULL,
+    priority integer NOT NULL DEFAULT 0,
+    price_weight numeric(6, 3) NOT NULL DEFAULT 0.6,
+    favorite_weight numeric(6, 3) NOT NULL DEFAULT 0.3,
+    coverage_weight numeric(6, 3) NOT NULL DEFAULT 0.1,
+    active boolean NOT NULL DEFAULT true,
+    notes text COLLATE pg_catalog."default",
+    updated_by_user_id character varying(50) COLLATE pg_catalog."default",
+    created_date timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT supplier_selection_rules_pkey PRIMARY KEY (rule_id),
+    CONSTRAINT fk_selection_rule_kitchen FOREIGN KEY (kitchen_id)
+        REFERENCES public.master_kitchens (kitchen_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE,
+    CONSTRAINT fk_selection_rule_type FOREIGN KEY (ingredient_type_id)
+        REFERENCES public.ingredient_types (ingredient_type_id) MATCH SIMPLE
+        ON UPDATE CASCADE
+        ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_selection_rule_kitchen
+    ON public.supplier_selection_rules(kitchen_id);
+
+-- Seed the rules that used to be hardcoded. Seeding only happens while the table
+-- is empty so that admin edits are never overwritten on restart.
+-- The default rule is cheapest, as FindBestSuppliers used outside the favorite lists. This
+-- changes GetBestSuppliersForOrder, which preferred favorites for every ingredient: other
+-- material groups now get the lowest price unless an admin adds a favorite_first rule.
+INSERT INTO public.supplier_selection_rules (ingredient_type_id, material_group, strategy, priority, notes)
+SELECT seed.ingredient_type_id, seed.material_group, seed.strategy, seed.priority, seed.notes
+FROM (
+    SELECT it.ingredient_type_id, NULL::varchar AS material_group, 'favorite_first' AS strategy,
+           0 AS priority, 'Seeded from legacy favorite ingredient types' AS notes
+    FROM public.ingredient_types it
+    WHERE it.ingredient_type_name IN ('VEGETABLE', 'MEAT', 'DAIRY', 'GRAIN')
+    UNION ALL
+    SELECT NULL, g.material_group, 'favorite_first', 0, 'Seeded from legacy favorite material groups'
+    FROM (VALUES ('Thịt heo'), ('Thịt bò'), ('Thịt gia cầm'), ('Trứng'), ('Gạo'),
+                 ('Bún phở'), ('Củ quả'), ('Rau xanh'), ('Củ')) AS g(material_group)
+    UNION ALL
+    SELECT NULL, NULL, 'cheapest', 0, 'Default rule: lowest unit price'
+) seed
+WHERE NOT EXISTS (SELECT 1 FROM public.supplier_selection_rules);
@@ -0,0 +1,2 @@
+ALTER TABLE master_suppliers DROP COLUMN IF EXISTS min_order_value;
+ALTER TABLE master_suppliers DROP COLUMN IF EXISTS delivery_fee;
@@ -0,0 +1,8 @@
+-- Delivery terms used by basket consolidation: a supplier may require a minimum
+-- order value and charge a flat delivery fee per order.
+
+ALTER TABLE master_suppliers ADD COLUMN IF NOT EXISTS min_order_value numeric(15, 2) NOT NULL DEFAULT 0;
+ALTER TABLE master_suppliers ADD COLUMN IF NOT EXISTS delivery_fee numeric(15, 2) NOT NULL DEFAULT 0;
+
+COMMENT ON COLUMN master_suppliers.min_order_value IS 'Giá trị đơn hàng tối thiểu';
+COMMENT ON COLUMN master_suppliers.delivery_fee IS 'Phí giao hàng mỗi đơn';
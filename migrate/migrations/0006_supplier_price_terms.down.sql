@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.supplier_promotions;
+DROP TABLE IF EXISTS public.supplier_contract_prices;
+DROP TABLE IF EXISTS public.supplier_price_tiers;
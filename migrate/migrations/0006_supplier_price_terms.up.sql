@@ -0,0 +1,74 @@
+-- Pricing terms layered on top of supplier_price_list.unit_price:
+--   * quantity tiers (price breaks by order quantity)
+--   * kitchen contract prices (negotiated price for one kitchen, optionally time-boxed)
+--   * time-boxed promotions (percentage off or fixed price, optionally per kitchen / above a quantity)
+
+CREATE TABLE IF NOT EXISTS public.supplier_price_tiers
+(
+    tier_id integer NOT NULL GENERATED ALWAYS AS IDENTITY,
+    product_id integer NOT NULL,
+    min_quantity numeric(15, 4) NOT NULL,
+    unit_price numeric(15, 2),
+    discount_percent numeric(5, 2),
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT supplier_price_tiers_pkey PRIMARY KEY (tier_id),
+    CONSTRAINT supplier_price_tiers_product_fkey FOREIGN KEY (product_id)
+        REFERENCES public.supplier_price_list (product_id) ON DELETE CASCADE,
+    CONSTRAINT supplier_price_tiers_unique UNIQUE (product_id, min_quantity),
+    CONSTRAINT supplier_price_tiers_value_check CHECK (unit_price IS NOT NULL OR discount_percent IS NOT NULL)
+);
+
+COMMENT ON TABLE public.supplier_price_tiers IS 'Bậc giá theo số lượng';
+
+CREATE TABLE IF NOT EXISTS public.supplier_contract_prices
+(
+    contract_id integer NOT NULL GENERATED ALWAYS AS IDENTITY,
+    product_id integer NOT NULL,
+    kitchen_id character varying(50) NOT NULL,
+    unit_price numeric(15, 2) NOT NULL,
+    effective_from timestamp without time zone,
+    effective_to timestamp without time zone,
+    active boolean DEFAULT true,
+    notes text,
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT supplier_contract_prices_pkey PRIMARY KEY (contract_id),
+    CONSTRAINT supplier_contract_prices_product_fkey FOREIGN KEY (product_id)
+        REFERENCES public.supplier_price_list (product_id) ON DELETE CASCADE,
+    CONSTRAINT supplier_contract_prices_kitchen_fkey FOREIGN KEY (kitchen_id)
+        REFERENCES public.master_kitchens (kitchen_id) ON DELETE CASCADE
+);
+
+COMMENT ON TABLE public.supplier_contract_prices IS 'Giá hợp đồng theo bếp';
+
+CREATE INDEX IF NOT EXISTS idx_contract_price_product_kitchen
+    ON public.supplier_contract_prices(product_id, kitchen_id);
+
+CREATE TABLE IF NOT EXISTS public.supplier_promotions
+(
+    promotion_id integer NOT NULL GENERATED ALWAYS AS IDENTITY,
+    product_id integer NOT NULL,
+    kitchen_id character varying(50),
+    name character varying(255),
+    discount_percent numeric(5, 2),
+    promo_price numeric(15, 2),
+    min_quantity numeric(15, 4) NOT NULL DEFAULT 0,
+    starts_at timestamp without time zone NOT NULL,
+    ends_at timestamp without time zone NOT NULL,
+    active boolean DEFAULT true,
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT supplier_promotions_pkey PRIMARY KEY (promotion_id),
+    CONSTRAINT supplier_promotions_product_fkey FOREIGN KEY (product_id)
+        REFERENCES public.supplier_price_list (product_id) ON DELETE CASCADE,
+    CONSTRAINT supplier_promotions_kitchen_fkey FOREIGN KEY (kitchen_id)
+        REFERENCES public.master_kitchens (kitchen_id) ON DELETE CASCADE,
+    CONSTRAINT supplier_promotions_value_check CHECK (promo_price IS NOT NULL OR discount_percent IS NOT NULL),
+    CONSTRAINT supplier_promotions_window_check CHECK (ends_at > starts_at)
+);
+
+COMMENT ON TABLE public.supplier_promotions IS 'Khuyến mãi có thời hạn';
+
+CREATE INDEX IF NOT EXISTS idx_promotion_product_window
+    ON public.supplier_promotions(product_id, starts_at, ends_at);
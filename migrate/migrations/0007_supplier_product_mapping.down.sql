@@ -0,0 +1,8 @@
+-- Fails while products without an ingredient remain; map or delete them first.
+
+DROP TABLE IF EXISTS public.supplier_product_mapping_rejections;
+DROP TABLE IF EXISTS public.supplier_product_ingredients;
+
+DROP INDEX IF EXISTS public.idx_supplier_price_mapping_status;
+ALTER TABLE supplier_price_list DROP COLUMN IF EXISTS mapping_status;
+ALTER TABLE supplier_price_list ALTER COLUMN ingredient_id SET NOT NULL;
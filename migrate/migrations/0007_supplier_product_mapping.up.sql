@@ -0,0 +1,41 @@
+-- Supplier product onboarding: products may arrive without an ingredient, wait in a
+-- mapping queue until confirmed, and may be mapped to several equivalent ingredients.
+
+ALTER TABLE supplier_price_list ALTER COLUMN ingredient_id DROP NOT NULL;
+ALTER TABLE supplier_price_list ADD COLUMN IF NOT EXISTS mapping_status character varying(20) NOT NULL DEFAULT 'confirmed';
+
+COMMENT ON COLUMN supplier_price_list.mapping_status IS 'unmapped | pending | confirmed';
+
+CREATE INDEX IF NOT EXISTS idx_supplier_price_mapping_status
+    ON public.supplier_price_list(mapping_status);
+
+-- Additional equivalent ingredients served by one product (the primary one stays in supplier_price_list.ingredient_id)
+CREATE TABLE IF NOT EXISTS public.supplier_product_ingredients
+(
+    product_id integer NOT NULL,
+    ingredient_id character varying(50) NOT NULL,
+    mapped_by_user_id character varying(50),
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT supplier_product_ingredients_pkey PRIMARY KEY (product_id, ingredient_id),
+    CONSTRAINT supplier_product_ingredients_product_fkey FOREIGN KEY (product_id)
+        REFERENCES public.supplier_price_list (product_id) ON DELETE CASCADE,
+    CONSTRAINT supplier_product_ingredients_ingredient_fkey FOREIGN KEY (ingredient_id)
+        REFERENCES public.master_ingredients (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_supplier_product_ingredients_ingredient
+    ON public.supplier_product_ingredients(ingredient_id);
+
+-- Suggestions rejected by a user are not suggested again for the same product
+CREATE TABLE IF NOT EXISTS public.supplier_product_mapping_rejections
+(
+    product_id integer NOT NULL,
+    ingredient_id character varying(50) NOT NULL,
+    rejected_by_user_id character varying(50),
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT supplier_product_mapping_rejections_pkey PRIMARY KEY (product_id, ingredient_id),
+    CONSTRAINT supplier_product_mapping_rejections_product_fkey FOREIGN KEY (product_id)
+        REFERENCES public.supplier_price_list (product_id) ON DELETE CASCADE,
+    CONSTRAINT supplier_product_mapping_rejections_ingredient_fkey FOREIGN KEY (ingredient_id)
+        REFERENCES public.master_ingredients (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE
+);
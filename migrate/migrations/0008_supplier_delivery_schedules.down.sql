@@ -0,0 +1,3 @@
+ALTER TABLE public.ingredient_request_details DROP COLUMN IF EXISTS order_deadline;
+
+DROP TABLE IF EXISTS public.supplier_delivery_schedules;
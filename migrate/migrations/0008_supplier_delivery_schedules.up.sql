@@ -0,0 +1,34 @@
+-- Supplier delivery calendars: the weekdays a supplier delivers and the order cut-off
+-- (e.g. 15:00 one day before). Rows with a kitchen_id replace the supplier's general
+-- schedule for that kitchen. A supplier without rows delivers every day.
+
+CREATE TABLE IF NOT EXISTS public.supplier_delivery_schedules
+(
+    schedule_id integer NOT NULL GENERATED ALWAYS AS IDENTITY,
+    supplier_id character varying(50) NOT NULL,
+    kitchen_id character varying(50),
+    weekday smallint NOT NULL,
+    cutoff_days_before integer NOT NULL DEFAULT 1,
+    cutoff_time character varying(5) NOT NULL DEFAULT '15:00',
+    active boolean DEFAULT true,
+    notes text,
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT supplier_delivery_schedules_pkey PRIMARY KEY (schedule_id),
+    CONSTRAINT supplier_delivery_schedules_supplier_fkey FOREIGN KEY (supplier_id)
+        REFERENCES public.master_suppliers (supplier_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT supplier_delivery_schedules_kitchen_fkey FOREIGN KEY (kitchen_id)
+        REFERENCES public.master_kitchens (kitchen_id) ON DELETE CASCADE,
+    CONSTRAINT supplier_delivery_schedules_weekday_check CHECK (weekday BETWEEN 0 AND 6),
+    CONSTRAINT supplier_delivery_schedules_cutoff_days_check CHECK (cutoff_days_before >= 0),
+    CONSTRAINT supplier_delivery_schedules_cutoff_time_check CHECK (cutoff_time ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$')
+);
+
+COMMENT ON TABLE public.supplier_delivery_schedules IS 'Lịch giao hàng và giờ chốt đơn của nhà cung cấp';
+COMMENT ON COLUMN public.supplier_delivery_schedules.weekday IS '0 = Chủ nhật ... 6 = Thứ bảy';
+
+CREATE INDEX IF NOT EXISTS idx_delivery_schedule_supplier
+    ON public.supplier_delivery_schedules(supplier_id, kitchen_id);
+
+-- Latest time the supplier must receive the order for each request line
+ALTER TABLE public.ingredient_request_details ADD COLUMN IF NOT EXISTS order_deadline timestamp without time zone;
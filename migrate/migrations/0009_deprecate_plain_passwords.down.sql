@@ -0,0 +1 @@
+COMMENT ON COLUMN public.master_users.plain_password IS NULL;
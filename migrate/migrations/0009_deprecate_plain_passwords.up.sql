@@ -0,0 +1,4 @@
+-- Plain text passwords are no longer written. Remaining values are rehashed and wiped on the
+-- user's next login, and wiped for everyone once PLAINTEXT_PASSWORD_FALLBACK_UNTIL has passed.
+COMMENT ON COLUMN public.master_users.plain_password
+    IS 'Deprecated: legacy plain text password, wiped on next login and after the migration window';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.role_permissions;
+DROP TABLE IF EXISTS public.roles CASCADE;
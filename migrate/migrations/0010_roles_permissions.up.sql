@@ -0,0 +1,64 @@
+-- Permission based access control. Permissions are defined in code (rbac.Catalogue),
+-- roles and the permissions granted to them live here. master_users.role names a role.
+
+CREATE TABLE IF NOT EXISTS public.roles
+(
+    role_name character varying(50) NOT NULL,
+    description text,
+    is_superuser boolean NOT NULL DEFAULT false,
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT roles_pkey PRIMARY KEY (role_name)
+);
+
+COMMENT ON TABLE public.roles IS 'Vai trò người dùng; vai trò superuser có mọi quyền';
+
+CREATE TABLE IF NOT EXISTS public.role_permissions
+(
+    role_name character varying(50) NOT NULL,
+    permission_key character varying(100) NOT NULL,
+    CONSTRAINT role_permissions_pkey PRIMARY KEY (role_name, permission_key),
+    CONSTRAINT role_permissions_role_fkey FOREIGN KEY (role_name)
+        REFERENCES public.roles (role_name) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- Built-in roles, matching the roles accepted before permissions existed
+INSERT INTO roles (role_name, description, is_superuser) VALUES
+    ('Admin', 'Quản trị viên - toàn quyền', true),
+    ('moderator', 'Quản lý bếp - thao tác và duyệt phiếu', false),
+    ('user', 'Nhân viên - xem dữ liệu, lập đơn và phiếu yêu cầu', false)
+ON CONFLICT (role_name) DO NOTHING;
+
+-- Default grants, only for roles that have no permissions yet
+INSERT INTO role_permissions (role_name, permission_key)
+SELECT 'user', p FROM unnest(ARRAY[
+    'ingredient.read', 'kitchen.read', 'dish.read', 'recipe_standard.read',
+    'supplier.read', 'supplier_price.read',
+    'order.read', 'order.write',
+    'inventory.stock.read', 'inventory.import.read', 'inventory.export.read',
+    'inventory.adjustment.read', 'inventory.request.read', 'inventory.request.write',
+    'inventory.report.read'
+]) AS p
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions WHERE role_name = 'user');
+
+INSERT INTO role_permissions (role_name, permission_key)
+SELECT 'moderator', p FROM unnest(ARRAY[
+    'ingredient.read', 'ingredient.write', 'kitchen.read', 'dish.read', 'dish.write',
+    'recipe_standard.read', 'recipe_standard.write',
+    'supplier.read', 'supplier.write', 'supplier_price.read', 'supplier_price.write',
+    'supplier_product.map',
+    'order.read', 'order.write', 'order.cancel',
+    'inventory.stock.read', 'inventory.stock.write',
+    'inventory.import.read', 'inventory.import.write', 'inventory.import.approve',
+    'inventory.export.read', 'inventory.export.write', 'inventory.export.approve',
+    'inventory.adjustment.read', 'inventory.adjustment.write', 'inventory.adjustment.approve',
+    'inventory.request.read', 'inventory.request.write', 'inventory.request.approve',
+    'inventory.report.read'
+]) AS p
+WHERE NOT EXISTS (SELECT 1 FROM role_permissions WHERE role_name = 'moderator');
+
+-- Any other role already assigned to users is kept, without permissions
+INSERT INTO roles (role_name, description)
+SELECT DISTINCT role, 'Vai trò có sẵn' FROM master_users
+WHERE role IS NOT NULL AND role <> ''
+ON CONFLICT (role_name) DO NOTHING;
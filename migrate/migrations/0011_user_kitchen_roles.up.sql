@@ -0,0 +1,26 @@
+-- Kitchens a user works in, with an optional role that replaces the user's global role
+-- inside that kitchen (e.g. storekeeper in K001, viewer in K002).
+
+CREATE TABLE IF NOT EXISTS public.user_kitchens
+(
+    user_id character varying(50) NOT NULL,
+    kitchen_id character varying(50) NOT NULL,
+    CONSTRAINT user_kitchens_pkey PRIMARY KEY (user_id, kitchen_id),
+    CONSTRAINT user_kitchens_user_fkey FOREIGN KEY (user_id)
+        REFERENCES public.master_users (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT user_kitchens_kitchen_fkey FOREIGN KEY (kitchen_id)
+        REFERENCES public.master_kitchens (kitchen_id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+ALTER TABLE public.user_kitchens ADD COLUMN IF NOT EXISTS role_name character varying(50);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'user_kitchens_role_fkey') THEN
+        ALTER TABLE public.user_kitchens
+            ADD CONSTRAINT user_kitchens_role_fkey FOREIGN KEY (role_name)
+            REFERENCES public.roles (role_name) ON UPDATE CASCADE ON DELETE SET NULL;
+    END IF;
+END $$;
+
+COMMENT ON COLUMN public.user_kitchens.role_name IS 'Vai trò trong bếp này; NULL = dùng vai trò chung của người dùng';
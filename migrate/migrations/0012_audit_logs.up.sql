@@ -0,0 +1,28 @@
+-- Audit log of every create, update, delete and approval, written by the audit package
+-- from GORM callbacks. Only superuser roles may read it unless audit.read is granted.
+
+CREATE TABLE IF NOT EXISTS public.audit_logs
+(
+    audit_id bigint NOT NULL GENERATED ALWAYS AS IDENTITY,
+    actor_user_id character varying(50),
+    kitchen_id character varying(50),
+    entity_type character varying(100) NOT NULL,
+    entity_id character varying(255) NOT NULL DEFAULT '',
+    action character varying(20) NOT NULL,
+    before_data jsonb,
+    after_data jsonb,
+    changes jsonb,
+    request_id character varying(100) NOT NULL DEFAULT '',
+    ip_address character varying(64) NOT NULL DEFAULT '',
+    user_agent text NOT NULL DEFAULT '',
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT audit_logs_pkey PRIMARY KEY (audit_id),
+    CONSTRAINT audit_logs_action_check CHECK (action IN ('create', 'update', 'delete', 'approve'))
+);
+
+COMMENT ON TABLE public.audit_logs IS 'Nhật ký thay đổi dữ liệu';
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity ON public.audit_logs(entity_type, entity_id, created_date);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor ON public.audit_logs(actor_user_id, created_date);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_kitchen ON public.audit_logs(kitchen_id, created_date);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_request ON public.audit_logs(request_id);
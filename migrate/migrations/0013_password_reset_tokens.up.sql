@@ -0,0 +1,21 @@
+-- Single-use password reset links. Only the SHA-256 hash of the token is stored; a token
+-- is consumed by setting used_at and is refused after expires_at.
+
+CREATE TABLE IF NOT EXISTS public.password_reset_tokens
+(
+    token_id bigint NOT NULL GENERATED ALWAYS AS IDENTITY,
+    user_id character varying(50) NOT NULL,
+    token_hash character(64) NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    used_at timestamp without time zone,
+    created_by_user_id character varying(50),
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT password_reset_tokens_pkey PRIMARY KEY (token_id),
+    CONSTRAINT password_reset_tokens_hash_key UNIQUE (token_hash),
+    CONSTRAINT password_reset_tokens_user_fkey FOREIGN KEY (user_id)
+        REFERENCES public.master_users (user_id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+COMMENT ON TABLE public.password_reset_tokens IS 'Liên kết đặt lại mật khẩu (chỉ lưu mã băm)';
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON public.password_reset_tokens(user_id, created_date);
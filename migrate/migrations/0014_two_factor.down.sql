@@ -0,0 +1,4 @@
+ALTER TABLE public.roles DROP COLUMN IF EXISTS require_two_factor;
+
+DROP TABLE IF EXISTS public.user_recovery_codes;
+DROP TABLE IF EXISTS public.user_two_factor;
@@ -0,0 +1,35 @@
+-- TOTP two-factor authentication: the secret of each enrolled user, hashed single-use
+-- recovery codes, and a per-role flag making the second factor mandatory.
+
+CREATE TABLE IF NOT EXISTS public.user_two_factor
+(
+    user_id character varying(50) NOT NULL,
+    totp_secret character varying(64) NOT NULL,
+    enabled_at timestamp without time zone,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    modified_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_two_factor_pkey PRIMARY KEY (user_id),
+    CONSTRAINT user_two_factor_user_fkey FOREIGN KEY (user_id)
+        REFERENCES public.master_users (user_id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+COMMENT ON TABLE public.user_two_factor IS 'Xác thực hai lớp (TOTP) của người dùng';
+
+CREATE TABLE IF NOT EXISTS public.user_recovery_codes
+(
+    code_id bigint NOT NULL GENERATED ALWAYS AS IDENTITY,
+    user_id character varying(50) NOT NULL,
+    code_hash character(64) NOT NULL,
+    used_at timestamp without time zone,
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_recovery_codes_pkey PRIMARY KEY (code_id),
+    CONSTRAINT user_recovery_codes_user_fkey FOREIGN KEY (user_id)
+        REFERENCES public.master_users (user_id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+COMMENT ON TABLE public.user_recovery_codes IS 'Mã khôi phục dùng một lần (chỉ lưu mã băm)';
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON public.user_recovery_codes(user_id, code_hash);
+
+ALTER TABLE public.roles ADD COLUMN IF NOT EXISTS require_two_factor boolean NOT NULL DEFAULT false;
@@ -0,0 +1,32 @@
+-- Login brute-force protection: failure counters shared by all replicas and a log of
+-- rejected login attempts.
+
+CREATE TABLE IF NOT EXISTS public.login_throttles
+(
+    throttle_key character varying(255) NOT NULL,
+    failures integer NOT NULL,
+    last_failure timestamp without time zone NOT NULL,
+    CONSTRAINT login_throttles_pkey PRIMARY KEY (throttle_key)
+);
+
+COMMENT ON TABLE public.login_throttles IS 'Số lần đăng nhập sai theo tên đăng nhập / địa chỉ IP';
+
+CREATE INDEX IF NOT EXISTS idx_login_throttles_last_failure ON public.login_throttles(last_failure);
+
+CREATE TABLE IF NOT EXISTS public.failed_logins
+(
+    failed_login_id bigint NOT NULL GENERATED ALWAYS AS IDENTITY,
+    user_name character varying(255),
+    user_id character varying(50),
+    ip_address character varying(64),
+    user_agent text,
+    reason character varying(50) NOT NULL,
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT failed_logins_pkey PRIMARY KEY (failed_login_id)
+);
+
+COMMENT ON TABLE public.failed_logins IS 'Nhật ký đăng nhập thất bại';
+
+CREATE INDEX IF NOT EXISTS idx_failed_logins_created ON public.failed_logins(created_date);
+CREATE INDEX IF NOT EXISTS idx_failed_logins_user ON public.failed_logins(user_name, created_date);
+CREATE INDEX IF NOT EXISTS idx_failed_logins_ip ON public.failed_logins(ip_address, created_date);
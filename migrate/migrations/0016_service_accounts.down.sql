@@ -0,0 +1,8 @@
+-- Service accounts stay as ordinary, inactive users.
+
+UPDATE public.master_users SET active = false WHERE account_type = 'service';
+
+DROP TABLE IF EXISTS public.api_key_permissions;
+DROP TABLE IF EXISTS public.api_keys;
+
+ALTER TABLE public.master_users DROP COLUMN IF EXISTS account_type;
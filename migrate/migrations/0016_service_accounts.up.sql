@@ -0,0 +1,41 @@
+-- Service accounts for integrations: master_users rows with account_type 'service' that
+-- authenticate with API keys. Only the SHA-256 hash of a key's secret is stored.
+
+ALTER TABLE public.master_users ADD COLUMN IF NOT EXISTS account_type character varying(20) NOT NULL DEFAULT 'user';
+
+CREATE TABLE IF NOT EXISTS public.api_keys
+(
+    key_id bigint NOT NULL GENERATED ALWAYS AS IDENTITY,
+    user_id character varying(50) NOT NULL,
+    name character varying(255) NOT NULL,
+    prefix character varying(16) NOT NULL,
+    secret_hash character(64) NOT NULL,
+    expires_at timestamp without time zone,
+    last_used_at timestamp without time zone,
+    last_used_ip character varying(64),
+    revoked_at timestamp without time zone,
+    rotated_from_key_id bigint,
+    created_by_user_id character varying(50),
+    created_date timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT api_keys_pkey PRIMARY KEY (key_id),
+    CONSTRAINT api_keys_prefix_key UNIQUE (prefix),
+    CONSTRAINT api_keys_user_fkey FOREIGN KEY (user_id)
+        REFERENCES public.master_users (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT api_keys_rotated_from_fkey FOREIGN KEY (rotated_from_key_id)
+        REFERENCES public.api_keys (key_id) ON DELETE SET NULL
+);
+
+COMMENT ON TABLE public.api_keys IS 'API key của tài khoản dịch vụ (chỉ lưu mã băm)';
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON public.api_keys(user_id);
+
+CREATE TABLE IF NOT EXISTS public.api_key_permissions
+(
+    key_id bigint NOT NULL,
+    permission_key character varying(100) NOT NULL,
+    CONSTRAINT api_key_permissions_pkey PRIMARY KEY (key_id, permission_key),
+    CONSTRAINT api_key_permissions_key_fkey FOREIGN KEY (key_id)
+        REFERENCES public.api_keys (key_id) ON DELETE CASCADE
+);
+
+COMMENT ON TABLE public.api_key_permissions IS 'Quyền được cấp cho API key';
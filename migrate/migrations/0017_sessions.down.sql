@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.idx_token_last_activity;
+DROP INDEX IF EXISTS public.idx_token_refresh_expires;
+
+ALTER TABLE public.master_users DROP COLUMN IF EXISTS last_login_at;
@@ -0,0 +1,7 @@
+-- Session management: the last successful login of each user, and indexes for the idle
+-- timeout and the cleanup of expired sessions.
+
+ALTER TABLE public.master_users ADD COLUMN IF NOT EXISTS last_login_at timestamp without time zone;
+
+CREATE INDEX IF NOT EXISTS idx_token_refresh_expires ON public.auth_token_pairs(refresh_expires_at);
+CREATE INDEX IF NOT EXISTS idx_token_last_activity ON public.auth_token_pairs(last_activity);
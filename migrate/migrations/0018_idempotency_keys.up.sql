@@ -0,0 +1,19 @@
+-- Idempotent requests: the Idempotency-Key sent by a client with the hash of its request and
+-- the response it got, replayed when the request is retried.
+
+CREATE TABLE IF NOT EXISTS public.idempotency_keys
+(
+    owner character varying(255) NOT NULL,
+    idempotency_key character varying(255) NOT NULL,
+    request_hash character(64) NOT NULL,
+    status_code integer,
+    content_type character varying(255),
+    response_body bytea,
+    created_at timestamp without time zone NOT NULL,
+    CONSTRAINT idempotency_keys_pkey PRIMARY KEY (owner, idempotency_key)
+);
+
+COMMENT ON TABLE public.idempotency_keys IS 'Khóa chống gửi trùng yêu cầu (Idempotency-Key) và phản hồi đã trả';
+COMMENT ON COLUMN public.idempotency_keys.status_code IS 'NULL khi yêu cầu đang được xử lý';
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON public.idempotency_keys(created_at);
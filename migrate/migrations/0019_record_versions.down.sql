@@ -0,0 +1,7 @@
+ALTER TABLE public.orders DROP COLUMN IF EXISTS version;
+ALTER TABLE public.supplier_price_list DROP COLUMN IF EXISTS version;
+ALTER TABLE public.dish_recipe_standards DROP COLUMN IF EXISTS version;
+ALTER TABLE public.ingredient_requests DROP COLUMN IF EXISTS version;
+ALTER TABLE public.inventory_adjustments DROP COLUMN IF EXISTS version;
+ALTER TABLE public.inventory_exports DROP COLUMN IF EXISTS version;
+ALTER TABLE public.inventory_imports DROP COLUMN IF EXISTS version;
@@ -0,0 +1,18 @@
+-- Optimistic concurrency: a version per mutable record, incremented by every change and sent
+-- as the record's ETag. A change naming an older version is refused.
+
+ALTER TABLE public.inventory_imports ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE public.inventory_exports ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE public.inventory_adjustments ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE public.ingredient_requests ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE public.dish_recipe_standards ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE public.supplier_price_list ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
+
+COMMENT ON COLUMN public.inventory_imports.version IS 'Phiên bản, tăng sau mỗi lần thay đổi';
+COMMENT ON COLUMN public.inventory_exports.version IS 'Phiên bản, tăng sau mỗi lần thay đổi';
+COMMENT ON COLUMN public.inventory_adjustments.version IS 'Phiên bản, tăng sau mỗi lần thay đổi';
+COMMENT ON COLUMN public.ingredient_requests.version IS 'Phiên bản, tăng sau mỗi lần thay đổi';
+COMMENT ON COLUMN public.dish_recipe_standards.version IS 'Phiên bản, tăng sau mỗi lần thay đổi';
+COMMENT ON COLUMN public.supplier_price_list.version IS 'Phiên bản, tăng sau mỗi lần thay đổi';
+COMMENT ON COLUMN public.orders.version IS 'Phiên bản, tăng sau mỗi lần thay đổi';
@@ -0,0 +1,5 @@
+-- ETag and Location of stored idempotent responses, sent again with the replayed response so
+-- a client retrying a create or an approval gets the version for its next If-Match.
+
+ALTER TABLE public.idempotency_keys ADD COLUMN IF NOT EXISTS etag character varying(255);
+ALTER TABLE public.idempotency_keys ADD COLUMN IF NOT EXISTS location text;
@@ -0,0 +1,135 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration - One numbered schema change with the SQL applying and reverting it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Checksum is the SHA-256 of Up, recorded when the migration is applied
+	Checksum string
+}
+
+// fileName is the name of the up or down file of m
+func (m Migration) fileName(direction string) string {
+	return fmt.Sprintf("%04d_%s.%s.sql", m.Version, m.Name, direction)
+}
+
+// filePattern matches migration files such as 0001_initial_schema.up.sql
+var filePattern = regexp.MustCompile(`^(\d{4,})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// namePattern is what a migration name may contain once create has normalized it
+var namePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Embedded returns the migrations compiled into the binary
+func Embedded() ([]Migration, error) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return Load(sub)
+}
+
+// Load reads the migrations in the root of fsys. Every version needs both an up and a down
+// file, and versions must be unique.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := filePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s is not named NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("migration %s is missing or empty", m.fileName("up"))
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			return nil, fmt.Errorf("migration %s is missing or empty", m.fileName("down"))
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Create writes empty up and down files for a new migration in dir, numbered after the
+// highest version already there, and returns their paths
+func Create(dir, name string) (string, string, error) {
+	name = strings.ToLower(strings.NewReplacer(" ", "_", "-", "_").Replace(strings.TrimSpace(name)))
+	if !namePattern.MatchString(name) {
+		return "", "", fmt.Errorf("migration name %q may only contain letters, digits, spaces, - and _", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("read migrations: %w", err)
+	}
+	next := 1
+	for _, entry := range entries {
+		if match := filePattern.FindStringSubmatch(entry.Name()); match != nil {
+			if version, _ := strconv.Atoi(match[1]); version >= next {
+				next = version + 1
+			}
+		}
+	}
+
+	m := Migration{Version: next, Name: name}
+	up := filepath.Join(dir, m.fileName("up"))
+	down := filepath.Join(dir, m.fileName("down"))
+	header := "-- " + strings.ReplaceAll(name, "_", " ") + "\n"
+	if err := os.WriteFile(up, []byte(header), 0o644); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(down, []byte("-- Reverts "+m.fileName("up")+"\n"), 0o644); err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// APIKey - Key a service account authenticates with through the X-API-Key header (api_keys).
+// Only the SHA-256 hash of the secret is stored; Prefix identifies the key. The key can only
+// use the permissions listed in Permissions, and only where the account's role grants them.
+type APIKey struct {
+	KeyID            int64              `gorm:"primaryKey;column:key_id;autoIncrement" json:"keyId"`
+	UserID           string             `gorm:"column:user_id;not null" json:"userId"`
+	Name             string             `gorm:"column:name;not null" json:"name"`
+	Prefix           string             `gorm:"column:prefix;not null;unique" json:"prefix"`
+	SecretHash       string             `gorm:"column:secret_hash;not null" json:"-"`
+	ExpiresAt        *time.Time         `gorm:"column:expires_at" json:"expiresAt"`
+	LastUsedAt       *time.Time         `gorm:"column:last_used_at" json:"lastUsedAt"`
+	LastUsedIP       string             `gorm:"column:last_used_ip" json:"lastUsedIp"`
+	RevokedAt        *time.Time         `gorm:"column:revoked_at" json:"revokedAt"`
+	RotatedFromKeyID *int64             `gorm:"column:rotated_from_key_id" json:"rotatedFromKeyId"`
+	CreatedByUserID  *string            `gorm:"column:created_by_user_id" json:"createdByUserId"`
+	CreatedDate      time.Time          `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	Permissions      []APIKeyPermission `gorm:"foreignKey:KeyID;references:KeyID" json:"permissions"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Usable reports whether the key is neither revoked nor expired at now
+func (k *APIKey) Usable(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// PermissionKeys returns the permissions the key is scoped to
+func (k *APIKey) PermissionKeys() []string {
+	keys := make([]string, len(k.Permissions))
+	for i, p := range k.Permissions {
+		keys[i] = p.PermissionKey
+	}
+	return keys
+}
+
+// APIKeyPermission - One permission an API key is scoped to (api_key_permissions)
+type APIKeyPermission struct {
+	KeyID         int64  `gorm:"primaryKey;column:key_id" json:"-"`
+	PermissionKey string `gorm:"primaryKey;column:permission_key" json:"permissionKey"`
+}
+
+func (APIKeyPermission) TableName() string {
+	return "api_key_permissions"
+}
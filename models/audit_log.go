@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditActionCreate  = "create"
+	AuditActionUpdate  = "update"
+	AuditActionDelete  = "delete"
+	AuditActionApprove = "approve"
+)
+
+// AuditLog - One recorded data change (audit_logs). Written by the audit package from GORM
+// callbacks; Before/After are the row as stored, Changes maps each changed column to
+// {"from": old, "to": new}.
+type AuditLog struct {
+	AuditID     int64           `gorm:"primaryKey;column:audit_id;autoIncrement" json:"auditId"`
+	ActorUserID *string         `gorm:"column:actor_user_id" json:"actorUserId"`
+	KitchenID   *string         `gorm:"column:kitchen_id" json:"kitchenId"`
+	EntityType  string          `gorm:"column:entity_type" json:"entityType"`
+	EntityID    string          `gorm:"column:entity_id" json:"entityId"`
+	Action      string          `gorm:"column:action" json:"action"`
+	Before      json.RawMessage `gorm:"column:before_data;type:jsonb" json:"before,omitempty"`
+	After       json.RawMessage `gorm:"column:after_data;type:jsonb" json:"after,omitempty"`
+	Changes     json.RawMessage `gorm:"column:changes;type:jsonb" json:"changes,omitempty"`
+	RequestID   string          `gorm:"column:request_id" json:"requestId"`
+	IPAddress   string          `gorm:"column:ip_address" json:"ipAddress"`
+	UserAgent   string          `gorm:"column:user_agent" json:"userAgent"`
+	CreatedDate time.Time       `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_logs"
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyKey - Idempotency-Key of a request (idempotency_keys) with the hash of the request
+// and the response it got. StatusCode is nil while the request is processed.
+type IdempotencyKey struct {
+	Owner          string    `gorm:"primaryKey;column:owner" json:"owner"`
+	IdempotencyKey string    `gorm:"primaryKey;column:idempotency_key" json:"idempotencyKey"`
+	RequestHash    string    `gorm:"column:request_hash;not null" json:"requestHash"`
+	StatusCode     *int      `gorm:"column:status_code" json:"statusCode"`
+	ContentType    string    `gorm:"column:content_type" json:"contentType"`
+	ETag           string    `gorm:"column:etag" json:"etag"`
+	Location       string    `gorm:"column:location" json:"location"`
+	ResponseBody   []byte    `gorm:"column:response_body" json:"-"`
+	CreatedAt      time.Time `gorm:"column:created_at;not null" json:"createdAt"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
@@ -17,6 +17,7 @@ type IngredientRequest struct {
 	ApprovedDate     *time.Time `gorm:"column:approved_date" json:"approvedDate,omitempty"`
 	CreatedDate      time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
 	ModifiedDate     time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Version          int       `gorm:"column:version;not null;default:1" json:"version"`
 
 	// Relationships
 	Order        *Order                      `gorm:"foreignKey:OrderID;references:OrderID" json:"order,omitempty"`
@@ -41,8 +42,10 @@ type IngredientRequestDetail struct {
 	UnitPrice       *float64  `gorm:"column:unit_price" json:"unitPrice,omitempty"`
 	TotalPrice      *float64  `gorm:"column:total_price" json:"totalPrice,omitempty"`
 	Notes           *string   `gorm:"column:notes" json:"notes,omitempty"`
-	CreatedDate     time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
-	ModifiedDate    time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	// OrderDeadline is the latest time the supplier must receive the order to deliver on the required date
+	OrderDeadline *time.Time `gorm:"column:order_deadline" json:"orderDeadline,omitempty"`
+	CreatedDate   time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate  time.Time  `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
 
 	// Relationships
 	Request    *IngredientRequest `gorm:"foreignKey:RequestID;references:RequestID" json:"request,omitempty"`
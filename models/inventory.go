@@ -40,6 +40,7 @@ type InventoryImport struct {
 	CreatedByUserID  *string    `gorm:"column:created_by_user_id" json:"createdByUserId,omitempty"`
 	CreatedDate      time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
 	ModifiedDate     time.Time  `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Version          int        `gorm:"column:version;not null;default:1" json:"version"`
 
 	// Relationships
 	Kitchen      *Kitchen                   `gorm:"foreignKey:KitchenID;references:KitchenID" json:"kitchen,omitempty"`
@@ -98,6 +99,7 @@ type InventoryExport struct {
 	CreatedByUserID     *string    `gorm:"column:created_by_user_id" json:"createdByUserId,omitempty"`
 	CreatedDate         time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
 	ModifiedDate        time.Time  `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Version             int        `gorm:"column:version;not null;default:1" json:"version"`
 
 	// Relationships
 	Kitchen            *Kitchen                  `gorm:"foreignKey:KitchenID;references:KitchenID" json:"kitchen,omitempty"`
@@ -177,6 +179,7 @@ type InventoryAdjustment struct {
 	CreatedByUserID  *string                       `gorm:"column:created_by_user_id" json:"createdByUserId,omitempty"`
 	CreatedDate      time.Time                     `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
 	ModifiedDate     time.Time                     `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Version          int                           `gorm:"column:version;not null;default:1" json:"version"`
 
 	// Relationships
 	Kitchen           *Kitchen                      `gorm:"foreignKey:KitchenID;references:KitchenID" json:"kitchen,omitempty"`
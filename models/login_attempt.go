@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Failed login reasons
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureTwoFactorInvalid   = "two_factor_invalid"
+	LoginFailureThrottled          = "throttled"
+	LoginFailureLocked             = "locked"
+)
+
+// FailedLogin - One rejected login attempt (failed_logins). UserID is set when the user name
+// matched an account; the client only ever sees a generic message.
+type FailedLogin struct {
+	FailedLoginID int64     `gorm:"primaryKey;column:failed_login_id;autoIncrement" json:"failedLoginId"`
+	UserName      string    `gorm:"column:user_name" json:"userName"`
+	UserID        *string   `gorm:"column:user_id" json:"userId"`
+	IPAddress     string    `gorm:"column:ip_address" json:"ipAddress"`
+	UserAgent     string    `gorm:"column:user_agent" json:"userAgent"`
+	Reason        string    `gorm:"column:reason" json:"reason"`
+	CreatedDate   time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+}
+
+func (FailedLogin) TableName() string {
+	return "failed_logins"
+}
+
+// LoginThrottle - Failure counter of a user name or client address (login_throttles), shared
+// by all replicas
+type LoginThrottle struct {
+	ThrottleKey string    `gorm:"primaryKey;column:throttle_key" json:"throttleKey"`
+	Failures    int       `gorm:"column:failures;not null" json:"failures"`
+	LastFailure time.Time `gorm:"column:last_failure;not null" json:"lastFailure"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
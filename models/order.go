@@ -13,6 +13,7 @@ type Order struct {
 	CreatedByUserID string    `gorm:"column:created_by_user_id" json:"createdByUserId"`
 	CreatedDate     time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
 	ModifiedDate    time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Version         int       `gorm:"column:version;not null;default:1" json:"version"`
 
 	// Relationships
 	Kitchen            *Kitchen                 `gorm:"foreignKey:KitchenID;references:KitchenID" json:"kitchen,omitempty"`
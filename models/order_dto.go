@@ -14,6 +14,7 @@ type OrderDTO struct {
 	CreatedByName   string                  `json:"createdByName"`
 	CreatedDate     time.Time               `json:"createdDate"`
 	ModifiedDate    time.Time               `json:"modifiedDate"`
+	Version         int                     `json:"version"`
 	Details         []OrderDetailDTO        `json:"details"`
 	Supplementaries []OrderSupplementaryDTO `json:"supplementaries"`
 }
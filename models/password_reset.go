@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PasswordResetToken - Single-use password reset link (password_reset_tokens). Only the
+// SHA-256 hash of the token is stored. CreatedByUserID is set when an admin forced the reset.
+type PasswordResetToken struct {
+	TokenID         int64      `gorm:"primaryKey;column:token_id;autoIncrement" json:"tokenId"`
+	UserID          string     `gorm:"column:user_id;not null" json:"userId"`
+	TokenHash       string     `gorm:"column:token_hash;not null;unique" json:"-"`
+	ExpiresAt       time.Time  `gorm:"column:expires_at;not null" json:"expiresAt"`
+	UsedAt          *time.Time `gorm:"column:used_at" json:"usedAt"`
+	CreatedByUserID *string    `gorm:"column:created_by_user_id" json:"createdByUserId"`
+	CreatedDate     time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+}
+
+func (PasswordResetToken) TableName() string {
+	return "password_reset_tokens"
+}
@@ -15,6 +15,7 @@ type RecipeStandard struct {
 	UpdatedByID  string    `gorm:"column:updated_by_user_id" json:"updatedById"`
 	CreatedDate  time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
 	ModifiedDate time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Version      int       `gorm:"column:version;not null;default:1" json:"version"`
 
 	// Relationships
 	Dish       *Dish       `gorm:"foreignKey:DishID;references:DishID" json:"dish,omitempty"`
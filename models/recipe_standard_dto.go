@@ -17,6 +17,7 @@ type RecipeStandardDTO struct {
 	UpdatedByName  string    `json:"updatedByName"`      // Added: User name (optional)
 	CreatedDate    time.Time `json:"createdDate"`
 	ModifiedDate   time.Time `json:"modifiedDate"`
+	Version        int       `json:"version"`
 }
 
 // ToDTO converts RecipeStandard model to DTO
@@ -32,6 +33,7 @@ func (r *RecipeStandard) ToDTO() RecipeStandardDTO {
 		UpdatedByID:  r.UpdatedByID,
 		CreatedDate:  r.CreatedDate,
 		ModifiedDate: r.ModifiedDate,
+		Version:      r.Version,
 	}
 
 	// Populate names from relationships if available
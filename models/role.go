@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Role - Named set of permissions assigned to users through master_users.role (roles)
+// A superuser role is granted every permission, including ones added later. Users holding
+// a role with RequireTwoFactor cannot sign in without a TOTP code.
+type Role struct {
+	RoleName         string           `gorm:"primaryKey;column:role_name" json:"roleName" binding:"required"`
+	Description      string           `gorm:"column:description;type:text" json:"description"`
+	IsSuperuser      bool             `gorm:"column:is_superuser;default:false" json:"isSuperuser"`
+	RequireTwoFactor bool             `gorm:"column:require_two_factor;default:false" json:"requireTwoFactor"`
+	CreatedDate      time.Time        `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate     time.Time        `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Permissions      []RolePermission `gorm:"foreignKey:RoleName;references:RoleName" json:"permissions"`
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+// RolePermission - One permission granted to a role (role_permissions)
+type RolePermission struct {
+	RoleName      string `gorm:"primaryKey;column:role_name" json:"-"`
+	PermissionKey string `gorm:"primaryKey;column:permission_key" json:"permissionKey"`
+}
+
+func (RolePermission) TableName() string {
+	return "role_permissions"
+}
+
+// UserKitchen - Kitchen a user works in (user_kitchens). RoleName, when set, replaces the
+// user's global role inside that kitchen, e.g. storekeeper in K001 and viewer in K002.
+type UserKitchen struct {
+	UserID    string  `gorm:"primaryKey;column:user_id" json:"userId"`
+	KitchenID string  `gorm:"primaryKey;column:kitchen_id" json:"kitchenId" binding:"required"`
+	RoleName  *string `gorm:"column:role_name" json:"roleName"`
+
+	// Relationships
+	Kitchen *Kitchen `gorm:"foreignKey:KitchenID;references:KitchenID" json:"kitchen,omitempty"`
+}
+
+func (UserKitchen) TableName() string {
+	return "user_kitchens"
+}
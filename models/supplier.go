@@ -4,22 +4,44 @@ import "time"
 
 // Supplier - Master data for suppliers (dm_ncc)
 type Supplier struct {
-	SupplierID   string    `gorm:"primaryKey;column:supplier_id" json:"supplierId"`
-	SupplierName string    `gorm:"column:supplier_name;not null" json:"supplierName"`
-	ZaloLink     string    `gorm:"column:zalo_link;type:text" json:"zaloLink"`
-	Address      string    `gorm:"column:address;type:text" json:"address"`
-	Phone        string    `gorm:"column:phone" json:"phone"`
-	Email        string    `gorm:"column:email" json:"email"`
-	Active       *bool     `gorm:"column:active;default:true" json:"active"`
-	LegacyID     *string   `gorm:"column:legacy_id" json:"legacyId,omitempty"`
-	CreatedDate  time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
-	ModifiedDate time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	SupplierID   string `gorm:"primaryKey;column:supplier_id" json:"supplierId"`
+	SupplierName string `gorm:"column:supplier_name;not null" json:"supplierName"`
+	ZaloLink     string `gorm:"column:zalo_link;type:text" json:"zaloLink"`
+	Address      string `gorm:"column:address;type:text" json:"address"`
+	Phone        string `gorm:"column:phone" json:"phone"`
+	Email        string `gorm:"column:email" json:"email"`
+	Active       *bool  `gorm:"column:active;default:true" json:"active"`
+	// Delivery terms used by basket consolidation
+	MinOrderValue float64   `gorm:"column:min_order_value;default:0" json:"minOrderValue"`
+	DeliveryFee   float64   `gorm:"column:delivery_fee;default:0" json:"deliveryFee"`
+	LegacyID      *string   `gorm:"column:legacy_id" json:"legacyId,omitempty"`
+	CreatedDate   time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate  time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
 }
 
 func (Supplier) TableName() string {
 	return "master_suppliers"
 }
 
+// SupplierDeliverySchedule - A weekday a supplier delivers on and its order cut-off (supplier_delivery_schedules).
+// Rows with a KitchenID replace the supplier's general schedule for that kitchen.
+type SupplierDeliverySchedule struct {
+	ScheduleID       int       `gorm:"primaryKey;autoIncrement;column:schedule_id" json:"scheduleId"`
+	SupplierID       string    `gorm:"column:supplier_id;not null" json:"supplierId"`
+	KitchenID        *string   `gorm:"column:kitchen_id" json:"kitchenId"`
+	Weekday          int       `gorm:"column:weekday;not null" json:"weekday" binding:"gte=0,lte=6"` // 0 = Sunday
+	CutoffDaysBefore int       `gorm:"column:cutoff_days_before;not null;default:1" json:"cutoffDaysBefore" binding:"gte=0"`
+	CutoffTime       string    `gorm:"column:cutoff_time;not null" json:"cutoffTime" binding:"required"` // HH:MM
+	Active           *bool     `gorm:"column:active;default:true" json:"active"`
+	Notes            string    `gorm:"column:notes;type:text" json:"notes"`
+	CreatedDate      time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate     time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+}
+
+func (SupplierDeliverySchedule) TableName() string {
+	return "supplier_delivery_schedules"
+}
+
 // BestSupplierRequest - Request to find best suppliers for ingredients in an order
 type BestSupplierRequest struct {
 	OrderID       string   `json:"orderId" binding:"required"`
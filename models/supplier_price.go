@@ -6,7 +6,7 @@ import "time"
 type SupplierPrice struct {
 	ProductID     int        `gorm:"primaryKey;autoIncrement;column:product_id" json:"productId"`
 	ProductName   string     `gorm:"column:product_name" json:"productName"`
-	IngredientID  string     `gorm:"column:ingredient_id" json:"ingredientId"`
+	IngredientID  *string    `gorm:"column:ingredient_id" json:"ingredientId"` // nil until the product is mapped
 	Category      string     `gorm:"column:classification" json:"category"`
 	SupplierID    string     `gorm:"column:supplier_id" json:"supplierId"`
 	Manufacturer  string     `gorm:"column:manufacturer_name" json:"manufacturer"`
@@ -19,8 +19,10 @@ type SupplierPrice struct {
 	Active        *bool      `gorm:"column:active;default:true" json:"active"`
 	NewPrice      float64    `gorm:"column:new_buying_price;type:decimal(15,2)" json:"newPrice"`
 	Promotion     string     `gorm:"column:promotion;type:char(1)" json:"promotion"`
+	MappingStatus string     `gorm:"column:mapping_status;default:confirmed" json:"mappingStatus"`
 	CreatedDate   time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
 	ModifiedDate  time.Time  `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	Version       int        `gorm:"column:version;not null;default:1" json:"version"`
 
 	// Relationships
 	Ingredient *Ingredient `gorm:"foreignKey:IngredientID;references:IngredientID" json:"ingredient,omitempty"`
@@ -30,3 +32,37 @@ type SupplierPrice struct {
 func (SupplierPrice) TableName() string {
 	return "supplier_price_list"
 }
+
+// Mapping status of a supplier product
+const (
+	MappingStatusUnmapped  = "unmapped"  // no ingredient yet
+	MappingStatusPending   = "pending"   // ingredient set but not confirmed by a user
+	MappingStatusConfirmed = "confirmed" // used by supplier selection
+)
+
+// SupplierProductIngredient - Additional ingredient a supplier product can be used for (supplier_product_ingredients)
+type SupplierProductIngredient struct {
+	ProductID      int       `gorm:"primaryKey;column:product_id" json:"productId"`
+	IngredientID   string    `gorm:"primaryKey;column:ingredient_id" json:"ingredientId"`
+	MappedByUserID *string   `gorm:"column:mapped_by_user_id" json:"mappedByUserId"`
+	CreatedDate    time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+
+	// Relationships
+	Ingredient *Ingredient `gorm:"foreignKey:IngredientID;references:IngredientID" json:"ingredient,omitempty"`
+}
+
+func (SupplierProductIngredient) TableName() string {
+	return "supplier_product_ingredients"
+}
+
+// SupplierProductMappingRejection - Ingredient suggestion rejected for a product (supplier_product_mapping_rejections)
+type SupplierProductMappingRejection struct {
+	ProductID        int       `gorm:"primaryKey;column:product_id" json:"productId"`
+	IngredientID     string    `gorm:"primaryKey;column:ingredient_id" json:"ingredientId"`
+	RejectedByUserID *string   `gorm:"column:rejected_by_user_id" json:"rejectedByUserId"`
+	CreatedDate      time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+}
+
+func (SupplierProductMappingRejection) TableName() string {
+	return "supplier_product_mapping_rejections"
+}
@@ -6,7 +6,7 @@ import "time"
 type SupplierPriceDTO struct {
 	ProductID        int        `json:"productId"`
 	ProductName      string     `json:"productName"`
-	IngredientID     string     `json:"ingredientId"`
+	IngredientID     *string    `json:"ingredientId"`
 	IngredientName   string     `json:"ingredientName"`   // Ingredient name from relationship
 	Category         string     `json:"category"`
 	SupplierID       string     `json:"supplierId"`
@@ -21,6 +21,8 @@ type SupplierPriceDTO struct {
 	Active           *bool      `json:"active"`
 	NewPrice         float64    `json:"newPrice"`
 	Promotion        string     `json:"promotion"`
+	MappingStatus    string     `json:"mappingStatus"`
+	Version          int        `json:"version"`
 }
 
 // ToDTO converts SupplierPrice model to DTO
@@ -41,6 +43,8 @@ func (sp *SupplierPrice) ToDTO() SupplierPriceDTO {
 		Active:        sp.Active,
 		NewPrice:      sp.NewPrice,
 		Promotion:     sp.Promotion,
+		MappingStatus: sp.MappingStatus,
+		Version:       sp.Version,
 	}
 
 	// Populate names from relationships if available
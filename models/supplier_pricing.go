@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// SupplierPriceTier - Quantity price break for a supplier product (supplier_price_tiers).
+// Either UnitPrice replaces the list price or DiscountPercent is taken off it.
+type SupplierPriceTier struct {
+	TierID          int       `gorm:"primaryKey;autoIncrement;column:tier_id" json:"tierId"`
+	ProductID       int       `gorm:"column:product_id;not null" json:"productId"`
+	MinQuantity     float64   `gorm:"column:min_quantity;type:decimal(15,4);not null" json:"minQuantity" binding:"gt=0"`
+	UnitPrice       *float64  `gorm:"column:unit_price;type:decimal(15,2)" json:"unitPrice" binding:"omitempty,gte=0"`
+	DiscountPercent *float64  `gorm:"column:discount_percent;type:decimal(5,2)" json:"discountPercent" binding:"omitempty,gt=0,lte=100"`
+	CreatedDate     time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate    time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+}
+
+func (SupplierPriceTier) TableName() string {
+	return "supplier_price_tiers"
+}
+
+// SupplierContractPrice - Negotiated price of a supplier product for one kitchen (supplier_contract_prices)
+type SupplierContractPrice struct {
+	ContractID    int        `gorm:"primaryKey;autoIncrement;column:contract_id" json:"contractId"`
+	ProductID     int        `gorm:"column:product_id;not null" json:"productId"`
+	KitchenID     string     `gorm:"column:kitchen_id;not null" json:"kitchenId" binding:"required"`
+	UnitPrice     float64    `gorm:"column:unit_price;type:decimal(15,2);not null" json:"unitPrice" binding:"gte=0"`
+	EffectiveFrom *time.Time `gorm:"column:effective_from" json:"effectiveFrom"`
+	EffectiveTo   *time.Time `gorm:"column:effective_to" json:"effectiveTo"`
+	Active        *bool      `gorm:"column:active;default:true" json:"active"`
+	Notes         string     `gorm:"column:notes;type:text" json:"notes"`
+	CreatedDate   time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate  time.Time  `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+
+	// Relationships
+	Kitchen *Kitchen `gorm:"foreignKey:KitchenID;references:KitchenID" json:"kitchen,omitempty"`
+}
+
+func (SupplierContractPrice) TableName() string {
+	return "supplier_contract_prices"
+}
+
+// SupplierPromotion - Time-boxed promotion on a supplier product (supplier_promotions).
+// KitchenID nil means the promotion applies to every kitchen.
+type SupplierPromotion struct {
+	PromotionID     int       `gorm:"primaryKey;autoIncrement;column:promotion_id" json:"promotionId"`
+	ProductID       int       `gorm:"column:product_id;not null" json:"productId"`
+	KitchenID       *string   `gorm:"column:kitchen_id" json:"kitchenId"`
+	Name            string    `gorm:"column:name" json:"name"`
+	DiscountPercent *float64  `gorm:"column:discount_percent;type:decimal(5,2)" json:"discountPercent" binding:"omitempty,gt=0,lte=100"`
+	PromoPrice      *float64  `gorm:"column:promo_price;type:decimal(15,2)" json:"promoPrice" binding:"omitempty,gte=0"`
+	MinQuantity     float64   `gorm:"column:min_quantity;type:decimal(15,4);default:0" json:"minQuantity" binding:"gte=0"`
+	StartsAt        time.Time `gorm:"column:starts_at;not null" json:"startsAt" binding:"required"`
+	EndsAt          time.Time `gorm:"column:ends_at;not null" json:"endsAt" binding:"required,gtfield=StartsAt"`
+	Active          *bool     `gorm:"column:active;default:true" json:"active"`
+	CreatedDate     time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate    time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+}
+
+func (SupplierPromotion) TableName() string {
+	return "supplier_promotions"
+}
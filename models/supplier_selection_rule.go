@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Supplier selection strategies understood by the selection engine
+const (
+	StrategyFavoriteFirst       = "favorite_first"
+	StrategyCheapest            = "cheapest"
+	StrategyCheapestPerBaseUnit = "cheapest_per_base_unit"
+	StrategySingleSupplier      = "single_supplier"
+	StrategyScoreWeighted       = "score_weighted"
+)
+
+// SupplierSelectionRule - Configurable rule deciding how the best supplier is picked (supplier_selection_rules)
+// KitchenID nil means the rule applies to all kitchens. A rule without IngredientTypeID
+// and MaterialGroup is the default for its kitchen scope.
+type SupplierSelectionRule struct {
+	RuleID           int       `gorm:"primaryKey;autoIncrement;column:rule_id" json:"ruleId"`
+	KitchenID        *string   `gorm:"column:kitchen_id" json:"kitchenId"`
+	IngredientTypeID *string   `gorm:"column:ingredient_type_id" json:"ingredientTypeId"`
+	MaterialGroup    *string   `gorm:"column:material_group" json:"materialGroup"`
+	Strategy         string    `gorm:"column:strategy;not null" json:"strategy" binding:"required"`
+	Priority         int       `gorm:"column:priority;default:0" json:"priority"`
+	PriceWeight      float64   `gorm:"column:price_weight;type:numeric(6,3);default:0.6" json:"priceWeight"`
+	FavoriteWeight   float64   `gorm:"column:favorite_weight;type:numeric(6,3);default:0.3" json:"favoriteWeight"`
+	CoverageWeight   float64   `gorm:"column:coverage_weight;type:numeric(6,3);default:0.1" json:"coverageWeight"`
+	Active           *bool     `gorm:"column:active;default:true" json:"active"`
+	Notes            string    `gorm:"column:notes;type:text" json:"notes"`
+	UpdatedByUserID  *string   `gorm:"column:updated_by_user_id" json:"updatedByUserId,omitempty"`
+	CreatedDate      time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate     time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+
+	// Relationships
+	Kitchen        *Kitchen        `gorm:"foreignKey:KitchenID;references:KitchenID" json:"kitchen,omitempty"`
+	IngredientType *IngredientType `gorm:"foreignKey:IngredientTypeID;references:IngredientTypeID" json:"ingredientType,omitempty"`
+}
+
+func (SupplierSelectionRule) TableName() string {
+	return "supplier_selection_rules"
+}
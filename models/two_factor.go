@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// UserTwoFactor - TOTP second factor of a user (user_two_factor). The row is created at
+// enrollment; EnabledAt is set once the user confirmed a first code. LastUsedStep is the
+// time step of the last accepted code, so a code cannot be used twice.
+type UserTwoFactor struct {
+	UserID       string     `gorm:"primaryKey;column:user_id" json:"userId"`
+	TOTPSecret   string     `gorm:"column:totp_secret;not null" json:"-"`
+	EnabledAt    *time.Time `gorm:"column:enabled_at" json:"enabledAt"`
+	LastUsedStep int64      `gorm:"column:last_used_step;not null;default:0" json:"-"`
+	CreatedDate  time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate time.Time  `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+}
+
+func (UserTwoFactor) TableName() string {
+	return "user_two_factor"
+}
+
+// Enabled reports whether the second factor has been confirmed
+func (t *UserTwoFactor) Enabled() bool {
+	return t != nil && t.EnabledAt != nil
+}
+
+// RecoveryCode - Single-use code replacing a TOTP code when the phone is lost
+// (user_recovery_codes). Only the SHA-256 hash of the code is stored.
+type RecoveryCode struct {
+	CodeID      int64      `gorm:"primaryKey;column:code_id;autoIncrement" json:"codeId"`
+	UserID      string     `gorm:"column:user_id;not null" json:"userId"`
+	CodeHash    string     `gorm:"column:code_hash;not null" json:"-"`
+	UsedAt      *time.Time `gorm:"column:used_at" json:"usedAt"`
+	CreatedDate time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "user_recovery_codes"
+}
@@ -2,25 +2,29 @@ package models
 
 import "time"
 
-
-
-
-
+// Account types. Service accounts are used by integrations through API keys and cannot
+// sign in with a password.
+const (
+	AccountTypeUser    = "user"
+	AccountTypeService = "service"
+)
 
 // User - Master data for user accounts (dm_nguoidung)
 type User struct {
-	UserID        string    `gorm:"primaryKey;column:user_id" json:"userId"`
-	UserName      string    `gorm:"column:user_name;not null;unique" json:"userName"`
-	Password      string    `gorm:"column:password;not null" json:"password,omitempty"`
-	PlainPassword string    `gorm:"column:plain_password" json:"plainPassword,omitempty"`
-	FullName      string    `gorm:"column:full_name;not null" json:"fullName"`
-	Role          string    `gorm:"column:role" json:"role"`
-	Email         string    `gorm:"column:email" json:"email"`
-	Phone         string    `gorm:"column:phone" json:"phone"`
-	Active        *bool     `gorm:"column:active;default:true" json:"active"`
-	LegacyID      *string   `gorm:"column:legacy_id" json:"legacyId,omitempty"`
-	CreatedDate   time.Time `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
-	ModifiedDate  time.Time `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
+	UserID        string     `gorm:"primaryKey;column:user_id" json:"userId"`
+	UserName      string     `gorm:"column:user_name;not null;unique" json:"userName"`
+	Password      string     `gorm:"column:password;not null" json:"-"`
+	PlainPassword string     `gorm:"column:plain_password" json:"-"` // Deprecated: read only to rehash legacy accounts
+	FullName      string     `gorm:"column:full_name;not null" json:"fullName"`
+	Role          string     `gorm:"column:role" json:"role"`
+	Email         string     `gorm:"column:email" json:"email"`
+	Phone         string     `gorm:"column:phone" json:"phone"`
+	Active        *bool      `gorm:"column:active;default:true" json:"active"`
+	AccountType   string     `gorm:"column:account_type;default:user" json:"accountType"`
+	LegacyID      *string    `gorm:"column:legacy_id" json:"legacyId,omitempty"`
+	LastLoginAt   *time.Time `gorm:"column:last_login_at" json:"lastLoginAt"`
+	CreatedDate   time.Time  `gorm:"column:created_date;autoCreateTime" json:"createdDate"`
+	ModifiedDate  time.Time  `gorm:"column:modified_date;autoUpdateTime" json:"modifiedDate"`
 
 	// Relationships
 	// Many-to-many: one user can work in many kitchens, one kitchen can have many users
@@ -30,4 +34,3 @@ type User struct {
 func (User) TableName() string {
 	return "master_users"
 }
-
@@ -0,0 +1,298 @@
+// Package openapi generates the OpenAPI 3 document of the API from the route table and the
+// Go types the handlers bind and return, so the document cannot drift from the code. Each
+// route is declared once in a Routes table next to the router; Build turns the routes
+// registered on the rbac router into paths, and the request, query and response types into
+// JSON schemas, including the constraints of their binding tags.
+package openapi
+
+import (
+	"adong-be/apperr"
+	"adong-be/rbac"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.0.3"
+
+// Route documents one route, keyed in Routes like rbac.RouteTable ("METHOD /full/path")
+type Route struct {
+	Summary     string
+	Description string
+	// Query is a struct whose form tags are query parameters, e.g. models.PaginationParams
+	Query any
+	// Params are query parameters the handler reads itself with c.Query, and headers
+	Params []Param
+	// Request is the JSON body, Response the body of a success. Both are Go values whose
+	// type is documented, a Shape, or a *Schema.
+	Request  any
+	Response any
+	// OptionalBody marks a Request body the handler accepts empty
+	OptionalBody bool
+	// Status is the success status, 200 by default
+	Status int
+}
+
+// Routes documents the routes of the API
+type Routes map[string]Route
+
+// Param is a query parameter read with c.Query, or a header
+type Param struct {
+	Name        string
+	In          string // query by default, or header
+	Type        string // string, integer, number or boolean
+	Format      string // e.g. date
+	Description string
+	Required    bool
+	Enum        []string
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// PathItem holds the operations of a path by lower case method
+type PathItem map[string]*Operation
+
+// Operation is one method of a path
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	// Permission is the permission the route requires, see rbac
+	Permission string `json:"x-permission,omitempty"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the body of an operation
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response of an operation, or a reference to a shared one
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components are the schemas, responses and security schemes operations refer to
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Responses       map[string]*Response      `json:"responses"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way of authenticating
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+const jsonContent = "application/json"
+
+// errorResponse refers to the shared error response
+var errorResponse = &Response{Ref: "#/components/responses/Error"}
+
+// Build documents every route in routes with its entry in docs. Routes without an entry are
+// documented by their path alone; Undocumented lists them.
+func Build(info Info, routes rbac.RouteTable, docs Routes) *Document {
+	g := newGenerator()
+	errorType := reflect.TypeOf(apperr.Response{})
+	g.names[errorType] = "Error"
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Responses: map[string]*Response{
+				"Error": {
+					Description: "Error, see the code for the reason",
+					Content:     map[string]MediaType{jsonContent: {Schema: g.schemaOf(errorType)}},
+				},
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"apiKeyAuth": {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+	}
+
+	for _, key := range sortedKeys(routes) {
+		method, path, _ := strings.Cut(key, " ")
+		op := g.operation(path, routes[key], docs[key])
+		item := doc.Paths[pathTemplate(path)]
+		if item == nil {
+			item = &PathItem{}
+			doc.Paths[pathTemplate(path)] = item
+		}
+		(*item)[strings.ToLower(method)] = op
+	}
+	doc.Components.Schemas = g.components
+	return doc
+}
+
+func (g *generator) operation(path, permission string, route Route) *Operation {
+	op := &Operation{
+		Tags:        []string{tag(path)},
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   make(map[string]*Response),
+	}
+
+	for _, name := range pathParams(path) {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, g.queryParameters(reflect.TypeOf(route.Query))...)
+	}
+	for _, p := range route.Params {
+		s := &Schema{Type: p.Type, Format: p.Format}
+		if s.Type == "" {
+			s.Type = "string"
+		}
+		for _, v := range p.Enum {
+			s.Enum = append(s.Enum, v)
+		}
+		in := p.In
+		if in == "" {
+			in = "query"
+		}
+		op.Parameters = append(op.Parameters, Parameter{Name: p.Name, In: in, Description: p.Description, Required: p.Required, Schema: s})
+	}
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: !route.OptionalBody,
+			Content:  map[string]MediaType{jsonContent: {Schema: g.valueSchema(route.Request)}},
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := &Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		success.Content = map[string]MediaType{jsonContent: {Schema: g.valueSchema(route.Response)}}
+	}
+	op.Responses[strconv.Itoa(status)] = success
+
+	if len(op.Parameters) > 0 || op.RequestBody != nil {
+		op.Responses["400"] = errorResponse
+	}
+	if permission != rbac.Public {
+		op.Responses["401"] = errorResponse
+		op.Responses["403"] = errorResponse
+		op.Security = []map[string][]string{{"bearerAuth": {}}}
+		if strings.HasPrefix(path, "/api/") {
+			op.Security = append(op.Security, map[string][]string{"apiKeyAuth": {}})
+		}
+		if permission != rbac.Authenticated {
+			op.Permission = permission
+		}
+	}
+	if len(pathParams(path)) > 0 {
+		op.Responses["404"] = errorResponse
+	}
+	op.Responses["500"] = errorResponse
+	return op
+}
+
+// Undocumented lists the routes without an entry in docs
+func Undocumented(routes rbac.RouteTable, docs Routes) []string {
+	var missing []string
+	for _, key := range sortedKeys(routes) {
+		if _, ok := docs[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
+
+// Unrouted lists the entries of docs that match no route, e.g. after a route was removed
+func Unrouted(routes rbac.RouteTable, docs Routes) []string {
+	var stale []string
+	for key := range docs {
+		if _, ok := routes[key]; !ok {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(stale)
+	return stale
+}
+
+// pathTemplate turns a gin path into an OpenAPI path template: /orders/:id becomes
+// /orders/{id}
+func pathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if len(s) > 1 && (s[0] == ':' || s[0] == '*') {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams lists the parameter names of a gin path
+func pathParams(path string) []string {
+	var names []string
+	for _, s := range strings.Split(path, "/") {
+		if len(s) > 1 && (s[0] == ':' || s[0] == '*') {
+			names = append(names, s[1:])
+		}
+	}
+	return names
+}
+
+// tag groups operations by resource: the first segment after /api, two for inventory
+func tag(path string) string {
+	segments := strings.Split(strings.TrimPrefix(strings.TrimPrefix(path, "/api"), "/"), "/")
+	if segments[0] == "inventory" && len(segments) > 1 {
+		return segments[0] + "/" + segments[1]
+	}
+	return segments[0]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
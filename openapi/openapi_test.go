@@ -0,0 +1,219 @@
+package openapi
+
+import (
+	"adong-be/apperr"
+	"adong-be/rbac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type audited struct {
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type line struct {
+	IngredientID string  `json:"ingredientId" binding:"required"`
+	Quantity     float64 `json:"quantity" binding:"required,gt=0"`
+}
+
+type order struct {
+	audited
+	KitchenID string   `json:"kitchenId" binding:"required,min=2"`
+	Status    string   `json:"status" binding:"omitempty,oneof=draft approved"`
+	Notes     *string  `json:"notes"`
+	Lines     []line   `json:"lines" binding:"required,min=1,dive"`
+	Tags      []string `json:"tags" binding:"dive,max=10"`
+	Internal  string   `json:"-"`
+}
+
+type listQuery struct {
+	Page   int    `form:"page" binding:"omitempty,min=1"`
+	Status string `form:"status" binding:"omitempty,oneof=open closed"`
+}
+
+func TestPathTemplate(t *testing.T) {
+	assert.Equal(t, "/api/orders/{id}/ingredients/{ingredientId}/summary", pathTemplate("/api/orders/:id/ingredients/:ingredientId/summary"))
+	assert.Equal(t, []string{"id", "ingredientId"}, pathParams("/api/orders/:id/ingredients/:ingredientId/summary"))
+	assert.Equal(t, "inventory/stocks", tag("/api/inventory/stocks/:id"))
+	assert.Equal(t, "orders", tag("/api/orders"))
+	assert.Equal(t, "auth", tag("/auth/login"))
+}
+
+func TestSchema(t *testing.T) {
+	g := newGenerator()
+	ref := g.valueSchema(order{})
+	assert.Equal(t, "#/components/schemas/order", ref.Ref)
+
+	s := g.components["order"]
+	require.NotNil(t, s)
+	assert.ElementsMatch(t, []string{"createdBy", "createdAt", "kitchenId", "status", "notes", "lines", "tags"}, keys(s.Properties), "embedded fields are inlined, hidden ones skipped")
+	assert.Equal(t, []string{"kitchenId", "lines"}, s.Required)
+	assert.Equal(t, "date-time", s.Properties["createdAt"].Format)
+	assert.True(t, s.Properties["notes"].Nullable)
+	assert.Equal(t, 2, *s.Properties["kitchenId"].MinLength)
+	assert.Equal(t, []any{"draft", "approved"}, s.Properties["status"].Enum)
+	assert.Equal(t, 1, *s.Properties["lines"].MinItems)
+	assert.Equal(t, "#/components/schemas/line", s.Properties["lines"].Items.Ref)
+	assert.Equal(t, 10, *s.Properties["tags"].Items.MaxLength, "rules after dive constrain the items")
+
+	quantity := g.components["line"].Properties["quantity"]
+	assert.Equal(t, 0.0, *quantity.Minimum)
+	assert.True(t, quantity.ExclusiveMinimum)
+
+	patch := g.valueSchema(Patch(order{}))
+	assert.Empty(t, patch.Required)
+	assert.Len(t, patch.Properties, len(s.Properties))
+
+	params := g.queryParameters(reflect.TypeOf(listQuery{}))
+	require.Len(t, params, 2)
+	assert.Equal(t, "page", params[0].Name)
+	assert.Equal(t, 1.0, *params[0].Schema.Minimum)
+	assert.Equal(t, []any{"open", "closed"}, params[1].Schema.Enum)
+}
+
+func TestBuild(t *testing.T) {
+	routes := rbac.RouteTable{
+		"POST /auth/login":    rbac.Public,
+		"GET /api/orders":     rbac.OrderRead,
+		"PUT /api/orders/:id": rbac.OrderWrite,
+		"GET /api/profile":    rbac.Authenticated,
+	}
+	docs := Routes{
+		"GET /api/orders":     {Summary: "List orders", Query: listQuery{}, Response: Object{"data": []order{}}},
+		"PUT /api/orders/:id": {Request: Patch(order{}), Params: []Param{{Name: "Idempotency-Key", In: "header"}}, Response: order{}},
+		"GET /api/removed":    {},
+	}
+	doc := Build(Info{Title: "test", Version: "1"}, routes, docs)
+
+	assert.Equal(t, []string{"GET /api/profile", "POST /auth/login"}, Undocumented(routes, docs))
+	assert.Equal(t, []string{"GET /api/removed"}, Unrouted(routes, docs))
+
+	login := (*doc.Paths["/auth/login"])["post"]
+	assert.Empty(t, login.Security)
+	assert.NotContains(t, login.Responses, "401")
+
+	list := (*doc.Paths["/api/orders"])["get"]
+	assert.Equal(t, rbac.OrderRead, list.Permission)
+	assert.Len(t, list.Security, 2, "API routes take a token or an API key")
+	assert.Contains(t, list.Responses, "400")
+	assert.NotContains(t, list.Responses, "404")
+
+	update := (*doc.Paths["/api/orders/{id}"])["put"]
+	assert.Equal(t, "id", update.Parameters[0].Name)
+	assert.Equal(t, Parameter{Name: "Idempotency-Key", In: "header", Schema: &Schema{Type: "string"}}, update.Parameters[1])
+	assert.Contains(t, update.Responses, "404")
+	assert.Equal(t, "#/components/responses/Error", update.Responses["500"].Ref)
+
+	profile := (*doc.Paths["/api/profile"])["get"]
+	assert.Empty(t, profile.Permission)
+	assert.Contains(t, doc.Components.Schemas, "Error")
+
+	_, err := json.Marshal(doc)
+	assert.NoError(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := rbac.RouteTable{"POST /orders": rbac.OrderWrite, "GET /orders": rbac.OrderRead}
+	doc := Build(Info{}, routes, Routes{
+		"POST /orders": {Request: order{}},
+		"GET /orders":  {Query: listQuery{}, Params: []Param{{Name: "from_date", Format: "date"}, {Name: "kitchen_id", Required: true}}},
+	})
+
+	var received string
+	r := gin.New()
+	r.Use(Validate(doc))
+	r.POST("/orders", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.Status(http.StatusNoContent)
+	})
+	r.GET("/orders", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.GET("/undocumented", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	serve := func(method, target, body string) (int, apperr.Response) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		var resp apperr.Response
+		if w.Code != http.StatusNoContent {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+		}
+		return w.Code, resp
+	}
+	fields := func(resp apperr.Response) []string {
+		var out []string
+		for _, f := range resp.Fields {
+			out = append(out, f.Field+":"+f.Rule)
+		}
+		return out
+	}
+
+	valid := `{"kitchenId": "K001", "createdAt": "2024-05-20T08:00:00Z", "notes": null, "lines": [{"ingredientId": "NL001", "quantity": 2.5}]}`
+	status, _ := serve(http.MethodPost, "/orders", valid)
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.Equal(t, valid, received, "the handler reads the body again")
+
+	status, resp := serve(http.MethodPost, "/orders", `{"kitchenId": 7, "status": "sent", "createdAt": "yesterday", "lines": [{"quantity": 0}], "tags": ["a-very-long-tag"]}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, apperr.CodeValidation, resp.Code)
+	assert.ElementsMatch(t, []string{
+		"kitchenId:type",
+		"status:oneof",
+		"createdAt:date",
+		"lines[0].ingredientId:required",
+		"lines[0].quantity:gt",
+		"tags[0]:max",
+	}, fields(resp))
+
+	status, resp = serve(http.MethodPost, "/orders", `{"kitchenId": "K001", "lines": []}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, []string{"lines:min"}, fields(resp))
+
+	status, resp = serve(http.MethodPost, "/orders", `{`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, apperr.CodeInvalidRequest, resp.Code)
+
+	status, _ = serve(http.MethodGet, "/orders?kitchen_id=K001&page=2&status=open&from_date=2024-05-01", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	status, resp = serve(http.MethodGet, "/orders?page=0&status=pending&from_date=May", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.ElementsMatch(t, []string{"page:gte", "status:oneof", "from_date:date", "kitchen_id:required"}, fields(resp))
+
+	status, resp = serve(http.MethodGet, "/orders?kitchen_id=K001&page=two", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, []string{"page:type"}, fields(resp))
+
+	status, _ = serve(http.MethodGet, "/undocumented?page=two", "")
+	assert.Equal(t, http.StatusNoContent, status)
+}
+
+func TestViewer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/docs", Viewer("/openapi.json"))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`)
+}
+
+func keys(m map[string]*Schema) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
@@ -0,0 +1,313 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON schema of the OpenAPI dialect
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     bool               `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// Shape describes a body that has no Go type of its own, e.g. the gin.H a handler returns
+type Shape interface {
+	schema(g *generator) *Schema
+}
+
+// Object is a JSON object whose properties are described by Go values (documented by their
+// type), Shapes or *Schemas:
+//
+//	openapi.Object{"data": []models.Order{}, "meta": models.PaginationMeta{}}
+type Object map[string]any
+
+func (o Object) schema(g *generator) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema, len(o))}
+	for name, v := range o {
+		s.Properties[name] = g.valueSchema(v)
+	}
+	return s
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+	marshaler   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// generator turns Go types into schemas. Structs become components of the document,
+// referred to by name.
+type generator struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newGenerator() *generator {
+	return &generator{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// valueSchema is the schema of a value declared in a Route
+func (g *generator) valueSchema(v any) *Schema {
+	switch v := v.(type) {
+	case nil:
+		return &Schema{}
+	case *Schema:
+		return v
+	case Shape:
+		return v.schema(g)
+	default:
+		return g.schemaOf(reflect.TypeOf(v))
+	}
+}
+
+// schemaOf is the schema of the JSON encoding of t
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{}
+	case t.Kind() != reflect.Pointer && t.Implements(marshaler):
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := g.schemaOf(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return g.component(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// component refers to the schema of the struct t, adding it to the components on first use.
+// Types named alike in different packages are told apart by their package unless named in
+// g.names beforehand.
+func (g *generator) component(t reflect.Type) *Schema {
+	if t.Name() == "" {
+		return g.object(t)
+	}
+	name, ok := g.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := g.components[name]; taken {
+			name = path.Base(t.PkgPath()) + "." + name
+		}
+		g.names[t] = name
+	}
+	if _, done := g.components[name]; !done {
+		g.components[name] = &Schema{} // placeholder for recursive types
+		*g.components[name] = *g.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// object is the schema of the struct t, with the fields of embedded structs inlined
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := g.object(ft)
+				for n, p := range embedded.Properties {
+					s.Properties[n] = p
+				}
+				s.Required = append(s.Required, embedded.Required...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		field := g.schemaOf(f.Type)
+		if constrain(field, f.Tag.Get("binding")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = field
+	}
+	return s
+}
+
+// queryParameters are the query parameters of the struct t, named by their form tags
+func (g *generator) queryParameters(t reflect.Type) []Parameter {
+	var params []Parameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("form"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		s := g.schemaOf(f.Type)
+		required := constrain(s, f.Tag.Get("binding"))
+		params = append(params, Parameter{Name: name, In: "query", Required: required, Schema: s})
+	}
+	return params
+}
+
+// constrain adds the rules of a binding tag to s and reports whether they require the
+// field. Rules after dive apply to the items of s.
+func constrain(s *Schema, tag string) (required bool) {
+	if tag == "" || s.Ref != "" {
+		return strings.HasPrefix(tag, "required")
+	}
+	target := s
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			if target.Items == nil {
+				return required
+			}
+			target = target.Items
+			if target.Ref != "" {
+				return required
+			}
+		case "required":
+			required = required || target == s
+		case "email":
+			target.Format = "email"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				target.Enum = append(target.Enum, enumValue(target.Type, v))
+			}
+		case "min", "max", "gt", "gte", "lt", "lte":
+			bound(target, name, param)
+		}
+	}
+	return required
+}
+
+// bound adds a min, max, gt, gte, lt or lte rule to s. min and max bound the length of
+// strings and arrays and the value of numbers.
+func bound(s *Schema, rule, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "string":
+		switch rule {
+		case "min", "gte":
+			s.MinLength = length(n)
+		case "max", "lte":
+			s.MaxLength = length(n)
+		}
+	case "array":
+		switch rule {
+		case "min", "gte":
+			s.MinItems = length(n)
+		case "max", "lte":
+			s.MaxItems = length(n)
+		}
+	case "integer", "number":
+		switch rule {
+		case "min", "gte":
+			s.Minimum = float(n)
+		case "gt":
+			s.Minimum, s.ExclusiveMinimum = float(n), true
+		case "max", "lte":
+			s.Maximum = float(n)
+		case "lt":
+			s.Maximum, s.ExclusiveMaximum = float(n), true
+		}
+	}
+}
+
+// enumValue is a oneof value in the type of the schema it constrains
+func enumValue(typ, v string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+func float(n float64) *float64 { return &n }
+
+func length(n float64) *int {
+	l := int(n)
+	return &l
+}
+
+// Patch is a body merged into a stored record, as the update handlers bind into the record
+// they loaded: the fields of v's type, none of them required
+func Patch(v any) Shape {
+	return patch{v}
+}
+
+type patch struct{ v any }
+
+func (p patch) schema(g *generator) *Schema {
+	t := reflect.TypeOf(p.v)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	s := g.object(t)
+	s.Required = nil
+	return s
+}
@@ -0,0 +1,285 @@
+package openapi
+
+import (
+	"adong-be/apperr"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/mail"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Validate checks requests against doc before they reach their handlers: the query
+// parameters and the JSON body of every documented operation, by type, required fields,
+// enums and bounds. Invalid requests are answered with VALIDATION_FAILED listing the fields,
+// bodies that are not JSON with INVALID_REQUEST. doc is read per request, so it may be
+// built after the middleware is installed.
+func Validate(doc *Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		op := doc.operation(c.Request.Method, c.FullPath())
+		if op == nil {
+			c.Next()
+			return
+		}
+
+		v := &validator{doc: doc, err: apperr.New(apperr.CodeValidation)}
+		for _, p := range op.Parameters {
+			if p.In == "query" {
+				v.query(c, p)
+			}
+		}
+		if op.RequestBody != nil {
+			if err := v.body(c, op.RequestBody); err != nil {
+				apperr.Respond(c, err)
+				return
+			}
+		}
+		if len(v.err.Fields) > 0 {
+			apperr.Respond(c, v.err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// operation is the operation of the route with the gin path route, nil when undocumented
+func (d *Document) operation(method, route string) *Operation {
+	if route == "" {
+		return nil
+	}
+	item := d.Paths[pathTemplate(route)]
+	if item == nil {
+		return nil
+	}
+	return (*item)[strings.ToLower(method)]
+}
+
+type validator struct {
+	doc *Document
+	err *apperr.Error
+}
+
+func (v *validator) fail(field, rule string, param ...string) {
+	v.err = v.err.Field(field, rule, param...)
+}
+
+// query checks a query parameter, which arrives as a string whatever its type
+func (v *validator) query(c *gin.Context, p Parameter) {
+	raw, ok := c.GetQuery(p.Name)
+	if !ok || raw == "" {
+		if p.Required {
+			v.fail(p.Name, "required")
+		}
+		return
+	}
+	var value any = raw
+	switch p.Schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil || (p.Schema.Type == "integer" && strings.ContainsAny(raw, ".eE")) {
+			v.fail(p.Name, "type", p.Schema.Type)
+			return
+		}
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			v.fail(p.Name, "type", p.Schema.Type)
+			return
+		}
+		value = b
+	case "string":
+		if p.Schema.Format == "date" {
+			if _, err := time.Parse(time.DateOnly, raw); err != nil {
+				v.fail(p.Name, "date", "YYYY-MM-DD")
+				return
+			}
+		}
+	}
+	v.value(p.Name, p.Schema, value)
+}
+
+// body checks the JSON body and restores it for the handler
+func (v *validator) body(c *gin.Context, rb *RequestBody) error {
+	if c.Request.Body == nil {
+		if rb.Required {
+			return apperr.New(apperr.CodeInvalidRequest)
+		}
+		return nil
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return apperr.Wrap(apperr.CodeInvalidRequest, err)
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		if rb.Required {
+			return apperr.New(apperr.CodeInvalidRequest)
+		}
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var body any
+	if err := dec.Decode(&body); err != nil {
+		return apperr.Wrap(apperr.CodeInvalidRequest, err)
+	}
+	if media, ok := rb.Content[jsonContent]; ok {
+		v.value("", media.Schema, body)
+	}
+	return nil
+}
+
+// resolve follows a reference to the components
+func (v *validator) resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = v.doc.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// value checks a decoded JSON value at path against s. null passes: the handlers treat it
+// as absent, and required fields are checked by their object.
+func (v *validator) value(path string, s *Schema, value any) {
+	s = v.resolve(s)
+	if s == nil || value == nil || s.Type == "" {
+		return
+	}
+	field := path
+	if field == "" {
+		field = "body"
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.fail(field, "type", s.Type)
+			return
+		}
+		for _, name := range s.Required {
+			if obj[name] == nil {
+				v.fail(join(path, name), "required")
+			}
+		}
+		for name, item := range obj {
+			if p, ok := s.Properties[name]; ok {
+				v.value(join(path, name), p, item)
+			} else if s.AdditionalProperties != nil {
+				v.value(join(path, name), s.AdditionalProperties, item)
+			}
+		}
+
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			v.fail(field, "type", s.Type)
+			return
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			v.fail(field, "min", strconv.Itoa(*s.MinItems))
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			v.fail(field, "max", strconv.Itoa(*s.MaxItems))
+		}
+		for i, item := range arr {
+			v.value(fmt.Sprintf("%s[%d]", path, i), s.Items, item)
+		}
+
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			v.fail(field, "type", s.Type)
+			return
+		}
+		if !v.enum(field, s, str) {
+			return
+		}
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				v.fail(field, "date", "YYYY-MM-DDTHH:MM:SSZ")
+			}
+		case "email":
+			if _, err := mail.ParseAddress(str); err != nil {
+				v.fail(field, "email")
+			}
+		}
+		if n := len([]rune(str)); s.MinLength != nil && n < *s.MinLength {
+			v.fail(field, "min", strconv.Itoa(*s.MinLength))
+		} else if s.MaxLength != nil && n > *s.MaxLength {
+			v.fail(field, "max", strconv.Itoa(*s.MaxLength))
+		}
+
+	case "integer", "number":
+		num, ok := value.(json.Number)
+		if !ok {
+			v.fail(field, "type", s.Type)
+			return
+		}
+		n, err := num.Float64()
+		if err != nil || (s.Type == "integer" && n != float64(int64(n))) {
+			v.fail(field, "type", s.Type)
+			return
+		}
+		if !v.enum(field, s, n) {
+			return
+		}
+		v.bounds(field, s, n)
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			v.fail(field, "type", s.Type)
+		}
+	}
+}
+
+// enum reports whether value is one of the values of s, failing the field when it is not
+func (v *validator) enum(field string, s *Schema, value any) bool {
+	if len(s.Enum) == 0 {
+		return true
+	}
+	allowed := make([]string, len(s.Enum))
+	for i, e := range s.Enum {
+		allowed[i] = fmt.Sprint(e)
+	}
+	if slices.Contains(allowed, fmt.Sprint(value)) {
+		return true
+	}
+	v.fail(field, "oneof", strings.Join(allowed, " "))
+	return false
+}
+
+func (v *validator) bounds(field string, s *Schema, n float64) {
+	format := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	if s.Minimum != nil {
+		switch {
+		case s.ExclusiveMinimum && n <= *s.Minimum:
+			v.fail(field, "gt", format(*s.Minimum))
+		case !s.ExclusiveMinimum && n < *s.Minimum:
+			v.fail(field, "gte", format(*s.Minimum))
+		}
+	}
+	if s.Maximum != nil {
+		switch {
+		case s.ExclusiveMaximum && n >= *s.Maximum:
+			v.fail(field, "lt", format(*s.Maximum))
+		case !s.ExclusiveMaximum && n > *s.Maximum:
+			v.fail(field, "lte", format(*s.Maximum))
+		}
+	}
+}
+
+// join is the path of the property name of the object at path
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
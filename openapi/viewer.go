@@ -0,0 +1,27 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed viewer.html
+var viewerPage string
+
+// Serve serves doc as JSON
+func Serve(doc *Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
+
+// Viewer serves a page browsing the document at specURL
+func Viewer(specURL string) gin.HandlerFunc {
+	page := strings.ReplaceAll(viewerPage, "{{SPEC_URL}}", specURL)
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+	}
+}
@@ -0,0 +1,157 @@
+// Package pricing resolves the effective unit price of a supplier product for a
+// given kitchen, order quantity and date from the list price, quantity tiers,
+// kitchen contract prices and time-boxed promotions.
+package pricing
+
+import (
+	"adong-be/models"
+	"fmt"
+	"time"
+)
+
+// Price sources reported in Quote.Source
+const (
+	SourceList      = "list"
+	SourceTier      = "tier"
+	SourceContract  = "contract"
+	SourcePromotion = "promotion"
+)
+
+// Terms are the pricing terms attached to one supplier product
+type Terms struct {
+	Tiers      []models.SupplierPriceTier
+	Contracts  []models.SupplierContractPrice
+	Promotions []models.SupplierPromotion
+}
+
+// Quote is the resolved price of a product for one kitchen, quantity and date
+type Quote struct {
+	ProductID   int     `json:"productId"`
+	ListPrice   float64 `json:"listPrice"`
+	UnitPrice   float64 `json:"unitPrice"`
+	Source      string  `json:"source"`
+	TierID      int     `json:"tierId,omitempty"`
+	ContractID  int     `json:"contractId,omitempty"`
+	PromotionID int     `json:"promotionId,omitempty"`
+	Note        string  `json:"note"`
+}
+
+// Resolve computes the unit price of price for kitchenID, quantity and at.
+//
+// A contract price for the kitchen replaces the list price; otherwise the largest
+// quantity tier reached by quantity applies. A running promotion is then applied
+// on top if it makes the price lower. When several contracts or promotions match,
+// the cheapest wins.
+func Resolve(price models.SupplierPrice, terms Terms, kitchenID string, quantity float64, at time.Time) Quote {
+	q := Quote{
+		ProductID: price.ProductID,
+		ListPrice: price.UnitPrice,
+		UnitPrice: price.UnitPrice,
+		Source:    SourceList,
+		Note:      "List price",
+	}
+
+	if contract := bestContract(terms.Contracts, kitchenID, at); contract != nil {
+		q.UnitPrice = contract.UnitPrice
+		q.Source = SourceContract
+		q.ContractID = contract.ContractID
+		q.Note = fmt.Sprintf("Contract price for kitchen %s", kitchenID)
+	} else if tier := bestTier(terms.Tiers, quantity); tier != nil {
+		q.UnitPrice = tierPrice(*tier, price.UnitPrice)
+		q.Source = SourceTier
+		q.TierID = tier.TierID
+		q.Note = fmt.Sprintf("Quantity tier from %g %s", tier.MinQuantity, price.Unit)
+	}
+
+	var bestPromo *models.SupplierPromotion
+	bestPromoPrice := q.UnitPrice
+	for i, promo := range terms.Promotions {
+		if !promotionApplies(promo, kitchenID, quantity, at) {
+			continue
+		}
+		if p := promotionPrice(promo, q.UnitPrice); p < bestPromoPrice {
+			bestPromoPrice = p
+			bestPromo = &terms.Promotions[i]
+		}
+	}
+	if bestPromo != nil {
+		q.Note = fmt.Sprintf("%s; promotion %q until %s", q.Note, bestPromo.Name, bestPromo.EndsAt.Format("2006-01-02"))
+		q.UnitPrice = bestPromoPrice
+		q.Source = SourcePromotion
+		q.PromotionID = bestPromo.PromotionID
+	}
+
+	return q
+}
+
+func isActive(active *bool) bool {
+	return active == nil || *active
+}
+
+func bestContract(contracts []models.SupplierContractPrice, kitchenID string, at time.Time) *models.SupplierContractPrice {
+	var best *models.SupplierContractPrice
+	for i, c := range contracts {
+		if !isActive(c.Active) || c.KitchenID != kitchenID {
+			continue
+		}
+		if c.EffectiveFrom != nil && at.Before(*c.EffectiveFrom) {
+			continue
+		}
+		if c.EffectiveTo != nil && at.After(*c.EffectiveTo) {
+			continue
+		}
+		if best == nil || c.UnitPrice < best.UnitPrice {
+			best = &contracts[i]
+		}
+	}
+	return best
+}
+
+func bestTier(tiers []models.SupplierPriceTier, quantity float64) *models.SupplierPriceTier {
+	var best *models.SupplierPriceTier
+	for i, t := range tiers {
+		if quantity < t.MinQuantity {
+			continue
+		}
+		if best == nil || t.MinQuantity > best.MinQuantity {
+			best = &tiers[i]
+		}
+	}
+	return best
+}
+
+func tierPrice(t models.SupplierPriceTier, listPrice float64) float64 {
+	if t.UnitPrice != nil {
+		return *t.UnitPrice
+	}
+	if t.DiscountPercent != nil {
+		return listPrice * (1 - *t.DiscountPercent/100)
+	}
+	return listPrice
+}
+
+func promotionApplies(p models.SupplierPromotion, kitchenID string, quantity float64, at time.Time) bool {
+	if !isActive(p.Active) {
+		return false
+	}
+	if p.KitchenID != nil && *p.KitchenID != kitchenID {
+		return false
+	}
+	if quantity < p.MinQuantity {
+		return false
+	}
+	return !at.Before(p.StartsAt) && at.Before(p.EndsAt)
+}
+
+func promotionPrice(p models.SupplierPromotion, current float64) float64 {
+	price := current
+	if p.PromoPrice != nil && *p.PromoPrice < price {
+		price = *p.PromoPrice
+	}
+	if p.DiscountPercent != nil {
+		if discounted := current * (1 - *p.DiscountPercent/100); discounted < price {
+			price = discounted
+		}
+	}
+	return price
+}
@@ -0,0 +1,65 @@
+package pricing
+
+import (
+	"adong-be/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func f64(v float64) *float64 { return &v }
+func strp(s string) *string  { return &s }
+func boolp(b bool) *bool     { return &b }
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2025, 6, 15, 10, 0, 0, 0, time.UTC)
+	yesterday := now.AddDate(0, 0, -1)
+	tomorrow := now.AddDate(0, 0, 1)
+	price := models.SupplierPrice{ProductID: 1, UnitPrice: 100000, Unit: "kg"}
+
+	tiers := []models.SupplierPriceTier{
+		{TierID: 1, MinQuantity: 50, DiscountPercent: f64(5)},
+		{TierID: 2, MinQuantity: 100, UnitPrice: f64(90000)},
+	}
+	contracts := []models.SupplierContractPrice{
+		{ContractID: 1, KitchenID: "K001", UnitPrice: 92000},
+		{ContractID: 2, KitchenID: "K002", UnitPrice: 80000, EffectiveTo: &yesterday},
+		{ContractID: 3, KitchenID: "K003", UnitPrice: 70000, Active: boolp(false)},
+	}
+	promotions := []models.SupplierPromotion{
+		{PromotionID: 1, Name: "Summer", DiscountPercent: f64(10), StartsAt: yesterday, EndsAt: tomorrow, MinQuantity: 20},
+		{PromotionID: 2, KitchenID: strp("K004"), PromoPrice: f64(50000), StartsAt: yesterday, EndsAt: tomorrow},
+		{PromotionID: 3, PromoPrice: f64(1000), StartsAt: tomorrow, EndsAt: tomorrow.AddDate(0, 0, 1)},
+	}
+	terms := Terms{Tiers: tiers, Contracts: contracts, Promotions: promotions}
+
+	tests := []struct {
+		name      string
+		kitchen   string
+		quantity  float64
+		terms     Terms
+		wantPrice float64
+		wantSrc   string
+	}{
+		{"list price", "K009", 10, terms, 100000, SourceList},
+		{"first tier", "K009", 50, Terms{Tiers: tiers}, 95000, SourceTier},
+		{"highest reached tier", "K009", 150, Terms{Tiers: tiers}, 90000, SourceTier},
+		{"contract beats tier", "K001", 150, Terms{Tiers: tiers, Contracts: contracts}, 92000, SourceContract},
+		{"expired contract ignored", "K002", 10, Terms{Contracts: contracts}, 100000, SourceList},
+		{"inactive contract ignored", "K003", 10, Terms{Contracts: contracts}, 100000, SourceList},
+		{"promotion on top of tier", "K009", 60, terms, 85500, SourcePromotion},
+		{"promotion below min quantity", "K009", 10, terms, 100000, SourceList},
+		{"kitchen promotion", "K004", 1, terms, 50000, SourcePromotion},
+		{"promotion on top of contract", "K001", 20, terms, 82800, SourcePromotion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := Resolve(price, tt.terms, tt.kitchen, tt.quantity, now)
+			assert.InDelta(t, tt.wantPrice, q.UnitPrice, 0.001)
+			assert.Equal(t, tt.wantSrc, q.Source)
+			assert.Equal(t, 100000.0, q.ListPrice)
+			assert.NotEmpty(t, q.Note)
+		})
+	}
+}
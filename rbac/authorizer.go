@@ -0,0 +1,198 @@
+package rbac
+
+import (
+	"adong-be/apperr"
+	"adong-be/logger"
+	"adong-be/models"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoleSource loads every role with its granted permissions and the kitchens of a user
+type RoleSource interface {
+	LoadRoles() ([]models.Role, error)
+	LoadUserKitchens(userID string) ([]models.UserKitchen, error)
+}
+
+// ScopeKey is the context key under which Require stores the caller's Scope
+const ScopeKey = "kitchen_scope"
+
+// PermissionLimitKey holds the permissions an API key is scoped to. When set, Require also
+// refuses permissions outside the list, even if the account's role grants them.
+const PermissionLimitKey = "permission_limit"
+
+// Scope lists the kitchens in which the caller holds the permission of the current route.
+// AllKitchens is set for superusers, who are not bound to their assigned kitchens.
+type Scope struct {
+	Permission  string
+	AllKitchens bool
+	KitchenIDs  []string
+}
+
+// ScopeFrom returns the scope stored by Require, if the route declared a permission
+func ScopeFrom(c *gin.Context) (Scope, bool) {
+	v, ok := c.Get(ScopeKey)
+	if !ok {
+		return Scope{}, false
+	}
+	scope, ok := v.(Scope)
+	return scope, ok
+}
+
+type grant struct {
+	superuser   bool
+	permissions map[string]bool
+}
+
+// Authorizer answers permission checks from an in-memory copy of the roles table,
+// reloaded after ttl or when Invalidate is called
+type Authorizer struct {
+	source RoleSource
+	ttl    time.Duration
+
+	mu       sync.RWMutex
+	roles    map[string]grant
+	loadedAt time.Time
+}
+
+// NewAuthorizer creates an authorizer reading roles from source
+func NewAuthorizer(source RoleSource, ttl time.Duration) *Authorizer {
+	return &Authorizer{source: source, ttl: ttl}
+}
+
+// Invalidate forces the roles to be reloaded on the next check
+func (a *Authorizer) Invalidate() {
+	a.mu.Lock()
+	a.roles = nil
+	a.mu.Unlock()
+}
+
+func (a *Authorizer) snapshot() (map[string]grant, error) {
+	a.mu.RLock()
+	roles, loadedAt := a.roles, a.loadedAt
+	a.mu.RUnlock()
+	if roles != nil && time.Since(loadedAt) < a.ttl {
+		return roles, nil
+	}
+
+	loaded, err := a.source.LoadRoles()
+	if err != nil {
+		// Keep serving the last known roles rather than locking everybody out
+		if roles != nil {
+			logger.Log.Error("reload roles failed, using cached roles", "error", err)
+			return roles, nil
+		}
+		return nil, err
+	}
+	roles = make(map[string]grant, len(loaded))
+	for _, r := range loaded {
+		g := grant{superuser: r.IsSuperuser, permissions: make(map[string]bool, len(r.Permissions))}
+		for _, p := range r.Permissions {
+			g.permissions[p.PermissionKey] = true
+		}
+		roles[r.RoleName] = g
+	}
+
+	a.mu.Lock()
+	a.roles, a.loadedAt = roles, time.Now()
+	a.mu.Unlock()
+	return roles, nil
+}
+
+// RoleExists reports whether role is defined; tokens carrying an unknown role are refused
+func (a *Authorizer) RoleExists(role string) bool {
+	roles, err := a.snapshot()
+	if err != nil {
+		logger.Log.Error("load roles failed", "error", err)
+		return false
+	}
+	_, ok := roles[role]
+	return ok
+}
+
+// Allowed reports whether role has been granted permission
+func (a *Authorizer) Allowed(role, permission string) (bool, error) {
+	roles, err := a.snapshot()
+	if err != nil {
+		return false, err
+	}
+	return roles[role].allows(permission), nil
+}
+
+func (g grant) allows(permission string) bool {
+	return g.superuser || g.permissions[permission]
+}
+
+// Resolve computes the kitchens in which the user holds permission. A role assigned in
+// user_kitchens replaces the user's global role for that kitchen. allowed is false when
+// the permission is held neither globally nor in any kitchen.
+func (a *Authorizer) Resolve(userID, globalRole, permission string) (scope Scope, allowed bool, err error) {
+	roles, err := a.snapshot()
+	if err != nil {
+		return Scope{}, false, err
+	}
+	scope.Permission = permission
+	global := roles[globalRole]
+	if global.superuser {
+		scope.AllKitchens = true
+		return scope, true, nil
+	}
+
+	kitchens, err := a.source.LoadUserKitchens(userID)
+	if err != nil {
+		return Scope{}, false, err
+	}
+	for _, k := range kitchens {
+		g := global
+		if k.RoleName != nil && *k.RoleName != "" {
+			g = roles[*k.RoleName]
+		}
+		if g.allows(permission) {
+			scope.KitchenIDs = append(scope.KitchenIDs, k.KitchenID)
+		}
+	}
+	return scope, global.allows(permission) || len(scope.KitchenIDs) > 0, nil
+}
+
+// Require returns a middleware aborting with 403 unless the caller holds permission, through
+// their global role (set as "user_role" by the auth middleware) or a kitchen role. The kitchens
+// in which it is held are stored as the request's Scope for kitchen-bound handlers.
+func (a *Authorizer) Require(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("user_role")
+		roleName, _ := role.(string)
+		uid, _ := c.Get("identity")
+		userID, _ := uid.(string)
+
+		scope, allowed, err := a.Resolve(userID, roleName, permission)
+		if err != nil {
+			logger.From(c).Error("permission check failed", "permission", permission, "error", err)
+			apperr.Respond(c, apperr.Internal(err))
+			return
+		}
+		if !allowed {
+			logger.From(c).Warn("permission denied", "permission", permission, "role", roleName, "user_id", uid, "path", c.FullPath())
+			apperr.Respond(c, apperr.New(apperr.CodeForbidden).With("permission", permission))
+			return
+		}
+		if limit, ok := c.Get(PermissionLimitKey); ok && !contains(limit, permission) {
+			logger.From(c).Warn("permission outside API key scope", "permission", permission, "user_id", uid, "path", c.FullPath())
+			apperr.Respond(c, apperr.New(apperr.CodeAPIKeyNotScoped).With("permission", permission))
+			return
+		}
+		c.Set(ScopeKey, scope)
+		c.Next()
+	}
+}
+
+func contains(limit interface{}, permission string) bool {
+	keys, _ := limit.([]string)
+	for _, k := range keys {
+		if k == permission {
+			return true
+		}
+	}
+	return false
+}
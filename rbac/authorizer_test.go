@@ -0,0 +1,156 @@
+package rbac
+
+import (
+	"adong-be/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRoles struct {
+	roles    []models.Role
+	kitchens map[string][]models.UserKitchen
+	loads    int
+}
+
+func (f *fakeRoles) LoadRoles() ([]models.Role, error) {
+	f.loads++
+	return f.roles, nil
+}
+
+func (f *fakeRoles) LoadUserKitchens(userID string) ([]models.UserKitchen, error) {
+	return f.kitchens[userID], nil
+}
+
+func testRoles() *fakeRoles {
+	return &fakeRoles{roles: []models.Role{
+		{RoleName: "Admin", IsSuperuser: true},
+		{RoleName: "user", Permissions: []models.RolePermission{{PermissionKey: OrderRead}}},
+		{RoleName: "storekeeper", Permissions: []models.RolePermission{
+			{PermissionKey: InventoryImportRead}, {PermissionKey: InventoryImportApprove},
+		}},
+		{RoleName: "viewer", Permissions: []models.RolePermission{{PermissionKey: InventoryImportRead}}},
+	}, kitchens: map[string][]models.UserKitchen{
+		"u1": {
+			{UserID: "u1", KitchenID: "K001", RoleName: strPtr("storekeeper")},
+			{UserID: "u1", KitchenID: "K002", RoleName: strPtr("viewer")},
+			{UserID: "u1", KitchenID: "K003"},
+		},
+	}}
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestAuthorizerRequire(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authz := NewAuthorizer(testRoles(), time.Minute)
+
+	tests := []struct {
+		name       string
+		role       string
+		limit      []string
+		permission string
+		wantStatus int
+	}{
+		{"granted permission", "user", nil, OrderRead, http.StatusOK},
+		{"missing permission", "user", nil, InventoryImportApprove, http.StatusForbidden},
+		{"superuser has every permission", "Admin", nil, InventoryImportApprove, http.StatusOK},
+		{"unknown role", "guest", nil, OrderRead, http.StatusForbidden},
+		{"no role", "", nil, OrderRead, http.StatusForbidden},
+		{"API key scoped to the permission", "user", []string{OrderRead}, OrderRead, http.StatusOK},
+		{"API key not scoped to the permission", "Admin", []string{OrderRead}, InventoryImportApprove, http.StatusForbidden},
+		{"API key scope does not extend the role", "user", []string{InventoryImportApprove}, InventoryImportApprove, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/x", func(c *gin.Context) {
+				if tt.role != "" {
+					c.Set("user_role", tt.role)
+				}
+				if tt.limit != nil {
+					c.Set(PermissionLimitKey, tt.limit)
+				}
+				c.Next()
+			}, authz.Require(tt.permission), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x", nil))
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestAuthorizerResolveKitchenRoles(t *testing.T) {
+	authz := NewAuthorizer(testRoles(), time.Minute)
+
+	tests := []struct {
+		name         string
+		userID       string
+		role         string
+		permission   string
+		wantAllowed  bool
+		wantAll      bool
+		wantKitchens []string
+	}{
+		{"kitchen role grants approve in its kitchen only", "u1", "user", InventoryImportApprove, true, false, []string{"K001"}},
+		{"read through both kitchen roles", "u1", "user", InventoryImportRead, true, false, []string{"K001", "K002"}},
+		{"global role applies where no kitchen role is set", "u1", "user", OrderRead, true, false, []string{"K003"}},
+		{"permission held nowhere", "u1", "user", InventoryExportApprove, false, false, nil},
+		{"global permission without kitchens", "u2", "user", OrderRead, true, false, nil},
+		{"superuser covers every kitchen", "u2", "Admin", InventoryImportApprove, true, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope, allowed, err := authz.Resolve(tt.userID, tt.role, tt.permission)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantAllowed, allowed)
+			assert.Equal(t, tt.wantAll, scope.AllKitchens)
+			assert.Equal(t, tt.wantKitchens, scope.KitchenIDs)
+		})
+	}
+}
+
+func TestAuthorizerCache(t *testing.T) {
+	source := testRoles()
+	authz := NewAuthorizer(source, time.Minute)
+
+	assert.True(t, authz.RoleExists("user"))
+	assert.True(t, authz.RoleExists("Admin"))
+	assert.False(t, authz.RoleExists("guest"))
+	assert.Equal(t, 1, source.loads)
+
+	source.roles = append(source.roles, models.Role{RoleName: "guest"})
+	assert.False(t, authz.RoleExists("guest"), "cached roles are used until invalidated")
+
+	authz.Invalidate()
+	assert.True(t, authz.RoleExists("guest"))
+	assert.Equal(t, 2, source.loads)
+}
+
+func TestRouterDeclarations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	table := RouteTable{}
+	router := NewRouter(&r.RouterGroup, NewAuthorizer(testRoles(), time.Minute), table)
+
+	api := router.Group("/api")
+	api.GET("/orders", OrderRead, func(c *gin.Context) {})
+	api.GET("/orders/", OrderRead, func(c *gin.Context) {})
+	router.POST("/auth/login", Public, func(c *gin.Context) {})
+
+	assert.Equal(t, RouteTable{
+		"GET /api/orders":  OrderRead,
+		"GET /api/orders/": OrderRead,
+		"POST /auth/login": Public,
+	}, table)
+
+	assert.Panics(t, func() { api.GET("/missing", "", func(c *gin.Context) {}) })
+	assert.Panics(t, func() { api.GET("/typo", "order.raed", func(c *gin.Context) {}) })
+}
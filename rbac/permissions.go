@@ -0,0 +1,125 @@
+// Package rbac implements permission based access control. Permissions are defined in code
+// (every route declares the one it needs), roles are stored in the database as a set of
+// permissions and are manageable through the API.
+package rbac
+
+// Sentinel declarations for routes that don't require a permission
+const (
+	// Public routes are reachable without a token
+	Public = "public"
+	// Authenticated routes only require a valid token
+	Authenticated = "authenticated"
+)
+
+// Permissions checked by the API
+const (
+	IngredientRead  = "ingredient.read"
+	IngredientWrite = "ingredient.write"
+
+	KitchenRead  = "kitchen.read"
+	KitchenWrite = "kitchen.write"
+
+	UserRead   = "user.read"
+	UserWrite  = "user.write"
+	RoleManage = "role.manage"
+	AuditRead  = "audit.read"
+
+	ServiceAccountManage = "service_account.manage"
+
+	DishRead  = "dish.read"
+	DishWrite = "dish.write"
+
+	RecipeStandardRead  = "recipe_standard.read"
+	RecipeStandardWrite = "recipe_standard.write"
+
+	SupplierRead  = "supplier.read"
+	SupplierWrite = "supplier.write"
+
+	SupplierPriceRead  = "supplier_price.read"
+	SupplierPriceWrite = "supplier_price.write"
+	SupplierProductMap = "supplier_product.map"
+
+	SupplierSelectionRuleManage = "supplier_selection_rule.manage"
+
+	OrderRead   = "order.read"
+	OrderWrite  = "order.write"
+	OrderCancel = "order.cancel"
+
+	InventoryStockRead  = "inventory.stock.read"
+	InventoryStockWrite = "inventory.stock.write"
+
+	InventoryImportRead    = "inventory.import.read"
+	InventoryImportWrite   = "inventory.import.write"
+	InventoryImportApprove = "inventory.import.approve"
+
+	InventoryExportRead    = "inventory.export.read"
+	InventoryExportWrite   = "inventory.export.write"
+	InventoryExportApprove = "inventory.export.approve"
+
+	InventoryAdjustmentRead    = "inventory.adjustment.read"
+	InventoryAdjustmentWrite   = "inventory.adjustment.write"
+	InventoryAdjustmentApprove = "inventory.adjustment.approve"
+
+	InventoryRequestRead    = "inventory.request.read"
+	InventoryRequestWrite   = "inventory.request.write"
+	InventoryRequestApprove = "inventory.request.approve"
+
+	InventoryReportRead = "inventory.report.read"
+)
+
+// Permission describes one entry of the permission catalogue
+type Permission struct {
+	Key         string `json:"key"`
+	Description string `json:"description"`
+}
+
+// Catalogue lists every permission that can be granted to a role
+var Catalogue = []Permission{
+	{IngredientRead, "Xem nguyên liệu"},
+	{IngredientWrite, "Thêm, sửa, xóa nguyên liệu"},
+	{KitchenRead, "Xem bếp"},
+	{KitchenWrite, "Thêm, sửa, xóa bếp"},
+	{UserRead, "Xem người dùng"},
+	{UserWrite, "Thêm, sửa, xóa người dùng"},
+	{RoleManage, "Quản lý vai trò và quyền"},
+	{AuditRead, "Xem nhật ký thay đổi dữ liệu"},
+	{ServiceAccountManage, "Quản lý tài khoản dịch vụ và API key"},
+	{DishRead, "Xem món ăn"},
+	{DishWrite, "Thêm, sửa, xóa món ăn"},
+	{RecipeStandardRead, "Xem định mức"},
+	{RecipeStandardWrite, "Thêm, sửa, xóa định mức"},
+	{SupplierRead, "Xem nhà cung cấp"},
+	{SupplierWrite, "Thêm, sửa, xóa nhà cung cấp và lịch giao hàng"},
+	{SupplierPriceRead, "Xem bảng giá nhà cung cấp"},
+	{SupplierPriceWrite, "Thay đổi giá, bậc giá, giá hợp đồng và khuyến mãi"},
+	{SupplierProductMap, "Gán sản phẩm nhà cung cấp với nguyên liệu"},
+	{SupplierSelectionRuleManage, "Quản lý quy tắc chọn nhà cung cấp"},
+	{OrderRead, "Xem đơn hàng và gợi ý nhà cung cấp"},
+	{OrderWrite, "Tạo đơn hàng, chọn nhà cung cấp, đổi trạng thái"},
+	{OrderCancel, "Hủy đơn hàng"},
+	{InventoryStockRead, "Xem tồn kho"},
+	{InventoryStockWrite, "Thay đổi mức tồn kho"},
+	{InventoryImportRead, "Xem phiếu nhập"},
+	{InventoryImportWrite, "Tạo, sửa, xóa phiếu nhập"},
+	{InventoryImportApprove, "Duyệt phiếu nhập"},
+	{InventoryExportRead, "Xem phiếu xuất"},
+	{InventoryExportWrite, "Tạo, sửa, xóa phiếu xuất"},
+	{InventoryExportApprove, "Duyệt phiếu xuất"},
+	{InventoryAdjustmentRead, "Xem phiếu điều chỉnh"},
+	{InventoryAdjustmentWrite, "Tạo, sửa, xóa phiếu điều chỉnh"},
+	{InventoryAdjustmentApprove, "Duyệt phiếu điều chỉnh"},
+	{InventoryRequestRead, "Xem phiếu yêu cầu nguyên liệu"},
+	{InventoryRequestWrite, "Tạo, sửa, xóa phiếu yêu cầu nguyên liệu"},
+	{InventoryRequestApprove, "Duyệt phiếu yêu cầu nguyên liệu"},
+	{InventoryReportRead, "Xem báo cáo kho"},
+}
+
+// Known reports whether key is part of the permission catalogue
+func Known(key string) bool {
+	for _, p := range Catalogue {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package rbac
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteTable records the permission declared for every registered route, keyed by
+// "METHOD /full/path"
+type RouteTable map[string]string
+
+// Router registers routes on a gin group together with the permission they require.
+// Public and Authenticated add no check, any other permission is enforced with
+// Authorizer.Require in front of the handlers.
+type Router struct {
+	group *gin.RouterGroup
+	authz *Authorizer
+	table RouteTable
+}
+
+// NewRouter wraps group; declarations are recorded in table
+func NewRouter(group *gin.RouterGroup, authz *Authorizer, table RouteTable) *Router {
+	return &Router{group: group, authz: authz, table: table}
+}
+
+// Group creates a sub router sharing the same authorizer and table
+func (r *Router) Group(relativePath string, middleware ...gin.HandlerFunc) *Router {
+	return &Router{group: r.group.Group(relativePath, middleware...), authz: r.authz, table: r.table}
+}
+
+// Use adds middleware to the underlying group
+func (r *Router) Use(middleware ...gin.HandlerFunc) {
+	r.group.Use(middleware...)
+}
+
+func (r *Router) handle(method, relativePath, permission string, handlers []gin.HandlerFunc) {
+	if permission == "" {
+		panic("rbac: route " + method + " " + relativePath + " declares no permission")
+	}
+	if permission != Public && permission != Authenticated {
+		if !Known(permission) {
+			panic("rbac: route " + method + " " + relativePath + " declares unknown permission " + permission)
+		}
+		handlers = append([]gin.HandlerFunc{r.authz.Require(permission)}, handlers...)
+	}
+	r.group.Handle(method, relativePath, handlers...)
+	r.table[method+" "+joinPaths(r.group.BasePath(), relativePath)] = permission
+}
+
+func (r *Router) GET(relativePath, permission string, handlers ...gin.HandlerFunc) {
+	r.handle("GET", relativePath, permission, handlers)
+}
+
+func (r *Router) POST(relativePath, permission string, handlers ...gin.HandlerFunc) {
+	r.handle("POST", relativePath, permission, handlers)
+}
+
+func (r *Router) PUT(relativePath, permission string, handlers ...gin.HandlerFunc) {
+	r.handle("PUT", relativePath, permission, handlers)
+}
+
+func (r *Router) PATCH(relativePath, permission string, handlers ...gin.HandlerFunc) {
+	r.handle("PATCH", relativePath, permission, handlers)
+}
+
+func (r *Router) DELETE(relativePath, permission string, handlers ...gin.HandlerFunc) {
+	r.handle("DELETE", relativePath, permission, handlers)
+}
+
+// joinPaths mirrors how gin builds the full path of a route
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+	finalPath := path.Join(absolutePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
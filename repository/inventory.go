@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"time"
+)
+
+// Approval records who approved an inventory document and when
+type Approval struct {
+	UserID string
+	At     time.Time
+	// Version is the version of the document read before approving it, the approval
+	// increments it
+	Version int
+}
+
+// InventoryRepository stores stock levels, the transaction log and the documents moving
+// stock: imports, exports (including transfers) and count adjustments
+type InventoryRepository interface {
+	// Transaction runs fn with a repository whose changes are committed together when fn
+	// returns nil and discarded otherwise
+	Transaction(ctx context.Context, fn func(tx InventoryRepository) error) error
+
+	// GetImport, GetExport and GetAdjustment return a document with its details and the
+	// relations the approval responses show
+	GetImport(ctx context.Context, importID string) (*models.InventoryImport, error)
+	GetExport(ctx context.Context, exportID string) (*models.InventoryExport, error)
+	GetAdjustment(ctx context.Context, adjustmentID string) (*models.InventoryAdjustment, error)
+
+	// ApproveImport, ApproveExport and ApproveAdjustment set a document's status to
+	// approved and record the approval. They return ErrNotFound when the document is
+	// missing, already approved or no longer at approval.Version.
+	ApproveImport(ctx context.Context, importID string, approval Approval) error
+	ApproveExport(ctx context.Context, exportID string, approval Approval) error
+	ApproveAdjustment(ctx context.Context, adjustmentID string, approval Approval) error
+
+	// GetStock returns the stock of an ingredient in a kitchen, ErrNotFound when it was
+	// never stocked there
+	GetStock(ctx context.Context, kitchenID, ingredientID string) (*models.InventoryStock, error)
+	// LockStock returns the stock of an ingredient in a kitchen and locks it against other
+	// transactions until the current one ends. A missing stock is created empty in unit;
+	// created reports whether that happened.
+	LockStock(ctx context.Context, kitchenID, ingredientID, unit string) (stock *models.InventoryStock, created bool, err error)
+	// UpdateStock sets the quantity of a stock. An empty unit keeps the stock's unit.
+	UpdateStock(ctx context.Context, stockID int, quantity float64, unit string, at time.Time) error
+	// LogTransaction appends to the inventory transaction log
+	LogTransaction(ctx context.Context, transaction *models.InventoryTransaction) error
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// InventoryData seeds a MemoryInventory
+type InventoryData struct {
+	Imports     []models.InventoryImport
+	Exports     []models.InventoryExport
+	Adjustments []models.InventoryAdjustment
+	Stocks      []models.InventoryStock
+}
+
+type stockKey struct{ kitchenID, ingredientID string }
+
+// MemoryInventory keeps the inventory in process. Transactions run one at a time and are
+// rolled back by restoring a snapshot taken when they started.
+type MemoryInventory struct {
+	txMu sync.Mutex
+
+	mu           sync.Mutex
+	imports      map[string]models.InventoryImport
+	exports      map[string]models.InventoryExport
+	adjustments  map[string]models.InventoryAdjustment
+	stocks       map[stockKey]models.InventoryStock
+	transactions []models.InventoryTransaction
+	nextID       int
+}
+
+// NewMemoryInventory returns an inventory repository holding data
+func NewMemoryInventory(data InventoryData) *MemoryInventory {
+	m := &MemoryInventory{
+		imports:     make(map[string]models.InventoryImport),
+		exports:     make(map[string]models.InventoryExport),
+		adjustments: make(map[string]models.InventoryAdjustment),
+		stocks:      make(map[stockKey]models.InventoryStock),
+	}
+	for _, d := range data.Imports {
+		m.imports[d.ImportID] = d
+	}
+	for _, d := range data.Exports {
+		m.exports[d.ExportID] = d
+	}
+	for _, d := range data.Adjustments {
+		m.adjustments[d.AdjustmentID] = d
+	}
+	for _, s := range data.Stocks {
+		m.nextID++
+		s.StockID = m.nextID
+		m.stocks[stockKey{s.KitchenID, s.IngredientID}] = s
+	}
+	return m
+}
+
+// Transactions returns the transaction log in the order it was written
+func (m *MemoryInventory) Transactions() []models.InventoryTransaction {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.InventoryTransaction(nil), m.transactions...)
+}
+
+func (m *MemoryInventory) Transaction(ctx context.Context, fn func(tx InventoryRepository) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	// Details are never changed in place, so copying the maps is a full snapshot
+	m.mu.Lock()
+	imports, exports, adjustments := cloneMap(m.imports), cloneMap(m.exports), cloneMap(m.adjustments)
+	stocks, transactions := cloneMap(m.stocks), len(m.transactions)
+	m.mu.Unlock()
+
+	err := fn(m)
+	if err != nil {
+		m.mu.Lock()
+		m.imports, m.exports, m.adjustments, m.stocks = imports, exports, adjustments, stocks
+		m.transactions = m.transactions[:transactions]
+		m.mu.Unlock()
+	}
+	return err
+}
+
+func cloneMap[K comparable, V any](src map[K]V) map[K]V {
+	dst := make(map[K]V, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func (m *MemoryInventory) GetImport(ctx context.Context, importID string) (*models.InventoryImport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.imports[importID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	record.ImportDetails = append([]models.InventoryImportDetail(nil), record.ImportDetails...)
+	return &record, nil
+}
+
+func (m *MemoryInventory) GetExport(ctx context.Context, exportID string) (*models.InventoryExport, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.exports[exportID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	record.ExportDetails = append([]models.InventoryExportDetail(nil), record.ExportDetails...)
+	return &record, nil
+}
+
+func (m *MemoryInventory) GetAdjustment(ctx context.Context, adjustmentID string) (*models.InventoryAdjustment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.adjustments[adjustmentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	record.AdjustmentDetails = append([]models.InventoryAdjustmentDetail(nil), record.AdjustmentDetails...)
+	return &record, nil
+}
+
+func (m *MemoryInventory) ApproveImport(ctx context.Context, importID string, approval Approval) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.imports[importID]
+	if !ok || record.Status == "approved" || record.Version != approval.Version {
+		return ErrNotFound
+	}
+	record.Status, record.ApprovedByUserID, record.ApprovedDate = "approved", &approval.UserID, &approval.At
+	record.Version++
+	m.imports[importID] = record
+	return nil
+}
+
+func (m *MemoryInventory) ApproveExport(ctx context.Context, exportID string, approval Approval) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.exports[exportID]
+	if !ok || record.Status == "approved" || record.Version != approval.Version {
+		return ErrNotFound
+	}
+	record.Status, record.ApprovedByUserID, record.ApprovedDate = "approved", &approval.UserID, &approval.At
+	record.Version++
+	m.exports[exportID] = record
+	return nil
+}
+
+func (m *MemoryInventory) ApproveAdjustment(ctx context.Context, adjustmentID string, approval Approval) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	record, ok := m.adjustments[adjustmentID]
+	if !ok || record.Status == "approved" || record.Version != approval.Version {
+		return ErrNotFound
+	}
+	record.Status, record.ApprovedByUserID, record.ApprovedDate = "approved", &approval.UserID, &approval.At
+	record.Version++
+	m.adjustments[adjustmentID] = record
+	return nil
+}
+
+func (m *MemoryInventory) GetStock(ctx context.Context, kitchenID, ingredientID string) (*models.InventoryStock, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stock, ok := m.stocks[stockKey{kitchenID, ingredientID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &stock, nil
+}
+
+// LockStock needs no lock of its own: transactions already run one at a time
+func (m *MemoryInventory) LockStock(ctx context.Context, kitchenID, ingredientID, unit string) (*models.InventoryStock, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := stockKey{kitchenID, ingredientID}
+	stock, ok := m.stocks[key]
+	if !ok {
+		m.nextID++
+		now := time.Now()
+		stock = models.InventoryStock{StockID: m.nextID, KitchenID: kitchenID, IngredientID: ingredientID, Unit: unit,
+			LastUpdated: now, CreatedDate: now, ModifiedDate: now}
+		m.stocks[key] = stock
+	}
+	return &stock, !ok, nil
+}
+
+func (m *MemoryInventory) UpdateStock(ctx context.Context, stockID int, quantity float64, unit string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, stock := range m.stocks {
+		if stock.StockID != stockID {
+			continue
+		}
+		stock.Quantity = quantity
+		if unit != "" {
+			stock.Unit = unit
+		}
+		stock.LastUpdated, stock.ModifiedDate = at, at
+		m.stocks[key] = stock
+		return nil
+	}
+	return ErrNotFound
+}
+
+func (m *MemoryInventory) LogTransaction(ctx context.Context, transaction *models.InventoryTransaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	transaction.TransactionID = m.nextID
+	transaction.CreatedDate = transaction.TransactionDate
+	m.transactions = append(m.transactions, *transaction)
+	return nil
+}
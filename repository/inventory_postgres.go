@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PostgresInventory is the InventoryRepository of the server
+type PostgresInventory struct {
+	DB *gorm.DB
+}
+
+// NewPostgresInventory returns an inventory repository over db
+func NewPostgresInventory(db *gorm.DB) *PostgresInventory {
+	return &PostgresInventory{DB: db}
+}
+
+func (r *PostgresInventory) Transaction(ctx context.Context, fn func(tx InventoryRepository) error) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&PostgresInventory{DB: tx})
+	})
+}
+
+func (r *PostgresInventory) GetImport(ctx context.Context, importID string) (*models.InventoryImport, error) {
+	var record models.InventoryImport
+	err := r.DB.WithContext(ctx).Preload("Kitchen").
+		Preload("Supplier").
+		Preload("ApprovedBy").
+		Preload("ImportDetails.Ingredient").
+		First(&record, "import_id = ?", importID).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &record, nil
+}
+
+func (r *PostgresInventory) GetExport(ctx context.Context, exportID string) (*models.InventoryExport, error) {
+	var record models.InventoryExport
+	err := r.DB.WithContext(ctx).Preload("Kitchen").
+		Preload("DestinationKitchen").
+		Preload("ApprovedBy").
+		Preload("ExportDetails.Ingredient").
+		First(&record, "export_id = ?", exportID).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &record, nil
+}
+
+func (r *PostgresInventory) GetAdjustment(ctx context.Context, adjustmentID string) (*models.InventoryAdjustment, error) {
+	var record models.InventoryAdjustment
+	err := r.DB.WithContext(ctx).Preload("Kitchen").
+		Preload("ApprovedBy").
+		Preload("AdjustmentDetails.Ingredient").
+		First(&record, "adjustment_id = ?", adjustmentID).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &record, nil
+}
+
+// approve marks the document matching where as approved unless it already is or changed
+// since it was read. A concurrent approval or change of the same document waits for the row
+// and then finds it approved or at another version.
+func (r *PostgresInventory) approve(ctx context.Context, model any, where string, id string, approval Approval) error {
+	result := r.DB.WithContext(ctx).Model(model).Where(where, id).Where("status <> ? AND version = ?", "approved", approval.Version).Updates(map[string]interface{}{
+		"status":              "approved",
+		"approved_by_user_id": approval.UserID,
+		"approved_date":       approval.At,
+		"version":             gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresInventory) ApproveImport(ctx context.Context, importID string, approval Approval) error {
+	return r.approve(ctx, &models.InventoryImport{}, "import_id = ?", importID, approval)
+}
+
+func (r *PostgresInventory) ApproveExport(ctx context.Context, exportID string, approval Approval) error {
+	return r.approve(ctx, &models.InventoryExport{}, "export_id = ?", exportID, approval)
+}
+
+func (r *PostgresInventory) ApproveAdjustment(ctx context.Context, adjustmentID string, approval Approval) error {
+	return r.approve(ctx, &models.InventoryAdjustment{}, "adjustment_id = ?", adjustmentID, approval)
+}
+
+func (r *PostgresInventory) GetStock(ctx context.Context, kitchenID, ingredientID string) (*models.InventoryStock, error) {
+	var stock models.InventoryStock
+	if err := r.DB.WithContext(ctx).Where("kitchen_id = ? AND ingredient_id = ?", kitchenID, ingredientID).
+		First(&stock).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &stock, nil
+}
+
+func (r *PostgresInventory) LockStock(ctx context.Context, kitchenID, ingredientID, unit string) (*models.InventoryStock, bool, error) {
+	// Inserting first means there is always a row to lock: concurrent postings of a new
+	// stock wait on the unique constraint instead of both creating it
+	result := r.DB.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "kitchen_id"}, {Name: "ingredient_id"}},
+		DoNothing: true,
+	}).Create(&models.InventoryStock{KitchenID: kitchenID, IngredientID: ingredientID, Unit: unit})
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+
+	var stock models.InventoryStock
+	if err := r.DB.WithContext(ctx).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("kitchen_id = ? AND ingredient_id = ?", kitchenID, ingredientID).
+		First(&stock).Error; err != nil {
+		return nil, false, notFound(err)
+	}
+	return &stock, result.RowsAffected > 0, nil
+}
+
+func (r *PostgresInventory) UpdateStock(ctx context.Context, stockID int, quantity float64, unit string, at time.Time) error {
+	updates := map[string]interface{}{
+		"quantity":     quantity,
+		"last_updated": at,
+	}
+	if unit != "" {
+		updates["unit"] = unit
+	}
+	result := r.DB.WithContext(ctx).Model(&models.InventoryStock{}).Where("stock_id = ?", stockID).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresInventory) LogTransaction(ctx context.Context, transaction *models.InventoryTransaction) error {
+	return r.DB.WithContext(ctx).Create(transaction).Error
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder is a GORM logger keeping the SQL of every statement
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// dryRunDB builds SQL without connecting and records it. Writes skip the default
+// transaction, which would connect.
+func dryRunDB(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN: "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable",
+	}), &gorm.Config{DisableAutomaticPing: true, DryRun: true, SkipDefaultTransaction: true, Logger: recorder})
+	require.NoError(t, err)
+	return db, recorder
+}
+
+func TestPostgresInventoryLockStock(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	_, _, err := NewPostgresInventory(db).LockStock(context.Background(), "K1", "RAU", "kg")
+	require.NoError(t, err)
+
+	require.Len(t, recorder.statements, 2)
+	assert.Contains(t, recorder.statements[0], `INSERT INTO "inventory_stocks"`)
+	assert.Contains(t, recorder.statements[0], `ON CONFLICT ("kitchen_id","ingredient_id") DO NOTHING`)
+	assert.Contains(t, recorder.statements[1], `WHERE kitchen_id = 'K1' AND ingredient_id = 'RAU'`)
+	assert.True(t, strings.HasSuffix(recorder.statements[1], "FOR UPDATE"), recorder.statements[1])
+}
+
+func TestPostgresInventoryApproveOnlyDrafts(t *testing.T) {
+	db, recorder := dryRunDB(t)
+
+	// A dry run affects no rows, which reads as already approved or changed
+	err := NewPostgresInventory(db).ApproveExport(context.Background(), "XK1", Approval{UserID: "U1", At: time.Now(), Version: 4})
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.Len(t, recorder.statements, 1)
+	assert.Contains(t, recorder.statements[0], `status <> 'approved' AND version = 4`)
+	assert.Contains(t, recorder.statements[0], `"version"=version + 1`)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"adong-be/models"
+	"sync"
+)
+
+// memoryTable is the in-memory store behind the CRUD repositories, rows keyed by their ID
+type memoryTable[T any] struct {
+	mu   sync.Mutex
+	key  func(T) string
+	rows map[string]T
+}
+
+func newMemoryTable[T any](key func(T) string, rows []T) *memoryTable[T] {
+	t := &memoryTable[T]{key: key, rows: make(map[string]T, len(rows))}
+	for _, row := range rows {
+		t.rows[key(row)] = row
+	}
+	return t
+}
+
+// list returns the page of rows for which match holds and their total. Without a known
+// sort key rows are ordered by ID so pages are stable.
+func (t *memoryTable[T]) list(params models.PaginationParams, match func(T) bool, sortKeys map[string]func(T) string) ([]T, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	items := []T{}
+	for _, row := range t.rows {
+		if match(row) {
+			items = append(items, row)
+		}
+	}
+	total := int64(len(items))
+	if _, ok := sortKeys[params.SortBy]; !ok {
+		params.SortBy, params.SortDir = "", "asc"
+		sortKeys = map[string]func(T) string{"": t.key}
+	}
+	return sortAndPage(items, params, sortKeys), total
+}
+
+func (t *memoryTable[T]) get(id string) (T, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[id]
+	if !ok {
+		return row, ErrNotFound
+	}
+	return row, nil
+}
+
+func (t *memoryTable[T]) create(row T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[t.key(row)]; ok {
+		return ErrDuplicate
+	}
+	t.rows[t.key(row)] = row
+	return nil
+}
+
+func (t *memoryTable[T]) update(row T) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.rows[t.key(row)]; !ok {
+		return ErrNotFound
+	}
+	t.rows[t.key(row)] = row
+	return nil
+}
+
+func (t *memoryTable[T]) delete(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.rows, id)
+}
@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+)
+
+// OrderFilter narrows an order list. Dates are YYYY-MM-DD and inclusive.
+type OrderFilter struct {
+	Kitchens     Kitchens
+	Status       string
+	FromDate     string
+	ToDate       string
+	DishID       string
+	IngredientID string
+}
+
+// OrderRepository stores orders with their dish details, the ingredients calculated for
+// each detail and the supplementary foods
+type OrderRepository interface {
+	// List returns one page of the orders matching filter, with their relations, and the
+	// number of matching orders
+	List(ctx context.Context, filter OrderFilter, params models.PaginationParams) ([]models.Order, int64, error)
+	// Get returns the order with its relations
+	Get(ctx context.Context, orderID string) (*models.Order, error)
+	// Create stores order with its details, ingredients and supplementary foods in one
+	// transaction, filling in their generated IDs
+	Create(ctx context.Context, order *models.Order) error
+	// UpdateStatus sets the status of the order at version and increments the version. It
+	// returns ErrNotFound when the order is missing or no longer at version.
+	UpdateStatus(ctx context.Context, orderID, status string, version int) error
+	Delete(ctx context.Context, orderID string) error
+}
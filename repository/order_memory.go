@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryOrders keeps orders in process. Relations other than the children are not loaded.
+type MemoryOrders struct {
+	mu     sync.Mutex
+	orders map[string]models.Order
+	nextID int
+}
+
+// NewMemoryOrders returns an order repository holding orders
+func NewMemoryOrders(orders ...models.Order) *MemoryOrders {
+	m := &MemoryOrders{orders: make(map[string]models.Order)}
+	for _, o := range orders {
+		m.orders[o.OrderID] = copyOrder(o)
+	}
+	return m
+}
+
+// copyOrder copies o down to its ingredients, so callers never share slices with the store
+func copyOrder(o models.Order) models.Order {
+	details := make([]models.OrderDetail, len(o.Details))
+	for i, d := range o.Details {
+		d.Ingredients = append([]models.OrderIngredient(nil), d.Ingredients...)
+		details[i] = d
+	}
+	o.Details = details
+	o.SupplementaryFoods = append([]models.OrderSupplementaryFood(nil), o.SupplementaryFoods...)
+	return o
+}
+
+func (m *MemoryOrders) matches(o models.Order, filter OrderFilter, search string) bool {
+	if !containsFold(search, o.Note, o.OrderID) || !filter.Kitchens.Contains(o.KitchenID) {
+		return false
+	}
+	if filter.Status != "" && o.Status != filter.Status {
+		return false
+	}
+	date := o.OrderDate
+	if len(date) > len("2006-01-02") {
+		date = date[:len("2006-01-02")]
+	}
+	if (filter.FromDate != "" && date < filter.FromDate) || (filter.ToDate != "" && date > filter.ToDate) {
+		return false
+	}
+	if filter.DishID == "" && filter.IngredientID == "" {
+		return true
+	}
+	dish, ingredient := filter.DishID == "", filter.IngredientID == ""
+	for _, d := range o.Details {
+		dish = dish || d.DishID == filter.DishID
+		for _, ing := range d.Ingredients {
+			ingredient = ingredient || ing.IngredientID == filter.IngredientID
+		}
+	}
+	return dish && ingredient
+}
+
+func (m *MemoryOrders) List(ctx context.Context, filter OrderFilter, params models.PaginationParams) ([]models.Order, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var orders []models.Order
+	for _, o := range m.orders {
+		if m.matches(o, filter, params.Search) {
+			orders = append(orders, copyOrder(o))
+		}
+	}
+	total := int64(len(orders))
+	if params.SortBy == "" {
+		params.SortBy, params.SortDir = "order_id", "asc"
+	}
+	orders = sortAndPage(orders, params, map[string]func(models.Order) string{
+		"order_id":     func(o models.Order) string { return o.OrderID },
+		"order_date":   func(o models.Order) string { return o.OrderDate },
+		"status":       func(o models.Order) string { return o.Status },
+		"created_date": func(o models.Order) string { return o.CreatedDate.Format(time.RFC3339Nano) },
+	})
+	return orders, total, nil
+}
+
+func (m *MemoryOrders) Get(ctx context.Context, orderID string) (*models.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.orders[orderID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	o = copyOrder(o)
+	return &o, nil
+}
+
+func (m *MemoryOrders) Create(ctx context.Context, order *models.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.orders[order.OrderID]; ok {
+		return ErrDuplicate
+	}
+	now := time.Now()
+	if order.Status == "" {
+		order.Status = "Pending"
+	}
+	order.CreatedDate, order.ModifiedDate, order.Version = now, now, 1
+	for i := range order.Details {
+		m.nextID++
+		d := &order.Details[i]
+		d.OrderID, d.OrderDetailID = order.OrderID, m.nextID
+		for j := range d.Ingredients {
+			m.nextID++
+			d.Ingredients[j].OrderDetailID, d.Ingredients[j].OrderIngredientID = d.OrderDetailID, m.nextID
+		}
+	}
+	for i := range order.SupplementaryFoods {
+		m.nextID++
+		order.SupplementaryFoods[i].OrderID, order.SupplementaryFoods[i].SupplementaryID = order.OrderID, m.nextID
+	}
+	m.orders[order.OrderID] = copyOrder(*order)
+	return nil
+}
+
+func (m *MemoryOrders) UpdateStatus(ctx context.Context, orderID, status string, version int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	o, ok := m.orders[orderID]
+	if !ok || o.Version != version {
+		return ErrNotFound
+	}
+	o.Status, o.ModifiedDate = status, time.Now()
+	o.Version++
+	m.orders[orderID] = o
+	return nil
+}
+
+func (m *MemoryOrders) Delete(ctx context.Context, orderID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.orders, orderID)
+	return nil
+}
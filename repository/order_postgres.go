@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"adong-be/models"
+	"adong-be/utils"
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresOrders is the OrderRepository of the server
+type PostgresOrders struct {
+	DB *gorm.DB
+}
+
+// NewPostgresOrders returns an order repository over db
+func NewPostgresOrders(db *gorm.DB) *PostgresOrders {
+	return &PostgresOrders{DB: db}
+}
+
+// withRelations loads what the order DTOs show
+func (r *PostgresOrders) withRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Kitchen").
+		Preload("CreatedBy").
+		Preload("Details.Dish").
+		Preload("Details.Ingredients.Ingredient").
+		Preload("SupplementaryFoods.Ingredient")
+}
+
+func (r *PostgresOrders) filter(ctx context.Context, filter OrderFilter, search string) *gorm.DB {
+	db := r.DB.WithContext(ctx).Model(&models.Order{})
+	if search != "" {
+		db = db.Where("note ILIKE ? OR order_id ILIKE ?", "%"+search+"%", "%"+search+"%")
+	}
+	db = whereKitchens(db, "orders.kitchen_id", filter.Kitchens)
+	if filter.Status != "" {
+		db = db.Where("status = ?", filter.Status)
+	}
+	if filter.FromDate != "" {
+		if t, err := time.Parse("2006-01-02", filter.FromDate); err == nil {
+			db = db.Where("order_date >= ?", t)
+		} else {
+			db = db.Where("order_date >= ?", filter.FromDate)
+		}
+	}
+	if filter.ToDate != "" {
+		if t, err := time.Parse("2006-01-02", filter.ToDate); err == nil {
+			db = db.Where("order_date < ?", t.Add(24*time.Hour))
+		} else {
+			db = db.Where("order_date <= ?", filter.ToDate)
+		}
+	}
+	if filter.DishID != "" {
+		db = db.Joins("JOIN order_details od ON od.order_id = orders.order_id").Where("od.dish_id = ?", filter.DishID)
+	}
+	if filter.IngredientID != "" {
+		db = db.Joins("JOIN order_details od2 ON od2.order_id = orders.order_id").
+			Joins("JOIN order_ingredients oi ON oi.order_detail_id = od2.order_detail_id").
+			Where("oi.ingredient_id = ?", filter.IngredientID)
+	}
+	return db
+}
+
+func (r *PostgresOrders) List(ctx context.Context, filter OrderFilter, params models.PaginationParams) ([]models.Order, int64, error) {
+	var total int64
+	if err := r.filter(ctx, filter, params.Search).Distinct("orders.order_id").Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	dataDB := r.filter(ctx, filter, params.Search)
+	if filter.DishID != "" || filter.IngredientID != "" {
+		dataDB = dataDB.Distinct("orders.order_id")
+	}
+	allowedSort := map[string]string{
+		"order_id":     "orders.order_id",
+		"order_date":   "orders.order_date",
+		"status":       "orders.status",
+		"created_date": "orders.created_date",
+	}
+	dataDB = utils.ApplySort(dataDB, params.SortBy, params.SortDir, allowedSort)
+	dataDB = utils.ApplyPagination(dataDB, params.Page, params.PageSize)
+
+	var orders []models.Order
+	if err := r.withRelations(dataDB.Select("orders.*")).Find(&orders).Error; err != nil {
+		return nil, 0, err
+	}
+	return orders, total, nil
+}
+
+func (r *PostgresOrders) Get(ctx context.Context, orderID string) (*models.Order, error) {
+	var order models.Order
+	if err := r.withRelations(r.DB.WithContext(ctx)).First(&order, "order_id = ?", orderID).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &order, nil
+}
+
+func (r *PostgresOrders) Create(ctx context.Context, order *models.Order) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The children are created one by one so each detail's ID reaches its ingredients
+		details, supplementaryFoods := order.Details, order.SupplementaryFoods
+		order.Details, order.SupplementaryFoods = nil, nil
+		defer func() { order.Details, order.SupplementaryFoods = details, supplementaryFoods }()
+
+		if err := tx.Create(order).Error; err != nil {
+			return err
+		}
+		for i := range details {
+			details[i].OrderID = order.OrderID
+			details[i].OrderDetailID = 0
+			ingredients := details[i].Ingredients
+			details[i].Ingredients = nil
+			err := tx.Create(&details[i]).Error
+			details[i].Ingredients = ingredients
+			if err != nil {
+				return err
+			}
+			for j := range ingredients {
+				ingredients[j].OrderDetailID = details[i].OrderDetailID
+				ingredients[j].OrderIngredientID = 0
+				if err := tx.Create(&ingredients[j]).Error; err != nil {
+					return err
+				}
+			}
+		}
+		for i := range supplementaryFoods {
+			supplementaryFoods[i].OrderID = order.OrderID
+			supplementaryFoods[i].SupplementaryID = 0
+			if err := tx.Create(&supplementaryFoods[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *PostgresOrders) UpdateStatus(ctx context.Context, orderID, status string, version int) error {
+	result := r.DB.WithContext(ctx).Model(&models.Order{}).Where("order_id = ? AND version = ?", orderID, version).Updates(map[string]interface{}{
+		"status":  status,
+		"version": gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *PostgresOrders) Delete(ctx context.Context, orderID string) error {
+	return r.DB.WithContext(ctx).Delete(&models.Order{}, "order_id = ?", orderID).Error
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrResetTokenInvalid is returned for an unknown, used or expired password reset token
+var ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+
+// PasswordRepository stores the password hashes of users and their single-use password
+// reset tokens. Only the SHA-256 hash of a reset token is stored.
+type PasswordRepository interface {
+	GetUser(ctx context.Context, userID string) (*models.User, error)
+	// FindUserByLogin looks a user up by email when login contains "@", by user name otherwise
+	FindUserByLogin(ctx context.Context, login string) (*models.User, error)
+	// CountResetsSince counts the reset tokens issued to a user since t
+	CountResetsSince(ctx context.Context, userID string, t time.Time) (int64, error)
+	// CreateResetToken stores a new reset token; the user's outstanding tokens stop working
+	CreateResetToken(ctx context.Context, token *models.PasswordResetToken) error
+	// FindResetToken returns the token stored under hash if it can still be used at now
+	FindResetToken(ctx context.Context, hash string, now time.Time) (*models.PasswordResetToken, error)
+	// ResetPassword consumes the token stored under tokenHash and sets the password of its
+	// user, whose ID it returns. Of concurrent calls with one token only one succeeds.
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (string, error)
+	// SetPassword stores a new password hash for the user, drops a legacy plain text copy
+	// and invalidates the user's reset tokens
+	SetPassword(ctx context.Context, userID, passwordHash string, now time.Time) error
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryPasswords keeps users and their reset tokens in process
+type MemoryPasswords struct {
+	mu     sync.Mutex
+	users  map[string]models.User
+	tokens []models.PasswordResetToken
+}
+
+// NewMemoryPasswords returns a password repository holding users
+func NewMemoryPasswords(users []models.User) *MemoryPasswords {
+	m := &MemoryPasswords{users: make(map[string]models.User, len(users))}
+	for _, u := range users {
+		m.users[u.UserID] = u
+	}
+	return m
+}
+
+func (m *MemoryPasswords) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *MemoryPasswords) FindUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	byEmail := strings.Contains(login, "@")
+	for _, user := range m.users {
+		if byEmail && strings.EqualFold(user.Email, login) || !byEmail && user.UserName == login {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *MemoryPasswords) CountResetsSince(ctx context.Context, userID string, t time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var count int64
+	for _, token := range m.tokens {
+		if token.UserID == userID && !token.CreatedDate.Before(t) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryPasswords) CreateResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.expireResetTokens(token.UserID, now)
+	token.TokenID = int64(len(m.tokens) + 1)
+	if token.CreatedDate.IsZero() {
+		token.CreatedDate = now
+	}
+	m.tokens = append(m.tokens, *token)
+	return nil
+}
+
+func (m *MemoryPasswords) FindResetToken(ctx context.Context, hash string, now time.Time) (*models.PasswordResetToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.usableToken(hash, now)
+	if i < 0 {
+		return nil, ErrResetTokenInvalid
+	}
+	token := m.tokens[i]
+	return &token, nil
+}
+
+func (m *MemoryPasswords) ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.usableToken(tokenHash, now)
+	if i < 0 {
+		return "", ErrResetTokenInvalid
+	}
+	m.tokens[i].UsedAt = &now
+	userID := m.tokens[i].UserID
+	return userID, m.setPassword(userID, passwordHash, now)
+}
+
+func (m *MemoryPasswords) SetPassword(ctx context.Context, userID, passwordHash string, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.setPassword(userID, passwordHash, now)
+}
+
+// usableToken returns the index of the unused, unexpired token stored under hash, or -1
+func (m *MemoryPasswords) usableToken(hash string, now time.Time) int {
+	for i, token := range m.tokens {
+		if token.TokenHash == hash && token.UsedAt == nil && token.ExpiresAt.After(now) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *MemoryPasswords) setPassword(userID, passwordHash string, now time.Time) error {
+	user, ok := m.users[userID]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Password, user.PlainPassword = passwordHash, ""
+	m.users[userID] = user
+	m.expireResetTokens(userID, now)
+	return nil
+}
+
+func (m *MemoryPasswords) expireResetTokens(userID string, now time.Time) {
+	for i := range m.tokens {
+		if m.tokens[i].UserID == userID && m.tokens[i].UsedAt == nil {
+			m.tokens[i].UsedAt = &now
+		}
+	}
+}
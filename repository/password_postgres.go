@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresPasswords is the PasswordRepository of the server
+type PostgresPasswords struct {
+	DB *gorm.DB
+}
+
+// NewPostgresPasswords returns a password repository over db
+func NewPostgresPasswords(db *gorm.DB) *PostgresPasswords {
+	return &PostgresPasswords{DB: db}
+}
+
+func (r *PostgresPasswords) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := r.DB.WithContext(ctx).First(&user, "user_id = ?", userID).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *PostgresPasswords) FindUserByLogin(ctx context.Context, login string) (*models.User, error) {
+	var user models.User
+	db := r.DB.WithContext(ctx)
+	if strings.Contains(login, "@") {
+		db = db.Where("LOWER(email) = LOWER(?)", login)
+	} else {
+		db = db.Where("user_name = ?", login)
+	}
+	if err := db.First(&user).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *PostgresPasswords) CountResetsSince(ctx context.Context, userID string, t time.Time) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(ctx).Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND created_date >= ?", userID, t).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *PostgresPasswords) CreateResetToken(ctx context.Context, token *models.PasswordResetToken) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := expireResetTokens(tx, token.UserID, time.Now()); err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+func (r *PostgresPasswords) FindResetToken(ctx context.Context, hash string, now time.Time) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.DB.WithContext(ctx).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+		First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *PostgresPasswords) ResetPassword(ctx context.Context, tokenHash, passwordHash string, now time.Time) (string, error) {
+	var userID string
+	err := r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token models.PasswordResetToken
+		// Claiming the token with a conditional update makes it single-use under concurrency
+		res := tx.Model(&token).
+			Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", tokenHash, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrResetTokenInvalid
+		}
+		if err := tx.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+			return err
+		}
+		userID = token.UserID
+		return setPassword(tx, userID, passwordHash, now)
+	})
+	return userID, err
+}
+
+func (r *PostgresPasswords) SetPassword(ctx context.Context, userID, passwordHash string, now time.Time) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return setPassword(tx, userID, passwordHash, now)
+	})
+}
+
+func setPassword(tx *gorm.DB, userID, passwordHash string, now time.Time) error {
+	res := tx.Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"password": passwordHash, "plain_password": nil})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return expireResetTokens(tx, userID, now)
+}
+
+func expireResetTokens(tx *gorm.DB, userID string, now time.Time) error {
+	return tx.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", now).Error
+}
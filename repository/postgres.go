@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"adong-be/models"
+	"adong-be/utils"
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// notFound maps GORM's missing record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// whereKitchens restricts db to k on column
+func whereKitchens(db *gorm.DB, column string, k Kitchens) *gorm.DB {
+	if k.All {
+		return db
+	}
+	if len(k.IDs) == 0 {
+		return db.Where("1 = 0")
+	}
+	if len(k.IDs) == 1 {
+		return db.Where(column+" = ?", k.IDs[0])
+	}
+	return db.Where(column+" IN ?", k.IDs)
+}
+
+// listPage runs the paginated, searchable list query the CRUD screens share: the total of
+// rows matching search and the requested page sorted by one of sortFields
+func listPage[T any](ctx context.Context, db *gorm.DB, params models.PaginationParams, search utils.SearchConfig, sortFields map[string]string) ([]T, int64, error) {
+	var model T
+	var total int64
+	if err := utils.ApplySearch(db.WithContext(ctx).Model(&model), params.Search, search).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query := utils.ApplySearch(db.WithContext(ctx).Model(&model), params.Search, search)
+	query = utils.ApplySort(query, params.SortBy, params.SortDir, sortFields)
+	query = utils.ApplyPagination(query, params.Page, params.PageSize)
+	var items []T
+	if err := query.Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
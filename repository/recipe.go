@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+)
+
+// RecipeRepository stores dishes and their recipe standards, the ingredients a portion
+// of a dish needs in a kitchen
+type RecipeRepository interface {
+	// ListDishes returns one page of the dishes matching params.Search and their total
+	ListDishes(ctx context.Context, params models.PaginationParams) ([]models.Dish, int64, error)
+	GetDish(ctx context.Context, dishID string) (*models.Dish, error)
+	CreateDish(ctx context.Context, dish *models.Dish) error
+	UpdateDish(ctx context.Context, dish *models.Dish) error
+	DeleteDish(ctx context.Context, dishID string) error
+	// ListStandards returns one page of the recipe standards of a dish in kitchens, with
+	// their relations, whose ingredient matches params.Search, and their total
+	ListStandards(ctx context.Context, dishID string, kitchens Kitchens, params models.PaginationParams) ([]models.RecipeStandard, int64, error)
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MemoryRecipes keeps dishes and recipe standards in process
+type MemoryRecipes struct {
+	dishes *memoryTable[models.Dish]
+
+	mu        sync.Mutex
+	standards []models.RecipeStandard
+}
+
+// NewMemoryRecipes returns a recipe repository holding dishes and standards
+func NewMemoryRecipes(dishes []models.Dish, standards []models.RecipeStandard) *MemoryRecipes {
+	return &MemoryRecipes{
+		dishes:    newMemoryTable(func(d models.Dish) string { return d.DishID }, dishes),
+		standards: append([]models.RecipeStandard(nil), standards...),
+	}
+}
+
+func (m *MemoryRecipes) ListDishes(ctx context.Context, params models.PaginationParams) ([]models.Dish, int64, error) {
+	items, total := m.dishes.list(params, func(d models.Dish) bool {
+		return containsFold(params.Search, d.DishName, d.DishID, d.Description)
+	}, map[string]func(models.Dish) string{
+		"dish_id":        func(d models.Dish) string { return d.DishID },
+		"dish_name":      func(d models.Dish) string { return d.DishName },
+		"cooking_method": func(d models.Dish) string { return d.CookingMethod },
+		"category":       func(d models.Dish) string { return d.Group },
+		"created_date":   func(d models.Dish) string { return d.CreatedDate.Format(time.RFC3339Nano) },
+	})
+	return items, total, nil
+}
+
+func (m *MemoryRecipes) GetDish(ctx context.Context, dishID string) (*models.Dish, error) {
+	dish, err := m.dishes.get(dishID)
+	if err != nil {
+		return nil, err
+	}
+	return &dish, nil
+}
+
+func (m *MemoryRecipes) CreateDish(ctx context.Context, dish *models.Dish) error {
+	dish.CreatedDate, dish.ModifiedDate = time.Now(), time.Now()
+	return m.dishes.create(*dish)
+}
+
+func (m *MemoryRecipes) UpdateDish(ctx context.Context, dish *models.Dish) error {
+	dish.ModifiedDate = time.Now()
+	return m.dishes.update(*dish)
+}
+
+func (m *MemoryRecipes) DeleteDish(ctx context.Context, dishID string) error {
+	m.dishes.delete(dishID)
+	return nil
+}
+
+func (m *MemoryRecipes) ListStandards(ctx context.Context, dishID string, kitchens Kitchens, params models.PaginationParams) ([]models.RecipeStandard, int64, error) {
+	m.mu.Lock()
+	items := []models.RecipeStandard{}
+	for _, s := range m.standards {
+		if s.DishID == dishID && kitchens.Contains(s.KitchenID) && containsFold(params.Search, s.IngredientID) {
+			items = append(items, s)
+		}
+	}
+	m.mu.Unlock()
+
+	total := int64(len(items))
+	if params.SortBy != "ingredientId" && params.SortBy != "standardPer1" {
+		params.SortBy, params.SortDir = "ingredientId", "asc"
+	}
+	items = sortAndPage(items, params, map[string]func(models.RecipeStandard) string{
+		"ingredientId": func(s models.RecipeStandard) string { return s.IngredientID },
+		"standardPer1": func(s models.RecipeStandard) string {
+			// Zero padded so the text order is the numeric order
+			return fmt.Sprintf("%020.4f", s.StandardPer1)
+		},
+	})
+	return items, total, nil
+}
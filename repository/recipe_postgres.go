@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"adong-be/models"
+	"adong-be/utils"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// PostgresRecipes is the RecipeRepository of the server
+type PostgresRecipes struct {
+	DB *gorm.DB
+}
+
+// NewPostgresRecipes returns a recipe repository over db
+func NewPostgresRecipes(db *gorm.DB) *PostgresRecipes {
+	return &PostgresRecipes{DB: db}
+}
+
+func (r *PostgresRecipes) ListDishes(ctx context.Context, params models.PaginationParams) ([]models.Dish, int64, error) {
+	return listPage[models.Dish](ctx, r.DB, params, utils.SearchConfig{
+		Fields: []string{"dish_name", "dish_id", "description"},
+		Fuzzy:  true,
+	}, map[string]string{
+		"dish_id":        "dish_id",
+		"dish_name":      "dish_name",
+		"cooking_method": "cooking_method",
+		"category":       "category",
+		"created_date":   "created_date",
+	})
+}
+
+func (r *PostgresRecipes) GetDish(ctx context.Context, dishID string) (*models.Dish, error) {
+	var dish models.Dish
+	if err := r.DB.WithContext(ctx).First(&dish, "dish_id = ?", dishID).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &dish, nil
+}
+
+func (r *PostgresRecipes) CreateDish(ctx context.Context, dish *models.Dish) error {
+	return r.DB.WithContext(ctx).Create(dish).Error
+}
+
+func (r *PostgresRecipes) UpdateDish(ctx context.Context, dish *models.Dish) error {
+	return r.DB.WithContext(ctx).Save(dish).Error
+}
+
+func (r *PostgresRecipes) DeleteDish(ctx context.Context, dishID string) error {
+	return r.DB.WithContext(ctx).Delete(&models.Dish{}, "dish_id = ?", dishID).Error
+}
+
+func (r *PostgresRecipes) ListStandards(ctx context.Context, dishID string, kitchens Kitchens, params models.PaginationParams) ([]models.RecipeStandard, int64, error) {
+	// Preload related entities to get names
+	db := whereKitchens(r.DB.Where("dish_id = ?", dishID), "kitchen_id", kitchens).
+		Preload("Dish").Preload("Kitchen").Preload("Ingredient").Preload("UpdatedBy")
+	return listPage[models.RecipeStandard](ctx, db, params, utils.SearchConfig{
+		Fields: []string{"ingredient_id"},
+		Fuzzy:  true,
+	}, map[string]string{
+		"ingredientId": "ingredient_id",
+		"standardPer1": "quantity_per_serving",
+	})
+}
@@ -0,0 +1,90 @@
+// Package repository is the data access layer of one aggregate each: orders, inventory,
+// suppliers, recipes (dishes), users and their passwords. Every repository is an interface
+// with a Postgres implementation over GORM, used by the server, and a Memory implementation
+// keeping the data in process, used by tests that run without a database.
+package repository
+
+import (
+	"adong-be/models"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned when the requested record does not exist
+var ErrNotFound = errors.New("record not found")
+
+// ErrDuplicate is returned by the Memory repositories when a record with the same key
+// exists; Postgres reports its unique violation instead
+var ErrDuplicate = errors.New("duplicate key")
+
+// Kitchens restricts a query to the kitchens a caller may access. All lifts the
+// restriction; otherwise only IDs match, so an empty Kitchens matches nothing.
+type Kitchens struct {
+	All bool
+	IDs []string
+}
+
+// AllKitchens matches every kitchen
+var AllKitchens = Kitchens{All: true}
+
+// Contains reports whether kitchenID is within k
+func (k Kitchens) Contains(kitchenID string) bool {
+	if k.All {
+		return true
+	}
+	for _, id := range k.IDs {
+		if id == kitchenID {
+			return true
+		}
+	}
+	return false
+}
+
+// Only narrows k to kitchenID, false when kitchenID is outside k
+func (k Kitchens) Only(kitchenID string) (Kitchens, bool) {
+	if !k.Contains(kitchenID) {
+		return Kitchens{}, false
+	}
+	return Kitchens{IDs: []string{kitchenID}}, true
+}
+
+// containsFold is the in-memory counterpart of ILIKE '%search%' on any of fields
+func containsFold(search string, fields ...string) bool {
+	search = strings.ToLower(strings.TrimSpace(search))
+	if search == "" {
+		return true
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortAndPage orders items by the sort key of params, when keys knows it, and cuts the
+// requested page the way utils.ApplySort and utils.ApplyPagination do in SQL
+func sortAndPage[T any](items []T, params models.PaginationParams, keys map[string]func(T) string) []T {
+	if key, ok := keys[params.SortBy]; ok {
+		desc := params.SortDir == "desc"
+		sort.SliceStable(items, func(i, j int) bool {
+			if desc {
+				return key(items[i]) > key(items[j])
+			}
+			return key(items[i]) < key(items[j])
+		})
+	}
+	if params.Page < 1 || params.PageSize < 1 {
+		return items
+	}
+	start := (params.Page - 1) * params.PageSize
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + params.PageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+)
+
+// SupplierRepository stores suppliers
+type SupplierRepository interface {
+	// List returns one page of the suppliers matching params.Search and their total
+	List(ctx context.Context, params models.PaginationParams) ([]models.Supplier, int64, error)
+	Get(ctx context.Context, supplierID string) (*models.Supplier, error)
+	Create(ctx context.Context, supplier *models.Supplier) error
+	// Update saves supplier; with deactivatePrices its active prices are deactivated in
+	// the same transaction
+	Update(ctx context.Context, supplier *models.Supplier, deactivatePrices bool) error
+	Delete(ctx context.Context, supplierID string) error
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemorySuppliers keeps suppliers and their prices in process
+type MemorySuppliers struct {
+	suppliers *memoryTable[models.Supplier]
+
+	mu     sync.Mutex
+	prices []models.SupplierPrice
+}
+
+// NewMemorySuppliers returns a supplier repository holding suppliers and prices
+func NewMemorySuppliers(suppliers []models.Supplier, prices []models.SupplierPrice) *MemorySuppliers {
+	return &MemorySuppliers{
+		suppliers: newMemoryTable(func(s models.Supplier) string { return s.SupplierID }, suppliers),
+		prices:    append([]models.SupplierPrice(nil), prices...),
+	}
+}
+
+// Prices returns the supplier prices, including the deactivated ones
+func (m *MemorySuppliers) Prices() []models.SupplierPrice {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.SupplierPrice(nil), m.prices...)
+}
+
+func (m *MemorySuppliers) List(ctx context.Context, params models.PaginationParams) ([]models.Supplier, int64, error) {
+	items, total := m.suppliers.list(params, func(s models.Supplier) bool {
+		return containsFold(params.Search, s.SupplierName, s.SupplierID, s.Address, s.Phone)
+	}, map[string]func(models.Supplier) string{
+		"supplier_id":   func(s models.Supplier) string { return s.SupplierID },
+		"supplier_name": func(s models.Supplier) string { return s.SupplierName },
+		"address":       func(s models.Supplier) string { return s.Address },
+		"created_date":  func(s models.Supplier) string { return s.CreatedDate.Format(time.RFC3339Nano) },
+	})
+	return items, total, nil
+}
+
+func (m *MemorySuppliers) Get(ctx context.Context, supplierID string) (*models.Supplier, error) {
+	supplier, err := m.suppliers.get(supplierID)
+	if err != nil {
+		return nil, err
+	}
+	return &supplier, nil
+}
+
+func (m *MemorySuppliers) Create(ctx context.Context, supplier *models.Supplier) error {
+	supplier.CreatedDate, supplier.ModifiedDate = time.Now(), time.Now()
+	return m.suppliers.create(*supplier)
+}
+
+func (m *MemorySuppliers) Update(ctx context.Context, supplier *models.Supplier, deactivatePrices bool) error {
+	supplier.ModifiedDate = time.Now()
+	if err := m.suppliers.update(*supplier); err != nil {
+		return err
+	}
+	if deactivatePrices {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		inactive := false
+		for i := range m.prices {
+			if m.prices[i].SupplierID == supplier.SupplierID {
+				m.prices[i].Active = &inactive
+			}
+		}
+	}
+	return nil
+}
+
+func (m *MemorySuppliers) Delete(ctx context.Context, supplierID string) error {
+	m.suppliers.delete(supplierID)
+	return nil
+}
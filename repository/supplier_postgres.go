@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"adong-be/models"
+	"adong-be/utils"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// PostgresSuppliers is the SupplierRepository of the server
+type PostgresSuppliers struct {
+	DB *gorm.DB
+}
+
+// NewPostgresSuppliers returns a supplier repository over db
+func NewPostgresSuppliers(db *gorm.DB) *PostgresSuppliers {
+	return &PostgresSuppliers{DB: db}
+}
+
+func (r *PostgresSuppliers) List(ctx context.Context, params models.PaginationParams) ([]models.Supplier, int64, error) {
+	return listPage[models.Supplier](ctx, r.DB, params, utils.SearchConfig{
+		Fields: []string{"supplier_name", "supplier_id", "address", "phone"},
+		Fuzzy:  true,
+	}, map[string]string{
+		"supplier_id":   "supplier_id",
+		"supplier_name": "supplier_name",
+		"address":       "address",
+		"created_date":  "created_date",
+	})
+}
+
+func (r *PostgresSuppliers) Get(ctx context.Context, supplierID string) (*models.Supplier, error) {
+	var supplier models.Supplier
+	if err := r.DB.WithContext(ctx).First(&supplier, "supplier_id = ?", supplierID).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &supplier, nil
+}
+
+func (r *PostgresSuppliers) Create(ctx context.Context, supplier *models.Supplier) error {
+	return r.DB.WithContext(ctx).Create(supplier).Error
+}
+
+func (r *PostgresSuppliers) Update(ctx context.Context, supplier *models.Supplier, deactivatePrices bool) error {
+	return r.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(supplier).Error; err != nil {
+			return err
+		}
+		if !deactivatePrices {
+			return nil
+		}
+		return tx.Model(&models.SupplierPrice{}).
+			Where("supplier_id = ? AND active = true", supplier.SupplierID).
+			Updates(map[string]interface{}{"active": false, "version": gorm.Expr("version + 1")}).Error
+	})
+}
+
+func (r *PostgresSuppliers) Delete(ctx context.Context, supplierID string) error {
+	return r.DB.WithContext(ctx).Delete(&models.Supplier{}, "supplier_id = ?", supplierID).Error
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+)
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	// List returns one page of the users matching params.Search and their total
+	List(ctx context.Context, params models.PaginationParams) ([]models.User, int64, error)
+	Get(ctx context.Context, userID string) (*models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, userID string) error
+	// RoleExists reports whether role is defined in the roles table
+	RoleExists(ctx context.Context, role string) (bool, error)
+}
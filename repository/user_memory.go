@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"adong-be/models"
+	"context"
+	"time"
+)
+
+// MemoryUsers keeps users in process, with a fixed set of roles
+type MemoryUsers struct {
+	users *memoryTable[models.User]
+	roles map[string]bool
+}
+
+// NewMemoryUsers returns a user repository holding users and knowing roles
+func NewMemoryUsers(users []models.User, roles ...string) *MemoryUsers {
+	m := &MemoryUsers{
+		users: newMemoryTable(func(u models.User) string { return u.UserID }, users),
+		roles: make(map[string]bool, len(roles)),
+	}
+	for _, role := range roles {
+		m.roles[role] = true
+	}
+	return m
+}
+
+func (m *MemoryUsers) List(ctx context.Context, params models.PaginationParams) ([]models.User, int64, error) {
+	items, total := m.users.list(params, func(u models.User) bool {
+		return containsFold(params.Search, u.UserID, u.UserName, u.FullName, u.Email, u.Phone)
+	}, map[string]func(models.User) string{
+		"user_id":   func(u models.User) string { return u.UserID },
+		"user_name": func(u models.User) string { return u.UserName },
+		"full_name": func(u models.User) string { return u.FullName },
+		"email":     func(u models.User) string { return u.Email },
+		"role":      func(u models.User) string { return u.Role },
+	})
+	return items, total, nil
+}
+
+func (m *MemoryUsers) Get(ctx context.Context, userID string) (*models.User, error) {
+	user, err := m.users.get(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (m *MemoryUsers) Create(ctx context.Context, user *models.User) error {
+	user.CreatedDate, user.ModifiedDate = time.Now(), time.Now()
+	return m.users.create(*user)
+}
+
+func (m *MemoryUsers) Update(ctx context.Context, user *models.User) error {
+	user.ModifiedDate = time.Now()
+	return m.users.update(*user)
+}
+
+func (m *MemoryUsers) Delete(ctx context.Context, userID string) error {
+	m.users.delete(userID)
+	return nil
+}
+
+func (m *MemoryUsers) RoleExists(ctx context.Context, role string) (bool, error) {
+	return m.roles[role], nil
+}
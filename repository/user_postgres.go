@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"adong-be/models"
+	"adong-be/utils"
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// PostgresUsers is the UserRepository of the server
+type PostgresUsers struct {
+	DB *gorm.DB
+}
+
+// NewPostgresUsers returns a user repository over db
+func NewPostgresUsers(db *gorm.DB) *PostgresUsers {
+	return &PostgresUsers{DB: db}
+}
+
+func (r *PostgresUsers) List(ctx context.Context, params models.PaginationParams) ([]models.User, int64, error) {
+	return listPage[models.User](ctx, r.DB, params, utils.SearchConfig{
+		Fields: []string{"user_id", "user_name", "full_name", "email", "phone"},
+		Fuzzy:  true,
+	}, map[string]string{
+		"user_id":   "user_id",
+		"user_name": "user_name",
+		"full_name": "full_name",
+		"email":     "email",
+		"role":      "role",
+	})
+}
+
+func (r *PostgresUsers) Get(ctx context.Context, userID string) (*models.User, error) {
+	var user models.User
+	if err := r.DB.WithContext(ctx).First(&user, "user_id = ?", userID).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *PostgresUsers) Create(ctx context.Context, user *models.User) error {
+	return r.DB.WithContext(ctx).Create(user).Error
+}
+
+func (r *PostgresUsers) Update(ctx context.Context, user *models.User) error {
+	return r.DB.WithContext(ctx).Save(user).Error
+}
+
+func (r *PostgresUsers) Delete(ctx context.Context, userID string) error {
+	return r.DB.WithContext(ctx).Delete(&models.User{}, "user_id = ?", userID).Error
+}
+
+func (r *PostgresUsers) RoleExists(ctx context.Context, role string) (bool, error) {
+	var count int64
+	if err := r.DB.WithContext(ctx).Model(&models.Role{}).Where("role_name = ?", role).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,61 @@
+// Package requestid gives every request an ID, taken from the X-Request-ID header when the
+// client or a proxy sends a usable one and generated otherwise. The ID is echoed in the
+// response and ties together the log lines, audit entries and spans of one request.
+package requestid
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Header carries the request ID
+const Header = "X-Request-ID"
+
+// key is the gin context key holding the request ID
+const key = "request_id"
+
+// maxLength bounds accepted IDs, longer ones are replaced
+const maxLength = 128
+
+// Middleware assigns the request ID before the handlers run
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		Ensure(c)
+		c.Next()
+	}
+}
+
+// Ensure returns the ID of the request, assigning it on first use
+func Ensure(c *gin.Context) string {
+	if id := c.GetString(key); id != "" {
+		return id
+	}
+	id := c.GetHeader(Header)
+	if !valid(id) {
+		id = uuid.NewString()
+	}
+	c.Set(key, id)
+	c.Header(Header, id)
+	return id
+}
+
+// Get returns the ID assigned to the request, empty when none was
+func Get(c *gin.Context) string {
+	return c.GetString(key)
+}
+
+// valid accepts IDs of letters, digits and . _ : - only, so a client cannot forge log lines
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '.', r == '_', r == ':', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,286 @@
+package selection
+
+import (
+	"math"
+	"sort"
+)
+
+// exactSupplierLimit is the largest number of candidate suppliers for which every
+// supplier subset is enumerated. Above it a greedy drop heuristic is used.
+const exactSupplierLimit = 16
+
+// SupplierTerms are the delivery conditions of one supplier
+type SupplierTerms struct {
+	MinOrderValue float64 `json:"minOrderValue"`
+	DeliveryFee   float64 `json:"deliveryFee"`
+}
+
+// ConsolidationOptions configures the basket optimizer
+type ConsolidationOptions struct {
+	MaxSuppliers int // 0 means no limit
+	Terms        map[string]SupplierTerms
+}
+
+// PlanLine is one item assigned to one supplier
+type PlanLine struct {
+	IngredientID   string  `json:"ingredientId"`
+	IngredientName string  `json:"ingredientName"`
+	Quantity       float64 `json:"quantity"`
+	Unit           string  `json:"unit"`
+	SupplierID     string  `json:"supplierId"`
+	SupplierName   string  `json:"supplierName"`
+	ProductID      int     `json:"productId"`
+	ProductName    string  `json:"productName"`
+	UnitPrice      float64 `json:"unitPrice"`
+	PriceSource    string  `json:"priceSource"`
+	LineCost       float64 `json:"lineCost"`
+}
+
+// PlanSupplier summarizes what is bought from one supplier
+type PlanSupplier struct {
+	SupplierID    string  `json:"supplierId"`
+	SupplierName  string  `json:"supplierName"`
+	ItemCount     int     `json:"itemCount"`
+	Subtotal      float64 `json:"subtotal"`
+	DeliveryFee   float64 `json:"deliveryFee"`
+	MinOrderValue float64 `json:"minOrderValue"`
+	// MinOrderTopUp is the amount still needed to reach the supplier's minimum order value.
+	// It is counted in the landed cost because the order has to be padded or a surcharge paid.
+	MinOrderTopUp float64 `json:"minOrderTopUp"`
+	LandedCost    float64 `json:"landedCost"`
+}
+
+// Plan is an assignment of items to suppliers with its landed cost
+type Plan struct {
+	Lines           []PlanLine     `json:"lines"`
+	Suppliers       []PlanSupplier `json:"suppliers"`
+	Unassigned      []string       `json:"unassigned"`
+	SupplierCount   int            `json:"supplierCount"`
+	ItemsCost       float64        `json:"itemsCost"`
+	DeliveryFees    float64        `json:"deliveryFees"`
+	MinOrderTopUps  float64        `json:"minOrderTopUps"`
+	TotalLandedCost float64        `json:"totalLandedCost"`
+}
+
+// ConsolidationResult compares the optimized plan with the naive cheapest-per-item plan
+type ConsolidationResult struct {
+	Method               string  `json:"method"` // "exact" or "heuristic"
+	MaxSuppliers         int     `json:"maxSuppliers"`
+	MaxSuppliersExceeded bool    `json:"maxSuppliersExceeded"`
+	Optimized            Plan    `json:"optimized"`
+	Naive                Plan    `json:"naive"`
+	Savings              float64 `json:"savings"`
+	SavingsPercent       float64 `json:"savingsPercent"`
+}
+
+// Consolidate chooses which suppliers to buy from so that the total landed cost
+// (item cost + delivery fees + minimum order top-ups) is minimal while using at most
+// MaxSuppliers suppliers. Items are always bought from the cheapest supplier in the
+// chosen set. Small candidate sets are solved exactly by enumerating supplier subsets.
+func Consolidate(items []Item, candidates map[string][]Candidate, opts ConsolidationOptions) ConsolidationResult {
+	supplierSet := make(map[string]bool)
+	for _, item := range items {
+		for _, cand := range candidates[item.IngredientID] {
+			supplierSet[cand.Price.SupplierID] = true
+		}
+	}
+	suppliers := make([]string, 0, len(supplierSet))
+	for id := range supplierSet {
+		suppliers = append(suppliers, id)
+	}
+	sort.Strings(suppliers)
+
+	naive := buildPlan(items, candidates, supplierSet, opts.Terms)
+
+	result := ConsolidationResult{MaxSuppliers: opts.MaxSuppliers, Naive: naive}
+	if len(suppliers) <= exactSupplierLimit {
+		result.Method = "exact"
+		result.Optimized, result.MaxSuppliersExceeded = solveExact(items, candidates, suppliers, opts)
+	} else {
+		result.Method = "heuristic"
+		result.Optimized, result.MaxSuppliersExceeded = solveGreedy(items, candidates, naive, opts)
+	}
+
+	result.Savings = round2(naive.TotalLandedCost - result.Optimized.TotalLandedCost)
+	if naive.TotalLandedCost > 0 {
+		result.SavingsPercent = round2(result.Savings / naive.TotalLandedCost * 100)
+	}
+	return result
+}
+
+// coverable returns the number of items that have at least one candidate in allowed
+func coverable(items []Item, candidates map[string][]Candidate, allowed map[string]bool) int {
+	n := 0
+	for _, item := range items {
+		for _, cand := range candidates[item.IngredientID] {
+			if allowed[cand.Price.SupplierID] {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func solveExact(items []Item, candidates map[string][]Candidate, suppliers []string, opts ConsolidationOptions) (Plan, bool) {
+	all := make(map[string]bool, len(suppliers))
+	for _, id := range suppliers {
+		all[id] = true
+	}
+	target := coverable(items, candidates, all)
+
+	var best *Plan
+	var fallback *Plan
+	for mask := 1; mask < 1<<len(suppliers); mask++ {
+		allowed := make(map[string]bool)
+		for i, id := range suppliers {
+			if mask&(1<<i) != 0 {
+				allowed[id] = true
+			}
+		}
+		if coverable(items, candidates, allowed) < target {
+			continue
+		}
+		plan := buildPlan(items, candidates, allowed, opts.Terms)
+		if opts.MaxSuppliers > 0 && plan.SupplierCount > opts.MaxSuppliers {
+			if fallback == nil || plan.SupplierCount < fallback.SupplierCount ||
+				(plan.SupplierCount == fallback.SupplierCount && plan.TotalLandedCost < fallback.TotalLandedCost) {
+				p := plan
+				fallback = &p
+			}
+			continue
+		}
+		if best == nil || plan.TotalLandedCost < best.TotalLandedCost ||
+			(plan.TotalLandedCost == best.TotalLandedCost && plan.SupplierCount < best.SupplierCount) {
+			p := plan
+			best = &p
+		}
+	}
+
+	if best != nil {
+		return *best, false
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return buildPlan(items, candidates, all, opts.Terms), false
+}
+
+// solveGreedy starts from the naive plan and repeatedly drops the supplier whose
+// removal gives the cheapest plan, as long as that lowers the landed cost or the plan
+// still uses more suppliers than allowed.
+func solveGreedy(items []Item, candidates map[string][]Candidate, naive Plan, opts ConsolidationOptions) (Plan, bool) {
+	allowed := make(map[string]bool)
+	for _, s := range naive.Suppliers {
+		allowed[s.SupplierID] = true
+	}
+	target := coverable(items, candidates, allowed)
+	current := naive
+
+	for {
+		var bestPlan *Plan
+		var bestDrop string
+		for id := range allowed {
+			trial := make(map[string]bool, len(allowed))
+			for k := range allowed {
+				if k != id {
+					trial[k] = true
+				}
+			}
+			if coverable(items, candidates, trial) < target {
+				continue
+			}
+			plan := buildPlan(items, candidates, trial, opts.Terms)
+			if bestPlan == nil || plan.TotalLandedCost < bestPlan.TotalLandedCost ||
+				(plan.TotalLandedCost == bestPlan.TotalLandedCost && id < bestDrop) {
+				p := plan
+				bestPlan = &p
+				bestDrop = id
+			}
+		}
+		if bestPlan == nil {
+			break
+		}
+		overLimit := opts.MaxSuppliers > 0 && current.SupplierCount > opts.MaxSuppliers
+		if !overLimit && bestPlan.TotalLandedCost >= current.TotalLandedCost {
+			break
+		}
+		delete(allowed, bestDrop)
+		current = *bestPlan
+	}
+
+	exceeded := opts.MaxSuppliers > 0 && current.SupplierCount > opts.MaxSuppliers
+	return current, exceeded
+}
+
+// buildPlan assigns every item to its cheapest candidate among the allowed suppliers
+// and computes the landed cost of the result
+func buildPlan(items []Item, candidates map[string][]Candidate, allowed map[string]bool, terms map[string]SupplierTerms) Plan {
+	plan := Plan{Lines: []PlanLine{}, Suppliers: []PlanSupplier{}, Unassigned: []string{}}
+	bySupplier := make(map[string]*PlanSupplier)
+	var order []string
+
+	for _, item := range items {
+		var best *Candidate
+		for i, cand := range candidates[item.IngredientID] {
+			if !allowed[cand.Price.SupplierID] {
+				continue
+			}
+			if best == nil || cand.UnitPrice < best.UnitPrice {
+				best = &candidates[item.IngredientID][i]
+			}
+		}
+		if best == nil {
+			plan.Unassigned = append(plan.Unassigned, item.IngredientID)
+			continue
+		}
+
+		line := PlanLine{
+			IngredientID:   item.IngredientID,
+			IngredientName: item.IngredientName,
+			Quantity:       item.Quantity,
+			Unit:           item.Unit,
+			SupplierID:     best.Price.SupplierID,
+			SupplierName:   best.SupplierName(),
+			ProductID:      best.Price.ProductID,
+			ProductName:    best.Price.ProductName,
+			UnitPrice:      best.UnitPrice,
+			PriceSource:    best.PriceSource,
+			LineCost:       item.Quantity * best.UnitPrice,
+		}
+		plan.Lines = append(plan.Lines, line)
+
+		s, ok := bySupplier[line.SupplierID]
+		if !ok {
+			t := terms[line.SupplierID]
+			s = &PlanSupplier{
+				SupplierID:    line.SupplierID,
+				SupplierName:  line.SupplierName,
+				DeliveryFee:   t.DeliveryFee,
+				MinOrderValue: t.MinOrderValue,
+			}
+			bySupplier[line.SupplierID] = s
+			order = append(order, line.SupplierID)
+		}
+		s.ItemCount++
+		s.Subtotal += line.LineCost
+	}
+
+	for _, id := range order {
+		s := bySupplier[id]
+		s.MinOrderTopUp = math.Max(0, s.MinOrderValue-s.Subtotal)
+		s.LandedCost = s.Subtotal + s.DeliveryFee + s.MinOrderTopUp
+
+		plan.ItemsCost += s.Subtotal
+		plan.DeliveryFees += s.DeliveryFee
+		plan.MinOrderTopUps += s.MinOrderTopUp
+		plan.Suppliers = append(plan.Suppliers, *s)
+	}
+	plan.SupplierCount = len(plan.Suppliers)
+	plan.TotalLandedCost = plan.ItemsCost + plan.DeliveryFees + plan.MinOrderTopUps
+	return plan
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
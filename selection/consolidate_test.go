@@ -0,0 +1,113 @@
+package selection
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsolidate(t *testing.T) {
+	items := []Item{
+		{IngredientID: "NL001", Quantity: 10},
+		{IngredientID: "NL002", Quantity: 10},
+		{IngredientID: "NL003", Quantity: 10},
+	}
+	// S1 is cheapest for NL001, S2 for NL002, S3 for NL003; S1 carries everything
+	candidates := map[string][]Candidate{
+		"NL001": {candidate(1, "S1", 100, false, 0), candidate(2, "S2", 120, false, 0)},
+		"NL002": {candidate(3, "S1", 110, false, 0), candidate(4, "S2", 100, false, 0)},
+		"NL003": {candidate(5, "S1", 110, false, 0), candidate(6, "S3", 100, false, 0)},
+	}
+
+	tests := []struct {
+		name          string
+		opts          ConsolidationOptions
+		wantSuppliers []string
+		wantTotal     float64
+		wantExceeded  bool
+	}{
+		{
+			name:          "no fees keeps cheapest per item",
+			wantSuppliers: []string{"S1", "S2", "S3"},
+			wantTotal:     3000,
+		},
+		{
+			name: "delivery fees favour a single supplier",
+			opts: ConsolidationOptions{Terms: map[string]SupplierTerms{
+				"S1": {DeliveryFee: 150}, "S2": {DeliveryFee: 150}, "S3": {DeliveryFee: 150},
+			}},
+			wantSuppliers: []string{"S1"},
+			wantTotal:     3350,
+		},
+		{
+			name: "minimum order value counts as top-up",
+			opts: ConsolidationOptions{Terms: map[string]SupplierTerms{
+				"S3": {MinOrderValue: 1200},
+			}},
+			wantSuppliers: []string{"S1", "S2"},
+			wantTotal:     3100,
+		},
+		{
+			name:          "max suppliers",
+			opts:          ConsolidationOptions{MaxSuppliers: 2},
+			wantSuppliers: []string{"S1", "S2"},
+			wantTotal:     3100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Consolidate(items, candidates, tt.opts)
+			assert.Equal(t, "exact", result.Method)
+			assert.Equal(t, tt.wantExceeded, result.MaxSuppliersExceeded)
+			var got []string
+			for _, s := range result.Optimized.Suppliers {
+				got = append(got, s.SupplierID)
+			}
+			assert.ElementsMatch(t, tt.wantSuppliers, got)
+			assert.InDelta(t, tt.wantTotal, result.Optimized.TotalLandedCost, 0.001)
+			assert.Equal(t, 3, result.Naive.SupplierCount)
+			assert.InDelta(t, result.Naive.TotalLandedCost-result.Optimized.TotalLandedCost, result.Savings, 0.01)
+		})
+	}
+}
+
+func TestConsolidate_Heuristic(t *testing.T) {
+	// Every supplier carries every item; one of them is slightly cheaper on each item
+	// but the delivery fee makes buying everything from S00 the best plan
+	var items []Item
+	candidates := make(map[string][]Candidate)
+	for i := 0; i < exactSupplierLimit+4; i++ {
+		ing := fmt.Sprintf("NL%03d", i)
+		items = append(items, Item{IngredientID: ing, Quantity: 1})
+		for s := 0; s < exactSupplierLimit+4; s++ {
+			price := 100.0
+			if s == i && s != 0 {
+				price = 99
+			}
+			candidates[ing] = append(candidates[ing], candidate(i*100+s, fmt.Sprintf("S%02d", s), price, false, 0))
+		}
+	}
+	terms := make(map[string]SupplierTerms)
+	for s := 0; s < exactSupplierLimit+4; s++ {
+		terms[fmt.Sprintf("S%02d", s)] = SupplierTerms{DeliveryFee: 10}
+	}
+
+	result := Consolidate(items, candidates, ConsolidationOptions{MaxSuppliers: 1, Terms: terms})
+	assert.Equal(t, "heuristic", result.Method)
+	assert.False(t, result.MaxSuppliersExceeded)
+	assert.Equal(t, 1, result.Optimized.SupplierCount)
+	assert.Empty(t, result.Optimized.Unassigned)
+	assert.Greater(t, result.Savings, 0.0)
+}
+
+func TestConsolidate_MaxSuppliersUnreachable(t *testing.T) {
+	items := []Item{{IngredientID: "NL001", Quantity: 1}, {IngredientID: "NL002", Quantity: 1}}
+	candidates := map[string][]Candidate{
+		"NL001": {candidate(1, "S1", 100, false, 0)},
+		"NL002": {candidate(2, "S2", 100, false, 0)},
+	}
+	result := Consolidate(items, candidates, ConsolidationOptions{MaxSuppliers: 1})
+	assert.True(t, result.MaxSuppliersExceeded)
+	assert.Equal(t, 2, result.Optimized.SupplierCount)
+}
@@ -0,0 +1,198 @@
+// Package selection picks the best supplier for each ingredient of an order.
+//
+// Which strategy applies to an ingredient is decided by models.SupplierSelectionRule
+// rows stored in the database, so the behaviour can be changed per kitchen, per
+// ingredient type or per material group without a redeploy. Every decision carries
+// a human readable explanation of why the supplier was chosen.
+package selection
+
+import (
+	"adong-be/delivery"
+	"adong-be/models"
+	"sort"
+)
+
+// Item is one ingredient line that needs a supplier
+type Item struct {
+	IngredientID     string
+	IngredientName   string
+	IngredientTypeID string
+	MaterialGroup    string
+	Quantity         float64
+	Unit             string
+}
+
+// Candidate is one supplier price row that can deliver an Item
+type Candidate struct {
+	Price        models.SupplierPrice
+	UnitPrice    float64 // price used for comparison, defaults to Price.UnitPrice
+	PriceSource  string  // where UnitPrice comes from (list, tier, contract, promotion)
+	PriceNote    string
+	IsFavorite   bool
+	FavoriteRank int              // kitchen favorite display order, lower is preferred
+	Delivery     *delivery.Status // nil when no delivery date was checked
+}
+
+// SupplierName returns the preloaded supplier name or an empty string
+func (c Candidate) SupplierName() string {
+	if c.Price.Supplier != nil {
+		return c.Price.Supplier.SupplierName
+	}
+	return ""
+}
+
+// Decision is the engine's answer for a single Item
+type Decision struct {
+	Item           Item
+	Selected       *Candidate
+	LowestPrice    float64
+	CandidateCount int
+	Strategy       string
+	RuleID         int
+	Explanation    string
+}
+
+// Context gives strategies a view of the whole order, which consolidation and
+// scoring strategies need in order to prefer suppliers covering many items.
+type Context struct {
+	KitchenID  string
+	Items      []Item
+	Candidates map[string][]Candidate
+	coverage   map[string]int
+}
+
+// Coverage returns how many items of the order the supplier can deliver
+func (ctx *Context) Coverage(supplierID string) int {
+	if ctx.coverage == nil {
+		ctx.coverage = make(map[string]int)
+		for _, item := range ctx.Items {
+			seen := make(map[string]bool)
+			for _, cand := range ctx.Candidates[item.IngredientID] {
+				if !seen[cand.Price.SupplierID] {
+					seen[cand.Price.SupplierID] = true
+					ctx.coverage[cand.Price.SupplierID]++
+				}
+			}
+		}
+	}
+	return ctx.coverage[supplierID]
+}
+
+// defaultRule is used when no stored rule matches an item
+var defaultRule = models.SupplierSelectionRule{
+	Strategy:       models.StrategyCheapest,
+	PriceWeight:    0.6,
+	FavoriteWeight: 0.3,
+	CoverageWeight: 0.1,
+}
+
+// Engine resolves selection rules and runs the matching strategy for each item
+type Engine struct {
+	rules []models.SupplierSelectionRule
+}
+
+// NewEngine creates an engine from the rules loaded from supplier_selection_rules.
+// Inactive rules are ignored.
+func NewEngine(rules []models.SupplierSelectionRule) *Engine {
+	var active []models.SupplierSelectionRule
+	for _, r := range rules {
+		if r.Active == nil || *r.Active {
+			active = append(active, r)
+		}
+	}
+	return &Engine{rules: active}
+}
+
+// RuleFor returns the rule that applies to an item in a kitchen.
+// Higher Priority wins first, then the more specific rule: kitchen specific rules
+// beat global ones and a material group match beats an ingredient type match.
+func (e *Engine) RuleFor(kitchenID string, item Item) models.SupplierSelectionRule {
+	var matches []models.SupplierSelectionRule
+	for _, r := range e.rules {
+		if r.KitchenID != nil && *r.KitchenID != kitchenID {
+			continue
+		}
+		if r.IngredientTypeID != nil && *r.IngredientTypeID != item.IngredientTypeID {
+			continue
+		}
+		if r.MaterialGroup != nil && *r.MaterialGroup != item.MaterialGroup {
+			continue
+		}
+		matches = append(matches, r)
+	}
+	if len(matches) == 0 {
+		return defaultRule
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Priority != matches[j].Priority {
+			return matches[i].Priority > matches[j].Priority
+		}
+		si, sj := specificity(matches[i]), specificity(matches[j])
+		if si != sj {
+			return si > sj
+		}
+		return matches[i].RuleID < matches[j].RuleID
+	})
+	return matches[0]
+}
+
+func specificity(r models.SupplierSelectionRule) int {
+	score := 0
+	if r.KitchenID != nil {
+		score += 4
+	}
+	if r.MaterialGroup != nil {
+		score += 2
+	}
+	if r.IngredientTypeID != nil {
+		score++
+	}
+	return score
+}
+
+// Select picks a supplier for every item. candidates is keyed by ingredient ID.
+func (e *Engine) Select(kitchenID string, items []Item, candidates map[string][]Candidate) []Decision {
+	ctx := &Context{KitchenID: kitchenID, Items: items, Candidates: candidates}
+
+	decisions := make([]Decision, 0, len(items))
+	for _, item := range items {
+		rule := e.RuleFor(kitchenID, item)
+		cands := candidates[item.IngredientID]
+
+		decision := Decision{
+			Item:           item,
+			CandidateCount: len(cands),
+			Strategy:       rule.Strategy,
+			RuleID:         rule.RuleID,
+		}
+
+		if len(cands) == 0 {
+			decision.Explanation = "No active supplier price for this ingredient"
+			decisions = append(decisions, decision)
+			continue
+		}
+
+		decision.LowestPrice = cands[0].UnitPrice
+		for _, cand := range cands[1:] {
+			if cand.UnitPrice < decision.LowestPrice {
+				decision.LowestPrice = cand.UnitPrice
+			}
+		}
+
+		strategy, ok := Lookup(rule.Strategy)
+		if !ok {
+			strategy, _ = Lookup(models.StrategyCheapest)
+			decision.Strategy = models.StrategyCheapest
+		}
+
+		selected, explanation := strategy.Select(ctx, rule, item, cands)
+		decision.Selected = selected
+		decision.Explanation = explanation
+		if !ok {
+			decision.Explanation = "Unknown strategy \"" + rule.Strategy + "\", fell back to cheapest. " + explanation
+		}
+		decisions = append(decisions, decision)
+	}
+	return decisions
+}
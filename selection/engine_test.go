@@ -0,0 +1,131 @@
+package selection
+
+import (
+	"adong-be/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func strp(s string) *string { return &s }
+
+func candidate(productID int, supplierID string, price float64, favorite bool, rank int) Candidate {
+	return Candidate{
+		Price: models.SupplierPrice{
+			ProductID:  productID,
+			SupplierID: supplierID,
+			UnitPrice:  price,
+			Unit:       "kg",
+			Supplier:   &models.Supplier{SupplierID: supplierID, SupplierName: supplierID},
+		},
+		UnitPrice:    price,
+		IsFavorite:   favorite,
+		FavoriteRank: rank,
+	}
+}
+
+func TestEngine_RuleFor(t *testing.T) {
+	rules := []models.SupplierSelectionRule{
+		{RuleID: 1, Strategy: models.StrategyCheapest},
+		{RuleID: 2, MaterialGroup: strp("Rau xanh"), Strategy: models.StrategyFavoriteFirst},
+		{RuleID: 3, KitchenID: strp("K001"), MaterialGroup: strp("Rau xanh"), Strategy: models.StrategySingleSupplier},
+		{RuleID: 4, IngredientTypeID: strp("MEAT"), Strategy: models.StrategyScoreWeighted},
+		{RuleID: 5, KitchenID: strp("K002"), Strategy: models.StrategyCheapestPerBaseUnit, Priority: 10},
+	}
+	engine := NewEngine(rules)
+
+	tests := []struct {
+		name    string
+		kitchen string
+		item    Item
+		want    int
+	}{
+		{"default rule", "K003", Item{MaterialGroup: "Gạo"}, 1},
+		{"material group beats default", "K003", Item{MaterialGroup: "Rau xanh"}, 2},
+		{"kitchen specific beats global", "K001", Item{MaterialGroup: "Rau xanh"}, 3},
+		{"ingredient type", "K003", Item{IngredientTypeID: "MEAT"}, 4},
+		{"priority beats specificity", "K002", Item{MaterialGroup: "Rau xanh"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, engine.RuleFor(tt.kitchen, tt.item).RuleID)
+		})
+	}
+}
+
+// The seeded rules prefer favorites only for the listed groups, every other ingredient goes
+// to the lowest price even when a favorite supplier offers it
+func TestEngine_SeededDefaultIsCheapest(t *testing.T) {
+	rules := []models.SupplierSelectionRule{
+		{RuleID: 1, MaterialGroup: strp("Rau xanh"), Strategy: models.StrategyFavoriteFirst},
+		{RuleID: 2, Strategy: models.StrategyCheapest},
+	}
+	items := []Item{
+		{IngredientID: "NL001", MaterialGroup: "Rau xanh", Quantity: 10},
+		{IngredientID: "NL002", MaterialGroup: "Gia vị", Quantity: 2},
+	}
+	candidates := map[string][]Candidate{
+		"NL001": {candidate(1, "S1", 20000, false, 0), candidate(2, "S2", 25000, true, 1)},
+		"NL002": {candidate(3, "S1", 30000, false, 0), candidate(4, "S2", 35000, true, 1)},
+	}
+
+	decisions := NewEngine(rules).Select("K001", items, candidates)
+	assert.Equal(t, "S2", decisions[0].Selected.Price.SupplierID)
+	assert.Equal(t, models.StrategyCheapest, decisions[1].Strategy)
+	assert.Equal(t, 2, decisions[1].RuleID)
+	assert.Equal(t, "S1", decisions[1].Selected.Price.SupplierID)
+}
+
+func TestEngine_Select(t *testing.T) {
+	items := []Item{
+		{IngredientID: "NL001", MaterialGroup: "Rau xanh", Quantity: 10},
+		{IngredientID: "NL002", MaterialGroup: "Gạo", Quantity: 5},
+		{IngredientID: "NL003", Quantity: 1},
+	}
+	candidates := map[string][]Candidate{
+		"NL001": {candidate(1, "S1", 20000, false, 0), candidate(2, "S2", 25000, true, 1)},
+		"NL002": {candidate(3, "S1", 15000, false, 0), candidate(4, "S3", 12000, false, 0)},
+	}
+
+	tests := []struct {
+		name      string
+		rules     []models.SupplierSelectionRule
+		wantByIng map[string]string
+	}{
+		{
+			name:      "no rules falls back to cheapest",
+			wantByIng: map[string]string{"NL001": "S1", "NL002": "S3"},
+		},
+		{
+			name: "favorite first for vegetables",
+			rules: []models.SupplierSelectionRule{
+				{RuleID: 1, MaterialGroup: strp("Rau xanh"), Strategy: models.StrategyFavoriteFirst},
+			},
+			wantByIng: map[string]string{"NL001": "S2", "NL002": "S3"},
+		},
+		{
+			name: "single supplier consolidates",
+			rules: []models.SupplierSelectionRule{
+				{RuleID: 1, Strategy: models.StrategySingleSupplier},
+			},
+			wantByIng: map[string]string{"NL001": "S1", "NL002": "S1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := NewEngine(tt.rules).Select("K001", items, candidates)
+			assert.Len(t, decisions, len(items))
+			for _, d := range decisions {
+				assert.NotEmpty(t, d.Explanation)
+				want, ok := tt.wantByIng[d.Item.IngredientID]
+				if !ok {
+					assert.Nil(t, d.Selected)
+					continue
+				}
+				if assert.NotNil(t, d.Selected) {
+					assert.Equal(t, want, d.Selected.Price.SupplierID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package selection
+
+import (
+	"adong-be/models"
+	"fmt"
+	"sort"
+)
+
+// Strategy picks one candidate for an item and explains the choice
+type Strategy interface {
+	Name() string
+	Select(ctx *Context, rule models.SupplierSelectionRule, item Item, candidates []Candidate) (*Candidate, string)
+}
+
+var registry = map[string]Strategy{}
+
+// Register makes a strategy available to rules under its name
+func Register(s Strategy) {
+	registry[s.Name()] = s
+}
+
+// Lookup returns the strategy registered under name
+func Lookup(name string) (Strategy, bool) {
+	s, ok := registry[name]
+	return s, ok
+}
+
+// Names returns the registered strategy names in alphabetical order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(favoriteFirst{})
+	Register(cheapest{})
+	Register(cheapestPerBaseUnit{})
+	Register(singleSupplier{})
+	Register(scoreWeighted{})
+}
+
+// byPrice orders candidates by comparison price, favorites first on ties
+func byPrice(cands []Candidate) []Candidate {
+	sorted := append([]Candidate(nil), cands...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].UnitPrice != sorted[j].UnitPrice {
+			return sorted[i].UnitPrice < sorted[j].UnitPrice
+		}
+		if sorted[i].IsFavorite != sorted[j].IsFavorite {
+			return sorted[i].IsFavorite
+		}
+		return sorted[i].FavoriteRank < sorted[j].FavoriteRank
+	})
+	return sorted
+}
+
+// favoriteFirst prefers the kitchen's favorite suppliers and falls back to the lowest price
+type favoriteFirst struct{}
+
+func (favoriteFirst) Name() string { return models.StrategyFavoriteFirst }
+
+func (favoriteFirst) Select(ctx *Context, rule models.SupplierSelectionRule, item Item, cands []Candidate) (*Candidate, string) {
+	var favorites []Candidate
+	for _, cand := range cands {
+		if cand.IsFavorite {
+			favorites = append(favorites, cand)
+		}
+	}
+	if len(favorites) == 0 {
+		best := byPrice(cands)[0]
+		return &best, fmt.Sprintf("No kitchen favorite supplier offers this ingredient; lowest unit price %.2f/%s among %d suppliers",
+			best.UnitPrice, best.Price.Unit, len(cands))
+	}
+
+	sort.SliceStable(favorites, func(i, j int) bool {
+		if favorites[i].FavoriteRank != favorites[j].FavoriteRank {
+			return favorites[i].FavoriteRank < favorites[j].FavoriteRank
+		}
+		return favorites[i].UnitPrice < favorites[j].UnitPrice
+	})
+	best := favorites[0]
+	return &best, fmt.Sprintf("Kitchen favorite supplier %s (first of %d favorites offering this ingredient) at %.2f/%s",
+		best.SupplierName(), len(favorites), best.UnitPrice, best.Price.Unit)
+}
+
+// cheapest picks the lowest unit price
+type cheapest struct{}
+
+func (cheapest) Name() string { return models.StrategyCheapest }
+
+func (cheapest) Select(ctx *Context, rule models.SupplierSelectionRule, item Item, cands []Candidate) (*Candidate, string) {
+	best := byPrice(cands)[0]
+	return &best, fmt.Sprintf("Lowest unit price %.2f/%s among %d suppliers", best.UnitPrice, best.Price.Unit, len(cands))
+}
+
+// cheapestPerBaseUnit compares the price per base unit (price_per_item) so that a
+// 5kg bag and a 1kg bag are compared fairly. Rows without it use the unit price.
+type cheapestPerBaseUnit struct{}
+
+func (cheapestPerBaseUnit) Name() string { return models.StrategyCheapestPerBaseUnit }
+
+func baseUnitPrice(c Candidate) float64 {
+	if c.Price.PricePer1 > 0 {
+		// Carry any tier, contract or promotion discount over to the per-item price
+		if c.Price.UnitPrice > 0 && c.UnitPrice != c.Price.UnitPrice {
+			return c.Price.PricePer1 * c.UnitPrice / c.Price.UnitPrice
+		}
+		return c.Price.PricePer1
+	}
+	return c.UnitPrice
+}
+
+func (cheapestPerBaseUnit) Select(ctx *Context, rule models.SupplierSelectionRule, item Item, cands []Candidate) (*Candidate, string) {
+	sorted := byPrice(cands)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return baseUnitPrice(sorted[i]) < baseUnitPrice(sorted[j])
+	})
+	best := sorted[0]
+	return &best, fmt.Sprintf("Lowest price per base unit %.2f (pack price %.2f/%s, specification %q) among %d suppliers",
+		baseUnitPrice(best), best.UnitPrice, best.Price.Unit, best.Price.Specification, len(cands))
+}
+
+// singleSupplier consolidates the order with the supplier that can deliver the most items
+type singleSupplier struct{}
+
+func (singleSupplier) Name() string { return models.StrategySingleSupplier }
+
+func (singleSupplier) Select(ctx *Context, rule models.SupplierSelectionRule, item Item, cands []Candidate) (*Candidate, string) {
+	sorted := byPrice(cands)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return ctx.Coverage(sorted[i].Price.SupplierID) > ctx.Coverage(sorted[j].Price.SupplierID)
+	})
+	best := sorted[0]
+	return &best, fmt.Sprintf("Consolidating with %s, which can deliver %d of %d ingredients in this order, at %.2f/%s",
+		best.SupplierName(), ctx.Coverage(best.Price.SupplierID), len(ctx.Items), best.UnitPrice, best.Price.Unit)
+}
+
+// scoreWeighted combines price, favorite status and order coverage with the rule's weights
+type scoreWeighted struct{}
+
+func (scoreWeighted) Name() string { return models.StrategyScoreWeighted }
+
+func (scoreWeighted) Select(ctx *Context, rule models.SupplierSelectionRule, item Item, cands []Candidate) (*Candidate, string) {
+	minPrice := byPrice(cands)[0].UnitPrice
+
+	score := func(c Candidate) (float64, float64, float64, float64) {
+		priceScore := 1.0
+		if c.UnitPrice > 0 {
+			priceScore = minPrice / c.UnitPrice
+		}
+		favoriteScore := 0.0
+		if c.IsFavorite {
+			favoriteScore = 1
+		}
+		coverageScore := 0.0
+		if len(ctx.Items) > 0 {
+			coverageScore = float64(ctx.Coverage(c.Price.SupplierID)) / float64(len(ctx.Items))
+		}
+		total := rule.PriceWeight*priceScore + rule.FavoriteWeight*favoriteScore + rule.CoverageWeight*coverageScore
+		return total, priceScore, favoriteScore, coverageScore
+	}
+
+	sorted := byPrice(cands)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		si, _, _, _ := score(sorted[i])
+		sj, _, _, _ := score(sorted[j])
+		return si > sj
+	})
+	best := sorted[0]
+	total, p, f, cv := score(best)
+	return &best, fmt.Sprintf("Highest weighted score %.3f for %s (price %.2f×%.2f + favorite %.0f×%.2f + coverage %.2f×%.2f)",
+		total, best.SupplierName(), p, rule.PriceWeight, f, rule.FavoriteWeight, cv, rule.CoverageWeight)
+}
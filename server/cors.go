@@ -0,0 +1,36 @@
+package server
+
+import (
+	"adong-be/config"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMiddleware answers preflight requests and allows the configured origins. With an
+// explicit origin list, credentials (the auth cookies) are allowed for those origins only.
+func corsMiddleware(cfg config.ServerConfig) gin.HandlerFunc {
+	anyOrigin := cfg.AllowsAnyOrigin()
+	return func(c *gin.Context) {
+		origin := c.Request.Header.Get("Origin")
+		h := c.Writer.Header()
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Add("Vary", "Origin")
+			if origin != "" && cfg.AllowsOrigin(origin) {
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match")
+		// Clients read the version of a record from its ETag to send it back in If-Match
+		h.Set("Access-Control-Expose-Headers", "ETag")
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"adong-be/logger"
+	"adong-be/migrate"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// readinessTimeout bounds each readiness check, so a hung database fails the probe instead
+// of timing it out
+const readinessTimeout = 2 * time.Second
+
+// readinessReport is the body of /readyz: "ok" or "fail" for every check
+type readinessReport struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// readiness decides whether a replica should receive traffic: the connection pool has a
+// free connection, the database answers and its schema is at the version of this build
+type readiness struct {
+	db       *gorm.DB
+	migrator *migrate.Migrator
+	// migratorErr is set when the embedded migrations cannot be read
+	migratorErr error
+}
+
+func newReadiness(db *gorm.DB) *readiness {
+	m, err := migrate.New(db)
+	return &readiness{db: db, migrator: m, migratorErr: err}
+}
+
+// live answers liveness probes: the process serves requests, whatever the database does
+func live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ready answers readiness probes with 200 when every check passes and 503 otherwise. The
+// reasons are logged rather than returned, the route is public.
+func (r *readiness) ready(c *gin.Context) {
+	report := readinessReport{Status: "ok", Checks: make(map[string]string)}
+	check := func(name string, fn func(ctx context.Context) error) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		if err := fn(ctx); err != nil {
+			logger.From(c).Warn("readiness check failed", "check", name, "error", err)
+			report.Status = "fail"
+			report.Checks[name] = "fail"
+			return
+		}
+		report.Checks[name] = "ok"
+	}
+
+	check("pool", r.pool)
+	check("database", r.database)
+	check("migrations", r.migrations)
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+var errNoDatabase = errors.New("no database connection")
+
+// pool fails when every connection the pool may open is in use, new requests would queue
+func (r *readiness) pool(context.Context) error {
+	if r.db == nil {
+		return errNoDatabase
+	}
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return err
+	}
+	stats := sqlDB.Stats()
+	if stats.MaxOpenConnections > 0 && stats.InUse >= stats.MaxOpenConnections {
+		return fmt.Errorf("all %d connections in use, %d waits so far", stats.MaxOpenConnections, stats.WaitCount)
+	}
+	return nil
+}
+
+func (r *readiness) database(ctx context.Context) error {
+	if r.db == nil {
+		return errNoDatabase
+	}
+	return migrate.CheckDatabaseHealth(ctx, r.db)
+}
+
+func (r *readiness) migrations(ctx context.Context) error {
+	if r.db == nil {
+		return errNoDatabase
+	}
+	if r.migratorErr != nil {
+		return r.migratorErr
+	}
+	return r.migrator.Check(ctx)
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"adong-be/logger"
+	"adong-be/requestid"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextLogger gives the request a logger adding its request ID, and its trace and span
+// IDs when traced, to every line. Handlers and services take it with logger.From(c).
+func contextLogger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		requestID := requestid.Ensure(c)
+		l := logger.Log.With("request_id", requestID)
+		if span := trace.SpanFromContext(ctx); span.SpanContext().IsValid() {
+			span.SetAttributes(attribute.String("http.request.id", requestID))
+			l = l.With("trace_id", span.SpanContext().TraceID().String(), "span_id", span.SpanContext().SpanID().String())
+		}
+		c.Request = c.Request.WithContext(logger.NewContext(ctx, l))
+		c.Next()
+	}
+}
+
+// requestLog logs every request with its outcome and the user making it
+func requestLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+		status := c.Writer.Status()
+		userIDAfter, _ := c.Get("identity")
+		log := logger.From(c)
+		if len(c.Errors) > 0 {
+			log.Error("handler returned error",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"status", status,
+				"errors", c.Errors.String(),
+				"latency", latency.String(),
+				"user_id", userIDAfter,
+			)
+		} else if status >= 400 {
+			log.Error("request completed with error status",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"status", status,
+				"latency", latency.String(),
+				"user_id", userIDAfter,
+			)
+		} else {
+			log.Info("request completed",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"status", status,
+				"latency", latency.String(),
+				"user_id", userIDAfter,
+			)
+		}
+	}
+}
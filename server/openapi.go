@@ -0,0 +1,445 @@
+package server
+
+import (
+	"adong-be/auth"
+	"adong-be/delivery"
+	"adong-be/handler"
+	"adong-be/matching"
+	"adong-be/models"
+	"adong-be/openapi"
+	"adong-be/pricing"
+	"adong-be/rbac"
+	"adong-be/selection"
+	"net/http"
+
+	"github.com/hsdfat/go-auth-middleware/ginauth"
+)
+
+// apiInfo describes the API in its OpenAPI document
+var apiInfo = openapi.Info{
+	Title:       "Adong Food Management API",
+	Version:     "1.0",
+	Description: "Kitchens, dishes and recipes, orders, suppliers and their prices, and inventory. Errors carry a stable code, see apperr.",
+}
+
+// Query parameters the handlers read themselves
+var (
+	kitchenParam       = openapi.Param{Name: "kitchen_id", Description: "Only records of this kitchen"}
+	kitchenRequired    = openapi.Param{Name: "kitchen_id", Required: true}
+	ingredientParam    = openapi.Param{Name: "ingredient_id", Description: "Only records of this ingredient"}
+	statusParam        = openapi.Param{Name: "status", Description: "Only records with this status"}
+	fromParam          = openapi.Param{Name: "from_date", Description: "Only records from this date on"}
+	toParam            = openapi.Param{Name: "to_date", Description: "Only records up to this date"}
+	fromDate           = openapi.Param{Name: "from_date", Format: "date", Description: "First day, YYYY-MM-DD"}
+	toDate             = openapi.Param{Name: "to_date", Format: "date", Description: "Last day, YYYY-MM-DD"}
+	fromDateRequired   = openapi.Param{Name: "from_date", Format: "date", Required: true, Description: "First day, YYYY-MM-DD"}
+	toDateRequired     = openapi.Param{Name: "to_date", Format: "date", Required: true, Description: "Last day, YYYY-MM-DD"}
+	undeliverableParam = openapi.Param{Name: "undeliverable", Enum: []string{"exclude", "flag"}, Description: "Exclude suppliers that cannot deliver in time (default) or only flag them"}
+	idempotencyKey     = openapi.Param{Name: "Idempotency-Key", In: "header", Description: "Key of the request, e.g. a UUID. A retry with the same key and body gets the first response back instead of running again."}
+	ifMatch            = openapi.Param{Name: "If-Match", In: "header", Required: true, Description: "ETag of the record as last read. A record changed since is not changed again, the answer is 412 with its current state."}
+)
+
+// Bodies of several routes
+var (
+	messageBody   = openapi.Object{"message": ""}
+	recipePage    = page([]models.RecipeStandardDTO{})
+	supplierPrice = page([]models.SupplierPriceDTO{})
+)
+
+// page is a paginated list of items
+func page(items any) openapi.Object {
+	return openapi.Object{"data": items, "meta": models.PaginationMeta{}}
+}
+
+// data wraps v in a data property
+func data(v any) openapi.Object {
+	return openapi.Object{"data": v}
+}
+
+// saved is the body of a write returning the record with a message
+func saved(v any) openapi.Object {
+	return openapi.Object{"message": "", "data": v}
+}
+
+// apiRoutes documents every route of setupRouter, keyed like rbac.RouteTable.
+// TestEveryRouteIsDocumented fails for routes missing here.
+var apiRoutes = openapi.Routes{
+	// Authentication
+	"POST /auth/login": {
+		Summary:     "Sign in",
+		Description: "Users with two-factor authentication also send a TOTP or recovery code. Repeated failures are throttled (LOGIN_THROTTLED).",
+		Request:     auth.LoginRequest{},
+		Response:    ginauth.EnhancedLoginResponse{},
+	},
+	"POST /auth/register": {Summary: "Register a user", Request: ginauth.RegistrationRequest{}, Response: ginauth.RegistrationResponse{}, Status: http.StatusCreated},
+	"POST /auth/refresh": {
+		Summary:      "Refresh the access token",
+		Description:  "The refresh token is read from the body or the refresh_token cookie.",
+		Request:      openapi.Object{"refresh_token": ""},
+		OptionalBody: true,
+		Response:     ginauth.EnhancedRefreshResponse{},
+	},
+	"POST /auth/forgot-password":    {Summary: "Email a password reset link", Request: handler.ForgotPasswordRequest{}, Response: messageBody, Status: http.StatusAccepted},
+	"POST /auth/reset-password":     {Summary: "Reset a password with a reset token", Request: handler.ResetPasswordRequest{}, Response: messageBody},
+	"POST /auth/2fa/enroll":         {Summary: "Start two-factor enrollment", Request: handler.TwoFactorEnrollRequest{}, Response: openapi.Object{"secret": "", "otpauthUri": ""}},
+	"POST /auth/2fa/confirm":        {Summary: "Confirm two-factor enrollment", Request: handler.TwoFactorConfirmRequest{}, Response: openapi.Object{"message": "", "recoveryCodes": []string{}}},
+	"POST /auth/logout":             {Summary: "Sign out of this session", Response: ginauth.EnhancedLogoutResponse{}},
+	"POST /auth/logout-all":         {Summary: "Sign out of every session", Response: ginauth.EnhancedLogoutResponse{}},
+	"GET /auth/sessions":            {Summary: "List the caller's sessions", Response: ginauth.SessionsResponse{}},
+	"POST /auth/change-password":    {Summary: "Change the caller's password", Request: handler.ChangePasswordRequest{}, Response: messageBody},
+	"GET /auth/2fa":                 {Summary: "Two-factor status of the caller", Response: openapi.Object{"enabled": false, "required": false, "recoveryCodesLeft": 0}},
+	"POST /auth/2fa/disable":        {Summary: "Disable two-factor authentication", Request: handler.TwoFactorDisableRequest{}, Response: messageBody},
+	"POST /auth/2fa/recovery-codes": {Summary: "Replace the recovery codes", Request: handler.TwoFactorCodeRequest{}, Response: openapi.Object{"recoveryCodes": []string{}}},
+
+	// Ingredients
+	"GET /api/ingredients":        {Summary: "List ingredients", Query: models.PaginationParams{}, Response: page([]models.Ingredient{})},
+	"GET /api/ingredients/:id":    {Summary: "Get an ingredient", Response: models.Ingredient{}},
+	"POST /api/ingredients":       {Summary: "Create an ingredient", Request: models.Ingredient{}, Response: models.Ingredient{}, Status: http.StatusCreated},
+	"PUT /api/ingredients/:id":    {Summary: "Update an ingredient", Request: openapi.Patch(models.Ingredient{}), Response: models.Ingredient{}},
+	"DELETE /api/ingredients/:id": {Summary: "Delete an ingredient", Response: messageBody},
+
+	// Kitchens
+	"GET /api/kitchens":        {Summary: "List kitchens", Query: models.PaginationParams{}, Response: page([]models.Kitchen{})},
+	"GET /api/kitchens/my":     {Summary: "List the caller's kitchens", Response: data([]models.Kitchen{})},
+	"GET /api/kitchens/:id":    {Summary: "Get a kitchen", Response: models.Kitchen{}},
+	"POST /api/kitchens":       {Summary: "Create a kitchen", Request: models.Kitchen{}, Response: models.Kitchen{}, Status: http.StatusCreated},
+	"PUT /api/kitchens/:id":    {Summary: "Update a kitchen", Request: openapi.Patch(models.Kitchen{}), Response: models.Kitchen{}},
+	"DELETE /api/kitchens/:id": {Summary: "Delete a kitchen", Response: messageBody},
+
+	// Users
+	"GET /api/users":              {Summary: "List users", Query: models.PaginationParams{}, Response: page([]models.User{})},
+	"GET /api/users/:id":          {Summary: "Get a user", Response: models.User{}},
+	"POST /api/users":             {Summary: "Create a user", Request: handler.UserInput{}, Response: models.User{}, Status: http.StatusCreated},
+	"PUT /api/users/:id":          {Summary: "Update a user", Request: openapi.Patch(handler.UserInput{}), Response: models.User{}},
+	"DELETE /api/users/:id":       {Summary: "Delete a user", Response: messageBody},
+	"GET /api/users/:id/kitchens": {Summary: "List the kitchens of a user", Response: data([]models.UserKitchen{})},
+	"PUT /api/users/:id/kitchens": {Summary: "Replace the kitchens of a user", Request: []handler.UserKitchenRequest{}, Response: data([]models.UserKitchen{})},
+	"POST /api/users/:id/reset-password": {
+		Summary:     "Reset a user's password",
+		Description: "Emails a reset link, or returns it when mail is not configured.",
+		Response:    openapi.Object{"message": "", "emailSent": false, "resetUrl": "", "expiresAt": &openapi.Schema{Type: "string", Format: "date-time"}},
+	},
+	"DELETE /api/users/:id/two-factor":          {Summary: "Reset a user's two-factor authentication", Response: messageBody},
+	"GET /api/users/:id/login-status":           {Summary: "Failed sign-ins and lock of a user", Response: openapi.Object{"failures": 0, "lastFailure": &openapi.Schema{Type: "string", Format: "date-time", Nullable: true}, "locked": false}},
+	"POST /api/users/:id/unlock":                {Summary: "Unlock a user locked out by failed sign-ins", Response: messageBody},
+	"GET /api/users/:id/sessions":               {Summary: "List a user's sessions", Response: []models.UserSession{}},
+	"DELETE /api/users/:id/sessions":            {Summary: "Revoke every session of a user", Response: openapi.Object{"message": "", "revoked": 0}},
+	"DELETE /api/users/:id/sessions/:sessionId": {Summary: "Revoke a session of a user", Response: messageBody},
+	"GET /api/failed-logins": {
+		Summary:  "List failed sign-ins",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{{Name: "user_name"}, {Name: "user_id"}, {Name: "ip_address"}, {Name: "reason"}, fromDate, toDate},
+		Response: page([]models.FailedLogin{}),
+	},
+
+	// Service accounts
+	"GET /api/service-accounts":                         {Summary: "List service accounts", Response: data([]models.User{})},
+	"POST /api/service-accounts":                        {Summary: "Create a service account", Request: handler.ServiceAccountRequest{}, Response: models.User{}, Status: http.StatusCreated},
+	"DELETE /api/service-accounts/:id":                  {Summary: "Deactivate a service account", Response: messageBody},
+	"GET /api/service-accounts/:id/keys":                {Summary: "List the API keys of a service account", Response: data([]models.APIKey{})},
+	"POST /api/service-accounts/:id/keys":               {Summary: "Issue an API key", Description: "The key is returned only in this response.", Request: handler.APIKeyRequest{}, Response: openapi.Object{"key": "", "apiKey": models.APIKey{}}, Status: http.StatusCreated},
+	"POST /api/service-accounts/:id/keys/:keyId/rotate": {Summary: "Rotate an API key", Description: "The previous key stays valid for the grace period.", Request: handler.RotateAPIKeyRequest{}, OptionalBody: true, Response: openapi.Object{"key": "", "apiKey": models.APIKey{}, "previousKeyValidUntil": &openapi.Schema{Type: "string", Format: "date-time"}}, Status: http.StatusCreated},
+	"DELETE /api/service-accounts/:id/keys/:keyId":      {Summary: "Revoke an API key", Response: messageBody},
+
+	// Roles
+	"GET /api/permissions":    {Summary: "List the permissions roles can grant", Response: data([]rbac.Permission{})},
+	"GET /api/roles":          {Summary: "List roles", Response: data([]models.Role{})},
+	"GET /api/roles/:name":    {Summary: "Get a role", Response: models.Role{}},
+	"POST /api/roles":         {Summary: "Create a role", Request: handler.RoleRequest{}, Response: models.Role{}, Status: http.StatusCreated},
+	"PUT /api/roles/:name":    {Summary: "Update a role", Request: handler.RoleRequest{}, Response: models.Role{}},
+	"DELETE /api/roles/:name": {Summary: "Delete a role", Response: messageBody},
+	"GET /api/audit-logs": {
+		Summary:  "List the audit log",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam, {Name: "actor_user_id"}, {Name: "entity_type"}, {Name: "entity_id"}, {Name: "action"}, {Name: "request_id"}, fromDate, toDate},
+		Response: page([]models.AuditLog{}),
+	},
+
+	// Dishes
+	"GET /api/dishes":        {Summary: "List dishes", Query: models.PaginationParams{}, Response: page([]models.Dish{})},
+	"GET /api/dishes/:id":    {Summary: "Get a dish", Response: models.Dish{}},
+	"POST /api/dishes":       {Summary: "Create a dish", Request: models.Dish{}, Response: models.Dish{}, Status: http.StatusCreated},
+	"PUT /api/dishes/:id":    {Summary: "Update a dish", Request: openapi.Patch(models.Dish{}), Response: models.Dish{}},
+	"DELETE /api/dishes/:id": {Summary: "Delete a dish", Response: messageBody},
+
+	// Suppliers
+	"GET /api/suppliers":                        {Summary: "List suppliers", Query: models.PaginationParams{}, Response: page([]models.Supplier{})},
+	"GET /api/suppliers/:id":                    {Summary: "Get a supplier", Response: models.Supplier{}},
+	"POST /api/suppliers":                       {Summary: "Create a supplier", Request: models.Supplier{}, Response: models.Supplier{}, Status: http.StatusCreated},
+	"PUT /api/suppliers/:id":                    {Summary: "Update a supplier", Request: openapi.Patch(models.Supplier{}), Response: models.Supplier{}},
+	"DELETE /api/suppliers/:id":                 {Summary: "Delete a supplier", Response: messageBody},
+	"GET /api/suppliers/:id/delivery-schedules": {Summary: "Get the delivery calendar of a supplier", Response: openapi.Object{"supplierId": "", "schedules": []models.SupplierDeliverySchedule{}}},
+	"PUT /api/suppliers/:id/delivery-schedules": {Summary: "Replace the delivery calendar of a supplier", Request: handler.DeliverySchedulesRequest{}, Response: openapi.Object{"supplierId": "", "schedules": []models.SupplierDeliverySchedule{}}},
+	"GET /api/suppliers/:id/delivery-check": {
+		Summary:  "Check whether a supplier delivers on a date",
+		Params:   []openapi.Param{{Name: "date", Required: true, Description: "Delivery date, YYYY-MM-DD"}, kitchenParam},
+		Response: openapi.Object{"supplierId": "", "kitchenId": "", "deliveryDate": "", "delivery": delivery.Status{}},
+	},
+
+	// Recipe standards
+	"GET /api/recipe-standards":                                 {Summary: "List recipe standards", Query: models.PaginationParams{}, Response: recipePage},
+	"GET /api/recipe-standards/:id":                             {Summary: "Get a recipe standard", Response: models.RecipeStandardDTO{}},
+	"POST /api/recipe-standards":                                {Summary: "Create a recipe standard", Request: models.RecipeStandard{}, Response: models.RecipeStandardDTO{}, Status: http.StatusCreated},
+	"POST /api/recipe-standards/bulk":                           {Summary: "Create recipe standards", Request: []models.RecipeStandard{}, Response: openapi.Object{"message": "", "count": 0, "data": []models.RecipeStandardDTO{}}, Status: http.StatusCreated},
+	"PUT /api/recipe-standards/:id":                             {Summary: "Update a recipe standard", Params: []openapi.Param{ifMatch}, Request: handler.UpdateRecipeStandardRequest{}, Response: models.RecipeStandardDTO{}},
+	"DELETE /api/recipe-standards/:id":                          {Summary: "Delete a recipe standard", Response: messageBody},
+	"GET /api/recipe-standards/dish/:dishId":                    {Summary: "List the recipe standards of a dish", Query: models.PaginationParams{}, Response: recipePage},
+	"GET /api/recipe-standards/kitchen/:kitchenId":              {Summary: "List the recipe standards of a kitchen", Query: models.PaginationParams{}, Response: recipePage},
+	"GET /api/recipe-standards/dish/:dishId/kitchen/:kitchenId": {Summary: "List the recipe standards of a dish in a kitchen", Query: models.PaginationParams{}, Response: recipePage},
+
+	// Supplier prices
+	"GET /api/supplier-prices": {
+		Summary:  "List supplier prices",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{{Name: "effective_from", Description: "Only prices effective from this date on"}, {Name: "effective_to", Description: "Only prices effective up to this date"}},
+		Response: supplierPrice,
+	},
+	"GET /api/supplier-prices/ingredient/:ingredientId": {Summary: "List the prices of an ingredient", Query: models.PaginationParams{}, Response: supplierPrice},
+	"GET /api/supplier-prices/supplier/:supplierId":     {Summary: "List the prices of a supplier", Query: models.PaginationParams{}, Response: supplierPrice},
+	"GET /api/supplier-prices/:id":                      {Summary: "Get a supplier price", Response: models.SupplierPriceDTO{}},
+	"POST /api/supplier-prices":                         {Summary: "Create a supplier price", Request: models.SupplierPrice{}, Response: models.SupplierPriceDTO{}, Status: http.StatusCreated},
+	"PUT /api/supplier-prices/:id":                      {Summary: "Update a supplier price", Params: []openapi.Param{ifMatch}, Request: handler.UpdateSupplierPriceRequest{}, Response: models.SupplierPriceDTO{}},
+	"DELETE /api/supplier-prices/:id":                   {Summary: "Delete a supplier price", Response: messageBody},
+	"GET /api/supplier-prices/:id/terms": {
+		Summary:  "Get the pricing terms of a supplier price",
+		Response: openapi.Object{"productId": "", "listPrice": 0.0, "tiers": []models.SupplierPriceTier{}, "contracts": []models.SupplierContractPrice{}, "promotions": []models.SupplierPromotion{}},
+	},
+	"GET /api/supplier-prices/:id/quote": {
+		Summary:  "Quote a supplier price",
+		Params:   []openapi.Param{{Name: "quantity", Type: "number", Description: "Quantity to buy, 1 by default"}, {Name: "date", Format: "date", Description: "Day of the purchase, today by default"}, kitchenParam},
+		Response: openapi.Object{"quote": pricing.Quote{}, "quantity": 0.0, "totalCost": 0.0},
+	},
+	"PUT /api/supplier-prices/:id/tiers":       {Summary: "Replace the quantity tiers of a supplier price", Request: handler.PriceTiersRequest{}, Response: openapi.Object{"productId": "", "tiers": []models.SupplierPriceTier{}}},
+	"POST /api/supplier-prices/:id/contracts":  {Summary: "Add a kitchen contract price", Request: models.SupplierContractPrice{}, Response: models.SupplierContractPrice{}, Status: http.StatusCreated},
+	"DELETE /api/supplier-contract-prices/:id": {Summary: "Delete a kitchen contract price", Response: messageBody},
+	"POST /api/supplier-prices/:id/promotions": {Summary: "Add a promotion", Request: models.SupplierPromotion{}, Response: models.SupplierPromotion{}, Status: http.StatusCreated},
+	"DELETE /api/supplier-promotions/:id":      {Summary: "Delete a promotion", Response: messageBody},
+
+	// Supplier product mapping
+	"GET /api/supplier-products/unmapped": {
+		Summary:  "List supplier products not mapped to an ingredient",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{{Name: "supplier_id", Description: "Only products of this supplier"}},
+		Response: page([]handler.UnmappedProduct{}),
+	},
+	"GET /api/supplier-products/:id/suggestions": {
+		Summary:  "Suggest ingredients for a supplier product",
+		Params:   []openapi.Param{{Name: "limit", Type: "integer", Description: "Number of suggestions"}},
+		Response: openapi.Object{"productId": "", "productName": "", "unit": "", "suggestions": []matching.Suggestion{}},
+	},
+	"GET /api/supplier-products/:id/mappings": {
+		Summary:  "Get the ingredients a supplier product is mapped to",
+		Response: openapi.Object{"productId": "", "mappingStatus": "", "ingredientId": &openapi.Schema{Type: "string", Nullable: true}, "additionalMappings": []models.SupplierProductIngredient{}},
+	},
+	"POST /api/supplier-products/:id/mappings/confirm": {
+		Summary:  "Map a supplier product to ingredients",
+		Request:  handler.ConfirmMappingRequest{},
+		Response: openapi.Object{"message": "", "productId": "", "ingredientId": "", "additionalIngredientIds": []string{}},
+	},
+	"POST /api/supplier-products/:id/mappings/reject": {
+		Summary:  "Reject an ingredient suggested for a supplier product",
+		Request:  handler.RejectMappingRequest{},
+		Response: openapi.Object{"message": "", "productId": "", "mappingStatus": ""},
+	},
+
+	// Orders
+	"GET /api/orders": {
+		Summary:  "List orders",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam, statusParam, fromParam, toParam, {Name: "dish_id", Description: "Only orders with this dish"}, ingredientParam},
+		Response: page([]models.OrderDTO{}),
+	},
+	"GET /api/orders/:id":                                   {Summary: "Get an order", Response: models.OrderDTO{}},
+	"GET /api/orders/:id/ingredients/summary":               {Summary: "Total the ingredients of an order", Response: []handler.IngredientTotal{}},
+	"GET /api/orders/:id/ingredients/:ingredientId/summary": {Summary: "Total one ingredient of an order", Response: handler.IngredientTotal{}},
+	"GET /api/orders/:id/selected-suppliers": {
+		Summary:  "List the suppliers selected for an order",
+		Response: openapi.Object{"orderId": "", "kitchenId": "", "selections": []models.OrderIngredientSupplier{}, "count": 0},
+	},
+	"GET /api/orders/:id/suppliers-for-inventory": {
+		Summary:  "List an order's selected suppliers for creating inventory imports",
+		Params:   []openapi.Param{{Name: "supplier_id", Description: "Only this supplier"}},
+		Response: models.GetOrderSuppliersResponse{},
+	},
+	"GET /api/orders/:id/suppliers-with-highlight": {Summary: "List suppliers, flagging those selected for an order", Response: models.GetSuppliersForOrderResponse{}},
+	"POST /api/orders": {Summary: "Create an order", Params: []openapi.Param{idempotencyKey}, Request: models.Order{}, Response: models.OrderDTO{}, Status: http.StatusCreated},
+	"POST /api/orders/:id/supplier-requests": {
+		Summary:  "Save the suppliers selected for an order's ingredients",
+		Params:   []openapi.Param{idempotencyKey},
+		Request:  handler.SupplierSelectionsRequest{},
+		Response: openapi.Object{"message": "", "orderId": "", "selections": []models.OrderIngredientSupplier{}, "count": 0},
+	},
+	"PATCH /api/orders/:id/status": {Summary: "Change the status of an order", Params: []openapi.Param{ifMatch}, Request: handler.OrderStatusRequest{}, Response: openapi.Object{"message": "", "status": ""}},
+	"DELETE /api/orders/:id":       {Summary: "Cancel an order", Response: messageBody},
+	"GET /api/orders/:id/best-suppliers": {
+		Summary:  "Rank the suppliers of an order's ingredients",
+		Params:   []openapi.Param{undeliverableParam},
+		Response: openapi.Object{"orderId": "", "kitchenId": "", "orderDate": &openapi.Schema{Type: "string", Format: "date-time"}, "ingredients": []handler.IngredientSuppliers{}},
+	},
+	"POST /api/orders/best-suppliers": {
+		Summary:  "Rank the suppliers of ingredients",
+		Params:   []openapi.Param{undeliverableParam},
+		Request:  handler.BestSuppliersRequest{},
+		Response: openapi.Object{"kitchenId": "", "ingredients": []handler.IngredientSuppliers{}},
+	},
+	"POST /api/orders/:id/best-suppliers/consolidated": {
+		Summary:      "Plan the purchase of an order's ingredients from few suppliers",
+		Request:      handler.ConsolidationOptions{},
+		OptionalBody: true,
+		Response:     openapi.Object{"orderId": "", "kitchenId": "", "plan": selection.ConsolidationResult{}},
+	},
+	"POST /api/orders/best-suppliers/consolidated": {
+		Summary:  "Plan the purchase of ingredients from few suppliers",
+		Request:  handler.ConsolidatedSuppliersRequest{},
+		Response: openapi.Object{"kitchenId": "", "plan": selection.ConsolidationResult{}},
+	},
+
+	// Supplier selection rules
+	"GET /api/supplier-selection-rules": {
+		Summary:  "List supplier selection rules",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam},
+		Response: page([]models.SupplierSelectionRule{}),
+	},
+	"GET /api/supplier-selection-rules/strategies": {Summary: "List the selection strategies", Response: openapi.Object{"strategies": []string{}}},
+	"GET /api/supplier-selection-rules/:id":        {Summary: "Get a supplier selection rule", Response: models.SupplierSelectionRule{}},
+	"POST /api/supplier-selection-rules":           {Summary: "Create a supplier selection rule", Request: models.SupplierSelectionRule{}, Response: models.SupplierSelectionRule{}, Status: http.StatusCreated},
+	"PUT /api/supplier-selection-rules/:id":        {Summary: "Update a supplier selection rule", Request: openapi.Patch(models.SupplierSelectionRule{}), Response: models.SupplierSelectionRule{}},
+	"DELETE /api/supplier-selection-rules/:id":     {Summary: "Delete a supplier selection rule", Response: messageBody},
+
+	// Inventory stocks
+	"GET /api/inventory/stocks": {
+		Summary:  "List stock levels",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam, {Name: "low_stock", Enum: []string{"true"}, Description: "Only items below their minimum level"}},
+		Response: page([]models.InventoryStock{}),
+	},
+	"GET /api/inventory/stocks/:id":        {Summary: "Get a stock level", Response: data(models.InventoryStock{})},
+	"GET /api/inventory/stocks/query":      {Summary: "Get the stock of an ingredient in a kitchen", Params: []openapi.Param{kitchenRequired, {Name: "ingredient_id", Required: true}}, Response: data(models.InventoryStock{})},
+	"PUT /api/inventory/stocks/:id/levels": {Summary: "Set the minimum and maximum levels of a stock", Request: handler.UpdateStockLevelsRequest{}, Response: saved(models.InventoryStock{})},
+	"GET /api/inventory/stocks/alerts/low": {Summary: "List stocks below their minimum level", Params: []openapi.Param{kitchenParam}, Response: openapi.Object{"data": []models.InventoryStock{}, "count": 0}},
+	"GET /api/inventory/stocks/transactions": {
+		Summary:  "List the stock movements of an ingredient in a kitchen",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenRequired, {Name: "ingredient_id", Required: true}, {Name: "transaction_type", Description: "Only movements of this type"}, fromParam, toParam},
+		Response: page([]models.InventoryTransaction{}),
+	},
+	"GET /api/inventory/stocks/summary": {
+		Summary:  "Count the stock items of a kitchen",
+		Params:   []openapi.Param{kitchenRequired},
+		Response: data(openapi.Object{"totalItems": int64(0), "lowStockItems": int64(0), "outOfStockItems": int64(0), "totalValue": 0.0}),
+	},
+	"GET /api/inventory/stocks/valuation": {
+		Summary: "Value the stock of a kitchen at average prices",
+		Params:  []openapi.Param{kitchenRequired},
+		Response: openapi.Object{
+			"data":       []openapi.Object{{"ingredientId": "", "ingredientName": "", "quantity": 0.0, "unit": "", "averagePrice": 0.0, "totalValue": 0.0}},
+			"totalValue": 0.0,
+			"count":      0,
+		},
+	},
+
+	// Inventory imports
+	"GET /api/inventory/imports": {
+		Summary:  "List imports",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam, statusParam, fromParam, toParam},
+		Response: page([]models.InventoryImport{}),
+	},
+	"GET /api/inventory/imports/:id":                      {Summary: "Get an import", Response: data(models.InventoryImport{})},
+	"POST /api/inventory/imports":                         {Summary: "Create an import", Params: []openapi.Param{idempotencyKey}, Request: handler.CreateImportRequest{}, Response: saved(models.InventoryImport{}), Status: http.StatusCreated},
+	"POST /api/inventory/imports/from-request/:requestId": {Summary: "Create an import from an approved ingredient request", Params: []openapi.Param{idempotencyKey}, Response: saved(models.InventoryImport{}), Status: http.StatusCreated},
+	"PUT /api/inventory/imports/:id":                      {Summary: "Update a draft import", Params: []openapi.Param{ifMatch}, Request: handler.CreateImportRequest{}, Response: saved(models.InventoryImport{})},
+	"POST /api/inventory/imports/:id/approve":             {Summary: "Approve an import, adding its quantities to stock", Params: []openapi.Param{idempotencyKey, ifMatch}, Response: saved(models.InventoryImport{})},
+	"DELETE /api/inventory/imports/:id":                   {Summary: "Delete a draft import", Response: messageBody},
+
+	// Inventory exports
+	"GET /api/inventory/exports": {
+		Summary:  "List exports",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam, {Name: "export_type", Description: "Only exports of this type"}, statusParam, fromParam, toParam},
+		Response: page([]models.InventoryExport{}),
+	},
+	"GET /api/inventory/exports/:id":          {Summary: "Get an export", Response: data(models.InventoryExport{})},
+	"POST /api/inventory/exports":             {Summary: "Create an export", Params: []openapi.Param{idempotencyKey}, Request: handler.CreateExportRequest{}, Response: saved(models.InventoryExport{}), Status: http.StatusCreated},
+	"PUT /api/inventory/exports/:id":          {Summary: "Update a draft export", Params: []openapi.Param{ifMatch}, Request: handler.CreateExportRequest{}, Response: saved(models.InventoryExport{})},
+	"POST /api/inventory/exports/:id/approve": {Summary: "Approve an export, taking its quantities from stock", Params: []openapi.Param{idempotencyKey, ifMatch}, Response: saved(models.InventoryExport{})},
+	"DELETE /api/inventory/exports/:id":       {Summary: "Delete a draft export", Response: messageBody},
+
+	// Inventory adjustments
+	"GET /api/inventory/adjustments": {
+		Summary:  "List adjustments",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam, {Name: "adjustment_type", Description: "Only adjustments of this type"}, statusParam, fromParam, toParam},
+		Response: page([]models.InventoryAdjustment{}),
+	},
+	"GET /api/inventory/adjustments/:id":          {Summary: "Get an adjustment", Response: data(models.InventoryAdjustment{})},
+	"POST /api/inventory/adjustments":             {Summary: "Create an adjustment", Params: []openapi.Param{idempotencyKey}, Request: handler.CreateAdjustmentRequest{}, Response: saved(models.InventoryAdjustment{}), Status: http.StatusCreated},
+	"PUT /api/inventory/adjustments/:id":          {Summary: "Update a draft adjustment", Params: []openapi.Param{ifMatch}, Request: handler.CreateAdjustmentRequest{}, Response: saved(models.InventoryAdjustment{})},
+	"POST /api/inventory/adjustments/:id/approve": {Summary: "Approve an adjustment, correcting stock", Params: []openapi.Param{idempotencyKey, ifMatch}, Response: saved(models.InventoryAdjustment{})},
+	"DELETE /api/inventory/adjustments/:id":       {Summary: "Delete a draft adjustment", Response: messageBody},
+
+	// Ingredient requests
+	"GET /api/inventory/requests": {
+		Summary:  "List ingredient requests",
+		Query:    models.PaginationParams{},
+		Params:   []openapi.Param{kitchenParam, {Name: "order_id", Description: "Only requests of this order"}, statusParam, fromParam, toParam},
+		Response: page([]models.IngredientRequest{}),
+	},
+	"GET /api/inventory/requests/:id": {Summary: "Get an ingredient request", Response: data(models.IngredientRequest{})},
+	"POST /api/inventory/requests": {
+		Summary:  "Create an ingredient request",
+		Params:   []openapi.Param{idempotencyKey},
+		Request:  handler.CreateRequestInput{},
+		Response: openapi.Object{"message": "", "data": models.IngredientRequest{}, "supplierDeadlines": map[string]delivery.Status{}},
+		Status:   http.StatusCreated,
+	},
+	"POST /api/inventory/requests/from-order/:orderId": {
+		Summary:  "Create an ingredient request from an order's selected suppliers",
+		Params:   []openapi.Param{idempotencyKey},
+		Response: openapi.Object{"message": "", "data": models.IngredientRequest{}, "supplierDeadlines": map[string]delivery.Status{}},
+		Status:   http.StatusCreated,
+	},
+	"PUT /api/inventory/requests/:id":          {Summary: "Update a pending ingredient request", Params: []openapi.Param{ifMatch}, Request: handler.CreateRequestInput{}, Response: saved(models.IngredientRequest{})},
+	"POST /api/inventory/requests/:id/approve": {Summary: "Approve an ingredient request", Params: []openapi.Param{idempotencyKey, ifMatch}, Response: saved(models.IngredientRequest{})},
+	"DELETE /api/inventory/requests/:id":       {Summary: "Delete a pending ingredient request", Response: messageBody},
+
+	// Inventory reports
+	"GET /api/inventory/reports/stock-movement": {
+		Summary:  "Report the stock movements of a kitchen per ingredient",
+		Params:   []openapi.Param{kitchenRequired, fromDateRequired, toDateRequired},
+		Response: openapi.Object{"data": []handler.StockMovementReport{}, "from_date": "", "to_date": "", "count": 0},
+	},
+	"GET /api/inventory/reports/expiry-alerts": {
+		Summary:  "List stock expiring soon",
+		Params:   []openapi.Param{kitchenRequired, {Name: "days_ahead", Type: "integer", Description: "Days to look ahead, 30 by default"}},
+		Response: openapi.Object{"data": []handler.ExpiryAlert{}, "days_ahead": "", "count": 0},
+	},
+	"GET /api/inventory/reports/stock-value-trend": {
+		Summary:  "Report the stock value of a kitchen over time",
+		Params:   []openapi.Param{kitchenRequired, fromDateRequired, toDateRequired, {Name: "interval", Enum: []string{"day", "week", "month"}, Description: "Period of each value, day by default"}},
+		Response: openapi.Object{"data": []handler.StockValueTrend{}, "from_date": "", "to_date": "", "interval": "", "count": 0},
+	},
+	"GET /api/inventory/reports/transaction-summary": {
+		Summary:  "Total the stock movements of a kitchen by type",
+		Params:   []openapi.Param{kitchenRequired, fromParam, toParam},
+		Response: openapi.Object{"data": []handler.TransactionSummary{}, "count": 0},
+	},
+	"GET /api/inventory/reports/top-consumed": {
+		Summary:  "List the ingredients a kitchen consumed most",
+		Params:   []openapi.Param{kitchenRequired, fromParam, toParam, {Name: "limit", Type: "integer", Description: "Number of ingredients, 10 by default"}},
+		Response: openapi.Object{"data": []handler.TopConsumedIngredient{}, "count": 0, "limit": ""},
+	},
+
+	// Service
+	"GET /health": {Summary: "Liveness check, kept for existing probes", Response: openapi.Object{"status": ""}},
+	"GET /livez":  {Summary: "Liveness check", Description: "Answers while the process serves requests, whatever the state of the database.", Response: openapi.Object{"status": ""}},
+	"GET /readyz": {
+		Summary:     "Readiness check",
+		Description: "Checks the connection pool has a free connection, the database answers and every migration of this build is applied. Answers 503 with the failing checks otherwise.",
+		Response:    readinessReport{},
+	},
+	"GET /metrics":      {Summary: "Prometheus metrics", Description: "Requests by route and status, connection pool, business events and background jobs, in the Prometheus text format."},
+	"GET /openapi.json": {Summary: "This document"},
+	"GET /docs":         {Summary: "Browse this document"},
+}
@@ -1,59 +1,75 @@
 package server
 
 import (
+	"adong-be/audit"
 	"adong-be/auth"
+	"adong-be/auth/limiter"
+	"adong-be/config"
 	"adong-be/handler"
-	"adong-be/logger"
+	"adong-be/idempotency"
+	"adong-be/mail"
+	"adong-be/metrics"
+	"adong-be/openapi"
+	"adong-be/rbac"
+	"adong-be/repository"
+	"adong-be/requestid"
+	"adong-be/service"
 	"adong-be/store"
+	"adong-be/telemetry"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hsdfat/go-auth-middleware/ginauth"
 )
 
-// AdminOnlyMiddleware checks if the user has Admin role
-func AdminOnlyMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		role, exists := c.Get("user_role")
-		if !exists || role != "Admin" {
-			c.JSON(403, gin.H{
-				"code":    403,
-				"success": false,
-				"message": "Admin access required",
-			})
-			c.Abort()
-			return
-		}
-		c.Next()
-	}
+// rolesCacheTTL bounds how long a role change made outside the roles API takes to apply
+const rolesCacheTTL = time.Minute
+
+// SetupRouter builds the HTTP engine from cfg, serving data from st
+func SetupRouter(cfg *config.Config, st *store.Store) *gin.Engine {
+	r, _ := setupRouter(cfg, st)
+	return r
 }
 
-func SetupRouter() *gin.Engine {
+// setupRouter builds the engine and returns the permission declared for each route
+func setupRouter(cfg *config.Config, st *store.Store) (*gin.Engine, rbac.RouteTable) {
 	r := gin.Default()
-
+	// c.Done, c.Value and friends read the request context, which carries the request's
+	// logger and span, so queries run with db.WithContext(c) join the request's trace
+	r.ContextWithFallback = true
+
+	// Requests by route and status, see /metrics
+	r.Use(metrics.Middleware())
+	// A span per request, then the request ID and a logger carrying both, see logger.From
+	r.Use(telemetry.Middleware())
+	r.Use(requestid.Middleware())
+	r.Use(contextLogger())
 	// CORS middleware - must be registered before routes
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-		c.Next()
-	})
+	r.Use(corsMiddleware(cfg.Server))
+	// Request ID, IP and user agent for the audit log
+	r.Use(audit.Middleware())
+	// The document is built from the route table once every route is registered
+	spec := new(openapi.Document)
+	if cfg.Server.ValidateRequests {
+		r.Use(openapi.Validate(spec))
+	}
 
 	// Create user provider
 
 	// Create enhanced token storage
 	// tokenStorage := core.NewInMemoryTokenStorage()
 
+	// Every route declares the permission it requires, see rbac.Router
+	authorizer := rbac.NewAuthorizer(st, rolesCacheTTL)
+	routes := rbac.RouteTable{}
+	root := rbac.NewRouter(&r.RouterGroup, authorizer, routes)
+
 	// Create enhanced auth middleware
 	authMiddleware := ginauth.NewEnhanced(ginauth.EnhancedAuthConfig{
-		SecretKey:           "your-access-token-secret-key",
-		RefreshSecretKey:    "your-refresh-token-secret-key", // Should be different
-		AccessTokenTimeout:  24 * time.Hour,                  // Short-lived access tokens
-		RefreshTokenTimeout: 7 * 24 * time.Hour,              // 7 days refresh tokens
+		SecretKey:           cfg.Auth.AccessSecret,
+		RefreshSecretKey:    cfg.Auth.RefreshSecret,
+		AccessTokenTimeout:  cfg.Auth.AccessTokenTTL,
+		RefreshTokenTimeout: cfg.Auth.RefreshTokenTTL,
 
 		TokenLookup:   "header:Authorization,cookie:jwt",
 		TokenHeadName: "Bearer",
@@ -65,160 +81,234 @@ func SetupRouter() *gin.Engine {
 		CookieName:        "access_token",
 		RefreshCookieName: "refresh_token",
 		CookieHTTPOnly:    true,
-		CookieSecure:      false, // Set to true in production with HTTPS
-		CookieDomain:      "",
+		CookieSecure:      cfg.Auth.CookieSecure,
+		CookieDomain:      cfg.Auth.CookieDomain,
 
 		// Storage and providers
-		TokenStorage: store.DB,
-		UserProvider: store.DB,
-		UserCreator:  store.DB, // Enable user creation for registration
+		TokenStorage: st,
+		UserProvider: st,
+		UserCreator:  st, // Enable user creation for registration
+
+		// Authentication function - supports both hashed and plain text passwords, followed
+		// by the TOTP step for users with two-factor authentication
+		Authenticator: auth.CreateTwoFactorAuthenticator(st, auth.CreatePasswordAuthenticator(st, cfg.Auth.PlaintextFallbackUntil)),
 
-		// Authentication function - supports both hashed and plain text passwords
-		Authenticator: auth.CreateDualPasswordAuthenticator(store.DB),
+		// Tokens are only accepted for roles defined in the roles table
+		RoleAuthorizator: func(role string, c *gin.Context) bool {
+			return authorizer.RoleExists(role)
+		},
 
-		// Role-based authorization (example: only admin and user roles allowed)
-		RoleAuthorizator: ginauth.CreateRoleAuthorizator("Admin", "user", "moderator"),
+		// Errors use the API's codes and localized messages
+		Unauthorized: auth.Unauthorized,
 
 		// Registration configuration
-		EnableRegistration: true,              // Enable registration endpoint
-		RegisterableRoles:  []string{"user"},  // Only 'user' role can self-register
-		DefaultRole:        "user",            // Default role for new users
+		EnableRegistration: cfg.Auth.EnableRegistration,
+		RegisterableRoles:  []string{"user"}, // Only 'user' role can self-register
+		DefaultRole:        "user",           // Default role for new users
 
 		// Security settings
-		MaxConcurrentSessions: 5,         // Max 5 concurrent sessions per user
-		SingleSessionMode:     false,     // Allow multiple sessions
+		MaxConcurrentSessions: cfg.Auth.MaxConcurrentSessions,
+		SingleSessionMode:     cfg.Auth.SingleSessionMode,
 		EnableTokenRevocation: true,      // Enable token revocation on logout
-		CleanupInterval:       time.Hour, // Cleanup expired tokens every hour
+		CleanupInterval:       time.Hour, // Not used by the middleware, main runs the cleanup as an app worker
 	})
 
-	// Public routes
-	r.POST("/auth/login", authMiddleware.LoginHandler)
-	r.POST("/auth/register", authMiddleware.RegisterHandler)
-	r.POST("/auth/refresh", authMiddleware.RefreshHandler)
-	authenticated := r.Group("/auth")
+	// Public routes. Everything checking a password is throttled per user name and address.
+	loginGuard := newLoginGuard(cfg.Auth, st)
+	root.POST("/auth/login", rbac.Public, loginGuard.Middleware(), authMiddleware.LoginHandler)
+	root.POST("/auth/register", rbac.Public, authMiddleware.RegisterHandler)
+	root.POST("/auth/refresh", rbac.Public, authMiddleware.RefreshHandler)
+
+	passwordHandler := handler.NewPasswordHandler(service.NewPasswords(repository.NewPostgresPasswords(st.GormClient), st,
+		mail.New(cfg.Mail), cfg.Auth.PasswordPolicy, cfg.Auth.PasswordResetTTL, cfg.Auth.PasswordResetURL))
+	root.POST("/auth/forgot-password", rbac.Public, passwordHandler.ForgotPassword)
+	root.POST("/auth/reset-password", rbac.Public, passwordHandler.ResetPassword)
+
+	// Enrollment checks the password itself, users whose role requires two-factor
+	// authentication have to enroll before they can sign in
+	twoFactorHandler := handler.NewTwoFactorHandler(st, cfg.Auth.TOTPIssuer)
+	root.POST("/auth/2fa/enroll", rbac.Public, loginGuard.Middleware(), twoFactorHandler.EnrollTwoFactor)
+	root.POST("/auth/2fa/confirm", rbac.Public, loginGuard.Middleware(), twoFactorHandler.ConfirmTwoFactor)
+	authenticated := root.Group("/auth")
 	authenticated.Use(authMiddleware.MiddlewareFunc())
 	{
-		authenticated.POST("/logout", authMiddleware.LogoutHandler)
-		authenticated.POST("/logout-all", authMiddleware.LogoutAllHandler)
-		authenticated.GET("/sessions", authMiddleware.GetUserSessionsHandler)
+		authenticated.POST("/logout", rbac.Authenticated, authMiddleware.LogoutHandler)
+		authenticated.POST("/logout-all", rbac.Authenticated, authMiddleware.LogoutAllHandler)
+		authenticated.GET("/sessions", rbac.Authenticated, authMiddleware.GetUserSessionsHandler)
+		authenticated.POST("/change-password", rbac.Authenticated, passwordHandler.ChangePassword)
+		authenticated.GET("/2fa", rbac.Authenticated, twoFactorHandler.GetTwoFactorStatus)
+		authenticated.POST("/2fa/disable", rbac.Authenticated, twoFactorHandler.DisableTwoFactor)
+		authenticated.POST("/2fa/recovery-codes", rbac.Authenticated, twoFactorHandler.RegenerateRecoveryCodes)
 	}
 
 	// Request logging middleware with user identity
-	r.Use(func(c *gin.Context) {
-		start := time.Now()
-		c.Next()
-		latency := time.Since(start)
-		status := c.Writer.Status()
-		userIDAfter, _ := c.Get("identity")
-		if len(c.Errors) > 0 {
-			logger.Log.Error("handler returned error",
-				"method", c.Request.Method,
-				"path", c.Request.URL.Path,
-				"status", status,
-				"errors", c.Errors.String(),
-				"latency", latency.String(),
-				"user_id", userIDAfter,
-			)
-		} else if status >= 400 {
-			logger.Log.Error("request completed with error status",
-				"method", c.Request.Method,
-				"path", c.Request.URL.Path,
-				"status", status,
-				"latency", latency.String(),
-				"user_id", userIDAfter,
-			)
-		} else {
-			logger.Log.Info("request completed",
-				"method", c.Request.Method,
-				"path", c.Request.URL.Path,
-				"status", status,
-				"latency", latency.String(),
-				"user_id", userIDAfter,
-			)
-		}
-	})
+	r.Use(requestLog())
 
 	// API routes
-	api := r.Group("/api")
-	api.Use(authMiddleware.MiddlewareFunc())
+	api := root.Group("/api")
+	// Integrations authenticate with an X-API-Key, everybody else with a token
+	api.Use(auth.APIKeyOrToken(st, authMiddleware.MiddlewareFunc()))
+	// Creating and approving documents may be retried safely with an Idempotency-Key
+	idempotent := idempotency.Middleware(idempotency.NewPostgres(st.GormClient))
 	{
-		api.GET("/ingredients", handler.GetIngredients)
-		api.GET("/ingredients/:id", handler.GetIngredient)
-		api.POST("/ingredients", handler.CreateIngredient)
-		api.PUT("/ingredients/:id", handler.UpdateIngredient)
-		api.DELETE("/ingredients/:id", handler.DeleteIngredient)
-
-			api.GET("/kitchens", handler.GetKitchens)
-			api.GET("/kitchens/my", handler.GetMyKitchens)
-		api.GET("/kitchens/:id", handler.GetKitchen)
-		api.POST("/kitchens", handler.CreateKitchen)
-		api.PUT("/kitchens/:id", handler.UpdateKitchen)
-		api.DELETE("/kitchens/:id", handler.DeleteKitchen)
-
-		// User management routes - Admin only
+		ingredientHandler := handler.NewIngredientHandler(st.GormClient)
+		api.GET("/ingredients", rbac.IngredientRead, ingredientHandler.GetIngredients)
+		api.GET("/ingredients/:id", rbac.IngredientRead, ingredientHandler.GetIngredient)
+		api.POST("/ingredients", rbac.IngredientWrite, ingredientHandler.CreateIngredient)
+		api.PUT("/ingredients/:id", rbac.IngredientWrite, ingredientHandler.UpdateIngredient)
+		api.DELETE("/ingredients/:id", rbac.IngredientWrite, ingredientHandler.DeleteIngredient)
+
+		kitchenHandler := handler.NewKitchenHandler(st.GormClient)
+		api.GET("/kitchens", rbac.KitchenRead, kitchenHandler.GetKitchens)
+		api.GET("/kitchens/my", rbac.Authenticated, kitchenHandler.GetMyKitchens)
+		api.GET("/kitchens/:id", rbac.KitchenRead, kitchenHandler.GetKitchen)
+		api.POST("/kitchens", rbac.KitchenWrite, kitchenHandler.CreateKitchen)
+		api.PUT("/kitchens/:id", rbac.KitchenWrite, kitchenHandler.UpdateKitchen)
+		api.DELETE("/kitchens/:id", rbac.KitchenWrite, kitchenHandler.DeleteKitchen)
+
+		// User management routes
+		userHandler := handler.NewUserHandler(st.GormClient, service.NewUsers(repository.NewPostgresUsers(st.GormClient), st, cfg.Auth.PasswordPolicy))
+		loginLockHandler := handler.NewLoginLockHandler(st.GormClient, loginGuard)
+		sessionHandler := handler.NewSessionHandler(st)
 		users := api.Group("/users")
-		users.Use(AdminOnlyMiddleware())
 		{
-			users.GET("", handler.GetUsers)
-			users.GET("/:id", handler.GetUser)
-			users.POST("", handler.CreateUser)
-			users.PUT("/:id", handler.UpdateUser)
-			users.DELETE("/:id", handler.DeleteUser)
+			users.GET("", rbac.UserRead, userHandler.GetUsers)
+			users.GET("/:id", rbac.UserRead, userHandler.GetUser)
+			users.POST("", rbac.UserWrite, userHandler.CreateUser)
+			users.PUT("/:id", rbac.UserWrite, userHandler.UpdateUser)
+			users.DELETE("/:id", rbac.UserWrite, userHandler.DeleteUser)
+			users.GET("/:id/kitchens", rbac.UserRead, userHandler.GetUserKitchens)
+			users.PUT("/:id/kitchens", rbac.UserWrite, userHandler.ReplaceUserKitchens)
+			users.POST("/:id/reset-password", rbac.UserWrite, passwordHandler.AdminResetPassword)
+			users.DELETE("/:id/two-factor", rbac.UserWrite, twoFactorHandler.AdminResetTwoFactor)
+			users.GET("/:id/login-status", rbac.UserRead, loginLockHandler.GetLoginStatus)
+			users.POST("/:id/unlock", rbac.UserWrite, loginLockHandler.UnlockUser)
+			users.GET("/:id/sessions", rbac.UserRead, sessionHandler.GetUserSessions)
+			users.DELETE("/:id/sessions", rbac.UserWrite, sessionHandler.RevokeUserSessions)
+			users.DELETE("/:id/sessions/:sessionId", rbac.UserWrite, sessionHandler.RevokeUserSession)
 		}
+		api.GET("/failed-logins", rbac.AuditRead, loginLockHandler.GetFailedLogins)
 
-		api.GET("/dishes", handler.GetDishes)
-		api.GET("/dishes/:id", handler.GetDish)
-		api.POST("/dishes", handler.CreateDish)
-		api.PUT("/dishes/:id", handler.UpdateDish)
-		api.DELETE("/dishes/:id", handler.DeleteDish)
-
-		api.GET("/suppliers", handler.GetSuppliers)
-		api.GET("/suppliers/:id", handler.GetSupplier)
-		api.POST("/suppliers", handler.CreateSupplier)
-		api.PUT("/suppliers/:id", handler.UpdateSupplier)
-		api.DELETE("/suppliers/:id", handler.DeleteSupplier)
-
-		api.GET("/recipe-standards", handler.GetRecipeStandards)
-		api.GET("/recipe-standards/:id", handler.GetRecipeStandard)
-		api.POST("/recipe-standards", handler.CreateRecipeStandard)
-		api.POST("/recipe-standards/bulk", handler.CreateRecipeStandardsBulk)
-		api.PUT("/recipe-standards/:id", handler.UpdateRecipeStandard)
-		api.DELETE("/recipe-standards/:id", handler.DeleteRecipeStandard)
-		api.GET("/recipe-standards/dish/:dishId", handler.GetRecipeStandardsByDish)
-		api.GET("/recipe-standards/kitchen/:kitchenId", handler.GetRecipeStandardsByKitchen)
-		api.GET("/recipe-standards/dish/:dishId/kitchen/:kitchenId", handler.GetRecipeStandardsByDishAndKitchen)
-
-		api.GET("/supplier-prices", handler.GetSupplierPrices)
-		api.GET("/supplier-prices/ingredient/:ingredientId", handler.GetSupplierPricesByIngredient)
-		api.GET("/supplier-prices/supplier/:supplierId", handler.GetSupplierPricesBySupplier)
-		api.GET("/supplier-prices/:id", handler.GetSupplierPrice)
-		api.POST("/supplier-prices", handler.CreateSupplierPrice)
-		api.PUT("/supplier-prices/:id", handler.UpdateSupplierPrice)
-		api.DELETE("/supplier-prices/:id", handler.DeleteSupplierPrice)
-
-		api.GET("/orders", handler.GetOrders)
-		api.GET("/orders/:id", handler.GetOrder)
-		api.GET("/orders/:id/ingredients/summary", handler.GetOrderIngredientsSummary)
-		api.GET("/orders/:id/ingredients/:ingredientId/summary", handler.GetOrderIngredientSummary)
-		api.GET("/orders/:id/selected-suppliers", handler.GetOrderSelectedSuppliers)
-		api.GET("/orders/:id/suppliers-for-inventory", handler.GetOrderSuppliersForInventory)
-		api.GET("/orders/:id/suppliers-with-highlight", handler.GetSuppliersWithOrderHighlight)
-		api.POST("/orders", handler.CreateOrder)
-		api.POST("/orders/:id/supplier-requests", handler.SaveOrderIngredientsWithSupplier)
-		api.PATCH("/orders/:id/status", handler.UpdateOrderStatus)
-		api.DELETE("/orders/:id", handler.DeleteOrder)
+		// Service accounts and their API keys
+		serviceAccountHandler := handler.NewServiceAccountHandler(st)
+		serviceAccounts := api.Group("/service-accounts")
+		{
+			serviceAccounts.GET("", rbac.ServiceAccountManage, serviceAccountHandler.GetServiceAccounts)
+			serviceAccounts.POST("", rbac.ServiceAccountManage, serviceAccountHandler.CreateServiceAccount)
+			serviceAccounts.DELETE("/:id", rbac.ServiceAccountManage, serviceAccountHandler.DeleteServiceAccount)
+			serviceAccounts.GET("/:id/keys", rbac.ServiceAccountManage, serviceAccountHandler.GetAPIKeys)
+			serviceAccounts.POST("/:id/keys", rbac.ServiceAccountManage, serviceAccountHandler.CreateAPIKey)
+			serviceAccounts.POST("/:id/keys/:keyId/rotate", rbac.ServiceAccountManage, serviceAccountHandler.RotateAPIKey)
+			serviceAccounts.DELETE("/:id/keys/:keyId", rbac.ServiceAccountManage, serviceAccountHandler.RevokeAPIKey)
+		}
+
+		// Roles and permissions
+		roleHandler := handler.NewRoleHandler(st.GormClient, authorizer)
+		api.GET("/permissions", rbac.RoleManage, roleHandler.GetPermissions)
+		roles := api.Group("/roles")
+		{
+			roles.GET("", rbac.RoleManage, roleHandler.GetRoles)
+			roles.GET("/:name", rbac.RoleManage, roleHandler.GetRole)
+			roles.POST("", rbac.RoleManage, roleHandler.CreateRole)
+			roles.PUT("/:name", rbac.RoleManage, roleHandler.UpdateRole)
+			roles.DELETE("/:name", rbac.RoleManage, roleHandler.DeleteRole)
+		}
+
+		auditLogHandler := handler.NewAuditLogHandler(st.GormClient)
+		api.GET("/audit-logs", rbac.AuditRead, auditLogHandler.GetAuditLogs)
+
+		dishHandler := handler.NewDishHandler(service.NewRecipes(repository.NewPostgresRecipes(st.GormClient)))
+		api.GET("/dishes", rbac.DishRead, dishHandler.GetDishes)
+		api.GET("/dishes/:id", rbac.DishRead, dishHandler.GetDish)
+		api.POST("/dishes", rbac.DishWrite, dishHandler.CreateDish)
+		api.PUT("/dishes/:id", rbac.DishWrite, dishHandler.UpdateDish)
+		api.DELETE("/dishes/:id", rbac.DishWrite, dishHandler.DeleteDish)
+
+		supplierHandler := handler.NewSupplierHandler(st.GormClient, service.NewSuppliers(repository.NewPostgresSuppliers(st.GormClient)))
+		api.GET("/suppliers", rbac.SupplierRead, supplierHandler.GetSuppliers)
+		api.GET("/suppliers/:id", rbac.SupplierRead, supplierHandler.GetSupplier)
+		api.POST("/suppliers", rbac.SupplierWrite, supplierHandler.CreateSupplier)
+		api.PUT("/suppliers/:id", rbac.SupplierWrite, supplierHandler.UpdateSupplier)
+		api.DELETE("/suppliers/:id", rbac.SupplierWrite, supplierHandler.DeleteSupplier)
+		api.GET("/suppliers/:id/delivery-schedules", rbac.SupplierRead, supplierHandler.GetSupplierDeliverySchedules)
+		api.PUT("/suppliers/:id/delivery-schedules", rbac.SupplierWrite, supplierHandler.ReplaceSupplierDeliverySchedules)
+		api.GET("/suppliers/:id/delivery-check", rbac.SupplierRead, supplierHandler.CheckSupplierDelivery)
+
+		recipeStandardHandler := handler.NewRecipeStandardHandler(st.GormClient)
+		api.GET("/recipe-standards", rbac.RecipeStandardRead, recipeStandardHandler.GetRecipeStandards)
+		api.GET("/recipe-standards/:id", rbac.RecipeStandardRead, recipeStandardHandler.GetRecipeStandard)
+		api.POST("/recipe-standards", rbac.RecipeStandardWrite, recipeStandardHandler.CreateRecipeStandard)
+		api.POST("/recipe-standards/bulk", rbac.RecipeStandardWrite, recipeStandardHandler.CreateRecipeStandardsBulk)
+		api.PUT("/recipe-standards/:id", rbac.RecipeStandardWrite, recipeStandardHandler.UpdateRecipeStandard)
+		api.DELETE("/recipe-standards/:id", rbac.RecipeStandardWrite, recipeStandardHandler.DeleteRecipeStandard)
+		api.GET("/recipe-standards/dish/:dishId", rbac.RecipeStandardRead, dishHandler.GetRecipeStandardsByDish)
+		api.GET("/recipe-standards/kitchen/:kitchenId", rbac.RecipeStandardRead, recipeStandardHandler.GetRecipeStandardsByKitchen)
+		api.GET("/recipe-standards/dish/:dishId/kitchen/:kitchenId", rbac.RecipeStandardRead, recipeStandardHandler.GetRecipeStandardsByDishAndKitchen)
+
+		supplierPriceHandler := handler.NewSupplierPriceHandler(st.GormClient)
+		api.GET("/supplier-prices", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierPrices)
+		api.GET("/supplier-prices/ingredient/:ingredientId", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierPricesByIngredient)
+		api.GET("/supplier-prices/supplier/:supplierId", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierPricesBySupplier)
+		api.GET("/supplier-prices/:id", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierPrice)
+		api.POST("/supplier-prices", rbac.SupplierPriceWrite, supplierPriceHandler.CreateSupplierPrice)
+		api.PUT("/supplier-prices/:id", rbac.SupplierPriceWrite, supplierPriceHandler.UpdateSupplierPrice)
+		api.DELETE("/supplier-prices/:id", rbac.SupplierPriceWrite, supplierPriceHandler.DeleteSupplierPrice)
+		// Pricing terms: quantity tiers, kitchen contract prices, promotions
+		api.GET("/supplier-prices/:id/terms", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierPriceTerms)
+		api.GET("/supplier-prices/:id/quote", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierPriceQuote)
+		api.PUT("/supplier-prices/:id/tiers", rbac.SupplierPriceWrite, supplierPriceHandler.ReplaceSupplierPriceTiers)
+		api.POST("/supplier-prices/:id/contracts", rbac.SupplierPriceWrite, supplierPriceHandler.CreateSupplierContractPrice)
+		api.DELETE("/supplier-contract-prices/:id", rbac.SupplierPriceWrite, supplierPriceHandler.DeleteSupplierContractPrice)
+		api.POST("/supplier-prices/:id/promotions", rbac.SupplierPriceWrite, supplierPriceHandler.CreateSupplierPromotion)
+		api.DELETE("/supplier-promotions/:id", rbac.SupplierPriceWrite, supplierPriceHandler.DeleteSupplierPromotion)
+		// Supplier product onboarding: unmapped queue and ingredient mapping
+		api.GET("/supplier-products/unmapped", rbac.SupplierPriceRead, supplierPriceHandler.GetUnmappedSupplierProducts)
+		api.GET("/supplier-products/:id/suggestions", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierProductSuggestions)
+		api.GET("/supplier-products/:id/mappings", rbac.SupplierPriceRead, supplierPriceHandler.GetSupplierProductMappings)
+		api.POST("/supplier-products/:id/mappings/confirm", rbac.SupplierProductMap, supplierPriceHandler.ConfirmSupplierProductMapping)
+		api.POST("/supplier-products/:id/mappings/reject", rbac.SupplierProductMap, supplierPriceHandler.RejectSupplierProductMapping)
+
+		orderHandler := handler.NewOrderHandler(st.GormClient, service.NewOrders(repository.NewPostgresOrders(st.GormClient)))
+		api.GET("/orders", rbac.OrderRead, orderHandler.GetOrders)
+		api.GET("/orders/:id", rbac.OrderRead, orderHandler.GetOrder)
+		api.GET("/orders/:id/ingredients/summary", rbac.OrderRead, orderHandler.GetOrderIngredientsSummary)
+		api.GET("/orders/:id/ingredients/:ingredientId/summary", rbac.OrderRead, orderHandler.GetOrderIngredientSummary)
+		api.GET("/orders/:id/selected-suppliers", rbac.OrderRead, orderHandler.GetOrderSelectedSuppliers)
+		api.GET("/orders/:id/suppliers-for-inventory", rbac.OrderRead, orderHandler.GetOrderSuppliersForInventory)
+		api.GET("/orders/:id/suppliers-with-highlight", rbac.OrderRead, orderHandler.GetSuppliersWithOrderHighlight)
+		api.POST("/orders", rbac.OrderWrite, idempotent, orderHandler.CreateOrder)
+		api.POST("/orders/:id/supplier-requests", rbac.OrderWrite, idempotent, orderHandler.SaveOrderIngredientsWithSupplier)
+		api.PATCH("/orders/:id/status", rbac.OrderWrite, orderHandler.UpdateOrderStatus)
+		api.DELETE("/orders/:id", rbac.OrderCancel, orderHandler.DeleteOrder)
 
 		// Best supplier selection - returns data to frontend only
-		api.GET("/orders/:id/best-suppliers", handler.GetBestSuppliersForOrder)
-		api.POST("/orders/best-suppliers", handler.GetBestSuppliersForIngredients)
+		api.GET("/orders/:id/best-suppliers", rbac.OrderRead, orderHandler.GetBestSuppliersForOrder)
+		api.POST("/orders/best-suppliers", rbac.OrderRead, orderHandler.GetBestSuppliersForIngredients)
+		// Basket consolidation - fewest suppliers / lowest landed cost
+		api.POST("/orders/:id/best-suppliers/consolidated", rbac.OrderRead, orderHandler.GetConsolidatedSuppliersForOrder)
+		api.POST("/orders/best-suppliers/consolidated", rbac.OrderRead, orderHandler.GetConsolidatedSuppliersForIngredients)
+
+		// Supplier selection rules used by the best supplier endpoints
+		selectionRuleHandler := handler.NewSupplierSelectionRuleHandler(st.GormClient)
+		selectionRules := api.Group("/supplier-selection-rules")
+		{
+			selectionRules.GET("", rbac.SupplierSelectionRuleManage, selectionRuleHandler.GetSupplierSelectionRules)
+			selectionRules.GET("/strategies", rbac.SupplierSelectionRuleManage, selectionRuleHandler.GetSupplierSelectionStrategies)
+			selectionRules.GET("/:id", rbac.SupplierSelectionRuleManage, selectionRuleHandler.GetSupplierSelectionRule)
+			selectionRules.POST("", rbac.SupplierSelectionRuleManage, selectionRuleHandler.CreateSupplierSelectionRule)
+			selectionRules.PUT("/:id", rbac.SupplierSelectionRuleManage, selectionRuleHandler.UpdateSupplierSelectionRule)
+			selectionRules.DELETE("/:id", rbac.SupplierSelectionRuleManage, selectionRuleHandler.DeleteSupplierSelectionRule)
+		}
 
 		// Initialize inventory handlers
-		stockHandler := handler.NewInventoryStockHandler(store.DB.GormClient)
-		importHandler := handler.NewInventoryImportHandler(store.DB.GormClient)
-		exportHandler := handler.NewInventoryExportHandler(store.DB.GormClient)
-		adjustmentHandler := handler.NewInventoryAdjustmentHandler(store.DB.GormClient)
-		requestHandler := handler.NewIngredientRequestHandler(store.DB.GormClient)
-		reportsHandler := handler.NewInventoryReportsHandler(store.DB.GormClient)
+		inventoryService := service.NewInventory(repository.NewPostgresInventory(st.GormClient))
+		inventoryService.AllowNegativeStock = cfg.Inventory.AllowNegativeStock
+		stockHandler := handler.NewInventoryStockHandler(st.GormClient)
+		importHandler := handler.NewInventoryImportHandler(st.GormClient, inventoryService)
+		exportHandler := handler.NewInventoryExportHandler(st.GormClient, inventoryService)
+		adjustmentHandler := handler.NewInventoryAdjustmentHandler(st.GormClient, inventoryService)
+		requestHandler := handler.NewIngredientRequestHandler(st.GormClient)
+		reportsHandler := handler.NewInventoryReportsHandler(st.GormClient)
 
 		// Inventory routes group
 		inventory := api.Group("/inventory")
@@ -226,76 +316,105 @@ func SetupRouter() *gin.Engine {
 			// Stock management
 			stock := inventory.Group("/stocks")
 			{
-				stock.GET("", stockHandler.GetAllStocks)                         // GET /api/inventory/stocks?kitchen_id=K001&page=1&limit=50
-				stock.GET("/:id", stockHandler.GetStockByID)                     // GET /api/inventory/stocks/1
-				stock.GET("/query", stockHandler.GetStockByKitchenAndIngredient) // GET /api/inventory/stocks/query?kitchen_id=K001&ingredient_id=NL001
-				stock.PUT("/:id/levels", stockHandler.UpdateStockLevels)         // PUT /api/inventory/stocks/1/levels
-				stock.GET("/alerts/low", stockHandler.GetLowStockAlerts)         // GET /api/inventory/stocks/alerts/low?kitchen_id=K001
-				stock.GET("/transactions", stockHandler.GetStockTransactions)    // GET /api/inventory/stocks/transactions?kitchen_id=K001&ingredient_id=NL001
-				stock.GET("/summary", stockHandler.GetStockSummary)              // GET /api/inventory/stocks/summary?kitchen_id=K001
-				stock.GET("/valuation", stockHandler.GetStockValuation)          // GET /api/inventory/stocks/valuation?kitchen_id=K001
+				stock.GET("", rbac.InventoryStockRead, stockHandler.GetAllStocks)                         // GET /api/inventory/stocks?kitchen_id=K001&page=1&limit=50
+				stock.GET("/:id", rbac.InventoryStockRead, stockHandler.GetStockByID)                     // GET /api/inventory/stocks/1
+				stock.GET("/query", rbac.InventoryStockRead, stockHandler.GetStockByKitchenAndIngredient) // GET /api/inventory/stocks/query?kitchen_id=K001&ingredient_id=NL001
+				stock.PUT("/:id/levels", rbac.InventoryStockWrite, stockHandler.UpdateStockLevels)        // PUT /api/inventory/stocks/1/levels
+				stock.GET("/alerts/low", rbac.InventoryStockRead, stockHandler.GetLowStockAlerts)         // GET /api/inventory/stocks/alerts/low?kitchen_id=K001
+				stock.GET("/transactions", rbac.InventoryStockRead, stockHandler.GetStockTransactions)    // GET /api/inventory/stocks/transactions?kitchen_id=K001&ingredient_id=NL001
+				stock.GET("/summary", rbac.InventoryStockRead, stockHandler.GetStockSummary)              // GET /api/inventory/stocks/summary?kitchen_id=K001
+				stock.GET("/valuation", rbac.InventoryStockRead, stockHandler.GetStockValuation)          // GET /api/inventory/stocks/valuation?kitchen_id=K001
 			}
 
 			// Import management
 			imports := inventory.Group("/imports")
 			{
-				imports.GET("", importHandler.GetAllImports)                                // GET /api/inventory/imports?kitchen_id=K001&status=draft
-				imports.GET("/:id", importHandler.GetImportByID)                            // GET /api/inventory/imports/IM20240520-12345
-				imports.POST("", importHandler.CreateImport)                                // POST /api/inventory/imports
-				imports.POST("/from-request/:requestId", importHandler.CreateImportFromRequest) // POST /api/inventory/imports/from-request/RQ20240520-12345
-				imports.PUT("/:id", importHandler.UpdateImport)                             // PUT /api/inventory/imports/IM20240520-12345
-				imports.POST("/:id/approve", importHandler.ApproveImport)                   // POST /api/inventory/imports/IM20240520-12345/approve
-				imports.DELETE("/:id", importHandler.DeleteImport)                          // DELETE /api/inventory/imports/IM20240520-12345
+				imports.GET("", rbac.InventoryImportRead, importHandler.GetAllImports)                                                 // GET /api/inventory/imports?kitchen_id=K001&status=draft
+				imports.GET("/:id", rbac.InventoryImportRead, importHandler.GetImportByID)                                             // GET /api/inventory/imports/IM20240520-12345
+				imports.POST("", rbac.InventoryImportWrite, idempotent, importHandler.CreateImport)                                    // POST /api/inventory/imports
+				imports.POST("/from-request/:requestId", rbac.InventoryImportWrite, idempotent, importHandler.CreateImportFromRequest) // POST /api/inventory/imports/from-request/RQ20240520-12345
+				imports.PUT("/:id", rbac.InventoryImportWrite, importHandler.UpdateImport)                                             // PUT /api/inventory/imports/IM20240520-12345
+				imports.POST("/:id/approve", rbac.InventoryImportApprove, idempotent, importHandler.ApproveImport)                     // POST /api/inventory/imports/IM20240520-12345/approve
+				imports.DELETE("/:id", rbac.InventoryImportWrite, importHandler.DeleteImport)                                          // DELETE /api/inventory/imports/IM20240520-12345
 			}
 
 			// Export management
 			exports := inventory.Group("/exports")
 			{
-				exports.GET("", exportHandler.GetAllExports)              // GET /api/inventory/exports?kitchen_id=K001&export_type=production
-				exports.GET("/:id", exportHandler.GetExportByID)          // GET /api/inventory/exports/EX20240520-12345
-				exports.POST("", exportHandler.CreateExport)              // POST /api/inventory/exports
-				exports.PUT("/:id", exportHandler.UpdateExport)           // PUT /api/inventory/exports/EX20240520-12345
-				exports.POST("/:id/approve", exportHandler.ApproveExport) // POST /api/inventory/exports/EX20240520-12345/approve
-				exports.DELETE("/:id", exportHandler.DeleteExport)        // DELETE /api/inventory/exports/EX20240520-12345
+				exports.GET("", rbac.InventoryExportRead, exportHandler.GetAllExports)                             // GET /api/inventory/exports?kitchen_id=K001&export_type=production
+				exports.GET("/:id", rbac.InventoryExportRead, exportHandler.GetExportByID)                         // GET /api/inventory/exports/EX20240520-12345
+				exports.POST("", rbac.InventoryExportWrite, idempotent, exportHandler.CreateExport)                // POST /api/inventory/exports
+				exports.PUT("/:id", rbac.InventoryExportWrite, exportHandler.UpdateExport)                         // PUT /api/inventory/exports/EX20240520-12345
+				exports.POST("/:id/approve", rbac.InventoryExportApprove, idempotent, exportHandler.ApproveExport) // POST /api/inventory/exports/EX20240520-12345/approve
+				exports.DELETE("/:id", rbac.InventoryExportWrite, exportHandler.DeleteExport)                      // DELETE /api/inventory/exports/EX20240520-12345
 			}
 
 			// Adjustment management
 			adjustments := inventory.Group("/adjustments")
 			{
-				adjustments.GET("", adjustmentHandler.GetAllAdjustments)                  // GET /api/inventory/adjustments?kitchen_id=K001&adjustment_type=count
-				adjustments.GET("/:id", adjustmentHandler.GetAdjustmentByID)              // GET /api/inventory/adjustments/ADJ20240520-12345
-				adjustments.POST("", adjustmentHandler.CreateAdjustment)                  // POST /api/inventory/adjustments
-				adjustments.PUT("/:id", adjustmentHandler.UpdateAdjustment)               // PUT /api/inventory/adjustments/ADJ20240520-12345
-				adjustments.POST("/:id/approve", adjustmentHandler.ApproveAdjustment)     // POST /api/inventory/adjustments/ADJ20240520-12345/approve
-				adjustments.DELETE("/:id", adjustmentHandler.DeleteAdjustment)            // DELETE /api/inventory/adjustments/ADJ20240520-12345
+				adjustments.GET("", rbac.InventoryAdjustmentRead, adjustmentHandler.GetAllAdjustments)                             // GET /api/inventory/adjustments?kitchen_id=K001&adjustment_type=count
+				adjustments.GET("/:id", rbac.InventoryAdjustmentRead, adjustmentHandler.GetAdjustmentByID)                         // GET /api/inventory/adjustments/ADJ20240520-12345
+				adjustments.POST("", rbac.InventoryAdjustmentWrite, idempotent, adjustmentHandler.CreateAdjustment)                // POST /api/inventory/adjustments
+				adjustments.PUT("/:id", rbac.InventoryAdjustmentWrite, adjustmentHandler.UpdateAdjustment)                         // PUT /api/inventory/adjustments/ADJ20240520-12345
+				adjustments.POST("/:id/approve", rbac.InventoryAdjustmentApprove, idempotent, adjustmentHandler.ApproveAdjustment) // POST /api/inventory/adjustments/ADJ20240520-12345/approve
+				adjustments.DELETE("/:id", rbac.InventoryAdjustmentWrite, adjustmentHandler.DeleteAdjustment)                      // DELETE /api/inventory/adjustments/ADJ20240520-12345
 			}
 
 			// Ingredient Request management
 			requests := inventory.Group("/requests")
 			{
-				requests.GET("", requestHandler.GetAllRequests)                           // GET /api/inventory/requests?kitchen_id=K001&status=pending
-				requests.GET("/:id", requestHandler.GetRequestByID)                       // GET /api/inventory/requests/RQ20240520-12345
-				requests.POST("", requestHandler.CreateRequest)                           // POST /api/inventory/requests
-				requests.POST("/from-order/:orderId", requestHandler.CreateRequestFromOrder) // POST /api/inventory/requests/from-order/OR001
-				requests.PUT("/:id", requestHandler.UpdateRequest)                        // PUT /api/inventory/requests/RQ20240520-12345
-				requests.POST("/:id/approve", requestHandler.ApproveRequest)              // POST /api/inventory/requests/RQ20240520-12345/approve
-				requests.DELETE("/:id", requestHandler.DeleteRequest)                     // DELETE /api/inventory/requests/RQ20240520-12345
+				requests.GET("", rbac.InventoryRequestRead, requestHandler.GetAllRequests)                                           // GET /api/inventory/requests?kitchen_id=K001&status=pending
+				requests.GET("/:id", rbac.InventoryRequestRead, requestHandler.GetRequestByID)                                       // GET /api/inventory/requests/RQ20240520-12345
+				requests.POST("", rbac.InventoryRequestWrite, idempotent, requestHandler.CreateRequest)                              // POST /api/inventory/requests
+				requests.POST("/from-order/:orderId", rbac.InventoryRequestWrite, idempotent, requestHandler.CreateRequestFromOrder) // POST /api/inventory/requests/from-order/OR001
+				requests.PUT("/:id", rbac.InventoryRequestWrite, requestHandler.UpdateRequest)                                       // PUT /api/inventory/requests/RQ20240520-12345
+				requests.POST("/:id/approve", rbac.InventoryRequestApprove, idempotent, requestHandler.ApproveRequest)               // POST /api/inventory/requests/RQ20240520-12345/approve
+				requests.DELETE("/:id", rbac.InventoryRequestWrite, requestHandler.DeleteRequest)                                    // DELETE /api/inventory/requests/RQ20240520-12345
 			}
 
 			// Inventory Reports
 			reports := inventory.Group("/reports")
 			{
-				reports.GET("/stock-movement", reportsHandler.GetStockMovementReport)         // GET /api/inventory/reports/stock-movement?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31
-				reports.GET("/expiry-alerts", reportsHandler.GetExpiryAlerts)                 // GET /api/inventory/reports/expiry-alerts?kitchen_id=K001&days_ahead=30
-				reports.GET("/stock-value-trend", reportsHandler.GetStockValueTrend)          // GET /api/inventory/reports/stock-value-trend?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31
-				reports.GET("/transaction-summary", reportsHandler.GetTransactionSummary)     // GET /api/inventory/reports/transaction-summary?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31
-				reports.GET("/top-consumed", reportsHandler.GetTopConsumedIngredients)        // GET /api/inventory/reports/top-consumed?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31&limit=10
+				reports.GET("/stock-movement", rbac.InventoryReportRead, reportsHandler.GetStockMovementReport)     // GET /api/inventory/reports/stock-movement?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31
+				reports.GET("/expiry-alerts", rbac.InventoryReportRead, reportsHandler.GetExpiryAlerts)             // GET /api/inventory/reports/expiry-alerts?kitchen_id=K001&days_ahead=30
+				reports.GET("/stock-value-trend", rbac.InventoryReportRead, reportsHandler.GetStockValueTrend)      // GET /api/inventory/reports/stock-value-trend?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31
+				reports.GET("/transaction-summary", rbac.InventoryReportRead, reportsHandler.GetTransactionSummary) // GET /api/inventory/reports/transaction-summary?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31
+				reports.GET("/top-consumed", rbac.InventoryReportRead, reportsHandler.GetTopConsumedIngredients)    // GET /api/inventory/reports/top-consumed?kitchen_id=K001&from_date=2024-01-01&to_date=2024-01-31&limit=10
 			}
 		}
 	}
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Probes and monitoring. /health predates /livez and answers the same.
+	readiness := newReadiness(st.GormClient)
+	root.GET("/health", rbac.Public, live)
+	root.GET("/livez", rbac.Public, live)
+	root.GET("/readyz", rbac.Public, readiness.ready)
+	root.GET("/metrics", rbac.Public, metrics.Handler())
+	root.GET("/openapi.json", rbac.Public, openapi.Serve(spec))
+	root.GET("/docs", rbac.Public, openapi.Viewer("/openapi.json"))
+	*spec = *openapi.Build(apiInfo, routes, apiRoutes)
+
+	return r, routes
+}
 
-	return r
+// newLoginGuard builds the login throttling from cfg. A user name gets three free attempts,
+// then waits 1s, 2s, 4s... up to a minute, until LoginLockoutAfter failures lock it. An
+// address is allowed more failures since offices share one.
+func newLoginGuard(cfg config.AuthConfig, st *store.Store) *auth.LoginGuard {
+	var l limiter.Limiter = limiter.NewMemory()
+	if cfg.LoginLimiter == config.LoginLimiterPostgres {
+		l = limiter.NewPostgres(st.GormClient)
+	}
+	window := max(time.Hour, cfg.LoginLockoutDuration)
+	user := limiter.Policy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    cfg.LoginLockoutAfter,
+		LockoutDuration: cfg.LoginLockoutDuration,
+		Window:          window,
+	}
+	ip := user
+	ip.FreeAttempts = 20
+	ip.LockoutAfter = cfg.LoginIPLockoutAfter
+	return auth.NewLoginGuard(l, st, user, ip)
 }
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"adong-be/apperr"
+	"adong-be/config"
+	"adong-be/logger"
+	"adong-be/openapi"
+	"adong-be/rbac"
+	"adong-be/requestid"
+	"adong-be/store"
+	"adong-be/telemetry"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Every route must be registered through rbac.Router with an explicit permission
+func TestEveryRouteDeclaresPermission(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r, routes := setupRouter(config.Default(), &store.Store{})
+
+	registered := r.Routes()
+	assert.NotEmpty(t, registered)
+	for _, route := range registered {
+		permission, ok := routes[route.Method+" "+route.Path]
+		if assert.True(t, ok, "route %s %s is registered without a permission", route.Method, route.Path) {
+			assert.True(t, permission == rbac.Public || permission == rbac.Authenticated || rbac.Known(permission),
+				"route %s %s declares unknown permission %q", route.Method, route.Path, permission)
+		}
+	}
+}
+
+// Only login, registration, token refresh, password recovery, the probes, the metrics and
+// the API documentation are reachable without a token
+func TestPublicRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, routes := setupRouter(config.Default(), &store.Store{})
+
+	var public []string
+	for route, permission := range routes {
+		if permission == rbac.Public {
+			public = append(public, route)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"POST /auth/login",
+		"POST /auth/register",
+		"POST /auth/refresh",
+		"POST /auth/forgot-password",
+		"POST /auth/reset-password",
+		"POST /auth/2fa/enroll",
+		"POST /auth/2fa/confirm",
+		"GET /health",
+		"GET /livez",
+		"GET /readyz",
+		"GET /metrics",
+		"GET /openapi.json",
+		"GET /docs",
+	}, public)
+}
+
+// Every route must be documented in apiRoutes, and apiRoutes must not document removed routes
+func TestEveryRouteIsDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, routes := setupRouter(config.Default(), &store.Store{})
+
+	assert.Empty(t, openapi.Undocumented(routes, apiRoutes), "routes missing from apiRoutes")
+	assert.Empty(t, openapi.Unrouted(routes, apiRoutes), "apiRoutes entries without a route")
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r, routes := setupRouter(config.Default(), &store.Store{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+	operations := 0
+	for _, item := range doc.Paths {
+		operations += len(*item)
+	}
+	assert.Equal(t, len(routes), operations)
+
+	order := (*doc.Paths["/api/orders/{id}/status"])["patch"]
+	require.NotNil(t, order)
+	assert.Equal(t, rbac.OrderWrite, order.Permission)
+	assert.Equal(t, "id", order.Parameters[0].Name)
+	assert.Contains(t, order.Responses, "404")
+}
+
+// With validate_requests on, invalid bodies are rejected before they reach a handler
+func TestValidateRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Server.ValidateRequests = true
+	r, _ := setupRouter(cfg, &store.Store{})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"username": 1}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var body apperr.Response
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, apperr.CodeValidation, body.Code)
+	var fields []string
+	for _, f := range body.Fields {
+		fields = append(fields, f.Field+":"+f.Rule)
+	}
+	assert.ElementsMatch(t, []string{"username:type", "password:required"}, fields)
+}
+
+// Liveness does not depend on the database, readiness does
+func TestProbes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r, _ := setupRouter(config.Default(), &store.Store{})
+	serve := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/livez").Code)
+	assert.Equal(t, http.StatusOK, serve("/health").Code)
+
+	w := serve("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var report readinessReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "fail", report.Status)
+	assert.Equal(t, map[string]string{"pool": "fail", "database": "fail", "migrations": "fail"}, report.Checks)
+
+	w = serve("/metrics")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `adong_http_requests_total{method="GET",route="/readyz",status="503"}`)
+}
+
+// Requests get an ID and a span, handlers a logger carrying both, and a caller's trace is
+// continued
+func TestRequestContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+	_, err := telemetry.Setup(context.Background(), config.TracingConfig{Exporter: config.TracingExporterNone})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	r.Use(telemetry.Middleware(), requestid.Middleware(), contextLogger())
+	var log logger.LoggerI
+	var span trace.SpanContext
+	handler := func(c *gin.Context) {
+		log = logger.From(c)
+		span = trace.SpanContextFromContext(c)
+	}
+	r.GET("/orders/:id", handler)
+	r.GET("/livez", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/1", nil)
+	req.Header.Set(requestid.Header, "req-42")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, "req-42", w.Header().Get(requestid.Header))
+	assert.NotSame(t, logger.Log, log, "handlers get the request's logger")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID().String())
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "GET /orders/:id", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("http.request.id", "req-42"))
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Len(t, recorder.Ended(), 1, "probes are not traced")
+}
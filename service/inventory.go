@@ -0,0 +1,308 @@
+package service
+
+import (
+	"adong-be/etag"
+	"adong-be/logger"
+	"adong-be/metrics"
+	"adong-be/models"
+	"adong-be/repository"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// StockError rejects a movement taking more of an ingredient than a kitchen holds
+type StockError struct {
+	IngredientID string
+	// Missing is set when the ingredient was never stocked in the kitchen
+	Missing   bool
+	Available float64
+	Required  float64
+}
+
+func (e *StockError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("ingredient %s is not in stock", e.IngredientID)
+	}
+	return fmt.Sprintf("insufficient stock of %s: %g available, %g required", e.IngredientID, e.Available, e.Required)
+}
+
+// Inventory approves the documents moving stock. Every line of an approved document is
+// posted with PostMovement, in the transaction approving the document.
+type Inventory struct {
+	Repo repository.InventoryRepository
+	Now  func() time.Time
+	// AllowNegativeStock lets movements take more than a kitchen holds instead of failing
+	// with a *StockError
+	AllowNegativeStock bool
+}
+
+// NewInventory returns the inventory service over repo
+func NewInventory(repo repository.InventoryRepository) *Inventory {
+	return &Inventory{Repo: repo, Now: time.Now}
+}
+
+// Movement is one change of the stock of an ingredient in a kitchen
+type Movement struct {
+	KitchenID    string
+	IngredientID string
+	// Quantity is added to the stock, or taken from it when negative. For a counted
+	// movement it is the new stock level.
+	Quantity float64
+	Counted  bool
+	Unit     string
+	// Type is the transaction type logged. A counted movement logs it with _IN or _OUT
+	// appended when the stock went up or down.
+	Type          string
+	ReferenceType string
+	ReferenceID   string
+	UserID        string
+	At            time.Time
+}
+
+// PostMovement applies m to the stock within tx and logs it as an inventory transaction.
+// The stock row stays locked until tx ends, so concurrent postings of the same stock run
+// one after the other and each sees the quantity the previous one left. Unless negative
+// stock is allowed, a movement leaving less than zero fails with a *StockError.
+func (s *Inventory) PostMovement(ctx context.Context, tx repository.InventoryRepository, m Movement) (*models.InventoryTransaction, error) {
+	stock, created, err := tx.LockStock(ctx, m.KitchenID, m.IngredientID, m.Unit)
+	if err != nil {
+		return nil, err
+	}
+
+	before, delta, transactionType := stock.Quantity, m.Quantity, m.Type
+	if m.Counted {
+		delta = m.Quantity - before
+		if delta > 0 {
+			transactionType += "_IN"
+		} else if delta < 0 {
+			transactionType += "_OUT"
+		}
+	}
+	after := before + delta
+	if after < 0 && delta < 0 {
+		if !s.AllowNegativeStock {
+			return nil, &StockError{IngredientID: m.IngredientID, Missing: created, Available: before, Required: -delta}
+		}
+		logger.From(ctx).Warn("PostMovement stock below zero", "kitchen_id", m.KitchenID, "ingredient_id", m.IngredientID,
+			"quantity", after, "reference_type", m.ReferenceType, "reference_id", m.ReferenceID)
+	}
+
+	// Taking from a stock keeps its unit, the other movements bring theirs
+	unit := m.Unit
+	if delta < 0 && !m.Counted {
+		unit = ""
+	}
+	if err := tx.UpdateStock(ctx, stock.StockID, after, unit, m.At); err != nil {
+		return nil, err
+	}
+
+	transaction := &models.InventoryTransaction{
+		KitchenID:       m.KitchenID,
+		IngredientID:    m.IngredientID,
+		TransactionType: transactionType,
+		TransactionDate: m.At,
+		Quantity:        delta,
+		Unit:            m.Unit,
+		QuantityBefore:  before,
+		QuantityAfter:   after,
+		ReferenceType:   &m.ReferenceType,
+		ReferenceID:     &m.ReferenceID,
+		CreatedByUserID: &m.UserID,
+	}
+	if err := tx.LogTransaction(ctx, transaction); err != nil {
+		return nil, err
+	}
+	return transaction, nil
+}
+
+// postAll posts the movements of a document and returns how many left a stock at or below
+// zero. Stocks are locked in kitchen and ingredient order, so two documents sharing
+// ingredients cannot each hold a lock the other waits for.
+func (s *Inventory) postAll(ctx context.Context, tx repository.InventoryRepository, movements []Movement) (stockOuts int, err error) {
+	slices.SortStableFunc(movements, func(a, b Movement) int {
+		return cmp.Or(cmp.Compare(a.KitchenID, b.KitchenID), cmp.Compare(a.IngredientID, b.IngredientID))
+	})
+	for _, m := range movements {
+		transaction, err := s.PostMovement(ctx, tx, m)
+		if err != nil {
+			return 0, err
+		}
+		if transaction.Quantity < 0 && transaction.QuantityAfter <= 0 {
+			stockOuts++
+		}
+	}
+	return stockOuts, nil
+}
+
+// markApproved passes on the error of marking a document approved. The document was read
+// as a draft in the same transaction, so not finding it means a concurrent approval or
+// change won; status reads the document again to tell which.
+func markApproved(err error, status func() (string, error)) error {
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	current, err := status()
+	if err != nil {
+		return err
+	}
+	if current == "approved" {
+		return ErrAlreadyApproved
+	}
+	return ErrVersionMismatch
+}
+
+// ApproveImport approves an import of one of kitchens and adds its lines to the stock. It
+// fails with ErrVersionMismatch unless ifMatch matches the version of the import, as do
+// ApproveExport and ApproveAdjustment.
+func (s *Inventory) ApproveImport(ctx context.Context, kitchens repository.Kitchens, importID, userID string, ifMatch etag.Condition) (*models.InventoryImport, error) {
+	approval := repository.Approval{UserID: userID, At: s.Now()}
+	err := s.Repo.Transaction(ctx, func(tx repository.InventoryRepository) error {
+		record, err := tx.GetImport(ctx, importID)
+		if err != nil {
+			return err
+		}
+		if err := authorize(kitchens, record.KitchenID); err != nil {
+			return err
+		}
+		if record.Status == "approved" {
+			return ErrAlreadyApproved
+		}
+		if !ifMatch.Matches(record.Version) {
+			return ErrVersionMismatch
+		}
+		approval.Version = record.Version
+		err = tx.ApproveImport(ctx, importID, approval)
+		if err := markApproved(err, func() (string, error) {
+			current, err := tx.GetImport(ctx, importID)
+			if err != nil {
+				return "", err
+			}
+			return current.Status, nil
+		}); err != nil {
+			return err
+		}
+
+		var movements []Movement
+		for _, detail := range record.ImportDetails {
+			movements = append(movements, Movement{
+				KitchenID: record.KitchenID, IngredientID: detail.IngredientID, Quantity: detail.Quantity, Unit: detail.Unit,
+				Type: "IMPORT", ReferenceType: "IMPORT", ReferenceID: importID, UserID: userID, At: approval.At,
+			})
+		}
+		_, err = s.postAll(ctx, tx, movements)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.ImportsApproved.Inc()
+	return s.Repo.GetImport(ctx, importID)
+}
+
+// ApproveExport approves an export of one of kitchens and takes its lines from the stock.
+// It fails with a *StockError when the kitchen lacks an ingredient and negative stock is
+// not allowed. A transfer adds the lines to the destination kitchen's stock.
+func (s *Inventory) ApproveExport(ctx context.Context, kitchens repository.Kitchens, exportID, userID string, ifMatch etag.Condition) (*models.InventoryExport, error) {
+	approval := repository.Approval{UserID: userID, At: s.Now()}
+	var stockOuts int
+	err := s.Repo.Transaction(ctx, func(tx repository.InventoryRepository) error {
+		record, err := tx.GetExport(ctx, exportID)
+		if err != nil {
+			return err
+		}
+		if err := authorize(kitchens, record.KitchenID); err != nil {
+			return err
+		}
+		if record.Status == "approved" {
+			return ErrAlreadyApproved
+		}
+		if !ifMatch.Matches(record.Version) {
+			return ErrVersionMismatch
+		}
+		approval.Version = record.Version
+		err = tx.ApproveExport(ctx, exportID, approval)
+		if err := markApproved(err, func() (string, error) {
+			current, err := tx.GetExport(ctx, exportID)
+			if err != nil {
+				return "", err
+			}
+			return current.Status, nil
+		}); err != nil {
+			return err
+		}
+
+		transfer := record.ExportType == "transfer" && record.DestinationKitchenID != nil
+		var movements []Movement
+		for _, detail := range record.ExportDetails {
+			movements = append(movements, Movement{
+				KitchenID: record.KitchenID, IngredientID: detail.IngredientID, Quantity: -detail.Quantity, Unit: detail.Unit,
+				Type: "EXPORT", ReferenceType: record.ExportType, ReferenceID: exportID, UserID: userID, At: approval.At,
+			})
+			if transfer {
+				movements = append(movements, Movement{
+					KitchenID: *record.DestinationKitchenID, IngredientID: detail.IngredientID, Quantity: detail.Quantity, Unit: detail.Unit,
+					Type: "TRANSFER_IN", ReferenceType: "EXPORT", ReferenceID: exportID, UserID: userID, At: approval.At,
+				})
+			}
+		}
+		stockOuts, err = s.postAll(ctx, tx, movements)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.StockOuts.Add(float64(stockOuts))
+	return s.Repo.GetExport(ctx, exportID)
+}
+
+// ApproveAdjustment approves a count adjustment of one of kitchens and sets the stock of
+// each line to the counted quantity
+func (s *Inventory) ApproveAdjustment(ctx context.Context, kitchens repository.Kitchens, adjustmentID, userID string, ifMatch etag.Condition) (*models.InventoryAdjustment, error) {
+	approval := repository.Approval{UserID: userID, At: s.Now()}
+	var stockOuts int
+	err := s.Repo.Transaction(ctx, func(tx repository.InventoryRepository) error {
+		record, err := tx.GetAdjustment(ctx, adjustmentID)
+		if err != nil {
+			return err
+		}
+		if err := authorize(kitchens, record.KitchenID); err != nil {
+			return err
+		}
+		if record.Status == "approved" {
+			return ErrAlreadyApproved
+		}
+		if !ifMatch.Matches(record.Version) {
+			return ErrVersionMismatch
+		}
+		approval.Version = record.Version
+		err = tx.ApproveAdjustment(ctx, adjustmentID, approval)
+		if err := markApproved(err, func() (string, error) {
+			current, err := tx.GetAdjustment(ctx, adjustmentID)
+			if err != nil {
+				return "", err
+			}
+			return current.Status, nil
+		}); err != nil {
+			return err
+		}
+
+		var movements []Movement
+		for _, detail := range record.AdjustmentDetails {
+			movements = append(movements, Movement{
+				KitchenID: record.KitchenID, IngredientID: detail.IngredientID, Quantity: detail.QuantityAfter, Counted: true, Unit: detail.Unit,
+				Type: "ADJUSTMENT", ReferenceType: "ADJUSTMENT", ReferenceID: adjustmentID, UserID: userID, At: approval.At,
+			})
+		}
+		stockOuts, err = s.postAll(ctx, tx, movements)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	metrics.StockOuts.Add(float64(stockOuts))
+	return s.Repo.GetAdjustment(ctx, adjustmentID)
+}
@@ -0,0 +1,328 @@
+package service
+
+import (
+	"adong-be/etag"
+	"adong-be/metrics"
+	"adong-be/models"
+	"adong-be/repository"
+	"adong-be/utils"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var approvedAt = time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+
+// anyVersion is If-Match: *, approving whatever version a document is at
+var anyVersion = etag.Condition{Any: true}
+
+func inventoryService(data repository.InventoryData) (*Inventory, *repository.MemoryInventory) {
+	repo := repository.NewMemoryInventory(data)
+	svc := NewInventory(repo)
+	svc.Now = func() time.Time { return approvedAt }
+	return svc, repo
+}
+
+func stockOf(t *testing.T, repo *repository.MemoryInventory, kitchenID, ingredientID string) float64 {
+	t.Helper()
+	stock, err := repo.GetStock(context.Background(), kitchenID, ingredientID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0
+	}
+	require.NoError(t, err)
+	return stock.Quantity
+}
+
+func TestInventoryApproveImport(t *testing.T) {
+	draft := models.InventoryImport{ImportID: "NK1", KitchenID: "K1", Status: "draft", Version: 2, ImportDetails: []models.InventoryImportDetail{
+		{IngredientID: "RAU", Quantity: 5, Unit: "kg"},
+		{IngredientID: "THIT", Quantity: 2, Unit: "kg"},
+	}}
+	approved := draft
+	approved.Status = "approved"
+
+	tests := []struct {
+		name      string
+		record    models.InventoryImport
+		importID  string
+		kitchens  repository.Kitchens
+		ifMatch   etag.Condition
+		wantErr   error
+		wantStock map[string]float64
+	}{
+		{name: "adds to existing and new stock", record: draft, importID: "NK1", kitchens: kitchens("K1"), ifMatch: anyVersion,
+			wantStock: map[string]float64{"RAU": 15, "THIT": 2}},
+		{name: "read at the current version", record: draft, importID: "NK1", kitchens: kitchens("K1"), ifMatch: etag.Condition{Versions: []int{2}},
+			wantStock: map[string]float64{"RAU": 15, "THIT": 2}},
+		{name: "changed since read", record: draft, importID: "NK1", kitchens: kitchens("K1"), ifMatch: etag.Condition{Versions: []int{1}},
+			wantErr: ErrVersionMismatch, wantStock: map[string]float64{"RAU": 10}},
+		{name: "already approved", record: approved, importID: "NK1", kitchens: kitchens("K1"),
+			wantErr: ErrAlreadyApproved, wantStock: map[string]float64{"RAU": 10}},
+		{name: "kitchen outside the scope", record: draft, importID: "NK1", kitchens: kitchens("K2"),
+			wantErr: utils.ErrKitchenForbidden, wantStock: map[string]float64{"RAU": 10}},
+		{name: "missing import", record: draft, importID: "NK9", kitchens: repository.AllKitchens,
+			wantErr: repository.ErrNotFound, wantStock: map[string]float64{"RAU": 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := inventoryService(repository.InventoryData{
+				Imports: []models.InventoryImport{tt.record},
+				Stocks:  []models.InventoryStock{{KitchenID: "K1", IngredientID: "RAU", Quantity: 10, Unit: "kg"}},
+			})
+
+			result, err := svc.ApproveImport(context.Background(), tt.kitchens, tt.importID, "U1", tt.ifMatch)
+			for ingredientID, want := range tt.wantStock {
+				assert.Equal(t, want, stockOf(t, repo, "K1", ingredientID), ingredientID)
+			}
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				assert.Empty(t, repo.Transactions())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "approved", result.Status)
+			assert.Equal(t, 3, result.Version)
+			require.NotNil(t, result.ApprovedByUserID)
+			assert.Equal(t, "U1", *result.ApprovedByUserID)
+
+			logged := repo.Transactions()
+			require.Len(t, logged, 2)
+			assert.Equal(t, "IMPORT", logged[0].TransactionType)
+			assert.Equal(t, 10.0, logged[0].QuantityBefore)
+			assert.Equal(t, 15.0, logged[0].QuantityAfter)
+			assert.Equal(t, 0.0, logged[1].QuantityBefore)
+			assert.Equal(t, approvedAt, logged[1].TransactionDate)
+		})
+	}
+}
+
+func TestInventoryApproveExport(t *testing.T) {
+	destination := "K2"
+	export := func(exportType string, quantities ...float64) models.InventoryExport {
+		e := models.InventoryExport{ExportID: "XK1", KitchenID: "K1", ExportType: exportType, Status: "draft"}
+		if exportType == "transfer" {
+			e.DestinationKitchenID = &destination
+		}
+		ingredients := []string{"RAU", "THIT", "CA"}
+		for i, q := range quantities {
+			e.ExportDetails = append(e.ExportDetails, models.InventoryExportDetail{IngredientID: ingredients[i], Quantity: q, Unit: "kg"})
+		}
+		return e
+	}
+
+	tests := []struct {
+		name       string
+		record     models.InventoryExport
+		wantErr    error
+		wantStock  *StockError
+		wantSource map[string]float64
+		wantDest   map[string]float64
+		wantTypes  []string
+		// wantStockOuts counts the stocks the export emptied
+		wantStockOuts float64
+	}{
+		{name: "takes from stock", record: export("usage", 4, 3),
+			wantSource: map[string]float64{"RAU": 6, "THIT": 0}, wantTypes: []string{"EXPORT", "EXPORT"}, wantStockOuts: 1},
+		{name: "transfer moves stock to the destination", record: export("transfer", 4),
+			wantSource: map[string]float64{"RAU": 6}, wantDest: map[string]float64{"RAU": 5},
+			wantTypes: []string{"EXPORT", "TRANSFER_IN"}},
+		{name: "insufficient stock rolls back", record: export("usage", 4, 5),
+			wantStock:  &StockError{IngredientID: "THIT", Available: 3, Required: 5},
+			wantSource: map[string]float64{"RAU": 10, "THIT": 3}},
+		{name: "ingredient never stocked", record: export("usage", 1, 1, 1),
+			wantStock:  &StockError{IngredientID: "CA", Missing: true, Required: 1},
+			wantSource: map[string]float64{"RAU": 10, "THIT": 3}},
+		{name: "already approved", record: func() models.InventoryExport {
+			e := export("usage", 4)
+			e.Status = "approved"
+			return e
+		}(), wantErr: ErrAlreadyApproved, wantSource: map[string]float64{"RAU": 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := inventoryService(repository.InventoryData{
+				Exports: []models.InventoryExport{tt.record},
+				Stocks: []models.InventoryStock{
+					{KitchenID: "K1", IngredientID: "RAU", Quantity: 10, Unit: "kg"},
+					{KitchenID: "K1", IngredientID: "THIT", Quantity: 3, Unit: "kg"},
+					{KitchenID: "K2", IngredientID: "RAU", Quantity: 1, Unit: "kg"},
+				},
+			})
+
+			stockOuts := testutil.ToFloat64(metrics.StockOuts)
+			result, err := svc.ApproveExport(context.Background(), kitchens("K1"), "XK1", "U1", anyVersion)
+			assert.Equal(t, tt.wantStockOuts, testutil.ToFloat64(metrics.StockOuts)-stockOuts, "stock-outs")
+			for ingredientID, want := range tt.wantSource {
+				assert.Equal(t, want, stockOf(t, repo, "K1", ingredientID), ingredientID)
+			}
+			for ingredientID, want := range tt.wantDest {
+				assert.Equal(t, want, stockOf(t, repo, "K2", ingredientID), ingredientID)
+			}
+
+			switch {
+			case tt.wantStock != nil:
+				var stockErr *StockError
+				require.ErrorAs(t, err, &stockErr)
+				assert.Equal(t, tt.wantStock, stockErr)
+			case tt.wantErr != nil:
+				require.ErrorIs(t, err, tt.wantErr)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, "approved", result.Status)
+			}
+			if err != nil {
+				assert.Empty(t, repo.Transactions())
+				stored, _ := repo.GetExport(context.Background(), "XK1")
+				assert.Equal(t, tt.record.Status, stored.Status)
+				return
+			}
+
+			var types []string
+			for _, tx := range repo.Transactions() {
+				types = append(types, tx.TransactionType)
+			}
+			assert.Equal(t, tt.wantTypes, types)
+			assert.Negative(t, repo.Transactions()[0].Quantity)
+		})
+	}
+}
+
+func TestInventoryApproveAdjustment(t *testing.T) {
+	tests := []struct {
+		name     string
+		detail   models.InventoryAdjustmentDetail
+		wantType string
+	}{
+		{name: "count above stock", detail: models.InventoryAdjustmentDetail{IngredientID: "RAU", QuantityBefore: 10, QuantityAfter: 12, QuantityDifference: 2, Unit: "kg"}, wantType: "ADJUSTMENT_IN"},
+		{name: "count below stock", detail: models.InventoryAdjustmentDetail{IngredientID: "RAU", QuantityBefore: 10, QuantityAfter: 7, QuantityDifference: -3, Unit: "kg"}, wantType: "ADJUSTMENT_OUT"},
+		{name: "count matches stock", detail: models.InventoryAdjustmentDetail{IngredientID: "RAU", QuantityBefore: 10, QuantityAfter: 10, Unit: "kg"}, wantType: "ADJUSTMENT"},
+		{name: "ingredient not yet stocked", detail: models.InventoryAdjustmentDetail{IngredientID: "CA", QuantityAfter: 4, QuantityDifference: 4, Unit: "kg"}, wantType: "ADJUSTMENT_IN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := inventoryService(repository.InventoryData{
+				Adjustments: []models.InventoryAdjustment{{AdjustmentID: "DC1", KitchenID: "K1", Status: "draft",
+					AdjustmentDetails: []models.InventoryAdjustmentDetail{tt.detail}}},
+				Stocks: []models.InventoryStock{{KitchenID: "K1", IngredientID: "RAU", Quantity: 10, Unit: "kg"}},
+			})
+
+			result, err := svc.ApproveAdjustment(context.Background(), kitchens("K1"), "DC1", "U1", anyVersion)
+			require.NoError(t, err)
+			assert.Equal(t, "approved", result.Status)
+			assert.Equal(t, tt.detail.QuantityAfter, stockOf(t, repo, "K1", tt.detail.IngredientID))
+
+			logged := repo.Transactions()
+			require.Len(t, logged, 1)
+			assert.Equal(t, tt.wantType, logged[0].TransactionType)
+			assert.Equal(t, tt.detail.QuantityDifference, logged[0].Quantity)
+
+			_, err = svc.ApproveAdjustment(context.Background(), kitchens("K1"), "DC1", "U1", anyVersion)
+			assert.ErrorIs(t, err, ErrAlreadyApproved)
+		})
+	}
+}
+
+func TestInventoryPostMovement(t *testing.T) {
+	tests := []struct {
+		name          string
+		movement      Movement
+		allowNegative bool
+		wantErr       *StockError
+		wantStock     float64
+		wantType      string
+		wantQuantity  float64
+	}{
+		{name: "adds to stock", movement: Movement{IngredientID: "RAU", Quantity: 2.5, Type: "IMPORT"},
+			wantStock: 12.5, wantType: "IMPORT", wantQuantity: 2.5},
+		{name: "takes the whole stock", movement: Movement{IngredientID: "RAU", Quantity: -10, Type: "EXPORT"},
+			wantStock: 0, wantType: "EXPORT", wantQuantity: -10},
+		{name: "rejects going below zero", movement: Movement{IngredientID: "RAU", Quantity: -10.5, Type: "EXPORT"},
+			wantErr: &StockError{IngredientID: "RAU", Available: 10, Required: 10.5}},
+		{name: "rejects taking an unstocked ingredient", movement: Movement{IngredientID: "CA", Quantity: -1, Type: "EXPORT"},
+			wantErr: &StockError{IngredientID: "CA", Missing: true, Required: 1}},
+		{name: "allows going below zero by policy", movement: Movement{IngredientID: "RAU", Quantity: -12, Type: "EXPORT"},
+			allowNegative: true, wantStock: -2, wantType: "EXPORT", wantQuantity: -12},
+		{name: "count above stock", movement: Movement{IngredientID: "RAU", Quantity: 13, Counted: true, Type: "ADJUSTMENT"},
+			wantStock: 13, wantType: "ADJUSTMENT_IN", wantQuantity: 3},
+		{name: "count below stock", movement: Movement{IngredientID: "RAU", Quantity: 4, Counted: true, Type: "ADJUSTMENT"},
+			wantStock: 4, wantType: "ADJUSTMENT_OUT", wantQuantity: -6},
+		{name: "negative count", movement: Movement{IngredientID: "RAU", Quantity: -1, Counted: true, Type: "ADJUSTMENT"},
+			wantErr: &StockError{IngredientID: "RAU", Available: 10, Required: 11}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc, repo := inventoryService(repository.InventoryData{
+				Stocks: []models.InventoryStock{{KitchenID: "K1", IngredientID: "RAU", Quantity: 10, Unit: "kg"}},
+			})
+			svc.AllowNegativeStock = tt.allowNegative
+			m := tt.movement
+			m.KitchenID, m.Unit, m.ReferenceType, m.ReferenceID, m.UserID, m.At = "K1", "kg", "TEST", "T1", "U1", approvedAt
+
+			var logged *models.InventoryTransaction
+			err := repo.Transaction(context.Background(), func(tx repository.InventoryRepository) error {
+				var err error
+				logged, err = svc.PostMovement(context.Background(), tx, m)
+				return err
+			})
+			if tt.wantErr != nil {
+				var stockErr *StockError
+				require.ErrorAs(t, err, &stockErr)
+				assert.Equal(t, tt.wantErr, stockErr)
+				assert.Equal(t, 10.0, stockOf(t, repo, "K1", "RAU"))
+				_, err := repo.GetStock(context.Background(), "K1", "CA")
+				assert.ErrorIs(t, err, repository.ErrNotFound, "a failed posting leaves no stock behind")
+				assert.Empty(t, repo.Transactions())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStock, stockOf(t, repo, "K1", m.IngredientID))
+			assert.Equal(t, tt.wantType, logged.TransactionType)
+			assert.Equal(t, tt.wantQuantity, logged.Quantity)
+			assert.Equal(t, logged.QuantityBefore+logged.Quantity, logged.QuantityAfter)
+			assert.Equal(t, []models.InventoryTransaction{*logged}, repo.Transactions())
+		})
+	}
+}
+
+func TestInventoryApproveExportConcurrent(t *testing.T) {
+	// Each export alone fits the stock, both together would drive it negative
+	var exports []models.InventoryExport
+	for _, id := range []string{"XK1", "XK2"} {
+		exports = append(exports, models.InventoryExport{ExportID: id, KitchenID: "K1", ExportType: "usage", Status: "draft",
+			ExportDetails: []models.InventoryExportDetail{{IngredientID: "RAU", Quantity: 6, Unit: "kg"}}})
+	}
+	svc, repo := inventoryService(repository.InventoryData{
+		Exports: exports,
+		Stocks:  []models.InventoryStock{{KitchenID: "K1", IngredientID: "RAU", Quantity: 10, Unit: "kg"}},
+	})
+
+	errs := make(chan error, len(exports))
+	for _, e := range exports {
+		go func(exportID string) {
+			_, err := svc.ApproveExport(context.Background(), kitchens("K1"), exportID, "U1", anyVersion)
+			errs <- err
+		}(e.ExportID)
+	}
+	var failed []error
+	for range exports {
+		if err := <-errs; err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	require.Len(t, failed, 1)
+	var stockErr *StockError
+	require.ErrorAs(t, failed[0], &stockErr)
+	assert.Equal(t, 4.0, stockErr.Available)
+	assert.Equal(t, 4.0, stockOf(t, repo, "K1", "RAU"))
+	assert.Len(t, repo.Transactions(), 1)
+}
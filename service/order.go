@@ -0,0 +1,129 @@
+package service
+
+import (
+	"adong-be/etag"
+	"adong-be/logger"
+	"adong-be/metrics"
+	"adong-be/models"
+	"adong-be/repository"
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+// Orders creates and manages kitchen orders
+type Orders struct {
+	Repo repository.OrderRepository
+	// NewID generates the ID of orders created without one
+	NewID func() string
+}
+
+// NewOrders returns the order service over repo
+func NewOrders(repo repository.OrderRepository) *Orders {
+	return &Orders{Repo: repo, NewID: uuid.NewString}
+}
+
+// List returns one page of the orders matching filter and their total
+func (s *Orders) List(ctx context.Context, filter repository.OrderFilter, params models.PaginationParams) ([]models.Order, int64, error) {
+	return s.Repo.List(ctx, filter, params)
+}
+
+// Get returns an order of one of kitchens
+func (s *Orders) Get(ctx context.Context, kitchens repository.Kitchens, orderID string) (*models.Order, error) {
+	order, err := s.Repo.Get(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize(kitchens, order.KitchenID); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// Create stores a new order for one of kitchens on behalf of userID and returns it as
+// stored. Ingredient and supplementary lines without a quantity get the recipe standard
+// times the portions; lines where neither is known are dropped.
+func (s *Orders) Create(ctx context.Context, kitchens repository.Kitchens, order *models.Order, userID string) (*models.Order, error) {
+	if err := authorize(kitchens, order.KitchenID); err != nil {
+		return nil, err
+	}
+	if userID != "" {
+		order.CreatedByUserID = userID
+	}
+	if order.OrderID == "" {
+		order.OrderID = s.NewID()
+		logger.From(ctx).Info("CreateOrder auto-generated OrderID", "orderId", order.OrderID)
+	}
+
+	for i := range order.Details {
+		detail := &order.Details[i]
+		ingredients := detail.Ingredients[:0]
+		for _, ing := range detail.Ingredients {
+			if quantity, ok := orderQuantity(ing.Quantity, ing.StandardPerPortion, detail.Portions); ok {
+				ing.Quantity = quantity
+				ingredients = append(ingredients, ing)
+			} else {
+				logger.From(ctx).Warn("CreateOrder skipping ingredient with invalid quantity", "ingredient_id", ing.IngredientID)
+			}
+		}
+		detail.Ingredients = ingredients
+	}
+	supplementaryFoods := order.SupplementaryFoods[:0]
+	for _, food := range order.SupplementaryFoods {
+		if quantity, ok := orderQuantity(food.Quantity, food.StandardPerPortion, food.Portions); ok {
+			food.Quantity = quantity
+			supplementaryFoods = append(supplementaryFoods, food)
+		} else {
+			logger.From(ctx).Warn("CreateOrder skipping supplementary with invalid quantity", "ingredient_id", food.IngredientID)
+		}
+	}
+	order.SupplementaryFoods = supplementaryFoods
+
+	if err := s.Repo.Create(ctx, order); err != nil {
+		return nil, err
+	}
+	metrics.OrdersCreated.Inc()
+	return s.Repo.Get(ctx, order.OrderID)
+}
+
+// orderQuantity is the quantity to order: the given one, or the standard per portion times
+// the portions when none is given. ok is false when neither is known.
+func orderQuantity(quantity, standardPerPortion float64, portions int) (float64, bool) {
+	if quantity > 0 {
+		return quantity, true
+	}
+	if standardPerPortion > 0 && portions > 0 {
+		return standardPerPortion * float64(portions), true
+	}
+	return 0, false
+}
+
+// UpdateStatus sets the status of an order of one of kitchens and returns its new version.
+// It fails with ErrVersionMismatch unless ifMatch matches the version of the order.
+func (s *Orders) UpdateStatus(ctx context.Context, kitchens repository.Kitchens, orderID, status string, ifMatch etag.Condition) (int, error) {
+	order, err := s.Get(ctx, kitchens, orderID)
+	if err != nil {
+		return 0, err
+	}
+	if !ifMatch.Matches(order.Version) {
+		return 0, ErrVersionMismatch
+	}
+	err = s.Repo.UpdateStatus(ctx, orderID, status, order.Version)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Changed or deleted since it was read
+		return 0, ErrVersionMismatch
+	}
+	if err != nil {
+		return 0, err
+	}
+	return order.Version + 1, nil
+}
+
+// Delete removes an order of one of kitchens
+func (s *Orders) Delete(ctx context.Context, kitchens repository.Kitchens, orderID string) error {
+	if _, err := s.Get(ctx, kitchens, orderID); err != nil {
+		return err
+	}
+	return s.Repo.Delete(ctx, orderID)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"adong-be/etag"
+	"adong-be/models"
+	"adong-be/repository"
+	"adong-be/utils"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func kitchens(ids ...string) repository.Kitchens {
+	return repository.Kitchens{IDs: ids}
+}
+
+func TestOrdersCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		kitchens repository.Kitchens
+		order    models.Order
+		wantErr  error
+		check    func(t *testing.T, order *models.Order)
+	}{
+		{
+			name:     "generates the ID and records the creator",
+			kitchens: kitchens("K1"),
+			order:    models.Order{KitchenID: "K1", OrderDate: "2025-01-02"},
+			check: func(t *testing.T, order *models.Order) {
+				assert.Equal(t, "ORD-1", order.OrderID)
+				assert.Equal(t, "U1", order.CreatedByUserID)
+				assert.Equal(t, "Pending", order.Status)
+			},
+		},
+		{
+			name:     "keeps a given ID",
+			kitchens: repository.AllKitchens,
+			order:    models.Order{OrderID: "ORD-X", KitchenID: "K2"},
+			check: func(t *testing.T, order *models.Order) {
+				assert.Equal(t, "ORD-X", order.OrderID)
+			},
+		},
+		{
+			name:     "defaults quantities from the recipe standard",
+			kitchens: kitchens("K1"),
+			order: models.Order{KitchenID: "K1", Details: []models.OrderDetail{{
+				DishID: "D1", Portions: 40,
+				Ingredients: []models.OrderIngredient{
+					{IngredientID: "I1", Quantity: 3},
+					{IngredientID: "I2", StandardPerPortion: 0.25},
+					{IngredientID: "I3"},
+				},
+			}}, SupplementaryFoods: []models.OrderSupplementaryFood{
+				{IngredientID: "S1", StandardPerPortion: 0.1, Portions: 20},
+				{IngredientID: "S2", Portions: 20},
+			}},
+			check: func(t *testing.T, order *models.Order) {
+				require.Len(t, order.Details, 1)
+				ingredients := order.Details[0].Ingredients
+				require.Len(t, ingredients, 2)
+				assert.Equal(t, 3.0, ingredients[0].Quantity)
+				assert.Equal(t, 10.0, ingredients[1].Quantity)
+				require.Len(t, order.SupplementaryFoods, 1)
+				assert.Equal(t, "S1", order.SupplementaryFoods[0].IngredientID)
+				assert.InDelta(t, 2.0, order.SupplementaryFoods[0].Quantity, 1e-9)
+			},
+		},
+		{
+			name:     "rejects a kitchen outside the scope",
+			kitchens: kitchens("K1"),
+			order:    models.Order{KitchenID: "K2"},
+			wantErr:  utils.ErrKitchenForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := repository.NewMemoryOrders()
+			svc := NewOrders(repo)
+			svc.NewID = func() string { return "ORD-1" }
+
+			order := tt.order
+			created, err := svc.Create(context.Background(), tt.kitchens, &order, "U1")
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				_, total, _ := repo.List(context.Background(), repository.OrderFilter{Kitchens: repository.AllKitchens}, models.PaginationParams{})
+				assert.Zero(t, total)
+				return
+			}
+			require.NoError(t, err)
+			tt.check(t, created)
+		})
+	}
+}
+
+func TestOrdersScope(t *testing.T) {
+	seed := []models.Order{
+		{OrderID: "O1", KitchenID: "K1", Status: "Pending", Version: 1},
+		{OrderID: "O2", KitchenID: "K2", Status: "Pending", Version: 1},
+	}
+	tests := []struct {
+		name    string
+		orderID string
+		wantErr error
+	}{
+		{name: "own kitchen", orderID: "O1"},
+		{name: "other kitchen", orderID: "O2", wantErr: utils.ErrKitchenForbidden},
+		{name: "missing order", orderID: "O3", wantErr: repository.ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := repository.NewMemoryOrders(seed...)
+			svc := NewOrders(repo)
+
+			version, err := svc.UpdateStatus(ctx, kitchens("K1"), tt.orderID, "Approved", etag.Condition{Versions: []int{1}})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+				order, err := repo.Get(ctx, tt.orderID)
+				require.NoError(t, err)
+				assert.Equal(t, "Approved", order.Status)
+				assert.Equal(t, 2, version)
+				assert.Equal(t, 2, order.Version)
+
+				_, err = svc.UpdateStatus(ctx, kitchens("K1"), tt.orderID, "Rejected", etag.Condition{Versions: []int{1}})
+				assert.ErrorIs(t, err, ErrVersionMismatch, "a second change read at version 1")
+			}
+
+			err = svc.Delete(ctx, kitchens("K1"), tt.orderID)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			_, err = repo.Get(ctx, tt.orderID)
+			assert.ErrorIs(t, err, repository.ErrNotFound)
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"adong-be/auth/password"
+	"adong-be/logger"
+	"adong-be/mail"
+	"adong-be/models"
+	"adong-be/repository"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// resetThrottle is the minimum time between two self-service reset emails to one user
+const resetThrottle = time.Minute
+
+// ErrPasswordIncorrect is returned when the current password given to change it is wrong
+var ErrPasswordIncorrect = errors.New("current password is incorrect")
+
+// ErrSamePassword is returned when a new password equals the current one
+var ErrSamePassword = errors.New("new password must differ from the current one")
+
+// Passwords runs the forgot, reset and change password flows. New passwords are checked
+// against Policy and every password change revokes all sessions of the user.
+type Passwords struct {
+	Repo     repository.PasswordRepository
+	Sessions SessionRevoker
+	Mailer   mail.Sender
+	Policy   password.Policy
+	// ResetTTL is how long a reset link stays valid, ResetURL the frontend page receiving
+	// its token as ?token=
+	ResetTTL time.Duration
+	ResetURL string
+}
+
+// NewPasswords returns the password service over repo, signing users out through sessions
+// and sending reset links through mailer
+func NewPasswords(repo repository.PasswordRepository, sessions SessionRevoker, mailer mail.Sender, policy password.Policy, resetTTL time.Duration, resetURL string) *Passwords {
+	return &Passwords{Repo: repo, Sessions: sessions, Mailer: mailer, Policy: policy, ResetTTL: resetTTL, ResetURL: resetURL}
+}
+
+// ResetLink is a reset link issued by an admin. EmailSent is false when the user has no
+// usable email address, the admin then hands URL over.
+type ResetLink struct {
+	URL       string
+	ExpiresAt time.Time
+	EmailSent bool
+}
+
+// Forgot emails a reset link to the account matching login, at most one per resetThrottle.
+// Unknown, inactive and service accounts get nothing; the caller answers the same either
+// way, so failures are only logged.
+func (s *Passwords) Forgot(ctx context.Context, login string) {
+	user, err := s.Repo.FindUserByLogin(ctx, login)
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			logger.From(ctx).Error("ForgotPassword lookup error", "error", err)
+		}
+		return
+	}
+	if user.Active == nil || !*user.Active || user.Email == "" || user.AccountType == models.AccountTypeService {
+		logger.From(ctx).Warn("ForgotPassword for account without usable email", "user_id", user.UserID)
+		return
+	}
+
+	recent, err := s.Repo.CountResetsSince(ctx, user.UserID, time.Now().Add(-resetThrottle))
+	if err != nil {
+		logger.From(ctx).Error("ForgotPassword throttle check error", "user_id", user.UserID, "error", err)
+		return
+	}
+	if recent > 0 {
+		logger.From(ctx).Warn("ForgotPassword throttled", "user_id", user.UserID)
+		return
+	}
+
+	link, expiresAt, err := s.issueResetToken(ctx, user, nil)
+	if err != nil {
+		logger.From(ctx).Error("ForgotPassword issue token error", "user_id", user.UserID, "error", err)
+		return
+	}
+	if err := s.sendResetEmail(ctx, user, link, expiresAt); err != nil {
+		logger.From(ctx).Error("ForgotPassword send email error", "user_id", user.UserID, "error", err)
+	}
+}
+
+// Reset sets the password pw with a token from a reset link and returns the user's ID. The
+// token is consumed; an unknown, used or expired one fails with repository.ErrResetTokenInvalid.
+func (s *Passwords) Reset(ctx context.Context, token, pw string) (string, error) {
+	tokenHash := password.HashToken(token)
+	now := time.Now()
+	reset, err := s.Repo.FindResetToken(ctx, tokenHash, now)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.Repo.GetUser(ctx, reset.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", repository.ErrResetTokenInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+	hash, err := s.hash(pw, user.UserName)
+	if err != nil {
+		return "", err
+	}
+
+	userID, err := s.Repo.ResetPassword(ctx, tokenHash, hash, now)
+	if err != nil {
+		return "", err
+	}
+	return userID, s.revokeSessions(userID)
+}
+
+// Change sets the password of userID to newPw once oldPw, the current one, is confirmed
+func (s *Passwords) Change(ctx context.Context, userID, oldPw, newPw string) error {
+	user, err := s.Repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !password.Verify(user.Password, oldPw) {
+		return ErrPasswordIncorrect
+	}
+	if newPw == oldPw {
+		return ErrSamePassword
+	}
+	hash, err := s.hash(newPw, user.UserName)
+	if err != nil {
+		return err
+	}
+	if err := s.Repo.SetPassword(ctx, userID, hash, time.Now()); err != nil {
+		return err
+	}
+	return s.revokeSessions(userID)
+}
+
+// AdminReset forces userID to choose a new password: the current password stops working,
+// every session is revoked and a reset link, issued by adminID, is emailed
+func (s *Passwords) AdminReset(ctx context.Context, adminID, userID string) (*ResetLink, error) {
+	user, err := s.Repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	locked, err := password.Unusable()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Repo.SetPassword(ctx, userID, locked, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.revokeSessions(userID); err != nil {
+		return nil, err
+	}
+
+	link, expiresAt, err := s.issueResetToken(ctx, user, &adminID)
+	if err != nil {
+		return nil, err
+	}
+	reset := &ResetLink{URL: link, ExpiresAt: expiresAt}
+	if user.Email != "" {
+		if err := s.sendResetEmail(ctx, user, link, expiresAt); err != nil {
+			logger.From(ctx).Error("AdminResetPassword send email error", "id", userID, "error", err)
+		} else {
+			reset.EmailSent = true
+		}
+	}
+	return reset, nil
+}
+
+// hash validates pw against the password policy and returns its bcrypt hash
+func (s *Passwords) hash(pw, username string) (string, error) {
+	if err := s.Policy.Validate(pw, username); err != nil {
+		return "", err
+	}
+	hash, err := password.Hash(pw)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return hash, nil
+}
+
+// revokeSessions signs userID out everywhere, so the new password is needed on every device
+func (s *Passwords) revokeSessions(userID string) error {
+	if err := s.Sessions.RevokeAllUserTokens(userID); err != nil {
+		return fmt.Errorf("revoke sessions of %s: %w", userID, err)
+	}
+	return nil
+}
+
+// issueResetToken stores a new reset token for user and returns the link and its expiry
+func (s *Passwords) issueResetToken(ctx context.Context, user *models.User, createdBy *string) (string, time.Time, error) {
+	token, hash, err := password.NewToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(s.ResetTTL)
+	record := models.PasswordResetToken{
+		UserID:          user.UserID,
+		TokenHash:       hash,
+		ExpiresAt:       expiresAt,
+		CreatedByUserID: createdBy,
+	}
+	if err := s.Repo.CreateResetToken(ctx, &record); err != nil {
+		return "", time.Time{}, err
+	}
+	return s.resetLink(token), expiresAt, nil
+}
+
+// resetLink appends the token to the configured frontend page
+func (s *Passwords) resetLink(token string) string {
+	u, err := url.Parse(s.ResetURL)
+	if err != nil {
+		return s.ResetURL + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func (s *Passwords) sendResetEmail(ctx context.Context, user *models.User, link string, expiresAt time.Time) error {
+	body := fmt.Sprintf("Xin chào %s,\n\n"+
+		"Chúng tôi nhận được yêu cầu đặt lại mật khẩu cho tài khoản %s.\n"+
+		"Mở liên kết sau để đặt mật khẩu mới (hết hạn lúc %s):\n\n%s\n\n"+
+		"Nếu bạn không yêu cầu, hãy bỏ qua email này.\n",
+		user.FullName, user.UserName, expiresAt.Format("15:04 02/01/2006"), link)
+	return s.Mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Đặt lại mật khẩu", Body: body})
+}
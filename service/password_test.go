@@ -0,0 +1,188 @@
+package service
+
+import (
+	"adong-be/auth/password"
+	"adong-be/mail"
+	"adong-be/models"
+	"adong-be/repository"
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionLog records the users signed out everywhere
+type sessionLog struct {
+	revoked []string
+}
+
+func (s *sessionLog) RevokeAllUserTokens(userID string) error {
+	s.revoked = append(s.revoked, userID)
+	return nil
+}
+
+// outbox keeps the emails sent instead of delivering them
+type outbox struct {
+	sent []mail.Message
+}
+
+func (o *outbox) Send(ctx context.Context, msg mail.Message) error {
+	o.sent = append(o.sent, msg)
+	return nil
+}
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// resetToken returns the token of the reset link in msg
+func resetToken(t *testing.T, msg mail.Message) string {
+	t.Helper()
+	link, err := url.Parse(linkPattern.FindString(msg.Body))
+	require.NoError(t, err)
+	token := link.Query().Get("token")
+	require.NotEmpty(t, token, msg.Body)
+	return token
+}
+
+const currentPassword = "Adong2024"
+
+// newPasswords returns the password service over three users in memory: U1 (bep01) with
+// an email, U2 (bep02) without one and the inactive U3 (bep03)
+func newPasswords(t *testing.T) (*Passwords, *repository.MemoryPasswords, *sessionLog, *outbox) {
+	t.Helper()
+	hash, err := password.Hash(currentPassword)
+	require.NoError(t, err)
+	active, inactive := true, false
+	repo := repository.NewMemoryPasswords([]models.User{
+		{UserID: "U1", UserName: "bep01", Email: "bep01@adong.vn", Password: hash, Active: &active},
+		{UserID: "U2", UserName: "bep02", Password: hash, Active: &active},
+		{UserID: "U3", UserName: "bep03", Email: "bep03@adong.vn", Password: hash, Active: &inactive},
+	})
+	sessions, mailer := &sessionLog{}, &outbox{}
+	s := NewPasswords(repo, sessions, mailer, password.DefaultPolicy(), time.Hour, "https://app.adong.vn/reset-password")
+	return s, repo, sessions, mailer
+}
+
+// passwordIs reports whether pw is the stored password of userID
+func passwordIs(t *testing.T, repo repository.PasswordRepository, userID, pw string) bool {
+	t.Helper()
+	user, err := repo.GetUser(context.Background(), userID)
+	require.NoError(t, err)
+	return password.Verify(user.Password, pw)
+}
+
+func TestPasswordsForgot(t *testing.T) {
+	ctx := context.Background()
+	s, _, _, mailer := newPasswords(t)
+
+	s.Forgot(ctx, "unknown")
+	s.Forgot(ctx, "bep02")
+	s.Forgot(ctx, "bep03@adong.vn")
+	assert.Empty(t, mailer.sent, "unknown, email-less and inactive accounts get nothing")
+
+	s.Forgot(ctx, "BEP01@adong.vn")
+	require.Len(t, mailer.sent, 1)
+	assert.Equal(t, "bep01@adong.vn", mailer.sent[0].To)
+
+	s.Forgot(ctx, "bep01")
+	assert.Len(t, mailer.sent, 1, "a second request within a minute is throttled")
+}
+
+func TestPasswordsReset(t *testing.T) {
+	ctx := context.Background()
+	s, repo, sessions, mailer := newPasswords(t)
+	s.Forgot(ctx, "bep01")
+	require.Len(t, mailer.sent, 1)
+	token := resetToken(t, mailer.sent[0])
+
+	var policyErr *password.PolicyError
+	_, err := s.Reset(ctx, token, "short")
+	require.True(t, errors.As(err, &policyErr), err)
+	assert.Empty(t, sessions.revoked, "a refused password keeps the token and the sessions")
+
+	userID, err := s.Reset(ctx, token, "NewAdong2025")
+	require.NoError(t, err)
+	assert.Equal(t, "U1", userID)
+	assert.True(t, passwordIs(t, repo, "U1", "NewAdong2025"))
+	assert.Equal(t, []string{"U1"}, sessions.revoked)
+
+	_, err = s.Reset(ctx, token, "OtherAdong2025")
+	assert.ErrorIs(t, err, repository.ErrResetTokenInvalid, "a token works only once")
+	assert.True(t, passwordIs(t, repo, "U1", "NewAdong2025"))
+
+	_, err = s.Reset(ctx, "never-issued", "OtherAdong2025")
+	assert.ErrorIs(t, err, repository.ErrResetTokenInvalid)
+}
+
+func TestPasswordsResetExpiredToken(t *testing.T) {
+	ctx := context.Background()
+	s, repo, sessions, _ := newPasswords(t)
+	require.NoError(t, repo.CreateResetToken(ctx, &models.PasswordResetToken{
+		UserID:    "U1",
+		TokenHash: password.HashToken("expired-token"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}))
+
+	_, err := s.Reset(ctx, "expired-token", "NewAdong2025")
+	assert.ErrorIs(t, err, repository.ErrResetTokenInvalid)
+	assert.True(t, passwordIs(t, repo, "U1", currentPassword))
+	assert.Empty(t, sessions.revoked)
+}
+
+func TestPasswordsChange(t *testing.T) {
+	ctx := context.Background()
+	s, repo, sessions, mailer := newPasswords(t)
+
+	assert.ErrorIs(t, s.Change(ctx, "U1", "wrong", "NewAdong2025"), ErrPasswordIncorrect)
+	assert.ErrorIs(t, s.Change(ctx, "U1", currentPassword, currentPassword), ErrSamePassword)
+	var policyErr *password.PolicyError
+	assert.True(t, errors.As(s.Change(ctx, "U1", currentPassword, "bep01Adong2025"), &policyErr), "contains the user name")
+	assert.ErrorIs(t, s.Change(ctx, "U9", currentPassword, "NewAdong2025"), repository.ErrNotFound)
+	assert.Empty(t, sessions.revoked)
+
+	s.Forgot(ctx, "bep01")
+	require.Len(t, mailer.sent, 1)
+	require.NoError(t, s.Change(ctx, "U1", currentPassword, "NewAdong2025"))
+	assert.True(t, passwordIs(t, repo, "U1", "NewAdong2025"))
+	assert.Equal(t, []string{"U1"}, sessions.revoked)
+
+	_, err := s.Reset(ctx, resetToken(t, mailer.sent[0]), "OtherAdong2025")
+	assert.ErrorIs(t, err, repository.ErrResetTokenInvalid, "changing the password drops outstanding reset links")
+}
+
+func TestPasswordsAdminReset(t *testing.T) {
+	ctx := context.Background()
+	s, repo, sessions, mailer := newPasswords(t)
+
+	reset, err := s.AdminReset(ctx, "admin", "U1")
+	require.NoError(t, err)
+	assert.True(t, reset.EmailSent)
+	require.Len(t, mailer.sent, 1)
+	assert.False(t, passwordIs(t, repo, "U1", currentPassword), "the current password stops working")
+	assert.Equal(t, []string{"U1"}, sessions.revoked)
+	_, err = s.Reset(ctx, resetToken(t, mailer.sent[0]), "NewAdong2025")
+	assert.NoError(t, err)
+
+	reset, err = s.AdminReset(ctx, "admin", "U2")
+	require.NoError(t, err)
+	assert.False(t, reset.EmailSent, "without an email the admin hands the link over")
+	assert.Contains(t, reset.URL, "token=")
+	assert.Equal(t, []string{"U1", "U1", "U2"}, sessions.revoked)
+
+	_, err = s.AdminReset(ctx, "admin", "U9")
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func TestPasswordsResetLink(t *testing.T) {
+	s := &Passwords{ResetURL: "https://app.example.com/reset-password?lang=vi"}
+	link, err := url.Parse(s.resetLink("abc-_123"))
+	assert.NoError(t, err)
+	assert.Equal(t, "app.example.com", link.Host)
+	assert.Equal(t, "/reset-password", link.Path)
+	assert.Equal(t, "vi", link.Query().Get("lang"))
+	assert.Equal(t, "abc-_123", link.Query().Get("token"))
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"adong-be/models"
+	"adong-be/repository"
+	"context"
+)
+
+// Recipes manages dishes and their recipe standards
+type Recipes struct {
+	Repo repository.RecipeRepository
+}
+
+// NewRecipes returns the recipe service over repo
+func NewRecipes(repo repository.RecipeRepository) *Recipes {
+	return &Recipes{Repo: repo}
+}
+
+// ListDishes returns one page of the dishes matching params.Search and their total
+func (s *Recipes) ListDishes(ctx context.Context, params models.PaginationParams) ([]models.Dish, int64, error) {
+	return s.Repo.ListDishes(ctx, params)
+}
+
+func (s *Recipes) GetDish(ctx context.Context, dishID string) (*models.Dish, error) {
+	return s.Repo.GetDish(ctx, dishID)
+}
+
+func (s *Recipes) CreateDish(ctx context.Context, dish *models.Dish) error {
+	return s.Repo.CreateDish(ctx, dish)
+}
+
+func (s *Recipes) UpdateDish(ctx context.Context, dish *models.Dish) error {
+	return s.Repo.UpdateDish(ctx, dish)
+}
+
+func (s *Recipes) DeleteDish(ctx context.Context, dishID string) error {
+	return s.Repo.DeleteDish(ctx, dishID)
+}
+
+// ListStandards returns one page of the recipe standards of a dish in kitchens and their total
+func (s *Recipes) ListStandards(ctx context.Context, dishID string, kitchens repository.Kitchens, params models.PaginationParams) ([]models.RecipeStandard, int64, error) {
+	return s.Repo.ListStandards(ctx, dishID, kitchens, params)
+}
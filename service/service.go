@@ -0,0 +1,29 @@
+// Package service holds the business rules of orders, inventory, suppliers, recipes and
+// users on top of the repository interfaces. Handlers get their services through
+// constructors, so the rules run the same against Postgres and the in-memory repositories.
+//
+// Missing records are reported as repository.ErrNotFound and records in kitchens outside
+// the caller's scope as utils.ErrKitchenForbidden.
+package service
+
+import (
+	"adong-be/repository"
+	"adong-be/utils"
+	"errors"
+	"fmt"
+)
+
+// ErrAlreadyApproved is returned when approving an inventory document a second time
+var ErrAlreadyApproved = errors.New("document is already approved")
+
+// ErrVersionMismatch is returned when a record changed since the version the caller read,
+// see package etag
+var ErrVersionMismatch = errors.New("record changed since it was read")
+
+// authorize fails with utils.ErrKitchenForbidden unless kitchens contains kitchenID
+func authorize(kitchens repository.Kitchens, kitchenID string) error {
+	if !kitchens.Contains(kitchenID) {
+		return fmt.Errorf("kitchen %s: %w", kitchenID, utils.ErrKitchenForbidden)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/repository"
+	"context"
+)
+
+// Suppliers manages the supplier master data
+type Suppliers struct {
+	Repo repository.SupplierRepository
+}
+
+// NewSuppliers returns the supplier service over repo
+func NewSuppliers(repo repository.SupplierRepository) *Suppliers {
+	return &Suppliers{Repo: repo}
+}
+
+// List returns one page of the suppliers matching params.Search and their total
+func (s *Suppliers) List(ctx context.Context, params models.PaginationParams) ([]models.Supplier, int64, error) {
+	return s.Repo.List(ctx, params)
+}
+
+func (s *Suppliers) Get(ctx context.Context, supplierID string) (*models.Supplier, error) {
+	return s.Repo.Get(ctx, supplierID)
+}
+
+func (s *Suppliers) Create(ctx context.Context, supplier *models.Supplier) error {
+	return s.Repo.Create(ctx, supplier)
+}
+
+// Update saves supplier, previously stored as before. A deactivated supplier's products
+// must not be offered anymore, so deactivation also deactivates its prices.
+func (s *Suppliers) Update(ctx context.Context, before models.Supplier, supplier *models.Supplier) error {
+	wasActive := before.Active == nil || *before.Active
+	deactivated := wasActive && supplier.Active != nil && !*supplier.Active
+	if err := s.Repo.Update(ctx, supplier, deactivated); err != nil {
+		return err
+	}
+	if deactivated {
+		logger.From(ctx).Info("UpdateSupplier deactivated supplier prices", "supplier_id", supplier.SupplierID)
+	}
+	return nil
+}
+
+func (s *Suppliers) Delete(ctx context.Context, supplierID string) error {
+	return s.Repo.Delete(ctx, supplierID)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"adong-be/auth/password"
+	"adong-be/logger"
+	"adong-be/models"
+	"adong-be/repository"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrPasswordRequired is returned when creating a user without a password
+var ErrPasswordRequired = errors.New("password is required")
+
+// UnknownRoleError rejects a role that is not defined in the roles table
+type UnknownRoleError struct {
+	Role string
+}
+
+func (e *UnknownRoleError) Error() string {
+	return "Unknown role " + e.Role
+}
+
+// SessionRevoker signs a user out of every session
+type SessionRevoker interface {
+	RevokeAllUserTokens(userID string) error
+}
+
+// Users manages user accounts. Passwords are checked against the password policy and
+// stored as bcrypt hashes; a *password.PolicyError lists the violated rules.
+type Users struct {
+	Repo     repository.UserRepository
+	Sessions SessionRevoker
+	Policy   password.Policy
+}
+
+// NewUsers returns the user service over repo, signing users out through sessions and
+// checking passwords against policy
+func NewUsers(repo repository.UserRepository, sessions SessionRevoker, policy password.Policy) *Users {
+	return &Users{Repo: repo, Sessions: sessions, Policy: policy}
+}
+
+// List returns one page of the users matching params.Search and their total
+func (s *Users) List(ctx context.Context, params models.PaginationParams) ([]models.User, int64, error) {
+	return s.Repo.List(ctx, params)
+}
+
+func (s *Users) Get(ctx context.Context, userID string) (*models.User, error) {
+	return s.Repo.Get(ctx, userID)
+}
+
+// Create stores a new user account with pw as its password
+func (s *Users) Create(ctx context.Context, user *models.User, pw string) error {
+	if pw == "" {
+		return ErrPasswordRequired
+	}
+	if err := s.validRole(ctx, user.Role); err != nil {
+		return err
+	}
+	hash, err := s.hash(pw, user.UserName)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	user.PlainPassword = ""
+	// Service accounts are created through the service account API
+	user.AccountType = models.AccountTypeUser
+	user.LastLoginAt = nil
+	return s.Repo.Create(ctx, user)
+}
+
+// Update saves user, previously stored as before. The stored credentials are kept unless a
+// new password pw is given; a new password or deactivation signs the user out everywhere.
+func (s *Users) Update(ctx context.Context, before models.User, user *models.User, pw string) error {
+	if user.Role != before.Role {
+		if err := s.validRole(ctx, user.Role); err != nil {
+			return err
+		}
+	}
+	user.Password = before.Password
+	user.PlainPassword = before.PlainPassword
+	user.AccountType = before.AccountType
+	user.LastLoginAt = before.LastLoginAt
+	if pw != "" {
+		hash, err := s.hash(pw, user.UserName)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+		user.PlainPassword = ""
+	}
+	if err := s.Repo.Update(ctx, user); err != nil {
+		return err
+	}
+
+	deactivated := user.Active != nil && !*user.Active
+	if (pw != "" || deactivated) && s.Sessions != nil {
+		if err := s.Sessions.RevokeAllUserTokens(user.UserID); err != nil {
+			logger.From(ctx).Error("UpdateUser revoke sessions error", "id", user.UserID, "error", err)
+		}
+	}
+	return nil
+}
+
+func (s *Users) Delete(ctx context.Context, userID string) error {
+	return s.Repo.Delete(ctx, userID)
+}
+
+// validRole accepts an empty role and the roles defined in the roles table
+func (s *Users) validRole(ctx context.Context, role string) error {
+	if role == "" {
+		return nil
+	}
+	ok, err := s.Repo.RoleExists(ctx, role)
+	if err != nil {
+		return fmt.Errorf("validate role %s: %w", role, err)
+	}
+	if !ok {
+		return &UnknownRoleError{Role: role}
+	}
+	return nil
+}
+
+// hash validates pw against the password policy and returns its bcrypt hash
+func (s *Users) hash(pw, username string) (string, error) {
+	if err := s.Policy.Validate(pw, username); err != nil {
+		return "", err
+	}
+	hash, err := password.Hash(pw)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return hash, nil
+}
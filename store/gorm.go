@@ -1,20 +1,43 @@
 package store
 
 import (
+	"adong-be/auth/password"
+	"adong-be/config"
 	"adong-be/models"
+	"errors"
 	"time"
 
 	"github.com/hsdfat/go-auth-middleware/core"
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 type Store struct {
 	GormClient *gorm.DB
+	// SessionIdleTimeout signs out sessions unused for longer; zero disables it
+	SessionIdleTimeout time.Duration
+	// PasswordPolicy is checked by the passwords of self-registered users
+	PasswordPolicy password.Policy
 }
 
 var DB *Store = &Store{}
 
+// Open connects to PostgreSQL with the configured connection pool
+func Open(cfg config.DatabaseConfig) (*Store, error) {
+	db, err := gorm.Open(postgres.Open(cfg.URL), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	return &Store{GormClient: db}, nil
+}
+
 // Enhanced UserProvider interface
 type UserProvider interface {
 	GetUserByUsername(username string) (*core.User, error)
@@ -56,12 +79,20 @@ func (s *Store) GetUserByEmail(email string) (*core.User, error) {
 	return convertToCoreUser(user), nil
 }
 
+// UpdateUserLastLogin records a successful login. It runs as a plain statement so logins
+// stay out of the audit log.
 func (s *Store) UpdateUserLastLogin(userID string, lastLogin time.Time) error {
-	return nil
+	return s.GormClient.Exec("UPDATE master_users SET last_login_at = ? WHERE user_id = ?", lastLogin, userID).Error
 }
 
+// IsUserActive is checked on every authenticated request, deactivating a user signs them
+// out at their next request
 func (s *Store) IsUserActive(userID string) (bool, error) {
-	return true, nil
+	var count int64
+	err := s.GormClient.Model(&models.User{}).
+		Where("user_id = ? AND active", userID).
+		Count(&count).Error
+	return count > 0, err
 }
 
 func convertToCoreUser(dbUser models.User) *core.User {
@@ -78,8 +109,9 @@ func convertToCoreUser(dbUser models.User) *core.User {
 	}
 }
 
-// GetUserWithPlainPassword retrieves user with both hashed and plain password
-func (s *Store) GetUserWithPlainPassword(username string) (*models.User, error) {
+// GetUserForLogin retrieves the user row used by the login authenticator, including
+// the legacy plain_password column that is still read during the rehash window
+func (s *Store) GetUserForLogin(username string) (*models.User, error) {
 	var dbUser models.User
 	if err := s.GormClient.First(&dbUser, "user_name = ?", username).Error; err != nil {
 		return nil, err
@@ -87,32 +119,68 @@ func (s *Store) GetUserWithPlainPassword(username string) (*models.User, error)
 	return &dbUser, nil
 }
 
-// UserCreator implementation
+// UpgradePasswordHash stores a new bcrypt hash for the user and wipes the legacy plain text password
+func (s *Store) UpgradePasswordHash(userID, hash string) error {
+	return s.GormClient.Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Updates(map[string]interface{}{"password": hash, "plain_password": nil}).Error
+}
+
+// ClearPlainPassword wipes the legacy plain text password of one user
+func (s *Store) ClearPlainPassword(userID string) error {
+	return s.GormClient.Model(&models.User{}).
+		Where("user_id = ?", userID).
+		Update("plain_password", nil).Error
+}
+
+// CountPlainPasswords returns how many users still have a plain text password stored
+func (s *Store) CountPlainPasswords() (int64, error) {
+	var count int64
+	err := s.GormClient.Model(&models.User{}).
+		Where("plain_password IS NOT NULL AND plain_password <> ''").
+		Count(&count).Error
+	return count, err
+}
+
+// WipePlainPasswords clears every remaining plain text password and returns the number of users affected
+func (s *Store) WipePlainPasswords() (int64, error) {
+	res := s.GormClient.Model(&models.User{}).
+		Where("plain_password IS NOT NULL AND plain_password <> ''").
+		Update("plain_password", nil)
+	return res.RowsAffected, res.Error
+}
+
+// CreateUser implements UserCreator for /auth/register. The password always comes from
+// the client, so it is checked against the password policy and hashed even when it looks
+// like a bcrypt hash.
 func (s *Store) CreateUser(user *core.User) error {
-	// Store plain password
-	plainPassword := user.Password
-
-	// Hash password if not already hashed
-	hashedPassword := user.Password
-	if len(user.Password) < 60 { // bcrypt hashes are 60 chars
-		hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-		if err != nil {
-			return err
-		}
-		hashedPassword = string(hash)
+	if err := s.PasswordPolicy.Validate(user.Password, user.Username); err != nil {
+		return err
+	}
+	hash, err := password.Hash(user.Password)
+	if err != nil {
+		return err
+	}
+	return s.CreateUserWithHash(user, hash)
+}
+
+// CreateUserWithHash creates user with a password already hashed by password.Hash, for
+// internal callers that never hold the plain text password
+func (s *Store) CreateUserWithHash(user *core.User, hash string) error {
+	if !password.IsHash(hash) {
+		return errors.New("password is not a bcrypt hash")
 	}
 
 	active := true
 	dbUser := models.User{
-		UserID:        user.ID,
-		UserName:      user.Username,
-		Password:      hashedPassword,
-		PlainPassword: plainPassword,
-		FullName:      user.Username, // Default to username if not provided
-		Role:          user.Role,
-		Email:         user.Email,
-		Phone:         "",
-		Active:        &active,
+		UserID:   user.ID,
+		UserName: user.Username,
+		Password: hash,
+		FullName: user.Username, // Default to username if not provided
+		Role:     user.Role,
+		Email:    user.Email,
+		Phone:    "",
+		Active:   &active,
 	}
 
 	if err := s.GormClient.Create(&dbUser).Error; err != nil {
@@ -155,33 +223,16 @@ func (s *Store) IsEmailAvailable(email string) (bool, error) {
 	return count == 0, nil
 }
 
-// Helper function to hash passwords
-func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hash), nil
-}
-
-// Helper function to verify passwords
-// Supports both plain text and hashed password verification
-func VerifyPassword(hashedPassword, password string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+// LoadRoles returns every role with its granted permissions, used by the authorizer
+func (s *Store) LoadRoles() ([]models.Role, error) {
+	var roles []models.Role
+	err := s.GormClient.Preload("Permissions").Find(&roles).Error
+	return roles, err
 }
 
-// Helper function to verify password with fallback to plain text
-// First tries bcrypt hash comparison, then falls back to plain text comparison
-func VerifyPasswordWithPlainFallback(hashedPassword, plainPassword, inputPassword string) bool {
-	// First try bcrypt verification
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(inputPassword)); err == nil {
-		return true
-	}
-
-	// Fallback to plain text comparison if available
-	if plainPassword != "" && plainPassword == inputPassword {
-		return true
-	}
-
-	return false
+// LoadUserKitchens returns the kitchens assigned to a user with their per-kitchen role
+func (s *Store) LoadUserKitchens(userID string) ([]models.UserKitchen, error) {
+	var kitchens []models.UserKitchen
+	err := s.GormClient.Where("user_id = ?", userID).Find(&kitchens).Error
+	return kitchens, err
 }
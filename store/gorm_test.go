@@ -0,0 +1,40 @@
+package store
+
+import (
+	"adong-be/auth/password"
+	"adong-be/models"
+	"testing"
+
+	"github.com/hsdfat/go-auth-middleware/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestCreateUser_HashFromClientIsAPassword(t *testing.T) {
+	s := dryRunStore(t, 0)
+	s.GormClient = s.GormClient.Session(&gorm.Session{SkipDefaultTransaction: true})
+	var created models.User
+	require.NoError(t, s.GormClient.Callback().Create().After("gorm:create").Register("test:created", func(tx *gorm.DB) {
+		created = *tx.Statement.Dest.(*models.User)
+	}))
+	hash, err := password.Hash("abc")
+	require.NoError(t, err)
+
+	// A hash sent to /auth/register is checked against the policy like any password
+	s.PasswordPolicy = password.Policy{MinLength: len(hash) + 1}
+	var policyErr *password.PolicyError
+	assert.ErrorAs(t, s.CreateUser(&core.User{ID: "u1", Username: "lan", Password: hash}), &policyErr)
+
+	// and stored hashed again, so signing in needs the hash itself rather than "abc"
+	s.PasswordPolicy = password.Policy{MinLength: 8}
+	require.NoError(t, s.CreateUser(&core.User{ID: "u1", Username: "lan", Password: hash}))
+	assert.NotEqual(t, hash, created.Password)
+	assert.True(t, password.Verify(created.Password, hash))
+	assert.False(t, password.Verify(created.Password, "abc"))
+}
+
+func TestCreateUserWithHash_RequiresAHash(t *testing.T) {
+	s := dryRunStore(t, 0)
+	assert.Error(t, s.CreateUserWithHash(&core.User{ID: "u1", Username: "lan"}, "Secret123"))
+}
@@ -0,0 +1,11 @@
+package store
+
+import (
+	"adong-be/models"
+	"context"
+)
+
+// RecordFailedLogin appends a rejected login attempt to the failed login log
+func (s *Store) RecordFailedLogin(ctx context.Context, entry *models.FailedLogin) error {
+	return s.GormClient.WithContext(ctx).Create(entry).Error
+}
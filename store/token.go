@@ -2,12 +2,19 @@ package store
 
 import (
 	"adong-be/logger"
+	"adong-be/metrics"
 	"adong-be/models"
+	"context"
 	"time"
 
 	"github.com/hsdfat/go-auth-middleware/core"
+	"gorm.io/gorm"
 )
 
+// activityResolution is how stale the recorded activity of a session may get: the auth
+// middleware reports every request, only one write per session and period reaches the database
+const activityResolution = time.Minute
+
 var TokenInterface core.TokenStorage
 
 // Enhanced TokenStorage interface for refresh token support
@@ -75,23 +82,70 @@ func (s *Store) GetRefreshToken(sessionID string) (string, error) {
 }
 
 func (s *Store) IsAccessTokenValid(sessionID string) (bool, error) {
-	var tokenPair models.TokenPair
-	if err := s.GormClient.First(&tokenPair, "session_id = ?", sessionID).Error; err != nil {
+	tokenPair, err := s.GetTokenPair(sessionID)
+	if err != nil {
 		return false, err
 	}
-	return tokenPair.AccessToken != "" && tokenPair.AccessExpiresAt.After(time.Now()), nil
+	now := time.Now()
+	if tokenPair.AccessToken == "" || !tokenPair.AccessExpiresAt.After(now) {
+		return false, nil
+	}
+	return !s.endIfIdle(tokenPair, now), nil
 }
 
 func (s *Store) IsRefreshTokenValid(sessionID string) (bool, error) {
-	var tokenPair models.TokenPair
-	if err := s.GormClient.First(&tokenPair, "session_id = ?", sessionID).Error; err != nil {
+	tokenPair, err := s.GetTokenPair(sessionID)
+	if err != nil {
 		return false, err
 	}
-	return tokenPair.RefreshToken != "" && tokenPair.RefreshExpiresAt.After(time.Now()), nil
+	now := time.Now()
+	if tokenPair.RefreshToken == "" || !tokenPair.RefreshExpiresAt.After(now) {
+		return false, nil
+	}
+	return !s.endIfIdle(tokenPair, now), nil
+}
+
+// idle reports whether a session last active at lastActivity has passed the idle timeout
+func (s *Store) idle(lastActivity, now time.Time) bool {
+	return s.SessionIdleTimeout > 0 && now.Sub(lastActivity) > s.SessionIdleTimeout
+}
+
+// endIfIdle signs out the session of tokenPair once it passed the idle timeout. Neither its
+// access nor its refresh token is accepted afterwards.
+func (s *Store) endIfIdle(tokenPair *models.TokenPair, now time.Time) bool {
+	if !s.idle(tokenPair.LastActivity, now) {
+		return false
+	}
+	if _, err := s.endSessions(context.Background(), "session_id = ?", tokenPair.SessionID); err != nil {
+		logger.Log.Error("end idle session error", "session_id", tokenPair.SessionID, "error", err)
+	}
+	logger.Log.Info("session ended after idle timeout", "session_id", tokenPair.SessionID, "user_id", tokenPair.UserID)
+	return true
 }
 
+// endSessions signs out the sessions matching where, which applies to both the token pairs
+// and the sessions table. The tokens are deleted; the session rows stay, inactive and with
+// their logout time, until the next cleanup. It returns the number of sessions ended.
+func (s *Store) endSessions(ctx context.Context, where string, args ...interface{}) (int64, error) {
+	var ended int64
+	err := s.GormClient.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where(where, args...).Delete(&models.TokenPair{})
+		if res.Error != nil {
+			return res.Error
+		}
+		ended = res.RowsAffected
+		return tx.Model(&models.UserSession{}).
+			Where(where, args...).
+			Where("is_active").
+			UpdateColumns(map[string]interface{}{"is_active": false, "logout_time": time.Now()}).Error
+	})
+	return ended, err
+}
+
+// DeleteTokenPair is called on logout and ends the session
 func (s *Store) DeleteTokenPair(sessionID string) error {
-	return s.GormClient.Delete(&models.TokenPair{}, "session_id = ?", sessionID).Error
+	_, err := s.endSessions(context.Background(), "session_id = ?", sessionID)
+	return err
 }
 
 func (s *Store) RefreshTokenPair(sessionID string, newAccessToken, newRefreshToken string, accessExpiresAt, refreshExpiresAt time.Time) error {
@@ -103,83 +157,154 @@ func (s *Store) RefreshTokenPair(sessionID string, newAccessToken, newRefreshTok
 	}).Error
 }
 
+// RevokeAllUserTokens ends every session of a user
 func (s *Store) RevokeAllUserTokens(userID string) error {
-	return s.GormClient.Delete(&models.TokenPair{}, "user_id = ?", userID).Error
+	_, err := s.endSessions(context.Background(), "user_id = ?", userID)
+	return err
 }
 
-func (s *Store) GetUserActiveSessions(userID string) ([]string, error) {
-	var tokenPairs []models.TokenPair
-	if err := s.GormClient.Where("user_id = ?", userID).Find(&tokenPairs).Error; err != nil {
-		return nil, err
+// usablePairs restricts a token pair query to sessions that can still be used or refreshed
+func (s *Store) usablePairs(db *gorm.DB, now time.Time) *gorm.DB {
+	db = db.Where("auth_token_pairs.refresh_expires_at > ?", now)
+	if s.SessionIdleTimeout > 0 {
+		db = db.Where("auth_token_pairs.last_activity >= ?", now.Add(-s.SessionIdleTimeout))
 	}
+	return db
+}
+
+func (s *Store) GetUserActiveSessions(userID string) ([]string, error) {
 	var sessionIDs []string
-	for _, tokenPair := range tokenPairs {
-		sessionIDs = append(sessionIDs, tokenPair.SessionID)
-	}
-	return sessionIDs, nil
+	err := s.usablePairs(s.GormClient.Model(&models.TokenPair{}), time.Now()).
+		Where("user_id = ?", userID).
+		Pluck("session_id", &sessionIDs).Error
+	return sessionIDs, err
 }
 
+// UpdateSessionActivity records that a session was used, at most once per activityResolution.
+// The sessions table is written without hooks so the update does not count as a modification.
 func (s *Store) UpdateSessionActivity(sessionID string, lastActivity time.Time) error {
-	return s.GormClient.Model(&models.TokenPair{}).Where("session_id = ?", sessionID).Update("last_activity", lastActivity).Error
+	stale := lastActivity.Add(-activityResolution)
+	if err := s.GormClient.Model(&models.TokenPair{}).
+		Where("session_id = ? AND last_activity < ?", sessionID, stale).
+		UpdateColumn("last_activity", lastActivity).Error; err != nil {
+		return err
+	}
+	return s.GormClient.Model(&models.UserSession{}).
+		Where("session_id = ? AND last_activity < ?", sessionID, stale).
+		UpdateColumn("last_activity", lastActivity).Error
 }
 
 func (s *Store) StoreUserSession(session core.UserSession) error {
-	// Convert core.UserSession to models.UserSession
+	active := true
 	userSession := models.UserSession{
 		SessionID:    session.SessionID,
 		UserID:       session.UserID,
 		IPAddress:    session.IPAddress,
 		UserAgent:    session.UserAgent,
 		LastActivity: session.LastActivity,
-		// IsActive:     session.IsActive,
-		// LoginTime:    session.LoginTime,
-		// LogoutTime:   session.LogoutTime,
+		IsActive:     &active,
+		LoginTime:    session.CreatedAt,
 	}
 	return s.GormClient.Create(&userSession).Error
 }
 
 func (s *Store) GetUserSession(sessionID string) (*core.UserSession, error) {
 	var userSession models.UserSession
-	if err := s.GormClient.First(&userSession, "session_id = ?", sessionID).Error; err != nil {
+	if err := s.GormClient.Preload("User").First(&userSession, "session_id = ?", sessionID).Error; err != nil {
 		return nil, err
 	}
 
 	// Convert models.UserSession to core.UserSession
-	return &core.UserSession{
+	session := &core.UserSession{
 		SessionID:    userSession.SessionID,
 		UserID:       userSession.UserID,
+		CreatedAt:    userSession.LoginTime,
+		LastActivity: userSession.LastActivity,
 		IPAddress:    userSession.IPAddress,
 		UserAgent:    userSession.UserAgent,
-		LastActivity: userSession.LastActivity,
-		// IsActive:     userSession.IsActive,
-		// LoginTime:    userSession.LoginTime,
-		// LogoutTime:   userSession.LogoutTime,
-	}, nil
+	}
+	if userSession.User != nil {
+		session.Username = userSession.User.UserName
+		session.Email = userSession.User.Email
+		session.Role = userSession.User.Role
+	}
+	return session, nil
 }
 
 func (s *Store) DeleteUserSession(sessionID string) error {
-	// Update logout time before deleting
-	now := time.Now()
-	if err := s.GormClient.Model(&models.UserSession{}).Where("session_id = ?", sessionID).Update("logout_time", now).Error; err != nil {
-		// If session doesn't exist, continue with token deletion
-		logger.Log.Error("Failed to update session logout time", "error", err)
+	_, err := s.endSessions(context.Background(), "session_id = ?", sessionID)
+	if err != nil {
+		logger.Log.Error("Fail to delete user session", "error", err)
 	}
+	return err
+}
 
-	// Delete from both token pairs and user sessions
-	if err := s.GormClient.Delete(&models.TokenPair{}, "session_id = ?", sessionID).Error; err != nil {
-		logger.Log.Error("Fail to delete token pair", "error", err)
-		return err
+// CleanupExpiredTokens deletes the token pairs whose refresh token expired or whose session
+// passed the idle timeout, then the session rows left without a token pair
+func (s *Store) CleanupExpiredTokens() error {
+	now := time.Now()
+	expired := s.GormClient.Where("refresh_expires_at <= ?", now)
+	if s.SessionIdleTimeout > 0 {
+		expired = expired.Or("last_activity < ?", now.Add(-s.SessionIdleTimeout))
 	}
-	err := s.GormClient.Delete(&models.UserSession{}, "session_id = ?", sessionID).Error
-	if err != nil {
-		logger.Log.Error("Fail to delete user session", "error", err)
-		return err
+	res := expired.Delete(&models.TokenPair{})
+	if res.Error != nil {
+		return res.Error
+	}
+	orphans := s.GormClient.
+		Where("NOT EXISTS (SELECT 1 FROM auth_token_pairs WHERE auth_token_pairs.session_id = auth_user_sessions.session_id)").
+		Delete(&models.UserSession{})
+	if orphans.Error != nil {
+		return orphans.Error
+	}
+	if res.RowsAffected > 0 || orphans.RowsAffected > 0 {
+		logger.Log.Info("expired sessions cleaned up", "token_pairs", res.RowsAffected, "sessions", orphans.RowsAffected)
 	}
 	return nil
 }
 
-func (s *Store) CleanupExpiredTokens() error {
-	return s.GormClient.Where("access_expires_at < ?", time.Now()).Delete(&models.TokenPair{}).Error
+// CleanupTokensEvery runs CleanupExpiredTokens every interval until ctx is done, recording
+// each run as the token_cleanup job
+func (s *Store) CleanupTokensEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			err := s.CleanupExpiredTokens()
+			if err != nil {
+				logger.Log.Error("cleanup expired tokens error", "error", err)
+			}
+			metrics.ObserveJob("token_cleanup", start, err)
+		}
+	}
+}
+
+// ListUserSessions returns the sessions of a user that are still signed in, most recently
+// active first
+func (s *Store) ListUserSessions(ctx context.Context, userID string) ([]models.UserSession, error) {
+	var sessions []models.UserSession
+	query := s.GormClient.WithContext(ctx).
+		Joins("JOIN auth_token_pairs ON auth_token_pairs.session_id = auth_user_sessions.session_id").
+		Where("auth_user_sessions.user_id = ? AND auth_user_sessions.is_active", userID)
+	err := s.usablePairs(query, time.Now()).
+		Order("auth_user_sessions.last_activity DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// EndUserSession signs out one session of a user, reporting whether it was still signed in
+func (s *Store) EndUserSession(ctx context.Context, userID, sessionID string) (bool, error) {
+	ended, err := s.endSessions(ctx, "user_id = ? AND session_id = ?", userID, sessionID)
+	return ended > 0, err
+}
+
+// EndAllUserSessions signs out every session of a user and returns how many there were
+func (s *Store) EndAllUserSessions(ctx context.Context, userID string) (int64, error) {
+	return s.endSessions(ctx, "user_id = ?", userID)
 }
 
 func (s *Store) GetTokenPair(sessionID string) (*models.TokenPair, error) {
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"adong-be/models"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func dryRunStore(t *testing.T, idleTimeout time.Duration) *Store {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN: "host=127.0.0.1 port=1 user=test dbname=test sslmode=disable",
+	}), &gorm.Config{DisableAutomaticPing: true, DryRun: true})
+	require.NoError(t, err)
+	return &Store{GormClient: db, SessionIdleTimeout: idleTimeout}
+}
+
+func TestSessionIdle(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		timeout      time.Duration
+		lastActivity time.Time
+		want         bool
+	}{
+		{"disabled", 0, now.Add(-24 * time.Hour), false},
+		{"recent", 30 * time.Minute, now.Add(-10 * time.Minute), false},
+		{"at the timeout", 30 * time.Minute, now.Add(-30 * time.Minute), false},
+		{"past the timeout", 30 * time.Minute, now.Add(-31 * time.Minute), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Store{SessionIdleTimeout: tt.timeout}
+			assert.Equal(t, tt.want, s.idle(tt.lastActivity, now))
+		})
+	}
+}
+
+func TestUsablePairs(t *testing.T) {
+	now := time.Now()
+	query := func(s *Store) string {
+		return s.GormClient.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			var pairs []models.TokenPair
+			return s.usablePairs(tx, now).Find(&pairs)
+		})
+	}
+
+	sql := query(dryRunStore(t, 0))
+	assert.Contains(t, sql, "auth_token_pairs.refresh_expires_at >")
+	assert.NotContains(t, sql, "last_activity")
+
+	sql = query(dryRunStore(t, 30*time.Minute))
+	assert.True(t, strings.Contains(sql, "auth_token_pairs.last_activity >="), sql)
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"adong-be/models"
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTwoFactorNotEnrolled is returned when a user has no confirmed second factor
+var ErrTwoFactorNotEnrolled = errors.New("two-factor authentication is not enabled")
+
+// GetTwoFactor returns the second factor of a user, nil when they never enrolled
+func (s *Store) GetTwoFactor(ctx context.Context, userID string) (*models.UserTwoFactor, error) {
+	var tf models.UserTwoFactor
+	err := s.GormClient.WithContext(ctx).First(&tf, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tf, nil
+}
+
+// SaveTwoFactorSecret starts an enrollment: the secret replaces any unconfirmed one and only
+// becomes active once EnableTwoFactor is called
+func (s *Store) SaveTwoFactorSecret(ctx context.Context, userID, secret string) error {
+	tf := models.UserTwoFactor{UserID: userID, TOTPSecret: secret}
+	return s.GormClient.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"totp_secret": secret, "enabled_at": nil, "last_used_step": 0, "modified_date": time.Now()}),
+	}).Create(&tf).Error
+}
+
+// EnableTwoFactor confirms the enrollment with the step of the first valid code and stores
+// the hashes of the user's recovery codes
+func (s *Store) EnableTwoFactor(ctx context.Context, userID string, step int64, codeHashes []string) error {
+	return s.GormClient.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		res := tx.Model(&models.UserTwoFactor{}).
+			Where("user_id = ? AND enabled_at IS NULL", userID).
+			Updates(map[string]interface{}{"enabled_at": now, "last_used_step": step})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrTwoFactorNotEnrolled
+		}
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// DisableTwoFactor removes the second factor and the recovery codes of a user
+func (s *Store) DisableTwoFactor(ctx context.Context, userID string) error {
+	return s.GormClient.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.UserTwoFactor{}).Error
+	})
+}
+
+// UseTOTPStep records that a code of step was accepted. It reports false when a code of the
+// same or a later step was already used, which makes every code single-use.
+func (s *Store) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res := s.GormClient.WithContext(ctx).Model(&models.UserTwoFactor{}).
+		Where("user_id = ? AND enabled_at IS NOT NULL AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	return res.RowsAffected > 0, res.Error
+}
+
+// UseRecoveryCode consumes the unused recovery code stored under hash, reporting whether
+// there was one
+func (s *Store) UseRecoveryCode(ctx context.Context, userID, hash string) (bool, error) {
+	res := s.GormClient.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	return res.RowsAffected > 0, res.Error
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores new ones
+func (s *Store) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	return s.GormClient.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has left
+func (s *Store) CountRecoveryCodes(ctx context.Context, userID string) (int64, error) {
+	var count int64
+	err := s.GormClient.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID string, codeHashes []string) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// RequiresTwoFactor reports whether the global role of the user, or one of their
+// per-kitchen roles, makes the second factor mandatory
+func (s *Store) RequiresTwoFactor(ctx context.Context, userID, role string) (bool, error) {
+	var count int64
+	err := s.GormClient.WithContext(ctx).Model(&models.Role{}).
+		Where("require_two_factor AND (role_name = ? OR role_name IN (?))", role,
+			s.GormClient.Model(&models.UserKitchen{}).Select("role_name").Where("user_id = ? AND role_name IS NOT NULL", userID)).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,142 @@
+// Package telemetry traces requests with OpenTelemetry: Setup installs the tracer provider
+// and its exporter, Middleware opens a span per request and InstrumentDB a child span per
+// SQL statement run with the request context, e.g. db.WithContext(c). Incoming traceparent
+// headers are honoured, so the spans join the trace of the caller.
+package telemetry
+
+import (
+	"adong-be/config"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// ServiceName identifies the API in traces
+const ServiceName = "adong-be"
+
+// untraced are the probe and scrape routes, called every few seconds and of no interest
+var untraced = map[string]bool{
+	"/health":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Setup installs the global tracer provider for cfg. The returned shutdown flushes the
+// spans still buffered and must run before the process exits. With the none exporter spans
+// are not recorded, but trace context is still passed on.
+func Setup(ctx context.Context, cfg config.TracingConfig) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	switch cfg.Exporter {
+	case config.TracingExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case config.TracingExporterOTLP:
+		var opts []otlptracehttp.Option
+		if cfg.OTLPEndpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	case config.TracingExporterStdout:
+		exporter, err = stdouttrace.New()
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("trace resource: %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Follow the caller's decision, sample the traces started here
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware opens a span for every request but the probes, named after its route
+func Middleware() gin.HandlerFunc {
+	return otelgin.Middleware(ServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return !untraced[r.URL.Path]
+	}))
+}
+
+// InstrumentDB records a span for every statement db runs, as a child of the span in the
+// statement context. Query arguments are left out, they may hold passwords and tokens.
+func InstrumentDB(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("telemetry:before_create", startSpan("create")),
+		cb.Create().After("gorm:create").Register("telemetry:after_create", endSpan),
+		cb.Query().Before("gorm:query").Register("telemetry:before_query", startSpan("query")),
+		cb.Query().After("gorm:query").Register("telemetry:after_query", endSpan),
+		cb.Update().Before("gorm:update").Register("telemetry:before_update", startSpan("update")),
+		cb.Update().After("gorm:update").Register("telemetry:after_update", endSpan),
+		cb.Delete().Before("gorm:delete").Register("telemetry:before_delete", startSpan("delete")),
+		cb.Delete().After("gorm:delete").Register("telemetry:after_delete", endSpan),
+		cb.Row().Before("gorm:row").Register("telemetry:before_row", startSpan("row")),
+		cb.Row().After("gorm:row").Register("telemetry:after_row", endSpan),
+		cb.Raw().Before("gorm:raw").Register("telemetry:before_raw", startSpan("raw")),
+		cb.Raw().After("gorm:raw").Register("telemetry:after_raw", endSpan),
+	)
+}
+
+const parentKey = "telemetry:parent"
+
+var tracer = otel.Tracer("adong-be/telemetry")
+
+func startSpan(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctx, _ := tracer.Start(parent, "db."+operation, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemNamePostgreSQL))
+		db.InstanceSet(parentKey, parent)
+		db.Statement.Context = ctx
+	}
+}
+
+// endSpan ends the statement's span and gives the statement its context back, so the next
+// statement of a reused session is not recorded as a child of this one
+func endSpan(db *gorm.DB) {
+	parent, ok := db.InstanceGet(parentKey)
+	if !ok {
+		return
+	}
+	span := trace.SpanFromContext(db.Statement.Context)
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		semconv.DBCollectionName(db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+	span.End()
+	db.Statement.Context = parent.(context.Context)
+}
@@ -2,22 +2,63 @@ package utils
 
 import (
 	"adong-be/models"
+	"adong-be/rbac"
 	"adong-be/store"
 	"errors"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// ErrKitchenForbidden is returned when the caller asks for a kitchen outside their scope
+var ErrKitchenForbidden = errors.New("access to this kitchen is not allowed")
+
 // UserKitchenScope holds authorization info for the current user
 type UserKitchenScope struct {
-	User        *models.User
-	IsAdmin     bool
-	KitchenIDs  []string
+	IsAdmin    bool
+	KitchenIDs []string
+}
+
+// Allows reports whether the scope covers kitchenID
+func (s *UserKitchenScope) Allows(kitchenID string) bool {
+	if s.IsAdmin {
+		return true
+	}
+	for _, kid := range s.KitchenIDs {
+		if kid == kitchenID {
+			return true
+		}
+	}
+	return false
 }
 
-// GetUserKitchenScope loads the current user and the list of kitchens they can access.
-// Admin users have IsAdmin=true and KitchenIDs left empty (meaning "all kitchens").
+// Filter restricts db to the scope on column. A non-empty kitchenID narrows the query to
+// that kitchen and fails with ErrKitchenForbidden when it is outside the scope.
+func (s *UserKitchenScope) Filter(db *gorm.DB, column, kitchenID string) (*gorm.DB, error) {
+	if kitchenID != "" {
+		if !s.Allows(kitchenID) {
+			return nil, ErrKitchenForbidden
+		}
+		return db.Where(column+" = ?", kitchenID), nil
+	}
+	if s.IsAdmin {
+		return db, nil
+	}
+	if len(s.KitchenIDs) == 0 {
+		return db.Where("1 = 0"), nil
+	}
+	return db.Where(column+" IN ?", s.KitchenIDs), nil
+}
+
+// GetUserKitchenScope returns the kitchens the current user can access.
+// On routes declaring a permission it is the set of kitchens where the user holds that
+// permission (see rbac.Authorizer.Require); otherwise all kitchens assigned in user_kitchens.
+// Superusers have IsAdmin=true and KitchenIDs left empty (meaning "all kitchens").
 func GetUserKitchenScope(c *gin.Context) (*UserKitchenScope, error) {
+	if scope, ok := rbac.ScopeFrom(c); ok {
+		return &UserKitchenScope{IsAdmin: scope.AllKitchens, KitchenIDs: scope.KitchenIDs}, nil
+	}
+
 	identity, ok := c.Get("identity")
 	if !ok {
 		return nil, errors.New("missing identity in context")
@@ -33,34 +74,28 @@ func GetUserKitchenScope(c *gin.Context) (*UserKitchenScope, error) {
 		return nil, err
 	}
 
-	scope := &UserKitchenScope{
-		User: &user,
-	}
+	scope := &UserKitchenScope{}
 
-	if user.Role == "Admin" {
+	var superuser int64
+	if err := store.DB.GormClient.Model(&models.Role{}).
+		Where("role_name = ? AND is_superuser = true", user.Role).
+		Count(&superuser).Error; err != nil {
+		return nil, err
+	}
+	if superuser > 0 {
 		scope.IsAdmin = true
 		return scope, nil
 	}
 
-	// Load kitchen IDs from the join table user_kitchens
-	type userKitchenRow struct {
-		KitchenID string `gorm:"column:kitchen_id"`
-	}
-	var rows []userKitchenRow
-	if err := store.DB.GormClient.
-		Table("user_kitchens").
-		Where("user_id = ?", userID).
-		Scan(&rows).Error; err != nil {
+	kitchens, err := store.DB.LoadUserKitchens(userID)
+	if err != nil {
 		return nil, err
 	}
-
-	for _, r := range rows {
-		if r.KitchenID != "" {
-			scope.KitchenIDs = append(scope.KitchenIDs, r.KitchenID)
+	for _, k := range kitchens {
+		if k.KitchenID != "" {
+			scope.KitchenIDs = append(scope.KitchenIDs, k.KitchenID)
 		}
 	}
 
 	return scope, nil
 }
-
-